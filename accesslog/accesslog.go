@@ -0,0 +1,240 @@
+// Package accesslog writes one line per proxied, mocked, or replayed request,
+// independently of the application log, in Common Log Format or as JSON lines.
+package accesslog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"mimic/config"
+)
+
+// Output formats
+const (
+	FormatCommon = "common"
+	FormatJSON   = "json"
+)
+
+// Match results recorded on an entry
+const (
+	MatchHit         = "hit"         // A mock answered from a recording
+	MatchMiss        = "miss"        // A mock had no recording for the request
+	MatchRecorded    = "recorded"    // A proxied exchange was saved
+	MatchPassthrough = "passthrough" // A proxied exchange was forwarded without being saved
+	MatchPassed      = "passed"      // A replayed interaction matched its recording
+	MatchFailed      = "failed"      // A replayed interaction did not match
+)
+
+const commonTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// Entry describes one request; handlers fill in the session and match result as they learn them
+type Entry struct {
+	Time       time.Time
+	Proxy      string
+	Mode       string
+	RemoteAddr string
+	Method     string
+	Path       string
+	Protocol   string // Request protocol, e.g. HTTP/1.1 or gRPC
+	Status     int    // HTTP status, or the gRPC status code for gRPC calls
+	Bytes      int64  // Response body bytes, when known
+	Latency    time.Duration
+	Session    string
+	Match      string
+}
+
+type jsonEntry struct {
+	Time       time.Time `json:"time"`
+	Proxy      string    `json:"proxy,omitempty"`
+	Mode       string    `json:"mode,omitempty"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+	Method     string    `json:"method"`
+	Path       string    `json:"path"`
+	Protocol   string    `json:"protocol,omitempty"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	LatencyMS  float64   `json:"latency_ms"`
+	Session    string    `json:"session,omitempty"`
+	Match      string    `json:"match,omitempty"`
+}
+
+type contextKey struct{}
+
+// NewContext returns a context carrying entry, so handlers further down can annotate it
+func NewContext(ctx context.Context, entry *Entry) context.Context {
+	return context.WithValue(ctx, contextKey{}, entry)
+}
+
+// FromContext returns the entry carried by ctx, or nil if the request is not being logged
+func FromContext(ctx context.Context) *Entry {
+	entry, _ := ctx.Value(contextKey{}).(*Entry)
+	return entry
+}
+
+// SetProxy records which proxy served the request, for listeners that route after accepting it
+func SetProxy(ctx context.Context, proxyName string) {
+	if entry := FromContext(ctx); entry != nil {
+		entry.Proxy = proxyName
+	}
+}
+
+// Annotate records the session and match result on the request's entry; empty values are left unchanged
+func Annotate(ctx context.Context, session, match string) {
+	entry := FromContext(ctx)
+	if entry == nil {
+		return
+	}
+	if session != "" {
+		entry.Session = session
+	}
+	if match != "" {
+		entry.Match = match
+	}
+}
+
+// Logger writes entries to the configured output; it drops them until configured
+type Logger struct {
+	mutex  sync.Mutex
+	out    io.Writer
+	closer io.Closer
+	format string
+}
+
+// Default is the process-wide access log
+var Default = &Logger{}
+
+// Configure points the logger at cfg's output, closing any file opened before
+func (l *Logger) Configure(cfg config.AccessLogConfig) error {
+	var out io.Writer
+	var closer io.Closer
+	switch cfg.Output {
+	case "":
+	case "stdout", "-":
+		out = os.Stdout
+	case "stderr":
+		out = os.Stderr
+	default:
+		file, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open access log: %w", err)
+		}
+		out, closer = file, file
+	}
+
+	format := cfg.Format
+	if format == "" {
+		format = FormatCommon
+	}
+
+	l.mutex.Lock()
+	previous := l.closer
+	l.out, l.closer, l.format = out, closer, format
+	l.mutex.Unlock()
+
+	if previous != nil {
+		previous.Close()
+	}
+	return nil
+}
+
+// Enabled reports whether entries are being written
+func (l *Logger) Enabled() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.out != nil
+}
+
+// Log writes one entry
+func (l *Logger) Log(entry *Entry) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.out == nil {
+		return
+	}
+
+	var line []byte
+	if l.format == FormatJSON {
+		data, err := json.Marshal(jsonEntry{
+			Time:       entry.Time,
+			Proxy:      entry.Proxy,
+			Mode:       entry.Mode,
+			RemoteAddr: entry.RemoteAddr,
+			Method:     entry.Method,
+			Path:       entry.Path,
+			Protocol:   entry.Protocol,
+			Status:     entry.Status,
+			Bytes:      entry.Bytes,
+			LatencyMS:  float64(entry.Latency.Microseconds()) / 1000,
+			Session:    entry.Session,
+			Match:      entry.Match,
+		})
+		if err != nil {
+			log.Printf("Error encoding access log entry: %v", err)
+			return
+		}
+		line = append(data, '\n')
+	} else {
+		line = []byte(formatCommon(entry))
+	}
+
+	if _, err := l.out.Write(line); err != nil {
+		log.Printf("Error writing access log: %v", err)
+	}
+}
+
+// Close closes the output file, if any, and stops logging
+func (l *Logger) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.out = nil
+	if l.closer == nil {
+		return nil
+	}
+	err := l.closer.Close()
+	l.closer = nil
+	return err
+}
+
+// formatCommon renders entry as a Common Log Format line followed by mimic's own fields
+func formatCommon(entry *Entry) string {
+	host := entry.RemoteAddr
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	bytes := "-"
+	if entry.Bytes > 0 {
+		bytes = fmt.Sprintf("%d", entry.Bytes)
+	}
+
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %s proxy=%s mode=%s session=%s match=%s latency_ms=%.3f\n",
+		orDash(host), entry.Time.Format(commonTimeFormat),
+		entry.Method, entry.Path, orDash(entry.Protocol), entry.Status, bytes,
+		orDash(entry.Proxy), orDash(entry.Mode), orDash(entry.Session), orDash(entry.Match),
+		float64(entry.Latency.Microseconds())/1000)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(value, " ", "_")
+}
+
+// Configure points the default logger at cfg's output
+func Configure(cfg config.AccessLogConfig) error {
+	return Default.Configure(cfg)
+}
+
+// Log writes an entry to the default logger
+func Log(entry *Entry) {
+	Default.Log(entry)
+}
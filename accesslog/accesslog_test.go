@@ -0,0 +1,89 @@
+package accesslog
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mimic/config"
+)
+
+func testEntry() *Entry {
+	return &Entry{
+		Time:       time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		Proxy:      "api",
+		Mode:       "mock",
+		RemoteAddr: "10.0.0.5:51234",
+		Method:     "GET",
+		Path:       "/users?id=1",
+		Protocol:   "HTTP/1.1",
+		Status:     200,
+		Bytes:      42,
+		Latency:    1500 * time.Microsecond,
+	}
+}
+
+func TestCommonFormat(t *testing.T) {
+	var out bytes.Buffer
+	logger := &Logger{out: &out, format: FormatCommon}
+
+	entry := testEntry()
+	ctx := NewContext(context.Background(), entry)
+	Annotate(ctx, "my session", MatchHit)
+	logger.Log(entry)
+
+	expected := `10.0.0.5 - - [01/Mar/2024:12:30:00 +0000] "GET /users?id=1 HTTP/1.1" 200 42 proxy=api mode=mock session=my_session match=hit latency_ms=1.500` + "\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected common log line:\n got: %s\nwant: %s", out.String(), expected)
+	}
+}
+
+func TestJSONFormat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	logger := &Logger{}
+	if err := logger.Configure(config.AccessLogConfig{Output: path, Format: FormatJSON}); err != nil {
+		t.Fatalf("Configure failed: %v", err)
+	}
+
+	entry := testEntry()
+	entry.Match = MatchMiss
+	logger.Log(entry)
+	logger.Log(entry)
+	if err := logger.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read access log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 lines, got %d", len(lines))
+	}
+
+	var decoded map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+		t.Fatalf("Expected a JSON line, got %q: %v", lines[0], err)
+	}
+	if decoded["match"] != "miss" || decoded["latency_ms"] != 1.5 || decoded["proxy"] != "api" {
+		t.Errorf("Unexpected JSON entry: %v", decoded)
+	}
+}
+
+func TestAnnotateWithoutEntry(t *testing.T) {
+	// Requests that are not being logged are left alone
+	Annotate(context.Background(), "session", MatchHit)
+	SetProxy(context.Background(), "api")
+
+	logger := &Logger{}
+	if logger.Enabled() {
+		t.Error("Expected an unconfigured logger to be disabled")
+	}
+	logger.Log(testEntry())
+}
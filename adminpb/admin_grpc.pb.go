@@ -0,0 +1,631 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.25.1
+// source: adminpb/admin.proto
+
+package adminpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	AdminService_ListSessions_FullMethodName    = "/mimic.admin.v1.AdminService/ListSessions"
+	AdminService_CreateSession_FullMethodName   = "/mimic.admin.v1.AdminService/CreateSession"
+	AdminService_DeleteSession_FullMethodName   = "/mimic.admin.v1.AdminService/DeleteSession"
+	AdminService_ClearSessions_FullMethodName   = "/mimic.admin.v1.AdminService/ClearSessions"
+	AdminService_GetMode_FullMethodName         = "/mimic.admin.v1.AdminService/GetMode"
+	AdminService_SetMode_FullMethodName         = "/mimic.admin.v1.AdminService/SetMode"
+	AdminService_ListSequences_FullMethodName   = "/mimic.admin.v1.AdminService/ListSequences"
+	AdminService_ResetSequences_FullMethodName  = "/mimic.admin.v1.AdminService/ResetSequences"
+	AdminService_GetProxySession_FullMethodName = "/mimic.admin.v1.AdminService/GetProxySession"
+	AdminService_SetProxySession_FullMethodName = "/mimic.admin.v1.AdminService/SetProxySession"
+	AdminService_GetProxyFaults_FullMethodName  = "/mimic.admin.v1.AdminService/GetProxyFaults"
+	AdminService_SetProxyFaults_FullMethodName  = "/mimic.admin.v1.AdminService/SetProxyFaults"
+	AdminService_StartReplay_FullMethodName     = "/mimic.admin.v1.AdminService/StartReplay"
+	AdminService_GetReplayRun_FullMethodName    = "/mimic.admin.v1.AdminService/GetReplayRun"
+	AdminService_ListReplayRuns_FullMethodName  = "/mimic.admin.v1.AdminService/ListReplayRuns"
+)
+
+// AdminServiceClient is the client API for AdminService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type AdminServiceClient interface {
+	ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error)
+	CreateSession(ctx context.Context, in *CreateSessionRequest, opts ...grpc.CallOption) (*Session, error)
+	DeleteSession(ctx context.Context, in *DeleteSessionRequest, opts ...grpc.CallOption) (*DeleteSessionResponse, error)
+	ClearSessions(ctx context.Context, in *ClearSessionsRequest, opts ...grpc.CallOption) (*ClearSessionsResponse, error)
+	GetMode(ctx context.Context, in *GetModeRequest, opts ...grpc.CallOption) (*ModeResponse, error)
+	SetMode(ctx context.Context, in *SetModeRequest, opts ...grpc.CallOption) (*ModeResponse, error)
+	ListSequences(ctx context.Context, in *ListSequencesRequest, opts ...grpc.CallOption) (*ListSequencesResponse, error)
+	ResetSequences(ctx context.Context, in *ResetSequencesRequest, opts ...grpc.CallOption) (*ResetSequencesResponse, error)
+	GetProxySession(ctx context.Context, in *GetProxySessionRequest, opts ...grpc.CallOption) (*ProxySession, error)
+	// SetProxySession points a mock proxy at another existing session and rewinds its sequences
+	SetProxySession(ctx context.Context, in *SetProxySessionRequest, opts ...grpc.CallOption) (*ProxySession, error)
+	GetProxyFaults(ctx context.Context, in *GetProxyFaultsRequest, opts ...grpc.CallOption) (*ProxyFaults, error)
+	// SetProxyFaults replaces the faults injected into a proxy's traffic; empty faults stop injecting
+	SetProxyFaults(ctx context.Context, in *SetProxyFaultsRequest, opts ...grpc.CallOption) (*ProxyFaults, error)
+	StartReplay(ctx context.Context, in *StartReplayRequest, opts ...grpc.CallOption) (*ReplayRun, error)
+	GetReplayRun(ctx context.Context, in *GetReplayRunRequest, opts ...grpc.CallOption) (*ReplayRun, error)
+	ListReplayRuns(ctx context.Context, in *ListReplayRunsRequest, opts ...grpc.CallOption) (*ListReplayRunsResponse, error)
+}
+
+type adminServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAdminServiceClient(cc grpc.ClientConnInterface) AdminServiceClient {
+	return &adminServiceClient{cc}
+}
+
+func (c *adminServiceClient) ListSessions(ctx context.Context, in *ListSessionsRequest, opts ...grpc.CallOption) (*ListSessionsResponse, error) {
+	out := new(ListSessionsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListSessions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) CreateSession(ctx context.Context, in *CreateSessionRequest, opts ...grpc.CallOption) (*Session, error) {
+	out := new(Session)
+	err := c.cc.Invoke(ctx, AdminService_CreateSession_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) DeleteSession(ctx context.Context, in *DeleteSessionRequest, opts ...grpc.CallOption) (*DeleteSessionResponse, error) {
+	out := new(DeleteSessionResponse)
+	err := c.cc.Invoke(ctx, AdminService_DeleteSession_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ClearSessions(ctx context.Context, in *ClearSessionsRequest, opts ...grpc.CallOption) (*ClearSessionsResponse, error) {
+	out := new(ClearSessionsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ClearSessions_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetMode(ctx context.Context, in *GetModeRequest, opts ...grpc.CallOption) (*ModeResponse, error) {
+	out := new(ModeResponse)
+	err := c.cc.Invoke(ctx, AdminService_GetMode_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetMode(ctx context.Context, in *SetModeRequest, opts ...grpc.CallOption) (*ModeResponse, error) {
+	out := new(ModeResponse)
+	err := c.cc.Invoke(ctx, AdminService_SetMode_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListSequences(ctx context.Context, in *ListSequencesRequest, opts ...grpc.CallOption) (*ListSequencesResponse, error) {
+	out := new(ListSequencesResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListSequences_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ResetSequences(ctx context.Context, in *ResetSequencesRequest, opts ...grpc.CallOption) (*ResetSequencesResponse, error) {
+	out := new(ResetSequencesResponse)
+	err := c.cc.Invoke(ctx, AdminService_ResetSequences_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetProxySession(ctx context.Context, in *GetProxySessionRequest, opts ...grpc.CallOption) (*ProxySession, error) {
+	out := new(ProxySession)
+	err := c.cc.Invoke(ctx, AdminService_GetProxySession_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetProxySession(ctx context.Context, in *SetProxySessionRequest, opts ...grpc.CallOption) (*ProxySession, error) {
+	out := new(ProxySession)
+	err := c.cc.Invoke(ctx, AdminService_SetProxySession_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetProxyFaults(ctx context.Context, in *GetProxyFaultsRequest, opts ...grpc.CallOption) (*ProxyFaults, error) {
+	out := new(ProxyFaults)
+	err := c.cc.Invoke(ctx, AdminService_GetProxyFaults_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) SetProxyFaults(ctx context.Context, in *SetProxyFaultsRequest, opts ...grpc.CallOption) (*ProxyFaults, error) {
+	out := new(ProxyFaults)
+	err := c.cc.Invoke(ctx, AdminService_SetProxyFaults_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) StartReplay(ctx context.Context, in *StartReplayRequest, opts ...grpc.CallOption) (*ReplayRun, error) {
+	out := new(ReplayRun)
+	err := c.cc.Invoke(ctx, AdminService_StartReplay_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) GetReplayRun(ctx context.Context, in *GetReplayRunRequest, opts ...grpc.CallOption) (*ReplayRun, error) {
+	out := new(ReplayRun)
+	err := c.cc.Invoke(ctx, AdminService_GetReplayRun_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *adminServiceClient) ListReplayRuns(ctx context.Context, in *ListReplayRunsRequest, opts ...grpc.CallOption) (*ListReplayRunsResponse, error) {
+	out := new(ListReplayRunsResponse)
+	err := c.cc.Invoke(ctx, AdminService_ListReplayRuns_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// AdminServiceServer is the server API for AdminService service.
+// All implementations must embed UnimplementedAdminServiceServer
+// for forward compatibility
+type AdminServiceServer interface {
+	ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error)
+	CreateSession(context.Context, *CreateSessionRequest) (*Session, error)
+	DeleteSession(context.Context, *DeleteSessionRequest) (*DeleteSessionResponse, error)
+	ClearSessions(context.Context, *ClearSessionsRequest) (*ClearSessionsResponse, error)
+	GetMode(context.Context, *GetModeRequest) (*ModeResponse, error)
+	SetMode(context.Context, *SetModeRequest) (*ModeResponse, error)
+	ListSequences(context.Context, *ListSequencesRequest) (*ListSequencesResponse, error)
+	ResetSequences(context.Context, *ResetSequencesRequest) (*ResetSequencesResponse, error)
+	GetProxySession(context.Context, *GetProxySessionRequest) (*ProxySession, error)
+	// SetProxySession points a mock proxy at another existing session and rewinds its sequences
+	SetProxySession(context.Context, *SetProxySessionRequest) (*ProxySession, error)
+	GetProxyFaults(context.Context, *GetProxyFaultsRequest) (*ProxyFaults, error)
+	// SetProxyFaults replaces the faults injected into a proxy's traffic; empty faults stop injecting
+	SetProxyFaults(context.Context, *SetProxyFaultsRequest) (*ProxyFaults, error)
+	StartReplay(context.Context, *StartReplayRequest) (*ReplayRun, error)
+	GetReplayRun(context.Context, *GetReplayRunRequest) (*ReplayRun, error)
+	ListReplayRuns(context.Context, *ListReplayRunsRequest) (*ListReplayRunsResponse, error)
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+// UnimplementedAdminServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedAdminServiceServer struct {
+}
+
+func (UnimplementedAdminServiceServer) ListSessions(context.Context, *ListSessionsRequest) (*ListSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSessions not implemented")
+}
+func (UnimplementedAdminServiceServer) CreateSession(context.Context, *CreateSessionRequest) (*Session, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSession not implemented")
+}
+func (UnimplementedAdminServiceServer) DeleteSession(context.Context, *DeleteSessionRequest) (*DeleteSessionResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSession not implemented")
+}
+func (UnimplementedAdminServiceServer) ClearSessions(context.Context, *ClearSessionsRequest) (*ClearSessionsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ClearSessions not implemented")
+}
+func (UnimplementedAdminServiceServer) GetMode(context.Context, *GetModeRequest) (*ModeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetMode not implemented")
+}
+func (UnimplementedAdminServiceServer) SetMode(context.Context, *SetModeRequest) (*ModeResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetMode not implemented")
+}
+func (UnimplementedAdminServiceServer) ListSequences(context.Context, *ListSequencesRequest) (*ListSequencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSequences not implemented")
+}
+func (UnimplementedAdminServiceServer) ResetSequences(context.Context, *ResetSequencesRequest) (*ResetSequencesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ResetSequences not implemented")
+}
+func (UnimplementedAdminServiceServer) GetProxySession(context.Context, *GetProxySessionRequest) (*ProxySession, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProxySession not implemented")
+}
+func (UnimplementedAdminServiceServer) SetProxySession(context.Context, *SetProxySessionRequest) (*ProxySession, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetProxySession not implemented")
+}
+func (UnimplementedAdminServiceServer) GetProxyFaults(context.Context, *GetProxyFaultsRequest) (*ProxyFaults, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetProxyFaults not implemented")
+}
+func (UnimplementedAdminServiceServer) SetProxyFaults(context.Context, *SetProxyFaultsRequest) (*ProxyFaults, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetProxyFaults not implemented")
+}
+func (UnimplementedAdminServiceServer) StartReplay(context.Context, *StartReplayRequest) (*ReplayRun, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartReplay not implemented")
+}
+func (UnimplementedAdminServiceServer) GetReplayRun(context.Context, *GetReplayRunRequest) (*ReplayRun, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetReplayRun not implemented")
+}
+func (UnimplementedAdminServiceServer) ListReplayRuns(context.Context, *ListReplayRunsRequest) (*ListReplayRunsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListReplayRuns not implemented")
+}
+func (UnimplementedAdminServiceServer) mustEmbedUnimplementedAdminServiceServer() {}
+
+// UnsafeAdminServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to AdminServiceServer will
+// result in compilation errors.
+type UnsafeAdminServiceServer interface {
+	mustEmbedUnimplementedAdminServiceServer()
+}
+
+func RegisterAdminServiceServer(s grpc.ServiceRegistrar, srv AdminServiceServer) {
+	s.RegisterService(&AdminService_ServiceDesc, srv)
+}
+
+func _AdminService_ListSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListSessions(ctx, req.(*ListSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_CreateSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).CreateSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_CreateSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).CreateSession(ctx, req.(*CreateSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_DeleteSession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).DeleteSession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_DeleteSession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).DeleteSession(ctx, req.(*DeleteSessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ClearSessions_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ClearSessionsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ClearSessions(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ClearSessions_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ClearSessions(ctx, req.(*ClearSessionsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetMode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetModeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetMode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetMode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetMode(ctx, req.(*GetModeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetMode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetModeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetMode(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_SetMode_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetMode(ctx, req.(*SetModeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListSequences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSequencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListSequences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListSequences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListSequences(ctx, req.(*ListSequencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ResetSequences_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ResetSequencesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ResetSequences(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ResetSequences_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ResetSequences(ctx, req.(*ResetSequencesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetProxySession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProxySessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetProxySession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetProxySession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetProxySession(ctx, req.(*GetProxySessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetProxySession_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetProxySessionRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetProxySession(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_SetProxySession_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetProxySession(ctx, req.(*SetProxySessionRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetProxyFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetProxyFaultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetProxyFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetProxyFaults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetProxyFaults(ctx, req.(*GetProxyFaultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_SetProxyFaults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetProxyFaultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).SetProxyFaults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_SetProxyFaults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).SetProxyFaults(ctx, req.(*SetProxyFaultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_StartReplay_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartReplayRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).StartReplay(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_StartReplay_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).StartReplay(ctx, req.(*StartReplayRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_GetReplayRun_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetReplayRunRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).GetReplayRun(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_GetReplayRun_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).GetReplayRun(ctx, req.(*GetReplayRunRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _AdminService_ListReplayRuns_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListReplayRunsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(AdminServiceServer).ListReplayRuns(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: AdminService_ListReplayRuns_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(AdminServiceServer).ListReplayRuns(ctx, req.(*ListReplayRunsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// AdminService_ServiceDesc is the grpc.ServiceDesc for AdminService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var AdminService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mimic.admin.v1.AdminService",
+	HandlerType: (*AdminServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSessions",
+			Handler:    _AdminService_ListSessions_Handler,
+		},
+		{
+			MethodName: "CreateSession",
+			Handler:    _AdminService_CreateSession_Handler,
+		},
+		{
+			MethodName: "DeleteSession",
+			Handler:    _AdminService_DeleteSession_Handler,
+		},
+		{
+			MethodName: "ClearSessions",
+			Handler:    _AdminService_ClearSessions_Handler,
+		},
+		{
+			MethodName: "GetMode",
+			Handler:    _AdminService_GetMode_Handler,
+		},
+		{
+			MethodName: "SetMode",
+			Handler:    _AdminService_SetMode_Handler,
+		},
+		{
+			MethodName: "ListSequences",
+			Handler:    _AdminService_ListSequences_Handler,
+		},
+		{
+			MethodName: "ResetSequences",
+			Handler:    _AdminService_ResetSequences_Handler,
+		},
+		{
+			MethodName: "GetProxySession",
+			Handler:    _AdminService_GetProxySession_Handler,
+		},
+		{
+			MethodName: "SetProxySession",
+			Handler:    _AdminService_SetProxySession_Handler,
+		},
+		{
+			MethodName: "GetProxyFaults",
+			Handler:    _AdminService_GetProxyFaults_Handler,
+		},
+		{
+			MethodName: "SetProxyFaults",
+			Handler:    _AdminService_SetProxyFaults_Handler,
+		},
+		{
+			MethodName: "StartReplay",
+			Handler:    _AdminService_StartReplay_Handler,
+		},
+		{
+			MethodName: "GetReplayRun",
+			Handler:    _AdminService_GetReplayRun_Handler,
+		},
+		{
+			MethodName: "ListReplayRuns",
+			Handler:    _AdminService_ListReplayRuns_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "adminpb/admin.proto",
+}
@@ -0,0 +1,4 @@
+// Package adminpb holds the generated server and client code for mimic's admin gRPC API.
+package adminpb
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative adminpb/admin.proto
@@ -0,0 +1,373 @@
+// Package calllog keeps the requests mock proxies have received, answered from a recording or
+// not, so tests can check how often their code made a call since a checkpoint, WireMock style.
+package calllog
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"mimic/scenario"
+	"mimic/storage"
+)
+
+// DefaultCapacity is how many calls the default log keeps; older calls are dropped first
+const DefaultCapacity = 10000
+
+// MaxBodyBytes is how much of each request body is kept for BodyContains matching
+const MaxBodyBytes = 64 * 1024
+
+// journalTrimInterval is how many calls are journaled between trims of the oldest
+const journalTrimInterval = 100
+
+// Call is one request received by a mock proxy
+type Call struct {
+	Seq     uint64      `json:"seq"` // Increases with every call; checkpoints are sequence numbers
+	Proxy   string      `json:"proxy"`
+	Method  string      `json:"method"`
+	Path    string      `json:"path"`
+	Query   string      `json:"query,omitempty"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"` // Truncated to MaxBodyBytes
+	Matched bool        `json:"matched"`        // Whether a recording answered it
+	Time    time.Time   `json:"time"`
+}
+
+// Matcher selects calls. Empty fields match anything.
+type Matcher struct {
+	Proxy  string `json:"proxy,omitempty"`
+	Method string `json:"method,omitempty"` // Case-insensitive; * matches any method
+	// Path segments * and {name} match any one segment, and a final ** matches the rest
+	Path         string            `json:"path,omitempty"`
+	Headers      map[string]string `json:"headers,omitempty"` // Exact header values
+	BodyContains string            `json:"body_contains,omitempty"`
+	Since        uint64            `json:"since,omitempty"`   // Only calls after this checkpoint
+	Matched      *bool             `json:"matched,omitempty"` // Only calls a recording answered (true) or did not (false)
+}
+
+// Matches reports whether a call is selected by the matcher
+func (m Matcher) Matches(call Call) bool {
+	if call.Seq <= m.Since {
+		return false
+	}
+	if m.Proxy != "" && m.Proxy != call.Proxy {
+		return false
+	}
+	if m.Matched != nil && *m.Matched != call.Matched {
+		return false
+	}
+	if m.Method != "" && m.Method != "*" && !strings.EqualFold(m.Method, call.Method) {
+		return false
+	}
+	if m.Path != "" && !scenario.MatchPath(m.Path, call.Path) {
+		return false
+	}
+	for name, value := range m.Headers {
+		if call.Headers.Get(name) != value {
+			return false
+		}
+	}
+	return m.BodyContains == "" || strings.Contains(call.Body, m.BodyContains)
+}
+
+// String describes the calls the matcher selects, e.g. "POST /orders on proxy api"
+func (m Matcher) String() string {
+	var parts []string
+	if m.Method != "" && m.Method != "*" {
+		parts = append(parts, strings.ToUpper(m.Method))
+	}
+	if m.Path != "" {
+		parts = append(parts, m.Path)
+	} else if len(parts) > 0 {
+		parts = append(parts, "(any path)")
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "any request")
+	}
+	if m.Proxy != "" {
+		parts = append(parts, "on proxy", m.Proxy)
+	}
+	if m.Matched != nil && *m.Matched {
+		parts = append(parts, "answered from a recording")
+	} else if m.Matched != nil {
+		parts = append(parts, "no recording answered")
+	}
+	return strings.Join(parts, " ")
+}
+
+// Expectation is how many calls a verification requires. Exactly cannot be combined with the
+// bounds; without any field set, at least one call is expected.
+type Expectation struct {
+	Exactly *int `json:"exactly,omitempty"`
+	AtLeast *int `json:"at_least,omitempty"`
+	AtMost  *int `json:"at_most,omitempty"`
+}
+
+// Validate rejects negative counts and contradictory bounds
+func (e Expectation) Validate() error {
+	for name, value := range map[string]*int{"exactly": e.Exactly, "at_least": e.AtLeast, "at_most": e.AtMost} {
+		if value != nil && *value < 0 {
+			return fmt.Errorf("%s must not be negative", name)
+		}
+	}
+	if e.Exactly != nil && (e.AtLeast != nil || e.AtMost != nil) {
+		return fmt.Errorf("exactly cannot be combined with at_least or at_most")
+	}
+	if e.AtLeast != nil && e.AtMost != nil && *e.AtMost < *e.AtLeast {
+		return fmt.Errorf("at_most must not be below at_least")
+	}
+	return nil
+}
+
+// Met reports whether count satisfies the expectation
+func (e Expectation) Met(count int) bool {
+	switch {
+	case e.Exactly != nil:
+		return count == *e.Exactly
+	case e.AtLeast == nil && e.AtMost == nil:
+		return count >= 1
+	}
+	return (e.AtLeast == nil || count >= *e.AtLeast) && (e.AtMost == nil || count <= *e.AtMost)
+}
+
+// String describes the expectation, e.g. "exactly 2 calls"
+func (e Expectation) String() string {
+	switch {
+	case e.Exactly != nil:
+		return fmt.Sprintf("exactly %s", describeCalls(*e.Exactly))
+	case e.AtLeast != nil && e.AtMost != nil:
+		return fmt.Sprintf("%d to %d calls", *e.AtLeast, *e.AtMost)
+	case e.AtMost != nil:
+		return fmt.Sprintf("at most %s", describeCalls(*e.AtMost))
+	case e.AtLeast != nil:
+		return fmt.Sprintf("at least %s", describeCalls(*e.AtLeast))
+	default:
+		return "at least 1 call"
+	}
+}
+
+func describeCalls(n int) string {
+	if n == 1 {
+		return "1 call"
+	}
+	return fmt.Sprintf("%d calls", n)
+}
+
+// Verification is the outcome of checking an expectation against the log
+type Verification struct {
+	Passed   bool   `json:"passed"`
+	Count    int    `json:"count"`
+	Expected string `json:"expected"`
+	Message  string `json:"message"` // e.g. "expected exactly 2 calls matching POST /orders, got 1"
+}
+
+// Log holds the most recent calls received by the mock proxies
+type Log struct {
+	mutex    sync.Mutex
+	calls    []Call
+	capacity int
+	last     uint64 // Sequence number of the latest call
+	dropped  uint64 // Sequence number of the latest call dropped for capacity
+	// Journal table the calls are kept in instead, so they outlive restarts and are shared by
+	// the replicas using the database
+	database *storage.Database
+}
+
+// Default is the log the mock proxies record into
+var Default = NewLog(DefaultCapacity)
+
+func NewLog(capacity int) *Log {
+	return &Log{capacity: capacity}
+}
+
+// UseDatabase keeps calls in the database's call journal from now on, rather than in memory
+func (l *Log) UseDatabase(db *storage.Database) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.database = db
+	l.calls = nil
+}
+
+// Record adds a call, numbering and timestamping it
+func (l *Log) Record(call Call) {
+	if len(call.Body) > MaxBodyBytes {
+		call.Body = call.Body[:MaxBodyBytes]
+	}
+	call.Time = time.Now()
+
+	if db := l.journal(); db != nil {
+		l.append(db, call)
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.last++
+	call.Seq = l.last
+	if len(l.calls) >= l.capacity {
+		l.dropped = l.calls[0].Seq
+		l.calls = l.calls[1:]
+	}
+	l.calls = append(l.calls, call)
+}
+
+// Checkpoint returns a checkpoint that later matchers can count from with Since
+func (l *Log) Checkpoint() uint64 {
+	if db := l.journal(); db != nil {
+		last, err := db.LastJournalID()
+		if err != nil {
+			log.Printf("Failed to take a call journal checkpoint: %v", err)
+		}
+		return uint64(last)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.last
+}
+
+// Find returns the calls selected by the matcher, oldest first. A checkpoint whose calls have
+// already been dropped is an error, since the count would be short; without a checkpoint, every
+// call still kept is considered.
+func (l *Log) Find(m Matcher) ([]Call, error) {
+	if db := l.journal(); db != nil {
+		return find(db, m)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if m.Since > l.last {
+		return nil, fmt.Errorf("unknown checkpoint %d", m.Since)
+	}
+	if m.Since > 0 && m.Since < l.dropped {
+		return nil, fmt.Errorf("checkpoint %d is older than the %d calls kept", m.Since, l.capacity)
+	}
+
+	matched := []Call{}
+	for _, call := range l.calls {
+		if m.Matches(call) {
+			matched = append(matched, call)
+		}
+	}
+	return matched, nil
+}
+
+// Count returns how many calls the matcher selects
+func (l *Log) Count(m Matcher) (int, error) {
+	matched, err := l.Find(m)
+	return len(matched), err
+}
+
+// Verify checks how many calls the matcher selects against an expectation
+func (l *Log) Verify(m Matcher, e Expectation) (Verification, error) {
+	if err := e.Validate(); err != nil {
+		return Verification{}, err
+	}
+	count, err := l.Count(m)
+	if err != nil {
+		return Verification{}, err
+	}
+
+	v := Verification{Passed: e.Met(count), Count: count, Expected: e.String()}
+	if v.Passed {
+		v.Message = fmt.Sprintf("got %s matching %s", describeCalls(count), m)
+	} else {
+		v.Message = fmt.Sprintf("expected %s matching %s, got %d", v.Expected, m, count)
+	}
+	return v, nil
+}
+
+// Reset forgets every call. Checkpoints stay valid and count only calls made after the reset.
+func (l *Log) Reset() {
+	if db := l.journal(); db != nil {
+		if err := db.ClearJournal(); err != nil {
+			log.Printf("Failed to reset call journal: %v", err)
+		}
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.calls = nil
+	l.dropped = 0
+}
+
+// Record adds a call to the default log
+func Record(call Call) {
+	Default.Record(call)
+}
+
+// journal returns the database the log keeps calls in, nil when it keeps them in memory
+func (l *Log) journal() *storage.Database {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.database
+}
+
+// append adds a call to a database's call journal, dropping the oldest entries now and then to
+// keep the log's capacity
+func (l *Log) append(db *storage.Database, call Call) {
+	headers, err := json.Marshal(call.Headers)
+	if err != nil {
+		log.Printf("Failed to journal call: %v", err)
+		return
+	}
+	entry := &storage.JournalEntry{
+		Proxy:   call.Proxy,
+		Method:  call.Method,
+		Path:    call.Path,
+		Query:   call.Query,
+		Headers: string(headers),
+		Body:    call.Body,
+		Matched: call.Matched,
+	}
+	if err := db.AppendJournalEntry(entry); err != nil {
+		log.Printf("Failed to journal call: %v", err)
+		return
+	}
+	if entry.ID%journalTrimInterval == 0 {
+		if _, err := db.TrimJournal(l.capacity); err != nil {
+			log.Printf("Failed to trim call journal: %v", err)
+		}
+	}
+}
+
+// find returns the journaled calls selected by the matcher, oldest first
+func find(db *storage.Database, m Matcher) ([]Call, error) {
+	last, err := db.LastJournalID()
+	if err != nil {
+		return nil, err
+	}
+	if m.Since > uint64(last) {
+		return nil, fmt.Errorf("unknown checkpoint %d", m.Since)
+	}
+	entries, err := db.GetJournalEntries(int64(m.Since))
+	if err != nil {
+		return nil, err
+	}
+
+	matched := []Call{}
+	for _, entry := range entries {
+		call := Call{
+			Seq:     uint64(entry.ID),
+			Proxy:   entry.Proxy,
+			Method:  entry.Method,
+			Path:    entry.Path,
+			Query:   entry.Query,
+			Body:    entry.Body,
+			Matched: entry.Matched,
+			Time:    entry.Timestamp,
+		}
+		if entry.Headers != "" {
+			json.Unmarshal([]byte(entry.Headers), &call.Headers)
+		}
+		if m.Matches(call) {
+			matched = append(matched, call)
+		}
+	}
+	return matched, nil
+}
@@ -0,0 +1,172 @@
+package calllog
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mimic/storage"
+)
+
+func intPtr(n int) *int {
+	return &n
+}
+
+func TestCountSinceCheckpoint(t *testing.T) {
+	log := NewLog(DefaultCapacity)
+	log.Record(Call{Proxy: "api", Method: "POST", Path: "/orders"})
+	checkpoint := log.Checkpoint()
+	log.Record(Call{Proxy: "api", Method: "POST", Path: "/orders", Body: `{"sku":"A-1"}`, Headers: http.Header{"X-Tenant": {"acme"}}})
+	log.Record(Call{Proxy: "api", Method: "GET", Path: "/orders/42"})
+	log.Record(Call{Proxy: "billing", Method: "POST", Path: "/orders"})
+
+	tests := []struct {
+		matcher  Matcher
+		expected int
+	}{
+		{Matcher{}, 4},
+		{Matcher{Since: checkpoint}, 3},
+		{Matcher{Method: "post", Path: "/orders", Since: checkpoint}, 2},
+		{Matcher{Proxy: "api", Method: "POST", Path: "/orders", Since: checkpoint}, 1},
+		{Matcher{Path: "/orders/{id}"}, 1},
+		{Matcher{Method: "*", Path: "/orders/**"}, 4},
+		{Matcher{BodyContains: "A-1"}, 1},
+		{Matcher{Headers: map[string]string{"x-tenant": "acme"}}, 1},
+	}
+	for _, tt := range tests {
+		count, err := log.Count(tt.matcher)
+		if err != nil {
+			t.Fatalf("Count(%+v) failed: %v", tt.matcher, err)
+		}
+		if count != tt.expected {
+			t.Errorf("Count(%+v): expected %d, got %d", tt.matcher, tt.expected, count)
+		}
+	}
+
+	if _, err := log.Count(Matcher{Since: 99}); err == nil {
+		t.Error("Expected an unknown checkpoint to be rejected")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	log := NewLog(DefaultCapacity)
+	log.Record(Call{Proxy: "api", Method: "POST", Path: "/orders"})
+	matcher := Matcher{Method: "POST", Path: "/orders"}
+
+	tests := []struct {
+		expectation Expectation
+		passed      bool
+	}{
+		{Expectation{}, true},
+		{Expectation{Exactly: intPtr(1)}, true},
+		{Expectation{Exactly: intPtr(2)}, false},
+		{Expectation{AtLeast: intPtr(2)}, false},
+		{Expectation{AtMost: intPtr(0)}, false},
+		{Expectation{AtLeast: intPtr(1), AtMost: intPtr(3)}, true},
+	}
+	for _, tt := range tests {
+		verification, err := log.Verify(matcher, tt.expectation)
+		if err != nil {
+			t.Fatalf("Verify(%s) failed: %v", tt.expectation, err)
+		}
+		if verification.Passed != tt.passed || verification.Count != 1 {
+			t.Errorf("Verify(%s): expected passed %v with 1 call, got %+v", tt.expectation, tt.passed, verification)
+		}
+	}
+
+	verification, _ := log.Verify(matcher, Expectation{Exactly: intPtr(2)})
+	if verification.Message != "expected exactly 2 calls matching POST /orders, got 1" {
+		t.Errorf("Unexpected message %q", verification.Message)
+	}
+
+	for _, invalid := range []Expectation{{Exactly: intPtr(-1)}, {Exactly: intPtr(1), AtLeast: intPtr(1)}, {AtLeast: intPtr(3), AtMost: intPtr(2)}} {
+		if _, err := log.Verify(matcher, invalid); err == nil {
+			t.Errorf("Expected %+v to be rejected", invalid)
+		}
+	}
+}
+
+func TestCapacityAndReset(t *testing.T) {
+	log := NewLog(2)
+	log.Record(Call{Path: "/a"})
+	checkpoint := log.Checkpoint()
+	log.Record(Call{Path: "/b"})
+	log.Record(Call{Path: "/c"})
+	log.Record(Call{Path: "/d"})
+
+	if _, err := log.Count(Matcher{Since: checkpoint}); err == nil || !strings.Contains(err.Error(), "older than") {
+		t.Errorf("Expected a checkpoint older than the kept calls to be rejected, got %v", err)
+	}
+	if count, _ := log.Count(Matcher{}); count != 2 {
+		t.Errorf("Expected 2 kept calls, got %d", count)
+	}
+
+	checkpoint = log.Checkpoint()
+	log.Reset()
+	log.Record(Call{Path: "/e"})
+	if count, err := log.Count(Matcher{Since: checkpoint}); err != nil || count != 1 {
+		t.Errorf("Expected 1 call after the reset, got %d (%v)", count, err)
+	}
+}
+
+func TestDatabaseJournal(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "journal.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	journal := NewLog(DefaultCapacity)
+	journal.UseDatabase(db)
+	journal.Record(Call{Proxy: "api", Method: "POST", Path: "/orders", Matched: true})
+	checkpoint := journal.Checkpoint()
+	journal.Record(Call{Proxy: "api", Method: "POST", Path: "/orders", Headers: http.Header{"X-Tenant": {"acme"}}, Matched: true})
+	journal.Record(Call{Proxy: "api", Method: "POST", Path: "/orders", Body: `{"sku":"B-2"}`})
+
+	// A second log on the same database, as another replica would have, sees the same calls
+	replica := NewLog(DefaultCapacity)
+	replica.UseDatabase(db)
+	matched := true
+	tests := []struct {
+		matcher  Matcher
+		expected int
+	}{
+		{Matcher{Path: "/orders"}, 3},
+		{Matcher{Path: "/orders", Since: checkpoint}, 2},
+		{Matcher{Matched: &matched}, 2},
+		{Matcher{Headers: map[string]string{"X-Tenant": "acme"}}, 1},
+		{Matcher{BodyContains: "B-2"}, 1},
+	}
+	for _, tt := range tests {
+		if count, err := replica.Count(tt.matcher); err != nil || count != tt.expected {
+			t.Errorf("Count(%+v): expected %d, got %d, %v", tt.matcher, tt.expected, count, err)
+		}
+	}
+
+	// Checkpoints stay valid across a reset
+	last := replica.Checkpoint()
+	replica.Reset()
+	journal.Record(Call{Proxy: "api", Method: "GET", Path: "/orders/1"})
+	if calls, err := journal.Find(Matcher{Since: last}); err != nil || len(calls) != 1 || calls[0].Path != "/orders/1" {
+		t.Errorf("Expected only the call after the reset, got %+v, %v", calls, err)
+	}
+	if _, err := journal.Count(Matcher{Since: last + 5}); err == nil {
+		t.Error("Expected an unknown checkpoint to be rejected")
+	}
+}
+
+func TestMatchedMatcher(t *testing.T) {
+	log := NewLog(DefaultCapacity)
+	log.Record(Call{Proxy: "api", Method: "POST", Path: "/orders", Matched: true})
+	log.Record(Call{Proxy: "api", Method: "POST", Path: "/orders"})
+
+	missed := false
+	verification, err := log.Verify(Matcher{Method: "POST", Path: "/orders", Matched: &missed}, Expectation{Exactly: intPtr(0)})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if verification.Passed || verification.Count != 1 || !strings.Contains(verification.Message, "no recording answered") {
+		t.Errorf("Expected one unanswered call, got %+v", verification)
+	}
+}
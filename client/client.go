@@ -0,0 +1,647 @@
+// Package client is a Go client for the mimic admin API described by /api/openapi.json.
+// It lets test harnesses and scripts create sessions, switch modes, and run replays
+// against a running mimic server without importing the server packages.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Client talks to the admin API of one mimic server
+type Client struct {
+	baseURL    string
+	token      string
+	httpClient *http.Client
+}
+
+// Option customizes a Client
+type Option func(*Client)
+
+// WithToken authenticates every request with a viewer or admin bearer token
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithHTTPClient replaces the default HTTP client
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// New creates a client for the mimic server at baseURL, e.g. "http://localhost:8080"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is returned when the server answers with a non-2xx status
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("mimic API error %d: %s", e.StatusCode, e.Message)
+}
+
+// Auth reports the role granted to the client's token
+func (c *Client) Auth(ctx context.Context) (*AuthStatus, error) {
+	var status AuthStatus
+	if err := c.do(ctx, http.MethodGet, "/api/auth", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ListSessions returns every recorded session
+func (c *Client) ListSessions(ctx context.Context) ([]Session, error) {
+	var sessions []Session
+	if err := c.do(ctx, http.MethodGet, "/api/sessions", nil, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// CreateSession creates an empty session
+func (c *Client) CreateSession(ctx context.Context, name, description string) (*Session, error) {
+	var session Session
+	req := map[string]string{"session_name": name, "description": description}
+	if err := c.do(ctx, http.MethodPost, "/api/sessions", req, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// UpdateSessionDescription replaces a session's description
+func (c *Client) UpdateSessionDescription(ctx context.Context, sessionID int, description string) (*Session, error) {
+	var session Session
+	req := map[string]string{"description": description}
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/sessions/%d", sessionID), req, &session); err != nil {
+		return nil, err
+	}
+	return &session, nil
+}
+
+// ScanSessionPII looks for likely personal data in a session and suggests redact patterns for it
+func (c *Client) ScanSessionPII(ctx context.Context, sessionID int) (*PIIReport, error) {
+	var report PIIReport
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/sessions/%d/pii", sessionID), nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// RedactSessionPII replaces personal data of the given kinds in a session with [REDACTED], in
+// place; without kinds, every kind is redacted
+func (c *Client) RedactSessionPII(ctx context.Context, sessionID int, kinds ...string) (*PIIRedactResult, error) {
+	var result PIIRedactResult
+	req := map[string][]string{"kinds": kinds}
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/sessions/%d/pii/redact", sessionID), req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CheckSessionConformance checks a session against the OpenAPI spec of a proxy; an empty proxy
+// picks the one serving the session
+func (c *Client) CheckSessionConformance(ctx context.Context, sessionID int, proxy string) (*ConformanceReport, error) {
+	var report ConformanceReport
+	path := fmt.Sprintf("/api/sessions/%d/conformance", sessionID)
+	if proxy != "" {
+		path += "?proxy=" + url.QueryEscape(proxy)
+	}
+	if err := c.do(ctx, http.MethodGet, path, nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListInteractions returns the interactions recorded in a session
+func (c *Client) ListInteractions(ctx context.Context, sessionID int) ([]Interaction, error) {
+	var interactions []Interaction
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/sessions/%d", sessionID), nil, &interactions); err != nil {
+		return nil, err
+	}
+	return interactions, nil
+}
+
+// ListConsumerInteractions returns the interactions a consumer made in a session
+func (c *Client) ListConsumerInteractions(ctx context.Context, sessionID int, consumer string) ([]Interaction, error) {
+	var interactions []Interaction
+	path := fmt.Sprintf("/api/sessions/%d?consumer=%s", sessionID, url.QueryEscape(consumer))
+	if err := c.do(ctx, http.MethodGet, path, nil, &interactions); err != nil {
+		return nil, err
+	}
+	return interactions, nil
+}
+
+// SessionTimeline returns when each request of a session started and how long it took, in
+// start order
+func (c *Client) SessionTimeline(ctx context.Context, sessionID int) ([]TimelineEntry, error) {
+	var entries []TimelineEntry
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/sessions/%d/timeline", sessionID), nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ListSessionConsumers returns the consumers interactions in a session are attributed to
+func (c *Client) ListSessionConsumers(ctx context.Context, sessionID int) ([]SessionConsumer, error) {
+	var consumers []SessionConsumer
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/sessions/%d/consumers", sessionID), nil, &consumers); err != nil {
+		return nil, err
+	}
+	return consumers, nil
+}
+
+// GetInteraction returns a single interaction
+func (c *Client) GetInteraction(ctx context.Context, id int) (*Interaction, error) {
+	var interaction Interaction
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/interactions/%d", id), nil, &interaction); err != nil {
+		return nil, err
+	}
+	return &interaction, nil
+}
+
+// SetAnnotation sets an interaction's annotation; an empty annotation clears it
+func (c *Client) SetAnnotation(ctx context.Context, id int, annotation string) (*Interaction, error) {
+	var interaction Interaction
+	req := map[string]string{"annotation": annotation}
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/interactions/%d/annotation", id), req, &interaction); err != nil {
+		return nil, err
+	}
+	return &interaction, nil
+}
+
+// SetInteractionScenario sets the scenario state an interaction is served in and the state
+// serving it moves its scenario to; empty values clear them
+func (c *Client) SetInteractionScenario(ctx context.Context, id int, scenario, requiredState, newState string) (*Interaction, error) {
+	var interaction Interaction
+	req := map[string]string{"scenario": scenario, "required_state": requiredState, "new_state": newState}
+	if err := c.do(ctx, http.MethodPut, fmt.Sprintf("/api/interactions/%d/scenario", id), req, &interaction); err != nil {
+		return nil, err
+	}
+	return &interaction, nil
+}
+
+// DecodeInteraction renders a recorded gRPC interaction's messages as JSON using the descriptors
+// the server loaded from grpc.descriptor_sets or grpc.buf_modules
+func (c *Client) DecodeInteraction(ctx context.Context, id int) (*DecodedInteraction, error) {
+	var decoded DecodedInteraction
+	if err := c.do(ctx, http.MethodGet, fmt.Sprintf("/api/interactions/%d/decoded", id), nil, &decoded); err != nil {
+		return nil, err
+	}
+	return &decoded, nil
+}
+
+// ResendInteraction sends a recorded request again and compares the live response with the
+// recorded one
+func (c *Client) ResendInteraction(ctx context.Context, id int, req ResendRequest) (*ReplayResult, error) {
+	var result ReplayResult
+	if err := c.do(ctx, http.MethodPost, fmt.Sprintf("/api/interactions/%d/resend", id), req, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ClearSessions deletes every session and interaction
+func (c *Client) ClearSessions(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/api/clear", nil, nil)
+}
+
+// ListProxies returns the configured proxies
+func (c *Client) ListProxies(ctx context.Context) ([]Proxy, error) {
+	var proxies []Proxy
+	if err := c.do(ctx, http.MethodGet, "/api/proxies", nil, &proxies); err != nil {
+		return nil, err
+	}
+	return proxies, nil
+}
+
+// ProxySession returns the session a proxy currently records into or mocks from
+func (c *Client) ProxySession(ctx context.Context, proxyName string) (string, error) {
+	var body proxySessionBody
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/"+url.PathEscape(proxyName)+"/session", nil, &body); err != nil {
+		return "", err
+	}
+	return body.Session, nil
+}
+
+// SetProxySession points a mock proxy at another existing session and rewinds its sequences
+func (c *Client) SetProxySession(ctx context.Context, proxyName, sessionName string) error {
+	return c.do(ctx, http.MethodPut, "/api/proxies/"+url.PathEscape(proxyName)+"/session", proxySessionBody{Session: sessionName}, nil)
+}
+
+// ProxyFaults returns the faults currently injected into a proxy's traffic
+func (c *Client) ProxyFaults(ctx context.Context, proxyName string) (*Faults, error) {
+	var faults Faults
+	if err := c.do(ctx, http.MethodGet, "/api/proxies/"+url.PathEscape(proxyName)+"/faults", nil, &faults); err != nil {
+		return nil, err
+	}
+	return &faults, nil
+}
+
+// SetProxyFaults replaces the faults injected into a proxy's traffic, effective from its next request
+func (c *Client) SetProxyFaults(ctx context.Context, proxyName string, faults Faults) error {
+	return c.do(ctx, http.MethodPut, "/api/proxies/"+url.PathEscape(proxyName)+"/faults", faults, nil)
+}
+
+// ClearProxyFaults stops injecting faults into a proxy's traffic
+func (c *Client) ClearProxyFaults(ctx context.Context, proxyName string) error {
+	return c.do(ctx, http.MethodDelete, "/api/proxies/"+url.PathEscape(proxyName)+"/faults", nil, nil)
+}
+
+// Mode returns the current global mode
+func (c *Client) Mode(ctx context.Context) (string, error) {
+	var mode modeBody
+	if err := c.do(ctx, http.MethodGet, "/api/mode", nil, &mode); err != nil {
+		return "", err
+	}
+	return mode.Mode, nil
+}
+
+// SetMode switches every proxy to "record", "mock", or "replay"
+func (c *Client) SetMode(ctx context.Context, mode string) error {
+	return c.do(ctx, http.MethodPut, "/api/mode", modeBody{Mode: mode}, nil)
+}
+
+// StartReplay launches a replay run in the background
+func (c *Client) StartReplay(ctx context.Context, req ReplayRunRequest) (*ReplayRun, error) {
+	var run ReplayRun
+	if err := c.do(ctx, http.MethodPost, "/api/replay/runs", req, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// ListReplayRuns returns recent replay runs without their results, newest first
+func (c *Client) ListReplayRuns(ctx context.Context) ([]ReplayRun, error) {
+	var runs []ReplayRun
+	if err := c.do(ctx, http.MethodGet, "/api/replay/runs", nil, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+// GetReplayRun returns a replay run including its results once finished
+func (c *Client) GetReplayRun(ctx context.Context, id string) (*ReplayRun, error) {
+	var run ReplayRun
+	if err := c.do(ctx, http.MethodGet, "/api/replay/runs/"+url.PathEscape(id), nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+// WaitForReplay polls a replay run until it is no longer running or ctx is done
+func (c *Client) WaitForReplay(ctx context.Context, id string, interval time.Duration) (*ReplayRun, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		run, err := c.GetReplayRun(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if run.Status != "running" {
+			return run, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return run, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ListSequences returns the mock sequence position of every tracked request signature
+func (c *Client) ListSequences(ctx context.Context) ([]SequenceEntry, error) {
+	var entries []SequenceEntry
+	if err := c.do(ctx, http.MethodGet, "/api/sequences", nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// ResetSequences rewinds mock sequences; empty proxy or signature widens the reset
+func (c *Client) ResetSequences(ctx context.Context, proxy, signature string) (int, error) {
+	var resp struct {
+		Reset int `json:"reset"`
+	}
+	req := map[string]string{"proxy": proxy, "signature": signature}
+	if err := c.do(ctx, http.MethodPost, "/api/sequences/reset", req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Reset, nil
+}
+
+// ResetSessionSequences rewinds the sequences answered from a session, by the mock proxies serving
+// it or for requests that named it; an empty signature resets all of them
+func (c *Client) ResetSessionSequences(ctx context.Context, session, signature string) (int, error) {
+	var resp struct {
+		Reset int `json:"reset"`
+	}
+	req := map[string]string{"session": session, "signature": signature}
+	if err := c.do(ctx, http.MethodPost, "/api/sequences/reset", req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Reset, nil
+}
+
+// ListScenarios returns the current state of every scenario of the mock proxies
+func (c *Client) ListScenarios(ctx context.Context) ([]ScenarioEntry, error) {
+	var entries []ScenarioEntry
+	if err := c.do(ctx, http.MethodGet, "/api/scenarios", nil, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// SetScenarioState moves a scenario of a mock proxy to a state
+func (c *Client) SetScenarioState(ctx context.Context, proxy, scenario, state string) error {
+	req := ScenarioEntry{Proxy: proxy, Scenario: scenario, State: state}
+	return c.do(ctx, http.MethodPut, "/api/scenarios", req, nil)
+}
+
+// ResetScenarios returns mock scenarios to their starting state; empty proxy or scenario widens
+// the reset
+func (c *Client) ResetScenarios(ctx context.Context, proxy, scenario string) (int, error) {
+	var resp struct {
+		Reset int `json:"reset"`
+	}
+	req := map[string]string{"proxy": proxy, "scenario": scenario}
+	if err := c.do(ctx, http.MethodPost, "/api/scenarios/reset", req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Reset, nil
+}
+
+// SaveStubs saves stubs into session, or the session each names, replacing stubs saved before
+// under the same IDs
+func (c *Client) SaveStubs(ctx context.Context, session string, stubs ...Stub) (int, error) {
+	var resp struct {
+		Saved int `json:"saved"`
+	}
+	req := map[string]interface{}{"session": session, "stubs": stubs}
+	if err := c.do(ctx, http.MethodPost, "/api/stubs", req, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Saved, nil
+}
+
+// ListBreakpoints returns the intercept breakpoints
+func (c *Client) ListBreakpoints(ctx context.Context) ([]Breakpoint, error) {
+	var breakpoints []Breakpoint
+	if err := c.do(ctx, http.MethodGet, "/api/intercept/breakpoints", nil, &breakpoints); err != nil {
+		return nil, err
+	}
+	return breakpoints, nil
+}
+
+// AddBreakpoint pauses the HTTP exchanges the breakpoint matches until they are resolved
+func (c *Client) AddBreakpoint(ctx context.Context, breakpoint Breakpoint) (*Breakpoint, error) {
+	var created Breakpoint
+	if err := c.do(ctx, http.MethodPost, "/api/intercept/breakpoints", breakpoint, &created); err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// RemoveBreakpoint removes a breakpoint; exchanges it already paused stay paused
+func (c *Client) RemoveBreakpoint(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodDelete, "/api/intercept/breakpoints/"+url.PathEscape(id), nil, nil)
+}
+
+// ListPaused returns the exchanges waiting on a decision
+func (c *Client) ListPaused(ctx context.Context) ([]PausedExchange, error) {
+	var exchanges []PausedExchange
+	if err := c.do(ctx, http.MethodGet, "/api/intercept/paused", nil, &exchanges); err != nil {
+		return nil, err
+	}
+	return exchanges, nil
+}
+
+// ResolvePaused continues or aborts a paused exchange with the decision's edits
+func (c *Client) ResolvePaused(ctx context.Context, id string, decision InterceptDecision) error {
+	return c.do(ctx, http.MethodPost, "/api/intercept/paused/"+url.PathEscape(id), decision, nil)
+}
+
+// CallCheckpoint returns a checkpoint for counting the calls mock proxies receive from now on
+func (c *Client) CallCheckpoint(ctx context.Context) (uint64, error) {
+	var resp struct {
+		Checkpoint uint64 `json:"checkpoint"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/calls/checkpoint", nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Checkpoint, nil
+}
+
+// ListCalls returns the calls received by mock proxies that the matcher selects, oldest first
+func (c *Client) ListCalls(ctx context.Context, matcher CallMatcher) ([]Call, error) {
+	var calls []Call
+	if err := c.do(ctx, http.MethodGet, "/api/calls?"+callQuery(matcher, CallExpectation{}), nil, &calls); err != nil {
+		return nil, err
+	}
+	return calls, nil
+}
+
+// CountCalls returns how many calls the matcher selects
+func (c *Client) CountCalls(ctx context.Context, matcher CallMatcher) (int, error) {
+	var resp struct {
+		Count int `json:"count"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/calls/count?"+callQuery(matcher, CallExpectation{}), nil, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+// VerifyCalls checks how many calls the matcher selects. When the expectation is not met,
+// the verification is returned along with an error carrying its message.
+func (c *Client) VerifyCalls(ctx context.Context, matcher CallMatcher, expectation CallExpectation) (*CallVerification, error) {
+	var verification CallVerification
+	err := c.do(ctx, http.MethodGet, "/api/calls/verify?"+callQuery(matcher, expectation), nil, &verification)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusExpectationFailed {
+		if json.Unmarshal([]byte(apiErr.Message), &verification) == nil {
+			return &verification, fmt.Errorf("call verification failed: %s", verification.Message)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &verification, nil
+}
+
+// ResetCalls forgets the calls served so far
+func (c *Client) ResetCalls(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/api/calls", nil, nil)
+}
+
+func callQuery(matcher CallMatcher, expectation CallExpectation) string {
+	query := url.Values{}
+	set := func(name, value string) {
+		if value != "" {
+			query.Set(name, value)
+		}
+	}
+	set("proxy", matcher.Proxy)
+	set("method", matcher.Method)
+	set("path", matcher.Path)
+	set("body_contains", matcher.BodyContains)
+	if matcher.Since > 0 {
+		query.Set("since", strconv.FormatUint(matcher.Since, 10))
+	}
+	if matcher.Matched != nil {
+		query.Set("matched", strconv.FormatBool(*matcher.Matched))
+	}
+	for name, value := range matcher.Headers {
+		query.Add("header", name+":"+value)
+	}
+	for name, value := range map[string]*int{"exactly": expectation.Exactly, "at_least": expectation.AtLeast, "at_most": expectation.AtMost} {
+		if value != nil {
+			query.Set(name, strconv.Itoa(*value))
+		}
+	}
+	return query.Encode()
+}
+
+// ListConformanceViolations returns the OpenAPI violations seen in live traffic, oldest first;
+// empty proxy or session widens the list
+func (c *Client) ListConformanceViolations(ctx context.Context, proxy, session string) ([]ConformanceEvent, error) {
+	var events []ConformanceEvent
+	query := url.Values{}
+	if proxy != "" {
+		query.Set("proxy", proxy)
+	}
+	if session != "" {
+		query.Set("session", session)
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/conformance?"+query.Encode(), nil, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// ResetConformanceViolations forgets the violations seen so far
+func (c *Client) ResetConformanceViolations(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/api/conformance", nil, nil)
+}
+
+// ListMatchMisses returns the requests the mock proxies could not answer, oldest first, each
+// with the closest recordings; empty proxy or session widens the list
+func (c *Client) ListMatchMisses(ctx context.Context, proxy, session string) ([]MatchMiss, error) {
+	var misses []MatchMiss
+	query := url.Values{}
+	if proxy != "" {
+		query.Set("proxy", proxy)
+	}
+	if session != "" {
+		query.Set("session", session)
+	}
+	if err := c.do(ctx, http.MethodGet, "/api/match-misses?"+query.Encode(), nil, &misses); err != nil {
+		return nil, err
+	}
+	return misses, nil
+}
+
+// ResetMatchMisses forgets the misses seen so far
+func (c *Client) ResetMatchMisses(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/api/match-misses", nil, nil)
+}
+
+// Clock returns the time mimic's mocks and rate limits currently see
+func (c *Client) Clock(ctx context.Context) (*ClockState, error) {
+	var state ClockState
+	if err := c.do(ctx, http.MethodGet, "/api/clock", nil, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// SetClock moves the virtual clock to t; a frozen clock stands still until advanced
+func (c *Client) SetClock(ctx context.Context, t time.Time, frozen bool) (*ClockState, error) {
+	var state ClockState
+	req := map[string]interface{}{"now": t, "frozen": frozen}
+	if err := c.do(ctx, http.MethodPut, "/api/clock", req, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// AdvanceClock moves the virtual clock forward by d, or back for a negative d
+func (c *Client) AdvanceClock(ctx context.Context, d time.Duration) (*ClockState, error) {
+	var state ClockState
+	if err := c.do(ctx, http.MethodPost, "/api/clock/advance", map[string]string{"by": d.String()}, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
+
+// ResetClock returns the virtual clock to the wall clock
+func (c *Client) ResetClock(ctx context.Context) error {
+	return c.do(ctx, http.MethodDelete, "/api/clock", nil, nil)
+}
+
+// do sends a JSON request and decodes a JSON response into out when it is non-nil
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call %s %s: %w", method, path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(resp.Body)
+		return &APIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(message))}
+	}
+
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
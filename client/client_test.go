@@ -0,0 +1,515 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"mimic/calllog"
+	"mimic/config"
+	"mimic/fault"
+	"mimic/storage"
+	"mimic/web"
+)
+
+// fakeAdmin is a minimal web.AdminController for exercising mode and session switches
+type fakeAdmin struct {
+	mode     string
+	sessions map[string]string
+	faults   map[string]fault.Settings
+}
+
+func (f *fakeAdmin) SequenceState() []web.SequenceEntry { return []web.SequenceEntry{} }
+
+func (f *fakeAdmin) ResetSequence(proxyName, sessionName, signature string) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeAdmin) ScenarioStates() []web.ScenarioEntry { return []web.ScenarioEntry{} }
+
+func (f *fakeAdmin) SetScenarioState(proxyName, scenario, state string) error { return nil }
+
+func (f *fakeAdmin) ResetScenarios(proxyName, scenario string) (int, error) { return 0, nil }
+
+func (f *fakeAdmin) Mode() string { return f.mode }
+
+func (f *fakeAdmin) SetMode(mode string) error {
+	f.mode = mode
+	return nil
+}
+
+func (f *fakeAdmin) ProxyMode(proxyName string) string { return f.mode }
+
+func (f *fakeAdmin) ProxySession(proxyName string) string { return f.sessions[proxyName] }
+
+func (f *fakeAdmin) SetProxySession(proxyName, sessionName string) error {
+	f.sessions[proxyName] = sessionName
+	return nil
+}
+
+func (f *fakeAdmin) ProxyFaults(proxyName string) fault.Settings { return f.faults[proxyName] }
+
+func (f *fakeAdmin) SetProxyFaults(proxyName string, settings fault.Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+	f.faults[proxyName] = settings
+	return nil
+}
+
+func setupTestServer(t *testing.T, cfg *config.Config) *httptest.Server {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "mimic_test.db"))
+	if err != nil {
+		t.Fatalf("Failed to create test database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	webServer := web.NewServer(cfg, db)
+	webServer.SetAdminController(&fakeAdmin{mode: "record", sessions: map[string]string{}, faults: map[string]fault.Settings{}})
+
+	mux := http.NewServeMux()
+	webServer.RegisterRoutes(mux)
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	server := setupTestServer(t, &config.Config{})
+	c := New(server.URL)
+	ctx := context.Background()
+
+	session, err := c.CreateSession(ctx, "client-session", "created by test")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if session.SessionName != "client-session" {
+		t.Errorf("Expected session name 'client-session', got %q", session.SessionName)
+	}
+
+	_, err = c.CreateSession(ctx, "client-session", "")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusConflict {
+		t.Errorf("Expected conflict for duplicate session, got %v", err)
+	}
+
+	updated, err := c.UpdateSessionDescription(ctx, session.ID, "new notes")
+	if err != nil {
+		t.Fatalf("Failed to update description: %v", err)
+	}
+	if updated.Description != "new notes" {
+		t.Errorf("Expected updated description, got %q", updated.Description)
+	}
+
+	sessions, err := c.ListSessions(ctx)
+	if err != nil {
+		t.Fatalf("Failed to list sessions: %v", err)
+	}
+	if len(sessions) != 1 {
+		t.Errorf("Expected 1 session, got %d", len(sessions))
+	}
+}
+
+func TestSetMode(t *testing.T) {
+	server := setupTestServer(t, &config.Config{})
+	c := New(server.URL)
+	ctx := context.Background()
+
+	if err := c.SetMode(ctx, "mock"); err != nil {
+		t.Fatalf("Failed to set mode: %v", err)
+	}
+	mode, err := c.Mode(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get mode: %v", err)
+	}
+	if mode != "mock" {
+		t.Errorf("Expected mode 'mock', got %q", mode)
+	}
+}
+
+func TestSetProxySession(t *testing.T) {
+	server := setupTestServer(t, &config.Config{Proxies: map[string]config.ProxyConfig{
+		"api": {SessionName: "fixtures-a"},
+	}})
+	c := New(server.URL)
+	ctx := context.Background()
+
+	var apiErr *APIError
+	if err := c.SetProxySession(ctx, "api", "fixtures-b"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing session, got %v", err)
+	}
+	if err := c.SetProxySession(ctx, "unknown", "fixtures-b"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing proxy, got %v", err)
+	}
+
+	if _, err := c.CreateSession(ctx, "fixtures-b", ""); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	if err := c.SetProxySession(ctx, "api", "fixtures-b"); err != nil {
+		t.Fatalf("Failed to switch session: %v", err)
+	}
+	session, err := c.ProxySession(ctx, "api")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if session != "fixtures-b" {
+		t.Errorf("Expected session 'fixtures-b', got %q", session)
+	}
+}
+
+func TestSetProxyFaults(t *testing.T) {
+	server := setupTestServer(t, &config.Config{Proxies: map[string]config.ProxyConfig{"api": {}}})
+	c := New(server.URL)
+	ctx := context.Background()
+
+	var apiErr *APIError
+	if err := c.SetProxyFaults(ctx, "api", Faults{ErrorRate: 1.5}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an error rate above 1, got %v", err)
+	}
+
+	want := Faults{LatencyMS: 200, ErrorRate: 0.25, ErrorStatus: 502}
+	if err := c.SetProxyFaults(ctx, "api", want); err != nil {
+		t.Fatalf("Failed to set faults: %v", err)
+	}
+	got, err := c.ProxyFaults(ctx, "api")
+	if err != nil {
+		t.Fatalf("Failed to get faults: %v", err)
+	}
+	if *got != want {
+		t.Errorf("Expected %+v, got %+v", want, *got)
+	}
+
+	if err := c.ClearProxyFaults(ctx, "api"); err != nil {
+		t.Fatalf("Failed to clear faults: %v", err)
+	}
+	if got, _ := c.ProxyFaults(ctx, "api"); *got != (Faults{}) {
+		t.Errorf("Expected no faults after clearing, got %+v", *got)
+	}
+}
+
+func TestViewerTokenCannotMutate(t *testing.T) {
+	cfg := &config.Config{Auth: config.AuthConfig{
+		AdminTokens:  []string{"admin-token"},
+		ViewerTokens: []string{"viewer-token"},
+	}}
+	server := setupTestServer(t, cfg)
+	ctx := context.Background()
+
+	var apiErr *APIError
+	if _, err := New(server.URL).ListSessions(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Expected 401 without a token, got %v", err)
+	}
+
+	viewer := New(server.URL, WithToken("viewer-token"))
+	if _, err := viewer.ListSessions(ctx); err != nil {
+		t.Errorf("Expected viewer to list sessions, got %v", err)
+	}
+	if err := viewer.ClearSessions(ctx); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected 403 for viewer clear, got %v", err)
+	}
+
+	admin := New(server.URL, WithToken("admin-token"))
+	status, err := admin.Auth(ctx)
+	if err != nil {
+		t.Fatalf("Failed to get auth status: %v", err)
+	}
+	if status.Role != "admin" {
+		t.Errorf("Expected admin role, got %q", status.Role)
+	}
+	if err := admin.ClearSessions(ctx); err != nil {
+		t.Errorf("Expected admin to clear sessions, got %v", err)
+	}
+}
+
+func TestVerifyCalls(t *testing.T) {
+	server := setupTestServer(t, &config.Config{})
+	c := New(server.URL)
+	ctx := context.Background()
+
+	checkpoint, err := c.CallCheckpoint(ctx)
+	if err != nil {
+		t.Fatalf("Failed to take a checkpoint: %v", err)
+	}
+	calllog.Record(calllog.Call{Proxy: "api", Method: "POST", Path: "/orders", Headers: http.Header{"X-Tenant": {"acme"}}})
+	calllog.Record(calllog.Call{Proxy: "api", Method: "POST", Path: "/orders"})
+
+	orders := CallMatcher{Method: "POST", Path: "/orders", Since: checkpoint}
+	if count, err := c.CountCalls(ctx, orders); err != nil || count != 2 {
+		t.Errorf("Expected 2 calls since the checkpoint, got %d (%v)", count, err)
+	}
+	tenant := CallMatcher{Path: "/orders", Headers: map[string]string{"X-Tenant": "acme"}, Since: checkpoint}
+	if calls, err := c.ListCalls(ctx, tenant); err != nil || len(calls) != 1 {
+		t.Errorf("Expected 1 call for the tenant, got %v (%v)", calls, err)
+	}
+
+	two := 2
+	if verification, err := c.VerifyCalls(ctx, orders, CallExpectation{Exactly: &two}); err != nil || !verification.Passed {
+		t.Errorf("Expected exactly 2 calls to pass, got %+v (%v)", verification, err)
+	}
+	one := 1
+	verification, err := c.VerifyCalls(ctx, orders, CallExpectation{AtMost: &one})
+	if err == nil || verification == nil || verification.Passed || verification.Count != 2 {
+		t.Errorf("Expected at most 1 call to fail with the count, got %+v (%v)", verification, err)
+	}
+}
+
+func TestClock(t *testing.T) {
+	server := setupTestServer(t, &config.Config{})
+	c := New(server.URL)
+	ctx := context.Background()
+	t.Cleanup(func() { c.ResetClock(ctx) })
+
+	expiry := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	state, err := c.SetClock(ctx, expiry, true)
+	if err != nil {
+		t.Fatalf("Failed to set the clock: %v", err)
+	}
+	if !state.Now.Equal(expiry) || !state.Frozen {
+		t.Errorf("Expected a clock frozen at %v, got %+v", expiry, state)
+	}
+
+	state, err = c.AdvanceClock(ctx, 90*time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to advance the clock: %v", err)
+	}
+	if !state.Now.Equal(expiry.Add(90 * time.Minute)) {
+		t.Errorf("Expected the clock to be advanced by 90m, got %v", state.Now)
+	}
+
+	if err := c.ResetClock(ctx); err != nil {
+		t.Fatalf("Failed to reset the clock: %v", err)
+	}
+	if state, _ := c.Clock(ctx); state.Virtual {
+		t.Errorf("Expected the clock to follow the wall clock after a reset, got %+v", state)
+	}
+}
+
+func TestSaveStubs(t *testing.T) {
+	server := setupTestServer(t, &config.Config{})
+	c := New(server.URL)
+	ctx := context.Background()
+
+	health := Stub{ID: "health", Request: StubRequest{Method: "GET", Path: "/health"}, Response: StubResponse{Body: "ok"}}
+	for i := 0; i < 2; i++ {
+		if saved, err := c.SaveStubs(ctx, "stubs", health); err != nil || saved != 1 {
+			t.Fatalf("Expected 1 stub saved, got %d, %v", saved, err)
+		}
+	}
+	sessions, err := c.ListSessions(ctx)
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("Expected the stub session to be created, got %v, %v", sessions, err)
+	}
+	interactions, err := c.ListInteractions(ctx, sessions[0].ID)
+	if err != nil || len(interactions) != 1 {
+		t.Errorf("Expected saving the same stub twice to keep one, got %d, %v", len(interactions), err)
+	}
+
+	_, err = c.SaveStubs(ctx, "stubs", Stub{Request: StubRequest{Method: "GET"}})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a stub without a path to be rejected, got %v", err)
+	}
+}
+
+func TestSessionPII(t *testing.T) {
+	server := setupTestServer(t, &config.Config{})
+	c := New(server.URL)
+	ctx := context.Background()
+
+	session, err := c.CreateSession(ctx, "signup", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	report, err := c.ScanSessionPII(ctx, session.ID)
+	if err != nil {
+		t.Fatalf("Failed to scan session: %v", err)
+	}
+	if report.Session != "signup" || len(report.Findings) != 0 {
+		t.Errorf("Expected no findings in an empty session, got %+v", report)
+	}
+
+	var apiErr *APIError
+	if _, err := c.RedactSessionPII(ctx, session.ID, "ssn"); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown kind, got %v", err)
+	}
+	if _, err := c.ScanSessionPII(ctx, session.ID+100); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing session, got %v", err)
+	}
+	result, err := c.RedactSessionPII(ctx, session.ID, "email")
+	if err != nil || result.Interactions != 0 {
+		t.Errorf("Expected nothing to redact, got %+v (%v)", result, err)
+	}
+}
+
+func TestBreakpoints(t *testing.T) {
+	server := setupTestServer(t, &config.Config{})
+	c := New(server.URL)
+	ctx := context.Background()
+
+	breakpoint, err := c.AddBreakpoint(ctx, Breakpoint{Proxy: "billing", Endpoint: "^/v1/charges", Stage: "request"})
+	if err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	defer c.RemoveBreakpoint(ctx, breakpoint.ID)
+	if breakpoint.ID == "" || breakpoint.Proxy != "billing" {
+		t.Errorf("Expected the created breakpoint, got %+v", breakpoint)
+	}
+
+	breakpoints, err := c.ListBreakpoints(ctx)
+	if err != nil || len(breakpoints) != 1 || breakpoints[0].ID != breakpoint.ID {
+		t.Errorf("Expected the breakpoint listed, got %+v (%v)", breakpoints, err)
+	}
+	if paused, err := c.ListPaused(ctx); err != nil || len(paused) != 0 {
+		t.Errorf("Expected nothing paused, got %+v (%v)", paused, err)
+	}
+
+	var apiErr *APIError
+	if _, err := c.AddBreakpoint(ctx, Breakpoint{Endpoint: "(unclosed", Stage: "request"}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected 400 for a bad pattern, got %v", err)
+	}
+	if err := c.ResolvePaused(ctx, "missing", InterceptDecision{}); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a missing exchange, got %v", err)
+	}
+
+	if err := c.RemoveBreakpoint(ctx, breakpoint.ID); err != nil {
+		t.Fatalf("Failed to remove breakpoint: %v", err)
+	}
+	if err := c.RemoveBreakpoint(ctx, breakpoint.ID); !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusNotFound {
+		t.Errorf("Expected 404 for a removed breakpoint, got %v", err)
+	}
+}
+
+// clientOperations names the Client method for every operation in web/openapi.json. Operations
+// that only repeat another one under a second method or path share its method.
+var clientOperations = map[string]string{
+	"getAuth":                    "Auth",
+	"listSessions":               "ListSessions",
+	"createSession":              "CreateSession",
+	"listSessionInteractions":    "ListInteractions",
+	"updateSessionDescription":   "UpdateSessionDescription",
+	"getSessionTimeline":         "SessionTimeline",
+	"scanSessionPII":             "ScanSessionPII",
+	"redactSessionPII":           "RedactSessionPII",
+	"checkSessionConformance":    "CheckSessionConformance",
+	"listSessionConsumers":       "ListSessionConsumers",
+	"listInteractions":           "ListInteractions",
+	"getInteraction":             "GetInteraction",
+	"setAnnotation":              "SetAnnotation",
+	"setInteractionScenario":     "SetInteractionScenario",
+	"decodeInteraction":          "DecodeInteraction",
+	"resendInteraction":          "ResendInteraction",
+	"clearSessions":              "ClearSessions",
+	"listProxies":                "ListProxies",
+	"getProxySession":            "ProxySession",
+	"setProxySession":            "SetProxySession",
+	"getProxyFaults":             "ProxyFaults",
+	"setProxyFaults":             "SetProxyFaults",
+	"clearProxyFaults":           "ClearProxyFaults",
+	"getMode":                    "Mode",
+	"setMode":                    "SetMode",
+	"listReplayRuns":             "ListReplayRuns",
+	"startReplayRun":             "StartReplay",
+	"getReplayRun":               "GetReplayRun",
+	"listSequences":              "ListSequences",
+	"resetSequences":             "ResetSequences",
+	"listScenarios":              "ListScenarios",
+	"setScenarioState":           "SetScenarioState",
+	"resetScenarios":             "ResetScenarios",
+	"listBreakpoints":            "ListBreakpoints",
+	"addBreakpoint":              "AddBreakpoint",
+	"removeBreakpoint":           "RemoveBreakpoint",
+	"listPaused":                 "ListPaused",
+	"resolvePaused":              "ResolvePaused",
+	"listCalls":                  "ListCalls",
+	"resetCalls":                 "ResetCalls",
+	"callCheckpoint":             "CallCheckpoint",
+	"createCallCheckpoint":       "CallCheckpoint",
+	"countCalls":                 "CountCalls",
+	"verifyCalls":                "VerifyCalls",
+	"verifyCallsWithBody":        "VerifyCalls",
+	"verify":                     "VerifyCalls",
+	"verifyWithBody":             "VerifyCalls",
+	"getClock":                   "Clock",
+	"setClock":                   "SetClock",
+	"resetClock":                 "ResetClock",
+	"advanceClock":               "AdvanceClock",
+	"listConformanceViolations":  "ListConformanceViolations",
+	"resetConformanceViolations": "ResetConformanceViolations",
+	"listMatchMisses":            "ListMatchMisses",
+	"resetMatchMisses":           "ResetMatchMisses",
+	"saveStubs":                  "SaveStubs",
+}
+
+// unclientedOperations are served for dashboards and monitoring rather than test harnesses
+var unclientedOperations = map[string]string{
+	"getMetrics":           "UI dashboard snapshot without a stable schema",
+	"getPrometheusMetrics": "Prometheus text exposition, scraped rather than called",
+	"streamEvents":         "server-sent event stream",
+}
+
+func TestClientCoversOpenAPI(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "web", "openapi.json"))
+	if err != nil {
+		t.Fatalf("Failed to read OpenAPI document: %v", err)
+	}
+	var document struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(data, &document); err != nil {
+		t.Fatalf("Failed to decode OpenAPI document: %v", err)
+	}
+
+	clientType := reflect.TypeOf(&Client{})
+	seen := map[string]bool{}
+	for path, item := range document.Paths {
+		for method, raw := range item {
+			if method == "parameters" || method == "summary" || method == "description" {
+				continue
+			}
+			var operation struct {
+				OperationID string `json:"operationId"`
+			}
+			if err := json.Unmarshal(raw, &operation); err != nil {
+				t.Fatalf("Failed to decode %s %s: %v", method, path, err)
+			}
+			id := operation.OperationID
+			seen[id] = true
+			if _, ok := unclientedOperations[id]; ok {
+				continue
+			}
+			name, ok := clientOperations[id]
+			if !ok {
+				t.Errorf("%s %s (%s) has no client method; add one and list it in clientOperations", method, path, id)
+				continue
+			}
+			if _, ok := clientType.MethodByName(name); !ok {
+				t.Errorf("%s %s (%s) maps to Client.%s, which does not exist", method, path, id, name)
+			}
+		}
+	}
+
+	var stale []string
+	for id := range clientOperations {
+		if !seen[id] {
+			stale = append(stale, id)
+		}
+	}
+	for id := range unclientedOperations {
+		if !seen[id] {
+			stale = append(stale, id)
+		}
+	}
+	sort.Strings(stale)
+	for _, id := range stale {
+		t.Errorf("%s is no longer in the OpenAPI document", id)
+	}
+}
@@ -0,0 +1,414 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuthStatus reports whether auth is enabled and the caller's role
+type AuthStatus struct {
+	Enabled bool   `json:"enabled"`
+	Role    string `json:"role"` // "none", "viewer", or "admin"
+}
+
+// Session is a named group of recorded interactions
+type Session struct {
+	ID          int       `json:"id"`
+	SessionName string    `json:"session_name"`
+	CreatedAt   time.Time `json:"created_at"`
+	Description string    `json:"description"`
+}
+
+// Interaction is one recorded request and response
+type Interaction struct {
+	ID              int       `json:"id"`
+	SessionID       int       `json:"session_id"`
+	RequestID       string    `json:"request_id"`
+	Protocol        string    `json:"protocol"`
+	Method          string    `json:"method"`
+	Endpoint        string    `json:"endpoint"`
+	RequestHeaders  string    `json:"request_headers"` // JSON-encoded headers
+	RequestBody     []byte    `json:"request_body"`
+	ResponseStatus  int       `json:"response_status"`
+	ResponseHeaders string    `json:"response_headers"` // JSON-encoded headers
+	ResponseBody    []byte    `json:"response_body"`
+	Timestamp       time.Time `json:"timestamp"`
+	SequenceNumber  int       `json:"sequence_number"`
+	Metadata        string    `json:"metadata"` // JSON-encoded metadata
+	IsStreaming     bool      `json:"is_streaming"`
+}
+
+// Annotation returns the interaction's annotation, if any
+func (i *Interaction) Annotation() string {
+	var metadata map[string]interface{}
+	if err := json.Unmarshal([]byte(i.Metadata), &metadata); err != nil {
+		return ""
+	}
+	annotation, _ := metadata["annotation"].(string)
+	return annotation
+}
+
+// TimelineEntry is when one recorded request started and how long it took
+type TimelineEntry struct {
+	ID         int       `json:"id"`
+	RequestID  string    `json:"request_id"`
+	Protocol   string    `json:"protocol"`
+	Method     string    `json:"method"`
+	Endpoint   string    `json:"endpoint"`
+	Status     int       `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Streaming  bool      `json:"streaming"`
+	// ChunkOffsetsMs holds each stream chunk's arrival time relative to the first chunk
+	ChunkOffsetsMs []int64 `json:"chunk_offsets_ms,omitempty"`
+	// Estimated is set when the interaction predates timing capture and StartedAt is the record time
+	Estimated bool `json:"estimated"`
+}
+
+// ResendRequest says where to send a recorded request again; zero values fall back to the
+// server's replay config
+type ResendRequest struct {
+	Proxy              string `json:"proxy,omitempty"` // Send through this mimic proxy instead of an explicit target
+	TargetHost         string `json:"target_host,omitempty"`
+	TargetPort         int    `json:"target_port,omitempty"`
+	Protocol           string `json:"protocol,omitempty"`
+	BasePath           string `json:"base_path,omitempty"`
+	MatchingStrategy   string `json:"matching_strategy,omitempty"`
+	TimeoutSeconds     int    `json:"timeout_seconds,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	GRPCInsecure       bool   `json:"grpc_insecure"`
+}
+
+// DecodedInteraction is a gRPC interaction's messages rendered as protobuf JSON
+type DecodedInteraction struct {
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// Proxy describes a configured proxy
+type Proxy struct {
+	Name        string `json:"name"`
+	Protocol    string `json:"protocol"`
+	TargetHost  string `json:"target_host"`
+	TargetPort  int    `json:"target_port"`
+	SessionName string `json:"session_name"`
+	Mode        string `json:"mode"` // Mode the proxy is currently served in
+}
+
+// Faults are the latency and failures injected into a proxy's traffic; the zero value injects nothing
+type Faults struct {
+	LatencyMS       int     `json:"latency_ms"`        // Delay added before each request
+	LatencyJitterMS int     `json:"latency_jitter_ms"` // Random extra delay of up to this much
+	ErrorRate       float64 `json:"error_rate"`        // Fraction of requests (0-1) answered with ErrorStatus
+	ErrorStatus     int     `json:"error_status"`      // Default 503
+	DropRate        float64 `json:"drop_rate"`         // Fraction of requests (0-1) whose connection is closed unanswered
+}
+
+type modeBody struct {
+	Mode string `json:"mode"`
+}
+
+type proxySessionBody struct {
+	Proxy   string `json:"proxy,omitempty"`
+	Session string `json:"session"`
+}
+
+// ReplayRunRequest configures a replay; zero values fall back to the server's replay config
+type ReplayRunRequest struct {
+	SessionName        string `json:"session_name"`
+	TargetHost         string `json:"target_host,omitempty"`
+	TargetPort         int    `json:"target_port,omitempty"`
+	Protocol           string `json:"protocol,omitempty"`
+	MatchingStrategy   string `json:"matching_strategy,omitempty"`
+	FailFast           bool   `json:"fail_fast"`
+	TimeoutSeconds     int    `json:"timeout_seconds,omitempty"`
+	MaxConcurrency     int    `json:"max_concurrency"`
+	IgnoreTimestamps   bool   `json:"ignore_timestamps"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	GRPCInsecure       bool   `json:"grpc_insecure"`
+}
+
+// ReplayRun tracks a replay launched through the API
+type ReplayRun struct {
+	ID          string         `json:"id"`
+	Status      string         `json:"status"` // "running", "completed", or "failed"
+	SessionName string         `json:"session_name"`
+	TargetHost  string         `json:"target_host"`
+	TargetPort  int            `json:"target_port"`
+	Protocol    string         `json:"protocol"`
+	Strategy    string         `json:"matching_strategy"`
+	Completed   int            `json:"completed"`
+	Total       int            `json:"total"`
+	Failures    int            `json:"failures"`
+	Error       string         `json:"error,omitempty"`
+	StartedAt   time.Time      `json:"started_at"`
+	FinishedAt  *time.Time     `json:"finished_at,omitempty"`
+	Result      *ReplaySession `json:"result,omitempty"`
+}
+
+// ReplaySession summarizes a finished replay
+type ReplaySession struct {
+	SessionName   string          `json:"session_name"`
+	TotalRequests int             `json:"total_requests"`
+	SuccessCount  int             `json:"success_count"`
+	FailureCount  int             `json:"failure_count"`
+	Results       []*ReplayResult `json:"results"`
+	StartTime     time.Time       `json:"start_time"`
+	EndTime       time.Time       `json:"end_time"`
+	Duration      time.Duration   `json:"duration"`
+}
+
+// ReplayResult is the outcome of replaying one interaction
+type ReplayResult struct {
+	Interaction     *Interaction       `json:"interaction"`
+	Success         bool               `json:"success"`
+	ExpectedStatus  int                `json:"expected_status"`
+	ActualStatus    int                `json:"actual_status"`
+	ExpectedBody    []byte             `json:"expected_body"`
+	ActualBody      []byte             `json:"actual_body"`
+	ActualHeaders   map[string]string  `json:"actual_headers,omitempty"`
+	ResponseTime    time.Duration      `json:"response_time"`
+	Error           string             `json:"error,omitempty"`
+	ValidationError string             `json:"validation_error,omitempty"`
+	Mismatches      []ContractMismatch `json:"mismatches,omitempty"` // Set by the contract matching strategy
+}
+
+// ContractMismatch is one way a response broke the recorded contract
+type ContractMismatch struct {
+	Type    string `json:"type"` // status, header, or body
+	Path    string `json:"path,omitempty"`
+	Message string `json:"message"`
+}
+
+// SequenceEntry describes where one mock request signature sits in its recorded sequence
+type SequenceEntry struct {
+	Proxy     string `json:"proxy"`
+	Session   string `json:"session"`
+	Signature string `json:"signature"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Position  int    `json:"position"`
+}
+
+// ScenarioEntry is the current state of one scenario of a mock proxy
+type ScenarioEntry struct {
+	Proxy    string `json:"proxy"`
+	Scenario string `json:"scenario"`
+	State    string `json:"state"`
+}
+
+// Stub is a mock response defined by hand rather than recorded
+type Stub struct {
+	ID       string                 `json:"id,omitempty"` // Saving a stub under the same ID replaces it
+	Session  string                 `json:"session,omitempty"`
+	Request  StubRequest            `json:"request"`
+	Response StubResponse           `json:"response"`
+	Metadata map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// StubRequest is the request a stub answers; only the headers listed are compared, and a stub
+// without a body answers any body
+type StubRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Query   string            `json:"query,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"` // A string is compared as it is, anything else as JSON
+}
+
+// StubResponse is what a stub answers with
+type StubResponse struct {
+	Status  int               `json:"status,omitempty"` // Default 200
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    interface{}       `json:"body,omitempty"` // A string is sent as it is, anything else as JSON
+}
+
+// Breakpoint pauses the HTTP exchanges it matches; empty fields match anything
+type Breakpoint struct {
+	ID       string `json:"id,omitempty"`
+	Proxy    string `json:"proxy"`
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"` // Regular expression matched against the path
+	Stage    string `json:"stage"`    // "request", "response", or "both"
+}
+
+// PausedExchange is an exchange waiting on a decision
+type PausedExchange struct {
+	ID           string            `json:"id"`
+	BreakpointID string            `json:"breakpoint_id"`
+	Proxy        string            `json:"proxy"`
+	Stage        string            `json:"stage"`
+	Method       string            `json:"method"`
+	Endpoint     string            `json:"endpoint"`
+	Query        string            `json:"query,omitempty"`
+	Headers      map[string]string `json:"headers"`
+	Body         string            `json:"body"`
+	Status       int               `json:"status,omitempty"` // Response stage only
+	PausedAt     time.Time         `json:"paused_at"`
+	Deadline     time.Time         `json:"deadline"`
+}
+
+// InterceptDecision resumes a paused exchange; zero-valued fields leave it unchanged
+type InterceptDecision struct {
+	Action   string            `json:"action,omitempty"` // "continue" (default) or "abort"
+	Method   string            `json:"method,omitempty"`
+	Endpoint string            `json:"endpoint,omitempty"`
+	Query    *string           `json:"query,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"` // Replaces all headers when set
+	Body     *string           `json:"body,omitempty"`
+	Status   int               `json:"status,omitempty"` // Response status, or the status to abort with
+}
+
+// Call is one request received by a mock proxy
+type Call struct {
+	Seq     uint64              `json:"seq"`
+	Proxy   string              `json:"proxy"`
+	Method  string              `json:"method"`
+	Path    string              `json:"path"`
+	Query   string              `json:"query,omitempty"`
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    string              `json:"body,omitempty"`
+	Matched bool                `json:"matched"` // Whether a recording answered it
+	Time    time.Time           `json:"time"`
+}
+
+// CallMatcher selects calls; empty fields match anything
+type CallMatcher struct {
+	Proxy        string
+	Method       string            // Case-insensitive; * matches any method
+	Path         string            // Segments * and {name} match any one segment, a final ** the rest
+	Headers      map[string]string // Exact header values
+	BodyContains string
+	Since        uint64 // Only calls after this checkpoint
+	Matched      *bool  // Only calls a recording answered (true) or did not (false)
+}
+
+// CallExpectation is how many calls VerifyCalls requires. Nil fields are unchecked; with none
+// set, at least one call is expected.
+type CallExpectation struct {
+	Exactly *int
+	AtLeast *int
+	AtMost  *int
+}
+
+// CallVerification is the outcome of VerifyCalls
+type CallVerification struct {
+	Passed   bool   `json:"passed"`
+	Count    int    `json:"count"`
+	Expected string `json:"expected"`
+	Message  string `json:"message"`
+}
+
+// ClockState describes mimic's virtual clock
+type ClockState struct {
+	Now     time.Time `json:"now"`
+	Virtual bool      `json:"virtual"` // Set or advanced away from the wall clock
+	Frozen  bool      `json:"frozen"`  // Stands still until advanced
+}
+
+// PIIFinding is one kind of likely personal data found in one part of an interaction
+type PIIFinding struct {
+	InteractionID int    `json:"interaction_id"`
+	Method        string `json:"method"`
+	Endpoint      string `json:"endpoint"`
+	Kind          string `json:"kind"`     // email, phone, credit_card, or jwt
+	Location      string `json:"location"` // request_headers, request_body, response_headers, response_body, or stream
+	Count         int    `json:"count"`
+	Sample        string `json:"sample"` // The first match, masked
+}
+
+// PIISuggestion is a redact pattern that would mask one kind of personal data
+type PIISuggestion struct {
+	Kind         string `json:"kind"`
+	Pattern      string `json:"pattern"` // For recording.redact_patterns
+	Matches      int    `json:"matches"`
+	Interactions int    `json:"interactions"`
+}
+
+// PIIReport is what a scan found in a session
+type PIIReport struct {
+	Session      string          `json:"session"`
+	Interactions int             `json:"interactions"`
+	Findings     []PIIFinding    `json:"findings"`
+	Suggestions  []PIISuggestion `json:"suggestions"`
+}
+
+// PIIRedactResult is what redacting a session changed
+type PIIRedactResult struct {
+	Session      string   `json:"session"`
+	Kinds        []string `json:"kinds"`
+	Interactions int      `json:"interactions"`
+}
+
+// SessionConsumer is how many interactions of a session one consumer made
+type SessionConsumer struct {
+	Consumer     string `json:"consumer"`
+	Interactions int    `json:"interactions"`
+}
+
+// ConformanceViolation is one way an exchange departs from an OpenAPI spec
+type ConformanceViolation struct {
+	Kind          string `json:"kind"` // unknown_endpoint, unknown_method, undocumented_status, invalid_request, or invalid_response
+	Method        string `json:"method"`
+	Path          string `json:"path"`
+	Operation     string `json:"operation,omitempty"` // The matching path template
+	Status        int    `json:"status,omitempty"`
+	Pointer       string `json:"pointer,omitempty"` // JSON pointer into the offending body
+	Message       string `json:"message"`
+	InteractionID int    `json:"interaction_id,omitempty"`
+}
+
+// ConformanceEvent is a violation seen in live traffic
+type ConformanceEvent struct {
+	Seq     uint64    `json:"seq"`
+	Proxy   string    `json:"proxy"`
+	Session string    `json:"session"`
+	Time    time.Time `json:"time"`
+	ConformanceViolation
+}
+
+// MatchDifference is one reason a recording did not match a request. Recorded and Actual are
+// nil when the field is missing from that side.
+type MatchDifference struct {
+	Part     string      `json:"part"`            // method, path, query, header, body, or state
+	Field    string      `json:"field,omitempty"` // Param or header name, or a JSON path such as $.user.id
+	Recorded interface{} `json:"recorded,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+	Message  string      `json:"message"`
+}
+
+// MatchCandidate is a recording that came close to matching a missed request
+type MatchCandidate struct {
+	InteractionID  int               `json:"interaction_id"`
+	RequestID      string            `json:"request_id"`
+	Method         string            `json:"method"`
+	Endpoint       string            `json:"endpoint"`
+	SequenceNumber int               `json:"sequence_number"`
+	Differences    []MatchDifference `json:"differences"`
+}
+
+// MatchMiss is a request a mock proxy answered with not found
+type MatchMiss struct {
+	Seq        uint64           `json:"seq"`
+	Proxy      string           `json:"proxy"`
+	Session    string           `json:"session"`
+	Time       time.Time        `json:"time"`
+	Method     string           `json:"method"`
+	Path       string           `json:"path"`
+	Query      string           `json:"query,omitempty"`
+	Reason     string           `json:"reason"`
+	Candidates []MatchCandidate `json:"candidates"` // Closest first
+}
+
+// ConformanceReport is the outcome of checking a session against an OpenAPI spec
+type ConformanceReport struct {
+	Session      string                 `json:"session"`
+	Spec         string                 `json:"spec"`
+	Interactions int                    `json:"interactions"`
+	Checked      int                    `json:"checked"`
+	Conforming   int                    `json:"conforming"`
+	Violations   []ConformanceViolation `json:"violations"`
+	Counts       map[string]int         `json:"counts"`
+	Uncovered    []string               `json:"uncovered"` // Operations no interaction exercised
+}
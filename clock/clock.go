@@ -0,0 +1,102 @@
+// Package clock is mimic's notion of the current time. It follows the wall clock until a test
+// sets or advances it, so token expiry and scheduling can be fast-forwarded deterministically.
+package clock
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// State describes the clock as reported by the admin API
+type State struct {
+	Now     time.Time `json:"now"`
+	Virtual bool      `json:"virtual"` // Set or advanced since start or the last reset
+	Frozen  bool      `json:"frozen"`  // Stands still until advanced
+}
+
+// Clock is a wall clock that can be shifted and frozen
+type Clock struct {
+	mutex    sync.RWMutex
+	offset   time.Duration // Added to the wall clock while running
+	frozen   bool
+	frozenAt time.Time
+	wall     func() time.Time
+}
+
+// Default is the clock consulted by the mock proxies and rate limits
+var Default = New()
+
+func New() *Clock {
+	return &Clock{wall: time.Now}
+}
+
+// Now returns the clock's current time
+func (c *Clock) Now() time.Time {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	if c.frozen {
+		return c.frozenAt
+	}
+	if c.offset == 0 {
+		return c.wall()
+	}
+	// Round(0) drops the monotonic reading, which would ignore the offset in comparisons
+	return c.wall().Add(c.offset).Round(0)
+}
+
+// State reports the clock's time and mode
+func (c *Clock) State() State {
+	now := c.Now()
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return State{Now: now, Virtual: c.frozen || c.offset != 0, Frozen: c.frozen}
+}
+
+// Set moves the clock to t. A frozen clock then stands still until advanced; otherwise it keeps
+// running from t.
+func (c *Clock) Set(t time.Time, frozen bool) error {
+	if t.IsZero() {
+		return fmt.Errorf("time is required")
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.frozen = frozen
+	if frozen {
+		c.frozenAt, c.offset = t, 0
+	} else {
+		c.offset = t.Sub(c.wall())
+	}
+	return nil
+}
+
+// Advance moves the clock forward by d, or back for a negative d
+func (c *Clock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.frozen {
+		c.frozenAt = c.frozenAt.Add(d)
+	} else {
+		c.offset += d
+	}
+}
+
+// Reset returns the clock to the wall clock
+func (c *Clock) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.frozen, c.frozenAt, c.offset = false, time.Time{}, 0
+}
+
+// Virtual reports whether the clock has been set or advanced away from the wall clock
+func (c *Clock) Virtual() bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.frozen || c.offset != 0
+}
+
+// Now returns the default clock's current time
+func Now() time.Time {
+	return Default.Now()
+}
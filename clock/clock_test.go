@@ -0,0 +1,47 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetAndAdvance(t *testing.T) {
+	wall := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	c := &Clock{wall: func() time.Time { return wall }}
+
+	if c.Virtual() || !c.Now().Equal(wall) {
+		t.Fatalf("Expected a new clock to follow the wall clock, got %v", c.State())
+	}
+
+	target := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := c.Set(target, false); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	wall = wall.Add(time.Minute)
+	if got := c.Now(); !got.Equal(target.Add(time.Minute)) {
+		t.Errorf("Expected a running clock to move with the wall clock, got %v", got)
+	}
+
+	c.Advance(time.Hour)
+	if got := c.Now(); !got.Equal(target.Add(61 * time.Minute)) {
+		t.Errorf("Expected the clock to be advanced by an hour, got %v", got)
+	}
+
+	if err := c.Set(target, true); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	wall = wall.Add(time.Minute)
+	c.Advance(-time.Hour)
+	state := c.State()
+	if !state.Now.Equal(target.Add(-time.Hour)) || !state.Frozen || !state.Virtual {
+		t.Errorf("Expected a frozen clock to move only when advanced, got %+v", state)
+	}
+
+	c.Reset()
+	if c.Virtual() || !c.Now().Equal(wall) {
+		t.Errorf("Expected a reset clock to follow the wall clock, got %+v", c.State())
+	}
+	if err := c.Set(time.Time{}, true); err == nil {
+		t.Error("Expected a zero time to be rejected")
+	}
+}
@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"os"
+	"sort"
+
+	"mimic/config"
+	"mimic/storage"
+
+	"github.com/spf13/cobra"
+)
+
+// completeFunc is the signature cobra uses for dynamic argument and flag completion
+type completeFunc func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective)
+
+// completeValues completes from a fixed list of values
+func completeValues(values ...string) completeFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeFiles completes file names with the given extensions
+func completeFiles(extensions ...string) completeFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return extensions, cobra.ShellCompDirectiveFilterFileExt
+	}
+}
+
+// completeExportFiles completes the files that export writes and import, serve, and mock read
+var completeExportFiles = completeFiles("json", "gz")
+
+// completeSessions completes the names of recorded sessions
+func completeSessions(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return sessionNames(), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeSessionArgs completes session names for the first n positional arguments, or all of
+// them when n is 0
+func completeSessionArgs(n int) completeFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if n > 0 && len(args) >= n {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return sessionNames(), cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeProxies completes the names of configured proxies
+func completeProxies(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	names := make([]string, 0, len(cfg.Proxies))
+	for name := range cfg.Proxies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// sessionNames lists sessions for completion, quietly returning nothing when the database cannot
+// be read. A SQLite file that does not exist yet is left uncreated.
+func sessionNames() []string {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return nil
+	}
+	if cfg.Database.Driver == "" || cfg.Database.Driver == storage.DriverSQLite {
+		path, err := config.ExpandHome(cfg.Database.Path)
+		if err != nil {
+			return nil
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil
+		}
+	}
+
+	db, err := storage.Open(cfg.Database)
+	if err != nil {
+		return nil
+	}
+	defer db.Close()
+
+	sessions, err := db.ListSessions()
+	if err != nil {
+		return nil
+	}
+	names := make([]string, len(sessions))
+	for i, session := range sessions {
+		names[i] = session.SessionName
+	}
+	return names
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"mimic/conformance"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	conformanceSpec  string
+	conformanceProxy string
+)
+
+var sessionsConformanceCmd = &cobra.Command{
+	Use:   "conformance <session>",
+	Short: "Check a session against an OpenAPI spec",
+	Long: `Check every REST interaction of a session against an OpenAPI 3 spec: requests to endpoints
+or methods the spec does not describe, status codes it does not document for the operation, and
+request and response bodies that do not match their JSON schemas. The report also lists the
+operations no interaction exercised.
+
+The spec is the openapi_spec of the proxy serving the session, or of the only proxy with one;
+choose another with --proxy or give a file with --spec. gRPC interactions are skipped. The
+command exits with status 1 when any violation is found.`,
+	Example: `  mimic sessions conformance checkout
+  mimic sessions conformance checkout --spec api/openapi.yaml
+  mimic sessions conformance checkout --proxy payments -o json | jq .counts`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, db := mustOpenDatabase()
+		defer db.Close()
+
+		specFile := conformanceSpec
+		if specFile == "" {
+			proxyName := conformanceProxy
+			if proxyName == "" {
+				var err error
+				if proxyName, err = conformance.SpecProxy(cfg.Proxies, args[0]); err != nil {
+					log.Fatal(fmt.Errorf("%w; pass --spec or --proxy", err))
+				}
+			}
+			proxyConfig, ok := cfg.Proxies[proxyName]
+			if !ok {
+				log.Fatalf("Proxy '%s' not found in config", proxyName)
+			}
+			if proxyConfig.OpenAPISpec == "" {
+				log.Fatalf("Proxy '%s' has no openapi_spec", proxyName)
+			}
+			specFile = proxyConfig.OpenAPISpec
+		}
+		spec, err := conformance.LoadSpec(specFile)
+		if err != nil {
+			log.Fatal("Failed to load OpenAPI spec:", err)
+		}
+
+		report, err := conformance.CheckSession(db, args[0], spec)
+		if err != nil {
+			log.Fatal("Failed to check session:", err)
+		}
+		if jsonOutput() {
+			printJSON(report)
+		} else {
+			printConformanceReport(report)
+		}
+		if len(report.Violations) > 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	sessionsConformanceCmd.Flags().StringVar(&conformanceSpec, "spec", "", "OpenAPI 3 document to check against (default: the proxy's openapi_spec)")
+	sessionsConformanceCmd.Flags().StringVar(&conformanceProxy, "proxy", "", "proxy whose openapi_spec to check against")
+	sessionsConformanceCmd.RegisterFlagCompletionFunc("spec", completeFiles("json", "yaml", "yml"))
+	sessionsConformanceCmd.RegisterFlagCompletionFunc("proxy", completeProxies)
+	sessionsConformanceCmd.ValidArgsFunction = completeSessionArgs(1)
+
+	sessionsCmd.AddCommand(sessionsConformanceCmd)
+}
+
+func printConformanceReport(report *conformance.Report) {
+	if len(report.Violations) > 0 {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tKIND\tMETHOD\tPATH\tSTATUS\tMESSAGE")
+		for _, v := range report.Violations {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%d\t%s\n", v.InteractionID, v.Kind, v.Method, v.Path, v.Status, v.Message)
+		}
+		w.Flush()
+		fmt.Println()
+	}
+
+	if len(report.Uncovered) > 0 {
+		fmt.Printf("Operations not exercised (%d):\n", len(report.Uncovered))
+		for _, operation := range report.Uncovered {
+			fmt.Printf("  %s\n", operation)
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("Checked %d of %d interactions of '%s' against %s: %d conforming, %d violations\n",
+		report.Checked, report.Interactions, report.Session, report.Spec, report.Conforming, len(report.Violations))
+	kinds := make([]string, 0, len(report.Counts))
+	for kind := range report.Counts {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	for _, kind := range kinds {
+		fmt.Printf("  %s: %d\n", kind, report.Counts[kind])
+	}
+}
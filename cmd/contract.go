@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+
+	"mimic/config"
+	"mimic/export"
+	"mimic/replay"
+	"mimic/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	contractProviderURL   string
+	contractConsumer      string
+	contractProvider      string
+	contractReportFile    string
+	contractTimeout       int
+	contractConcurrency   int
+	contractOutboundProxy string
+)
+
+var contractCmd = &cobra.Command{
+	Use:   "contract",
+	Short: "Use exported sessions as consumer contracts",
+}
+
+var contractVerifyCmd = &cobra.Command{
+	Use:   "verify <export file>",
+	Short: "Verify a provider against an exported session",
+	Long: `Treat an exported session as a consumer contract and verify a provider against it: each
+recorded request is sent to the provider, and its response must have the recorded status, the
+recorded Content-Type, and, for JSON, every recorded field with a value of the same type. Values
+may differ and extra fields are allowed, so the contract holds as long as the consumer could
+still read the response.
+
+The report lists each interaction and the unmet ones. The command exits with status 1 when any
+interaction is unmet.`,
+	Example: `  mimic contract verify fixtures/checkout.json --provider-url https://staging.example.com
+  mimic contract verify checkout.json --provider-url http://localhost:3000/api --report verification.json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runContractVerify(args[0])
+	},
+}
+
+func init() {
+	contractVerifyCmd.Flags().StringVar(&contractProviderURL, "provider-url", "", "base URL of the provider to verify (required)")
+	contractVerifyCmd.Flags().StringVar(&contractConsumer, "consumer", "", "consumer name for the report (default: the export file name)")
+	contractVerifyCmd.Flags().StringVar(&contractProvider, "provider", "", "provider name for the report (default: the provider host)")
+	contractVerifyCmd.Flags().StringVar(&contractReportFile, "report", "", "also write the JSON report to this file")
+	addOutputFlag(contractVerifyCmd, false)
+	addLegacyJSONFlag(contractVerifyCmd, false)
+	contractVerifyCmd.Flags().IntVar(&contractTimeout, "timeout", 30, "request timeout in seconds")
+	contractVerifyCmd.Flags().IntVar(&contractConcurrency, "concurrency", 0, "max concurrent requests (0 for sequential)")
+	contractVerifyCmd.Flags().StringVar(&contractOutboundProxy, "outbound-proxy", "", "egress proxy URL for the provider, or 'none' (default: HTTP(S)_PROXY)")
+	contractVerifyCmd.MarkFlagRequired("provider-url")
+	contractVerifyCmd.ValidArgsFunction = completeExportFiles
+
+	contractCmd.AddCommand(contractVerifyCmd)
+	rootCmd.AddCommand(contractCmd)
+}
+
+func runContractVerify(file string) {
+	provider, err := url.Parse(contractProviderURL)
+	if err != nil || (provider.Scheme != "http" && provider.Scheme != "https") || provider.Hostname() == "" {
+		log.Fatalf("Invalid provider URL %q: must be an absolute http or https URL", contractProviderURL)
+	}
+
+	// The contract only lives for this run, so load it into memory rather than the configured database
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer db.Close()
+
+	sessionName := proxyNameForExport(file)
+	if err := export.NewExportManager(config.DefaultConfig(), db).ImportSession(file, sessionName, "append"); err != nil {
+		log.Fatalf("Failed to load contract %s: %v", file, err)
+	}
+
+	engine, err := replay.NewReplayEngine(&config.ReplayConfig{
+		TargetHost:       provider.Hostname(),
+		TargetPort:       defaultPort(provider),
+		Protocol:         provider.Scheme,
+		BasePath:         provider.Path,
+		SessionName:      sessionName,
+		MatchingStrategy: "contract",
+		TimeoutSeconds:   contractTimeout,
+		MaxConcurrency:   contractConcurrency,
+		IgnoreTimestamps: true,
+		OutboundProxy:    contractOutboundProxy,
+		GRPCInsecure:     provider.Scheme == "http",
+	}, db)
+	if err != nil {
+		log.Fatal("Failed to create replay engine:", err)
+	}
+	session, err := engine.Replay()
+	if err != nil {
+		log.Fatal("Verification failed:", err)
+	}
+
+	consumer := contractConsumer
+	if consumer == "" {
+		consumer = sessionName
+	}
+	providerName := contractProvider
+	if providerName == "" {
+		providerName = provider.Hostname()
+	}
+	report := replay.NewContractReport(consumer, providerName, contractProviderURL, session)
+
+	if contractReportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			log.Fatal("Failed to encode report:", err)
+		}
+		if err := os.WriteFile(contractReportFile, append(data, '\n'), 0644); err != nil {
+			log.Fatal("Failed to write report:", err)
+		}
+	}
+	if jsonOutput() {
+		printJSON(report)
+	} else {
+		printContractReport(report)
+	}
+	if !report.Success {
+		os.Exit(1)
+	}
+}
+
+func printContractReport(report *replay.ContractReport) {
+	fmt.Printf("Verifying consumer '%s' against provider '%s' (%s)\n\n", report.Consumer, report.Provider, report.ProviderURL)
+	for _, interaction := range report.Interactions {
+		if interaction.Success {
+			fmt.Printf("  ✓ %s\n", interaction.Description)
+			continue
+		}
+		fmt.Printf("  ✗ %s\n", interaction.Description)
+		for _, mismatch := range interaction.Mismatches {
+			fmt.Printf("      %s\n", mismatch)
+		}
+		if interaction.Error != "" {
+			fmt.Printf("      %s\n", interaction.Error)
+		}
+	}
+
+	fmt.Printf("\n%d interactions, %d verified, %d unmet\n",
+		len(report.Interactions), len(report.Interactions)-len(report.Unmet), len(report.Unmet))
+	if len(report.Unmet) > 0 {
+		fmt.Println("Unmet interactions:")
+		for _, description := range report.Unmet {
+			fmt.Printf("  %s\n", description)
+		}
+	}
+}
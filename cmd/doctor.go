@@ -0,0 +1,82 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"mimic/config"
+	"mimic/doctor"
+
+	"github.com/spf13/cobra"
+)
+
+var doctorTimeout time.Duration
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Check the config, database, ports, and targets before running",
+	Long: `Check that mimic can run here: the config is valid, the database is intact and on a
+schema this build understands, the listen ports are free, and every configured target
+resolves and accepts connections (including the TLS handshake and, for gRPC targets,
+a reflection call). Exits non-zero if any check fails.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runDoctor()
+	},
+}
+
+func init() {
+	doctorCmd.Flags().DurationVar(&doctorTimeout, "timeout", doctor.DefaultTimeout, "time limit for each network probe")
+	addOutputFlag(doctorCmd, false)
+
+	rootCmd.AddCommand(doctorCmd)
+}
+
+func runDoctor() {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		if jsonOutput() {
+			printJSON(doctor.Report{{Check: "config", Status: doctor.StatusFail, Message: fmt.Sprintf("failed to load: %v", err)}})
+		} else {
+			fmt.Printf("✗ config: failed to load: %v\n", err)
+		}
+		os.Exit(1)
+	}
+	if modeFlag != "" {
+		cfg.Mode = modeFlag
+	}
+
+	report := doctor.Run(cfg, doctorTimeout)
+	if jsonOutput() {
+		printJSON(report)
+		if report.Failed() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	counts := map[doctor.Status]int{}
+	for _, finding := range report {
+		counts[finding.Status]++
+		fmt.Printf("%s %s: %s\n", doctorSymbol(finding.Status), finding.Check, finding.Message)
+		if finding.Hint != "" && finding.Status != doctor.StatusOK {
+			fmt.Printf("    → %s\n", finding.Hint)
+		}
+	}
+	fmt.Printf("\n%d passed, %d warnings, %d failed\n", counts[doctor.StatusOK], counts[doctor.StatusWarn], counts[doctor.StatusFail])
+
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+func doctorSymbol(status doctor.Status) string {
+	switch status {
+	case doctor.StatusOK:
+		return "✓"
+	case doctor.StatusWarn:
+		return "!"
+	default:
+		return "✗"
+	}
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"mimic/config"
+	"mimic/export"
+)
+
+var (
+	exportConsumer   string
+	exportByConsumer bool
+	exportTag        string
+)
+
+// unsafeFileChars are replaced in consumer names used in file names, such as IPv6 addresses
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// consumerExport is the JSON output of exporting a session, or one consumer's or tag's slice of it
+type consumerExport struct {
+	Session      string `json:"session"`
+	Consumer     string `json:"consumer,omitempty"`
+	Tag          string `json:"tag,omitempty"`
+	File         string `json:"file"`
+	Interactions int    `json:"interactions"`
+}
+
+// sliceName names the export of a slice of a session, or of the whole session
+func sliceName(session string, slice export.Slice) string {
+	name := session
+	for _, part := range []string{slice.Consumer, slice.Tag} {
+		if part != "" {
+			name += "-" + unsafeFileChars.ReplaceAllString(part, "_")
+		}
+	}
+	return name
+}
+
+// exportEachConsumer writes each consumer's slice of the session to its own file in the output
+// directory
+func exportEachConsumer(cfg *config.Config, exportManager *export.ExportManager) {
+	consumers, err := exportManager.Consumers(sessionName)
+	if err != nil {
+		log.Fatal("Failed to export session:", err)
+	}
+	if len(consumers) == 0 {
+		log.Fatalf("Session '%s' has no interactions attributed to a consumer", sessionName)
+	}
+	if err := os.MkdirAll(outputFile, 0755); err != nil {
+		log.Fatal("Failed to create output directory:", err)
+	}
+
+	exports := make([]consumerExport, 0, len(consumers))
+	for _, consumer := range consumers {
+		slice := export.Slice{Consumer: consumer, Tag: exportTag}
+		path := exportPath(cfg, outputFile+string(filepath.Separator), sliceName(sessionName, slice))
+		count, err := exportManager.ExportSlice(sessionName, slice, path)
+		if err != nil {
+			log.Fatalf("Failed to export consumer '%s': %v", consumer, err)
+		}
+		exports = append(exports, consumerExport{sessionName, consumer, exportTag, path, count})
+	}
+
+	if jsonOutput() {
+		printJSON(exports)
+		return
+	}
+	for _, e := range exports {
+		fmt.Printf("Consumer '%s' exported to '%s' (%d interactions)\n", e.Consumer, e.File, e.Interactions)
+	}
+}
@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"mimic/config"
+	"mimic/export"
+)
+
+var (
+	exportWatch    bool
+	exportDebounce time.Duration
+)
+
+// exportPath resolves an export target, writing into it as <session>.json (or .json.gz when
+// exports are compressed) when it is a directory or ends with a path separator
+func exportPath(cfg *config.Config, target, session string) string {
+	info, err := os.Stat(target)
+	isDir := err == nil && info.IsDir()
+	if !isDir && !strings.HasSuffix(target, string(filepath.Separator)) && !strings.HasSuffix(target, "/") {
+		return target
+	}
+	name := session + ".json"
+	if cfg.Export.Compress {
+		name += ".gz"
+	}
+	return filepath.Join(target, name)
+}
+
+// watchExport re-exports the session whenever it changes, until interrupted
+func watchExport(exportManager *export.ExportManager) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("Watching session '%s'; exporting to '%s' after %s without changes (Ctrl+C to stop)", sessionName, outputFile, exportDebounce)
+	err := exportManager.WatchSession(ctx, sessionName, outputFile, export.WatchOptions{
+		Debounce: exportDebounce,
+		OnExport: func(path string, interactions int) {
+			if jsonOutput() {
+				json.NewEncoder(os.Stdout).Encode(struct {
+					Session      string `json:"session"`
+					File         string `json:"file"`
+					Interactions int    `json:"interactions"`
+				}{sessionName, path, interactions})
+				return
+			}
+			log.Printf("Exported %d interactions from session '%s' to '%s'", interactions, sessionName, path)
+		},
+	})
+	if err != nil {
+		log.Fatal("Failed to export session:", err)
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"mimic/config"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	initOutput      string
+	initComposeFile string
+	initForce       bool
+)
+
+var initCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Create a commented config.yaml by answering a few questions",
+	Long: `Ask for the targets to proxy, their protocols, and the mode, then write a commented
+config file ready for a first recording session. Optionally writes a docker-compose
+snippet that runs mimic with that config.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runInit(cmd.InOrStdin(), cmd.OutOrStdout()); err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	initCmd.Flags().StringVarP(&initOutput, "output", "o", "config.yaml", "config file to write")
+	initCmd.Flags().StringVar(&initComposeFile, "compose-file", "docker-compose.mimic.yml", "where to write the docker-compose snippet, if requested")
+	initCmd.Flags().BoolVar(&initForce, "force", false, "overwrite existing files")
+
+	rootCmd.AddCommand(initCmd)
+}
+
+func runInit(in io.Reader, out io.Writer) error {
+	if err := checkWritable(initOutput); err != nil {
+		return err
+	}
+
+	p := &prompter{in: bufio.NewReader(in), out: out}
+	fmt.Fprintln(out, "Answer a few questions to create a mimic config. Press enter to accept the [default].")
+
+	scaffold := config.Scaffold{
+		Mode:        p.choice("Mode (record, mock, or replay)", "record", "record", "mock", "replay"),
+		ListenPort:  p.number("HTTP listen port", 8080),
+		SessionName: p.text("Session to record into", "default"),
+	}
+
+	hasDefaultGRPC := false
+	for {
+		fmt.Fprintln(out)
+		defaultName := ""
+		if len(scaffold.Proxies) == 0 {
+			defaultName = "api"
+		}
+		name := p.text("Proxy name (blank to finish)", defaultName)
+		if name == "" {
+			if len(scaffold.Proxies) > 0 {
+				break
+			}
+			fmt.Fprintln(out, "At least one proxy is needed.")
+			continue
+		}
+		if strings.ContainsAny(name, " :/#\"'") {
+			fmt.Fprintln(out, "Proxy names cannot contain spaces, colons, slashes, quotes, or #.")
+			continue
+		}
+
+		proxy := config.ScaffoldProxy{
+			Name:       name,
+			TargetHost: p.required("Target host (e.g. api.example.com)"),
+			Protocol:   p.choice("Protocol (http, https, or grpc)", "https", "http", "https", "grpc"),
+		}
+		defaultPort := 443
+		if proxy.Protocol == "http" {
+			defaultPort = 80
+		}
+		proxy.TargetPort = p.number("Target port", defaultPort)
+
+		if proxy.Protocol == "grpc" {
+			if hasDefaultGRPC {
+				proxy.ServicePattern = p.required("Service name pattern routed to this proxy (regex, e.g. users\\..*)")
+			} else {
+				proxy.ServicePattern = p.text("Service name pattern routed to this proxy (regex, blank to route every service)", "")
+				hasDefaultGRPC = proxy.ServicePattern == ""
+			}
+		}
+		scaffold.Proxies = append(scaffold.Proxies, proxy)
+	}
+
+	if scaffold.HasGRPC() {
+		scaffold.GRPCPort = p.number("gRPC listen port", scaffold.ListenPort+1000)
+	}
+	fmt.Fprintln(out)
+	writeCompose := p.confirm("Write a docker-compose snippet too?")
+
+	var rendered bytes.Buffer
+	if err := config.WriteScaffold(&rendered, scaffold); err != nil {
+		return err
+	}
+	if err := os.WriteFile(initOutput, rendered.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write config: %w", err)
+	}
+	fmt.Fprintf(out, "\nWrote %s\n", initOutput)
+
+	if writeCompose {
+		if err := checkWritable(initComposeFile); err != nil {
+			return err
+		}
+		var compose bytes.Buffer
+		if err := config.WriteComposeSnippet(&compose, scaffold, initOutput); err != nil {
+			return err
+		}
+		if err := os.WriteFile(initComposeFile, compose.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write docker-compose snippet: %w", err)
+		}
+		fmt.Fprintf(out, "Wrote %s\n", initComposeFile)
+	}
+
+	fmt.Fprintf(out, "\nNext steps:\n")
+	fmt.Fprintf(out, "  mimic doctor --config %s   # check ports and targets\n", initOutput)
+	fmt.Fprintf(out, "  mimic --config %s          # start proxying\n", initOutput)
+	fmt.Fprintf(out, "  curl http://localhost:%d/proxy/%s/   # send traffic through the first proxy\n", scaffold.ListenPort, scaffold.Proxies[0].Name)
+	return nil
+}
+
+// checkWritable refuses to overwrite an existing file unless --force is given
+func checkWritable(path string) error {
+	if _, err := os.Stat(path); err == nil && !initForce {
+		return fmt.Errorf("%s already exists; use --force to overwrite it", path)
+	}
+	return nil
+}
+
+// prompter asks questions on the terminal, re-asking until an answer is usable
+type prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+// text returns the answer, or fallback when the answer is blank or input has ended
+func (p *prompter) text(question, fallback string) string {
+	if fallback != "" {
+		fmt.Fprintf(p.out, "%s [%s]: ", question, fallback)
+	} else {
+		fmt.Fprintf(p.out, "%s: ", question)
+	}
+
+	line, err := p.in.ReadString('\n')
+	if err != nil && line == "" {
+		fmt.Fprintln(p.out) // Input has ended; keep the next prompt on its own line
+	}
+	if answer := strings.TrimSpace(line); answer != "" {
+		return answer
+	}
+	return fallback
+}
+
+func (p *prompter) required(question string) string {
+	for {
+		if answer := p.text(question, ""); answer != "" {
+			return answer
+		}
+		fmt.Fprintln(p.out, "An answer is required.")
+		if _, err := p.in.Peek(1); err != nil {
+			log.Fatal("Input ended before the config was complete")
+		}
+	}
+}
+
+func (p *prompter) choice(question, fallback string, options ...string) string {
+	for {
+		answer := strings.ToLower(p.text(question, fallback))
+		for _, option := range options {
+			if answer == option {
+				return answer
+			}
+		}
+		fmt.Fprintf(p.out, "Choose one of: %s\n", strings.Join(options, ", "))
+	}
+}
+
+func (p *prompter) number(question string, fallback int) int {
+	for {
+		answer := p.text(question, strconv.Itoa(fallback))
+		if port, err := strconv.Atoi(answer); err == nil && port > 0 && port <= 65535 {
+			return port
+		}
+		fmt.Fprintln(p.out, "Enter a port between 1 and 65535.")
+	}
+}
+
+func (p *prompter) confirm(question string) bool {
+	answer := strings.ToLower(p.text(question+" (y/N)", ""))
+	return answer == "y" || answer == "yes"
+}
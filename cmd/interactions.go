@@ -0,0 +1,306 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"unicode/utf8"
+
+	"mimic/protoschema"
+	"mimic/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	interactionsSession  string
+	interactionsEndpoint string
+	interactionsMethod   string
+	interactionsStatus   int
+	interactionsDryRun   bool
+)
+
+var interactionsCmd = &cobra.Command{
+	Use:   "interactions",
+	Short: "Inspect and prune recorded interactions",
+	Long: `List, show, and delete individual recorded interactions without the web UI.
+--endpoint matches endpoints starting with the given path, or a glob when it contains *.`,
+}
+
+var interactionsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List a session's interactions",
+	Example: `  mimic interactions list --session checkout
+  mimic interactions list --session checkout --endpoint /api/orders --status 500`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db := mustOpenDatabase()
+		defer db.Close()
+
+		interactions := findInteractions(db)
+		if jsonOutput() {
+			printJSON(interactions)
+			return
+		}
+		if len(interactions) == 0 {
+			fmt.Println("No interactions found.")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tSEQ\tMETHOD\tENDPOINT\tSTATUS\tSIZE\tRECORDED")
+		for _, interaction := range interactions {
+			size := formatBytes(int64(len(interaction.ResponseBody)))
+			if interaction.IsStreaming {
+				size = "stream"
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%d\t%s\t%s\n", interaction.ID, interaction.SequenceNumber, interaction.Method,
+				interaction.Endpoint, interaction.ResponseStatus, size, interaction.Timestamp.Format("2006-01-02 15:04:05"))
+		}
+		w.Flush()
+	},
+}
+
+var interactionsShowCmd = &cobra.Command{
+	Use:   "show <id>",
+	Short: "Print an interaction's request and response",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			log.Fatalf("Invalid interaction ID: %s", args[0])
+		}
+
+		cfg, db := mustOpenDatabase()
+		defer db.Close()
+
+		interaction, err := db.GetInteraction(id)
+		if err != nil {
+			log.Fatal("Failed to get interaction:", err)
+		}
+		if interaction.Protocol == "gRPC" {
+			loadDescriptors(cfg)
+		}
+		var chunks []storage.StreamChunk
+		if interaction.IsStreaming {
+			if chunks, err = db.GetStreamChunks(id); err != nil {
+				log.Fatal("Failed to get stream chunks:", err)
+			}
+		}
+
+		if jsonOutput() {
+			printJSON(struct {
+				*storage.Interaction
+				StreamChunks []storage.StreamChunk `json:"stream_chunks,omitempty"`
+			}{interaction, chunks})
+			return
+		}
+
+		sessionName := strconv.Itoa(interaction.SessionID)
+		if session, err := db.GetSessionByID(interaction.SessionID); err == nil {
+			sessionName = session.SessionName
+		}
+		fmt.Printf("Interaction %d in session %s\n", interaction.ID, sessionName)
+		fmt.Printf("Request ID: %s\n", interaction.RequestID)
+		fmt.Printf("Recorded:   %s (%s, sequence %d)\n", interaction.Timestamp.Format("2006-01-02 15:04:05"), interaction.Protocol, interaction.SequenceNumber)
+		if tags := interaction.Tags(); len(tags) > 0 {
+			fmt.Printf("Tags:       %s\n", strings.Join(tags, ", "))
+		}
+		if annotation := interaction.Annotation(); annotation != "" {
+			fmt.Printf("Note:       %s\n", annotation)
+		}
+
+		fmt.Printf("\n%s %s\n", interaction.Method, interaction.Endpoint)
+		printHeaders(interaction.RequestHeaders)
+		printBody(decodeGRPCBody(interaction, protoschema.Request, interaction.RequestBody))
+
+		fmt.Printf("\n%d\n", interaction.ResponseStatus)
+		printHeaders(interaction.ResponseHeaders)
+		if interaction.IsStreaming {
+			var streamed bytes.Buffer
+			for _, chunk := range chunks {
+				streamed.Write(chunk.Data)
+			}
+			fmt.Printf("(streamed in %d chunks)\n", len(chunks))
+			printBody(streamed.Bytes())
+		} else {
+			printBody(decodeGRPCBody(interaction, protoschema.Response, interaction.ResponseBody))
+		}
+	},
+}
+
+var interactionsDeleteCmd = &cobra.Command{
+	Use:   "delete [id...]",
+	Short: "Delete interactions by ID, or those in a session matching filters",
+	Example: `  mimic interactions delete 41 42
+  mimic interactions delete --session checkout --endpoint '/api/*/debug' --dry-run`,
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db := mustOpenDatabase()
+		defer db.Close()
+
+		var interactions []storage.Interaction
+		switch {
+		case len(args) > 0:
+			if interactionsSession != "" {
+				log.Fatal("Give either interaction IDs or --session with filters, not both")
+			}
+			for _, arg := range args {
+				id, err := strconv.Atoi(arg)
+				if err != nil {
+					log.Fatalf("Invalid interaction ID: %s", arg)
+				}
+				interaction, err := db.GetInteraction(id)
+				if err != nil {
+					log.Fatal("Failed to get interaction:", err)
+				}
+				interactions = append(interactions, *interaction)
+			}
+		case interactionsEndpoint == "" && interactionsMethod == "" && interactionsStatus == 0:
+			log.Fatal("Give interaction IDs, or --session with --endpoint, --method, or --status; use 'mimic clear' to remove a whole session")
+		default:
+			interactions = findInteractions(db)
+		}
+
+		if interactionsDryRun && jsonOutput() {
+			printJSON(interactions)
+			return
+		}
+
+		ids := make([]int, len(interactions))
+		for i, interaction := range interactions {
+			ids[i] = interaction.ID
+			if interactionsDryRun {
+				fmt.Printf("Would delete %d: %s %s (%d)\n", interaction.ID, interaction.Method, interaction.Endpoint, interaction.ResponseStatus)
+			}
+		}
+		if interactionsDryRun {
+			fmt.Printf("%d interactions would be deleted\n", len(ids))
+			return
+		}
+
+		deleted, err := db.DeleteInteractions(ids)
+		if err != nil {
+			log.Fatal("Failed to delete interactions:", err)
+		}
+		if jsonOutput() {
+			printJSON(struct {
+				Deleted int64 `json:"deleted"`
+				IDs     []int `json:"ids"`
+			}{deleted, ids})
+			return
+		}
+		fmt.Printf("Deleted %d interactions\n", deleted)
+	},
+}
+
+func init() {
+	addOutputFlag(interactionsCmd, true)
+	addLegacyJSONFlag(interactionsCmd, true)
+	for _, cmd := range []*cobra.Command{interactionsListCmd, interactionsDeleteCmd} {
+		cmd.Flags().StringVar(&interactionsSession, "session", "", "session to search")
+		cmd.Flags().StringVar(&interactionsEndpoint, "endpoint", "", "only endpoints starting with this path, or matching this glob")
+		cmd.Flags().StringVar(&interactionsMethod, "method", "", "only this HTTP method")
+		cmd.Flags().IntVar(&interactionsStatus, "status", 0, "only this response status")
+		cmd.RegisterFlagCompletionFunc("session", completeSessions)
+	}
+	interactionsListCmd.MarkFlagRequired("session")
+	interactionsDeleteCmd.Flags().BoolVar(&interactionsDryRun, "dry-run", false, "print what would be deleted without deleting it")
+
+	interactionsCmd.AddCommand(interactionsListCmd)
+	interactionsCmd.AddCommand(interactionsShowCmd)
+	interactionsCmd.AddCommand(interactionsDeleteCmd)
+	rootCmd.AddCommand(interactionsCmd)
+}
+
+// findInteractions returns the interactions of --session that pass the filters
+func findInteractions(db *storage.Database) []storage.Interaction {
+	if interactionsSession == "" {
+		log.Fatal("Session name is required (--session)")
+	}
+	session, err := db.GetSession(interactionsSession)
+	if err != nil {
+		log.Fatal("Failed to get session:", err)
+	}
+	interactions, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		log.Fatal("Failed to get interactions:", err)
+	}
+
+	matched := []storage.Interaction{}
+	for _, interaction := range interactions {
+		if interactionsMethod != "" && !strings.EqualFold(interaction.Method, interactionsMethod) {
+			continue
+		}
+		if interactionsStatus != 0 && interaction.ResponseStatus != interactionsStatus {
+			continue
+		}
+		if interactionsEndpoint != "" && !endpointMatches(interactionsEndpoint, interaction.Endpoint) {
+			continue
+		}
+		matched = append(matched, interaction)
+	}
+	return matched
+}
+
+// endpointMatches treats patterns containing * as globs and others as path prefixes
+func endpointMatches(pattern, endpoint string) bool {
+	endpointPath, _, _ := strings.Cut(endpoint, "?")
+	if strings.Contains(pattern, "*") {
+		matched, _ := path.Match(pattern, endpointPath)
+		return matched
+	}
+	return strings.HasPrefix(endpointPath, pattern)
+}
+
+// printHeaders prints recorded headers one per line, sorted by name
+func printHeaders(headersJSON string) {
+	var headers map[string]interface{}
+	if err := json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+		return
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %v\n", name, headers[name])
+	}
+}
+
+// printBody prints a body after a blank line, indenting JSON and summarizing binary data
+// decodeGRPCBody renders a gRPC message as JSON when its descriptor is loaded, and returns other
+// bodies unchanged
+func decodeGRPCBody(interaction *storage.Interaction, direction protoschema.Direction, body []byte) []byte {
+	if interaction.Protocol != "gRPC" {
+		return body
+	}
+	if decoded, err := protoschema.Default.Decode(interaction.Method, direction, body); err == nil {
+		return decoded
+	}
+	return body
+}
+
+func printBody(body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	fmt.Println()
+
+	var indented bytes.Buffer
+	switch {
+	case json.Indent(&indented, body, "", "  ") == nil:
+		fmt.Println(indented.String())
+	case utf8.Valid(body):
+		fmt.Println(string(body))
+	default:
+		fmt.Printf("(%s of binary data)\n", formatBytes(int64(len(body))))
+	}
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"mimic/config"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateConfigDryRun bool
+
+var migrateConfigCmd = &cobra.Command{
+	Use:   "migrate-config [config file]",
+	Short: "Rewrite a config file to the current config_version",
+	Long: fmt.Sprintf(`Rewrite a YAML config file to config_version %d: deprecated keys are moved to their
+replacements and config_version is set, keeping comments. The original file is kept
+next to it with a .bak suffix.
+
+mimic still reads older files, rewriting deprecated keys as it loads them and warning
+about each one; this command makes the change permanent.`, config.CurrentConfigVersion),
+	Example: `  mimic migrate-config config.yaml
+  mimic migrate-config --dry-run config.yaml`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := cfgFile
+		if len(args) == 1 {
+			path = args[0]
+		}
+		if path == "" {
+			path = "config.yaml"
+		}
+		runMigrateConfig(path)
+	},
+}
+
+func init() {
+	migrateConfigCmd.Flags().BoolVar(&migrateConfigDryRun, "dry-run", false, "print the migrated config instead of writing it")
+	migrateConfigCmd.ValidArgsFunction = completeFiles("yaml", "yml")
+
+	rootCmd.AddCommand(migrateConfigCmd)
+}
+
+func runMigrateConfig(path string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Fatal("Failed to read config:", err)
+	}
+	migrated, changes, err := config.MigrateFile(data)
+	if err != nil {
+		log.Fatalf("Failed to migrate %s: %v", path, err)
+	}
+
+	if migrateConfigDryRun {
+		os.Stdout.Write(migrated)
+		return
+	}
+	if len(changes) == 0 {
+		fmt.Printf("%s is already at config_version %d\n", path, config.CurrentConfigVersion)
+		return
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		log.Fatal("Failed to read config:", err)
+	}
+	if err := os.WriteFile(path+".bak", data, info.Mode().Perm()); err != nil {
+		log.Fatal("Failed to write backup:", err)
+	}
+	if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+		log.Fatal("Failed to write config:", err)
+	}
+
+	for _, change := range changes {
+		fmt.Printf("  %s: %s\n", change.Key, change.Message)
+	}
+	fmt.Printf("Migrated %s to config_version %d (original saved as %s.bak)\n", path, config.CurrentConfigVersion, path)
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"mimic/config"
+	"mimic/export"
+	"mimic/server"
+	"mimic/storage"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultFixturesPath is where container mode looks for exports when no files are given
+const defaultFixturesPath = "/fixtures"
+
+var mockCmd = &cobra.Command{
+	Use:   "mock [export files or directories...]",
+	Short: "Serve export files in mock mode without a config file or database",
+	Long: `Start mimic in mock mode directly from one or more export files. Each file is served
+as its own proxy at /proxy/<file name>/, from an in-memory database; nothing is written to disk.
+
+No config file is read; settings come from environment variables:
+  MIMIC_LISTEN_HOST        HTTP listen address (default 0.0.0.0)
+  MIMIC_LISTEN_PORT        HTTP port for the proxies and web UI (default 8080)
+  MIMIC_GRPC_PORT          gRPC port for sessions with gRPC interactions (default 9080)
+  MIMIC_MATCHING_STRATEGY  exact, pattern, fuzzy, fuzzy-unordered, or fuzzy-subset (default exact)
+  MIMIC_SEQUENCE_MODE      ordered or random (default ordered)
+  MIMIC_FIXTURES           Files or directories to load when none are given (default /fixtures)
+
+Globs are expanded by mimic itself, so "mimic mock '/fixtures/*.json'" works without a shell.`,
+	Example: `  docker run -p 8080:8080 -v $PWD/fixtures:/fixtures mimic mock /fixtures/*.json`,
+	Run: func(cmd *cobra.Command, args []string) {
+		runMockFromExports(args)
+	},
+}
+
+func init() {
+	mockCmd.ValidArgsFunction = completeExportFiles
+	rootCmd.AddCommand(mockCmd)
+}
+
+func runMockFromExports(args []string) {
+	if len(args) == 0 {
+		args = strings.Split(envOr("MIMIC_FIXTURES", defaultFixturesPath), string(os.PathListSeparator))
+	}
+
+	files, err := resolveExportFiles(args)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, err := mockConfigFromEnv()
+	if err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+	serveExports(cfg, files)
+}
+
+// serveExports imports each export file into an in-memory database as its own proxy and serves
+// them all in mock mode until interrupted
+func serveExports(cfg *config.Config, files []string) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	defer db.Close()
+
+	exportManager := export.NewExportManager(cfg, db)
+	grpcDefaultSet := false
+	for _, file := range files {
+		name := proxyNameForExport(file)
+		if _, exists := cfg.Proxies[name]; exists {
+			log.Fatalf("Two export files map to the proxy name '%s'; rename one of them", name)
+		}
+		if err := exportManager.ImportSession(file, name, "append"); err != nil {
+			log.Fatalf("Failed to import %s: %v", file, err)
+		}
+
+		protocol := "http"
+		if grpcSession, err := sessionHasGRPC(db, name); err != nil {
+			log.Fatalf("Failed to inspect %s: %v", file, err)
+		} else if grpcSession {
+			protocol = "grpc"
+		}
+
+		proxyConfig := config.ProxyConfig{
+			Name:        name,
+			Protocol:    protocol,
+			SessionName: name,
+		}
+		// The first gRPC export answers calls no other route claims
+		if protocol == "grpc" && !grpcDefaultSet {
+			proxyConfig.IsDefault = true
+			grpcDefaultSet = true
+		}
+		cfg.Proxies[name] = proxyConfig
+		log.Printf("Loaded %s as %s proxy '%s'", file, protocol, name)
+	}
+
+	if err := cfg.Validate(); err != nil {
+		log.Fatal("Invalid configuration:", err)
+	}
+
+	multiServer, err := server.NewMultiProxyServer(cfg, db)
+	if err != nil {
+		log.Fatal("Failed to create multi-proxy server:", err)
+	}
+
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		<-c
+		log.Println("Shutting down...")
+		multiServer.FlushSequenceState()
+		db.Close()
+		os.Exit(0)
+	}()
+
+	if err := multiServer.Start(); err != nil {
+		log.Fatal("Server failed:", err)
+	}
+}
+
+// mockConfigFromEnv builds a mock-mode config from defaults and MIMIC_* environment variables
+func mockConfigFromEnv() (*config.Config, error) {
+	cfg := config.DefaultConfig()
+	cfg.Mode = "mock"
+	cfg.Proxies = make(map[string]config.ProxyConfig)
+
+	cfg.Server.ListenHost = envOr("MIMIC_LISTEN_HOST", cfg.Server.ListenHost)
+	cfg.Mock.MatchingStrategy = envOr("MIMIC_MATCHING_STRATEGY", cfg.Mock.MatchingStrategy)
+	cfg.Mock.SequenceMode = envOr("MIMIC_SEQUENCE_MODE", cfg.Mock.SequenceMode)
+
+	var err error
+	if cfg.Server.ListenPort, err = envIntOr("MIMIC_LISTEN_PORT", cfg.Server.ListenPort); err != nil {
+		return nil, err
+	}
+	if cfg.Server.GRPCPort, err = envIntOr("MIMIC_GRPC_PORT", cfg.Server.GRPCPort); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// resolveExportFiles expands globs and directories into a sorted list of export files
+func resolveExportFiles(args []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+
+	for _, arg := range args {
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no export files match %s", arg)
+		}
+
+		for _, match := range matches {
+			info, err := os.Stat(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", match, err)
+			}
+			if !info.IsDir() {
+				add(match)
+				continue
+			}
+
+			entries, err := os.ReadDir(match)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s: %w", match, err)
+			}
+			for _, entry := range entries {
+				if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".json") || strings.HasSuffix(entry.Name(), ".json.gz")) {
+					add(filepath.Join(match, entry.Name()))
+				}
+			}
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no export files found in %s", strings.Join(args, ", "))
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+var unsafeProxyNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// proxyNameForExport derives a URL-safe proxy name from an export's file name
+func proxyNameForExport(path string) string {
+	name := filepath.Base(path)
+	name = strings.TrimSuffix(name, ".gz")
+	name = strings.TrimSuffix(name, ".json")
+	name = unsafeProxyNameChars.ReplaceAllString(name, "-")
+	if name == "" {
+		name = "default"
+	}
+	return name
+}
+
+func sessionHasGRPC(db *storage.Database, sessionName string) (bool, error) {
+	session, err := db.GetSession(sessionName)
+	if err != nil {
+		return false, err
+	}
+	interactions, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		return false, err
+	}
+	for _, interaction := range interactions {
+		if interaction.Protocol == "gRPC" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+func envIntOr(key string, fallback int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("%s must be a number: %q", key, value)
+	}
+	return parsed, nil
+}
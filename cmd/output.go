@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// Values of --output
+const (
+	outputText = "text"
+	outputJSON = "json"
+)
+
+var (
+	// outputFormat is the --output of the command being run; only one command runs per process
+	outputFormat = outputText
+	// legacyJSON is the deprecated --json flag of commands that had it before --output
+	legacyJSON bool
+)
+
+// outputFormatValue is a flag value that only accepts the supported output formats
+type outputFormatValue struct {
+	target *string
+}
+
+func (v outputFormatValue) String() string { return *v.target }
+
+func (v outputFormatValue) Set(value string) error {
+	if value != outputText && value != outputJSON {
+		return fmt.Errorf("must be %q or %q", outputText, outputJSON)
+	}
+	*v.target = value
+	return nil
+}
+
+func (v outputFormatValue) Type() string { return "format" }
+
+// addOutputFlag lets a command print JSON instead of text. Commands whose --output already names
+// a file use --output-format instead.
+func addOutputFlag(cmd *cobra.Command, persistent bool) {
+	flags := cmd.Flags()
+	if persistent {
+		flags = cmd.PersistentFlags()
+	}
+	if cmd.Flags().Lookup("output") != nil {
+		flags.Var(outputFormatValue{&outputFormat}, "output-format", "output format: text or json")
+		cmd.RegisterFlagCompletionFunc("output-format", completeValues(outputText, outputJSON))
+		return
+	}
+	flags.VarP(outputFormatValue{&outputFormat}, "output", "o", "output format: text or json")
+	cmd.RegisterFlagCompletionFunc("output", completeValues(outputText, outputJSON))
+}
+
+// addLegacyJSONFlag keeps --json working on commands that had it before --output existed
+func addLegacyJSONFlag(cmd *cobra.Command, persistent bool) {
+	flags := cmd.Flags()
+	if persistent {
+		flags = cmd.PersistentFlags()
+	}
+	flags.BoolVar(&legacyJSON, "json", false, "print JSON instead of text")
+	flags.MarkDeprecated("json", "use --output json instead")
+}
+
+// jsonOutput reports whether the command should print JSON
+func jsonOutput() bool {
+	return outputFormat == outputJSON || legacyJSON
+}
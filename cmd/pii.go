@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"mimic/pii"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	scanPIIApply bool
+	scanPIIKinds []string
+)
+
+var sessionsScanPIICmd = &cobra.Command{
+	Use:   "scan-pii <session>",
+	Short: "Find likely personal data in a session",
+	Long: `Scan a session's headers, bodies, and stream chunks for likely personal data: emails,
+phone numbers, card numbers (checked with the Luhn checksum), and JWTs. Each finding names the
+interaction and where in it the data was found, with a masked sample, and the report ends with
+recording.redact_patterns that would keep each kind out of future recordings.
+
+With --apply, the matches are replaced with [REDACTED] in place, so the session can be exported
+or shared. Only the kinds found are redacted, or those given with --kinds. mimic asks before
+changing the session unless --force is given.`,
+	Example: `  mimic sessions scan-pii checkout
+  mimic sessions scan-pii checkout --apply --kinds email,credit_card
+  mimic sessions scan-pii checkout -o json | jq .suggestions`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := pii.ValidateKinds(scanPIIKinds); err != nil {
+			log.Fatal(err)
+		}
+		_, db := mustOpenDatabase()
+		defer db.Close()
+
+		report, err := pii.ScanSession(db, args[0])
+		if err != nil {
+			log.Fatal("Failed to scan session:", err)
+		}
+		if !scanPIIApply {
+			if jsonOutput() {
+				printJSON(report)
+			} else {
+				printPIIReport(report)
+			}
+			return
+		}
+
+		kinds := scanPIIKinds
+		if len(kinds) == 0 {
+			for _, suggestion := range report.Suggestions {
+				kinds = append(kinds, suggestion.Kind)
+			}
+		}
+		if len(kinds) == 0 {
+			if jsonOutput() {
+				printJSON(pii.RedactResult{Session: args[0], Kinds: []string{}})
+			} else {
+				fmt.Printf("No personal data found in %d interactions of '%s'\n", report.Interactions, args[0])
+			}
+			return
+		}
+		confirmSessionChange(cmd, fmt.Sprintf("Redact %s in session '%s' in place? This cannot be undone.", strings.Join(kinds, ", "), args[0]))
+
+		result, err := pii.RedactSession(db, args[0], kinds)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if jsonOutput() {
+			printJSON(result)
+			return
+		}
+		fmt.Printf("Redacted %s in %d interactions of '%s'\n", strings.Join(result.Kinds, ", "), result.Interactions, result.Session)
+	},
+}
+
+func init() {
+	sessionsScanPIICmd.Flags().BoolVar(&scanPIIApply, "apply", false, "replace the matches with [REDACTED] in place")
+	sessionsScanPIICmd.Flags().StringSliceVar(&scanPIIKinds, "kinds", nil, "kinds to redact with --apply: "+strings.Join(pii.Kinds(), ", ")+" (default: the kinds found)")
+	sessionsScanPIICmd.Flags().BoolVarP(&sessionsForce, "force", "f", false, "do not ask for confirmation")
+	sessionsScanPIICmd.RegisterFlagCompletionFunc("kinds", completeValues(pii.Kinds()...))
+	sessionsScanPIICmd.ValidArgsFunction = completeSessionArgs(1)
+
+	sessionsCmd.AddCommand(sessionsScanPIICmd)
+}
+
+func printPIIReport(report *pii.Report) {
+	if len(report.Findings) == 0 {
+		fmt.Printf("No personal data found in %d interactions of '%s'\n", report.Interactions, report.Session)
+		return
+	}
+
+	affected := make(map[int]bool)
+	for _, finding := range report.Findings {
+		affected[finding.InteractionID] = true
+	}
+	fmt.Printf("Found likely personal data in %d of %d interactions of '%s'\n\n", len(affected), report.Interactions, report.Session)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tMETHOD\tENDPOINT\tLOCATION\tKIND\tCOUNT\tSAMPLE")
+	for _, finding := range report.Findings {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n", finding.InteractionID, finding.Method, finding.Endpoint,
+			finding.Location, finding.Kind, finding.Count, finding.Sample)
+	}
+	w.Flush()
+
+	fmt.Println("\nSuggested redact patterns:")
+	fmt.Println("recording:")
+	fmt.Println("  redact_patterns:")
+	for _, suggestion := range report.Suggestions {
+		fmt.Printf("    - '%s'  # %s: %d matches in %d interactions\n", suggestion.Pattern, suggestion.Kind, suggestion.Matches, suggestion.Interactions)
+	}
+	fmt.Printf("\nRun 'mimic sessions scan-pii %s --apply' to redact them in place.\n", report.Session)
+}
@@ -0,0 +1,207 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"mimic/config"
+	"mimic/proxy"
+	"mimic/redact"
+	"mimic/webhook"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	recordRequestMethod   string
+	recordRequestHeaders  []string
+	recordRequestDataFile string
+	recordRequestSession  string
+	recordRequestProxy    string
+	recordRequestInclude  bool
+)
+
+var recordRequestCmd = &cobra.Command{
+	Use:   "record-request <url>",
+	Short: "Send one request to a target and record it into a session",
+	Long: `Send a single request to a target through mimic's recording pipeline and store the
+interaction in a session, without configuring a client to use the proxy. The response is
+recorded exactly as the proxy would record it, including streamed (SSE) responses.
+
+--proxy takes the upstream settings (transport, outbound proxy, forwarding headers, streaming)
+and the session from a configured proxy; the URL still decides where the request goes.`,
+	Example: `  mimic record-request https://api.example.com/v1/orders --session checkout
+  mimic record-request https://api.example.com/v1/orders -X POST \
+    -H 'Content-Type: application/json' --data-file order.json --session checkout
+  mimic record-request http://localhost:3000/health --proxy backend`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runRecordRequest(args[0])
+	},
+}
+
+func init() {
+	recordRequestCmd.Flags().StringVarP(&recordRequestMethod, "request", "X", "", "HTTP method (default GET, or POST with --data-file)")
+	recordRequestCmd.Flags().StringArrayVarP(&recordRequestHeaders, "header", "H", nil, "request header as 'Name: value' (repeatable)")
+	recordRequestCmd.Flags().StringVar(&recordRequestDataFile, "data-file", "", "file to send as the request body, or - for stdin")
+	recordRequestCmd.Flags().StringVar(&recordRequestSession, "session", "", "session to record into (default: the --proxy session)")
+	recordRequestCmd.Flags().StringVar(&recordRequestProxy, "proxy", "", "configured proxy whose upstream settings and session to use")
+	recordRequestCmd.Flags().BoolVarP(&recordRequestInclude, "include", "i", false, "print the response status and headers before the body")
+	recordRequestCmd.RegisterFlagCompletionFunc("request", completeValues("GET", "POST", "PUT", "PATCH", "DELETE", "HEAD", "OPTIONS"))
+	recordRequestCmd.RegisterFlagCompletionFunc("session", completeSessions)
+	recordRequestCmd.RegisterFlagCompletionFunc("proxy", completeProxies)
+	addOutputFlag(recordRequestCmd, false)
+	rootCmd.AddCommand(recordRequestCmd)
+}
+
+func runRecordRequest(rawURL string) {
+	cfg, db := mustOpenDatabase()
+	defer db.Close()
+
+	target, err := url.Parse(rawURL)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Hostname() == "" {
+		log.Fatalf("Invalid URL %q: must be an absolute http or https URL", rawURL)
+	}
+
+	proxyConfig := config.ProxyConfig{Name: "record-request", EnableStreaming: true}
+	if recordRequestProxy != "" {
+		configured, ok := cfg.Proxies[recordRequestProxy]
+		if !ok {
+			log.Fatalf("Proxy '%s' not found in config", recordRequestProxy)
+		}
+		if configured.Protocol == "grpc" {
+			log.Fatalf("Proxy '%s' is a gRPC proxy; record-request sends HTTP requests", recordRequestProxy)
+		}
+		proxyConfig = configured
+	}
+	proxyConfig.Protocol = target.Scheme
+	proxyConfig.TargetHost = target.Hostname()
+	proxyConfig.TargetPort = defaultPort(target)
+	if recordRequestSession != "" {
+		proxyConfig.SessionName = recordRequestSession
+	}
+	if proxyConfig.SessionName == "" {
+		log.Fatal("Session name is required (--session)")
+	}
+
+	req := buildRecordedRequest(target)
+	if host := req.Header.Get("Host"); host != "" {
+		// A Host header cannot travel in the header map, so send it the way host_header does
+		proxyConfig.HostHeader = host
+		req.Header.Del("Host")
+	}
+
+	engine, err := proxy.NewProxyEngine(proxyConfig, db)
+	if err != nil {
+		log.Fatal("Failed to create proxy engine:", err)
+	}
+	redactor, err := redact.New(cfg.Recording.RedactPatterns, cfg.Recording.RedactPresets)
+	if err != nil {
+		log.Fatal("Failed to configure redaction:", err)
+	}
+	engine.SetRedactor(redactor)
+	session, err := db.GetSession(proxyConfig.SessionName)
+	if err != nil {
+		log.Fatal("Failed to get session:", err)
+	}
+	before, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		log.Fatal("Failed to get interactions:", err)
+	}
+
+	webhook.Configure(cfg.Webhooks)
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, req)
+	// Deliver recording webhooks before exiting
+	webhook.Default.Wait(30 * time.Second)
+
+	resp := recorder.Result()
+	after, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		log.Fatal("Failed to get interactions:", err)
+	}
+	if len(after) == len(before) {
+		log.Fatalf("Request was not recorded: %d %s", resp.StatusCode, strings.TrimSpace(recorder.Body.String()))
+	}
+
+	if jsonOutput() {
+		// The newest interaction in the session is the one just recorded
+		recorded := after[0]
+		for _, interaction := range after {
+			if interaction.ID > recorded.ID {
+				recorded = interaction
+			}
+		}
+		printJSON(recorded)
+		return
+	}
+
+	if recordRequestInclude {
+		fmt.Printf("%s %s\n", resp.Proto, resp.Status)
+		resp.Header.Write(os.Stdout)
+		fmt.Println()
+	}
+	os.Stdout.Write(recorder.Body.Bytes())
+	fmt.Fprintf(os.Stderr, "Recorded %s %s -> %d in session '%s'\n", req.Method, target.Path, resp.StatusCode, proxyConfig.SessionName)
+}
+
+// buildRecordedRequest turns the command's flags into the request the proxy engine handles
+func buildRecordedRequest(target *url.URL) *http.Request {
+	var body []byte
+	if recordRequestDataFile != "" {
+		var err error
+		if recordRequestDataFile == "-" {
+			body, err = io.ReadAll(os.Stdin)
+		} else {
+			body, err = os.ReadFile(recordRequestDataFile)
+		}
+		if err != nil {
+			log.Fatal("Failed to read request body:", err)
+		}
+	}
+
+	method := strings.ToUpper(recordRequestMethod)
+	if method == "" {
+		method = http.MethodGet
+		if recordRequestDataFile != "" {
+			method = http.MethodPost
+		}
+	}
+
+	req, err := http.NewRequest(method, target.String(), bytes.NewReader(body))
+	if err != nil {
+		log.Fatal("Failed to create request:", err)
+	}
+	if recordRequestDataFile == "" {
+		req.Body = http.NoBody
+	}
+	req.RemoteAddr = "127.0.0.1:0"
+	for _, header := range recordRequestHeaders {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok || strings.TrimSpace(name) == "" {
+			log.Fatalf("Invalid header %q: must be 'Name: value'", header)
+		}
+		req.Header.Add(strings.TrimSpace(name), strings.TrimSpace(value))
+	}
+	return req
+}
+
+// defaultPort returns the URL's port, or the scheme's default when it has none
+func defaultPort(target *url.URL) int {
+	if port, err := strconv.Atoi(target.Port()); err == nil {
+		return port
+	}
+	if target.Scheme == "https" {
+		return 443
+	}
+	return 80
+}
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"time"
 
+	"mimic/accesslog"
 	"mimic/config"
 	"mimic/replay"
 	"mimic/storage"
+	"mimic/webhook"
 
 	"github.com/spf13/cobra"
 )
@@ -25,6 +28,8 @@ var (
 	replayInsecureSkipVerify bool
 	replayGRPCMaxMessageSize int
 	replayGRPCInsecure       bool
+	replayOutboundProxy      string
+	replayTag                string
 )
 
 var replayCmd = &cobra.Command{
@@ -39,10 +44,11 @@ This validates that the target server returns the same responses as were origina
 
 func init() {
 	replayCmd.Flags().StringVar(&replaySessionName, "session", "", "session name to replay (required)")
+	replayCmd.Flags().StringVar(&replayTag, "tag", "", "replay only the interactions recorded with this X-Mimic-Tag")
 	replayCmd.Flags().StringVar(&replayTargetHost, "target-host", "", "target server hostname (required)")
 	replayCmd.Flags().IntVar(&replayTargetPort, "target-port", 443, "target server port")
 	replayCmd.Flags().StringVar(&replayProtocol, "protocol", "https", "target server protocol (http, https, or grpc)")
-	replayCmd.Flags().StringVar(&replayMatchingStrategy, "matching-strategy", "exact", "response matching strategy (exact, fuzzy, or status_code)")
+	replayCmd.Flags().StringVar(&replayMatchingStrategy, "matching-strategy", "exact", "response matching strategy (exact, fuzzy, status_code, or contract)")
 	replayCmd.Flags().BoolVar(&replayFailFast, "fail-fast", false, "exit on first mismatch (otherwise collect all errors)")
 	replayCmd.Flags().IntVar(&replayTimeoutSeconds, "timeout", 30, "request timeout in seconds")
 	replayCmd.Flags().IntVar(&replayMaxConcurrency, "concurrency", 0, "max concurrent requests (0 for sequential)")
@@ -50,20 +56,25 @@ func init() {
 	replayCmd.Flags().BoolVar(&replayInsecureSkipVerify, "insecure-skip-verify", false, "skip TLS verification for HTTPS/gRPC")
 	replayCmd.Flags().IntVar(&replayGRPCMaxMessageSize, "grpc-max-message-size", 256*1024*1024, "max gRPC message size in bytes")
 	replayCmd.Flags().BoolVar(&replayGRPCInsecure, "grpc-insecure", false, "use insecure gRPC connection (no TLS)")
+	replayCmd.Flags().StringVar(&replayOutboundProxy, "outbound-proxy", "", "egress proxy URL for the target, or 'none' (default: replay.outbound_proxy, then HTTP(S)_PROXY)")
 
 	replayCmd.MarkFlagRequired("session")
 	replayCmd.MarkFlagRequired("target-host")
+	replayCmd.RegisterFlagCompletionFunc("session", completeSessions)
+	replayCmd.RegisterFlagCompletionFunc("protocol", completeValues("http", "https", "grpc"))
+	replayCmd.RegisterFlagCompletionFunc("matching-strategy", completeValues("exact", "fuzzy", "status_code", "contract"))
+	addOutputFlag(replayCmd, false)
 
 	rootCmd.AddCommand(replayCmd)
 }
 
 func runReplay() {
-	cfg, err := config.LoadConfig(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		log.Fatal("Failed to load config:", err)
 	}
 
-	db, err := storage.NewDatabase(cfg.Database.Path)
+	db, err := storage.Open(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
@@ -75,11 +86,16 @@ func runReplay() {
 		TargetPort:       replayTargetPort,
 		Protocol:         replayProtocol,
 		SessionName:      replaySessionName,
+		Tag:              replayTag,
 		MatchingStrategy: replayMatchingStrategy,
 		FailFast:         replayFailFast,
 		TimeoutSeconds:   replayTimeoutSeconds,
 		MaxConcurrency:   replayMaxConcurrency,
 		IgnoreTimestamps: replayIgnoreTimestamps,
+		OutboundProxy:    cfg.Replay.OutboundProxy,
+	}
+	if replayOutboundProxy != "" {
+		replayConfig.OutboundProxy = replayOutboundProxy
 	}
 
 	// Validate the replay config
@@ -92,8 +108,8 @@ func runReplay() {
 	if replayConfig.Protocol != "http" && replayConfig.Protocol != "https" && replayConfig.Protocol != "grpc" {
 		log.Fatal("protocol must be 'http', 'https', or 'grpc'")
 	}
-	if replayConfig.MatchingStrategy != "exact" && replayConfig.MatchingStrategy != "fuzzy" && replayConfig.MatchingStrategy != "status_code" {
-		log.Fatal("matching-strategy must be 'exact', 'fuzzy', or 'status_code'")
+	if replayConfig.MatchingStrategy != "exact" && replayConfig.MatchingStrategy != "fuzzy" && replayConfig.MatchingStrategy != "status_code" && replayConfig.MatchingStrategy != "contract" {
+		log.Fatal("matching-strategy must be 'exact', 'fuzzy', 'status_code', or 'contract'")
 	}
 
 	// Create and run the replay engine
@@ -105,7 +121,30 @@ func runReplay() {
 	fmt.Printf("Starting replay of session '%s' against %s://%s:%d\n",
 		replayConfig.SessionName, replayConfig.Protocol, replayConfig.TargetHost, replayConfig.TargetPort)
 
+	webhook.Configure(cfg.Webhooks)
+	if err := accesslog.Configure(cfg.AccessLog); err != nil {
+		log.Fatal("Failed to open access log:", err)
+	}
+	defer accesslog.Default.Close()
 	replaySession, err := engine.Replay()
+	finished := webhook.ReplayFinishedEvent{
+		Source:  "cli",
+		Session: replayConfig.SessionName,
+		Target:  fmt.Sprintf("%s://%s:%d", replayConfig.Protocol, replayConfig.TargetHost, replayConfig.TargetPort),
+	}
+	if replaySession != nil {
+		finished.Total = replaySession.TotalRequests
+		finished.Successes = replaySession.SuccessCount
+		finished.Failures = replaySession.FailureCount
+		finished.DurationMs = replaySession.Duration.Milliseconds()
+	}
+	if err != nil {
+		finished.Error = err.Error()
+	}
+	webhook.ReplayFinished(finished)
+	// Deliver before any exit below
+	webhook.Default.Wait(30 * time.Second)
+
 	if err != nil {
 		if replayConfig.FailFast {
 			log.Fatal("Replay failed:", err)
@@ -114,6 +153,18 @@ func runReplay() {
 		}
 	}
 
+	if replaySession == nil {
+		log.Fatal("Replay failed:", err)
+	}
+
+	if jsonOutput() {
+		printJSON(replaySession)
+		if replaySession.FailureCount > 0 {
+			os.Exit(1)
+		}
+		return
+	}
+
 	// Print summary
 	fmt.Printf("\nReplay Summary:\n")
 	fmt.Printf("Session: %s\n", replaySession.SessionName)
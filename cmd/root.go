@@ -6,11 +6,13 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"mimic/config"
 	"mimic/export"
 	"mimic/server"
 	"mimic/storage"
+	"mimic/webhook"
 
 	"github.com/spf13/cobra"
 )
@@ -23,15 +25,23 @@ var (
 	mergeStrategy string
 	debugMode     bool
 	modeFlag      string
+	serverSession string
+	serverPort    int
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "mimic",
 	Short: "API Mimic - Record and replay API interactions",
 	Long: `A transparent proxy for intercepting, recording, and mocking API calls.
-Supports both REST and gRPC protocols with SQLite storage and JSON export/import.`,
+Supports both REST and gRPC protocols with SQLite storage and JSON export/import.
+
+Run without a subcommand, mimic serves every configured proxy under /proxy/<name>/ along
+with the web UI and admin API. --mode, --session, and --port override the config file.`,
+	Example: `  mimic --config config.yaml
+  mimic --mode mock --session checkout --port 9000`,
+	Args: cobra.NoArgs,
 	Run: func(cmd *cobra.Command, args []string) {
-		runProxy()
+		runProxy(cmd)
 	},
 }
 
@@ -43,6 +53,11 @@ func init() {
 	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is config.yaml)")
 	rootCmd.PersistentFlags().BoolVar(&debugMode, "debug", false, "enable debug logging")
 	rootCmd.PersistentFlags().StringVar(&modeFlag, "mode", "", "operation mode (record, mock, or replay) - overrides config file setting")
+	rootCmd.RegisterFlagCompletionFunc("config", completeFiles("yaml", "yml"))
+	rootCmd.Flags().StringVar(&serverSession, "session", "", "session for every proxy - overrides each proxy's session_name")
+	rootCmd.Flags().IntVarP(&serverPort, "port", "p", 0, "HTTP port for the proxies and web UI - overrides server.listen_port")
+	rootCmd.RegisterFlagCompletionFunc("mode", completeValues("record", "mock", "replay"))
+	rootCmd.RegisterFlagCompletionFunc("session", completeSessions)
 
 	rootCmd.AddCommand(exportCmd)
 	rootCmd.AddCommand(importCmd)
@@ -51,14 +66,28 @@ func init() {
 	rootCmd.AddCommand(webCmd)
 }
 
-func runProxy() {
+// loadConfig loads the config file, logging the unknown and deprecated keys in it
+func loadConfig() (*config.Config, error) {
+	cfg, err := config.LoadConfig(cfgFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, warning := range cfg.Warnings {
+		log.Printf("Warning: config key %s: %s", warning.Key, warning.Message)
+	}
+	return cfg, nil
+}
+
+// runProxy starts the multi-proxy server, the one server behind every configured proxy, the web
+// UI, and the admin API
+func runProxy(cmd *cobra.Command) {
 	// Set up debug logging if requested
 	if debugMode {
 		log.SetFlags(log.LstdFlags | log.Lshortfile)
 		log.Println("Debug mode enabled")
 	}
 
-	cfg, err := config.LoadConfig(cfgFile)
+	cfg, err := loadConfig()
 	if err != nil {
 		log.Fatal("Failed to load config:", err)
 	}
@@ -67,12 +96,21 @@ func runProxy() {
 	if modeFlag != "" {
 		cfg.Mode = modeFlag
 	}
+	if cmd.Flags().Changed("session") {
+		for name, proxyConfig := range cfg.Proxies {
+			proxyConfig.SessionName = serverSession
+			cfg.Proxies[name] = proxyConfig
+		}
+	}
+	if cmd.Flags().Changed("port") {
+		cfg.Server.ListenPort = serverPort
+	}
 
 	if err := cfg.Validate(); err != nil {
 		log.Fatal("Invalid configuration:", err)
 	}
 
-	db, err := storage.NewDatabase(cfg.Database.Path)
+	db, err := storage.Open(cfg.Database)
 	if err != nil {
 		log.Fatal("Failed to initialize database:", err)
 	}
@@ -90,6 +128,7 @@ func runProxy() {
 	go func() {
 		<-c
 		log.Println("Shutting down...")
+		multiServer.FlushSequenceState()
 		os.Exit(0)
 	}()
 
@@ -101,7 +140,24 @@ func runProxy() {
 var exportCmd = &cobra.Command{
 	Use:   "export",
 	Short: "Export session data to JSON",
-	Long:  `Export recorded session data to JSON format for backup or CI/CD integration.`,
+	Long: `Export recorded session data to JSON format for backup or CI/CD integration.
+
+When --output is a directory, the session is written to <session>.json inside it.
+With --watch, export keeps running and writes the session again whenever interactions
+are recorded into it, once recording has been quiet for --debounce. This keeps a
+fixtures directory in sync while you record.
+
+A session recorded in a shared environment can be sliced per consumer: --consumer exports
+only the interactions attributed to one consumer, and --by-consumer writes each consumer's
+slice to <session>-<consumer>.json in the --output directory.
+
+--tag exports only the interactions recorded with that X-Mimic-Tag, one scenario of a
+recording run, to <session>-<tag>.json; it can be combined with the consumer flags.`,
+	Example: `  mimic export --session checkout --output checkout.json
+  mimic export --session checkout --output fixtures/ --watch
+  mimic export --session staging --consumer web-app --output fixtures/
+  mimic export --session staging --by-consumer --output fixtures/
+  mimic export --session checkout --tag checkout-happy-path --output fixtures/`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if sessionName == "" {
 			log.Fatal("Session name is required (--session)")
@@ -110,23 +166,48 @@ var exportCmd = &cobra.Command{
 			log.Fatal("Output file is required (--output)")
 		}
 
-		cfg, err := config.LoadConfig(cfgFile)
+		cfg, err := loadConfig()
 		if err != nil {
 			log.Fatal("Failed to load config:", err)
 		}
 
-		db, err := storage.NewDatabase(cfg.Database.Path)
+		db, err := storage.Open(cfg.Database)
 		if err != nil {
 			log.Fatal("Failed to initialize database:", err)
 		}
 		defer db.Close()
 
+		loadDescriptors(cfg)
 		exportManager := export.NewExportManager(cfg, db)
+		if exportByConsumer {
+			exportEachConsumer(cfg, exportManager)
+			return
+		}
+		slice := export.Slice{Consumer: exportConsumer, Tag: exportTag}
+		outputFile = exportPath(cfg, outputFile, sliceName(sessionName, slice))
 
-		if err := exportManager.ExportSession(sessionName, outputFile); err != nil {
+		if exportWatch {
+			watchExport(exportManager)
+			return
+		}
+
+		count, err := exportManager.ExportSlice(sessionName, slice, outputFile)
+		if err != nil {
 			log.Fatal("Failed to export session:", err)
 		}
 
+		if jsonOutput() {
+			printJSON(consumerExport{sessionName, exportConsumer, exportTag, outputFile, count})
+			return
+		}
+		if exportTag != "" {
+			fmt.Printf("Tag '%s' of session '%s' exported to '%s' (%d interactions)\n", exportTag, sessionName, outputFile, count)
+			return
+		}
+		if exportConsumer != "" {
+			fmt.Printf("Consumer '%s' of session '%s' exported to '%s' (%d interactions)\n", exportConsumer, sessionName, outputFile, count)
+			return
+		}
 		fmt.Printf("Session '%s' exported to '%s'\n", sessionName, outputFile)
 	},
 }
@@ -140,17 +221,18 @@ var importCmd = &cobra.Command{
 			log.Fatal("Input file is required (--input)")
 		}
 
-		cfg, err := config.LoadConfig(cfgFile)
+		cfg, err := loadConfig()
 		if err != nil {
 			log.Fatal("Failed to load config:", err)
 		}
 
-		db, err := storage.NewDatabase(cfg.Database.Path)
+		db, err := storage.Open(cfg.Database)
 		if err != nil {
 			log.Fatal("Failed to initialize database:", err)
 		}
 		defer db.Close()
 
+		loadDescriptors(cfg)
 		exportManager := export.NewExportManager(cfg, db)
 
 		if err := exportManager.ImportSession(inputFile, sessionName, mergeStrategy); err != nil {
@@ -166,12 +248,12 @@ var listSessionsCmd = &cobra.Command{
 	Short: "List all recorded sessions",
 	Long:  `List all recorded sessions in the database with their metadata.`,
 	Run: func(cmd *cobra.Command, args []string) {
-		cfg, err := config.LoadConfig(cfgFile)
+		cfg, err := loadConfig()
 		if err != nil {
 			log.Fatal("Failed to load config:", err)
 		}
 
-		db, err := storage.NewDatabase(cfg.Database.Path)
+		db, err := storage.Open(cfg.Database)
 		if err != nil {
 			log.Fatal("Failed to initialize database:", err)
 		}
@@ -182,6 +264,13 @@ var listSessionsCmd = &cobra.Command{
 			log.Fatal("Failed to list sessions:", err)
 		}
 
+		if jsonOutput() {
+			if sessions == nil {
+				sessions = []storage.Session{}
+			}
+			printJSON(sessions)
+			return
+		}
 		if len(sessions) == 0 {
 			fmt.Println("No sessions found.")
 			return
@@ -208,12 +297,12 @@ var clearCmd = &cobra.Command{
 			log.Fatal("Session name is required (--session)")
 		}
 
-		cfg, err := config.LoadConfig(cfgFile)
+		cfg, err := loadConfig()
 		if err != nil {
 			log.Fatal("Failed to load config:", err)
 		}
 
-		db, err := storage.NewDatabase(cfg.Database.Path)
+		db, err := storage.Open(cfg.Database)
 		if err != nil {
 			log.Fatal("Failed to initialize database:", err)
 		}
@@ -223,6 +312,10 @@ var clearCmd = &cobra.Command{
 			log.Fatal("Failed to clear session:", err)
 		}
 
+		webhook.Configure(cfg.Webhooks)
+		webhook.SessionCleared(sessionName)
+		webhook.Default.Wait(30 * time.Second)
+
 		fmt.Printf("Session '%s' cleared successfully\n", sessionName)
 	},
 }
@@ -232,12 +325,26 @@ func init() {
 	exportCmd.Flags().StringVar(&outputFile, "output", "", "output file path")
 	exportCmd.MarkFlagRequired("session")
 	exportCmd.MarkFlagRequired("output")
+	exportCmd.Flags().BoolVar(&exportWatch, "watch", false, "keep running and re-export whenever the session changes")
+	exportCmd.Flags().DurationVar(&exportDebounce, "debounce", export.DefaultWatchDebounce, "with --watch, how long recording must be quiet before exporting")
+	exportCmd.Flags().StringVar(&exportConsumer, "consumer", "", "export only the interactions of this consumer")
+	exportCmd.Flags().BoolVar(&exportByConsumer, "by-consumer", false, "write each consumer's interactions to its own file in the --output directory")
+	exportCmd.Flags().StringVar(&exportTag, "tag", "", "export only the interactions recorded with this X-Mimic-Tag")
+	exportCmd.MarkFlagsMutuallyExclusive("consumer", "by-consumer", "watch")
+	exportCmd.MarkFlagsMutuallyExclusive("tag", "watch")
+	exportCmd.RegisterFlagCompletionFunc("session", completeSessions)
+	addOutputFlag(exportCmd, false)
 
 	importCmd.Flags().StringVar(&inputFile, "input", "", "input file path")
 	importCmd.Flags().StringVar(&sessionName, "session", "", "target session name (optional)")
 	importCmd.Flags().StringVar(&mergeStrategy, "merge-strategy", "append", "merge strategy: append or replace")
 	importCmd.MarkFlagRequired("input")
+	importCmd.RegisterFlagCompletionFunc("input", completeExportFiles)
+	importCmd.RegisterFlagCompletionFunc("merge-strategy", completeValues("append", "replace"))
+
+	addOutputFlag(listSessionsCmd, false)
 
 	clearCmd.Flags().StringVar(&sessionName, "session", "", "session name to clear")
 	clearCmd.MarkFlagRequired("session")
+	clearCmd.RegisterFlagCompletionFunc("session", completeSessions)
 }
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveHost             string
+	servePort             int
+	serveGRPCPort         int
+	serveMatchingStrategy string
+	serveSequenceMode     string
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve <export files or directories...>",
+	Short: "Serve export files in mock mode from memory",
+	Long: `Load one or more export files into an in-memory database and serve them in mock mode
+straight away. Nothing is read from or written to the config directory or a database file,
+so a CI job needs only the mimic binary and its fixtures.
+
+Each file is served as its own proxy at /proxy/<file name>/. Flags override the MIMIC_*
+environment variables that 'mimic mock' reads.`,
+	Example: `  mimic serve fixtures/checkout.json
+  mimic serve 'fixtures/*.json' --port 9000 --matching-strategy fuzzy`,
+	Args: cobra.MinimumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		files, err := resolveExportFiles(args)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		cfg, err := mockConfigFromEnv()
+		if err != nil {
+			log.Fatal("Invalid configuration:", err)
+		}
+		flags := cmd.Flags()
+		if flags.Changed("host") {
+			cfg.Server.ListenHost = serveHost
+		}
+		if flags.Changed("port") {
+			cfg.Server.ListenPort = servePort
+		}
+		if flags.Changed("grpc-port") {
+			cfg.Server.GRPCPort = serveGRPCPort
+		}
+		if flags.Changed("matching-strategy") {
+			cfg.Mock.MatchingStrategy = serveMatchingStrategy
+		}
+		if flags.Changed("sequence-mode") {
+			cfg.Mock.SequenceMode = serveSequenceMode
+		}
+
+		serveExports(cfg, files)
+	},
+}
+
+func init() {
+	serveCmd.Flags().StringVar(&serveHost, "host", "0.0.0.0", "HTTP listen address")
+	serveCmd.Flags().IntVarP(&servePort, "port", "p", 8080, "HTTP port for the proxies and web UI")
+	serveCmd.Flags().IntVar(&serveGRPCPort, "grpc-port", 9080, "gRPC port for exports with gRPC interactions")
+	serveCmd.Flags().StringVar(&serveMatchingStrategy, "matching-strategy", "exact", "exact, pattern, fuzzy, fuzzy-unordered, or fuzzy-subset")
+	serveCmd.Flags().StringVar(&serveSequenceMode, "sequence-mode", "ordered", "ordered, repeat-last, strict, or random")
+	serveCmd.RegisterFlagCompletionFunc("matching-strategy", completeValues("exact", "pattern", "fuzzy", "fuzzy-unordered", "fuzzy-subset"))
+	serveCmd.RegisterFlagCompletionFunc("sequence-mode", completeValues("ordered", "ordered-cycle", "repeat-last", "strict", "random"))
+	serveCmd.ValidArgsFunction = completeExportFiles
+	rootCmd.AddCommand(serveCmd)
+}
@@ -0,0 +1,400 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"mimic/config"
+	"mimic/protoschema"
+	"mimic/sessiondiff"
+	"mimic/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	sessionsDetailed bool
+	sessionsExitCode bool
+	sessionsForce    bool
+)
+
+var sessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "Inspect recorded sessions",
+}
+
+var sessionsDescribeCmd = &cobra.Command{
+	Use:   "describe <session>",
+	Short: "Print a session's stats",
+	Long: `Print what a session recorded: interaction counts by method, endpoint, and status,
+the protocols used, the time range covered, how many responses were streamed, the total
+body size, and the tags on its interactions.`,
+	Example: `  mimic sessions describe checkout
+  mimic sessions describe checkout --json | jq .endpoints`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db := mustOpenDatabase()
+		defer db.Close()
+
+		stats, err := db.DescribeSession(args[0])
+		if err != nil {
+			log.Fatal("Failed to describe session:", err)
+		}
+
+		if jsonOutput() {
+			printJSON(stats)
+			return
+		}
+		printSessionStats(stats)
+	},
+}
+
+var sessionsDiffCmd = &cobra.Command{
+	Use:   "diff <base> <head>",
+	Short: "Compare two sessions",
+	Long: `Compare two sessions: the endpoints each covers, the interactions only one of them
+recorded, and the responses that changed. Interactions are paired by method, endpoint, and
+the order they were recorded in. Responses are compared by status, content type, and body,
+with JSON bodies compared field by field.`,
+	Example: `  mimic sessions diff checkout-v1 checkout-v2
+  mimic sessions diff checkout-v1 checkout-v2 --detailed
+  mimic sessions diff golden latest --exit-code > /dev/null`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db := mustOpenDatabase()
+		defer db.Close()
+
+		base, head := loadSessionForDiff(db, args[0]), loadSessionForDiff(db, args[1])
+		result := sessiondiff.Compare(args[0], base, args[1], head)
+
+		if jsonOutput() {
+			printJSON(result)
+		} else {
+			printSessionDiff(result)
+		}
+		if sessionsExitCode && !result.Identical() {
+			os.Exit(1)
+		}
+	},
+}
+
+var sessionsRenameCmd = &cobra.Command{
+	Use:   "rename <session> <new name>",
+	Short: "Rename a session",
+	Long: `Rename a session, keeping its interactions and mock sequence state. Proxies and exports
+that refer to the old name need updating, so mimic asks before renaming unless --force is given.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db := mustOpenDatabase()
+		defer db.Close()
+
+		oldName, newName := args[0], args[1]
+		confirmSessionChange(cmd, fmt.Sprintf("Rename session '%s' (%d interactions) to '%s'? Configs naming '%s' will need updating.",
+			oldName, countInteractions(db, oldName), newName, oldName))
+		if err := db.RenameSession(oldName, newName); err != nil {
+			log.Fatal("Failed to rename session:", err)
+		}
+		if jsonOutput() {
+			printJSON(struct {
+				Session     string `json:"session"`
+				RenamedFrom string `json:"renamed_from"`
+			}{newName, oldName})
+			return
+		}
+		fmt.Printf("Session '%s' renamed to '%s'\n", oldName, newName)
+	},
+}
+
+var sessionsCopyCmd = &cobra.Command{
+	Use:   "copy <session> <new session>",
+	Short: "Copy a session's interactions into a new session",
+	Long: `Copy every interaction of a session, with its sequence numbers and stream chunks, into a
+new session. If the new session already exists mimic asks before replacing it, unless --force is given.`,
+	Args: cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db := mustOpenDatabase()
+		defer db.Close()
+
+		source, target := args[0], args[1]
+		if _, err := db.GetSession(source); err != nil {
+			log.Fatal("Failed to copy session:", err)
+		}
+		if _, err := db.GetSession(target); err == nil {
+			confirmSessionChange(cmd, fmt.Sprintf("Session '%s' already exists with %d interactions. Replace it?", target, countInteractions(db, target)))
+			if err := db.ClearSession(target); err != nil {
+				log.Fatal("Failed to clear session:", err)
+			}
+		}
+
+		copied, err := db.CopySession(source, target)
+		if err != nil {
+			log.Fatal("Failed to copy session:", err)
+		}
+		if jsonOutput() {
+			printJSON(struct {
+				Session      string `json:"session"`
+				CopiedFrom   string `json:"copied_from"`
+				Interactions int    `json:"interactions"`
+			}{target, source, copied})
+			return
+		}
+		fmt.Printf("Copied %d interactions from '%s' to '%s'\n", copied, source, target)
+	},
+}
+
+var sessionsMergeCmd = &cobra.Command{
+	Use:   "merge <target> <source>...",
+	Short: "Append other sessions' interactions to a session",
+	Long: `Append copies of the sources' interactions to the target session, creating it if needed.
+Sequence numbers continue from the target's, so a mock serves the target's recordings of an
+endpoint first and then each source's in the order given. The sources are left as they are;
+remove them with 'mimic clear' once the merge looks right. mimic asks before merging unless
+--force is given.`,
+	Example: `  mimic sessions merge checkout-fixtures checkout-happy-path checkout-errors`,
+	Args:    cobra.MinimumNArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		_, db := mustOpenDatabase()
+		defer db.Close()
+
+		target, sources := args[0], args[1:]
+		total := 0
+		for _, source := range sources {
+			total += countInteractions(db, source)
+		}
+		confirmSessionChange(cmd, fmt.Sprintf("Append %d interactions from %s to session '%s'?", total, strings.Join(sources, ", "), target))
+
+		merged, err := db.MergeSessions(target, sources)
+		if err != nil {
+			log.Fatal("Failed to merge sessions:", err)
+		}
+		if jsonOutput() {
+			printJSON(struct {
+				Session      string   `json:"session"`
+				MergedFrom   []string `json:"merged_from"`
+				Interactions int      `json:"interactions"`
+			}{target, sources, merged})
+			return
+		}
+		fmt.Printf("Merged %d interactions into '%s'\n", merged, target)
+	},
+}
+
+func init() {
+	addOutputFlag(sessionsCmd, true)
+	addLegacyJSONFlag(sessionsCmd, true)
+	sessionsDiffCmd.Flags().BoolVar(&sessionsDetailed, "detailed", false, "list every added, removed, and changed interaction")
+	sessionsDiffCmd.Flags().BoolVar(&sessionsExitCode, "exit-code", false, "exit with status 1 if the sessions differ")
+	for _, cmd := range []*cobra.Command{sessionsRenameCmd, sessionsCopyCmd, sessionsMergeCmd} {
+		cmd.Flags().BoolVarP(&sessionsForce, "force", "f", false, "do not ask for confirmation")
+	}
+	sessionsDescribeCmd.ValidArgsFunction = completeSessionArgs(1)
+	sessionsDiffCmd.ValidArgsFunction = completeSessionArgs(2)
+	sessionsRenameCmd.ValidArgsFunction = completeSessionArgs(1)
+	sessionsCopyCmd.ValidArgsFunction = completeSessionArgs(1)
+	sessionsMergeCmd.ValidArgsFunction = completeSessionArgs(0)
+	sessionsCmd.AddCommand(sessionsDescribeCmd)
+	sessionsCmd.AddCommand(sessionsDiffCmd)
+	sessionsCmd.AddCommand(sessionsRenameCmd)
+	sessionsCmd.AddCommand(sessionsCopyCmd)
+	sessionsCmd.AddCommand(sessionsMergeCmd)
+
+	rootCmd.AddCommand(sessionsCmd)
+}
+
+// mustOpenDatabase loads the config and opens its database, exiting on failure
+func mustOpenDatabase() (*config.Config, *storage.Database) {
+	cfg, err := loadConfig()
+	if err != nil {
+		log.Fatal("Failed to load config:", err)
+	}
+
+	db, err := storage.Open(cfg.Database)
+	if err != nil {
+		log.Fatal("Failed to initialize database:", err)
+	}
+	return cfg, db
+}
+
+// loadDescriptors loads the configured protobuf descriptors so gRPC bodies can be shown and
+// exported as JSON; sources that fail to load are skipped with a warning
+func loadDescriptors(cfg *config.Config) {
+	for _, err := range protoschema.Load(context.Background(), cfg.GRPC.DescriptorSets, cfg.GRPC.BufModules) {
+		log.Printf("Warning: %v", err)
+	}
+}
+
+func printJSON(value interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		log.Fatal("Failed to encode JSON:", err)
+	}
+}
+
+func printSessionStats(stats *storage.SessionStats) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Session:\t%s (id %d)\n", stats.Session.SessionName, stats.Session.ID)
+	if stats.Session.Description != "" {
+		fmt.Fprintf(w, "Description:\t%s\n", stats.Session.Description)
+	}
+	fmt.Fprintf(w, "Created:\t%s\n", stats.Session.CreatedAt.Format("2006-01-02 15:04:05"))
+	if stats.FirstAt != nil {
+		fmt.Fprintf(w, "Recorded:\t%s to %s (%s)\n", stats.FirstAt.Format("2006-01-02 15:04:05"),
+			stats.LastAt.Format("2006-01-02 15:04:05"), stats.LastAt.Sub(*stats.FirstAt).Round(time.Second))
+	}
+	fmt.Fprintf(w, "Interactions:\t%d (%d streaming)\n", stats.Interactions, stats.Streaming)
+	fmt.Fprintf(w, "Body size:\t%s requests, %s responses\n", formatBytes(stats.RequestBytes), formatBytes(stats.ResponseBytes))
+	fmt.Fprintf(w, "Protocols:\t%s\n", formatCounts(stats.Protocols))
+	fmt.Fprintf(w, "Methods:\t%s\n", formatCounts(stats.Methods))
+
+	statuses := make(map[string]int, len(stats.Statuses))
+	for code, count := range stats.Statuses {
+		statuses[strconv.Itoa(code)] = count
+	}
+	fmt.Fprintf(w, "Statuses:\t%s\n", formatCounts(statuses))
+	fmt.Fprintf(w, "Tags:\t%s\n", formatCounts(stats.Tags))
+	fmt.Fprintf(w, "Consumers:\t%s\n", formatCounts(stats.Consumers))
+	w.Flush()
+
+	if len(stats.Endpoints) == 0 {
+		return
+	}
+	fmt.Println("\nEndpoints:")
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "  COUNT\tMETHOD\tENDPOINT")
+	for _, endpoint := range stats.Endpoints {
+		fmt.Fprintf(w, "  %d\t%s\t%s\n", endpoint.Count, endpoint.Method, endpoint.Endpoint)
+	}
+	w.Flush()
+}
+
+// confirmSessionChange asks before changing sessions, exiting unless the answer is yes or --force was given
+func confirmSessionChange(cmd *cobra.Command, question string) {
+	if sessionsForce {
+		return
+	}
+	p := &prompter{in: bufio.NewReader(cmd.InOrStdin()), out: cmd.OutOrStdout()}
+	if !p.confirm(question) {
+		log.Fatal("Aborted; pass --force to skip this question")
+	}
+}
+
+// countInteractions returns how many interactions a session holds, exiting if it does not exist
+func countInteractions(db *storage.Database, sessionName string) int {
+	session, err := db.GetSession(sessionName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	interactions, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		log.Fatal("Failed to get interactions:", err)
+	}
+	return len(interactions)
+}
+
+// loadSessionForDiff returns a session's interactions with streamed responses joined into their bodies
+func loadSessionForDiff(db *storage.Database, sessionName string) []storage.Interaction {
+	session, err := db.GetSession(sessionName)
+	if err != nil {
+		log.Fatal("Failed to get session:", err)
+	}
+	interactions, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		log.Fatal("Failed to get interactions:", err)
+	}
+
+	for i := range interactions {
+		if !interactions[i].IsStreaming {
+			continue
+		}
+		chunks, err := db.GetStreamChunks(interactions[i].ID)
+		if err != nil {
+			log.Fatal("Failed to get stream chunks:", err)
+		}
+		for _, chunk := range chunks {
+			interactions[i].ResponseBody = append(interactions[i].ResponseBody, chunk.Data...)
+		}
+	}
+	return interactions
+}
+
+func printSessionDiff(result *sessiondiff.Result) {
+	if result.Identical() {
+		fmt.Printf("Sessions %s and %s recorded the same %d responses\n", result.Base, result.Head, result.Unchanged)
+		return
+	}
+
+	fmt.Printf("Comparing %s (base) with %s (head)\n", result.Base, result.Head)
+	fmt.Printf("%d added, %d removed, %d changed, %d unchanged\n\n", len(result.Added), len(result.Removed), len(result.Changed), result.Unchanged)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "STATE\tMETHOD\tENDPOINT\tBASE\tHEAD")
+	for _, endpoint := range result.Endpoints {
+		if endpoint.State == sessiondiff.StateUnchanged && !sessionsDetailed {
+			continue
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\n", endpoint.State, endpoint.Method, endpoint.Endpoint, endpoint.BaseCount, endpoint.HeadCount)
+	}
+	w.Flush()
+
+	if !sessionsDetailed {
+		return
+	}
+	for _, entry := range result.Added {
+		fmt.Printf("\n+ %s %s #%d (head id %d): %d\n", entry.Method, entry.Endpoint, entry.Occurrence, entry.ID, entry.Status)
+	}
+	for _, entry := range result.Removed {
+		fmt.Printf("\n- %s %s #%d (base id %d): %d\n", entry.Method, entry.Endpoint, entry.Occurrence, entry.ID, entry.Status)
+	}
+	for _, change := range result.Changed {
+		fmt.Printf("\n~ %s %s #%d (base id %d, head id %d)\n", change.Method, change.Endpoint, change.Occurrence, change.BaseID, change.HeadID)
+		for _, difference := range change.Differences {
+			fmt.Printf("    %s\n", difference)
+		}
+	}
+}
+
+// formatCounts renders counts as "a 3, b 1", largest first
+func formatCounts(counts map[string]int) string {
+	if len(counts) == 0 {
+		return "-"
+	}
+	keys := make([]string, 0, len(counts))
+	for key := range counts {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if counts[keys[i]] != counts[keys[j]] {
+			return counts[keys[i]] > counts[keys[j]]
+		}
+		return keys[i] < keys[j]
+	})
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = fmt.Sprintf("%s %d", key, counts[key])
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"mimic/config"
+	"mimic/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var validateConfigCmd = &cobra.Command{
+	Use:   "validate-config [config file]",
+	Short: "Check a config file without starting mimic",
+	Long: `Load a config file and report every problem in it with the key that caused it:
+invalid values, regex patterns that do not compile, listeners sharing a port, unreadable
+proto paths, and mock proxies whose session is not in the database.
+Unlike doctor it makes no network connections, so it suits CI and pre-commit hooks.
+Unknown and deprecated keys are reported as warnings.
+Exits non-zero if any problem other than a warning is found.`,
+	Example: `  mimic validate-config config.yaml
+  mimic validate-config --output json config.yaml`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		path := cfgFile
+		if len(args) == 1 {
+			path = args[0]
+		}
+		runValidateConfig(path)
+	},
+}
+
+func init() {
+	addOutputFlag(validateConfigCmd, false)
+	addLegacyJSONFlag(validateConfigCmd, false)
+	validateConfigCmd.ValidArgsFunction = completeFiles("yaml", "yml")
+
+	rootCmd.AddCommand(validateConfigCmd)
+}
+
+func runValidateConfig(path string) {
+	var problems []config.Problem
+	cfg, err := config.LoadConfig(path)
+	if err != nil {
+		problems = append(problems, config.Problem{Message: err.Error()})
+	} else {
+		if modeFlag != "" {
+			cfg.Mode = modeFlag
+		}
+		problems = append(problems, cfg.Warnings...)
+		problems = append(problems, cfg.Lint()...)
+		problems = append(problems, checkMockSessions(cfg)...)
+	}
+
+	if jsonOutput() {
+		if problems == nil {
+			problems = []config.Problem{}
+		}
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(problems)
+	} else {
+		for _, problem := range problems {
+			symbol := "✗"
+			if problem.Warning {
+				symbol = "!"
+			}
+			if problem.Key == "" {
+				fmt.Printf("%s %s\n", symbol, problem.Message)
+			} else {
+				fmt.Printf("%s %s: %s\n", symbol, problem.Key, problem.Message)
+			}
+		}
+		if errorCount(problems) == 0 {
+			fmt.Println("✓ config is valid")
+		}
+	}
+
+	if errorCount(problems) > 0 {
+		os.Exit(1)
+	}
+}
+
+// errorCount counts the problems that are not warnings
+func errorCount(problems []config.Problem) int {
+	count := 0
+	for _, problem := range problems {
+		if !problem.Warning {
+			count++
+		}
+	}
+	return count
+}
+
+// checkMockSessions reports proxies whose session has not been recorded, since mock mode would
+// answer every request to them with a miss
+func checkMockSessions(cfg *config.Config) []config.Problem {
+	names := make([]string, 0, len(cfg.Proxies))
+	for name, proxyConfig := range cfg.Proxies {
+		if proxyConfig.EffectiveMode(cfg.Mode) == "mock" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	if cfg.Database.Driver == "" || cfg.Database.Driver == storage.DriverSQLite {
+		path, err := config.ExpandHome(cfg.Database.Path)
+		if err != nil {
+			return []config.Problem{{Key: "database.path", Message: err.Error()}}
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return []config.Problem{{Key: "database.path", Message: fmt.Sprintf("%s does not exist; mock mode needs recorded sessions", path)}}
+		}
+	}
+
+	db, err := storage.Open(cfg.Database)
+	if err != nil {
+		return []config.Problem{{Key: "database", Message: err.Error()}}
+	}
+	defer db.Close()
+
+	var problems []config.Problem
+	for _, name := range names {
+		sessionName := cfg.Proxies[name].SessionName
+		if sessionName == "" {
+			continue // Already reported by Validate
+		}
+		if _, err := db.GetSession(sessionName); err != nil {
+			problems = append(problems, config.Problem{Key: "proxies." + name + ".session_name", Message: err.Error()})
+		}
+	}
+	return problems
+}
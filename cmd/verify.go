@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"mimic/config"
+	"mimic/export"
+	"mimic/scenario"
+	"mimic/storage"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifySession    string
+	verifyExportFile string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify <scenario file>",
+	Short: "Check that a recorded session made the expected calls",
+	Long: `Check recorded sessions against the call sequences declared in a scenario file, so CI
+fails when the client under test skips a required call or calls something it should not.
+
+Each step is "[count] METHOD /path". The count is N, >=N (or ≥N), <=N (or ≤N), or N-M,
+and defaults to at least once. Method * matches any method, and path segments * and
+{name} match any one segment, with a final ** matching the rest. Steps are checked in
+order unless the scenario sets ordered: false. Calls that match no step are ignored.
+
+  session: checkout
+  scenarios:
+    - name: sign in and browse
+      steps:
+        - POST /login
+        - GET /profile
+        - "≥1 GET /items/{id}"
+    - name: no admin calls
+      ordered: false
+      steps:
+        - 0 * /admin/**
+
+Exits with status 1 when any scenario fails.`,
+	Example: `  mimic verify scenarios.yaml
+  mimic verify scenarios.yaml --session nightly-run
+  mimic verify scenarios.yaml --export fixtures/checkout.json`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runVerify(args[0])
+	},
+}
+
+func init() {
+	verifyCmd.Flags().StringVar(&verifySession, "session", "", "session to check every scenario against (default: the session named in the file)")
+	verifyCmd.Flags().StringVar(&verifyExportFile, "export", "", "check an export file instead of the database")
+	addOutputFlag(verifyCmd, false)
+	verifyCmd.RegisterFlagCompletionFunc("session", completeSessions)
+	verifyCmd.RegisterFlagCompletionFunc("export", completeExportFiles)
+	verifyCmd.ValidArgsFunction = completeFiles("yaml", "yml")
+
+	rootCmd.AddCommand(verifyCmd)
+}
+
+func runVerify(path string) {
+	file, err := scenario.Load(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var db *storage.Database
+	sessionOverride := verifySession
+	if verifyExportFile != "" {
+		db, err = storage.NewMemoryDatabase()
+		if err != nil {
+			log.Fatal("Failed to initialize database:", err)
+		}
+		sessionOverride = proxyNameForExport(verifyExportFile)
+		if err := export.NewExportManager(config.DefaultConfig(), db).ImportSession(verifyExportFile, sessionOverride, "append"); err != nil {
+			log.Fatalf("Failed to load %s: %v", verifyExportFile, err)
+		}
+	} else {
+		_, db = mustOpenDatabase()
+	}
+	defer db.Close()
+
+	results := make([]scenario.Result, 0, len(file.Scenarios))
+	for _, s := range file.Scenarios {
+		sessionName := firstNonEmpty(sessionOverride, s.Session, file.Session)
+		if sessionName == "" {
+			log.Fatalf("No session for scenario '%s': set session in the file or pass --session", s.Name)
+		}
+		interactions, err := sessionInteractions(db, sessionName)
+		if err != nil {
+			results = append(results, scenario.Result{Scenario: s.Name, Session: sessionName, Failures: []string{err.Error()}})
+			continue
+		}
+		results = append(results, scenario.Verify(s, sessionName, interactions))
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed {
+			failed++
+		}
+	}
+	if jsonOutput() {
+		printJSON(results)
+	} else {
+		for _, result := range results {
+			if result.Passed {
+				fmt.Printf("✓ %s (session %s, %d calls)\n", result.Scenario, result.Session, result.Calls)
+				continue
+			}
+			fmt.Printf("✗ %s (session %s)\n", result.Scenario, result.Session)
+			for _, failure := range result.Failures {
+				fmt.Printf("    %s\n", failure)
+			}
+		}
+		fmt.Printf("\n%d scenarios, %d passed, %d failed\n", len(results), len(results)-failed, failed)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+func sessionInteractions(db *storage.Database, sessionName string) ([]storage.Interaction, error) {
+	session, err := db.GetSession(sessionName)
+	if err != nil {
+		return nil, err
+	}
+	return db.GetInteractionsBySession(session.ID)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// EnvPrefix namespaces the environment variables that override config keys, e.g. MIMIC_SERVER_LISTEN_PORT
+const EnvPrefix = "MIMIC"
+
+// envReference matches $${ (an escaped literal), ${NAME}, and ${NAME:-default}
+var envReference = regexp.MustCompile(`\$\$\{|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// expandEnv replaces ${NAME} references in config text with the environment's values.
+// ${NAME:-default} falls back when NAME is unset or empty; $${ writes a literal ${.
+func expandEnv(data []byte) ([]byte, error) {
+	var missing []string
+	expanded := envReference.ReplaceAllFunc(data, func(match []byte) []byte {
+		if string(match) == "$${" {
+			return []byte("${")
+		}
+		groups := envReference.FindSubmatch(match)
+		name, hasDefault := string(groups[1]), len(groups[2]) > 0
+		if value := os.Getenv(name); value != "" {
+			return []byte(value)
+		}
+		if !hasDefault {
+			missing = append(missing, name)
+		}
+		return groups[3]
+	})
+
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("config references unset environment variables: %s (use ${NAME:-default} for optional ones)", strings.Join(missing, ", "))
+	}
+	return expanded, nil
+}
+
+// bindEnvOverrides lets MIMIC_<KEY> override every config key, with dots and dashes in the key
+// written as underscores. Proxy keys are bound for each proxy defined in the file or by default,
+// e.g. MIMIC_PROXIES_PAYMENTS_TARGET_HOST.
+func bindEnvOverrides(v *viper.Viper, proxyNames []string) {
+	v.SetEnvPrefix(EnvPrefix)
+	v.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+
+	bindStructKeys(v, "", reflect.TypeOf(Config{}))
+	for _, name := range proxyNames {
+		bindStructKeys(v, "proxies."+name+".", reflect.TypeOf(ProxyConfig{}))
+	}
+}
+
+// bindStructKeys binds every mapstructure key of t, descending into nested structs
+func bindStructKeys(v *viper.Viper, prefix string, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("mapstructure")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		key := prefix + tag
+
+		switch field.Type.Kind() {
+		case reflect.Struct:
+			bindStructKeys(v, key+".", field.Type)
+		case reflect.Map, reflect.Slice:
+			// Entries of struct maps and lists can't be named up front; proxies are bound by name
+			if field.Type.Elem().Kind() != reflect.Struct {
+				v.BindEnv(key)
+			}
+		default:
+			v.BindEnv(key)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("MIMIC_TEST_TOKEN", "s3cret")
+	t.Setenv("MIMIC_TEST_EMPTY", "")
+
+	input := `token: "${MIMIC_TEST_TOKEN}"
+fallback: "${MIMIC_TEST_EMPTY:-local}"
+optional: "${MIMIC_TEST_UNSET:-}"
+literal: "$${MIMIC_TEST_TOKEN}"
+regex: "^/v1/.*$"`
+	expected := `token: "s3cret"
+fallback: "local"
+optional: ""
+literal: "${MIMIC_TEST_TOKEN}"
+regex: "^/v1/.*$"`
+
+	output, err := expandEnv([]byte(input))
+	if err != nil {
+		t.Fatalf("expandEnv failed: %v", err)
+	}
+	if string(output) != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, output)
+	}
+
+	if _, err := expandEnv([]byte(`url: "${MIMIC_TEST_UNSET}"`)); err == nil || !strings.Contains(err.Error(), "MIMIC_TEST_UNSET") {
+		t.Errorf("Expected an error naming the unset variable, got %v", err)
+	}
+}
+
+func TestLoadConfigEnvironment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `mode: record
+server:
+  listen_port: 8080
+proxies:
+  payments-api:
+    protocol: https
+    target_host: api.example.com
+    target_port: 443
+    session_name: default
+webhooks:
+  - url: https://hooks.example.com/mimic
+    secret: ${MIMIC_TEST_WEBHOOK_SECRET}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	t.Setenv("MIMIC_TEST_WEBHOOK_SECRET", "from-env")
+	t.Setenv("MIMIC_SERVER_LISTEN_PORT", "18080")
+	t.Setenv("MIMIC_PROXIES_PAYMENTS_API_TARGET_HOST", "staging.example.com")
+	t.Setenv("MIMIC_ACCESS_LOG_FORMAT", "json")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+
+	if cfg.Server.ListenPort != 18080 {
+		t.Errorf("Expected MIMIC_SERVER_LISTEN_PORT to override the file, got %d", cfg.Server.ListenPort)
+	}
+	if host := cfg.Proxies["payments-api"].TargetHost; host != "staging.example.com" {
+		t.Errorf("Expected the proxy's target host from the environment, got %s", host)
+	}
+	if cfg.AccessLog.Format != "json" {
+		t.Errorf("Expected a key absent from the file to be set from the environment, got %q", cfg.AccessLog.Format)
+	}
+	if len(cfg.Webhooks) != 1 || cfg.Webhooks[0].Secret != "from-env" {
+		t.Errorf("Expected the webhook secret to be expanded, got %+v", cfg.Webhooks)
+	}
+}
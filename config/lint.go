@@ -0,0 +1,165 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+)
+
+// FieldError is a validation error tied to the config key that caused it
+type FieldError struct {
+	Key string // e.g. proxies.payments.target_host
+	Err error
+}
+
+func (e *FieldError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+func fieldError(key, format string, args ...interface{}) error {
+	return &FieldError{Key: key, Err: fmt.Errorf(format, args...)}
+}
+
+// proxyKey returns the key path of a proxy setting, or of the proxy itself when field is empty
+func proxyKey(name, field string) string {
+	if field == "" {
+		return "proxies." + name
+	}
+	return "proxies." + name + "." + field
+}
+
+// Problem is one issue found by Lint, or a warning from loading the config
+type Problem struct {
+	Key     string `json:"key"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"` // The config still works, e.g. an unknown or deprecated key
+}
+
+// Lint runs Validate and then the checks Validate leaves to runtime: patterns that fail to compile
+// (which the routers and redactor otherwise skip silently), listeners sharing a port, and proto
+// paths, descriptor sets, and OpenAPI specs that cannot be read. Unlike Validate it reports every
+// problem it finds rather than the first.
+func (c *Config) Lint() []Problem {
+	var problems []Problem
+	add := func(key, format string, args ...interface{}) {
+		problems = append(problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if err := c.Validate(); err != nil {
+		var fieldErr *FieldError
+		if errors.As(err, &fieldErr) {
+			add(fieldErr.Key, "%s", fieldErr.Err)
+		} else {
+			add("", "%s", err)
+		}
+	}
+
+	names := make([]string, 0, len(c.Proxies))
+	for name := range c.Proxies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	hasGRPC := false
+	for _, name := range names {
+		proxyConfig := c.Proxies[name]
+		if proxyConfig.Protocol == "grpc" {
+			hasGRPC = true
+		}
+		patterns := map[string]string{
+			"service_pattern": proxyConfig.ServicePattern,
+			"method_pattern":  proxyConfig.MethodPattern,
+		}
+		for _, field := range []string{"service_pattern", "method_pattern"} {
+			if pattern := patterns[field]; pattern != "" {
+				if _, err := regexp.Compile(pattern); err != nil {
+					add(proxyKey(name, field), "invalid regex %q: %v", pattern, err)
+				}
+			}
+		}
+		if proxyConfig.OpenAPISpec != "" {
+			if err := checkReadable(proxyConfig.OpenAPISpec); err != nil {
+				add(proxyKey(name, "openapi_spec"), "%v", err)
+			}
+		}
+	}
+
+	for i, pattern := range c.Recording.RedactPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			add("recording.redact_patterns["+strconv.Itoa(i)+"]", "invalid regex %q: %v", pattern, err)
+		}
+	}
+
+	type listener struct {
+		key  string
+		port int
+	}
+	ports := []listener{{"server.listen_port", c.Server.ListenPort}}
+	if hasGRPC {
+		ports = append(ports, listener{"server.grpc_port", c.Server.GRPCPort})
+	}
+	if c.Server.AdminGRPCPort > 0 {
+		ports = append(ports, listener{"server.admin_grpc_port", c.Server.AdminGRPCPort})
+	}
+	claimed := make(map[int]string)
+	for _, p := range ports {
+		if p.port <= 0 {
+			continue
+		}
+		if other, ok := claimed[p.port]; ok {
+			add(p.key, "port %d is also used by %s", p.port, other)
+			continue
+		}
+		claimed[p.port] = p.key
+	}
+
+	for i, path := range c.GRPC.ProtoPaths {
+		if err := checkReadable(path); err != nil {
+			add("grpc.proto_paths["+strconv.Itoa(i)+"]", "%v", err)
+		}
+	}
+	for i, path := range c.GRPC.DescriptorSets {
+		if err := checkReadable(path); err != nil {
+			add("grpc.descriptor_sets["+strconv.Itoa(i)+"]", "%v", err)
+		}
+	}
+
+	if c.Limits.SpillDir != "" {
+		if info, err := os.Stat(c.Limits.SpillDir); err != nil {
+			add("limits.spill_dir", "%v", err)
+		} else if !info.IsDir() {
+			add("limits.spill_dir", "%s is not a directory", c.Limits.SpillDir)
+		}
+	}
+
+	return problems
+}
+
+// checkReadable opens path, listing it if it is a directory
+func checkReadable(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		_, err = file.Readdirnames(1)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("cannot list %s: %w", path, err)
+		}
+	}
+	return nil
+}
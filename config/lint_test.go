@@ -0,0 +1,62 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateReportsKey(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Mode = "mock"
+	proxyConfig := cfg.Proxies["default"]
+	proxyConfig.SessionName = ""
+	cfg.Proxies["default"] = proxyConfig
+
+	var fieldErr *FieldError
+	if err := cfg.Validate(); !errors.As(err, &fieldErr) {
+		t.Fatalf("Expected a FieldError, got %v", err)
+	}
+	if fieldErr.Key != "proxies.default.session_name" {
+		t.Errorf("Expected key proxies.default.session_name, got %s", fieldErr.Key)
+	}
+}
+
+func TestLint(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Mode = "mock"
+	if problems := cfg.Lint(); len(problems) != 0 {
+		t.Fatalf("Expected the default config to be clean, got %v", problems)
+	}
+
+	grpcProxy := ProxyConfig{
+		Protocol:       "grpc",
+		TargetHost:     "localhost",
+		TargetPort:     9090,
+		SessionName:    "default",
+		ServicePattern: "users\\.(",
+	}
+	cfg.Proxies["users"] = grpcProxy
+	cfg.Server.GRPCPort = cfg.Server.ListenPort
+	cfg.Recording.RedactPatterns = []string{"token=.*", "[unclosed"}
+	cfg.GRPC.ProtoPaths = []string{filepath.Join(t.TempDir(), "missing")}
+
+	problems := cfg.Lint()
+	keys := make(map[string]bool)
+	for _, problem := range problems {
+		keys[problem.Key] = true
+	}
+	for _, key := range []string{
+		"proxies.users.service_pattern",
+		"recording.redact_patterns[1]",
+		"server.grpc_port",
+		"grpc.proto_paths[0]",
+	} {
+		if !keys[key] {
+			t.Errorf("Expected a problem at %s, got %v", key, problems)
+		}
+	}
+	if len(problems) != 4 {
+		t.Errorf("Expected 4 problems, got %v", problems)
+	}
+}
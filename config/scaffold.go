@@ -0,0 +1,170 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// ScaffoldProxy is one target entered during `mimic init`
+type ScaffoldProxy struct {
+	Name           string
+	TargetHost     string
+	TargetPort     int
+	Protocol       string // http, https, or grpc
+	ServicePattern string // gRPC only; empty makes the proxy the default gRPC route
+}
+
+// Scaffold describes the starter config written by `mimic init`
+type Scaffold struct {
+	Mode        string
+	ListenPort  int
+	GRPCPort    int
+	SessionName string
+	Proxies     []ScaffoldProxy
+}
+
+// ConfigVersion is the config_version the scaffold is written with
+func (s Scaffold) ConfigVersion() int {
+	return CurrentConfigVersion
+}
+
+// HasGRPC reports whether any proxy speaks gRPC
+func (s Scaffold) HasGRPC() bool {
+	for _, proxy := range s.Proxies {
+		if proxy.Protocol == "grpc" {
+			return true
+		}
+	}
+	return false
+}
+
+var scaffoldFuncs = template.FuncMap{"quote": strconv.Quote}
+
+var scaffoldTemplate = template.Must(template.New("config").Funcs(scaffoldFuncs).Parse(`# mimic configuration, generated by "mimic init".
+# Check it with "mimic doctor", then start with "mimic --config <this file>".
+# Every setting is described in config-example.yaml and the README.
+config_version: {{.ConfigVersion}}
+
+# record: forward to the targets and save every exchange
+# mock:   answer from saved exchanges without contacting the targets
+# replay: send saved requests to a target and compare the responses
+mode: {{.Mode}}
+
+server:
+  listen_host: "0.0.0.0"
+  listen_port: {{.ListenPort}} # HTTP proxies at http://localhost:{{.ListenPort}}/proxy/<name>/, web UI at /
+{{- if .HasGRPC}}
+  grpc_port: {{.GRPCPort}} # Point gRPC clients here; calls are routed by service name
+{{- end}}
+
+proxies:
+{{- range .Proxies}}
+  {{.Name}}:
+    protocol: {{quote .Protocol}}
+    target_host: {{quote .TargetHost}}
+    target_port: {{.TargetPort}}
+    session_name: {{quote $.SessionName}}
+{{- if eq .Protocol "grpc"}}
+{{- if .ServicePattern}}
+    service_pattern: {{quote .ServicePattern}}
+{{- else}}
+    is_default: true # Receives gRPC calls no other route matches
+{{- end}}
+{{- end}}
+{{- end}}
+
+database:
+  path: "~/.mimic/recordings.db"
+
+recording:
+  session_name: {{quote .SessionName}}
+  capture_headers: true
+  capture_body: true
+  # Text matching these patterns is masked before it is saved
+  redact_patterns:
+    - "Authorization: Bearer .*"
+    - "X-Api-Key: .*"
+
+mock:
+  # exact, pattern, fuzzy, fuzzy-unordered, or fuzzy-subset
+  matching_strategy: "exact"
+  # ordered serves repeated requests in recorded order, starting over at the end;
+  # repeat-last keeps the last; strict answers not found; random picks any
+  sequence_mode: "ordered"
+
+# auth:
+#   admin_tokens: ["change-me-admin"]   # Protects the web UI and admin API
+
+# access_log:
+#   output: "stdout"   # Or a file path
+#   format: "common"   # Or json
+`))
+
+var composeTemplate = template.Must(template.New("compose").Funcs(scaffoldFuncs).Parse(`# docker-compose snippet generated by "mimic init"; merge it into your compose file.
+# Build the image from the mimic repository first: docker build -t mimic .
+services:
+  mimic:
+    image: mimic
+    command: ["--config", "/etc/mimic/config.yaml"]
+    ports:
+      - "{{.Scaffold.ListenPort}}:{{.Scaffold.ListenPort}}"
+{{- if .Scaffold.HasGRPC}}
+      - "{{.Scaffold.GRPCPort}}:{{.Scaffold.GRPCPort}}"
+{{- end}}
+    volumes:
+      - {{quote .ConfigMount}}
+      - "mimic-data:/root/.mimic" # Recordings survive container restarts
+
+volumes:
+  mimic-data:
+`))
+
+// WriteScaffold renders s as a commented config file
+func WriteScaffold(w io.Writer, s Scaffold) error {
+	if err := s.validate(); err != nil {
+		return err
+	}
+	if err := scaffoldTemplate.Execute(w, s); err != nil {
+		return fmt.Errorf("failed to render config: %w", err)
+	}
+	return nil
+}
+
+// WriteComposeSnippet renders a docker-compose service running mimic with the config at configPath
+func WriteComposeSnippet(w io.Writer, s Scaffold, configPath string) error {
+	if !strings.HasPrefix(configPath, "/") && !strings.HasPrefix(configPath, ".") {
+		configPath = "./" + configPath
+	}
+	data := struct {
+		Scaffold    Scaffold
+		ConfigMount string
+	}{s, configPath + ":/etc/mimic/config.yaml:ro"}
+
+	if err := composeTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to render docker-compose snippet: %w", err)
+	}
+	return nil
+}
+
+// validate catches answers that would render an unusable file
+func (s Scaffold) validate() error {
+	if len(s.Proxies) == 0 {
+		return fmt.Errorf("at least one proxy must be configured")
+	}
+	defaults := 0
+	for _, proxy := range s.Proxies {
+		if proxy.Name == "" || strings.ContainsAny(proxy.Name, " :/#\"'") {
+			return fmt.Errorf("invalid proxy name: %q", proxy.Name)
+		}
+		if proxy.Protocol == "grpc" && proxy.ServicePattern == "" {
+			defaults++
+		}
+	}
+	if defaults > 1 {
+		return fmt.Errorf("only one gRPC proxy can be the default route; give the others a service pattern")
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteScaffoldLoadsAndValidates(t *testing.T) {
+	scaffold := Scaffold{
+		Mode:        "record",
+		ListenPort:  8080,
+		GRPCPort:    9080,
+		SessionName: "first-run",
+		Proxies: []ScaffoldProxy{
+			{Name: "payments", TargetHost: "api.stripe.com", TargetPort: 443, Protocol: "https"},
+			{Name: "users", TargetHost: "users.internal", TargetPort: 50051, Protocol: "grpc"},
+			{Name: "billing", TargetHost: "billing.internal", TargetPort: 50052, Protocol: "grpc", ServicePattern: `billing\..*`},
+		},
+	}
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create config file: %v", err)
+	}
+	if err := WriteScaffold(file, scaffold); err != nil {
+		t.Fatalf("WriteScaffold failed: %v", err)
+	}
+	file.Close()
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Generated config does not load: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Generated config is invalid: %v", err)
+	}
+	if cfg.ConfigVersion != CurrentConfigVersion || len(cfg.Warnings) != 0 {
+		t.Errorf("Expected config_version %d without warnings, got %d and %v", CurrentConfigVersion, cfg.ConfigVersion, cfg.Warnings)
+	}
+
+	if cfg.Server.GRPCPort != 9080 || cfg.Recording.SessionName != "first-run" {
+		t.Errorf("Expected grpc_port 9080 and session first-run, got %d and %s", cfg.Server.GRPCPort, cfg.Recording.SessionName)
+	}
+	if !cfg.Proxies["users"].IsDefault || cfg.Proxies["billing"].ServicePattern != `billing\..*` {
+		t.Errorf("Expected users as the default gRPC route and billing by pattern, got %+v", cfg.Proxies)
+	}
+	if proxy := cfg.Proxies["payments"]; proxy.TargetHost != "api.stripe.com" || proxy.TargetPort != 443 || proxy.SessionName != "first-run" {
+		t.Errorf("Unexpected payments proxy: %+v", proxy)
+	}
+}
+
+func TestWriteScaffoldRejectsTwoDefaultGRPCRoutes(t *testing.T) {
+	scaffold := Scaffold{Mode: "record", ListenPort: 8080, Proxies: []ScaffoldProxy{
+		{Name: "a", TargetHost: "a", TargetPort: 1, Protocol: "grpc"},
+		{Name: "b", TargetHost: "b", TargetPort: 2, Protocol: "grpc"},
+	}}
+	if err := WriteScaffold(&strings.Builder{}, scaffold); err == nil {
+		t.Error("Expected two gRPC proxies without service patterns to be rejected")
+	}
+}
+
+func TestWriteComposeSnippet(t *testing.T) {
+	var out strings.Builder
+	scaffold := Scaffold{ListenPort: 8080, GRPCPort: 9080, Proxies: []ScaffoldProxy{{Name: "users", Protocol: "grpc"}}}
+	if err := WriteComposeSnippet(&out, scaffold, "config.yaml"); err != nil {
+		t.Fatalf("WriteComposeSnippet failed: %v", err)
+	}
+	for _, want := range []string{`"8080:8080"`, `"9080:9080"`, `"./config.yaml:/etc/mimic/config.yaml:ro"`} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("Expected the snippet to contain %s:\n%s", want, out.String())
+		}
+	}
+}
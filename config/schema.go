@@ -0,0 +1,373 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentConfigVersion is the config_version written by this release. Files without a
+// config_version are version 1, which may still use the keys in deprecatedKeys.
+const CurrentConfigVersion = 2
+
+// keyRename moves a deprecated key to its replacement
+type keyRename struct {
+	Old string
+	New string
+}
+
+// deprecatedKeys are rewritten to their replacements when a config is loaded, with a warning, and
+// in the file itself by `mimic migrate-config`. The single proxy section of version 1 configs
+// became the proxy named "default".
+var deprecatedKeys = []keyRename{
+	{"server.port", "server.listen_port"},
+	{"proxy.mode", "mode"},
+	{"proxy.target_host", "proxies.default.target_host"},
+	{"proxy.target_port", "proxies.default.target_port"},
+	{"proxy.protocol", "proxies.default.protocol"},
+	{"proxy.session_name", "proxies.default.session_name"},
+	{"proxy.enable_streaming", "proxies.default.enable_streaming"},
+}
+
+// migrateSettings applies deprecatedKeys to settings read from a config file, returning a warning
+// for each deprecated key found and a map of the replacement keys to merge into the config
+func migrateSettings(settings map[string]interface{}) (map[string]interface{}, []Problem) {
+	var warnings []Problem
+	replacements := make(map[string]interface{})
+	for _, rename := range deprecatedKeys {
+		value, ok := removeSetting(settings, strings.Split(rename.Old, "."))
+		if !ok {
+			continue
+		}
+		if _, exists := lookupSetting(settings, strings.Split(rename.New, ".")); exists {
+			warnings = append(warnings, Problem{Key: rename.Old, Message: fmt.Sprintf("deprecated and ignored since %s is also set; remove it", rename.New), Warning: true})
+			continue
+		}
+		setSetting(replacements, strings.Split(rename.New, "."), value)
+		warnings = append(warnings, Problem{Key: rename.Old, Message: fmt.Sprintf("deprecated; use %s instead (mimic migrate-config rewrites the file)", rename.New), Warning: true})
+	}
+	return replacements, warnings
+}
+
+func lookupSetting(settings map[string]interface{}, path []string) (interface{}, bool) {
+	value, ok := settings[path[0]]
+	if !ok || len(path) == 1 {
+		return value, ok
+	}
+	nested, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, false
+	}
+	return lookupSetting(nested, path[1:])
+}
+
+// removeSetting deletes the key at path, and any section it leaves empty
+func removeSetting(settings map[string]interface{}, path []string) (interface{}, bool) {
+	value, ok := settings[path[0]]
+	if !ok {
+		return nil, false
+	}
+	if len(path) == 1 {
+		delete(settings, path[0])
+		return value, true
+	}
+	nested, isMap := value.(map[string]interface{})
+	if !isMap {
+		return nil, false
+	}
+	removed, ok := removeSetting(nested, path[1:])
+	if ok && len(nested) == 0 {
+		delete(settings, path[0])
+	}
+	return removed, ok
+}
+
+func setSetting(settings map[string]interface{}, path []string, value interface{}) {
+	if len(path) == 1 {
+		settings[path[0]] = value
+		return
+	}
+	nested, ok := settings[path[0]].(map[string]interface{})
+	if !ok {
+		nested = make(map[string]interface{})
+		settings[path[0]] = nested
+	}
+	setSetting(nested, path[1:], value)
+}
+
+// unknownKeys warns about keys in settings that no field of t reads, which would otherwise be
+// silently ignored
+func unknownKeys(settings map[string]interface{}, prefix string, t reflect.Type) []Problem {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		tag := t.Field(i).Tag.Get("mapstructure")
+		if tag != "" && tag != "-" {
+			fields[tag] = t.Field(i).Type
+		}
+	}
+
+	var warnings []Problem
+	for _, key := range sortedKeys(settings) {
+		fieldType, ok := fields[strings.ToLower(key)]
+		if !ok {
+			if nested, isMap := settings[key].(map[string]interface{}); isMap {
+				if deprecated := deprecatedSectionKeys(prefix + key); len(deprecated) > 0 {
+					// Whatever is left of a deprecated section was not migrated
+					warnings = append(warnings, leftoverKeys(nested, prefix+key+".", deprecated)...)
+					continue
+				}
+			}
+			warnings = append(warnings, unknownKey(prefix, key, fieldNames(fields)))
+			continue
+		}
+		warnings = append(warnings, unknownNestedKeys(settings[key], prefix+key, fieldType)...)
+	}
+	return warnings
+}
+
+// unknownKey warns about key, suggesting the closest of the known keys next to it
+func unknownKey(prefix, key string, known []string) Problem {
+	message := "unknown key, ignored"
+	if suggestion := closestKey(strings.ToLower(key), known); suggestion != "" {
+		message += fmt.Sprintf("; did you mean %s?", suggestion)
+	}
+	return Problem{Key: prefix + key, Message: message, Warning: true}
+}
+
+// leftoverKeys warns about every key of a deprecated section that migrateSettings left behind
+func leftoverKeys(settings map[string]interface{}, prefix string, deprecated []string) []Problem {
+	var warnings []Problem
+	for _, key := range sortedKeys(settings) {
+		warnings = append(warnings, unknownKey(prefix, key, deprecated))
+	}
+	return warnings
+}
+
+// deprecatedSectionKeys returns the names of the deprecated keys directly in section, which is
+// otherwise unknown
+func deprecatedSectionKeys(section string) []string {
+	var names []string
+	for _, rename := range deprecatedKeys {
+		if name := strings.TrimPrefix(rename.Old, strings.ToLower(section)+"."); name != rename.Old && !strings.Contains(name, ".") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func fieldNames(fields map[string]reflect.Type) []string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	return names
+}
+
+func sortedKeys(settings map[string]interface{}) []string {
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// unknownNestedKeys checks the sections, struct maps, and struct lists inside value
+func unknownNestedKeys(value interface{}, key string, t reflect.Type) []Problem {
+	switch t.Kind() {
+	case reflect.Ptr:
+		return unknownNestedKeys(value, key, t.Elem())
+	case reflect.Struct:
+		if nested, ok := value.(map[string]interface{}); ok {
+			return unknownKeys(nested, key+".", t)
+		}
+	case reflect.Map:
+		nested, ok := value.(map[string]interface{})
+		if !ok || t.Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		var warnings []Problem
+		for name, entry := range nested {
+			warnings = append(warnings, unknownNestedKeys(entry, key+"."+name, t.Elem())...)
+		}
+		sort.Slice(warnings, func(i, j int) bool { return warnings[i].Key < warnings[j].Key })
+		return warnings
+	case reflect.Slice:
+		entries, ok := value.([]interface{})
+		if !ok || t.Elem().Kind() != reflect.Struct {
+			return nil
+		}
+		var warnings []Problem
+		for i, entry := range entries {
+			warnings = append(warnings, unknownNestedKeys(entry, key+"["+strconv.Itoa(i)+"]", t.Elem())...)
+		}
+		return warnings
+	}
+	return nil
+}
+
+// closestKey returns the candidate within two edits of key, if there is one
+func closestKey(key string, candidates []string) string {
+	best, bestDistance := "", 3
+	for _, candidate := range candidates {
+		if distance := editDistance(key, candidate); distance < bestDistance || (distance == bestDistance && candidate < best) {
+			best, bestDistance = candidate, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// MigrateFile rewrites a YAML config to the current version: deprecated keys are moved to their
+// replacements and config_version is set. Comments are kept. The changes made are returned as
+// problems keyed by the deprecated key.
+func MigrateFile(data []byte) ([]byte, []Problem, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse config: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, nil, fmt.Errorf("config must be a YAML mapping")
+	}
+
+	var changes []Problem
+	for _, rename := range deprecatedKeys {
+		key, value, ok := removeNode(root, strings.Split(rename.Old, "."))
+		if !ok {
+			continue
+		}
+		newPath := strings.Split(rename.New, ".")
+		if lookupNode(root, newPath) != nil {
+			changes = append(changes, Problem{Key: rename.Old, Message: fmt.Sprintf("removed, since %s is also set", rename.New)})
+			continue
+		}
+		key.Value = newPath[len(newPath)-1]
+		setNode(root, newPath, key, value)
+		changes = append(changes, Problem{Key: rename.Old, Message: "moved to " + rename.New})
+	}
+	// Sections are only dropped once empty, so a key moved within its section stays in place
+	for _, change := range changes {
+		path := strings.Split(change.Key, ".")
+		for i := len(path) - 1; i > 0; i-- {
+			removeEmptyNode(root, path[:i])
+		}
+	}
+
+	version := strconv.Itoa(CurrentConfigVersion)
+	if node := lookupNode(root, []string{"config_version"}); node != nil {
+		if node.Value != version {
+			changes = append(changes, Problem{Key: "config_version", Message: fmt.Sprintf("set to %s", version)})
+		}
+		node.Kind, node.Tag, node.Style, node.Value = yaml.ScalarNode, "!!int", 0, version
+	} else {
+		// The file's leading comment stays at the top
+		key := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "config_version"}
+		if len(root.Content) > 0 {
+			key.HeadComment, root.Content[0].HeadComment = root.Content[0].HeadComment, ""
+		}
+		value := &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: version}
+		root.Content = append([]*yaml.Node{key, value}, root.Content...)
+		changes = append(changes, Problem{Key: "config_version", Message: fmt.Sprintf("set to %s", version)})
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&doc); err != nil {
+		return nil, nil, fmt.Errorf("failed to write config: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, nil, fmt.Errorf("failed to write config: %w", err)
+	}
+	return buf.Bytes(), changes, nil
+}
+
+// lookupNode returns the value at path in a mapping node, matching keys case-insensitively like viper
+func lookupNode(mapping *yaml.Node, path []string) *yaml.Node {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if !strings.EqualFold(mapping.Content[i].Value, path[0]) {
+			continue
+		}
+		value := mapping.Content[i+1]
+		if len(path) == 1 {
+			return value
+		}
+		if value.Kind != yaml.MappingNode {
+			return nil
+		}
+		return lookupNode(value, path[1:])
+	}
+	return nil
+}
+
+// removeNode deletes the entry at path from a mapping node
+func removeNode(mapping *yaml.Node, path []string) (*yaml.Node, *yaml.Node, bool) {
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if !strings.EqualFold(mapping.Content[i].Value, path[0]) {
+			continue
+		}
+		key, value := mapping.Content[i], mapping.Content[i+1]
+		if len(path) == 1 {
+			mapping.Content = append(mapping.Content[:i], mapping.Content[i+2:]...)
+			return key, value, true
+		}
+		if value.Kind != yaml.MappingNode {
+			return nil, nil, false
+		}
+		return removeNode(value, path[1:])
+	}
+	return nil, nil, false
+}
+
+// removeEmptyNode deletes the section at path if it has no entries left
+func removeEmptyNode(mapping *yaml.Node, path []string) {
+	if section := lookupNode(mapping, path); section != nil && section.Kind == yaml.MappingNode && len(section.Content) == 0 {
+		removeNode(mapping, path)
+	}
+}
+
+// setNode adds an entry at path to a mapping node, creating the sections on the way
+func setNode(mapping *yaml.Node, path []string, key, value *yaml.Node) {
+	if len(path) == 1 {
+		mapping.Content = append(mapping.Content, key, value)
+		return
+	}
+	section := lookupNode(mapping, path[:1])
+	if section == nil {
+		section = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		mapping.Content = append(mapping.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: path[0]}, section)
+	} else if section.Kind != yaml.MappingNode {
+		// An empty section such as "proxies:" parses as null
+		*section = yaml.Node{Kind: yaml.MappingNode, Tag: "!!map", LineComment: section.LineComment}
+	}
+	setNode(section, path[1:], key, value)
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const legacyConfig = `# Starter config
+server:
+  port: 18080
+proxy:
+  mode: mock # global mode
+  target_host: "api.example.com"
+  target_port: 443
+  session_name: legacy
+  enable_streming: true
+webhooks:
+  - url: https://hooks.example.com/mimic
+    secrt: abc
+`
+
+func TestLoadConfigMigratesDeprecatedKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(legacyConfig), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Migrated config is invalid: %v", err)
+	}
+
+	if cfg.ConfigVersion != 1 || cfg.Mode != "mock" || cfg.Server.ListenPort != 18080 {
+		t.Errorf("Expected version 1, mode mock, and port 18080, got %d, %s, and %d", cfg.ConfigVersion, cfg.Mode, cfg.Server.ListenPort)
+	}
+	if proxy := cfg.Proxies["default"]; proxy.TargetHost != "api.example.com" || proxy.TargetPort != 443 || proxy.SessionName != "legacy" {
+		t.Errorf("Expected the proxy section to become the default proxy, got %+v", cfg.Proxies)
+	}
+
+	warnings := make(map[string]string)
+	for _, warning := range cfg.Warnings {
+		if !warning.Warning {
+			t.Errorf("Expected %s to be a warning", warning.Key)
+		}
+		warnings[warning.Key] = warning.Message
+	}
+	expected := map[string]string{
+		"server.port":           "deprecated; use server.listen_port instead",
+		"proxy.target_host":     "deprecated; use proxies.default.target_host instead",
+		"proxy.enable_streming": "did you mean enable_streaming?",
+		"webhooks[0].secrt":     "did you mean secret?",
+	}
+	for key, message := range expected {
+		if !strings.Contains(warnings[key], message) {
+			t.Errorf("Expected a warning for %s containing %q, got %q", key, message, warnings[key])
+		}
+	}
+	if len(cfg.Warnings) != 7 {
+		t.Errorf("Expected 7 warnings, got %v", cfg.Warnings)
+	}
+}
+
+func TestValidateRejectsNewerConfigVersion(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.ConfigVersion = CurrentConfigVersion + 1
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "unsupported config_version") {
+		t.Errorf("Expected a newer config_version to be rejected, got %v", err)
+	}
+}
+
+func TestMigrateFile(t *testing.T) {
+	migrated, changes, err := MigrateFile([]byte(legacyConfig))
+	if err != nil {
+		t.Fatalf("MigrateFile failed: %v", err)
+	}
+	expected := `# Starter config
+config_version: 2
+server:
+  listen_port: 18080
+proxy:
+  enable_streming: true
+webhooks:
+  - url: https://hooks.example.com/mimic
+    secrt: abc
+mode: mock # global mode
+proxies:
+  default:
+    target_host: "api.example.com"
+    target_port: 443
+    session_name: legacy
+`
+	if string(migrated) != expected {
+		t.Errorf("Unexpected migrated config:\n%s", migrated)
+	}
+	if len(changes) != 6 || changes[0].Key != "server.port" || changes[5].Key != "config_version" {
+		t.Errorf("Unexpected changes: %v", changes)
+	}
+
+	again, changes, err := MigrateFile(migrated)
+	if err != nil || len(changes) != 0 || string(again) != expected {
+		t.Errorf("Expected a migrated config to be left alone, got %v and %v:\n%s", changes, err, again)
+	}
+}
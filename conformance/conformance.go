@@ -0,0 +1,278 @@
+// Package conformance checks HTTP traffic against an OpenAPI 3 spec: requests to endpoints or
+// methods the spec does not describe, status codes it does not document, and request and response
+// bodies that do not match their schemas. Recorded sessions are checked on demand; the traffic
+// of proxies with an openapi_spec is checked live.
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"mimic/storage"
+)
+
+// Kinds of violations
+const (
+	KindUnknownEndpoint    = "unknown_endpoint"    // No path in the spec matches
+	KindUnknownMethod      = "unknown_method"      // The path has no operation for the method
+	KindUndocumentedStatus = "undocumented_status" // The operation does not list the status, a range covering it, or default
+	KindInvalidRequest     = "invalid_request"     // The request body is missing or does not match its schema
+	KindInvalidResponse    = "invalid_response"    // The response body does not match its schema or content type
+)
+
+// Exchange is one request and its response, as far as conformance checks need them
+type Exchange struct {
+	Method              string
+	Path                string
+	RequestContentType  string
+	RequestBody         []byte
+	Status              int
+	ResponseContentType string
+	ResponseBody        []byte
+	SkipResponseBody    bool // The body was not kept whole, e.g. for streamed responses
+}
+
+// Violation is one way an exchange departs from the spec
+type Violation struct {
+	Kind          string `json:"kind"`
+	Method        string `json:"method"`
+	Path          string `json:"path"`
+	Operation     string `json:"operation,omitempty"` // The matching path template, e.g. /users/{id}
+	Status        int    `json:"status,omitempty"`
+	Pointer       string `json:"pointer,omitempty"` // JSON pointer into the offending body
+	Message       string `json:"message"`
+	InteractionID int    `json:"interaction_id,omitempty"`
+}
+
+// Check returns the ways an exchange departs from the spec, along with the operation it was
+// for as "METHOD /template", empty when there is none
+func (s *Spec) Check(exchange Exchange) ([]Violation, string) {
+	method := strings.ToUpper(exchange.Method)
+	violation := func(kind, format string, args ...interface{}) Violation {
+		return Violation{Kind: kind, Method: method, Path: exchange.Path, Status: exchange.Status, Message: fmt.Sprintf(format, args...)}
+	}
+
+	item := s.findPath(exchange.Path)
+	if item == nil {
+		return []Violation{violation(KindUnknownEndpoint, "%s is not described by the spec", exchange.Path)}, ""
+	}
+	operationMethod := method
+	operation, ok := item.operations[method]
+	if !ok && method == "HEAD" {
+		operationMethod = "GET"
+		operation, ok = item.operations["GET"]
+	}
+	if !ok {
+		v := violation(KindUnknownMethod, "%s %s is not described by the spec", method, item.template)
+		v.Operation = item.template
+		return []Violation{v}, ""
+	}
+
+	var violations []Violation
+	add := func(v Violation) {
+		v.Operation = item.template
+		violations = append(violations, v)
+	}
+
+	if body, ok := s.resolve(operation["requestBody"]).(map[string]interface{}); ok {
+		if len(exchange.RequestBody) == 0 {
+			if body["required"] == true {
+				add(violation(KindInvalidRequest, "request body is required"))
+			}
+		} else {
+			for _, problem := range s.checkBody(body, exchange.RequestContentType, exchange.RequestBody, false) {
+				v := violation(KindInvalidRequest, "request body%s: %s", at(problem.pointer), problem.message)
+				v.Pointer = problem.pointer
+				add(v)
+			}
+		}
+	}
+
+	responses, _ := operation["responses"].(map[string]interface{})
+	response, documented := s.findResponse(responses, exchange.Status)
+	if !documented {
+		add(violation(KindUndocumentedStatus, "status %d is not documented for %s %s", exchange.Status, method, item.template))
+	} else if len(exchange.ResponseBody) > 0 && !exchange.SkipResponseBody {
+		for _, problem := range s.checkBody(response, exchange.ResponseContentType, exchange.ResponseBody, true) {
+			v := violation(KindInvalidResponse, "response body%s: %s", at(problem.pointer), problem.message)
+			v.Pointer = problem.pointer
+			add(v)
+		}
+	}
+	return violations, operationMethod + " " + item.template
+}
+
+// findResponse picks the response documented for a status: the exact code, then its range such
+// as 4XX, then default
+func (s *Spec) findResponse(responses map[string]interface{}, status int) (map[string]interface{}, bool) {
+	code := strconv.Itoa(status)
+	for _, key := range []string{code, code[:1] + "XX", code[:1] + "xx", "default"} {
+		if response, ok := responses[key]; ok {
+			resolved, _ := s.resolve(response).(map[string]interface{})
+			return resolved, true
+		}
+	}
+	return nil, false
+}
+
+// checkBody validates a request body or response against the schema documented for its content
+// type. Bodies of content types other than JSON are only checked for being documented.
+func (s *Spec) checkBody(definition map[string]interface{}, contentType string, body []byte, response bool) []schemaError {
+	content, _ := definition["content"].(map[string]interface{})
+	if len(content) == 0 {
+		return nil // Nothing documented to check against
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	media, ok := content[mediaType]
+	if !ok {
+		if slash := strings.Index(mediaType, "/"); slash > 0 {
+			media, ok = content[mediaType[:slash]+"/*"]
+		}
+	}
+	if !ok {
+		media, ok = content["*/*"]
+	}
+	if !ok {
+		if mediaType == "" {
+			mediaType = "(none)"
+		}
+		return []schemaError{{message: fmt.Sprintf("undocumented content type %s", mediaType)}}
+	}
+	if !isJSON(mediaType) {
+		return nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return []schemaError{{message: fmt.Sprintf("invalid JSON: %v", err)}}
+	}
+	object, _ := media.(map[string]interface{})
+	schema, ok := object["schema"]
+	if !ok {
+		return nil
+	}
+	v := &validator{spec: s, response: response}
+	v.check(schema, value, "", 0)
+	return v.errors
+}
+
+// at locates a problem in a body for a message
+func at(pointer string) string {
+	if pointer == "" {
+		return ""
+	}
+	return " at " + pointer
+}
+
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// ExchangeFromInteraction reads a recorded REST interaction; gRPC interactions are not covered
+// by OpenAPI and report false
+func ExchangeFromInteraction(interaction *storage.Interaction) (Exchange, bool) {
+	if interaction.Protocol == "gRPC" {
+		return Exchange{}, false
+	}
+	return Exchange{
+		Method:              interaction.Method,
+		Path:                interaction.Endpoint,
+		RequestContentType:  headerValue(interaction.RequestHeaders, "Content-Type"),
+		RequestBody:         interaction.RequestBody,
+		Status:              interaction.ResponseStatus,
+		ResponseContentType: headerValue(interaction.ResponseHeaders, "Content-Type"),
+		ResponseBody:        interaction.ResponseBody,
+		SkipResponseBody:    interaction.IsStreaming || interaction.MetadataMap()[storage.MetadataResponseTruncated] == true || interaction.ResponseBodyFile() != "",
+	}, true
+}
+
+// headerValue finds a header in recorded headers, ignoring case
+func headerValue(headersJSON, name string) string {
+	var headers map[string]interface{}
+	if err := json.Unmarshal([]byte(headersJSON), &headers); err != nil {
+		return ""
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			switch v := value.(type) {
+			case string:
+				return v
+			case []interface{}:
+				if len(v) > 0 {
+					s, _ := v[0].(string)
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// Report is the outcome of checking a session against a spec
+type Report struct {
+	Session      string         `json:"session"`
+	Spec         string         `json:"spec"` // Title and version of the spec
+	Interactions int            `json:"interactions"`
+	Checked      int            `json:"checked"`    // REST interactions; gRPC ones are skipped
+	Conforming   int            `json:"conforming"` // Checked interactions without violations
+	Violations   []Violation    `json:"violations"`
+	Counts       map[string]int `json:"counts"`    // Violations by kind
+	Uncovered    []string       `json:"uncovered"` // Operations no interaction exercised, as "METHOD /template"
+}
+
+// CheckSession checks every REST interaction of a session against spec
+func CheckSession(db *storage.Database, sessionName string, spec *Spec) (*Report, error) {
+	session, err := db.GetSession(sessionName)
+	if err != nil {
+		return nil, err
+	}
+	interactions, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Session:      sessionName,
+		Spec:         strings.TrimSpace(spec.Title + " " + spec.Version),
+		Interactions: len(interactions),
+		Violations:   []Violation{},
+		Counts:       make(map[string]int),
+		Uncovered:    []string{},
+	}
+	covered := make(map[string]bool)
+	for i := range interactions {
+		exchange, ok := ExchangeFromInteraction(&interactions[i])
+		if !ok {
+			continue
+		}
+		report.Checked++
+		violations, operation := spec.Check(exchange)
+		if operation != "" {
+			covered[operation] = true
+		}
+		if len(violations) == 0 {
+			report.Conforming++
+		}
+		for _, v := range violations {
+			v.InteractionID = interactions[i].ID
+			report.Violations = append(report.Violations, v)
+			report.Counts[v.Kind]++
+		}
+	}
+	for _, operation := range spec.Operations() {
+		if !covered[operation] {
+			report.Uncovered = append(report.Uncovered, operation)
+		}
+	}
+	sort.SliceStable(report.Violations, func(i, j int) bool {
+		return report.Violations[i].InteractionID < report.Violations[j].InteractionID
+	})
+	return report, nil
+}
@@ -0,0 +1,215 @@
+package conformance
+
+import (
+	"reflect"
+	"testing"
+
+	"mimic/storage"
+)
+
+const testSpec = `
+openapi: 3.0.3
+info:
+  title: Users
+  version: "1.0"
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /users:
+    post:
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema:
+              $ref: '#/components/schemas/NewUser'
+      responses:
+        "201":
+          description: Created
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+        4XX:
+          description: Rejected
+  /users/{id}:
+    get:
+      responses:
+        "200":
+          description: The user
+          content:
+            application/json:
+              schema:
+                $ref: '#/components/schemas/User'
+  /users/me:
+    get:
+      responses:
+        "200":
+          description: The caller
+components:
+  schemas:
+    NewUser:
+      type: object
+      required: [name]
+      properties:
+        name: {type: string, minLength: 1}
+        role: {type: string, enum: [admin, member]}
+      additionalProperties: false
+    User:
+      type: object
+      required: [id, name]
+      properties:
+        id: {type: integer, readOnly: true}
+        name: {type: string}
+        email: {type: string, nullable: true}
+`
+
+func mustParse(t *testing.T) *Spec {
+	t.Helper()
+	spec, err := ParseSpec([]byte(testSpec))
+	if err != nil {
+		t.Fatalf("ParseSpec failed: %v", err)
+	}
+	return spec
+}
+
+func TestParseSpec(t *testing.T) {
+	spec := mustParse(t)
+	if spec.Title != "Users" || spec.Version != "1.0" {
+		t.Errorf("Expected Users 1.0, got %q %q", spec.Title, spec.Version)
+	}
+	expected := []string{"GET /users/me", "GET /users/{id}", "POST /users"}
+	if got := spec.Operations(); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected operations %v, got %v", expected, got)
+	}
+
+	if _, err := ParseSpec([]byte(`{"swagger": "2.0", "paths": {}}`)); err == nil {
+		t.Error("Expected swagger 2.0 to be rejected")
+	}
+}
+
+func TestCheck(t *testing.T) {
+	spec := mustParse(t)
+	json := "application/json"
+	tests := []struct {
+		name      string
+		exchange  Exchange
+		kinds     []string
+		operation string
+		message   string
+	}{
+		{"conforming", Exchange{Method: "POST", Path: "/v1/users", RequestContentType: json, RequestBody: []byte(`{"name":"Ada"}`),
+			Status: 201, ResponseContentType: "application/json; charset=utf-8", ResponseBody: []byte(`{"id":1,"name":"Ada","email":null}`)},
+			nil, "POST /users", ""},
+		{"concrete path wins", Exchange{Method: "GET", Path: "/users/me", Status: 200}, nil, "GET /users/me", ""},
+		{"status range", Exchange{Method: "POST", Path: "/users", RequestContentType: json, RequestBody: []byte(`{"name":"Ada"}`), Status: 409},
+			nil, "POST /users", ""},
+		{"unknown endpoint", Exchange{Method: "GET", Path: "/orders", Status: 200},
+			[]string{KindUnknownEndpoint}, "", "/orders is not described by the spec"},
+		{"unknown method", Exchange{Method: "DELETE", Path: "/users/7", Status: 204},
+			[]string{KindUnknownMethod}, "", "DELETE /users/{id} is not described by the spec"},
+		{"undocumented status", Exchange{Method: "GET", Path: "/users/7", Status: 500},
+			[]string{KindUndocumentedStatus}, "GET /users/{id}", "status 500 is not documented for GET /users/{id}"},
+		{"missing request body", Exchange{Method: "POST", Path: "/users", Status: 400},
+			[]string{KindInvalidRequest}, "POST /users", "request body is required"},
+		{"invalid request", Exchange{Method: "POST", Path: "/users", RequestContentType: json, RequestBody: []byte(`{"name":"Ada","role":"owner"}`), Status: 400},
+			[]string{KindInvalidRequest}, "POST /users", `request body at /role: "owner" is not one of the allowed values`},
+		{"invalid response", Exchange{Method: "GET", Path: "/users/7", Status: 200, ResponseContentType: json, ResponseBody: []byte(`{"id":"7","name":"Ada"}`)},
+			[]string{KindInvalidResponse}, "GET /users/{id}", "response body at /id: expected integer, got string"},
+		{"undocumented content type", Exchange{Method: "GET", Path: "/users/7", Status: 200, ResponseContentType: "text/html", ResponseBody: []byte(`<p>Ada</p>`)},
+			[]string{KindInvalidResponse}, "GET /users/{id}", "response body: undocumented content type text/html"},
+		{"skipped response body", Exchange{Method: "GET", Path: "/users/7", Status: 200, ResponseContentType: json, ResponseBody: []byte(`{"id":`), SkipResponseBody: true},
+			nil, "GET /users/{id}", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			violations, operation := spec.Check(tt.exchange)
+			var kinds []string
+			for _, v := range violations {
+				kinds = append(kinds, v.Kind)
+			}
+			if !reflect.DeepEqual(kinds, tt.kinds) {
+				t.Fatalf("Expected violations %v, got %+v", tt.kinds, violations)
+			}
+			if tt.operation != "" && operation != tt.operation {
+				t.Errorf("Expected operation %q, got %q", tt.operation, operation)
+			}
+			if tt.message != "" && violations[0].Message != tt.message {
+				t.Errorf("Expected message %q, got %q", tt.message, violations[0].Message)
+			}
+		})
+	}
+}
+
+func TestCheckSession(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	session, err := db.CreateSession("users", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	interactions := []*storage.Interaction{
+		{SessionID: session.ID, RequestID: "r1", Protocol: "REST", Method: "GET", Endpoint: "/users/1",
+			RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{"Content-Type":["application/json"]}`, ResponseBody: []byte(`{"id":1,"name":"Ada"}`)},
+		{SessionID: session.ID, RequestID: "r2", Protocol: "REST", Method: "GET", Endpoint: "/users/2",
+			RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{"Content-Type":["application/json"]}`, ResponseBody: []byte(`{"id":2}`)},
+		{SessionID: session.ID, RequestID: "r3", Protocol: "gRPC", Method: "POST", Endpoint: "/users.Users/Get",
+			RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{}`},
+	}
+	for _, interaction := range interactions {
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	report, err := CheckSession(db, "users", mustParse(t))
+	if err != nil {
+		t.Fatalf("CheckSession failed: %v", err)
+	}
+	if report.Interactions != 3 || report.Checked != 2 || report.Conforming != 1 {
+		t.Errorf("Expected 3 interactions, 2 checked, 1 conforming, got %+v", report)
+	}
+	if len(report.Violations) != 1 || report.Violations[0].InteractionID != interactions[1].ID ||
+		report.Violations[0].Message != `response body: missing required property "name"` {
+		t.Errorf("Expected the missing name of interaction %d, got %+v", interactions[1].ID, report.Violations)
+	}
+	if expected := []string{"GET /users/me", "POST /users"}; !reflect.DeepEqual(report.Uncovered, expected) {
+		t.Errorf("Expected uncovered %v, got %v", expected, report.Uncovered)
+	}
+}
+
+func TestMonitorObserve(t *testing.T) {
+	monitor := NewMonitor(2)
+	monitor.specs["api"] = mustParse(t)
+	var notified []Event
+	monitor.SetNotifier(func(eventType string, data interface{}) {
+		notified = append(notified, data.([]Event)...)
+	})
+
+	monitor.Observe("other", "s1", Exchange{Method: "GET", Path: "/nowhere", Status: 200})
+	monitor.Observe("api", "s1", Exchange{Method: "GET", Path: "/users/me", Status: 200})
+	for _, path := range []string{"/a", "/b", "/c"} {
+		monitor.Observe("api", "s1", Exchange{Method: "GET", Path: path, Status: 200})
+	}
+	monitor.Observe("api", "s2", Exchange{Method: "GET", Path: "/d", Status: 200})
+
+	if len(notified) != 4 {
+		t.Errorf("Expected 4 notified violations, got %d", len(notified))
+	}
+	events := monitor.Events("", "")
+	if len(events) != 2 || events[0].Path != "/c" || events[1].Path != "/d" || events[1].Seq != 4 {
+		t.Errorf("Expected the last 2 violations, got %+v", events)
+	}
+	if events := monitor.Events("api", "s2"); len(events) != 1 {
+		t.Errorf("Expected 1 violation in s2, got %+v", events)
+	}
+
+	monitor.Reset()
+	if events := monitor.Events("", ""); len(events) != 0 {
+		t.Errorf("Expected no violations after reset, got %+v", events)
+	}
+}
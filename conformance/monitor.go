@@ -0,0 +1,163 @@
+package conformance
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"mimic/config"
+)
+
+// DefaultCapacity is how many live violations a monitor keeps before dropping the oldest
+const DefaultCapacity = 1000
+
+// Event is a violation seen in live traffic
+type Event struct {
+	Seq     uint64    `json:"seq"`
+	Proxy   string    `json:"proxy"`
+	Session string    `json:"session"`
+	Time    time.Time `json:"time"`
+	Violation
+}
+
+// Monitor checks live traffic against the spec of each proxy that has one, keeping the violations
+type Monitor struct {
+	mutex    sync.RWMutex
+	specs    map[string]*Spec
+	events   []Event
+	next     uint64
+	capacity int
+	notify   func(eventType string, data interface{})
+}
+
+// Default is the monitor the proxies report to
+var Default = NewMonitor(DefaultCapacity)
+
+func NewMonitor(capacity int) *Monitor {
+	return &Monitor{specs: make(map[string]*Spec), capacity: capacity}
+}
+
+// SetNotifier registers a callback for "conformance_violation" events
+func (m *Monitor) SetNotifier(notify func(eventType string, data interface{})) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.notify = notify
+}
+
+// Configure loads the openapi_spec of each proxy, replacing the specs loaded before. A spec that
+// fails to load is skipped and its error returned, so live checks are off for that proxy only.
+func (m *Monitor) Configure(proxies map[string]config.ProxyConfig) []error {
+	specs := make(map[string]*Spec)
+	var errs []error
+	for name, proxyConfig := range proxies {
+		if proxyConfig.OpenAPISpec == "" {
+			continue
+		}
+		spec, err := LoadSpec(proxyConfig.OpenAPISpec)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("proxy '%s': %w", name, err))
+			continue
+		}
+		specs[name] = spec
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.specs = specs
+	return errs
+}
+
+// Spec returns the spec a proxy's traffic is checked against, nil when it has none
+func (m *Monitor) Spec(proxyName string) *Spec {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	return m.specs[proxyName]
+}
+
+// Observe checks an exchange served by a proxy, keeping and announcing any violations
+func (m *Monitor) Observe(proxyName, sessionName string, exchange Exchange) []Violation {
+	spec := m.Spec(proxyName)
+	if spec == nil {
+		return nil
+	}
+	violations, _ := spec.Check(exchange)
+	if len(violations) == 0 {
+		return nil
+	}
+
+	m.mutex.Lock()
+	now := time.Now()
+	events := make([]Event, len(violations))
+	for i, violation := range violations {
+		m.next++
+		events[i] = Event{Seq: m.next, Proxy: proxyName, Session: sessionName, Time: now, Violation: violation}
+	}
+	m.events = append(m.events, events...)
+	if excess := len(m.events) - m.capacity; excess > 0 {
+		m.events = m.events[excess:]
+	}
+	notify := m.notify
+	m.mutex.Unlock()
+
+	if notify != nil {
+		notify("conformance_violation", events)
+	}
+	return violations
+}
+
+// Events returns the kept violations, oldest first, limited to a proxy and session when given
+func (m *Monitor) Events(proxyName, sessionName string) []Event {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	events := []Event{}
+	for _, event := range m.events {
+		if (proxyName == "" || event.Proxy == proxyName) && (sessionName == "" || event.Session == sessionName) {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// Reset forgets the kept violations
+func (m *Monitor) Reset() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.events = nil
+}
+
+// SpecProxy picks the proxy whose openapi_spec a session is checked against: the one serving the
+// session, or else the only proxy with a spec
+func SpecProxy(proxies map[string]config.ProxyConfig, sessionName string) (string, error) {
+	var withSpec []string
+	for name, proxyConfig := range proxies {
+		if proxyConfig.OpenAPISpec == "" {
+			continue
+		}
+		if proxyConfig.SessionName == sessionName {
+			return name, nil
+		}
+		withSpec = append(withSpec, name)
+	}
+	switch len(withSpec) {
+	case 0:
+		return "", fmt.Errorf("no proxy has an openapi_spec")
+	case 1:
+		return withSpec[0], nil
+	default:
+		sort.Strings(withSpec)
+		return "", fmt.Errorf("several proxies have an openapi_spec (%s); choose one", strings.Join(withSpec, ", "))
+	}
+}
+
+// Configure loads the proxies' specs into the default monitor
+func Configure(proxies map[string]config.ProxyConfig) []error {
+	return Default.Configure(proxies)
+}
+
+// Observe checks an exchange with the default monitor
+func Observe(proxyName, sessionName string, exchange Exchange) []Violation {
+	return Default.Observe(proxyName, sessionName, exchange)
+}
@@ -0,0 +1,267 @@
+package conformance
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSchemaDepth stops recursive schemas from validating forever
+const maxSchemaDepth = 64
+
+// schemaError is one place a value does not match its schema
+type schemaError struct {
+	pointer string // JSON pointer into the value, "" for the whole value
+	message string
+}
+
+// validator checks values against the JSON Schema subset OpenAPI 3.0 and 3.1 documents use:
+// types, nullability, enums, properties, items, composition, and string and number bounds.
+// Formats and discriminators are not checked.
+type validator struct {
+	spec     *Spec
+	response bool // Required readOnly properties apply to responses, writeOnly ones to requests
+	errors   []schemaError
+}
+
+func (v *validator) fail(pointer, format string, args ...interface{}) {
+	v.errors = append(v.errors, schemaError{pointer: pointer, message: fmt.Sprintf(format, args...)})
+}
+
+// check validates value against schema, recording where it does not match
+func (v *validator) check(schema interface{}, value interface{}, pointer string, depth int) {
+	if depth > maxSchemaDepth {
+		return
+	}
+	definition, ok := v.spec.resolve(schema).(map[string]interface{})
+	if !ok {
+		return // true, or an unresolvable $ref, accepts anything
+	}
+
+	for _, sub := range list(definition["allOf"]) {
+		v.check(sub, value, pointer, depth+1)
+	}
+	if alternatives := list(definition["anyOf"]); len(alternatives) > 0 && v.matching(alternatives, value, depth) == 0 {
+		v.fail(pointer, "matches none of the anyOf schemas")
+	}
+	if alternatives := list(definition["oneOf"]); len(alternatives) > 0 {
+		if matched := v.matching(alternatives, value, depth); matched != 1 {
+			v.fail(pointer, "matches %d of the oneOf schemas, expected exactly 1", matched)
+		}
+	}
+
+	if value == nil && nullable(definition) {
+		return
+	}
+	if types := schemaTypes(definition); len(types) > 0 && !hasType(types, value) {
+		v.fail(pointer, "expected %s, got %s", strings.Join(types, " or "), jsonType(value))
+		return
+	}
+	if enum := list(definition["enum"]); len(enum) > 0 && !contains(enum, value) {
+		v.fail(pointer, "%s is not one of the allowed values", describe(value))
+	}
+	if constant, ok := definition["const"]; ok && !reflect.DeepEqual(constant, value) {
+		v.fail(pointer, "%s is not %s", describe(value), describe(constant))
+	}
+
+	switch typed := value.(type) {
+	case map[string]interface{}:
+		v.checkObject(definition, typed, pointer, depth)
+	case []interface{}:
+		if min, ok := number(definition["minItems"]); ok && float64(len(typed)) < min {
+			v.fail(pointer, "has %d items, fewer than %v", len(typed), min)
+		}
+		if max, ok := number(definition["maxItems"]); ok && float64(len(typed)) > max {
+			v.fail(pointer, "has %d items, more than %v", len(typed), max)
+		}
+		if items, ok := definition["items"]; ok {
+			for i, item := range typed {
+				v.check(items, item, fmt.Sprintf("%s/%d", pointer, i), depth+1)
+			}
+		}
+	case string:
+		length := float64(utf8.RuneCountInString(typed))
+		if min, ok := number(definition["minLength"]); ok && length < min {
+			v.fail(pointer, "is shorter than %v characters", min)
+		}
+		if max, ok := number(definition["maxLength"]); ok && length > max {
+			v.fail(pointer, "is longer than %v characters", max)
+		}
+		if pattern, ok := definition["pattern"].(string); ok {
+			if re, err := regexp.Compile(pattern); err == nil && !re.MatchString(typed) {
+				v.fail(pointer, "%s does not match %s", describe(typed), pattern)
+			}
+		}
+	case float64:
+		v.checkNumber(definition, typed, pointer)
+	}
+}
+
+// matching counts the alternatives value is valid against
+func (v *validator) matching(alternatives []interface{}, value interface{}, depth int) int {
+	matched := 0
+	for _, alternative := range alternatives {
+		trial := &validator{spec: v.spec, response: v.response}
+		trial.check(alternative, value, "", depth+1)
+		if len(trial.errors) == 0 {
+			matched++
+		}
+	}
+	return matched
+}
+
+func (v *validator) checkObject(definition, object map[string]interface{}, pointer string, depth int) {
+	properties, _ := definition["properties"].(map[string]interface{})
+	for _, name := range list(definition["required"]) {
+		key, _ := name.(string)
+		if _, ok := object[key]; ok {
+			continue
+		}
+		property, _ := v.spec.resolve(properties[key]).(map[string]interface{})
+		if (!v.response && property["readOnly"] == true) || (v.response && property["writeOnly"] == true) {
+			continue
+		}
+		v.fail(pointer, "missing required property %q", key)
+	}
+
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		child := pointer + "/" + strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+		if property, ok := properties[key]; ok {
+			v.check(property, object[key], child, depth+1)
+			continue
+		}
+		switch additional := definition["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				v.fail(pointer, "unexpected property %q", key)
+			}
+		case map[string]interface{}:
+			v.check(additional, object[key], child, depth+1)
+		}
+	}
+}
+
+func (v *validator) checkNumber(definition map[string]interface{}, n float64, pointer string) {
+	if min, ok := number(definition["minimum"]); ok {
+		// OpenAPI 3.0 makes exclusiveMinimum a flag on minimum; 3.1 makes it a bound of its own
+		if definition["exclusiveMinimum"] == true && n <= min {
+			v.fail(pointer, "%v is not greater than %v", n, min)
+		} else if n < min {
+			v.fail(pointer, "%v is less than %v", n, min)
+		}
+	}
+	if min, ok := number(definition["exclusiveMinimum"]); ok && n <= min {
+		v.fail(pointer, "%v is not greater than %v", n, min)
+	}
+	if max, ok := number(definition["maximum"]); ok {
+		if definition["exclusiveMaximum"] == true && n >= max {
+			v.fail(pointer, "%v is not less than %v", n, max)
+		} else if n > max {
+			v.fail(pointer, "%v is greater than %v", n, max)
+		}
+	}
+	if max, ok := number(definition["exclusiveMaximum"]); ok && n >= max {
+		v.fail(pointer, "%v is not less than %v", n, max)
+	}
+}
+
+// schemaTypes returns the types a schema allows; 3.1 may list several
+func schemaTypes(definition map[string]interface{}) []string {
+	switch t := definition["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		var types []string
+		for _, item := range t {
+			if name, ok := item.(string); ok {
+				types = append(types, name)
+			}
+		}
+		return types
+	}
+	return nil
+}
+
+// nullable reports whether a schema accepts null, as 3.0's nullable or 3.1's "null" type
+func nullable(definition map[string]interface{}) bool {
+	if definition["nullable"] == true {
+		return true
+	}
+	for _, t := range schemaTypes(definition) {
+		if t == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasType(types []string, value interface{}) bool {
+	actual := jsonType(value)
+	for _, t := range types {
+		if t == actual || (t == "number" && actual == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// jsonType names the JSON type of a decoded value, telling integers from other numbers
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+func list(value interface{}) []interface{} {
+	items, _ := value.([]interface{})
+	return items
+}
+
+func number(value interface{}) (float64, bool) {
+	n, ok := value.(float64)
+	return n, ok
+}
+
+func contains(values []interface{}, value interface{}) bool {
+	for _, candidate := range values {
+		if reflect.DeepEqual(candidate, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// describe renders a value for a message, shortening long strings
+func describe(value interface{}) string {
+	if s, ok := value.(string); ok {
+		if utf8.RuneCountInString(s) > 40 {
+			s = string([]rune(s)[:40]) + "…"
+		}
+		return fmt.Sprintf("%q", s)
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,222 @@
+package conformance
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// methods are the operations a path item may hold, in the order OpenAPI lists them
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Spec is an OpenAPI 3 document, indexed for looking up the operation a request is for
+type Spec struct {
+	Title    string
+	Version  string
+	basePath string // Path of the first server URL, e.g. /v1
+	paths    []*pathItem
+	document map[string]interface{} // For resolving $refs
+}
+
+type pathItem struct {
+	template   string // e.g. /users/{id}
+	pattern    *regexp.Regexp
+	params     int // Template parameters; fewer wins when several templates match
+	operations map[string]map[string]interface{}
+}
+
+// LoadSpec reads an OpenAPI 3 document, in JSON or YAML, from path
+func LoadSpec(path string) (*Spec, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	spec, err := ParseSpec(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return spec, nil
+}
+
+// ParseSpec parses an OpenAPI 3 document in JSON or YAML
+func ParseSpec(data []byte) (*Spec, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	document, ok := normalize(raw).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("not an OpenAPI document")
+	}
+	version, _ := document["openapi"].(string)
+	if !strings.HasPrefix(version, "3.") {
+		if _, swagger := document["swagger"]; swagger {
+			return nil, fmt.Errorf("swagger 2.0 documents are not supported; convert to OpenAPI 3")
+		}
+		return nil, fmt.Errorf("missing or unsupported openapi version %q", version)
+	}
+
+	spec := &Spec{document: document}
+	if info, ok := document["info"].(map[string]interface{}); ok {
+		spec.Title, _ = info["title"].(string)
+		spec.Version, _ = info["version"].(string)
+	}
+	if servers, ok := document["servers"].([]interface{}); ok && len(servers) > 0 {
+		if server, ok := servers[0].(map[string]interface{}); ok {
+			serverURL, _ := server["url"].(string)
+			if parsed, err := url.Parse(serverURL); err == nil {
+				spec.basePath = strings.TrimSuffix(parsed.Path, "/")
+			}
+		}
+	}
+
+	paths, _ := document["paths"].(map[string]interface{})
+	for template, value := range paths {
+		item, ok := spec.resolve(value).(map[string]interface{})
+		if !ok {
+			continue
+		}
+		pattern, params := compileTemplate(template)
+		entry := &pathItem{template: template, pattern: pattern, params: params, operations: make(map[string]map[string]interface{})}
+		for _, method := range methods {
+			if operation, ok := item[method].(map[string]interface{}); ok {
+				entry.operations[strings.ToUpper(method)] = operation
+			}
+		}
+		spec.paths = append(spec.paths, entry)
+	}
+	// Concrete paths take precedence over templated ones
+	sort.Slice(spec.paths, func(i, j int) bool {
+		if spec.paths[i].params != spec.paths[j].params {
+			return spec.paths[i].params < spec.paths[j].params
+		}
+		return spec.paths[i].template < spec.paths[j].template
+	})
+	return spec, nil
+}
+
+// compileTemplate turns a path template into a regexp where each {param} matches one segment
+func compileTemplate(template string) (*regexp.Regexp, int) {
+	var pattern strings.Builder
+	params := 0
+	pattern.WriteString("^")
+	for rest := template; rest != ""; {
+		start := strings.Index(rest, "{")
+		end := strings.Index(rest, "}")
+		if start < 0 || end < start {
+			pattern.WriteString(regexp.QuoteMeta(rest))
+			break
+		}
+		pattern.WriteString(regexp.QuoteMeta(rest[:start]))
+		pattern.WriteString("[^/]+")
+		params++
+		rest = rest[end+1:]
+	}
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String()), params
+}
+
+// Operations lists the spec's operations as "METHOD /template", sorted
+func (s *Spec) Operations() []string {
+	var operations []string
+	for _, item := range s.paths {
+		for method := range item.operations {
+			operations = append(operations, method+" "+item.template)
+		}
+	}
+	sort.Strings(operations)
+	return operations
+}
+
+// findPath returns the path item a request path is for, trying it with and without the base path
+func (s *Spec) findPath(path string) *pathItem {
+	candidates := []string{path}
+	if trimmed, ok := strings.CutPrefix(path, s.basePath); ok && s.basePath != "" {
+		if trimmed == "" {
+			trimmed = "/"
+		}
+		candidates = []string{trimmed, path}
+	}
+	for _, candidate := range candidates {
+		for _, item := range s.paths {
+			if item.pattern.MatchString(candidate) {
+				return item
+			}
+		}
+	}
+	return nil
+}
+
+// resolve follows a local $ref, such as #/components/schemas/User, until it reaches a value
+func (s *Spec) resolve(value interface{}) interface{} {
+	for depth := 0; depth < 32; depth++ {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return value
+		}
+		ref, ok := object["$ref"].(string)
+		if !ok {
+			return value
+		}
+		value = s.lookup(ref)
+	}
+	return nil
+}
+
+// lookup finds the value a local JSON pointer reference names
+func (s *Spec) lookup(ref string) interface{} {
+	pointer, ok := strings.CutPrefix(ref, "#")
+	if !ok {
+		return nil // References to other documents are not followed
+	}
+	var current interface{} = s.document
+	for _, token := range strings.Split(strings.TrimPrefix(pointer, "/"), "/") {
+		if token == "" {
+			continue
+		}
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch node := current.(type) {
+		case map[string]interface{}:
+			current = node[token]
+		default:
+			return nil
+		}
+	}
+	return current
+}
+
+// normalize converts decoded YAML to the shapes encoding/json produces: string-keyed maps and
+// float64 numbers, so bodies and schemas compare alike
+func normalize(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			v[key] = normalize(item)
+		}
+		return v
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted[fmt.Sprint(key)] = normalize(item)
+		}
+		return converted
+	case []interface{}:
+		for i, item := range v {
+			v[i] = normalize(item)
+		}
+		return v
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	case uint64:
+		return float64(v)
+	default:
+		return v
+	}
+}
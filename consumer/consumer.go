@@ -0,0 +1,193 @@
+// Package consumer attributes recorded interactions to the clients that made them, so one session
+// captured from a shared environment can be exported and mocked per consumer.
+package consumer
+
+import (
+	"context"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+
+	"mimic/config"
+	"mimic/storage"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// DefaultHeader names a request's consumer unless the proxy configures another header
+const DefaultHeader = "X-Mimic-Consumer"
+
+// Sources of a consumer name for requests that do not send the header
+const (
+	IdentityNone = "none"
+	IdentityIP   = "ip"  // The client's IP address
+	IdentityTLS  = "tls" // The common name of the client's certificate
+)
+
+// maxNameLength bounds consumer names taken from requests
+const maxNameLength = 128
+
+// Header returns the header a proxy reads consumer names from, in canonical form
+func Header(cfg config.ConsumerConfig) string {
+	if cfg.Header == "" {
+		return DefaultHeader
+	}
+	return http.CanonicalHeaderKey(cfg.Header)
+}
+
+// Internal reports whether a header is mimic's own, so it is neither forwarded upstream nor
+// recorded; headers an application already sends, such as X-Client-Id, are left alone
+func Internal(header string) bool {
+	return strings.HasPrefix(http.CanonicalHeaderKey(header), "X-Mimic-")
+}
+
+// FromRequest names the consumer of an HTTP request: the consumer header, or else the client's
+// identity when the proxy is configured to use it. It returns "" when the consumer is unknown.
+func FromRequest(r *http.Request, cfg config.ConsumerConfig) string {
+	if name := clean(r.Header.Get(Header(cfg))); name != "" {
+		return name
+	}
+	switch cfg.Identity {
+	case IdentityIP:
+		return hostOf(r.RemoteAddr)
+	case IdentityTLS:
+		if r.TLS != nil {
+			return commonName(r.TLS.PeerCertificates)
+		}
+	}
+	return ""
+}
+
+// FromContext names the consumer of a gRPC call from its metadata or, failing that, its peer
+func FromContext(ctx context.Context, cfg config.ConsumerConfig) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(Header(cfg)); len(values) > 0 {
+		if name := clean(values[0]); name != "" {
+			return name
+		}
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return ""
+	}
+	switch cfg.Identity {
+	case IdentityIP:
+		if p.Addr != nil {
+			return hostOf(p.Addr.String())
+		}
+	case IdentityTLS:
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			return commonName(info.State.PeerCertificates)
+		}
+	}
+	return ""
+}
+
+// Requested names the consumer whose slice of the session a mock answers an HTTP request from:
+// the one the request names in the consumer header, or else the configured serve. "" answers
+// from the whole session.
+func Requested(r *http.Request, cfg config.ConsumerConfig) string {
+	if name := clean(r.Header.Get(Header(cfg))); name != "" {
+		return name
+	}
+	return cfg.Serve
+}
+
+// RequestedFromContext is Requested for gRPC calls
+func RequestedFromContext(ctx context.Context, cfg config.ConsumerConfig) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(Header(cfg)); len(values) > 0 {
+		if name := clean(values[0]); name != "" {
+			return name
+		}
+	}
+	return cfg.Serve
+}
+
+// Tag attributes an interaction to a consumer; an empty consumer leaves it unattributed
+func Tag(interaction *storage.Interaction, consumer string) error {
+	if consumer == "" {
+		return nil
+	}
+	return interaction.SetMetadataValue(storage.MetadataConsumer, consumer)
+}
+
+// Filter returns the interactions made by a consumer, in order; an empty consumer keeps them all
+func Filter(interactions []storage.Interaction, consumer string) []storage.Interaction {
+	if consumer == "" {
+		return interactions
+	}
+	var slice []storage.Interaction
+	for _, interaction := range interactions {
+		if interaction.Consumer() == consumer {
+			slice = append(slice, interaction)
+		}
+	}
+	return slice
+}
+
+// Count is how many interactions of a session one consumer made
+type Count struct {
+	Consumer     string `json:"consumer"`
+	Interactions int    `json:"interactions"`
+}
+
+// Counts tallies interactions per consumer, sorted by name; unattributed ones are not counted
+func Counts(interactions []storage.Interaction) []Count {
+	tally := make(map[string]int)
+	for i := range interactions {
+		if name := interactions[i].Consumer(); name != "" {
+			tally[name]++
+		}
+	}
+	counts := make([]Count, 0, len(tally))
+	for name, n := range tally {
+		counts = append(counts, Count{Consumer: name, Interactions: n})
+	}
+	sort.Slice(counts, func(i, j int) bool { return counts[i].Consumer < counts[j].Consumer })
+	return counts
+}
+
+// Names lists the consumers interactions are attributed to, sorted
+func Names(interactions []storage.Interaction) []string {
+	counts := Counts(interactions)
+	names := make([]string, len(counts))
+	for i, count := range counts {
+		names[i] = count.Consumer
+	}
+	return names
+}
+
+// clean trims a consumer name taken from a request, dropping control characters and bounding
+// its length
+func clean(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if len(name) > maxNameLength {
+		name = strings.ToValidUTF8(name[:maxNameLength], "")
+	}
+	return name
+}
+
+func hostOf(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return address
+	}
+	return host
+}
+
+func commonName(certificates []*x509.Certificate) string {
+	if len(certificates) == 0 {
+		return ""
+	}
+	return clean(certificates[0].Subject.CommonName)
+}
@@ -0,0 +1,85 @@
+package consumer
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestFromRequest(t *testing.T) {
+	withCert := &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "checkout.internal"}}}}
+	tests := []struct {
+		name     string
+		cfg      config.ConsumerConfig
+		header   http.Header
+		tls      *tls.ConnectionState
+		expected string
+	}{
+		{"default header", config.ConsumerConfig{}, http.Header{"X-Mimic-Consumer": {" checkout "}}, nil, "checkout"},
+		{"custom header", config.ConsumerConfig{Header: "x-client-id"}, http.Header{"X-Client-Id": {"search"}}, nil, "search"},
+		{"no identity", config.ConsumerConfig{}, http.Header{}, nil, ""},
+		{"ip", config.ConsumerConfig{Identity: IdentityIP}, http.Header{}, nil, "10.0.0.7"},
+		{"header wins over ip", config.ConsumerConfig{Identity: IdentityIP}, http.Header{"X-Mimic-Consumer": {"checkout"}}, nil, "checkout"},
+		{"tls", config.ConsumerConfig{Identity: IdentityTLS}, http.Header{}, withCert, "checkout.internal"},
+		{"tls without certificate", config.ConsumerConfig{Identity: IdentityTLS}, http.Header{}, nil, ""},
+		{"control characters", config.ConsumerConfig{}, http.Header{"X-Mimic-Consumer": {"check\x00out\n"}}, nil, "checkout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{Header: tt.header, RemoteAddr: "10.0.0.7:52114", TLS: tt.tls}
+			if got := FromRequest(r, tt.cfg); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRequested(t *testing.T) {
+	cfg := config.ConsumerConfig{Identity: IdentityIP, Serve: "checkout"}
+	r := &http.Request{Header: http.Header{}, RemoteAddr: "10.0.0.7:52114"}
+	if got := Requested(r, cfg); got != "checkout" {
+		t.Errorf("Expected the served consumer rather than the client IP, got %q", got)
+	}
+	r.Header.Set("X-Mimic-Consumer", "search")
+	if got := Requested(r, cfg); got != "search" {
+		t.Errorf("Expected the consumer the request names, got %q", got)
+	}
+}
+
+func TestInternal(t *testing.T) {
+	if !Internal("x-mimic-consumer") || Internal("X-Client-Id") {
+		t.Error("Expected only X-Mimic- headers to be internal")
+	}
+}
+
+func TestFilterAndCounts(t *testing.T) {
+	var interactions []storage.Interaction
+	for i, name := range []string{"search", "checkout", "", "search"} {
+		interaction := storage.Interaction{ID: i + 1}
+		if err := Tag(&interaction, name); err != nil {
+			t.Fatalf("Tag failed: %v", err)
+		}
+		interactions = append(interactions, interaction)
+	}
+
+	slice := Filter(interactions, "search")
+	if len(slice) != 2 || slice[0].ID != 1 || slice[1].ID != 4 {
+		t.Errorf("Expected interactions 1 and 4, got %+v", slice)
+	}
+	if len(Filter(interactions, "")) != 4 {
+		t.Error("Expected an empty consumer to keep every interaction")
+	}
+	expected := []Count{{Consumer: "checkout", Interactions: 1}, {Consumer: "search", Interactions: 2}}
+	if got := Counts(interactions); !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, got)
+	}
+	if got := Names(interactions); !reflect.DeepEqual(got, []string{"checkout", "search"}) {
+		t.Errorf("Expected checkout and search, got %v", got)
+	}
+}
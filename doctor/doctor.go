@@ -0,0 +1,422 @@
+// Package doctor checks the environment mimic is about to run in: the config, the database,
+// the listen ports, and whether each configured target can be reached.
+package doctor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"mimic/config"
+	"mimic/conformance"
+	"mimic/protoschema"
+	"mimic/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	StatusOK Status = iota
+	StatusWarn
+	StatusFail
+)
+
+func (s Status) String() string {
+	switch s {
+	case StatusOK:
+		return "ok"
+	case StatusWarn:
+		return "warn"
+	default:
+		return "fail"
+	}
+}
+
+// MarshalText encodes a status by name, as in JSON reports
+func (s Status) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+// Finding is the result of one check, with a hint on how to fix it when it did not pass
+type Finding struct {
+	Check   string `json:"check"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+// Report is the findings of a run, in the order the checks ran
+type Report []Finding
+
+// Failed reports whether any check failed
+func (r Report) Failed() bool {
+	for _, finding := range r {
+		if finding.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultTimeout bounds each network probe
+const DefaultTimeout = 5 * time.Second
+
+// Run checks cfg and the environment, spending at most timeout on each network probe
+func Run(cfg *config.Config, timeout time.Duration) Report {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+
+	var report Report
+	report = append(report, checkConfig(cfg)...)
+	report = append(report, checkDatabase(cfg.Database)...)
+	report = append(report, checkPorts(cfg)...)
+	report = append(report, checkTLS(cfg.Server.TLS)...)
+	report = append(report, checkDescriptors(cfg.GRPC, timeout)...)
+	report = append(report, checkOpenAPISpecs(cfg)...)
+	report = append(report, checkTargets(cfg, timeout)...)
+	return report
+}
+
+// checkConfig validates cfg, warning about the unknown and deprecated keys found when it was loaded
+func checkConfig(cfg *config.Config) []Finding {
+	if err := cfg.Validate(); err != nil {
+		return []Finding{{Check: "config", Status: StatusFail, Message: err.Error(), Hint: "Fix the setting in the config file, or the flag or MIMIC_* variable that overrides it"}}
+	}
+	findings := []Finding{{Check: "config", Status: StatusOK, Message: fmt.Sprintf("valid, mode %s, %d proxies", cfg.Mode, len(cfg.Proxies))}}
+	for _, warning := range cfg.Warnings {
+		findings = append(findings, Finding{Check: "config " + warning.Key, Status: StatusWarn, Message: warning.Message,
+			Hint: "Fix or remove the key; mimic migrate-config rewrites deprecated keys"})
+	}
+	return findings
+}
+
+// checkDatabase opens the database and checks its integrity and schema; a SQLite file that does not
+// exist yet is reported rather than created
+func checkDatabase(cfg config.DatabaseConfig) []Finding {
+	if cfg.Driver == "" || cfg.Driver == storage.DriverSQLite {
+		path, err := config.ExpandHome(cfg.Path)
+		if err != nil {
+			return []Finding{{Check: "database", Status: StatusFail, Message: err.Error()}}
+		}
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return []Finding{{Check: "database", Status: StatusOK, Message: fmt.Sprintf("%s does not exist yet and will be created on first run", path)}}
+		}
+	}
+
+	db, err := storage.Open(cfg)
+	if err != nil {
+		return []Finding{{Check: "database", Status: StatusFail, Message: err.Error(), Hint: "Check database.path or database.url and that mimic can write there"}}
+	}
+	defer db.Close()
+
+	findings := []Finding{}
+	if err := db.IntegrityCheck(); err != nil {
+		findings = append(findings, Finding{Check: "database", Status: StatusFail, Message: err.Error(), Hint: "Restore from a backup, or export what is readable and start a new database"})
+	} else {
+		findings = append(findings, Finding{Check: "database", Status: StatusOK, Message: "integrity check passed"})
+	}
+
+	version, err := db.SchemaVersion()
+	switch {
+	case err != nil:
+		findings = append(findings, Finding{Check: "schema", Status: StatusFail, Message: err.Error()})
+	case version > storage.SchemaVersion:
+		findings = append(findings, Finding{Check: "schema", Status: StatusFail,
+			Message: fmt.Sprintf("database schema version %d is newer than this build supports (%d)", version, storage.SchemaVersion),
+			Hint:    "Upgrade mimic to match the other replicas using this database"})
+	default:
+		findings = append(findings, Finding{Check: "schema", Status: StatusOK, Message: fmt.Sprintf("version %d", version)})
+	}
+
+	if missing := db.MissingColumns(); len(missing) > 0 {
+		findings = append(findings, Finding{Check: "schema", Status: StatusFail,
+			Message: "missing columns: " + strings.Join(missing, ", "),
+			Hint:    "The database predates this build; export its sessions and import them into a new database"})
+	}
+
+	if files := db.BodyFiles(); files != nil {
+		if writer, err := files.Create(); err != nil {
+			findings = append(findings, Finding{Check: "body files", Status: StatusFail, Message: err.Error(),
+				Hint: "Large bodies are recorded truncated until database.body_dir is writable"})
+		} else {
+			writer.Abort()
+			findings = append(findings, Finding{Check: "body files", Status: StatusOK, Message: fmt.Sprintf("bodies over %d bytes go to %s", files.Threshold(), files.Dir())})
+		}
+	}
+	return findings
+}
+
+// checkPorts verifies the ports mimic will listen on are free
+func checkPorts(cfg *config.Config) []Finding {
+	type port struct {
+		setting string
+		port    int
+	}
+	ports := []port{{"server.listen_port", cfg.Server.ListenPort}}
+	if hasGRPCProxy(cfg) {
+		ports = append(ports, port{"server.grpc_port", cfg.Server.GRPCPort})
+	}
+	if cfg.Server.AdminGRPCPort > 0 {
+		ports = append(ports, port{"server.admin_grpc_port", cfg.Server.AdminGRPCPort})
+	}
+
+	var findings []Finding
+	for _, p := range ports {
+		if p.port <= 0 {
+			continue
+		}
+		address := net.JoinHostPort(cfg.Server.ListenHost, strconv.Itoa(p.port))
+		check := "port " + strconv.Itoa(p.port)
+		listener, err := net.Listen("tcp", address)
+		if err != nil {
+			findings = append(findings, Finding{Check: check, Status: StatusFail, Message: err.Error(),
+				Hint: fmt.Sprintf("Stop whatever is listening on %s (is mimic already running?) or change %s", address, p.setting)})
+			continue
+		}
+		listener.Close()
+		findings = append(findings, Finding{Check: check, Status: StatusOK, Message: fmt.Sprintf("%s is free", address)})
+	}
+	return findings
+}
+
+// checkDescriptors loads the configured descriptor sets and Buf modules the way the server will
+func checkDescriptors(cfg config.GRPCConfig, timeout time.Duration) []Finding {
+	if len(cfg.DescriptorSets) == 0 && len(cfg.BufModules) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	registry := protoschema.NewRegistry()
+	var findings []Finding
+	for _, err := range registry.Load(ctx, cfg.DescriptorSets, cfg.BufModules) {
+		findings = append(findings, Finding{Check: "grpc descriptors", Status: StatusWarn, Message: err.Error(),
+			Hint: "gRPC messages of the affected services are shown as raw bytes; for private Buf modules set " + protoschema.BufTokenEnv})
+	}
+	if services := registry.Services(); len(services) > 0 {
+		findings = append(findings, Finding{Check: "grpc descriptors", Status: StatusOK, Message: fmt.Sprintf("%d services: %s", len(services), strings.Join(services, ", "))})
+	}
+	return findings
+}
+
+// checkOpenAPISpecs parses the openapi_spec of each proxy that has one
+func checkOpenAPISpecs(cfg *config.Config) []Finding {
+	names := make([]string, 0, len(cfg.Proxies))
+	for name, proxyConfig := range cfg.Proxies {
+		if proxyConfig.OpenAPISpec != "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var findings []Finding
+	for _, name := range names {
+		spec, err := conformance.LoadSpec(cfg.Proxies[name].OpenAPISpec)
+		if err != nil {
+			findings = append(findings, Finding{Check: "openapi " + name, Status: StatusWarn, Message: err.Error(),
+				Hint: "Conformance checks are off for this proxy until openapi_spec points at a valid OpenAPI 3 document"})
+			continue
+		}
+		title := strings.TrimSpace(spec.Title + " " + spec.Version)
+		if title == "" {
+			title = "untitled spec"
+		}
+		findings = append(findings, Finding{Check: "openapi " + name, Status: StatusOK, Message: fmt.Sprintf("%s, %d operations", title, len(spec.Operations()))})
+	}
+	return findings
+}
+
+// certificateExpiryWarning is how close to expiry a serving certificate is reported
+const certificateExpiryWarning = 30 * 24 * time.Hour
+
+// checkTLS loads the certificate the listeners serve and reports when it expires; a self-signed
+// certificate whose files do not exist yet is generated on startup
+func checkTLS(cfg config.TLSConfig) []Finding {
+	if !cfg.Enabled() {
+		return nil
+	}
+	certFile, err := config.ExpandHome(cfg.CertFile)
+	if err != nil {
+		return []Finding{{Check: "tls", Status: StatusFail, Message: err.Error()}}
+	}
+	keyFile, err := config.ExpandHome(cfg.KeyFile)
+	if err != nil {
+		return []Finding{{Check: "tls", Status: StatusFail, Message: err.Error()}}
+	}
+	if cfg.SelfSigned {
+		if _, err := os.Stat(certFile); certFile == "" || os.IsNotExist(err) {
+			return []Finding{{Check: "tls", Status: StatusOK, Message: "a self-signed certificate will be generated on startup"}}
+		}
+	}
+
+	pair, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return []Finding{{Check: "tls", Status: StatusFail, Message: err.Error(),
+			Hint: "Point server.tls.cert_file and key_file at a PEM certificate and its key, or set self_signed"}}
+	}
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return []Finding{{Check: "tls", Status: StatusFail, Message: err.Error()}}
+	}
+	remaining := time.Until(certificate.NotAfter)
+	message := fmt.Sprintf("%s, valid until %s", certFile, certificate.NotAfter.Format("2006-01-02"))
+	switch {
+	case remaining <= 0:
+		hint := "Replace the certificate"
+		if cfg.SelfSigned {
+			hint = "Delete the certificate and key files so a new self-signed certificate is generated"
+		}
+		return []Finding{{Check: "tls", Status: StatusFail, Message: "certificate expired: " + message, Hint: hint}}
+	case remaining < certificateExpiryWarning:
+		return []Finding{{Check: "tls", Status: StatusWarn, Message: "certificate expires soon: " + message}}
+	}
+	return []Finding{{Check: "tls", Status: StatusOK, Message: message}}
+}
+
+// checkTargets probes each proxy's target, and the replay target when replaying
+func checkTargets(cfg *config.Config, timeout time.Duration) []Finding {
+	names := make([]string, 0, len(cfg.Proxies))
+	for name := range cfg.Proxies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []Finding
+	for _, name := range names {
+		proxyConfig := cfg.Proxies[name]
+		if proxyConfig.TargetHost == "" {
+			continue
+		}
+		probed := probeTarget("proxy "+name, proxyConfig.TargetHost, proxyConfig.TargetPort,
+			proxyConfig.Protocol, proxyConfig.OutboundProxy, proxyConfig.Transport.InsecureSkipVerify, timeout)
+
+		// Mocks answer from recordings, so an unreachable target only matters for later recording
+		if proxyConfig.EffectiveMode(cfg.Mode) == "mock" {
+			for i := range probed {
+				if probed[i].Status == StatusFail {
+					probed[i].Status = StatusWarn
+				}
+			}
+		}
+		findings = append(findings, probed...)
+	}
+
+	if cfg.Mode == "replay" && cfg.Replay.TargetHost != "" {
+		findings = append(findings, probeTarget("replay", cfg.Replay.TargetHost, cfg.Replay.TargetPort,
+			cfg.Replay.Protocol, cfg.Replay.OutboundProxy, cfg.Replay.InsecureSkipVerify, timeout)...)
+	}
+	return findings
+}
+
+// probeTarget resolves the target, connects to it, and for TLS and gRPC targets goes one step further;
+// insecure accepts any certificate, as the proxy or replay does when told to
+func probeTarget(check, host string, port int, protocol, outboundProxy string, insecure bool, timeout time.Duration) []Finding {
+	address := net.JoinHostPort(host, strconv.Itoa(port))
+	if outboundProxy != "" && outboundProxy != "none" {
+		return []Finding{{Check: check, Status: StatusWarn,
+			Message: fmt.Sprintf("%s not probed, it is reached through %s", address, outboundProxy),
+			Hint:    "Probe the target from the egress proxy's side if requests fail"}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	if err != nil {
+		return []Finding{{Check: check, Status: StatusFail, Message: fmt.Sprintf("cannot resolve %s: %v", host, err),
+			Hint: "Check target_host for typos and that this machine's DNS can see it"}}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return []Finding{{Check: check, Status: StatusFail, Message: fmt.Sprintf("cannot connect to %s (%s): %v", address, strings.Join(addrs, ", "), err),
+			Hint: "Check target_port, that the service is up, and that no firewall is in the way; set outbound_proxy if egress needs one"}}
+	}
+	conn.Close()
+
+	useTLS := protocol == "https" || port == 443
+	if useTLS {
+		tlsConn, err := tls.DialWithDialer(dialer, "tcp", address, &tls.Config{ServerName: host, InsecureSkipVerify: insecure})
+		if err != nil {
+			return []Finding{{Check: check, Status: StatusFail, Message: fmt.Sprintf("TLS handshake with %s failed: %v", address, err),
+				Hint: "Check the target's certificate; a plain-text target needs protocol http and a port other than 443"}}
+		}
+		tlsConn.Close()
+	}
+
+	if protocol == "grpc" {
+		return []Finding{probeReflection(ctx, check, address, host, useTLS, insecure)}
+	}
+
+	message := fmt.Sprintf("%s is reachable", address)
+	if useTLS {
+		message += " over TLS"
+	}
+	return []Finding{{Check: check, Status: StatusOK, Message: message}}
+}
+
+// probeReflection asks a gRPC target to list its services; reflection is optional, so its absence only warns
+func probeReflection(ctx context.Context, check, address, host string, useTLS, skipVerify bool) Finding {
+	creds := insecure.NewCredentials()
+	if useTLS {
+		creds = credentials.NewTLS(&tls.Config{ServerName: host, InsecureSkipVerify: skipVerify})
+	}
+
+	conn, err := grpc.DialContext(ctx, address, grpc.WithTransportCredentials(creds))
+	if err != nil {
+		return Finding{Check: check, Status: StatusFail, Message: fmt.Sprintf("cannot open a gRPC connection to %s: %v", address, err)}
+	}
+	defer conn.Close()
+
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err == nil {
+		err = stream.Send(&reflectionpb.ServerReflectionRequest{
+			MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{ListServices: "*"},
+		})
+	}
+	var response *reflectionpb.ServerReflectionResponse
+	if err == nil {
+		response, err = stream.Recv()
+	}
+	if err != nil {
+		if status.Code(err) == codes.Unimplemented {
+			return Finding{Check: check, Status: StatusWarn, Message: fmt.Sprintf("%s is reachable but does not serve reflection", address),
+				Hint: "Calls are still proxied; set grpc.descriptor_sets or grpc.buf_modules to see decoded messages"}
+		}
+		return Finding{Check: check, Status: StatusFail, Message: fmt.Sprintf("gRPC call to %s failed: %v", address, err),
+			Hint: "Check that the target speaks gRPC and whether it expects TLS"}
+	}
+	stream.CloseSend()
+
+	var services []string
+	for _, service := range response.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	return Finding{Check: check, Status: StatusOK, Message: fmt.Sprintf("%s serves %d services via reflection: %s", address, len(services), strings.Join(services, ", "))}
+}
+
+func hasGRPCProxy(cfg *config.Config) bool {
+	for _, proxyConfig := range cfg.Proxies {
+		if proxyConfig.Protocol == "grpc" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+package doctor
+
+import (
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to find a free port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func testConfig(t *testing.T) *config.Config {
+	cfg := config.DefaultConfig()
+	cfg.Mode = "record"
+	cfg.Server.ListenHost = "127.0.0.1"
+	cfg.Server.ListenPort = freePort(t)
+	cfg.Server.GRPCPort = freePort(t)
+	cfg.Database.Path = filepath.Join(t.TempDir(), "mimic.db")
+	cfg.Proxies = map[string]config.ProxyConfig{}
+	return cfg
+}
+
+func TestRunPassesOnAHealthyEnvironment(t *testing.T) {
+	target, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			conn.Close()
+		}
+	}()
+
+	cfg := testConfig(t)
+	cfg.Proxies["api"] = config.ProxyConfig{TargetHost: "127.0.0.1", TargetPort: target.Addr().(*net.TCPAddr).Port, Protocol: "http", SessionName: "default"}
+
+	// An existing database gets its integrity and schema checked
+	db, err := storage.Open(cfg.Database)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	db.Close()
+
+	report := Run(cfg, time.Second)
+	if report.Failed() {
+		t.Fatalf("Expected no failures, got %+v", report)
+	}
+	if !hasFinding(report, "proxy api", StatusOK) || !hasFinding(report, "schema", StatusOK) {
+		t.Errorf("Expected the target to be reported reachable, got %+v", report)
+	}
+}
+
+func TestRunReportsBusyPortAndUnreachableTarget(t *testing.T) {
+	cfg := testConfig(t)
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer busy.Close()
+	cfg.Server.ListenPort = busy.Addr().(*net.TCPAddr).Port
+	cfg.Proxies["api"] = config.ProxyConfig{TargetHost: "127.0.0.1", TargetPort: freePort(t), Protocol: "http", SessionName: "default"}
+
+	report := Run(cfg, time.Second)
+	if !report.Failed() {
+		t.Fatal("Expected the report to fail")
+	}
+	for _, check := range []string{"port " + strconv.Itoa(cfg.Server.ListenPort), "proxy api"} {
+		if !hasFinding(report, check, StatusFail) {
+			t.Errorf("Expected %s to fail, got %+v", check, report)
+		}
+	}
+}
+
+func TestProbeReflection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	server := grpc.NewServer()
+	reflection.Register(server)
+	go server.Serve(listener)
+	defer server.Stop()
+
+	port := listener.Addr().(*net.TCPAddr).Port
+	findings := probeTarget("proxy users", "127.0.0.1", port, "grpc", "", false, time.Second)
+	if len(findings) != 1 || findings[0].Status != StatusOK || !strings.Contains(findings[0].Message, "grpc.reflection") {
+		t.Errorf("Expected reflection to list its own service, got %+v", findings)
+	}
+}
+
+func hasFinding(report Report, check string, status Status) bool {
+	for _, finding := range report {
+		if finding.Check == check && finding.Status == status {
+			return true
+		}
+	}
+	return false
+}
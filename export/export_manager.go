@@ -2,6 +2,7 @@ package export
 
 import (
 	"compress/gzip"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,37 +11,75 @@ import (
 	"strings"
 
 	"mimic/config"
+	"mimic/consumer"
+	"mimic/protoschema"
+	"mimic/redact"
 	"mimic/storage"
+	"mimic/tag"
+
+	"github.com/gorilla/websocket"
 )
 
 type ExportManager struct {
 	config   *config.Config
 	database *storage.Database
+	redactor *redact.Redactor // Masks secrets in what is exported, including sessions recorded before redaction was set up
 }
 
 func NewExportManager(cfg *config.Config, db *storage.Database) *ExportManager {
+	// Unknown presets are rejected when the config is loaded
+	redactor, _ := redact.New(cfg.Recording.RedactPatterns, cfg.Recording.RedactPresets)
 	return &ExportManager{
 		config:   cfg,
 		database: db,
+		redactor: redactor,
 	}
 }
 
 func (e *ExportManager) ExportSession(sessionName, outputPath string) error {
+	_, err := e.ExportConsumer(sessionName, "", outputPath)
+	return err
+}
+
+// ExportConsumer exports the interactions of a session made by one consumer, so a session recorded
+// in a shared environment can become each team's own fixtures. An empty consumer exports the whole
+// session. It returns how many interactions were exported.
+func (e *ExportManager) ExportConsumer(sessionName, consumerName, outputPath string) (int, error) {
+	return e.ExportSlice(sessionName, Slice{Consumer: consumerName}, outputPath)
+}
+
+// Slice picks part of a session to export; a zero Slice is the whole session
+type Slice struct {
+	Consumer string // Only the interactions of this consumer
+	Tag      string // Only the interactions recorded with this X-Mimic-Tag
+}
+
+// ExportSlice exports the interactions of a session in a slice, such as one tagged scenario of a
+// recording run. It returns how many interactions were exported.
+func (e *ExportManager) ExportSlice(sessionName string, slice Slice, outputPath string) (int, error) {
 	session, err := e.database.GetSession(sessionName)
 	if err != nil {
-		return fmt.Errorf("failed to get session: %w", err)
+		return 0, fmt.Errorf("failed to get session: %w", err)
 	}
 
 	interactions, err := e.database.GetInteractionsBySession(session.ID)
 	if err != nil {
-		return fmt.Errorf("failed to get interactions: %w", err)
+		return 0, fmt.Errorf("failed to get interactions: %w", err)
+	}
+	interactions = consumer.Filter(interactions, slice.Consumer)
+	if slice.Consumer != "" && len(interactions) == 0 {
+		return 0, fmt.Errorf("session '%s' has no interactions from consumer '%s'", sessionName, slice.Consumer)
+	}
+	interactions = tag.Filter(interactions, slice.Tag)
+	if slice.Tag != "" && len(interactions) == 0 {
+		return 0, fmt.Errorf("session '%s' has no interactions tagged '%s'", sessionName, slice.Tag)
 	}
 
 	exportInteractions := make([]storage.ExportInteraction, len(interactions))
 	for i, interaction := range interactions {
 		exportInteraction, err := e.convertToExportInteraction(interaction)
 		if err != nil {
-			return fmt.Errorf("failed to convert interaction %d: %w", interaction.ID, err)
+			return 0, fmt.Errorf("failed to convert interaction %d: %w", interaction.ID, err)
 		}
 		exportInteractions[i] = exportInteraction
 	}
@@ -48,10 +87,25 @@ func (e *ExportManager) ExportSession(sessionName, outputPath string) error {
 	exportData := storage.ExportData{
 		Version:      "1.0",
 		Session:      *session,
+		Consumer:     slice.Consumer,
+		Tag:          slice.Tag,
 		Interactions: exportInteractions,
 	}
 
-	return e.writeExportData(exportData, outputPath)
+	return len(interactions), e.writeExportData(exportData, outputPath)
+}
+
+// Consumers lists the consumers a session's interactions are attributed to, sorted
+func (e *ExportManager) Consumers(sessionName string) ([]string, error) {
+	session, err := e.database.GetSession(sessionName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+	interactions, err := e.database.GetInteractionsBySession(session.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get interactions: %w", err)
+	}
+	return consumer.Names(interactions), nil
 }
 
 func (e *ExportManager) ImportSession(inputPath, sessionName, mergeStrategy string) error {
@@ -80,6 +134,13 @@ func (e *ExportManager) ImportSession(inputPath, sessionName, mergeStrategy stri
 		mergeStrategy = "append"
 	}
 
+	// Create the session up front so its notes survive the round trip
+	if _, err := e.database.GetSession(targetSessionName); err != nil {
+		if _, err := e.database.CreateSession(targetSessionName, exportData.Session.Description); err != nil {
+			return fmt.Errorf("failed to create session: %w", err)
+		}
+	}
+
 	// Import interactions one by one, handling stream chunks for streaming interactions
 	for i, exportInteraction := range exportData.Interactions {
 		interaction, err := e.convertFromExportInteraction(exportInteraction)
@@ -88,7 +149,15 @@ func (e *ExportManager) ImportSession(inputPath, sessionName, mergeStrategy stri
 		}
 
 		// If this is a streaming interaction with chunks, use the specialized import method
-		if exportInteraction.IsStreaming && len(exportInteraction.StreamChunks) > 0 {
+		if len(exportInteraction.Frames) > 0 {
+			frames, err := importFrames(exportInteraction.Frames)
+			if err != nil {
+				return fmt.Errorf("failed to convert frames of interaction %d: %w", i, err)
+			}
+			if err := e.database.ImportInteractionWithFrames(targetSessionName, interaction, frames); err != nil {
+				return fmt.Errorf("failed to import WebSocket interaction %d: %w", i, err)
+			}
+		} else if exportInteraction.IsStreaming && len(exportInteraction.StreamChunks) > 0 {
 			// Convert export chunks to storage chunks
 			chunks := make([]storage.StreamChunk, len(exportInteraction.StreamChunks))
 			for j, exportChunk := range exportInteraction.StreamChunks {
@@ -117,31 +186,35 @@ func (e *ExportManager) ImportSession(inputPath, sessionName, mergeStrategy stri
 func (e *ExportManager) convertToExportInteraction(interaction storage.Interaction) (storage.ExportInteraction, error) {
 	var requestHeaders map[string]string
 	if interaction.RequestHeaders != "" {
-		if err := json.Unmarshal([]byte(interaction.RequestHeaders), &requestHeaders); err != nil {
+		if err := json.Unmarshal([]byte(e.redactor.Headers(interaction.RequestHeaders)), &requestHeaders); err != nil {
 			return storage.ExportInteraction{}, fmt.Errorf("failed to unmarshal request headers: %w", err)
 		}
 	}
 
 	var responseHeaders map[string]string
 	if interaction.ResponseHeaders != "" {
-		if err := json.Unmarshal([]byte(interaction.ResponseHeaders), &responseHeaders); err != nil {
+		if err := json.Unmarshal([]byte(e.redactor.Headers(interaction.ResponseHeaders)), &responseHeaders); err != nil {
 			return storage.ExportInteraction{}, fmt.Errorf("failed to unmarshal response headers: %w", err)
 		}
 	}
 
-	var requestBody interface{}
-	if len(interaction.RequestBody) > 0 {
-		if err := json.Unmarshal(interaction.RequestBody, &requestBody); err != nil {
-			requestBody = string(interaction.RequestBody)
+	// Exports are self-contained, so bodies kept in body files are written inline
+	if err := e.database.LoadBodyFiles(&interaction); err != nil {
+		return storage.ExportInteraction{}, err
+	}
+	for _, key := range []string{storage.MetadataRequestBodyFile, storage.MetadataResponseBodyFile, storage.MetadataRequestBodySize, storage.MetadataResponseBodySize} {
+		if err := interaction.DeleteMetadataValue(key); err != nil {
+			return storage.ExportInteraction{}, err
 		}
 	}
 
-	var responseBody interface{}
-	if len(interaction.ResponseBody) > 0 {
-		if err := json.Unmarshal(interaction.ResponseBody, &responseBody); err != nil {
-			responseBody = string(interaction.ResponseBody)
-		}
+	// gRPC messages are binary and left as they are, the way they are recorded
+	if interaction.Protocol != "gRPC" {
+		interaction.RequestBody = e.redactor.Bytes(interaction.RequestBody)
+		interaction.ResponseBody = e.redactor.Bytes(interaction.ResponseBody)
 	}
+	requestBody := exportBody(interaction, protoschema.Request, interaction.RequestBody)
+	responseBody := exportBody(interaction, protoschema.Response, interaction.ResponseBody)
 
 	exportInteraction := storage.ExportInteraction{
 		RequestID: interaction.RequestID,
@@ -162,6 +235,10 @@ func (e *ExportManager) convertToExportInteraction(interaction storage.Interacti
 		IsStreaming:    interaction.IsStreaming,
 	}
 
+	if metadata := interaction.MetadataMap(); len(metadata) > 0 {
+		exportInteraction.Metadata = metadata
+	}
+
 	// If this is a streaming interaction, fetch and include the stream chunks
 	if interaction.IsStreaming {
 		chunks, err := e.database.GetStreamChunks(interaction.ID)
@@ -173,16 +250,71 @@ func (e *ExportManager) convertToExportInteraction(interaction storage.Interacti
 		for i, chunk := range chunks {
 			exportChunks[i] = storage.ExportStreamChunk{
 				ChunkIndex: chunk.ChunkIndex,
-				Data:       string(chunk.Data),
+				Data:       string(e.redactor.Bytes(chunk.Data)),
 				TimeDelta:  chunk.TimeDelta,
 			}
 		}
 		exportInteraction.StreamChunks = exportChunks
 	}
 
+	if interaction.WebSocket() {
+		frames, err := e.database.GetWebSocketFrames(interaction.ID)
+		if err != nil {
+			return storage.ExportInteraction{}, fmt.Errorf("failed to get WebSocket frames: %w", err)
+		}
+		for i := range frames {
+			if frames[i].MessageType == websocket.TextMessage {
+				frames[i].Data = e.redactor.Bytes(frames[i].Data)
+			}
+		}
+		exportInteraction.Frames = exportFrames(frames)
+	}
+
 	return exportInteraction, nil
 }
 
+// exportFrames converts WebSocket frames for export, with binary messages in base64
+func exportFrames(frames []storage.WebSocketFrame) []storage.ExportWebSocketFrame {
+	exported := make([]storage.ExportWebSocketFrame, len(frames))
+	for i, frame := range frames {
+		data := string(frame.Data)
+		if frame.MessageType == websocket.BinaryMessage {
+			data = base64.StdEncoding.EncodeToString(frame.Data)
+		}
+		exported[i] = storage.ExportWebSocketFrame{
+			FrameIndex:  frame.FrameIndex,
+			Direction:   frame.Direction,
+			MessageType: frame.MessageType,
+			Data:        data,
+			TimeDelta:   frame.TimeDelta,
+		}
+	}
+	return exported
+}
+
+// importFrames converts exported WebSocket frames back, decoding binary messages
+func importFrames(exported []storage.ExportWebSocketFrame) ([]storage.WebSocketFrame, error) {
+	frames := make([]storage.WebSocketFrame, len(exported))
+	for i, frame := range exported {
+		data := []byte(frame.Data)
+		if frame.MessageType == websocket.BinaryMessage {
+			decoded, err := base64.StdEncoding.DecodeString(frame.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode frame %d: %w", frame.FrameIndex, err)
+			}
+			data = decoded
+		}
+		frames[i] = storage.WebSocketFrame{
+			FrameIndex:  frame.FrameIndex,
+			Direction:   frame.Direction,
+			MessageType: frame.MessageType,
+			Data:        data,
+			TimeDelta:   frame.TimeDelta,
+		}
+	}
+	return frames, nil
+}
+
 func (e *ExportManager) convertFromExportInteraction(exportInteraction storage.ExportInteraction) (storage.Interaction, error) {
 	requestHeaders, err := json.Marshal(exportInteraction.Request.Headers)
 	if err != nil {
@@ -194,28 +326,23 @@ func (e *ExportManager) convertFromExportInteraction(exportInteraction storage.E
 		return storage.Interaction{}, fmt.Errorf("failed to marshal response headers: %w", err)
 	}
 
-	var requestBody []byte
-	if exportInteraction.Request.Body != nil {
-		if str, ok := exportInteraction.Request.Body.(string); ok {
-			requestBody = []byte(str)
-		} else {
-			requestBody, err = json.Marshal(exportInteraction.Request.Body)
-			if err != nil {
-				return storage.Interaction{}, fmt.Errorf("failed to marshal request body: %w", err)
-			}
-		}
+	requestBody, err := importBody(exportInteraction, protoschema.Request, exportInteraction.Request.Body)
+	if err != nil {
+		return storage.Interaction{}, fmt.Errorf("failed to marshal request body: %w", err)
 	}
 
-	var responseBody []byte
-	if exportInteraction.Response.Body != nil {
-		if str, ok := exportInteraction.Response.Body.(string); ok {
-			responseBody = []byte(str)
-		} else {
-			responseBody, err = json.Marshal(exportInteraction.Response.Body)
-			if err != nil {
-				return storage.Interaction{}, fmt.Errorf("failed to marshal response body: %w", err)
-			}
+	responseBody, err := importBody(exportInteraction, protoschema.Response, exportInteraction.Response.Body)
+	if err != nil {
+		return storage.Interaction{}, fmt.Errorf("failed to marshal response body: %w", err)
+	}
+
+	var metadata string
+	if len(exportInteraction.Metadata) > 0 {
+		metadataBytes, err := json.Marshal(exportInteraction.Metadata)
+		if err != nil {
+			return storage.Interaction{}, fmt.Errorf("failed to marshal metadata: %w", err)
 		}
+		metadata = string(metadataBytes)
 	}
 
 	return storage.Interaction{
@@ -231,9 +358,49 @@ func (e *ExportManager) convertFromExportInteraction(exportInteraction storage.E
 		Timestamp:       exportInteraction.Timestamp,
 		SequenceNumber:  exportInteraction.SequenceNumber,
 		IsStreaming:     exportInteraction.IsStreaming,
+		Metadata:        metadata,
 	}, nil
 }
 
+// exportBody returns a body as JSON when it is JSON, or as a string otherwise. gRPC messages
+// whose descriptor is loaded are exported as protobuf JSON rather than raw bytes.
+func exportBody(interaction storage.Interaction, direction protoschema.Direction, body []byte) interface{} {
+	if len(body) == 0 {
+		return nil
+	}
+	if interaction.Protocol == "gRPC" {
+		if decoded, err := protoschema.Default.Decode(interaction.Method, direction, body); err == nil {
+			body = decoded
+		}
+	}
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return string(body)
+	}
+	return value
+}
+
+// importBody reverses exportBody, encoding gRPC messages given as JSON back to protobuf when
+// their descriptor is loaded
+func importBody(exportInteraction storage.ExportInteraction, direction protoschema.Direction, body interface{}) ([]byte, error) {
+	if body == nil {
+		return nil, nil
+	}
+	if str, ok := body.(string); ok {
+		return []byte(str), nil
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	if exportInteraction.Protocol == "gRPC" {
+		if _, ok := protoschema.Default.Method(exportInteraction.Method); ok {
+			return protoschema.Default.Encode(exportInteraction.Method, direction, data)
+		}
+	}
+	return data, nil
+}
+
 func (e *ExportManager) writeExportData(data storage.ExportData, outputPath string) error {
 	var jsonData []byte
 	var err error
@@ -251,22 +418,39 @@ func (e *ExportManager) writeExportData(data storage.ExportData, outputPath stri
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	file, err := os.Create(outputPath)
+	// Write next to the target and rename over it, so readers such as a watching mock server or
+	// git never see a partly written file
+	file, err := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*")
 	if err != nil {
 		return fmt.Errorf("failed to create output file: %w", err)
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
 	var writer io.Writer = file
+	var gzWriter *gzip.Writer
 	if e.config.Export.Compress && strings.HasSuffix(outputPath, ".gz") {
-		gzWriter := gzip.NewWriter(file)
-		defer gzWriter.Close()
+		gzWriter = gzip.NewWriter(file)
 		writer = gzWriter
 	}
 
 	if _, err := writer.Write(jsonData); err != nil {
 		return fmt.Errorf("failed to write export data: %w", err)
 	}
+	if gzWriter != nil {
+		if err := gzWriter.Close(); err != nil {
+			return fmt.Errorf("failed to write export data: %w", err)
+		}
+	}
+	if err := file.Chmod(0644); err != nil {
+		return fmt.Errorf("failed to write export data: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write export data: %w", err)
+	}
+	if err := os.Rename(file.Name(), outputPath); err != nil {
+		return fmt.Errorf("failed to write export data: %w", err)
+	}
 
 	return nil
 }
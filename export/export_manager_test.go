@@ -0,0 +1,220 @@
+package export
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mimic/adminpb"
+	"mimic/config"
+	"mimic/protoschema"
+	"mimic/storage"
+
+	"github.com/gorilla/websocket"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func TestGRPCBodiesExportAsJSON(t *testing.T) {
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(adminpb.File_adminpb_admin_proto)}}
+	if err := protoschema.Default.Add(set); err != nil {
+		t.Fatalf("Failed to add descriptors: %v", err)
+	}
+	t.Cleanup(protoschema.Default.Reset)
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	session, err := db.CreateSession("admin", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	const method = "/mimic.admin.v1.AdminService/CreateSession"
+	request, _ := proto.Marshal(&adminpb.CreateSessionRequest{Name: "checkout"})
+	response, _ := proto.Marshal(&adminpb.Session{Id: 7, Name: "checkout"})
+	if err := db.RecordInteraction(&storage.Interaction{RequestID: "r1", SessionID: session.ID, Protocol: "gRPC", Method: method,
+		Endpoint: method, RequestHeaders: "{}", RequestBody: request, ResponseHeaders: "{}", ResponseBody: response, Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	manager := NewExportManager(config.DefaultConfig(), db)
+	path := filepath.Join(t.TempDir(), "admin.json")
+	if err := manager.ExportSession("admin", path); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported storage.ExportData
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("Failed to parse export: %v", err)
+	}
+	body, _ := json.Marshal(exported.Interactions[0].Response.Body)
+	if string(body) != `{"id":"7","name":"checkout"}` {
+		t.Errorf("Expected the response exported as protobuf JSON, got %s", body)
+	}
+
+	// Import into a fresh database, since request IDs are unique
+	other, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer other.Close()
+	if err := NewExportManager(config.DefaultConfig(), other).ImportSession(path, "", "append"); err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	imported, err := other.GetSession("admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	interactions, err := other.GetInteractionsBySession(imported.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected one imported interaction, got %d (%v)", len(interactions), err)
+	}
+	var decoded adminpb.Session
+	if err := proto.Unmarshal(interactions[0].ResponseBody, &decoded); err != nil || decoded.Id != 7 || decoded.Name != "checkout" {
+		t.Errorf("Expected the response encoded back to protobuf, got %v (%v)", &decoded, err)
+	}
+}
+
+func TestExportRedactsSecrets(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	session, err := db.CreateSession("billing", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	// Recorded before redaction was set up
+	if err := db.RecordInteraction(&storage.Interaction{RequestID: "r1", SessionID: session.ID, Protocol: "REST", Method: "GET", Endpoint: "/me",
+		RequestHeaders: `{"Authorization":"Bearer live-token"}`, ResponseStatus: 200, ResponseHeaders: "{}",
+		ResponseBody: []byte(`{"email":"ada@example.com"}`), Timestamp: time.Now()}); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Recording.RedactPresets = []string{"authorization", "email"}
+	path := filepath.Join(t.TempDir(), "billing.json")
+	if err := NewExportManager(cfg, db).ExportSession("billing", path); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported storage.ExportData
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("Failed to parse export: %v", err)
+	}
+	interaction := exported.Interactions[0]
+	if interaction.Request.Headers["Authorization"] != "[REDACTED]" {
+		t.Errorf("Expected the Authorization header redacted, got %q", interaction.Request.Headers["Authorization"])
+	}
+	if body, _ := json.Marshal(interaction.Response.Body); string(body) != `{"email":"[REDACTED]"}` {
+		t.Errorf("Expected the email redacted, got %s", body)
+	}
+}
+
+func TestExportSliceByTag(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	session, err := db.CreateSession("shop", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	for i, scenario := range []string{"checkout-happy-path", "payment-declined", "checkout-happy-path"} {
+		interaction := &storage.Interaction{RequestID: fmt.Sprintf("r%d", i), SessionID: session.ID, Protocol: "REST", Method: "POST", Endpoint: "/orders",
+			RequestHeaders: "{}", ResponseStatus: 200, ResponseHeaders: "{}", Timestamp: time.Now()}
+		interaction.SetMetadataValue(storage.MetadataTags, []string{scenario})
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	manager := NewExportManager(config.DefaultConfig(), db)
+	path := filepath.Join(t.TempDir(), "shop-checkout-happy-path.json")
+	count, err := manager.ExportSlice("shop", Slice{Tag: "checkout-happy-path"}, path)
+	if err != nil || count != 2 {
+		t.Fatalf("Expected 2 interactions exported, got %d (%v)", count, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var exported storage.ExportData
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("Failed to parse export: %v", err)
+	}
+	if exported.Tag != "checkout-happy-path" || len(exported.Interactions) != 2 {
+		t.Errorf("Expected the tagged slice, got tag %q with %d interactions", exported.Tag, len(exported.Interactions))
+	}
+
+	if _, err := manager.ExportSlice("shop", Slice{Tag: "refund"}, path); err == nil {
+		t.Error("Expected an error for a tag with no interactions")
+	}
+}
+
+func TestWebSocketFramesRoundTrip(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	session, err := db.CreateSession("sockets", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	interaction := &storage.Interaction{RequestID: "ws1", SessionID: session.ID, Protocol: "REST", Method: "GET", Endpoint: "/chat",
+		RequestHeaders: "{}", ResponseStatus: 101, ResponseHeaders: "{}", Metadata: `{"websocket":true}`, Timestamp: time.Now()}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+	frames := []*storage.WebSocketFrame{
+		{InteractionID: interaction.ID, FrameIndex: 0, Direction: storage.FrameFromClient, MessageType: websocket.TextMessage, Data: []byte("hello")},
+		{InteractionID: interaction.ID, FrameIndex: 1, Direction: storage.FrameFromServer, MessageType: websocket.BinaryMessage, Data: []byte{0xff, 0}, TimeDelta: 40},
+	}
+	if err := db.RecordWebSocketFrames(frames); err != nil {
+		t.Fatalf("Failed to record frames: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "sockets.json")
+	if err := NewExportManager(config.DefaultConfig(), db).ExportSession("sockets", path); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	other, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer other.Close()
+	if err := NewExportManager(config.DefaultConfig(), other).ImportSession(path, "", "append"); err != nil {
+		t.Fatalf("Failed to import: %v", err)
+	}
+	imported, err := other.GetSession("sockets")
+	if err != nil {
+		t.Fatal(err)
+	}
+	interactions, err := other.GetInteractionsBySession(imported.ID)
+	if err != nil || len(interactions) != 1 || !interactions[0].WebSocket() {
+		t.Fatalf("Expected one imported WebSocket interaction, got %v (%v)", interactions, err)
+	}
+	got, err := other.GetWebSocketFrames(interactions[0].ID)
+	if err != nil || len(got) != 2 {
+		t.Fatalf("Expected two imported frames, got %v (%v)", got, err)
+	}
+	if string(got[0].Data) != "hello" || got[1].Direction != storage.FrameFromServer || string(got[1].Data) != "\xff\x00" || got[1].TimeDelta != 40 {
+		t.Errorf("Expected the frames to survive the round trip, got %+v", got)
+	}
+}
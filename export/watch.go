@@ -0,0 +1,91 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"mimic/storage"
+)
+
+// Watch defaults, used when WatchOptions leaves them at zero
+const (
+	DefaultWatchPollInterval = 500 * time.Millisecond
+	DefaultWatchDebounce     = 2 * time.Second
+)
+
+// WatchOptions controls WatchSession
+type WatchOptions struct {
+	PollInterval time.Duration // How often the database is checked for new interactions
+	Debounce     time.Duration // How long the session must stay unchanged before it is exported
+	// OnExport is called after each export with the number of interactions written
+	OnExport func(outputPath string, interactions int)
+}
+
+// WatchSession exports a session to outputPath, then again whenever interactions are recorded
+// into or removed from it, until ctx is done. Bursts of changes are exported once, after the
+// session has been quiet for the debounce time; a change still pending when ctx ends is exported
+// before returning. A session that does not exist yet is exported once something is recorded.
+func (e *ExportManager) WatchSession(ctx context.Context, sessionName, outputPath string, opts WatchOptions) error {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = DefaultWatchPollInterval
+	}
+	if opts.Debounce <= 0 {
+		opts.Debounce = DefaultWatchDebounce
+	}
+
+	exported := storage.SessionWatermark{}
+	export := func(watermark storage.SessionWatermark) error {
+		exported = watermark
+		if _, err := e.database.GetSession(sessionName); err != nil {
+			// Not recorded yet, or cleared; the last export is left in place
+			return nil
+		}
+		if err := e.ExportSession(sessionName, outputPath); err != nil {
+			return err
+		}
+		if opts.OnExport != nil {
+			opts.OnExport(outputPath, watermark.Interactions)
+		}
+		return nil
+	}
+
+	current, err := e.database.GetSessionWatermark(sessionName)
+	if err != nil {
+		return err
+	}
+	if err := export(current); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(opts.PollInterval)
+	defer ticker.Stop()
+
+	var changedAt time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			watermark, err := e.database.GetSessionWatermark(sessionName)
+			if err != nil {
+				return fmt.Errorf("failed to check session: %w", err)
+			}
+			if watermark != exported {
+				return export(watermark)
+			}
+			return nil
+		case now := <-ticker.C:
+			watermark, err := e.database.GetSessionWatermark(sessionName)
+			if err != nil {
+				return fmt.Errorf("failed to check session: %w", err)
+			}
+			if watermark != current {
+				current, changedAt = watermark, now
+			}
+			if current != exported && now.Sub(changedAt) >= opts.Debounce {
+				if err := export(current); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
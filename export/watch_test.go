@@ -0,0 +1,94 @@
+package export
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestWatchSession(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	output := filepath.Join(t.TempDir(), "fixtures", "checkout.json")
+	var mu sync.Mutex
+	var exports []int
+	exported := make(chan struct{}, 10)
+
+	session, err := db.CreateSession("checkout", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	record := func(requestID string) {
+		if err := db.RecordInteraction(&storage.Interaction{SessionID: session.ID, RequestID: requestID, Protocol: "REST", Method: "GET", Endpoint: "/cart", ResponseStatus: 200}); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+	waitForExport := func() {
+		select {
+		case <-exported:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Expected the session to be exported")
+		}
+	}
+	record("a")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- NewExportManager(config.DefaultConfig(), db).WatchSession(ctx, "checkout", output, WatchOptions{
+			PollInterval: 10 * time.Millisecond,
+			Debounce:     50 * time.Millisecond,
+			OnExport: func(path string, interactions int) {
+				mu.Lock()
+				exports = append(exports, interactions)
+				mu.Unlock()
+				exported <- struct{}{}
+			},
+		})
+	}()
+	waitForExport()
+
+	// A burst is exported once, after it settles
+	record("b")
+	record("c")
+	waitForExport()
+
+	// A change still pending at shutdown is exported before returning
+	record("d")
+	cancel()
+	if err := <-done; err != nil {
+		t.Fatalf("WatchSession failed: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(exports) != 3 || exports[0] != 1 || exports[1] != 3 || exports[2] != 4 {
+		t.Errorf("Expected exports of 1, 3, and 4 interactions, got %v", exports)
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatalf("Failed to read export: %v", err)
+	}
+	var exportData storage.ExportData
+	if err := json.Unmarshal(data, &exportData); err != nil {
+		t.Fatalf("Export is not valid JSON: %v", err)
+	}
+	if len(exportData.Interactions) != 4 {
+		t.Errorf("Expected 4 interactions in the file, got %d", len(exportData.Interactions))
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(output), ".*")); len(matches) != 0 {
+		t.Errorf("Expected no temporary files to be left, got %v", matches)
+	}
+}
@@ -0,0 +1,269 @@
+// Package fault injects latency, errors, and dropped connections into a proxy's traffic,
+// so resilience tests can ramp failures up and down while mimic keeps running.
+package fault
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"mimic/config"
+	"mimic/metrics"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Kinds of injected fault
+const (
+	KindLatency = "latency"
+	KindError   = "error"
+	KindDrop    = "drop"
+
+	// Mocked responses can also be broken on the way out
+	KindReset     = "reset"
+	KindTruncate  = "truncate"
+	KindDrip      = "drip"
+	KindMalformed = "malformed"
+)
+
+const defaultErrorStatus = http.StatusServiceUnavailable
+
+// Settings are the faults injected into one proxy's traffic
+type Settings struct {
+	LatencyMS       int     `json:"latency_ms"`
+	LatencyJitterMS int     `json:"latency_jitter_ms"`
+	ErrorRate       float64 `json:"error_rate"`
+	ErrorStatus     int     `json:"error_status"`
+	DropRate        float64 `json:"drop_rate"`
+}
+
+// FromConfig converts a proxy's configured faults
+func FromConfig(cfg config.FaultConfig) Settings {
+	return Settings(cfg)
+}
+
+// Validate applies the same rules as the config file
+func (s Settings) Validate() error {
+	return config.FaultConfig(s).Validate()
+}
+
+// Active reports whether the settings inject anything
+func (s Settings) Active() bool {
+	return s.LatencyMS > 0 || s.LatencyJitterMS > 0 || s.ErrorRate > 0 || s.DropRate > 0
+}
+
+// Outcome is the fault chosen for one request
+type Outcome struct {
+	Kind   string // KindError, KindDrop, or empty to handle the request normally
+	Status int    // HTTP status of an injected error
+}
+
+// GRPCError returns the status a gRPC handler should end the call with, or nil to handle it normally.
+// A call cannot be cut off mid-connection from a handler, so drops end with UNAVAILABLE.
+func (o Outcome) GRPCError(proxyName string) error {
+	switch o.Kind {
+	case KindError:
+		return status.Errorf(httpToGRPCCode(o.Status), "fault injected by proxy '%s'", proxyName)
+	case KindDrop:
+		return status.Errorf(codes.Unavailable, "connection dropped by proxy '%s'", proxyName)
+	default:
+		return nil
+	}
+}
+
+// Registry holds the fault settings of every proxy
+type Registry struct {
+	mutex    sync.RWMutex
+	settings map[string]Settings
+
+	randomMutex sync.Mutex
+	random      *rand.Rand
+}
+
+// Default is the registry consulted by the proxy listeners
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		settings: make(map[string]Settings),
+		random:   rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// Configure replaces every proxy's settings with the ones from its config
+func (r *Registry) Configure(proxies map[string]config.ProxyConfig) {
+	settings := make(map[string]Settings)
+	for name, proxyConfig := range proxies {
+		if s := FromConfig(proxyConfig.Faults); s.Active() {
+			settings[name] = s
+		}
+	}
+
+	r.mutex.Lock()
+	r.settings = settings
+	r.mutex.Unlock()
+}
+
+// Get returns a proxy's current settings
+func (r *Registry) Get(proxyName string) Settings {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.settings[proxyName]
+}
+
+// Set replaces a proxy's settings; the next request sees them
+func (r *Registry) Set(proxyName string, settings Settings) error {
+	if err := settings.Validate(); err != nil {
+		return err
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if settings.Active() {
+		r.settings[proxyName] = settings
+	} else {
+		delete(r.settings, proxyName)
+	}
+	return nil
+}
+
+// Inject waits out the proxy's injected latency and then picks the fault, if any, for one request.
+// It returns ctx's error if the client gives up while waiting.
+func (r *Registry) Inject(ctx context.Context, proxyName string) (Outcome, error) {
+	r.mutex.RLock()
+	settings, ok := r.settings[proxyName]
+	r.mutex.RUnlock()
+	if !ok {
+		return Outcome{}, nil
+	}
+
+	delay := time.Duration(settings.LatencyMS) * time.Millisecond
+	if settings.LatencyJitterMS > 0 {
+		delay += time.Duration(r.float64() * float64(time.Duration(settings.LatencyJitterMS)*time.Millisecond))
+	}
+	if delay > 0 {
+		metrics.RecordFault(proxyName, KindLatency)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return Outcome{}, ctx.Err()
+		}
+	}
+
+	roll := r.float64()
+	switch {
+	case roll < settings.DropRate:
+		metrics.RecordFault(proxyName, KindDrop)
+		return Outcome{Kind: KindDrop}, nil
+	case roll < settings.DropRate+settings.ErrorRate:
+		metrics.RecordFault(proxyName, KindError)
+		errorStatus := settings.ErrorStatus
+		if errorStatus == 0 {
+			errorStatus = defaultErrorStatus
+		}
+		return Outcome{Kind: KindError, Status: errorStatus}, nil
+	default:
+		return Outcome{}, nil
+	}
+}
+
+// PickResponse picks the fault, if any, for one mocked response under an endpoint's mock faults
+func (r *Registry) PickResponse(proxyName string, cfg config.MockFaultConfig) Outcome {
+	roll := r.float64()
+	for _, candidate := range []struct {
+		kind string
+		rate float64
+	}{
+		{KindError, cfg.ErrorRate},
+		{KindReset, cfg.ResetRate},
+		{KindTruncate, cfg.TruncateRate},
+		{KindDrip, cfg.DripRate},
+		{KindMalformed, cfg.MalformedRate},
+	} {
+		if roll < candidate.rate {
+			metrics.RecordFault(proxyName, candidate.kind)
+			outcome := Outcome{Kind: candidate.kind}
+			if candidate.kind == KindError {
+				outcome.Status = cfg.ErrorStatus
+				if outcome.Status == 0 {
+					outcome.Status = defaultErrorStatus
+				}
+			}
+			return outcome
+		}
+		roll -= candidate.rate
+	}
+	return Outcome{}
+}
+
+// InjectGRPC applies a proxy's faults to a gRPC call, returning the error to end it with, or nil
+func (r *Registry) InjectGRPC(ctx context.Context, proxyName string) error {
+	outcome, err := r.Inject(ctx, proxyName)
+	if err != nil {
+		return status.FromContextError(err).Err()
+	}
+	return outcome.GRPCError(proxyName)
+}
+
+func (r *Registry) float64() float64 {
+	r.randomMutex.Lock()
+	defer r.randomMutex.Unlock()
+	return r.random.Float64()
+}
+
+// httpToGRPCCode maps an HTTP status onto a gRPC code the way gRPC clients do for HTTP errors
+func httpToGRPCCode(httpStatus int) codes.Code {
+	switch httpStatus {
+	case http.StatusBadRequest:
+		return codes.Internal
+	case http.StatusUnauthorized:
+		return codes.Unauthenticated
+	case http.StatusForbidden:
+		return codes.PermissionDenied
+	case http.StatusNotFound:
+		return codes.Unimplemented
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return codes.Unavailable
+	default:
+		return codes.Unknown
+	}
+}
+
+// Configure replaces the default registry's settings
+func Configure(proxies map[string]config.ProxyConfig) {
+	Default.Configure(proxies)
+}
+
+// Get returns a proxy's settings from the default registry
+func Get(proxyName string) Settings {
+	return Default.Get(proxyName)
+}
+
+// Set replaces a proxy's settings in the default registry
+func Set(proxyName string, settings Settings) error {
+	if err := Default.Set(proxyName, settings); err != nil {
+		return fmt.Errorf("invalid faults for proxy '%s': %w", proxyName, err)
+	}
+	return nil
+}
+
+// Inject applies a proxy's faults from the default registry
+func Inject(ctx context.Context, proxyName string) (Outcome, error) {
+	return Default.Inject(ctx, proxyName)
+}
+
+// InjectGRPC applies a proxy's faults from the default registry to a gRPC call
+func InjectGRPC(ctx context.Context, proxyName string) error {
+	return Default.InjectGRPC(ctx, proxyName)
+}
+
+// PickResponse picks a mocked response's fault from the default registry's random source
+func PickResponse(proxyName string, cfg config.MockFaultConfig) Outcome {
+	return Default.PickResponse(proxyName, cfg)
+}
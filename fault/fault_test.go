@@ -0,0 +1,116 @@
+package fault
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"mimic/config"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestConfigureAndSet(t *testing.T) {
+	registry := NewRegistry()
+	registry.Configure(map[string]config.ProxyConfig{
+		"api":   {Faults: config.FaultConfig{LatencyMS: 50}},
+		"quiet": {},
+	})
+
+	if got := registry.Get("api"); got.LatencyMS != 50 {
+		t.Errorf("Expected configured latency of 50ms, got %+v", got)
+	}
+	if got := registry.Get("quiet"); got.Active() {
+		t.Errorf("Expected no faults for a proxy without settings, got %+v", got)
+	}
+
+	invalid := []Settings{{ErrorRate: 1.5}, {DropRate: -0.1}, {ErrorRate: 0.6, DropRate: 0.6}, {ErrorStatus: 200}, {LatencyMS: -1}}
+	for _, settings := range invalid {
+		if err := registry.Set("api", settings); err == nil {
+			t.Errorf("Expected %+v to be rejected", settings)
+		}
+	}
+
+	if err := registry.Set("api", Settings{}); err != nil {
+		t.Fatalf("Set failed: %v", err)
+	}
+	if got := registry.Get("api"); got.Active() {
+		t.Errorf("Expected empty settings to clear the faults, got %+v", got)
+	}
+}
+
+func TestInject(t *testing.T) {
+	registry := NewRegistry()
+	ctx := context.Background()
+
+	if outcome, err := registry.Inject(ctx, "api"); err != nil || outcome.Kind != "" {
+		t.Errorf("Expected no fault for an unconfigured proxy, got %+v (%v)", outcome, err)
+	}
+
+	registry.Set("api", Settings{ErrorRate: 1})
+	outcome, _ := registry.Inject(ctx, "api")
+	if outcome.Kind != KindError || outcome.Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 error, got %+v", outcome)
+	}
+	if code := status.Code(outcome.GRPCError("api")); code != codes.Unavailable {
+		t.Errorf("Expected UNAVAILABLE for a 503, got %v", code)
+	}
+
+	registry.Set("api", Settings{ErrorRate: 1, ErrorStatus: http.StatusForbidden})
+	if err := registry.InjectGRPC(ctx, "api"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PERMISSION_DENIED for a 403, got %v", err)
+	}
+
+	registry.Set("api", Settings{DropRate: 1})
+	if outcome, _ := registry.Inject(ctx, "api"); outcome.Kind != KindDrop {
+		t.Errorf("Expected a drop, got %+v", outcome)
+	}
+
+	registry.Set("api", Settings{LatencyMS: 30})
+	start := time.Now()
+	if outcome, err := registry.Inject(ctx, "api"); err != nil || outcome.Kind != "" {
+		t.Errorf("Expected latency only, got %+v (%v)", outcome, err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond {
+		t.Errorf("Expected at least 30ms of latency, took %v", elapsed)
+	}
+}
+
+func TestInjectGivesUpWithTheClient(t *testing.T) {
+	registry := NewRegistry()
+	registry.Set("api", Settings{LatencyMS: 10000})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if err := registry.InjectGRPC(ctx, "api"); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected DEADLINE_EXCEEDED, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the wait to end with the context, took %v", elapsed)
+	}
+}
+
+func TestPickResponse(t *testing.T) {
+	registry := NewRegistry()
+
+	if outcome := registry.PickResponse("api", config.MockFaultConfig{}); outcome.Kind != "" {
+		t.Errorf("Expected no fault without rates, got %+v", outcome)
+	}
+	if outcome := registry.PickResponse("api", config.MockFaultConfig{ErrorRate: 1}); outcome.Kind != KindError || outcome.Status != http.StatusServiceUnavailable {
+		t.Errorf("Expected a 503 error, got %+v", outcome)
+	}
+	for kind, cfg := range map[string]config.MockFaultConfig{
+		KindReset:     {ResetRate: 1},
+		KindTruncate:  {TruncateRate: 1},
+		KindDrip:      {DripRate: 1},
+		KindMalformed: {MalformedRate: 1},
+	} {
+		if outcome := registry.PickResponse("api", cfg); outcome.Kind != kind {
+			t.Errorf("Expected a %s fault, got %+v", kind, outcome)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package intercept
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	StageRequest  = "request"  // Pause before the request reaches the proxy or mock handler
+	StageResponse = "response" // Pause before the response is sent back to the client
+	StageBoth     = "both"
+
+	ActionContinue = "continue"
+	ActionAbort    = "abort"
+
+	// DefaultTimeout is how long an exchange stays paused before it continues unchanged
+	DefaultTimeout = 5 * time.Minute
+)
+
+// Default is the process-wide manager used by the proxy middleware and the web API
+var Default = NewManager(DefaultTimeout)
+
+// Breakpoint selects the exchanges to pause; empty fields match everything
+type Breakpoint struct {
+	ID       string `json:"id"`
+	Proxy    string `json:"proxy"`
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"` // Regular expression matched against the request path
+	Stage    string `json:"stage"`    // "request", "response", or "both"
+
+	pattern *regexp.Regexp
+}
+
+func (b *Breakpoint) matches(proxy, stage, method, endpoint string) bool {
+	if b.Stage != StageBoth && b.Stage != stage {
+		return false
+	}
+	if b.Proxy != "" && b.Proxy != proxy {
+		return false
+	}
+	if b.Method != "" && !strings.EqualFold(b.Method, method) {
+		return false
+	}
+	if b.pattern != nil && !b.pattern.MatchString(endpoint) {
+		return false
+	}
+	return true
+}
+
+// Exchange is a paused request or response as shown to the user
+type Exchange struct {
+	ID           string            `json:"id"`
+	BreakpointID string            `json:"breakpoint_id"`
+	Proxy        string            `json:"proxy"`
+	Stage        string            `json:"stage"`
+	Method       string            `json:"method"`
+	Endpoint     string            `json:"endpoint"`
+	Query        string            `json:"query,omitempty"`
+	Headers      map[string]string `json:"headers"`
+	Body         string            `json:"body"`
+	Status       int               `json:"status,omitempty"` // Response stage only
+	PausedAt     time.Time         `json:"paused_at"`
+	Deadline     time.Time         `json:"deadline"`
+}
+
+// Decision resumes a paused exchange; zero-valued fields leave the original untouched
+type Decision struct {
+	Action   string            `json:"action"` // "continue" (default) or "abort"
+	Method   string            `json:"method,omitempty"`
+	Endpoint string            `json:"endpoint,omitempty"`
+	Query    *string           `json:"query,omitempty"`
+	Headers  map[string]string `json:"headers,omitempty"` // Replaces all headers when set
+	Body     *string           `json:"body,omitempty"`
+	Status   int               `json:"status,omitempty"` // Response status, or the status to abort with
+}
+
+type pending struct {
+	exchange Exchange
+	decision chan Decision
+}
+
+// Manager holds the active breakpoints and the exchanges waiting on a decision
+type Manager struct {
+	mutex       sync.Mutex
+	timeout     time.Duration
+	breakpoints []*Breakpoint
+	paused      map[string]*pending
+	notify      func(eventType string, data interface{})
+}
+
+// NewManager creates a manager that resumes paused exchanges after timeout
+func NewManager(timeout time.Duration) *Manager {
+	return &Manager{
+		timeout: timeout,
+		paused:  make(map[string]*pending),
+	}
+}
+
+// SetNotifier registers a callback for "intercept_paused" and "intercept_resumed" events
+func (m *Manager) SetNotifier(notify func(eventType string, data interface{})) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.notify = notify
+}
+
+// SetTimeout changes how long future exchanges stay paused
+func (m *Manager) SetTimeout(timeout time.Duration) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.timeout = timeout
+}
+
+// AddBreakpoint validates and activates a breakpoint
+func (m *Manager) AddBreakpoint(breakpoint Breakpoint) (Breakpoint, error) {
+	if breakpoint.Stage == "" {
+		breakpoint.Stage = StageRequest
+	}
+	if breakpoint.Stage != StageRequest && breakpoint.Stage != StageResponse && breakpoint.Stage != StageBoth {
+		return Breakpoint{}, fmt.Errorf("invalid stage: %s (must be 'request', 'response', or 'both')", breakpoint.Stage)
+	}
+	if breakpoint.Endpoint != "" {
+		pattern, err := regexp.Compile(breakpoint.Endpoint)
+		if err != nil {
+			return Breakpoint{}, fmt.Errorf("invalid endpoint pattern: %w", err)
+		}
+		breakpoint.pattern = pattern
+	}
+	breakpoint.ID = uuid.New().String()
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.breakpoints = append(m.breakpoints, &breakpoint)
+	return breakpoint, nil
+}
+
+// RemoveBreakpoint deactivates a breakpoint; exchanges it already paused stay paused
+func (m *Manager) RemoveBreakpoint(id string) bool {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, breakpoint := range m.breakpoints {
+		if breakpoint.ID == id {
+			m.breakpoints = append(m.breakpoints[:i], m.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns the active breakpoints in creation order
+func (m *Manager) Breakpoints() []Breakpoint {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	breakpoints := make([]Breakpoint, 0, len(m.breakpoints))
+	for _, breakpoint := range m.breakpoints {
+		breakpoints = append(breakpoints, *breakpoint)
+	}
+	return breakpoints
+}
+
+// Match returns the ID of the first breakpoint matching an exchange, or "" if none does
+func (m *Manager) Match(proxy, stage, method, endpoint string) string {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for _, breakpoint := range m.breakpoints {
+		if breakpoint.matches(proxy, stage, method, endpoint) {
+			return breakpoint.ID
+		}
+	}
+	return ""
+}
+
+// Pause blocks until the exchange is resolved, the timeout passes, or ctx is cancelled.
+// A timeout continues the exchange unchanged; a cancelled context aborts it.
+func (m *Manager) Pause(ctx context.Context, exchange Exchange) Decision {
+	m.mutex.Lock()
+	exchange.ID = uuid.New().String()
+	exchange.PausedAt = time.Now()
+	exchange.Deadline = exchange.PausedAt.Add(m.timeout)
+	p := &pending{exchange: exchange, decision: make(chan Decision, 1)}
+	m.paused[exchange.ID] = p
+	timeout := m.timeout
+	m.mutex.Unlock()
+
+	log.Printf("[INTERCEPT] Paused %s %s %s on proxy '%s'", exchange.Stage, exchange.Method, exchange.Endpoint, exchange.Proxy)
+	m.emit("intercept_paused", exchange)
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	var decision Decision
+	select {
+	case decision = <-p.decision:
+	case <-timer.C:
+		log.Printf("[INTERCEPT] Timed out waiting on %s, continuing unchanged", exchange.ID)
+		decision = Decision{Action: ActionContinue}
+		m.remove(exchange.ID)
+	case <-ctx.Done():
+		decision = Decision{Action: ActionAbort}
+		m.remove(exchange.ID)
+	}
+
+	m.emit("intercept_resumed", map[string]string{"id": exchange.ID, "action": decision.Action})
+	return decision
+}
+
+// Paused returns the exchanges currently waiting on a decision, oldest first
+func (m *Manager) Paused() []Exchange {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	exchanges := make([]Exchange, 0, len(m.paused))
+	for _, p := range m.paused {
+		exchanges = append(exchanges, p.exchange)
+	}
+	sort.Slice(exchanges, func(i, j int) bool { return exchanges[i].PausedAt.Before(exchanges[j].PausedAt) })
+	return exchanges
+}
+
+// Resolve hands a decision to a paused exchange
+func (m *Manager) Resolve(id string, decision Decision) error {
+	if decision.Action == "" {
+		decision.Action = ActionContinue
+	}
+	if decision.Action != ActionContinue && decision.Action != ActionAbort {
+		return fmt.Errorf("invalid action: %s (must be 'continue' or 'abort')", decision.Action)
+	}
+
+	p := m.remove(id)
+	if p == nil {
+		return fmt.Errorf("no paused exchange with id %s", id)
+	}
+	p.decision <- decision
+	return nil
+}
+
+func (m *Manager) remove(id string) *pending {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p := m.paused[id]
+	delete(m.paused, id)
+	return p
+}
+
+func (m *Manager) emit(eventType string, data interface{}) {
+	m.mutex.Lock()
+	notify := m.notify
+	m.mutex.Unlock()
+
+	if notify != nil {
+		notify(eventType, data)
+	}
+}
@@ -0,0 +1,103 @@
+package intercept
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBreakpointMatching(t *testing.T) {
+	m := NewManager(time.Second)
+
+	breakpoint, err := m.AddBreakpoint(Breakpoint{Proxy: "api", Method: "post", Endpoint: "^/orders"})
+	if err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+	if breakpoint.Stage != StageRequest {
+		t.Errorf("Expected default stage 'request', got %q", breakpoint.Stage)
+	}
+
+	if m.Match("api", StageRequest, "POST", "/orders/1") != breakpoint.ID {
+		t.Error("Expected POST /orders/1 on 'api' to match")
+	}
+	if m.Match("api", StageResponse, "POST", "/orders/1") != "" {
+		t.Error("Expected response stage not to match a request breakpoint")
+	}
+	if m.Match("other", StageRequest, "POST", "/orders/1") != "" {
+		t.Error("Expected other proxy not to match")
+	}
+	if m.Match("api", StageRequest, "GET", "/orders/1") != "" {
+		t.Error("Expected GET not to match")
+	}
+
+	if _, err := m.AddBreakpoint(Breakpoint{Endpoint: "("}); err == nil {
+		t.Error("Expected invalid endpoint pattern to be rejected")
+	}
+
+	if !m.RemoveBreakpoint(breakpoint.ID) {
+		t.Fatal("Expected breakpoint to be removed")
+	}
+	if m.Match("api", StageRequest, "POST", "/orders/1") != "" {
+		t.Error("Expected no match after removal")
+	}
+}
+
+func TestPauseAndResolve(t *testing.T) {
+	m := NewManager(time.Minute)
+
+	paused := make(chan Exchange, 1)
+	m.SetNotifier(func(eventType string, data interface{}) {
+		if eventType == "intercept_paused" {
+			paused <- data.(Exchange)
+		}
+	})
+
+	result := make(chan Decision, 1)
+	go func() {
+		result <- m.Pause(context.Background(), Exchange{Proxy: "api", Stage: StageRequest, Method: "GET", Endpoint: "/users"})
+	}()
+
+	exchange := <-paused
+	if len(m.Paused()) != 1 {
+		t.Fatalf("Expected 1 paused exchange, got %d", len(m.Paused()))
+	}
+
+	body := `{"edited":true}`
+	if err := m.Resolve(exchange.ID, Decision{Body: &body}); err != nil {
+		t.Fatalf("Failed to resolve: %v", err)
+	}
+
+	decision := <-result
+	if decision.Action != ActionContinue || decision.Body == nil || *decision.Body != body {
+		t.Errorf("Unexpected decision: %+v", decision)
+	}
+	if len(m.Paused()) != 0 {
+		t.Error("Expected no paused exchanges after resolve")
+	}
+	if err := m.Resolve(exchange.ID, Decision{}); err == nil {
+		t.Error("Expected resolving twice to fail")
+	}
+}
+
+func TestPauseTimesOutAndContinues(t *testing.T) {
+	m := NewManager(10 * time.Millisecond)
+
+	decision := m.Pause(context.Background(), Exchange{Stage: StageResponse})
+	if decision.Action != ActionContinue {
+		t.Errorf("Expected timeout to continue, got %q", decision.Action)
+	}
+	if len(m.Paused()) != 0 {
+		t.Error("Expected timed out exchange to be removed")
+	}
+}
+
+func TestPauseAbortsWhenClientGoes(t *testing.T) {
+	m := NewManager(time.Minute)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if decision := m.Pause(ctx, Exchange{Stage: StageRequest}); decision.Action != ActionAbort {
+		t.Errorf("Expected cancelled context to abort, got %q", decision.Action)
+	}
+}
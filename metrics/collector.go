@@ -0,0 +1,246 @@
+package metrics
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultBucketSize = 10 * time.Second
+	defaultBuckets    = 60 // 10 minutes of history
+)
+
+// Default is the process-wide collector fed by the proxy and mock engines
+var Default = NewCollector(defaultBucketSize, defaultBuckets)
+
+// Counters holds the counts tracked for a proxy, either in total or for one time bucket
+type Counters struct {
+	Requests      int64 `json:"requests"`
+	MockHits      int64 `json:"mock_hits"`
+	MockMisses    int64 `json:"mock_misses"`
+	Status4xx     int64 `json:"status_4xx"`
+	Status5xx     int64 `json:"status_5xx"`
+	Recorded      int64 `json:"recorded"`
+	RecordedBytes int64 `json:"recorded_bytes"`
+}
+
+// Point is one time bucket of a proxy's history
+type Point struct {
+	Time time.Time `json:"time"`
+	Counters
+}
+
+// ProxyMetrics is the snapshot of a single proxy
+type ProxyMetrics struct {
+	Name    string   `json:"name"`
+	Totals  Counters `json:"totals"`
+	History []Point  `json:"history"` // Oldest first, one point per bucket
+}
+
+// Snapshot is a point-in-time copy of everything the collector knows
+type Snapshot struct {
+	StartedAt     time.Time      `json:"started_at"`
+	BucketSeconds int            `json:"bucket_seconds"`
+	Proxies       []ProxyMetrics `json:"proxies"`
+}
+
+type proxyState struct {
+	totals  Counters
+	buckets []Counters
+	// bucketStart is the start time of buckets[head]
+	head        int
+	bucketStart time.Time
+}
+
+// Collector keeps per-proxy counters plus a fixed window of time-bucketed history
+type Collector struct {
+	mutex      sync.Mutex
+	bucketSize time.Duration
+	buckets    int
+	startedAt  time.Time
+	proxies    map[string]*proxyState
+	now        func() time.Time
+}
+
+// NewCollector creates a collector that keeps buckets × bucketSize of history
+func NewCollector(bucketSize time.Duration, buckets int) *Collector {
+	return &Collector{
+		bucketSize: bucketSize,
+		buckets:    buckets,
+		startedAt:  time.Now(),
+		proxies:    make(map[string]*proxyState),
+		now:        time.Now,
+	}
+}
+
+// RecordRequest counts a served request and classifies its status code
+func (c *Collector) RecordRequest(proxyName string, statusCode int) {
+	c.update(proxyName, func(counters *Counters) {
+		counters.Requests++
+		switch {
+		case statusCode >= 500:
+			counters.Status5xx++
+		case statusCode >= 400:
+			counters.Status4xx++
+		}
+	})
+}
+
+// RecordGRPCRequest counts a served gRPC call, mapping its status onto the HTTP classes
+func (c *Collector) RecordGRPCRequest(proxyName string, err error) {
+	c.RecordRequest(proxyName, grpcToHTTPStatus(err))
+}
+
+// RecordMockHit counts a request answered from a recording
+func (c *Collector) RecordMockHit(proxyName string) {
+	c.update(proxyName, func(counters *Counters) { counters.MockHits++ })
+}
+
+// RecordMockMiss counts a request with no matching recording
+func (c *Collector) RecordMockMiss(proxyName string) {
+	c.update(proxyName, func(counters *Counters) { counters.MockMisses++ })
+}
+
+// RecordInteraction counts an interaction persisted while recording
+func (c *Collector) RecordInteraction(proxyName string, size int) {
+	c.update(proxyName, func(counters *Counters) {
+		counters.Recorded++
+		counters.RecordedBytes += int64(size)
+	})
+}
+
+// Snapshot returns a copy of all proxy counters, sorted by proxy name
+func (c *Collector) Snapshot() Snapshot {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	snapshot := Snapshot{
+		StartedAt:     c.startedAt,
+		BucketSeconds: int(c.bucketSize / time.Second),
+		Proxies:       make([]ProxyMetrics, 0, len(c.proxies)),
+	}
+
+	for name, state := range c.proxies {
+		c.advance(state, now)
+
+		history := make([]Point, c.buckets)
+		for i := 0; i < c.buckets; i++ {
+			// Walk from the oldest bucket (just after head) to the newest (head)
+			index := (state.head + 1 + i) % c.buckets
+			history[i] = Point{
+				Time:     state.bucketStart.Add(-time.Duration(c.buckets-1-i) * c.bucketSize),
+				Counters: state.buckets[index],
+			}
+		}
+
+		snapshot.Proxies = append(snapshot.Proxies, ProxyMetrics{
+			Name:    name,
+			Totals:  state.totals,
+			History: history,
+		})
+	}
+
+	sort.Slice(snapshot.Proxies, func(i, j int) bool {
+		return snapshot.Proxies[i].Name < snapshot.Proxies[j].Name
+	})
+	return snapshot
+}
+
+// Reset discards all counters and history
+func (c *Collector) Reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.startedAt = c.now()
+	c.proxies = make(map[string]*proxyState)
+}
+
+func (c *Collector) update(proxyName string, fn func(counters *Counters)) {
+	if proxyName == "" {
+		proxyName = "default"
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	state, ok := c.proxies[proxyName]
+	if !ok {
+		state = &proxyState{
+			buckets:     make([]Counters, c.buckets),
+			bucketStart: now.Truncate(c.bucketSize),
+		}
+		c.proxies[proxyName] = state
+	}
+
+	c.advance(state, now)
+	fn(&state.totals)
+	fn(&state.buckets[state.head])
+}
+
+// advance rotates the ring so that buckets[head] covers now, clearing skipped buckets
+func (c *Collector) advance(state *proxyState, now time.Time) {
+	current := now.Truncate(c.bucketSize)
+	steps := int(current.Sub(state.bucketStart) / c.bucketSize)
+	if steps <= 0 {
+		return
+	}
+	if steps > c.buckets {
+		steps = c.buckets
+	}
+
+	for i := 0; i < steps; i++ {
+		state.head = (state.head + 1) % c.buckets
+		state.buckets[state.head] = Counters{}
+	}
+	state.bucketStart = current
+}
+
+func grpcToHTTPStatus(err error) int {
+	switch status.Code(err) {
+	case codes.OK:
+		return 200
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.FailedPrecondition, codes.OutOfRange,
+		codes.Unauthenticated, codes.ResourceExhausted:
+		return 400
+	default:
+		return 500
+	}
+}
+
+// RecordRequest counts a served request on the default collector
+func RecordRequest(proxyName string, statusCode int) {
+	Default.RecordRequest(proxyName, statusCode)
+	proxyRequests.Inc(proxyLabel(proxyName), statusLabel(statusCode))
+}
+
+// RecordGRPCRequest counts a served gRPC call on the default collector
+func RecordGRPCRequest(proxyName string, err error) {
+	Default.RecordGRPCRequest(proxyName, err)
+	proxyRequests.Inc(proxyLabel(proxyName), statusLabel(grpcToHTTPStatus(err)))
+}
+
+// RecordMockHit counts a mock hit on the default collector
+func RecordMockHit(proxyName string) {
+	Default.RecordMockHit(proxyName)
+	mockHits.Inc(proxyLabel(proxyName))
+}
+
+// RecordMockMiss counts a mock miss on the default collector
+func RecordMockMiss(proxyName string) {
+	Default.RecordMockMiss(proxyName)
+	mockMisses.Inc(proxyLabel(proxyName))
+}
+
+// RecordInteraction counts a recorded interaction on the default collector
+func RecordInteraction(proxyName string, size int) {
+	Default.RecordInteraction(proxyName, size)
+	recordedInteractions.Inc(proxyLabel(proxyName))
+	recordedBytes.Add(float64(size), proxyLabel(proxyName))
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestCollectorTotalsAndHistory(t *testing.T) {
+	collector := NewCollector(10*time.Second, 3)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	collector.now = func() time.Time { return now }
+
+	collector.RecordRequest("api", 200)
+	collector.RecordRequest("api", 404)
+	collector.RecordMockHit("api")
+
+	now = now.Add(10 * time.Second)
+	collector.RecordRequest("api", 503)
+	collector.RecordMockMiss("api")
+	collector.RecordInteraction("api", 128)
+
+	snapshot := collector.Snapshot()
+	if len(snapshot.Proxies) != 1 {
+		t.Fatalf("Expected 1 proxy, got %d", len(snapshot.Proxies))
+	}
+
+	api := snapshot.Proxies[0]
+	expected := Counters{Requests: 3, MockHits: 1, MockMisses: 1, Status4xx: 1, Status5xx: 1, Recorded: 1, RecordedBytes: 128}
+	if api.Totals != expected {
+		t.Errorf("Expected totals %+v, got %+v", expected, api.Totals)
+	}
+
+	if len(api.History) != 3 {
+		t.Fatalf("Expected 3 history points, got %d", len(api.History))
+	}
+	if api.History[1].Requests != 2 || api.History[2].Requests != 1 {
+		t.Errorf("Unexpected history: %+v", api.History)
+	}
+	if !api.History[2].Time.Equal(now) {
+		t.Errorf("Expected newest bucket at %v, got %v", now, api.History[2].Time)
+	}
+
+	// Moving past the whole window clears the history but keeps totals
+	now = now.Add(time.Minute)
+	api = collector.Snapshot().Proxies[0]
+	for _, point := range api.History {
+		if point.Requests != 0 {
+			t.Errorf("Expected expired bucket to be empty, got %+v", point)
+		}
+	}
+	if api.Totals.Requests != 3 {
+		t.Errorf("Expected totals to survive bucket expiry, got %d", api.Totals.Requests)
+	}
+}
+
+func TestGRPCStatusClassification(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{nil, 200},
+		{status.Error(codes.NotFound, "missing"), 400},
+		{status.Error(codes.Unavailable, "down"), 500},
+		{errors.New("plain error"), 500},
+	}
+
+	for _, test := range tests {
+		if got := grpcToHTTPStatus(test.err); got != test.expected {
+			t.Errorf("grpcToHTTPStatus(%v) = %d, expected %d", test.err, got, test.expected)
+		}
+	}
+}
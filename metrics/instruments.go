@@ -0,0 +1,126 @@
+package metrics
+
+import (
+	"io"
+	"strconv"
+	"time"
+)
+
+// Prometheus is the process-wide registry served on /metrics
+var Prometheus = NewRegistry()
+
+var (
+	proxyRequests = Prometheus.NewCounterVec("mimic_proxy_requests_total",
+		"Requests served by each proxy, by status code.", "proxy", "code")
+	upstreamLatency = Prometheus.NewHistogramVec("mimic_upstream_request_duration_seconds",
+		"Time for the upstream to answer a forwarded request, up to the response headers.", LatencyBuckets, "proxy", "protocol")
+	mockHits = Prometheus.NewCounterVec("mimic_mock_hits_total",
+		"Requests answered from a recording.", "proxy")
+	mockMisses = Prometheus.NewCounterVec("mimic_mock_misses_total",
+		"Requests with no matching recording.", "proxy")
+	sequenceCycles = Prometheus.NewCounterVec("mimic_mock_sequence_cycles_total",
+		"Times a mock sequence ran past its last recording and wrapped back to the first.", "proxy")
+	recordedInteractions = Prometheus.NewCounterVec("mimic_recorded_interactions_total",
+		"Interactions persisted while recording.", "proxy")
+	recordedBytes = Prometheus.NewCounterVec("mimic_recorded_bytes_total",
+		"Request and response bytes persisted while recording.", "proxy")
+	streamChunks = Prometheus.NewCounterVec("mimic_stream_chunks_recorded_total",
+		"Streaming response chunks persisted while recording.", "proxy")
+	dbWriteLatency = Prometheus.NewHistogramVec("mimic_db_write_duration_seconds",
+		"Time spent in database writes, by operation.", LatencyBuckets, "operation")
+	liveClients = Prometheus.NewGaugeVec("mimic_live_clients",
+		"Web UI clients subscribed to live events, by transport.", "transport")
+	replayResults = Prometheus.NewCounterVec("mimic_replay_results_total",
+		"Replayed interactions, by outcome.", "result")
+	limitedRequests = Prometheus.NewCounterVec("mimic_limited_requests_total",
+		"Requests turned away by a proxy's rate or concurrency limit.", "proxy", "reason")
+	injectedFaults = Prometheus.NewCounterVec("mimic_injected_faults_total",
+		"Faults injected into a proxy's traffic, by kind.", "proxy", "kind")
+	upstreamRetries = Prometheus.NewCounterVec("mimic_upstream_retries_total",
+		"Requests sent to the upstream again after a failed attempt.", "proxy")
+	circuitRejections = Prometheus.NewCounterVec("mimic_circuit_open_rejections_total",
+		"Requests answered with 503 without reaching the upstream, because its circuit was open.", "proxy")
+	sampledOut = Prometheus.NewCounterVec("mimic_sampled_out_total",
+		"Responses forwarded but not recorded because recording sampling passed them over.", "proxy")
+)
+
+// WritePrometheus renders the process-wide registry in the Prometheus text format
+func WritePrometheus(w io.Writer) error {
+	return Prometheus.WriteText(w)
+}
+
+// ObserveUpstreamLatency records how long an upstream took to answer a forwarded call
+func ObserveUpstreamLatency(proxyName, protocol string, start time.Time) {
+	upstreamLatency.ObserveDuration(start, proxyLabel(proxyName), protocol)
+}
+
+// RecordSequenceCycle counts a mock sequence wrapping back to its first recording
+func RecordSequenceCycle(proxyName string) {
+	sequenceCycles.Inc(proxyLabel(proxyName))
+}
+
+// RecordStreamChunks counts streaming chunks persisted for a proxy
+func RecordStreamChunks(proxyName string, count int) {
+	streamChunks.Add(float64(count), proxyLabel(proxyName))
+}
+
+// ObserveDBWrite records the duration of a database write started at start
+func ObserveDBWrite(operation string, start time.Time) {
+	dbWriteLatency.ObserveDuration(start, operation)
+}
+
+// LiveClientConnected counts a live event subscriber joining over transport ("websocket" or "sse")
+func LiveClientConnected(transport string) {
+	liveClients.Add(1, transport)
+}
+
+// LiveClientDisconnected counts a live event subscriber leaving
+func LiveClientDisconnected(transport string) {
+	liveClients.Add(-1, transport)
+}
+
+// RecordReplayResult counts one replayed interaction as passed or failed
+func RecordReplayResult(success bool) {
+	if success {
+		replayResults.Inc("success")
+	} else {
+		replayResults.Inc("failure")
+	}
+}
+
+func proxyLabel(proxyName string) string {
+	if proxyName == "" {
+		return "default"
+	}
+	return proxyName
+}
+
+func statusLabel(statusCode int) string {
+	return strconv.Itoa(statusCode)
+}
+
+// RecordLimited counts a request rejected by a proxy limit ("rate" or "concurrency")
+func RecordLimited(proxyName, reason string) {
+	limitedRequests.Inc(proxyLabel(proxyName), reason)
+}
+
+// RecordFault counts a fault injected into a proxy's traffic ("latency", "error", "drop", or one of
+// the mocked response faults)
+func RecordFault(proxyName, kind string) {
+	injectedFaults.Inc(proxyLabel(proxyName), kind)
+}
+
+// RecordSampledOut counts a response recording sampling passed over
+func RecordSampledOut(proxyName string) {
+	sampledOut.Inc(proxyLabel(proxyName))
+}
+
+// RecordUpstreamRetry counts a request sent to the upstream again
+func RecordUpstreamRetry(proxyName string) {
+	upstreamRetries.Inc(proxyLabel(proxyName))
+}
+
+// RecordCircuitRejection counts a request turned away by an open circuit
+func RecordCircuitRejection(proxyName string) {
+	circuitRejections.Inc(proxyLabel(proxyName))
+}
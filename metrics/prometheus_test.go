@@ -0,0 +1,84 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRegistryWriteText(t *testing.T) {
+	registry := NewRegistry()
+	requests := registry.NewCounterVec("test_requests_total", "Requests served.", "proxy", "code")
+	clients := registry.NewGaugeVec("test_clients", "Connected clients.", "transport")
+	latency := registry.NewHistogramVec("test_latency_seconds", "Latency.", []float64{0.1, 1}, "proxy")
+
+	requests.Inc("api", "200")
+	requests.Inc("api", "200")
+	requests.Inc("say \"hi\"", "500")
+	clients.Add(1, "websocket")
+	clients.Add(1, "websocket")
+	clients.Add(-1, "websocket")
+	latency.Observe(0.05, "api")
+	latency.Observe(0.5, "api")
+	latency.Observe(3, "api")
+
+	var out strings.Builder
+	if err := registry.WriteText(&out); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	text := out.String()
+
+	for _, line := range []string{
+		"# HELP test_requests_total Requests served.",
+		"# TYPE test_requests_total counter",
+		`test_requests_total{proxy="api",code="200"} 2`,
+		`test_requests_total{proxy="say \"hi\"",code="500"} 1`,
+		"# TYPE test_clients gauge",
+		`test_clients{transport="websocket"} 1`,
+		"# TYPE test_latency_seconds histogram",
+		`test_latency_seconds_bucket{proxy="api",le="0.1"} 1`,
+		`test_latency_seconds_bucket{proxy="api",le="1"} 2`,
+		`test_latency_seconds_bucket{proxy="api",le="+Inf"} 3`,
+		`test_latency_seconds_sum{proxy="api"} 3.55`,
+		`test_latency_seconds_count{proxy="api"} 3`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, text)
+		}
+	}
+
+	registry.Reset()
+	out.Reset()
+	registry.WriteText(&out)
+	if strings.Contains(out.String(), "test_requests_total{") {
+		t.Errorf("Expected Reset to clear series, got:\n%s", out.String())
+	}
+}
+
+func TestPackageHelpersFeedPrometheus(t *testing.T) {
+	Prometheus.Reset()
+	defer Prometheus.Reset()
+
+	RecordRequest("api", 201)
+	RecordMockMiss("api")
+	RecordSequenceCycle("api")
+	RecordStreamChunks("api", 4)
+	RecordReplayResult(false)
+
+	var out strings.Builder
+	if err := WritePrometheus(&out); err != nil {
+		t.Fatalf("WritePrometheus failed: %v", err)
+	}
+	text := out.String()
+
+	for _, line := range []string{
+		`mimic_proxy_requests_total{proxy="api",code="201"} 1`,
+		`mimic_mock_misses_total{proxy="api"} 1`,
+		`mimic_mock_sequence_cycles_total{proxy="api"} 1`,
+		`mimic_stream_chunks_recorded_total{proxy="api"} 4`,
+		`mimic_replay_results_total{result="failure"} 1`,
+	} {
+		if !strings.Contains(text, line+"\n") {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, text)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// Package mimictest serves recorded mimic sessions from inside Go tests.
+//
+//	srv := mimictest.FromExport(t, "testdata/payments.json")
+//	client := payments.NewClient(srv.URL)
+//	// Exercise client; srv is closed automatically when the test ends
+package mimictest
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"mimic/calllog"
+	"mimic/clock"
+	"mimic/config"
+	"mimic/export"
+	"mimic/mock"
+	"mimic/storage"
+
+	"google.golang.org/grpc"
+)
+
+// importedSessionName is the session export files are loaded into
+const importedSessionName = "mimictest"
+
+// Server is an in-process mock server answering from one recorded session
+type Server struct {
+	// URL is the base URL of the HTTP mock, e.g. http://127.0.0.1:54321
+	URL string
+	// GRPCAddr is the host:port of the gRPC mock; empty unless the session has gRPC interactions or WithGRPC was used
+	GRPCAddr string
+
+	httpServer *httptest.Server
+	grpcServer *grpc.Server
+	httpEngine *mock.MockEngine
+	database   *storage.Database
+	calls      *calllog.Log
+	clock      *clock.Clock
+	closed     bool
+}
+
+type options struct {
+	mockConfig config.MockConfig
+	grpc       bool
+}
+
+// Option customizes a Server
+type Option func(*options)
+
+// WithMatchingStrategy sets how requests are matched to recordings ("exact", "pattern", "fuzzy", "fuzzy-unordered", or "fuzzy-subset")
+func WithMatchingStrategy(strategy string) Option {
+	return func(o *options) { o.mockConfig.MatchingStrategy = strategy }
+}
+
+// WithSequenceMode sets how repeated requests pick among recordings ("ordered", "repeat-last", "strict",
+// or "random")
+func WithSequenceMode(mode string) Option {
+	return func(o *options) { o.mockConfig.SequenceMode = mode }
+}
+
+// WithMockConfig replaces the whole mock configuration
+func WithMockConfig(mockConfig config.MockConfig) Option {
+	return func(o *options) { o.mockConfig = mockConfig }
+}
+
+// WithGRPC starts the gRPC mock even if the session has no gRPC interactions yet
+func WithGRPC() Option {
+	return func(o *options) { o.grpc = true }
+}
+
+// FromExport serves the session in a mimic export file (.json or .json.gz)
+func FromExport(t testing.TB, path string, opts ...Option) *Server {
+	t.Helper()
+
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "mimictest.db"))
+	if err != nil {
+		t.Fatalf("mimictest: failed to create database: %v", err)
+	}
+
+	if err := export.NewExportManager(&config.Config{}, db).ImportSession(path, importedSessionName, "append"); err != nil {
+		db.Close()
+		t.Fatalf("mimictest: failed to import %s: %v", path, err)
+	}
+
+	return start(t, db, importedSessionName, opts)
+}
+
+// FromSession serves a session from an existing mimic database
+func FromSession(t testing.TB, dbPath, sessionName string, opts ...Option) *Server {
+	t.Helper()
+
+	db, err := storage.NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("mimictest: failed to open database %s: %v", dbPath, err)
+	}
+	if _, err := db.GetSession(sessionName); err != nil {
+		db.Close()
+		t.Fatalf("mimictest: %v", err)
+	}
+
+	return start(t, db, sessionName, opts)
+}
+
+func start(t testing.TB, db *storage.Database, sessionName string, opts []Option) *Server {
+	t.Helper()
+
+	o := &options{mockConfig: config.MockConfig{
+		MatchingStrategy: "exact",
+		SequenceMode:     "ordered",
+		NotFoundResponse: config.NotFoundResponseConfig{
+			Status: http.StatusNotFound,
+			Body:   map[string]interface{}{"error": "Recording not found"},
+		},
+	}}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	server, err := newServer(db, sessionName, o)
+	if err != nil {
+		db.Close()
+		t.Fatalf("mimictest: %v", err)
+	}
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newServer(db *storage.Database, sessionName string, o *options) (*Server, error) {
+	httpEngine, err := mock.NewMockEngine(config.ProxyConfig{
+		Name:        importedSessionName,
+		Protocol:    "http",
+		SessionName: sessionName,
+	}, o.mockConfig, db)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP mock: %w", err)
+	}
+
+	// Each server keeps its own calls and time, so servers in parallel tests do not see each other's
+	server := &Server{
+		httpEngine: httpEngine,
+		database:   db,
+		calls:      calllog.NewLog(calllog.DefaultCapacity),
+		clock:      clock.New(),
+	}
+	httpEngine.SetCallLog(server.calls)
+	httpEngine.SetClock(server.clock)
+	server.httpServer = httptest.NewServer(http.HandlerFunc(httpEngine.HandleRequest))
+	server.URL = server.httpServer.URL
+
+	if !o.grpc {
+		o.grpc, err = hasGRPCInteractions(db, sessionName)
+		if err != nil {
+			server.Close()
+			return nil, err
+		}
+	}
+	if o.grpc {
+		if err := server.startGRPC(db, sessionName, o.mockConfig); err != nil {
+			server.Close()
+			return nil, err
+		}
+	}
+
+	return server, nil
+}
+
+func (s *Server) startGRPC(db *storage.Database, sessionName string, mockConfig config.MockConfig) error {
+	grpcEngine, err := mock.NewMockEngine(config.ProxyConfig{
+		Name:        importedSessionName,
+		Protocol:    "grpc",
+		SessionName: sessionName,
+	}, mockConfig, db)
+	if err != nil {
+		return fmt.Errorf("failed to create gRPC mock: %w", err)
+	}
+	grpcEngine.SetCallLog(s.calls)
+	grpcEngine.SetClock(s.clock)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return fmt.Errorf("failed to listen for gRPC: %w", err)
+	}
+
+	s.grpcServer = grpcEngine.GetGRPCServer()
+	s.GRPCAddr = listener.Addr().String()
+	go s.grpcServer.Serve(listener)
+	return nil
+}
+
+func hasGRPCInteractions(db *storage.Database, sessionName string) (bool, error) {
+	session, err := db.GetSession(sessionName)
+	if err != nil {
+		return false, err
+	}
+	interactions, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load interactions: %w", err)
+	}
+	for _, interaction := range interactions {
+		if interaction.Protocol == "gRPC" {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Calls is the log of the requests the server received, for verifying what the code under test
+// sent: srv.Calls().Count(calllog.Matcher{Method: "POST", Path: "/orders"})
+func (s *Server) Calls() *calllog.Log {
+	return s.calls
+}
+
+// Clock is the server's clock. Setting or advancing it moves the dates, templates, and quotas of
+// this server only.
+func (s *Server) Clock() *clock.Clock {
+	return s.clock
+}
+
+// ResetSequences rewinds ordered playback so the next request gets the first recording again
+func (s *Server) ResetSequences() {
+	s.httpEngine.ResetSequenceState()
+}
+
+// ResetQuotas gives every client a full simulated quota again
+func (s *Server) ResetQuotas() {
+	s.httpEngine.ResetQuotas()
+}
+
+// ResetScenarios returns every scenario to its starting state
+func (s *Server) ResetScenarios() {
+	s.httpEngine.ResetScenarios("")
+}
+
+// Close shuts down the mock servers and releases the database; it is safe to call more than once
+func (s *Server) Close() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+
+	if s.grpcServer != nil {
+		s.grpcServer.Stop()
+	}
+	if s.httpServer != nil {
+		s.httpServer.Close()
+	}
+	s.database.Close()
+}
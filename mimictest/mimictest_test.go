@@ -0,0 +1,157 @@
+package mimictest
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"mimic/calllog"
+	"mimic/config"
+	"mimic/export"
+	"mimic/storage"
+)
+
+// recordSession writes a small recorded session to a fresh database
+func recordSession(t *testing.T) string {
+	t.Helper()
+
+	dbPath := filepath.Join(t.TempDir(), "recordings.db")
+	db, err := storage.NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	session, err := db.CreateSession("payments", "Recorded for mimictest")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	for _, body := range []string{`{"status":"pending"}`, `{"status":"settled"}`} {
+		if err := db.RecordInteraction(&storage.Interaction{
+			SessionID:       session.ID,
+			RequestID:       body,
+			Protocol:        "REST",
+			Method:          "GET",
+			Endpoint:        "/payments/42",
+			RequestHeaders:  `{"Accept-Encoding":"gzip","User-Agent":"Go-http-client/1.1"}`,
+			ResponseStatus:  http.StatusOK,
+			ResponseHeaders: `{"Content-Type":"application/json"}`,
+			ResponseBody:    []byte(body),
+		}); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+	return dbPath
+}
+
+func get(t *testing.T, url string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatalf("GET %s failed: %v", url, err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(body)
+}
+
+func TestFromExport(t *testing.T) {
+	dbPath := recordSession(t)
+	db, err := storage.NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	exportPath := filepath.Join(t.TempDir(), "payments.json")
+	if err := export.NewExportManager(&config.Config{}, db).ExportSession("payments", exportPath); err != nil {
+		t.Fatalf("Failed to export session: %v", err)
+	}
+	db.Close()
+
+	srv := FromExport(t, exportPath)
+
+	if status, body := get(t, srv.URL+"/payments/42"); status != http.StatusOK || body != `{"status":"pending"}` {
+		t.Errorf("Expected the first recording, got %d %s", status, body)
+	}
+	if _, body := get(t, srv.URL+"/payments/42"); body != `{"status":"settled"}` {
+		t.Errorf("Expected the second recording, got %s", body)
+	}
+
+	srv.ResetSequences()
+	if _, body := get(t, srv.URL+"/payments/42"); body != `{"status":"pending"}` {
+		t.Errorf("Expected the first recording after reset, got %s", body)
+	}
+
+	if status, _ := get(t, srv.URL+"/payments/missing"); status != http.StatusNotFound {
+		t.Errorf("Expected 404 for an unrecorded request, got %d", status)
+	}
+	if srv.GRPCAddr != "" {
+		t.Errorf("Expected no gRPC mock for an HTTP-only session, got %s", srv.GRPCAddr)
+	}
+}
+
+func TestFromSession(t *testing.T) {
+	dbPath := recordSession(t)
+
+	srv := FromSession(t, dbPath, "payments", WithGRPC())
+	defer srv.Close()
+
+	if status, body := get(t, srv.URL+"/payments/42"); status != http.StatusOK || body != `{"status":"pending"}` {
+		t.Errorf("Expected the first recording, got %d %s", status, body)
+	}
+	if srv.GRPCAddr == "" {
+		t.Error("Expected WithGRPC to start the gRPC mock")
+	}
+}
+
+func TestParallelServersKeepCallsAndClockApart(t *testing.T) {
+	t.Parallel()
+
+	first := FromSession(t, recordSession(t), "payments", WithSequenceMode("repeat-last"))
+	second := FromSession(t, recordSession(t), "payments", WithSequenceMode("repeat-last"))
+	frozen := time.Date(2030, 6, 1, 9, 30, 0, 0, time.UTC)
+	if err := first.Clock().Set(frozen, true); err != nil {
+		t.Fatalf("Failed to set clock: %v", err)
+	}
+
+	dates := make(chan string, 5)
+	var wg sync.WaitGroup
+	for _, srv := range []*Server{first, first, first, second, second} {
+		wg.Add(1)
+		go func(srv *Server) {
+			defer wg.Done()
+			resp, err := http.Get(srv.URL + "/payments/42")
+			if err != nil {
+				t.Errorf("GET failed: %v", err)
+				return
+			}
+			resp.Body.Close()
+			if srv == second {
+				dates <- resp.Header.Get("Date")
+			}
+		}(srv)
+	}
+	wg.Wait()
+	close(dates)
+
+	for _, test := range []struct {
+		srv   *Server
+		calls int
+	}{{first, 3}, {second, 2}} {
+		if count, err := test.srv.Calls().Count(calllog.Matcher{Method: "GET", Path: "/payments/42"}); err != nil || count != test.calls {
+			t.Errorf("Expected %d calls, got %d (%v)", test.calls, count, err)
+		}
+	}
+	for date := range dates {
+		if date == frozen.Format(http.TimeFormat) {
+			t.Errorf("Expected the other server's clock to leave this one's dates alone, got %s", date)
+		}
+	}
+	if second.Clock().Virtual() {
+		t.Error("Expected the other server's clock to stay on the wall clock")
+	}
+}
@@ -0,0 +1,242 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mimic/storage"
+)
+
+// Condition operators; numbers compare as numbers, anything else as text
+var conditionOperators = map[string]bool{
+	"==": true, "!=": true, ">": true, ">=": true, "<": true, "<=": true,
+	"contains": true, // Text containing the value, or an array with an element equal to it
+	"matches":  true, // Text matching the value as a regular expression
+	"exists":   true, // Takes no value
+	"missing":  true, // Takes no value
+}
+
+// condition is a parsed "when" condition of an interaction, such as body.amount > 1000,
+// query.page == 2, header.X-Tier != gold, form.plan matches ^pro, or path contains /v2/
+type condition struct {
+	source   string     // body, query, header, form, or path
+	name     string     // Query param, header, or form field; unset for body and path
+	bodyPath ignorePath // Path through the JSON request body
+	operator string
+	value    string
+	pattern  *regexp.Regexp
+}
+
+// parseCondition parses one condition: a request value, an operator, and unless the operator is
+// exists or missing, a value, which can be quoted to keep its spaces
+func parseCondition(text string) (condition, error) {
+	fields := strings.Fields(text)
+	if len(fields) < 2 {
+		return condition{}, fmt.Errorf("condition %q needs a request value and an operator", text)
+	}
+	c := condition{operator: fields[1]}
+	if !conditionOperators[c.operator] {
+		return condition{}, fmt.Errorf("condition %q has an unknown operator %s", text, c.operator)
+	}
+
+	source, name, _ := strings.Cut(fields[0], ".")
+	c.source = source
+	switch source {
+	case "body":
+		path, ok := parseBodyPath(name)
+		if !ok {
+			return condition{}, fmt.Errorf("condition %q names no body field", text)
+		}
+		c.bodyPath = path
+	case "query", "header", "form":
+		if name == "" {
+			return condition{}, fmt.Errorf("condition %q names no %s", text, source)
+		}
+		c.name = name
+	case "path":
+	default:
+		return condition{}, fmt.Errorf("condition %q reads an unknown request value %s (must be body, query, header, form, or path)", text, source)
+	}
+
+	if c.operator == "exists" || c.operator == "missing" {
+		if len(fields) > 2 {
+			return condition{}, fmt.Errorf("condition %q: %s takes no value", text, c.operator)
+		}
+		return c, nil
+	}
+	value := strings.TrimSpace(text)
+	value = strings.TrimSpace(value[len(fields[0]):])
+	value = strings.TrimSpace(value[len(fields[1]):])
+	if value == "" {
+		return condition{}, fmt.Errorf("condition %q has no value", text)
+	}
+	if strings.HasPrefix(value, `"`) {
+		unquoted, err := strconv.Unquote(value)
+		if err != nil {
+			return condition{}, fmt.Errorf("condition %q has a badly quoted value", text)
+		}
+		value = unquoted
+	}
+	c.value = value
+	if c.operator == "matches" {
+		pattern, err := regexp.Compile(value)
+		if err != nil {
+			return condition{}, fmt.Errorf("condition %q has an invalid pattern: %w", text, err)
+		}
+		c.pattern = pattern
+	}
+	return c, nil
+}
+
+// conditionsHold reports whether a request meets every condition of an interaction. Conditions
+// that do not parse are logged and never hold.
+func conditionsHold(conditions []string, r *http.Request) bool {
+	request := newTemplateRequest(r)
+	for _, text := range conditions {
+		c, err := parseCondition(text)
+		if err != nil {
+			log.Printf("Ignoring interaction with an invalid condition: %v", err)
+			return false
+		}
+		if !c.holds(request) {
+			return false
+		}
+	}
+	return true
+}
+
+// holds reports whether the condition holds for a request
+func (c condition) holds(request templateRequest) bool {
+	actual, found := c.lookup(request)
+	switch c.operator {
+	case "exists":
+		return found
+	case "missing":
+		return !found
+	}
+	if !found {
+		return c.operator == "!="
+	}
+
+	text := conditionText(actual)
+	switch c.operator {
+	case "contains":
+		if elements, ok := actual.([]interface{}); ok {
+			for _, element := range elements {
+				if conditionText(element) == c.value {
+					return true
+				}
+			}
+			return false
+		}
+		return strings.Contains(text, c.value)
+	case "matches":
+		return c.pattern.MatchString(text)
+	}
+
+	order := strings.Compare(text, c.value)
+	if number, err := strconv.ParseFloat(text, 64); err == nil {
+		if expected, err := strconv.ParseFloat(c.value, 64); err == nil {
+			order = compareNumbers(number, expected)
+		}
+	}
+	switch c.operator {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case ">":
+		return order > 0
+	case ">=":
+		return order >= 0
+	case "<":
+		return order < 0
+	default: // <=
+		return order <= 0
+	}
+}
+
+// lookup finds the request value a condition reads, reporting whether the request has it
+func (c condition) lookup(request templateRequest) (interface{}, bool) {
+	switch c.source {
+	case "body":
+		return lookupBodyPath(request.Body, c.bodyPath)
+	case "query":
+		values, ok := request.Query[c.name]
+		return strings.Join(values, ","), ok
+	case "header":
+		values := request.Headers.Values(c.name)
+		return strings.Join(values, ", "), len(values) > 0
+	case "form":
+		values, ok := request.Form[c.name]
+		return strings.Join(values, ","), ok
+	default: // path
+		return request.Path, true
+	}
+}
+
+// lookupBodyPath finds the value at a path of a decoded JSON body; wildcards find nothing, as a
+// condition reads one value
+func lookupBodyPath(value interface{}, path ignorePath) (interface{}, bool) {
+	for _, segment := range path {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			field, ok := current[segment]
+			if !ok || segment == "*" {
+				return nil, false
+			}
+			value = field
+		case []interface{}:
+			if !isIndexSegment(segment) {
+				return nil, false
+			}
+			index, err := strconv.Atoi(segment[1 : len(segment)-1])
+			if err != nil || index < 0 || index >= len(current) {
+				return nil, false
+			}
+			value = current[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+// conditionText is a request value as text: strings as they are, anything else as JSON
+func conditionText(value interface{}) string {
+	if text, ok := value.(string); ok {
+		return text
+	}
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// preferConditional narrows matching interactions to those with conditions, when any has them:
+// an interaction whose conditions hold is more specific than one that matches any request
+func preferConditional(interactions []storage.Interaction) []storage.Interaction {
+	var conditional []storage.Interaction
+	for _, interaction := range interactions {
+		if len(interaction.Conditions()) > 0 {
+			conditional = append(conditional, interaction)
+		}
+	}
+	if len(conditional) == 0 {
+		return interactions
+	}
+	return conditional
+}
@@ -0,0 +1,29 @@
+package mock
+
+import (
+	"net/http"
+
+	"mimic/storage"
+)
+
+// plainBody decompresses a request body sent gzip or deflate encoded, so it is compared with the
+// decompressed recording; bodies that fail to decode are compared as they are
+func plainBody(body []byte, header http.Header) []byte {
+	encoding := header.Get("Content-Encoding")
+	if !storage.Compressed(encoding) {
+		return body
+	}
+	decoded, err := storage.Decompress(body, encoding)
+	if err != nil {
+		return body
+	}
+	return decoded
+}
+
+// normalizeEncodingHeaders drops the Content-Length of a compressed body, which varies with how
+// the client compressed it
+func normalizeEncodingHeaders(headers map[string]string) {
+	if storage.Compressed(headers["Content-Encoding"]) {
+		delete(headers, "Content-Length")
+	}
+}
@@ -0,0 +1,99 @@
+package mock
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"mimic/config"
+	"mimic/fault"
+)
+
+// Slow drip defaults, applied when the faults leave them at zero
+const (
+	defaultDripBytes    = 16
+	defaultDripInterval = 100 * time.Millisecond
+)
+
+// pickFault picks the fault, if any, for a mocked response under the endpoint's mock faults
+func (m *MockEngine) pickFault() fault.Outcome {
+	if m.mockConfig == nil || m.mockConfig.Faults == (config.MockFaultConfig{}) {
+		return fault.Outcome{}
+	}
+	return fault.PickResponse(m.proxyConfig.Name, m.mockConfig.Faults)
+}
+
+// answerFault answers a request with an injected error or reset in place of its recording,
+// reporting whether it did; faults that break the recorded body are left to sendMockResponse
+func (m *MockEngine) answerFault(w http.ResponseWriter, outcome fault.Outcome) bool {
+	switch outcome.Kind {
+	case fault.KindError:
+		http.Error(w, fmt.Sprintf("Fault injected by mock proxy '%s'", m.proxyConfig.Name), outcome.Status)
+		return true
+	case fault.KindReset:
+		resetConnection(w)
+		return true
+	}
+	return false
+}
+
+// resetConnection drops the client connection with a TCP reset rather than a clean close; where
+// the connection cannot be taken over (HTTP/2), the stream is reset instead
+func resetConnection(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	if tcpConn, ok := conn.(*net.TCPConn); ok {
+		tcpConn.SetLinger(0)
+	}
+	conn.Close()
+}
+
+// writeFaultyBody sends a recorded body broken the way a fault asks: cut in half and sent as if
+// complete, cut off halfway with the connection closed, or dripped out a few bytes at a time
+func (m *MockEngine) writeFaultyBody(ctx context.Context, w http.ResponseWriter, status int, body []byte, outcome fault.Outcome) error {
+	switch outcome.Kind {
+	case fault.KindMalformed:
+		body = body[:len(body)/2]
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		if _, err := w.Write(body); err != nil {
+			return fmt.Errorf("failed to write response body: %w", err)
+		}
+	case fault.KindTruncate:
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		if _, err := w.Write(body[:len(body)/2]); err != nil {
+			return fmt.Errorf("failed to write response body: %w", err)
+		}
+		http.NewResponseController(w).Flush()
+		panic(http.ErrAbortHandler) // Closes the connection short of the promised length
+	case fault.KindDrip:
+		chunkSize, interval := m.mockConfig.Faults.DripBytes, time.Duration(m.mockConfig.Faults.DripIntervalMS)*time.Millisecond
+		if chunkSize <= 0 {
+			chunkSize = defaultDripBytes
+		}
+		if interval <= 0 {
+			interval = defaultDripInterval
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+		w.WriteHeader(status)
+		for len(body) > 0 {
+			n := min(chunkSize, len(body))
+			if _, err := w.Write(body[:n]); err != nil {
+				return fmt.Errorf("failed to write response body: %w", err)
+			}
+			http.NewResponseController(w).Flush()
+			if body = body[n:]; len(body) > 0 {
+				if err := delayResponse(ctx, interval); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
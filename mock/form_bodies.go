@@ -0,0 +1,171 @@
+package mock
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/url"
+	"reflect"
+)
+
+// formPart is one part of a multipart body, without the boundary it was sent with
+type formPart struct {
+	name        string
+	filename    string
+	contentType string
+	data        []byte
+}
+
+// matchesFormBody compares form submissions by their fields rather than their bytes, as the
+// order of url-encoded fields and the boundary of a multipart body vary between clients and
+// requests. ok is false when the request is not a form or either body cannot be parsed as one.
+func (m *MockEngine) matchesFormBody(recordedBody, currentBody []byte, contentType string) (matched, ok bool) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false, false
+	}
+	switch mediaType {
+	case "application/x-www-form-urlencoded":
+		recorded, err := url.ParseQuery(string(recordedBody))
+		if err != nil {
+			return false, false
+		}
+		current, err := url.ParseQuery(string(currentBody))
+		if err != nil {
+			return false, false
+		}
+		return m.matchesFormValues(recorded, current), true
+	case "multipart/form-data":
+		recorded, err := readFormParts(recordedBody, recordedBoundary(recordedBody))
+		if err != nil {
+			return false, false
+		}
+		current, err := readFormParts(currentBody, params["boundary"])
+		if err != nil {
+			return false, false
+		}
+		return m.matchesFormParts(recorded, current), true
+	}
+	return false, false
+}
+
+// matchesFormValues compares url-encoded fields; a field's values must match in order. Fuzzy
+// matching skips ignored fields and normalizes UUIDs, and fuzzy-subset lets the request have
+// fields the recording lacks.
+func (m *MockEngine) matchesFormValues(recorded, current url.Values) bool {
+	fuzzy := m.fuzzyMatching()
+	for _, values := range []url.Values{recorded, current} {
+		for key := range values {
+			if m.shouldIgnoreField([]string{key}) {
+				delete(values, key)
+			}
+		}
+	}
+	if len(recorded) != len(current) && m.mockConfig.MatchingStrategy != "fuzzy-subset" {
+		return false
+	}
+	for key, values := range recorded {
+		if !fuzzy {
+			if !reflect.DeepEqual(values, current[key]) {
+				return false
+			}
+			continue
+		}
+		if len(values) != len(current[key]) {
+			return false
+		}
+		for i, value := range values {
+			if normalizeStringValue(value) != normalizeStringValue(current[key][i]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// matchesFormParts compares multipart bodies part by part, in order, by field name, file name,
+// content type, and content. Fuzzy matching skips the content of ignored fields and compares
+// JSON parts as it does JSON bodies.
+func (m *MockEngine) matchesFormParts(recorded, current []formPart) bool {
+	if len(recorded) != len(current) {
+		return false
+	}
+	for i, part := range recorded {
+		other := current[i]
+		if part.name != other.name || part.filename != other.filename || part.contentType != other.contentType {
+			return false
+		}
+		if m.shouldIgnoreField([]string{part.name}) {
+			continue
+		}
+		if m.fuzzyMatching() {
+			if !m.fuzzyMatchBody(part.data, other.data) {
+				return false
+			}
+		} else if !bytes.Equal(part.data, other.data) {
+			return false
+		}
+	}
+	return true
+}
+
+// readFormParts splits a multipart body into its parts
+func readFormParts(body []byte, boundary string) ([]formPart, error) {
+	reader := multipart.NewReader(bytes.NewReader(body), boundary)
+	var parts []formPart
+	for {
+		part, err := reader.NextRawPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, formPart{
+			name:        part.FormName(),
+			filename:    part.FileName(),
+			contentType: part.Header.Get("Content-Type"),
+			data:        data,
+		})
+	}
+}
+
+// recordedBoundary finds the boundary of a recorded multipart body from its first delimiter
+// line, since the recording's Content-Type is not at hand
+func recordedBoundary(body []byte) string {
+	line := body
+	if end := bytes.IndexByte(body, '\n'); end >= 0 {
+		line = body[:end]
+	}
+	line = bytes.TrimRight(line, "\r \t")
+	if !bytes.HasPrefix(line, []byte("--")) {
+		return ""
+	}
+	return string(line[2:])
+}
+
+// normalizeFormHeaders drops what varies with a multipart body's boundary from its headers: the
+// boundary itself and the length of the body
+func normalizeFormHeaders(headers map[string]string) {
+	mediaType, _, err := mime.ParseMediaType(headers["Content-Type"])
+	if err != nil || mediaType != "multipart/form-data" {
+		return
+	}
+	headers["Content-Type"] = mediaType
+	delete(headers, "Content-Length")
+}
+
+// formSignatureBody replaces a multipart body's boundary so that requests sending the same form
+// share a sequence signature
+func formSignatureBody(body []byte, contentType string) []byte {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || mediaType != "multipart/form-data" || params["boundary"] == "" {
+		return body
+	}
+	return bytes.ReplaceAll(body, []byte(params["boundary"]), []byte("boundary"))
+}
@@ -0,0 +1,113 @@
+package mock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+
+	"mimic/accesslog"
+	"mimic/calllog"
+	"mimic/config"
+	"mimic/metrics"
+	"mimic/protoschema"
+	"mimic/storage"
+	"mimic/webhook"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// newMatcher returns an engine that only matches requests, with a mock config's settings and
+// rules, for serving paths that do not otherwise have a MockEngine
+func newMatcher(mockConfig config.MockConfig) (*MockEngine, error) {
+	rules, err := compileMatchRules(mockConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile mock rules: %w", err)
+	}
+	return &MockEngine{mockConfig: &mockConfig, rules: rules}, nil
+}
+
+// matchGRPCRequest narrows the recorded calls of a method to those whose request message matches
+// the live one. Messages are decoded with the loaded protobuf descriptors and compared like JSON
+// bodies, so the fuzzy strategies apply and fuzzy_ignore_fields names proto fields by their JSON
+// names. Without a descriptor for the method, recordings of the same bytes are preferred, and
+// every recording is a candidate when there are none.
+func (m *MockEngine) matchGRPCRequest(fullMethod string, interactions []storage.Interaction, data []byte) []storage.Interaction {
+	matcher := m
+	if rule := m.resolveRule("", fullMethod); rule != nil {
+		matcher = m.withSettings(rule.settings)
+	}
+
+	if _, ok := protoschema.Default.Method(fullMethod); !ok {
+		var same []storage.Interaction
+		for _, interaction := range interactions {
+			if bytes.Equal(interaction.RequestBody, data) {
+				same = append(same, interaction)
+			}
+		}
+		if len(same) == 0 {
+			return interactions
+		}
+		return same
+	}
+
+	current, err := protoschema.Default.Decode(fullMethod, protoschema.Request, data)
+	if err != nil {
+		log.Printf("Error decoding gRPC request for matching: %v", err)
+		return nil
+	}
+	var matches []storage.Interaction
+	for _, interaction := range interactions {
+		recorded, err := protoschema.Default.Decode(fullMethod, protoschema.Request, interaction.RequestBody)
+		if err != nil {
+			continue
+		}
+		if matcher.matchesGRPCMessage(recorded, current) {
+			matches = append(matches, interaction)
+		}
+	}
+	return matches
+}
+
+// matchesGRPCMessage compares two messages rendered as protobuf JSON. Exact matching compares
+// their fields rather than their bytes, since map entries can be encoded in any order.
+func (m *MockEngine) matchesGRPCMessage(recorded, current []byte) bool {
+	if m.fuzzyMatching() {
+		return m.fuzzyMatchBody(recorded, current)
+	}
+	var recordedValue, currentValue interface{}
+	if json.Unmarshal(recorded, &recordedValue) != nil || json.Unmarshal(current, &currentValue) != nil {
+		return false
+	}
+	return reflect.DeepEqual(recordedValue, currentValue)
+}
+
+// recordGRPCMockMiss reports a gRPC call no recording answers
+func recordGRPCMockMiss(ctx context.Context, proxyName string, session *storage.Session, fullMethod string) {
+	metrics.RecordMockMiss(proxyName)
+	accesslog.Annotate(ctx, session.SessionName, accesslog.MatchMiss)
+	webhook.MockMiss(proxyName, session.SessionName, "gRPC", fullMethod, fullMethod)
+}
+
+// recordGRPCCall adds a gRPC call to a call log, with its metadata as headers and the request
+// message, when it was received, as the body
+func recordGRPCCall(calls *calllog.Log, ctx context.Context, proxyName, fullMethod string, message []byte, matched bool) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	headers := http.Header{}
+	for key, values := range md {
+		for _, value := range values {
+			headers.Add(key, value)
+		}
+	}
+	calls.Record(calllog.Call{
+		Proxy:   proxyName,
+		Method:  fullMethod,
+		Path:    fullMethod,
+		Headers: headers,
+		Body:    string(message),
+		Matched: matched,
+	})
+}
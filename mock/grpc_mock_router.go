@@ -5,12 +5,17 @@ import (
 	"log"
 	"regexp"
 	"strings"
+	"sync"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"mimic/accesslog"
 	"mimic/config"
+	"mimic/fault"
+	"mimic/metrics"
 	"mimic/proxy"
+	"mimic/ratelimit"
 	"mimic/storage"
 )
 
@@ -25,23 +30,29 @@ type GRPCMockRoute struct {
 
 // GRPCMockRouter handles routing gRPC mock calls based on service/method patterns
 type GRPCMockRouter struct {
+	mutex        sync.RWMutex // Guards routes and defaultRoute against session swaps
 	routes       []*GRPCMockRoute
 	database     *storage.Database
-	grpcHandler  *proxy.GRPCHandler
+	matcher      *MockEngine // Matches request messages with the mock config's settings
 	webServer    proxy.WebBroadcaster
 	defaultRoute *GRPCMockRoute // Fallback route if no patterns match
 }
 
 // NewGRPCMockRouter creates a new gRPC mock router with multiple routes
-func NewGRPCMockRouter(routeConfigs map[string]config.ProxyConfig, db *storage.Database, webServer proxy.WebBroadcaster) (*GRPCMockRouter, error) {
+func NewGRPCMockRouter(routeConfigs map[string]config.ProxyConfig, mockConfig config.MockConfig, db *storage.Database, webServer proxy.WebBroadcaster) (*GRPCMockRouter, error) {
+	matcher, err := newMatcher(mockConfig)
+	if err != nil {
+		return nil, err
+	}
 	router := &GRPCMockRouter{
-		routes:      make([]*GRPCMockRoute, 0),
-		database:    db,
-		grpcHandler: proxy.NewGRPCHandler([]string{}), // Use empty redact patterns for now
-		webServer:   webServer,
+		routes:    make([]*GRPCMockRoute, 0),
+		database:  db,
+		matcher:   matcher,
+		webServer: webServer,
 	}
 
 	for name, proxyConfig := range routeConfigs {
+		proxyConfig := proxyConfig // Each route keeps its own copy
 		session, err := db.GetOrCreateSession(proxyConfig.SessionName, fmt.Sprintf("Mock session for %s", name))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create session for mock route %s: %w", name, err)
@@ -114,13 +125,31 @@ func (r *GRPCMockRouter) GetUnknownServiceHandler() grpc.StreamHandler {
 
 		log.Printf("gRPC Mock Router: matched route '%s' for %s", route.Name, fullMethodName)
 
+		accesslog.SetProxy(stream.Context(), route.Name)
+		release, err := ratelimit.Acquire(route.Name)
+		if err != nil {
+			metrics.RecordGRPCRequest(route.Name, err)
+			return err
+		}
+		defer release()
+
+		if err := fault.InjectGRPC(stream.Context(), route.Name); err != nil {
+			metrics.RecordGRPCRequest(route.Name, err)
+			return err
+		}
+
 		// Handle the mock request using the found route's session
-		return handleGRPCMockRequest(stream, r.database, route.Session, r.grpcHandler, r.webServer)
+		err = handleGRPCMockRequest(stream, route.Name, route.Config.Consumers, r.database, route.Session, r.matcher, r.webServer)
+		metrics.RecordGRPCRequest(route.Name, err)
+		return err
 	}
 }
 
 // findRoute finds the best matching route for a service/method combination
 func (r *GRPCMockRouter) findRoute(serviceName, methodName, fullMethodName string) *GRPCMockRoute {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
 	// Try to find exact pattern matches first
 	for _, route := range r.routes {
 		if r.routeMatches(route, serviceName, methodName, fullMethodName) {
@@ -164,6 +193,9 @@ func (r *GRPCMockRouter) routeMatches(route *GRPCMockRoute, serviceName, methodN
 
 // GetRoutes returns all configured routes for debugging/monitoring
 func (r *GRPCMockRouter) GetRoutes() []*GRPCMockRoute {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
 	routes := make([]*GRPCMockRoute, len(r.routes))
 	copy(routes, r.routes)
 
@@ -173,3 +205,26 @@ func (r *GRPCMockRouter) GetRoutes() []*GRPCMockRoute {
 
 	return routes
 }
+
+// SetSession switches a route to serve another session, reporting whether the route exists.
+// Routes are replaced rather than modified so calls already routed keep their session.
+func (r *GRPCMockRouter) SetSession(routeName string, session *storage.Session) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if r.defaultRoute != nil && r.defaultRoute.Name == routeName {
+		swapped := *r.defaultRoute
+		swapped.Session = session
+		r.defaultRoute = &swapped
+		return true
+	}
+	for i, route := range r.routes {
+		if route.Name == routeName {
+			swapped := *route
+			swapped.Session = session
+			r.routes[i] = &swapped
+			return true
+		}
+	}
+	return false
+}
@@ -3,8 +3,14 @@ package mock
 import (
 	"testing"
 
+	"mimic/adminpb"
 	"mimic/config"
+	"mimic/protoschema"
 	"mimic/storage"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
 )
 
 func TestMockEngineWithGRPC(t *testing.T) {
@@ -83,3 +89,48 @@ func TestMockEngineWithHTTP(t *testing.T) {
 		t.Error("Expected gRPC server to be nil for HTTP protocol")
 	}
 }
+
+func TestMatchGRPCRequest(t *testing.T) {
+	const method = "/mimic.admin.v1.AdminService/CreateSession"
+	message := func(name, description string) []byte {
+		data, _ := proto.Marshal(&adminpb.CreateSessionRequest{Name: name, Description: description})
+		return data
+	}
+	interactions := []storage.Interaction{
+		{RequestID: "checkout", Method: method, RequestBody: message("checkout", "first")},
+		{RequestID: "refunds", Method: method, RequestBody: message("refunds", "first")},
+	}
+	matched := func(engine *MockEngine, data []byte) []string {
+		var ids []string
+		for _, interaction := range engine.matchGRPCRequest(method, interactions, data) {
+			ids = append(ids, interaction.RequestID)
+		}
+		return ids
+	}
+
+	exact, _ := newMatcher(config.MockConfig{MatchingStrategy: "exact"})
+	// Without descriptors, recordings of the same bytes are preferred over the rest
+	if ids := matched(exact, message("refunds", "first")); len(ids) != 1 || ids[0] != "refunds" {
+		t.Errorf("Expected the recording with the same bytes, got %v", ids)
+	}
+	if ids := matched(exact, message("other", "")); len(ids) != 2 {
+		t.Errorf("Expected every recording without descriptors, got %v", ids)
+	}
+
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(adminpb.File_adminpb_admin_proto)}}
+	if err := protoschema.Default.Add(set); err != nil {
+		t.Fatalf("Failed to add descriptors: %v", err)
+	}
+	t.Cleanup(protoschema.Default.Reset)
+
+	if ids := matched(exact, message("other", "")); len(ids) != 0 {
+		t.Errorf("Expected no recording for a different message, got %v", ids)
+	}
+	if ids := matched(exact, message("refunds", "second")); len(ids) != 0 {
+		t.Errorf("Expected exact matching to compare every field, got %v", ids)
+	}
+	fuzzy, _ := newMatcher(config.MockConfig{MatchingStrategy: "fuzzy", FuzzyIgnoreFields: []string{"description"}})
+	if ids := matched(fuzzy, message("refunds", "second")); len(ids) != 1 || ids[0] != "refunds" {
+		t.Errorf("Expected the ignored field to be skipped, got %v", ids)
+	}
+}
@@ -0,0 +1,93 @@
+package mock
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// canonicalHeaders rewrites headers for comparison: names in canonical form, with headers whose
+// names differ only in case merged, and each value split into its elements, sorted, and
+// deduplicated, so the casing and order different clients send them in does not matter
+func canonicalHeaders(headers map[string]string) map[string]string {
+	elements := make(map[string][]string, len(headers))
+	for name, value := range headers {
+		name = http.CanonicalHeaderKey(name)
+		elements[name] = append(elements[name], splitHeaderValue(value, headerSeparator(name))...)
+	}
+	canonical := make(map[string]string, len(elements))
+	for name, values := range elements {
+		sort.Strings(values)
+		unique := values[:0]
+		for i, value := range values {
+			if i == 0 || value != values[i-1] {
+				unique = append(unique, value)
+			}
+		}
+		canonical[name] = strings.Join(unique, string(headerSeparator(name))+" ")
+	}
+	return canonical
+}
+
+// headerSeparator is the character between a header's elements: a semicolon between cookies, a
+// comma otherwise
+func headerSeparator(name string) byte {
+	if name == "Cookie" {
+		return ';'
+	}
+	return ','
+}
+
+// splitHeaderValue splits a header value into its trimmed, non-empty elements. Separators inside
+// quoted strings and parenthesized comments, like those of a User-Agent, do not split the value.
+func splitHeaderValue(value string, separator byte) []string {
+	var elements []string
+	quoted, depth, start := false, 0, 0
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; {
+		case quoted && c == '\\':
+			i++
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == '(':
+			depth++
+		case !quoted && c == ')' && depth > 0:
+			depth--
+		case !quoted && depth == 0 && c == separator:
+			elements = appendHeaderElement(elements, value[start:i])
+			start = i + 1
+		}
+	}
+	return appendHeaderElement(elements, value[start:])
+}
+
+// appendHeaderElement appends an element of a header value, trimmed, unless it is empty
+func appendHeaderElement(elements []string, element string) []string {
+	if element = strings.TrimSpace(element); element != "" {
+		elements = append(elements, element)
+	}
+	return elements
+}
+
+// selectHeaders drops the headers that take no part in matching: those on the ignore_headers list
+// and, when match_headers is set, every header not on it
+func (m *MockEngine) selectHeaders(headers map[string]string) {
+	if m.mockConfig == nil {
+		return
+	}
+	for _, header := range m.mockConfig.IgnoreHeaders {
+		delete(headers, http.CanonicalHeaderKey(header))
+	}
+	if len(m.mockConfig.MatchHeaders) == 0 {
+		return
+	}
+	matched := make(map[string]bool, len(m.mockConfig.MatchHeaders))
+	for _, header := range m.mockConfig.MatchHeaders {
+		matched[http.CanonicalHeaderKey(header)] = true
+	}
+	for header := range headers {
+		if !matched[http.CanonicalHeaderKey(header)] {
+			delete(headers, header)
+		}
+	}
+}
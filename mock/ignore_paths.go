@@ -0,0 +1,77 @@
+package mock
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ignorePath is a fuzzy_ignore_fields entry naming values by their path through a JSON body, such
+// as contents[*].parts[*].functionResponse.response.result. Segments are field names, * for any
+// field, [N] for an array element, and [*] for any element.
+type ignorePath []string
+
+// parseIgnorePath parses an ignore entry written as a path. Entries without a dot or bracket are
+// plain field names, ignored at any depth, and are not paths.
+func parseIgnorePath(expr string) (ignorePath, bool) {
+	expr = strings.TrimPrefix(strings.TrimPrefix(expr, "$"), ".")
+	if !strings.ContainsAny(expr, ".[") {
+		return nil, false
+	}
+
+	var path ignorePath
+	for _, part := range strings.Split(expr, ".") {
+		for part != "" {
+			open := strings.IndexByte(part, '[')
+			if open < 0 {
+				path = append(path, part)
+				break
+			}
+			if open > 0 {
+				path = append(path, part[:open])
+			}
+			end := strings.IndexByte(part[open:], ']')
+			if end < 0 {
+				return nil, false
+			}
+			path = append(path, part[open:open+end+1])
+			part = part[open+end+1:]
+		}
+	}
+	return path, len(path) > 0
+}
+
+// matches reports whether the path names the value at a path of the body
+func (p ignorePath) matches(path []string) bool {
+	if len(p) != len(path) {
+		return false
+	}
+	for i, segment := range p {
+		switch {
+		case segment == "[*]":
+			if !isIndexSegment(path[i]) {
+				return false
+			}
+		case segment == "*":
+			if isIndexSegment(path[i]) {
+				return false
+			}
+		case segment != path[i]:
+			return false
+		}
+	}
+	return true
+}
+
+// indexSegment is the path segment of an array element
+func indexSegment(i int) string {
+	return "[" + strconv.Itoa(i) + "]"
+}
+
+func isIndexSegment(segment string) bool {
+	return strings.HasPrefix(segment, "[") && strings.HasSuffix(segment, "]")
+}
+
+// appendPath extends a path without sharing its backing array with other paths
+func appendPath(path []string, segment string) []string {
+	return append(path[:len(path):len(path)], segment)
+}
@@ -0,0 +1,108 @@
+package mock
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+
+	"mimic/proxy"
+	"mimic/storage"
+)
+
+// Latency modes for mocked responses
+const (
+	LatencyNone       = "none"
+	LatencyRecorded   = "recorded"   // As long as the upstream took to answer the recording
+	LatencyFixed      = "fixed"      // The same delay for every response
+	LatencyRandom     = "random"     // Anywhere between min_ms and max_ms
+	LatencyPercentile = "percentile" // A percentile of the durations recorded for the endpoint
+)
+
+// responseDelay is how long to hold a response back under the latency settings. endpoint holds
+// every recording of the request's method and path, for percentile delays.
+func (m *MockEngine) responseDelay(selected *storage.Interaction, endpoint []storage.Interaction) time.Duration {
+	if m.mockConfig == nil {
+		return 0
+	}
+	latency := m.mockConfig.Latency
+	scale := latency.Scale
+	if scale == 0 {
+		scale = 1
+	}
+
+	switch latency.Mode {
+	case LatencyRecorded:
+		// Chunks replayed with their original timing already take as long as the upstream did
+		if selected.IsStreaming && m.streamPacing().Speed > 0 {
+			return 0
+		}
+		if _, duration, ok := selected.Timing(); ok {
+			return time.Duration(float64(duration) * scale)
+		}
+	case LatencyFixed:
+		return time.Duration(latency.FixedMS) * time.Millisecond
+	case LatencyRandom:
+		spread := latency.MaxMS - latency.MinMS
+		delay := latency.MinMS
+		if spread > 0 {
+			delay += rand.Intn(spread + 1)
+		}
+		return time.Duration(delay) * time.Millisecond
+	case LatencyPercentile:
+		var durations []time.Duration
+		for i := range endpoint {
+			if _, duration, ok := endpoint[i].Timing(); ok {
+				durations = append(durations, duration)
+			}
+		}
+		return time.Duration(float64(percentile(durations, latency.Percentile)) * scale)
+	}
+	return 0
+}
+
+// streamPacing is how replayed streaming responses follow their recorded timing. Chunks keep
+// their recorded gaps when respect_streaming_timing is on or a speed is set, unless instant.
+func (m *MockEngine) streamPacing() proxy.StreamPacing {
+	if m.mockConfig == nil {
+		return proxy.StreamPacing{}
+	}
+	streaming := m.mockConfig.Streaming
+	if streaming.Instant || (!m.mockConfig.RespectStreamingTiming && streaming.Speed == 0) {
+		return proxy.StreamPacing{}
+	}
+	speed := streaming.Speed
+	if speed == 0 {
+		speed = 1
+	}
+	return proxy.StreamPacing{Speed: speed, MaxDelay: time.Duration(streaming.MaxChunkDelayMS) * time.Millisecond}
+}
+
+// percentile returns the nearest-rank percentile of durations, 0 when there are none
+func percentile(durations []time.Duration, p float64) time.Duration {
+	if len(durations) == 0 {
+		return 0
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	rank := int(math.Ceil(p / 100 * float64(len(durations))))
+	if rank < 1 {
+		rank = 1
+	}
+	return durations[min(rank, len(durations))-1]
+}
+
+// delayResponse waits out a response delay, returning ctx's error if the client gives up first
+func delayResponse(ctx context.Context, delay time.Duration) error {
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
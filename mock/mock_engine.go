@@ -2,19 +2,35 @@ package mock
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"math"
+	"math/rand"
 	"net"
 	"net/http"
 	"regexp"
+	"strconv"
 	"strings"
-	"sync"
+	"time"
 
+	"mimic/accesslog"
+	"mimic/calllog"
+	"mimic/clock"
 	"mimic/config"
+	"mimic/conformance"
+	"mimic/consumer"
+	"mimic/fault"
+	"mimic/metrics"
+	"mimic/nearmiss"
 	"mimic/proxy"
+	"mimic/redact"
 	"mimic/storage"
+	"mimic/tag"
+	"mimic/webhook"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
@@ -22,6 +38,17 @@ import (
 	"google.golang.org/grpc/status"
 )
 
+// What happens to requests no recording matches
+const (
+	FallbackNone        = "none"        // Answered not found
+	FallbackPassthrough = "passthrough" // Forwarded to the proxy's target
+	FallbackRecord      = "record"      // Forwarded and recorded, so the next one is mocked
+)
+
+// TruncatedHeader marks mock responses whose recorded body is only a prefix of the one the
+// upstream sent, cut at recording.max_response_body_bytes or database.max_body_bytes
+const TruncatedHeader = "X-Mimic-Truncated"
+
 // UUID pattern for fuzzy matching - matches standard UUID format
 var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 
@@ -61,21 +88,29 @@ func (m *mockRawMessage) Size() int {
 }
 
 type MockEngine struct {
-	proxyConfig   *config.ProxyConfig
-	mockConfig    *config.MockConfig
-	database      *storage.Database
-	restHandler   *proxy.RESTHandler
-	grpcHandler   *proxy.GRPCHandler
-	grpcServer    *grpc.Server
-	session       *storage.Session
-	sequenceState map[string]int
-	sequenceMutex sync.RWMutex
-	webServer     WebBroadcaster
+	proxyConfig *config.ProxyConfig
+	mockConfig  *config.MockConfig
+	database    *storage.Database
+	restHandler *proxy.RESTHandler
+	grpcHandler *proxy.GRPCHandler
+	grpcServer  *grpc.Server
+	session     *storage.Session
+	sequences   sequenceStore
+	scenarios   *scenarioStates
+	quotas      *quotaCounters
+	webServer   WebBroadcaster
+	rules       []*matchRule
+	fallback    *proxy.ProxyEngine // Forwards misses to the target, with mock.fallback set
+	overrides   []*responseOverride
+	calls       *calllog.Log // Where served requests are logged; the shared log unless SetCallLog changed it
+	clock       *clock.Clock // Time for dates, templates, and quotas; the shared clock unless SetClock changed it
+	// Set on copies answering from a session a request named, whose sequences are kept apart
+	requestedSession bool
 }
 
 type WebBroadcaster interface {
-	BroadcastRequest(method, endpoint, sessionName, remoteAddr, requestID string, headers map[string]interface{}, body string)
-	BroadcastResponse(method, endpoint, sessionName, remoteAddr, requestID string, status int, headers map[string]interface{}, body string)
+	BroadcastRequest(proxyName, method, endpoint, sessionName, remoteAddr, requestID string, headers map[string]interface{}, body string)
+	BroadcastResponse(proxyName, method, endpoint, sessionName, remoteAddr, requestID string, status int, headers map[string]interface{}, body string)
 }
 
 func NewMockEngine(proxyConfig config.ProxyConfig, mockConfig config.MockConfig, db *storage.Database) (*MockEngine, error) {
@@ -88,34 +123,92 @@ func NewMockEngineWithBroadcaster(proxyConfig config.ProxyConfig, mockConfig con
 		return nil, fmt.Errorf("failed to get or create session: %w", err)
 	}
 
-	restHandler := proxy.NewRESTHandler([]string{}) // Use empty redact patterns for now
-	grpcHandler := proxy.NewGRPCHandler([]string{}) // Use empty redact patterns for now
+	restHandler := proxy.NewRESTHandler(nil) // Redaction is set with SetRedactor
+	grpcHandler := proxy.NewGRPCHandler(nil)
+
+	// A proxy's own header lists replace the mock section's, for its rules too
+	if proxyConfig.MatchHeaders != nil {
+		mockConfig.MatchHeaders = proxyConfig.MatchHeaders
+	}
+	if proxyConfig.IgnoreHeaders != nil {
+		mockConfig.IgnoreHeaders = proxyConfig.IgnoreHeaders
+	}
+	if proxyConfig.NotFoundResponse.IsSet() {
+		mockConfig.NotFoundResponse = proxyConfig.NotFoundResponse
+	}
+
+	rules, err := compileMatchRules(mockConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile mock rules: %w", err)
+	}
+	overrides, err := compileOverrides(mockConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compile mock overrides: %w", err)
+	}
+
+	// Replicas sharing a database also share where each sequence is up to
+	var sequences sequenceStore = newMemorySequenceStore()
+	switch {
+	case db.Shared() || mockConfig.PersistSequences == PersistSequencesImmediate:
+		sequences = newDatabaseSequenceStore(db, proxyConfig.Name, proxyConfig.SessionName)
+	case mockConfig.PersistSequences == PersistSequencesInterval:
+		interval := time.Duration(mockConfig.SequenceFlushIntervalMS) * time.Millisecond
+		if interval <= 0 {
+			interval = time.Second
+		}
+		sequences = newFlushingSequenceStore(db, proxyConfig.Name, proxyConfig.SessionName, interval)
+	}
+
+	engine := &MockEngine{
+		proxyConfig: &proxyConfig,
+		mockConfig:  &mockConfig,
+		database:    db,
+		restHandler: restHandler,
+		grpcHandler: grpcHandler,
+		session:     session,
+		sequences:   sequences,
+		scenarios:   newScenarioStates(),
+		quotas:      newQuotaCounters(),
+		webServer:   webServer,
+		rules:       rules,
+		overrides:   overrides,
+		calls:       calllog.Default,
+		clock:       clock.Default,
+	}
+
+	// Misses go on to the live target only for proxies that have one
+	if mockConfig.Fallback == FallbackPassthrough || mockConfig.Fallback == FallbackRecord {
+		switch {
+		case proxyConfig.Protocol == "grpc":
+			log.Printf("Mock fallback is not supported for gRPC proxy '%s'", proxyConfig.Name)
+		case proxyConfig.TargetHost == "":
+			log.Printf("Mock fallback needs a target for proxy '%s'", proxyConfig.Name)
+		case mockConfig.Fallback == FallbackRecord:
+			engine.fallback, err = proxy.NewProxyEngineWithBroadcaster(proxyConfig, db, webServer)
+		default:
+			engine.fallback, err = proxy.NewPassthroughEngine(proxyConfig, db, webServer)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to create mock fallback: %w", err)
+		}
+	}
 
-	var grpcServer *grpc.Server
 	if proxyConfig.Protocol == "grpc" {
-		grpcServer = grpc.NewServer(
+		engine.grpcServer = grpc.NewServer(
 			grpc.MaxRecvMsgSize(64*1024*1024),        // 64MB max receive message size
 			grpc.MaxSendMsgSize(64*1024*1024),        // 64MB max send message size
 			grpc.MaxHeaderListSize(64*1024*1024),     // 64MB max header list size
 			grpc.InitialWindowSize(64*1024*1024),     // 64MB initial window
 			grpc.InitialConnWindowSize(64*1024*1024), // 64MB connection window
 			grpc.UnknownServiceHandler(func(srv interface{}, stream grpc.ServerStream) error {
-				return handleGRPCMockRequest(stream, db, session, grpcHandler, webServer)
+				err := handleGRPCMockRequest(stream, proxyConfig.Name, proxyConfig.Consumers, db, session, engine, webServer)
+				metrics.RecordGRPCRequest(proxyConfig.Name, err)
+				return err
 			}),
 		)
 	}
 
-	return &MockEngine{
-		proxyConfig:   &proxyConfig,
-		mockConfig:    &mockConfig,
-		database:      db,
-		restHandler:   restHandler,
-		grpcHandler:   grpcHandler,
-		grpcServer:    grpcServer,
-		session:       session,
-		sequenceState: make(map[string]int),
-		webServer:     webServer,
-	}, nil
+	return engine, nil
 }
 
 func (m *MockEngine) Start() error {
@@ -169,6 +262,16 @@ func (m *MockEngine) HandleRequest(w http.ResponseWriter, r *http.Request) {
 func (m *MockEngine) handleRequest(w http.ResponseWriter, r *http.Request) {
 	log.Printf("[MOCK] %s %s %s", r.Method, r.URL.Path, r.RemoteAddr)
 
+	// A request can name another recorded session to be answered from
+	engine := m.forSession(r)
+	if engine == nil {
+		reason := fmt.Sprintf("no session named '%s'", m.requestedSessionName(r))
+		m.sendNotFoundResponse(w, r, m.reportMiss(r, reason, nil))
+		return
+	}
+	m = engine
+	accesslog.Annotate(r.Context(), m.session.SessionName, "")
+
 	// Broadcast request event if web server is available
 	if m.webServer != nil {
 		requestHeaders := make(map[string]interface{})
@@ -185,49 +288,121 @@ func (m *MockEngine) handleRequest(w http.ResponseWriter, r *http.Request) {
 			}
 		}
 
-		m.webServer.BroadcastRequest(r.Method, r.URL.Path, m.session.SessionName, r.RemoteAddr, "", requestHeaders, requestBody)
+		m.webServer.BroadcastRequest(m.proxyConfig.Name, r.Method, r.URL.Path, m.session.SessionName, r.RemoteAddr, "", requestHeaders, requestBody)
 	}
 
 	interactions, err := m.database.FindMatchingInteractions(m.session.ID, r.Method, r.URL.Path)
 	if err != nil {
 		log.Printf("Error finding matching interactions: %v", err)
-		m.sendNotFoundResponse(w)
+		m.sendNotFoundResponse(w, r, nil)
 		return
 	}
 
+	// A consumer's slice answers only from the interactions recorded for that consumer
+	if consumerName := m.requestedConsumer(r); consumerName != "" {
+		recorded := interactions
+		interactions = consumer.Filter(interactions, consumerName)
+		if len(interactions) == 0 && len(recorded) > 0 {
+			reason := fmt.Sprintf("no recording for consumer '%s'", consumerName)
+			m.sendNotFoundResponse(w, r, m.reportMiss(r, reason, m.closestRecordings(r, recorded)))
+			return
+		}
+	}
+
+	// A tagged scenario answers only from the interactions recorded with its tag
+	if tagName := m.requestedTag(r); tagName != "" {
+		recorded := interactions
+		interactions = tag.Filter(interactions, tagName)
+		if len(interactions) == 0 && len(recorded) > 0 {
+			reason := fmt.Sprintf("no recording tagged '%s'", tagName)
+			m.sendNotFoundResponse(w, r, m.reportMiss(r, reason, m.closestRecordings(r, recorded)))
+			return
+		}
+	}
+
 	if len(interactions) == 0 {
-		log.Printf("No matching interactions found for %s %s", r.Method, r.URL.Path)
-		m.sendNotFoundResponse(w)
+		m.sendNotFoundResponse(w, r, m.reportMiss(r, "no recording of this method and path", m.closestEndpoints(r)))
+		return
+	}
+
+	// Filter interactions based on headers and body matching, with the settings of the most
+	// specific rule for the endpoint
+	matcher := m.forRequest(r)
+
+	// Clients that have spent their simulated quota for the endpoint get its quota error instead
+	if matcher.checkQuota(w, r, interactions) {
 		return
 	}
 
-	// Filter interactions based on headers and body matching
-	matchingInteractions := m.filterMatchingInteractions(interactions, r)
+	matchingInteractions := matcher.filterMatchingInteractions(interactions, r)
 
 	if len(matchingInteractions) == 0 {
-		log.Printf("No interactions match request headers/body for %s %s", r.Method, r.URL.Path)
-		m.sendNotFoundResponse(w)
+		reason := "no recording matches the query, headers, and body"
+		m.sendNotFoundResponse(w, r, m.reportMiss(r, reason, m.closestRecordings(r, interactions)))
 		return
 	}
 
+	// Recordings annotated with scenario states are served only in the state they require
+	matchingInteractions = m.inScenarioState(matchingInteractions)
+	if len(matchingInteractions) == 0 {
+		reason := "no matching recording is served in the current scenario state"
+		m.sendNotFoundResponse(w, r, m.reportMiss(r, reason, m.closestRecordings(r, interactions)))
+		return
+	}
+
+	// Recordings whose conditions hold win over those served for any request
+	matchingInteractions = preferConditional(matchingInteractions)
+
 	// Select interaction based on sequence order (default behavior)
-	selectedInteraction := m.selectSequentialInteraction(matchingInteractions, r)
+	selectedInteraction := matcher.selectSequentialInteraction(matchingInteractions, r)
 
 	if selectedInteraction == nil {
-		log.Printf("No suitable interaction found for %s %s", r.Method, r.URL.Path)
-		m.sendNotFoundResponse(w)
+		// Only a strict sequence runs out of recordings
+		reason := "the recorded sequence is exhausted"
+		matcher.sendSequenceExhaustedResponse(w, r, m.reportMiss(r, reason, m.closestRecordings(r, matchingInteractions)))
 		return
 	}
+	m.advanceScenario(selectedInteraction)
+
+	metrics.RecordMockHit(m.proxyConfig.Name)
+	accesslog.Annotate(r.Context(), "", accesslog.MatchHit)
+	m.recordCall(r, true)
+	m.checkConformance(r, selectedInteraction)
 
 	// Broadcast response event if web server is available
 	if m.webServer != nil {
 		var responseHeaders map[string]interface{}
 		json.Unmarshal([]byte(selectedInteraction.ResponseHeaders), &responseHeaders)
 		responseBody := string(selectedInteraction.ResponseBody)
-		m.webServer.BroadcastResponse(selectedInteraction.Method, selectedInteraction.Endpoint, m.session.SessionName, r.RemoteAddr, selectedInteraction.RequestID, selectedInteraction.ResponseStatus, responseHeaders, responseBody)
+		m.webServer.BroadcastResponse(m.proxyConfig.Name, selectedInteraction.Method, selectedInteraction.Endpoint, m.session.SessionName, r.RemoteAddr, selectedInteraction.RequestID, selectedInteraction.ResponseStatus, responseHeaders, responseBody)
+	}
+
+	// Hold the response back as long as the latency settings ask
+	if err := delayResponse(r.Context(), matcher.responseDelay(selectedInteraction, interactions)); err != nil {
+		log.Printf("Client gave up waiting for a delayed mock response: %v", err)
+		return
+	}
+
+	// Resilience tests can have the response replaced by a fault or broken on the way out
+	outcome := matcher.pickFault()
+	if matcher.answerFault(w, outcome) {
+		log.Printf("Injected %s fault into mock response: %s %s", outcome.Kind, r.Method, r.URL.Path)
+		return
+	}
+
+	// Dates move with the time since recording, then overrides apply; both change the recording
+	// as it is served, never as it is stored
+	served, err := m.applyTimeShift(selectedInteraction)
+	if err != nil {
+		log.Printf("Error shifting recorded dates: %v", err)
+		served = selectedInteraction
+	}
+	if served, err = m.applyOverrides(served, r); err != nil {
+		log.Printf("Error applying mock overrides: %v", err)
+		served = selectedInteraction
 	}
 
-	if err := m.sendMockResponse(w, selectedInteraction); err != nil {
+	if err := matcher.sendMockResponse(w, r, served, outcome); err != nil {
 		// Don't try to write error response if client disconnected (headers already sent)
 		if !strings.Contains(err.Error(), "broken pipe") && !strings.Contains(err.Error(), "connection reset") {
 			log.Printf("Error sending mock response: %v", err)
@@ -237,8 +412,7 @@ func (m *MockEngine) handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	log.Printf("Served mock response: %s %s -> %d (sequence: %d)",
-		selectedInteraction.Method, selectedInteraction.Endpoint,
-		selectedInteraction.ResponseStatus, selectedInteraction.SequenceNumber)
+		served.Method, served.Endpoint, served.ResponseStatus, served.SequenceNumber)
 }
 
 func (m *MockEngine) filterMatchingInteractions(interactions []storage.Interaction, r *http.Request) []storage.Interaction {
@@ -254,13 +428,34 @@ func (m *MockEngine) filterMatchingInteractions(interactions []storage.Interacti
 }
 
 func (m *MockEngine) matchesRequestContent(interaction storage.Interaction, r *http.Request) bool {
+	// Conditional interactions match on their conditions in place of the query, headers, and body
+	if conditions := interaction.Conditions(); len(conditions) > 0 {
+		return conditionsHold(conditions, r)
+	}
+
+	if !m.matchesQuery(interaction, r) {
+		return false
+	}
+
+	// Stubs compare only the headers they list, and any body when they give none
+	if interaction.Stubbed() {
+		return matchesStubHeaders(interaction.RequestHeaders, r.Header) &&
+			(len(interaction.RequestBody) == 0 || m.matchesBody(interaction.PlainRequestBody(), r))
+	}
+
 	// Compare headers (ignoring redacted fields)
 	if !m.matchesHeaders(interaction.RequestHeaders, r.Header) {
 		return false
 	}
 
 	// Compare body
-	if !m.matchesBody(interaction.RequestBody, r) {
+	if interaction.RequestTruncated() {
+		return matchesTruncatedBody(interaction, r)
+	}
+	if name := interaction.RequestBodyFile(); name != "" {
+		return matchesBodyFile(name, r)
+	}
+	if !m.matchesBody(interaction.PlainRequestBody(), r) {
 		return false
 	}
 
@@ -268,24 +463,68 @@ func (m *MockEngine) matchesRequestContent(interaction storage.Interaction, r *h
 }
 
 func (m *MockEngine) matchesHeaders(recordedHeaders string, requestHeaders http.Header) bool {
+	recorded, current, err := m.comparedHeaders(recordedHeaders, requestHeaders)
+	if err != nil {
+		return false
+	}
+
+	// Apply redaction to both for comparison
+	recordedJSON, _ := json.Marshal(recorded)
+	currentJSON, _ := json.Marshal(current)
+
+	recordedRedacted := m.redactSensitiveData(string(recordedJSON))
+	currentRedacted := m.redactSensitiveData(string(currentJSON))
+
+	return recordedRedacted == currentRedacted
+}
+
+// matchesStubHeaders reports whether a request carries every header a stub lists, with the same value
+func matchesStubHeaders(stubHeaders string, requestHeaders http.Header) bool {
+	var headers map[string]string
+	if stubHeaders != "" {
+		if err := json.Unmarshal([]byte(stubHeaders), &headers); err != nil {
+			return false
+		}
+	}
+	for name, value := range headers {
+		if strings.Join(requestHeaders.Values(name), ", ") != value {
+			return false
+		}
+	}
+	return true
+}
+
+// comparedHeaders returns the recorded and request headers that take part in matching, before
+// redaction
+func (m *MockEngine) comparedHeaders(recordedHeaders string, requestHeaders http.Header) (recorded, current map[string]string, err error) {
 	// Parse recorded headers
-	var recorded map[string]string
 	if recordedHeaders != "" {
 		if err := json.Unmarshal([]byte(recordedHeaders), &recorded); err != nil {
-			return false
+			return nil, nil, err
 		}
 	} else {
 		recorded = make(map[string]string)
 	}
 
 	// Convert request headers to same format
-	current := make(map[string]string)
+	current = make(map[string]string)
 	for key, values := range requestHeaders {
 		current[key] = strings.Join(values, ", ")
 	}
 
+	// Header names and values are compared in canonical form, and only the configured headers
+	// take part in matching, without a multipart body's boundary
+	recorded = canonicalHeaders(recorded)
+	current = canonicalHeaders(current)
+	m.selectHeaders(recorded)
+	m.selectHeaders(current)
+	normalizeFormHeaders(recorded)
+	normalizeFormHeaders(current)
+	normalizeEncodingHeaders(recorded)
+	normalizeEncodingHeaders(current)
+
 	// When fuzzy matching is enabled, ignore dynamic headers
-	if m.mockConfig.MatchingStrategy == "fuzzy" || m.mockConfig.MatchingStrategy == "fuzzy-unordered" {
+	if m.fuzzyMatching() {
 		// Headers that change based on dynamic content should be ignored
 		dynamicHeaders := []string{"Content-Length", "Content-Md5", "Date", "If-None-Match", "If-Modified-Since"}
 		for _, header := range dynamicHeaders {
@@ -295,19 +534,26 @@ func (m *MockEngine) matchesHeaders(recordedHeaders string, requestHeaders http.
 
 		// Also ignore headers specified in fuzzy_ignore_fields configuration
 		for _, header := range m.mockConfig.FuzzyIgnoreFields {
-			delete(recorded, header)
-			delete(current, header)
+			delete(recorded, http.CanonicalHeaderKey(header))
+			delete(current, http.CanonicalHeaderKey(header))
 		}
 	}
 
-	// Apply redaction to both for comparison
-	recordedJSON, _ := json.Marshal(recorded)
-	currentJSON, _ := json.Marshal(current)
-
-	recordedRedacted := m.redactSensitiveData(string(recordedJSON))
-	currentRedacted := m.redactSensitiveData(string(currentJSON))
-
-	return recordedRedacted == currentRedacted
+	// A WebSocket handshake's key is random for every connection
+	delete(recorded, "Sec-Websocket-Key")
+	delete(current, "Sec-Websocket-Key")
+
+	// The consumer, tag, and session headers pick what answers rather than being part of the request
+	consumerHeader := http.CanonicalHeaderKey(m.consumerHeader())
+	delete(recorded, consumerHeader)
+	delete(current, consumerHeader)
+	delete(recorded, tag.Header)
+	delete(current, tag.Header)
+	if sessionHeader := m.sessionHeader(); sessionHeader != "" {
+		delete(recorded, http.CanonicalHeaderKey(sessionHeader))
+		delete(current, http.CanonicalHeaderKey(sessionHeader))
+	}
+	return recorded, current, nil
 }
 
 func (m *MockEngine) matchesBody(recordedBody []byte, r *http.Request) bool {
@@ -322,9 +568,23 @@ func (m *MockEngine) matchesBody(recordedBody []byte, r *http.Request) bool {
 		// Restore body for further processing
 		r.Body = io.NopCloser(bytes.NewBuffer(currentBody))
 	}
+	currentBody = plainBody(currentBody, r.Header)
+
+	// Secrets are compared masked, as they were recorded
+	redactor := m.restHandler.Redactor()
+	recordedBody = redactor.Bytes(recordedBody)
+	currentBody = redactor.Bytes(currentBody)
+
+	// Forms are compared field by field
+	if matched, ok := m.matchesFormBody(recordedBody, currentBody, r.Header.Get("Content-Type")); ok {
+		return matched
+	}
 
 	// Use fuzzy matching if configured
-	if m.mockConfig.MatchingStrategy == "fuzzy" || m.mockConfig.MatchingStrategy == "fuzzy-unordered" {
+	if m.fuzzyMatching() {
+		if isXMLContentType(r.Header.Get("Content-Type")) {
+			return m.fuzzyMatchXML(recordedBody, currentBody)
+		}
 		return m.fuzzyMatchBody(recordedBody, currentBody)
 	}
 
@@ -332,6 +592,76 @@ func (m *MockEngine) matchesBody(recordedBody []byte, r *http.Request) bool {
 	return bytes.Equal(recordedBody, currentBody)
 }
 
+// matchesBodyFile compares the request body with a recorded one kept in a body file. Body files
+// are named by the SHA-256 of their content, so large bodies match exactly without reading the file.
+func matchesBodyFile(name string, r *http.Request) bool {
+	reader, ok := requestBodyReader(r)
+	if !ok {
+		return false
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return false
+	}
+	return hex.EncodeToString(hash.Sum(nil)) == name
+}
+
+// matchesTruncatedBody compares the request body with a recorded one of which only a prefix was
+// kept. Where the whole body's SHA-256 was recorded the request must send the same body; otherwise
+// it matches when its body begins with the prefix, as sent.
+func matchesTruncatedBody(interaction storage.Interaction, r *http.Request) bool {
+	reader, ok := requestBodyReader(r)
+	if !ok {
+		return len(interaction.RequestBody) == 0 && interaction.RequestBodyFile() == ""
+	}
+	if sum := interaction.RequestSHA256(); sum != "" {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, reader); err != nil {
+			return false
+		}
+		return hex.EncodeToString(hash.Sum(nil)) == sum
+	}
+	name := interaction.RequestBodyFile()
+	if name == "" {
+		prefix := make([]byte, len(interaction.RequestBody))
+		if _, err := io.ReadFull(reader, prefix); err != nil {
+			return false
+		}
+		return bytes.Equal(prefix, interaction.RequestBody)
+	}
+	size, _ := interaction.MetadataMap()[storage.MetadataRequestBodySize].(float64)
+	hash := sha256.New()
+	if _, err := io.CopyN(hash, reader, int64(size)); err != nil {
+		return false
+	}
+	return hex.EncodeToString(hash.Sum(nil)) == name
+}
+
+// requestBodyReader reads the request body without consuming it, reporting false when there is none
+func requestBodyReader(r *http.Request) (io.Reader, bool) {
+	if r.Body == nil {
+		return nil, false
+	}
+	if spooled, ok := r.Body.(*proxy.SpooledBody); ok {
+		return spooled.NewReader(), true
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+	return bytes.NewReader(body), true
+}
+
+// fuzzyMatching reports whether the matching strategy is one of the fuzzy ones
+func (m *MockEngine) fuzzyMatching() bool {
+	switch m.mockConfig.MatchingStrategy {
+	case "fuzzy", "fuzzy-unordered", "fuzzy-subset":
+		return true
+	}
+	return false
+}
+
 func (m *MockEngine) fuzzyMatchBody(recordedBody, currentBody []byte) bool {
 	// If both are empty, they match
 	if len(recordedBody) == 0 && len(currentBody) == 0 {
@@ -357,14 +687,10 @@ func (m *MockEngine) fuzzyMatchJSON(recorded, current map[string]interface{}) bo
 	// UUID normalization will handle all dynamic values automatically
 	// Check if we should use unordered array matching
 	unordered := m.mockConfig.MatchingStrategy == "fuzzy-unordered"
-	result := m.fuzzyMatchJSONValueWithPath(recorded, current, false, unordered, "root")
+	result := m.fuzzyMatchJSONValue(recorded, current, false, unordered, nil)
 	return result
 }
 
-func (m *MockEngine) fuzzyMatchJSONValueWithPath(recorded, current interface{}, ignoreValues bool, unordered bool, path string) bool {
-	return m.fuzzyMatchJSONValue(recorded, current, ignoreValues, unordered)
-}
-
 // isUUID checks if a string matches the UUID format
 func isUUID(s string) bool {
 	return uuidPattern.MatchString(s)
@@ -378,15 +704,33 @@ func normalizeStringValue(s string) string {
 	return s
 }
 
-// shouldIgnoreField checks if a field should be ignored during fuzzy matching
-func (m *MockEngine) shouldIgnoreField(fieldName string) bool {
+// numbersMatch compares two numbers within the configured absolute and relative tolerances
+func (m *MockEngine) numbersMatch(recorded, current float64) bool {
+	if recorded == current {
+		return true
+	}
+	diff := math.Abs(recorded - current)
+	if diff <= m.mockConfig.NumericTolerance {
+		return true
+	}
+	return diff <= m.mockConfig.NumericRelativeTolerance*math.Max(math.Abs(recorded), math.Abs(current))
+}
+
+// shouldIgnoreField checks if the value at a path should be ignored during fuzzy matching. Plain
+// field names are ignored wherever they appear; path expressions only at the path they name.
+func (m *MockEngine) shouldIgnoreField(path []string) bool {
 	// Only apply ignore rules if fuzzy matching is enabled
-	if m.mockConfig.MatchingStrategy != "fuzzy" && m.mockConfig.MatchingStrategy != "fuzzy-unordered" {
+	if !m.fuzzyMatching() || len(path) == 0 {
 		return false
 	}
 
+	fieldName := path[len(path)-1]
 	for _, ignoredField := range m.mockConfig.FuzzyIgnoreFields {
-		if fieldName == ignoredField {
+		if ignorePath, ok := parseIgnorePath(ignoredField); ok {
+			if ignorePath.matches(path) {
+				return true
+			}
+		} else if fieldName == ignoredField && !isIndexSegment(fieldName) {
 			return true
 		}
 	}
@@ -395,12 +739,13 @@ func (m *MockEngine) shouldIgnoreField(fieldName string) bool {
 
 // fuzzyMatchArrayUnordered matches array elements in any order
 // Each element in recorded must match exactly one element in current
-func (m *MockEngine) fuzzyMatchArrayUnordered(recorded, current []interface{}, ignoreValues bool, unordered bool) bool {
+func (m *MockEngine) fuzzyMatchArrayUnordered(recorded, current []interface{}, ignoreValues bool, unordered bool, path []string) bool {
 	// Track which current elements have been matched
 	matched := make([]bool, len(current))
 
 	// For each recorded element, find a matching current element
-	for _, recElem := range recorded {
+	for i, recElem := range recorded {
+		elemPath := appendPath(path, indexSegment(i))
 		found := false
 		for j, curElem := range current {
 			// Skip already matched elements
@@ -408,7 +753,7 @@ func (m *MockEngine) fuzzyMatchArrayUnordered(recorded, current []interface{}, i
 				continue
 			}
 			// Try to match this pair
-			if m.fuzzyMatchJSONValue(recElem, curElem, ignoreValues, unordered) {
+			if m.fuzzyMatchJSONValue(recElem, curElem, ignoreValues, unordered, elemPath) {
 				matched[j] = true
 				found = true
 				break
@@ -422,7 +767,12 @@ func (m *MockEngine) fuzzyMatchArrayUnordered(recorded, current []interface{}, i
 	return true
 }
 
-func (m *MockEngine) fuzzyMatchJSONValue(recorded, current interface{}, ignoreValues bool, unordered bool) bool {
+func (m *MockEngine) fuzzyMatchJSONValue(recorded, current interface{}, ignoreValues bool, unordered bool, path []string) bool {
+	// Check if this value should be ignored during fuzzy matching
+	if m.shouldIgnoreField(path) {
+		return true
+	}
+
 	// Handle nil cases
 	if recorded == nil && current == nil {
 		return true
@@ -438,8 +788,8 @@ func (m *MockEngine) fuzzyMatchJSONValue(recorded, current interface{}, ignoreVa
 			return false
 		}
 
-		// Check if both maps have the same keys
-		if len(recVal) != len(curMap) {
+		// Check if both maps have the same keys; with fuzzy-subset, the request may have extra ones
+		if len(recVal) != len(curMap) && m.mockConfig.MatchingStrategy != "fuzzy-subset" {
 			return false
 		}
 
@@ -449,12 +799,7 @@ func (m *MockEngine) fuzzyMatchJSONValue(recorded, current interface{}, ignoreVa
 				return false
 			}
 
-			// Check if this field should be ignored during fuzzy matching
-			if m.shouldIgnoreField(key) {
-				continue
-			}
-
-			if !m.fuzzyMatchJSONValue(recValue, curValue, ignoreValues, unordered) {
+			if !m.fuzzyMatchJSONValue(recValue, curValue, ignoreValues, unordered, appendPath(path, key)) {
 				return false
 			}
 		}
@@ -471,12 +816,12 @@ func (m *MockEngine) fuzzyMatchJSONValue(recorded, current interface{}, ignoreVa
 
 		// If unordered matching is enabled, try to match elements in any order
 		if unordered {
-			return m.fuzzyMatchArrayUnordered(recVal, curSlice, ignoreValues, unordered)
+			return m.fuzzyMatchArrayUnordered(recVal, curSlice, ignoreValues, unordered, path)
 		}
 
 		// Default: ordered matching
 		for i := range recVal {
-			if !m.fuzzyMatchJSONValue(recVal[i], curSlice[i], ignoreValues, unordered) {
+			if !m.fuzzyMatchJSONValue(recVal[i], curSlice[i], ignoreValues, unordered, appendPath(path, indexSegment(i))) {
 				return false
 			}
 		}
@@ -496,8 +841,19 @@ func (m *MockEngine) fuzzyMatchJSONValue(recorded, current interface{}, ignoreVa
 		normalizedCur := normalizeStringValue(curStr)
 		return normalizedRec == normalizedCur
 
+	case float64:
+		// Numbers compare by value, so 1 and 1.0 match, and within the configured tolerance
+		curNum, ok := current.(float64)
+		if !ok {
+			return false
+		}
+		if ignoreValues {
+			return true
+		}
+		return m.numbersMatch(recVal, curNum)
+
 	default:
-		// For other primitive values (numbers, bools, etc.), do exact comparison
+		// For other primitive values (bools, etc.), do exact comparison
 		if ignoreValues {
 			return true
 		}
@@ -505,13 +861,78 @@ func (m *MockEngine) fuzzyMatchJSONValue(recorded, current interface{}, ignoreVa
 	}
 }
 
-func (m *MockEngine) redactSensitiveData(data string) string {
-	result := data
-	// Use the same redaction patterns as the REST handler
-	for _, pattern := range m.restHandler.GetRedactPatterns() {
-		result = pattern.ReplaceAllString(result, "[REDACTED]")
+// consumerHeader is the header naming the consumer whose slice a request is answered from
+func (m *MockEngine) consumerHeader() string {
+	if m.proxyConfig == nil {
+		return consumer.DefaultHeader
 	}
-	return result
+	return consumer.Header(m.proxyConfig.Consumers)
+}
+
+// requestedConsumer names the consumer whose slice a request is answered from, "" for all
+func (m *MockEngine) requestedConsumer(r *http.Request) string {
+	if m.proxyConfig == nil {
+		return ""
+	}
+	return consumer.Requested(r, m.proxyConfig.Consumers)
+}
+
+// servedTag is the tag a mock answers from when requests name none
+func (m *MockEngine) servedTag() string {
+	if m.mockConfig == nil {
+		return ""
+	}
+	return m.mockConfig.Tag
+}
+
+// requestedTag names the tag whose interactions a request is answered from, "" for all
+func (m *MockEngine) requestedTag(r *http.Request) string {
+	return tag.Requested(r, m.servedTag())
+}
+
+// SetRedactor masks what a redactor matches in requests before comparing them with recordings,
+// which were masked the same way as they were recorded, and in what a fallback records
+func (m *MockEngine) SetRedactor(redactor *redact.Redactor) {
+	m.restHandler.SetRedactor(redactor)
+	m.grpcHandler.SetRedactor(redactor)
+	if m.fallback != nil {
+		m.fallback.SetRedactor(redactor)
+	}
+}
+
+// SetCallLog logs the requests the engine serves to another log than the shared one, so that
+// engines in one process, such as parallel tests, count their calls apart
+func (m *MockEngine) SetCallLog(calls *calllog.Log) {
+	m.calls = calls
+}
+
+// SetClock has the engine follow another clock than the shared one
+func (m *MockEngine) SetClock(c *clock.Clock) {
+	m.clock = c
+}
+
+// callLog is the log served requests go to
+func (m *MockEngine) callLog() *calllog.Log {
+	if m.calls == nil {
+		return calllog.Default
+	}
+	return m.calls
+}
+
+// now is the time by the engine's clock
+func (m *MockEngine) now() time.Time {
+	return m.engineClock().Now()
+}
+
+func (m *MockEngine) engineClock() *clock.Clock {
+	if m.clock == nil {
+		return clock.Default
+	}
+	return m.clock
+}
+
+func (m *MockEngine) redactSensitiveData(data string) string {
+	return m.restHandler.Redactor().Headers(data)
 }
 
 func (m *MockEngine) getRequestSignature(r *http.Request) (string, error) {
@@ -520,6 +941,13 @@ func (m *MockEngine) getRequestSignature(r *http.Request) (string, error) {
 	for key, values := range r.Header {
 		headers[key] = strings.Join(values, ", ")
 	}
+	headers = canonicalHeaders(headers)
+	if sessionHeader := m.sessionHeader(); sessionHeader != "" {
+		delete(headers, http.CanonicalHeaderKey(sessionHeader))
+	}
+	m.selectHeaders(headers)
+	normalizeFormHeaders(headers)
+	normalizeEncodingHeaders(headers)
 
 	headersJSON, err := json.Marshal(headers)
 	if err != nil {
@@ -540,14 +968,28 @@ func (m *MockEngine) getRequestSignature(r *http.Request) (string, error) {
 	}
 
 	// Create signature
-	signature := fmt.Sprintf("%s:%s:%s:%s", r.Method, r.URL.Path, headersStr, string(body))
+	path := r.URL.Path
+	if query := m.querySignature(r); query != "" {
+		path += "?" + query
+	}
+	body = m.restHandler.Redactor().Bytes(plainBody(body, r.Header))
+	signature := fmt.Sprintf("%s:%s:%s:%s", r.Method, path, headersStr, string(formSignatureBody(body, r.Header.Get("Content-Type"))))
+	if m.requestedSession {
+		signature = m.session.SessionName + sessionKeySeparator + signature
+	}
 	return signature, nil
 }
 
+// selectSequentialInteraction picks the recording to serve as sequence_mode has it, or nil once
+// a strict sequence is exhausted
 func (m *MockEngine) selectSequentialInteraction(interactions []storage.Interaction, r *http.Request) *storage.Interaction {
 	if len(interactions) == 0 {
 		return nil
 	}
+	mode := m.sequenceMode()
+	if mode == SequenceRandom {
+		return m.selectRandomInteraction(interactions, r)
+	}
 
 	// Get request signature for sequence tracking
 	signature, err := m.getRequestSignature(r)
@@ -557,26 +999,31 @@ func (m *MockEngine) selectSequentialInteraction(interactions []storage.Interact
 		signature = fmt.Sprintf("%s:%s", r.Method, r.URL.Path)
 	}
 
-	m.sequenceMutex.Lock()
-	defer m.sequenceMutex.Unlock()
-
-	currentSequence := m.sequenceState[signature]
+	var selected *storage.Interaction
+	m.sequences.advance(signature, func(currentSequence int) int {
+		// Find the next interaction in sequence
+		for i := range interactions {
+			if interactions[i].SequenceNumber > currentSequence {
+				selected = &interactions[i]
+				return selected.SequenceNumber
+			}
+		}
 
-	// Find the next interaction in sequence
-	for _, interaction := range interactions {
-		if interaction.SequenceNumber > currentSequence {
-			m.sequenceState[signature] = interaction.SequenceNumber
-			return &interaction
+		switch mode {
+		case SequenceRepeatLast:
+			selected = &interactions[len(interactions)-1]
+			return selected.SequenceNumber
+		case SequenceStrict:
+			return currentSequence
 		}
-	}
 
-	// If we've reached the end, cycle back to the beginning
-	if len(interactions) > 0 {
-		m.sequenceState[signature] = interactions[0].SequenceNumber
-		return &interactions[0]
-	}
+		// If we've reached the end, cycle back to the beginning
+		metrics.RecordSequenceCycle(m.proxyConfig.Name)
+		selected = &interactions[0]
+		return selected.SequenceNumber
+	})
 
-	return nil
+	return selected
 }
 
 func (m *MockEngine) selectOrderedInteraction(interactions []storage.Interaction, r *http.Request) *storage.Interaction {
@@ -584,24 +1031,29 @@ func (m *MockEngine) selectOrderedInteraction(interactions []storage.Interaction
 	return m.selectSequentialInteraction(interactions, r)
 }
 
+// sequenceMode is the configured sequence_mode, SequenceOrdered when unset
+func (m *MockEngine) sequenceMode() string {
+	if m.mockConfig == nil || m.mockConfig.SequenceMode == "" {
+		return SequenceOrdered
+	}
+	return m.mockConfig.SequenceMode
+}
+
+// selectRandomInteraction picks any of the matching recordings, leaving the sequence where it is
 func (m *MockEngine) selectRandomInteraction(interactions []storage.Interaction, r *http.Request) *storage.Interaction {
 	if len(interactions) == 0 {
 		return nil
 	}
-
-	for _, interaction := range interactions {
-		if m.restHandler.MatchRequest(r, &interaction, "exact") { // Default to exact matching
-			return &interaction
-		}
-	}
-
-	return &interactions[0]
+	return &interactions[rand.Intn(len(interactions))]
 }
 
-func (m *MockEngine) sendMockResponse(w http.ResponseWriter, interaction *storage.Interaction) error {
+func (m *MockEngine) sendMockResponse(w http.ResponseWriter, r *http.Request, interaction *storage.Interaction, outcome fault.Outcome) error {
 	// Check if this is a streaming response
 	if interaction.IsStreaming {
-		return m.sendStreamingMockResponse(w, interaction)
+		return m.sendStreamingMockResponse(w, r, interaction)
+	}
+	if interaction.WebSocket() {
+		return m.sendWebSocketMockResponse(w, r, interaction)
 	}
 
 	var headers map[string]string
@@ -614,11 +1066,48 @@ func (m *MockEngine) sendMockResponse(w http.ResponseWriter, interaction *storag
 	for key, value := range headers {
 		w.Header().Set(key, value)
 	}
+	// Once a test has moved the clock, responses are dated by it rather than by the recording
+	if m.engineClock().Virtual() {
+		w.Header().Set("Date", m.now().UTC().Format(http.TimeFormat))
+	}
+
+	// Only a prefix of a truncated body was recorded, so it is served with its recorded length
+	if interaction.ResponseTruncated() {
+		w.Header().Set(TruncatedHeader, "true")
+	}
+
+	if interaction.ResponseBodyFile() != "" {
+		return m.sendResponseBodyFile(w, interaction)
+	}
+
+	// Bodies recorded decompressed are compressed again as the server sent them
+	body, err := interaction.ResponseBodyAsSent()
+	if err != nil {
+		return err
+	}
+	if m.templated(interaction) {
+		if body, err = m.renderTemplate(interaction.ResponseBody, r); err != nil {
+			return err
+		}
+		if encoding := interaction.ResponseEncoding(); encoding != "" {
+			if body, err = storage.Compress(body, encoding); err != nil {
+				return err
+			}
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	} else if interaction.ResponseEncoding() != "" || interaction.ResponseTruncated() {
+		w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	}
+
+	if outcome.Kind != "" && len(body) > 0 {
+		log.Printf("Injected %s fault into mock response: %s %s", outcome.Kind, r.Method, r.URL.Path)
+		return m.writeFaultyBody(r.Context(), w, interaction.ResponseStatus, body, outcome)
+	}
 
 	w.WriteHeader(interaction.ResponseStatus)
 
-	if len(interaction.ResponseBody) > 0 {
-		_, err := w.Write(interaction.ResponseBody)
+	if len(body) > 0 {
+		_, err := w.Write(body)
 		if err != nil {
 			return fmt.Errorf("failed to write response body: %w", err)
 		}
@@ -627,7 +1116,24 @@ func (m *MockEngine) sendMockResponse(w http.ResponseWriter, interaction *storag
 	return nil
 }
 
-func (m *MockEngine) sendStreamingMockResponse(w http.ResponseWriter, interaction *storage.Interaction) error {
+// sendResponseBodyFile streams a response body kept in a body file rather than loading it
+func (m *MockEngine) sendResponseBodyFile(w http.ResponseWriter, interaction *storage.Interaction) error {
+	body, size, err := m.database.OpenResponseBody(interaction)
+	if err != nil {
+		http.Error(w, "Recorded response body is missing", http.StatusInternalServerError)
+		return err
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(interaction.ResponseStatus)
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("failed to write response body: %w", err)
+	}
+	return nil
+}
+
+func (m *MockEngine) sendStreamingMockResponse(w http.ResponseWriter, r *http.Request, interaction *storage.Interaction) error {
 	// Retrieve the stream chunks from the database
 	chunks, err := m.database.GetStreamChunks(interaction.ID)
 	if err != nil {
@@ -644,8 +1150,24 @@ func (m *MockEngine) sendStreamingMockResponse(w http.ResponseWriter, interactio
 		}
 	}
 
-	// Replay the streaming response with timing based on config
-	if err := m.restHandler.ReplayStreamingResponse(w, sseChunks, m.mockConfig.RespectStreamingTiming); err != nil {
+	// Overrides can fix up each chunk, such as IDs that must line up with the current run
+	if err := m.transformChunks(sseChunks, r); err != nil {
+		log.Printf("Serving %s %s without its stream overrides: %v", r.Method, r.URL.Path, err)
+	}
+
+	// Replay the streaming response paced by the streaming settings
+	if interaction.EventStream() {
+		err = m.restHandler.ReplayStreamingResponse(w, sseChunks, m.streamPacing())
+	} else {
+		var headers map[string]string
+		if interaction.ResponseHeaders != "" {
+			if err := json.Unmarshal([]byte(interaction.ResponseHeaders), &headers); err != nil {
+				return fmt.Errorf("failed to unmarshal response headers: %w", err)
+			}
+		}
+		err = m.restHandler.ReplayChunkedResponse(w, interaction.ResponseStatus, headers, sseChunks, m.streamPacing())
+	}
+	if err != nil {
 		return fmt.Errorf("failed to replay streaming response: %w", err)
 	}
 
@@ -655,19 +1177,140 @@ func (m *MockEngine) sendStreamingMockResponse(w http.ResponseWriter, interactio
 	return nil
 }
 
-func (m *MockEngine) sendNotFoundResponse(w http.ResponseWriter) {
+// sendWebSocketMockResponse upgrades the client and replays the recorded conversation frame by
+// frame, paced by the streaming settings
+func (m *MockEngine) sendWebSocketMockResponse(w http.ResponseWriter, r *http.Request, interaction *storage.Interaction) error {
+	frames, err := m.database.GetWebSocketFrames(interaction.ID)
+	if err != nil {
+		return fmt.Errorf("failed to get WebSocket frames: %w", err)
+	}
+
+	var headers map[string]string
+	if interaction.ResponseHeaders != "" {
+		if err := json.Unmarshal([]byte(interaction.ResponseHeaders), &headers); err != nil {
+			return fmt.Errorf("failed to unmarshal response headers: %w", err)
+		}
+	}
+
+	if err := proxy.ReplayWebSocket(w, r, headers, frames, m.streamPacing()); err != nil {
+		return fmt.Errorf("failed to replay WebSocket conversation: %w", err)
+	}
+
+	log.Printf("Served WebSocket mock conversation: %s %s -> %d frames",
+		interaction.Method, interaction.Endpoint, len(frames))
+
+	return nil
+}
+
+// recordCall adds a request to the call log that tests verify against, with whether a recording
+// answered it
+func (m *MockEngine) recordCall(r *http.Request, matched bool) {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewBuffer(body))
+	}
+	m.callLog().Record(calllog.Call{
+		Proxy:   m.proxyConfig.Name,
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.RawQuery,
+		Headers: r.Header.Clone(),
+		Body:    string(body),
+		Matched: matched,
+	})
+}
+
+// checkConformance checks a served request and the recorded response against the proxy's
+// OpenAPI spec, if it has one
+func (m *MockEngine) checkConformance(r *http.Request, interaction *storage.Interaction) {
+	if conformance.Default.Spec(m.proxyConfig.Name) == nil {
+		return
+	}
+	exchange, ok := conformance.ExchangeFromInteraction(interaction)
+	if !ok {
+		return
+	}
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewBuffer(body))
+	}
+	exchange.Method, exchange.Path = r.Method, r.URL.Path
+	exchange.RequestContentType, exchange.RequestBody = r.Header.Get("Content-Type"), body
+	for _, violation := range conformance.Observe(m.proxyConfig.Name, m.session.SessionName, exchange) {
+		log.Printf("OpenAPI violation: %s %s: %s", violation.Method, violation.Path, violation.Message)
+	}
+}
+
+// sendNotFoundResponse answers a request no recording matches. With mock.debug on, the body
+// also explains the miss. With mock.fallback set, the request is forwarded to the target instead.
+func (m *MockEngine) sendNotFoundResponse(w http.ResponseWriter, r *http.Request, miss *nearmiss.Miss) {
+	if m.fallback != nil {
+		log.Printf("[MOCK] No recording for %s %s, forwarding to %s:%d", r.Method, r.URL.Path, m.proxyConfig.TargetHost, m.proxyConfig.TargetPort)
+		metrics.RecordMockMiss(m.proxyConfig.Name)
+		m.recordCall(r, false)
+		if header := m.sessionHeader(); header != "" {
+			r.Header.Del(header)
+		}
+		m.fallback.HandleRequest(w, r)
+		return
+	}
+	m.sendMissResponse(w, r, miss, m.mockConfig.NotFoundResponse, "Recording not found")
+}
+
+// sendSequenceExhaustedResponse answers a request whose strict sequence has served all its
+// recordings, with mock.sequence_exhausted_response
+func (m *MockEngine) sendSequenceExhaustedResponse(w http.ResponseWriter, r *http.Request, miss *nearmiss.Miss) {
+	m.sendMissResponse(w, r, miss, m.mockConfig.SequenceExhaustedResponse, "Recording sequence exhausted")
+}
+
+// sendMissResponse counts a miss and answers it as configured: 404 and a JSON body with
+// defaultError unless set, and with the miss explained in a JSON body when mock.debug is on
+func (m *MockEngine) sendMissResponse(w http.ResponseWriter, r *http.Request, miss *nearmiss.Miss, response config.NotFoundResponseConfig, defaultError string) {
+	metrics.RecordMockMiss(m.proxyConfig.Name)
+	accesslog.Annotate(r.Context(), m.session.SessionName, accesslog.MatchMiss)
+	webhook.MockMiss(m.proxyConfig.Name, m.session.SessionName, "REST", r.Method, r.URL.Path)
+	m.recordCall(r, false)
+
+	status := response.Status
+	if status == 0 {
+		status = http.StatusNotFound
+	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(404) // Default not found status
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
 
-	notFoundBody := map[string]interface{}{
-		"error": "Recording not found",
+	if response.Template != "" {
+		rendered, err := m.renderTemplate([]byte(response.Template), r)
+		if err == nil {
+			w.WriteHeader(status)
+			w.Write(rendered)
+			return
+		}
+		log.Printf("Answering %s %s without the not found template: %v", r.Method, r.URL.Path, err)
+	}
+
+	body := map[string]interface{}{"error": defaultError}
+	if response.Body != nil {
+		body = make(map[string]interface{}, len(response.Body))
+		for key, value := range response.Body {
+			body[key] = value
+		}
 	}
-	if err := json.NewEncoder(w).Encode(notFoundBody); err != nil {
+	if miss != nil && m.mockConfig.Debug {
+		body["reason"] = miss.Reason
+		body["near_misses"] = miss.Candidates
+	}
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
 		log.Printf("Error encoding not found response: %v", err)
 	}
 }
 
 func (m *MockEngine) Stop() error {
+	m.FlushSequenceState()
 	if m.grpcServer != nil {
 		m.grpcServer.GracefulStop()
 	}
@@ -679,27 +1322,102 @@ func (m *MockEngine) GetGRPCServer() *grpc.Server {
 }
 
 func (m *MockEngine) ResetSequenceState() {
-	m.sequenceMutex.Lock()
-	defer m.sequenceMutex.Unlock()
-
-	m.sequenceState = make(map[string]int)
+	m.sequences.reset()
 	log.Printf("Reset sequence state for mock engine")
 }
 
+// ResetSequenceSignature rewinds a single request signature, reporting whether it was tracked
+func (m *MockEngine) ResetSequenceSignature(signature string) bool {
+	if !m.sequences.resetSignature(signature) {
+		return false
+	}
+	log.Printf("Reset sequence state for signature %q", signature)
+	return true
+}
+
 func (m *MockEngine) GetSequenceState() map[string]int {
-	m.sequenceMutex.RLock()
-	defer m.sequenceMutex.RUnlock()
+	return m.sequences.positions()
+}
+
+// SequencePosition is how far ordered playback has got for one request signature
+type SequencePosition struct {
+	Session   string // Session answering the signature: the one a request named, else the engine's
+	Signature string // Key the position is kept under, as ResetSequenceSignature takes it
+	Method    string
+	Path      string
+	Position  int
+}
+
+// SequencePositions reports every tracked sequence position with the session it belongs to
+func (m *MockEngine) SequencePositions() []SequencePosition {
+	positions := []SequencePosition{}
+	for key, position := range m.sequences.positions() {
+		session, signature := splitSequenceKey(key)
+		if session == "" {
+			session = m.session.SessionName
+		}
+		entry := SequencePosition{Session: session, Signature: key, Position: position}
+		entry.Method, entry.Path = splitSignature(signature)
+		positions = append(positions, entry)
+	}
+	return positions
+}
+
+// splitSignature returns the method and path of a "METHOD:PATH:HEADERS:BODY" request signature.
+// Paths can hold ":" themselves, as in /v1/items:batchGet, so the path runs up to the headers,
+// whose JSON object always follows it.
+func splitSignature(signature string) (method, path string) {
+	method, rest, ok := strings.Cut(signature, ":")
+	if !ok {
+		return "", ""
+	}
+	for offset := 0; ; {
+		index := strings.Index(rest[offset:], ":{")
+		if index < 0 {
+			// The fallback signature has no headers or body
+			return method, rest
+		}
+		index += offset
+		if headers := rest[index+1:]; strings.HasPrefix(headers, `{"`) || strings.HasPrefix(headers, "{}:") {
+			return method, rest[:index]
+		}
+		offset = index + 1
+	}
+}
+
+// ResetSessionSequences rewinds the sequences answered from one session, the engine's own or one
+// requests named, optionally only one signature, and returns how many it rewound
+func (m *MockEngine) ResetSessionSequences(sessionName, signature string) int {
+	reset := 0
+	for _, position := range m.SequencePositions() {
+		if position.Session != sessionName || (signature != "" && position.Signature != signature) {
+			continue
+		}
+		if m.sequences.resetSignature(position.Signature) {
+			reset++
+		}
+	}
+	if reset > 0 {
+		log.Printf("Reset %d sequence(s) of session '%s'", reset, sessionName)
+	}
+	return reset
+}
 
-	state := make(map[string]int)
-	for key, value := range m.sequenceState {
-		state[key] = value
+// FlushSequenceState writes sequence positions kept with interval persistence to the database
+// without waiting for the interval
+func (m *MockEngine) FlushSequenceState() {
+	if store, ok := m.sequences.(*flushingSequenceStore); ok {
+		store.flush()
 	}
+}
 
-	return state
+// SessionName is the name of the recorded session the engine serves
+func (m *MockEngine) SessionName() string {
+	return m.session.SessionName
 }
 
 // handleGRPCMockRequest handles gRPC mock requests
-func handleGRPCMockRequest(stream grpc.ServerStream, db *storage.Database, session *storage.Session, grpcHandler *proxy.GRPCHandler, webServer WebBroadcaster) error {
+func handleGRPCMockRequest(stream grpc.ServerStream, proxyName string, consumers config.ConsumerConfig, db *storage.Database, session *storage.Session, matcher *MockEngine, webServer WebBroadcaster) error {
 	fullMethodName, ok := grpc.MethodFromServerStream(stream)
 	if !ok {
 		return status.Errorf(codes.Internal, "failed to get method from stream")
@@ -707,25 +1425,49 @@ func handleGRPCMockRequest(stream grpc.ServerStream, db *storage.Database, sessi
 
 	log.Printf("[GRPC MOCK] %s", fullMethodName)
 
+	// A call can name another recorded session to be answered from
+	routeSession := session
+	if session = sessionFromContext(stream.Context(), db, matcher.sessionHeader(), routeSession); session == nil {
+		recordGRPCMockMiss(stream.Context(), proxyName, routeSession, fullMethodName)
+		recordGRPCCall(matcher.callLog(), stream.Context(), proxyName, fullMethodName, nil, false)
+		return status.Errorf(codes.NotFound, "no session named %s", metadataValue(stream.Context(), matcher.sessionHeader()))
+	}
+
 	// Find matching gRPC interactions
 	interactions, err := db.FindMatchingInteractions(session.ID, fullMethodName, fullMethodName)
 	if err != nil {
 		log.Printf("Error finding matching gRPC interactions: %v", err)
 		return status.Errorf(codes.Internal, "failed to find matching interactions")
 	}
+	interactions = consumer.Filter(interactions, consumer.RequestedFromContext(stream.Context(), consumers))
+	interactions = tag.Filter(interactions, tag.RequestedFromContext(stream.Context(), matcher.servedTag()))
 
 	if len(interactions) == 0 {
 		log.Printf("No matching gRPC interactions found for %s", fullMethodName)
+		recordGRPCMockMiss(stream.Context(), proxyName, session, fullMethodName)
+		recordGRPCCall(matcher.callLog(), stream.Context(), proxyName, fullMethodName, nil, false)
 		return status.Errorf(codes.NotFound, "no recorded interaction found for method %s", fullMethodName)
 	}
 
-	// For simplicity, use the first matching interaction
-	// In a more sophisticated implementation, we could add sequence support for gRPC
-	selectedInteraction := &interactions[0]
+	// Receive the request message from the client (required for unary calls) to match it
+	var requestMsg mockRawMessage
+	if err := stream.RecvMsg(&requestMsg); err != nil {
+		log.Printf("Error receiving request message: %v", err)
+		return status.Errorf(codes.Internal, "failed to receive request: %v", err)
+	}
 
-	// Create a mock gRPC response
-	// Note: This is a simplified implementation
-	// In practice, we would need to handle protobuf message types dynamically
+	interactions = matcher.matchGRPCRequest(fullMethodName, interactions, requestMsg.Data)
+	if len(interactions) == 0 {
+		log.Printf("No gRPC interactions match the request message for %s", fullMethodName)
+		recordGRPCMockMiss(stream.Context(), proxyName, session, fullMethodName)
+		recordGRPCCall(matcher.callLog(), stream.Context(), proxyName, fullMethodName, requestMsg.Data, false)
+		return status.Errorf(codes.NotFound, "no recorded interaction matches the request for method %s", fullMethodName)
+	}
+
+	// Of the matching interactions, use the first
+	selectedInteraction := &interactions[0]
+	metrics.RecordMockHit(proxyName)
+	accesslog.Annotate(stream.Context(), session.SessionName, accesslog.MatchHit)
 
 	// Send response headers/metadata if present
 	if selectedInteraction.ResponseHeaders != "" {
@@ -741,12 +1483,7 @@ func handleGRPCMockRequest(stream grpc.ServerStream, db *storage.Database, sessi
 		}
 	}
 
-	// First, receive the request message from the client (required for unary calls)
-	var requestMsg mockRawMessage
-	if err := stream.RecvMsg(&requestMsg); err != nil {
-		log.Printf("Error receiving request message: %v", err)
-		return status.Errorf(codes.Internal, "failed to receive request: %v", err)
-	}
+	recordGRPCCall(matcher.callLog(), stream.Context(), proxyName, fullMethodName, requestMsg.Data, true)
 
 	// Generate request ID for tracking
 	requestID := proxy.GenerateRequestID()
@@ -755,7 +1492,7 @@ func handleGRPCMockRequest(stream grpc.ServerStream, db *storage.Database, sessi
 	if webServer != nil {
 		headers := make(map[string]interface{})
 		body := fmt.Sprintf("gRPC mock request (%d bytes)", len(requestMsg.Data))
-		webServer.BroadcastRequest(fullMethodName, fullMethodName, session.SessionName, "grpc-mock-client", requestID, headers, body)
+		webServer.BroadcastRequest(proxyName, fullMethodName, fullMethodName, session.SessionName, "grpc-mock-client", requestID, headers, body)
 	}
 
 	// Send the recorded response body if available
@@ -774,7 +1511,7 @@ func handleGRPCMockRequest(stream grpc.ServerStream, db *storage.Database, sessi
 	if webServer != nil {
 		responseHeaders := make(map[string]interface{})
 		responseBody := fmt.Sprintf("gRPC mock response (%d bytes)", len(selectedInteraction.ResponseBody))
-		webServer.BroadcastResponse(fullMethodName, fullMethodName, session.SessionName, "grpc-mock-client", requestID, selectedInteraction.ResponseStatus, responseHeaders, responseBody)
+		webServer.BroadcastResponse(proxyName, fullMethodName, fullMethodName, session.SessionName, "grpc-mock-client", requestID, selectedInteraction.ResponseStatus, responseHeaders, responseBody)
 	}
 
 	return nil
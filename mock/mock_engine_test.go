@@ -2,13 +2,33 @@ package mock
 
 import (
 	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/websocket"
+
+	"mimic/calllog"
+	"mimic/clock"
 	"mimic/config"
+	"mimic/fault"
+	"mimic/nearmiss"
 	"mimic/proxy"
+	"mimic/redact"
+	"mimic/storage"
+	"mimic/stub"
 )
 
 // Helper function to create a mock HTTP request with a body
@@ -403,3 +423,1942 @@ func TestMatchesHeaders_FuzzyIgnoreFields(t *testing.T) {
 		})
 	}
 }
+
+func TestMatchesHeaders_MatchAndIgnoreLists(t *testing.T) {
+	recorded, _ := json.Marshal(map[string]string{
+		"Content-Type": "application/json",
+		"Traceparent":  "00-aaaa-01",
+		"User-Agent":   "client/1.0",
+	})
+	tests := []struct {
+		name          string
+		matchHeaders  []string
+		ignoreHeaders []string
+		requestHdrs   http.Header
+		expectedMatch bool
+	}{
+		{
+			name:          "Ignored headers differ",
+			ignoreHeaders: []string{"traceparent", "user-agent"},
+			requestHdrs:   http.Header{"Content-Type": {"application/json"}, "Traceparent": {"00-bbbb-01"}, "User-Agent": {"client/2.0"}},
+			expectedMatch: true,
+		},
+		{
+			name:          "Compared header differs",
+			ignoreHeaders: []string{"traceparent", "user-agent"},
+			requestHdrs:   http.Header{"Content-Type": {"text/plain"}, "Traceparent": {"00-bbbb-01"}, "User-Agent": {"client/1.0"}},
+			expectedMatch: false,
+		},
+		{
+			name:          "Only matched headers compared",
+			matchHeaders:  []string{"content-type"},
+			requestHdrs:   http.Header{"Content-Type": {"application/json"}, "Accept": {"*/*"}},
+			expectedMatch: true,
+		},
+		{
+			name:          "Matched header missing",
+			matchHeaders:  []string{"Content-Type", "Authorization"},
+			requestHdrs:   http.Header{"Content-Type": {"application/json"}, "Authorization": {"Bearer token"}},
+			expectedMatch: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockEngine := &MockEngine{
+				mockConfig:  &config.MockConfig{MatchingStrategy: "exact", MatchHeaders: tt.matchHeaders, IgnoreHeaders: tt.ignoreHeaders},
+				restHandler: proxy.NewRESTHandler([]string{}),
+			}
+			if result := mockEngine.matchesHeaders(string(recorded), tt.requestHdrs); result != tt.expectedMatch {
+				t.Errorf("Expected match=%v, got match=%v", tt.expectedMatch, result)
+			}
+		})
+	}
+}
+
+func TestMatchesHeaders_Canonicalized(t *testing.T) {
+	recorded, _ := json.Marshal(map[string]string{
+		"accept":     "application/json, text/plain",
+		"Cookie":     "theme=dark; session=abc",
+		"User-Agent": "Mozilla/5.0 (X11, Linux)",
+		"x-api-key":  "key",
+	})
+	tests := []struct {
+		name          string
+		requestHdrs   http.Header
+		expectedMatch bool
+	}{
+		{
+			name:          "Same headers",
+			requestHdrs:   http.Header{"Accept": {"application/json, text/plain"}, "Cookie": {"theme=dark; session=abc"}, "User-Agent": {"Mozilla/5.0 (X11, Linux)"}, "X-Api-Key": {"key"}},
+			expectedMatch: true,
+		},
+		{
+			name:          "Values reordered and split across lines",
+			requestHdrs:   http.Header{"Accept": {"text/plain", "application/json"}, "Cookie": {"session=abc;theme=dark"}, "User-Agent": {"Mozilla/5.0 (X11, Linux)"}, "X-Api-Key": {"key"}},
+			expectedMatch: true,
+		},
+		{
+			name:          "Comment in value not split",
+			requestHdrs:   http.Header{"Accept": {"application/json, text/plain"}, "Cookie": {"theme=dark; session=abc"}, "User-Agent": {"Linux), Mozilla/5.0 (X11"}, "X-Api-Key": {"key"}},
+			expectedMatch: false,
+		},
+		{
+			name:          "Value differs",
+			requestHdrs:   http.Header{"Accept": {"application/json"}, "Cookie": {"theme=dark; session=abc"}, "User-Agent": {"Mozilla/5.0 (X11, Linux)"}, "X-Api-Key": {"key"}},
+			expectedMatch: false,
+		},
+	}
+
+	mockEngine := &MockEngine{
+		mockConfig:  &config.MockConfig{MatchingStrategy: "exact"},
+		restHandler: proxy.NewRESTHandler([]string{}),
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := mockEngine.matchesHeaders(string(recorded), tt.requestHdrs); result != tt.expectedMatch {
+				t.Errorf("Expected match=%v, got match=%v", tt.expectedMatch, result)
+			}
+		})
+	}
+}
+
+func TestProxyHeaderListsOverrideMockConfig(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	proxyConfig := config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "headers", IgnoreHeaders: []string{"X-Trace-Id"}}
+	engine, err := NewMockEngine(proxyConfig, config.MockConfig{IgnoreHeaders: []string{"User-Agent"}}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	if got := engine.mockConfig.IgnoreHeaders; len(got) != 1 || got[0] != "X-Trace-Id" {
+		t.Errorf("Expected the proxy's ignore_headers, got %v", got)
+	}
+}
+
+func TestResetSequenceSignature(t *testing.T) {
+	mockEngine := &MockEngine{
+		sequences: &memorySequenceStore{state: map[string]int{
+			"GET:/poll:{}:":  3,
+			"GET:/other:{}:": 1,
+		}},
+	}
+
+	if !mockEngine.ResetSequenceSignature("GET:/poll:{}:") {
+		t.Error("Expected tracked signature to be reset")
+	}
+	if mockEngine.ResetSequenceSignature("GET:/poll:{}:") {
+		t.Error("Expected second reset of the same signature to report nothing to reset")
+	}
+
+	state := mockEngine.GetSequenceState()
+	if _, ok := state["GET:/poll:{}:"]; ok {
+		t.Error("Expected reset signature to be removed from sequence state")
+	}
+	if state["GET:/other:{}:"] != 1 {
+		t.Errorf("Expected other signature to keep position 1, got %d", state["GET:/other:{}:"])
+	}
+}
+
+func TestSequencePositionsSplitSignature(t *testing.T) {
+	mockEngine := &MockEngine{
+		session: &storage.Session{SessionName: "items"},
+		sequences: &memorySequenceStore{state: map[string]int{
+			`POST:/v1/items:batchGet:{"Content-Type":"application/json"}:{"ids":["a:b"]}`: 2,
+			"checkout|GET:/v1/items/1:{}:":  1,
+			"GET:/v1/items/2:{x}?q=a:b:{}:": 1,
+			"GET:/fallback:path":            1,
+		}},
+	}
+
+	expected := map[string][3]string{
+		`POST:/v1/items:batchGet:{"Content-Type":"application/json"}:{"ids":["a:b"]}`: {"items", "POST", "/v1/items:batchGet"},
+		"checkout|GET:/v1/items/1:{}:":  {"checkout", "GET", "/v1/items/1"},
+		"GET:/v1/items/2:{x}?q=a:b:{}:": {"items", "GET", "/v1/items/2:{x}?q=a:b"},
+		"GET:/fallback:path":            {"items", "GET", "/fallback:path"},
+	}
+	positions := mockEngine.SequencePositions()
+	if len(positions) != len(expected) {
+		t.Fatalf("Expected %d positions, got %+v", len(expected), positions)
+	}
+	for _, position := range positions {
+		want := expected[position.Signature]
+		if got := [3]string{position.Session, position.Method, position.Path}; got != want {
+			t.Errorf("Expected %q for %s, got %q", want, position.Signature, got)
+		}
+	}
+}
+
+func TestSharedSequenceStore(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "shared.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Two replicas serving the same proxy share one sequence through the database
+	replicas := []*MockEngine{
+		{proxyConfig: &config.ProxyConfig{Name: "payments"}, restHandler: proxy.NewRESTHandler(nil), sequences: newDatabaseSequenceStore(db, "payments", "default")},
+		{proxyConfig: &config.ProxyConfig{Name: "payments"}, restHandler: proxy.NewRESTHandler(nil), sequences: newDatabaseSequenceStore(db, "payments", "default")},
+	}
+	interactions := []storage.Interaction{
+		{SequenceNumber: 1, ResponseBody: []byte("pending")},
+		{SequenceNumber: 2, ResponseBody: []byte("settled")},
+	}
+
+	var bodies []string
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", "/payments/42", nil)
+		bodies = append(bodies, string(replicas[i%2].selectSequentialInteraction(interactions, req).ResponseBody))
+	}
+	if bodies[0] != "pending" || bodies[1] != "settled" || bodies[2] != "pending" {
+		t.Errorf("Expected replicas to walk one sequence together, got %v", bodies)
+	}
+
+	replicas[1].ResetSequenceState()
+	if state := replicas[0].GetSequenceState(); len(state) != 0 {
+		t.Errorf("Expected a reset on one replica to be seen by the other, got %v", state)
+	}
+}
+
+func TestSequenceModes(t *testing.T) {
+	interactions := []storage.Interaction{
+		{SequenceNumber: 1, ResponseBody: []byte("first")},
+		{SequenceNumber: 2, ResponseBody: []byte("second")},
+	}
+	tests := []struct {
+		mode     string
+		expected []string
+	}{
+		{SequenceOrdered, []string{"first", "second", "first", "second"}},
+		{SequenceOrderedCycle, []string{"first", "second", "first", "second"}},
+		{SequenceRepeatLast, []string{"first", "second", "second", "second"}},
+		{SequenceStrict, []string{"first", "second", "", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.mode, func(t *testing.T) {
+			engine := &MockEngine{
+				proxyConfig: &config.ProxyConfig{Name: "payments"},
+				restHandler: proxy.NewRESTHandler(nil),
+				mockConfig:  &config.MockConfig{SequenceMode: tt.mode},
+				sequences:   newMemorySequenceStore(),
+			}
+			var bodies []string
+			for range tt.expected {
+				req, _ := http.NewRequest("GET", "/payments/42", nil)
+				body := ""
+				if selected := engine.selectSequentialInteraction(interactions, req); selected != nil {
+					body = string(selected.ResponseBody)
+				}
+				bodies = append(bodies, body)
+			}
+			if !reflect.DeepEqual(bodies, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, bodies)
+			}
+		})
+	}
+
+	t.Run(SequenceRandom, func(t *testing.T) {
+		engine := &MockEngine{mockConfig: &config.MockConfig{SequenceMode: SequenceRandom}, restHandler: proxy.NewRESTHandler(nil), sequences: newMemorySequenceStore()}
+		for i := 0; i < 10; i++ {
+			req, _ := http.NewRequest("GET", "/payments/42", nil)
+			if engine.selectSequentialInteraction(interactions, req) == nil {
+				t.Fatal("Expected a random recording")
+			}
+		}
+		if state := engine.GetSequenceState(); len(state) != 0 {
+			t.Errorf("Expected random selection to leave the sequence alone, got %v", state)
+		}
+	})
+}
+
+func TestStrictSequenceExhaustedResponse(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "strict"}, config.MockConfig{
+		MatchingStrategy: "exact",
+		SequenceMode:     SequenceStrict,
+		SequenceExhaustedResponse: config.NotFoundResponseConfig{
+			Status: 410,
+			Body:   map[string]interface{}{"error": "No more recorded responses"},
+		},
+	}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "1", Protocol: "REST", Method: "GET", Endpoint: "/jobs/1",
+		RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{}`, ResponseBody: []byte(`{"status":"done"}`), SequenceNumber: 1}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	for i, expected := range []struct {
+		status int
+		body   string
+	}{
+		{200, `{"status":"done"}`},
+		{410, `{"error":"No more recorded responses"}`},
+		{410, `{"error":"No more recorded responses"}`},
+	} {
+		recorder := httptest.NewRecorder()
+		engine.HandleRequest(recorder, httptest.NewRequest("GET", "/jobs/1", nil))
+		if body := strings.TrimSpace(recorder.Body.String()); recorder.Code != expected.status || body != expected.body {
+			t.Errorf("Request %d: expected %d %s, got %d %s", i, expected.status, expected.body, recorder.Code, body)
+		}
+	}
+
+	engine.ResetSequenceState()
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, httptest.NewRequest("GET", "/jobs/1", nil))
+	if recorder.Code != 200 {
+		t.Errorf("Expected a reset to start the strict sequence over, got %d", recorder.Code)
+	}
+}
+
+func TestMockNotFoundResponse(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	mockConfig := config.MockConfig{
+		MatchingStrategy: "exact",
+		NotFoundResponse: config.NotFoundResponseConfig{
+			Status:  501,
+			Body:    map[string]interface{}{"error": "not recorded"},
+			Headers: map[string]string{"x-mimic-miss": "true"},
+		},
+	}
+
+	shared, err := NewMockEngine(config.ProxyConfig{Name: "shared", Protocol: "http", SessionName: "missing"}, mockConfig, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	w := httptest.NewRecorder()
+	shared.HandleRequest(w, httptest.NewRequest("GET", "/users/1", nil))
+	if body := strings.TrimSpace(w.Body.String()); w.Code != 501 || body != `{"error":"not recorded"}` || w.Header().Get("X-Mimic-Miss") != "true" {
+		t.Errorf("Expected the mock section's not found response, got %d %s %v", w.Code, body, w.Header())
+	}
+
+	// A proxy's own response replaces the mock section's, here with a template
+	own, err := NewMockEngine(config.ProxyConfig{Name: "own", Protocol: "http", SessionName: "missing",
+		NotFoundResponse: config.NotFoundResponseConfig{
+			Headers:  map[string]string{"Content-Type": "text/plain"},
+			Template: "no recording for {{ .Method }} {{ .Path }}",
+		},
+	}, mockConfig, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	w = httptest.NewRecorder()
+	own.HandleRequest(w, httptest.NewRequest("DELETE", "/users/1", nil))
+	if w.Code != 404 || w.Body.String() != "no recording for DELETE /users/1" || w.Header().Get("Content-Type") != "text/plain" || w.Header().Get("X-Mimic-Miss") != "" {
+		t.Errorf("Expected the proxy's templated not found response, got %d %q %v", w.Code, w.Body.String(), w.Header())
+	}
+}
+
+func TestPersistedSequenceState(t *testing.T) {
+	for _, persist := range []string{PersistSequencesImmediate, PersistSequencesInterval} {
+		t.Run(persist, func(t *testing.T) {
+			db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "persist.db"))
+			if err != nil {
+				t.Fatalf("Failed to create database: %v", err)
+			}
+			defer db.Close()
+
+			proxyConfig := config.ProxyConfig{Name: "payments", Protocol: "http", SessionName: "fixtures"}
+			mockConfig := config.MockConfig{MatchingStrategy: "exact", PersistSequences: persist, SequenceFlushIntervalMS: 60000}
+			engine, err := NewMockEngine(proxyConfig, mockConfig, db)
+			if err != nil {
+				t.Fatalf("Failed to create mock engine: %v", err)
+			}
+			for i, body := range []string{"pending", "settled"} {
+				interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: body, Protocol: "REST", Method: "GET", Endpoint: "/payments/42",
+					RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{}`, ResponseBody: []byte(body), SequenceNumber: i + 1}
+				if err := db.RecordInteraction(interaction); err != nil {
+					t.Fatalf("Failed to record interaction: %v", err)
+				}
+			}
+			serve := func(engine *MockEngine) string {
+				recorder := httptest.NewRecorder()
+				engine.HandleRequest(recorder, httptest.NewRequest("GET", "/payments/42", nil))
+				return recorder.Body.String()
+			}
+
+			if body := serve(engine); body != "pending" {
+				t.Fatalf("Expected the first recording, got %q", body)
+			}
+			engine.Stop()
+
+			// A restart picks up where the sequence was
+			restarted, err := NewMockEngine(proxyConfig, mockConfig, db)
+			if err != nil {
+				t.Fatalf("Failed to create mock engine: %v", err)
+			}
+			if body := serve(restarted); body != "settled" {
+				t.Errorf("Expected the restarted engine to resume with the second recording, got %q", body)
+			}
+		})
+	}
+}
+
+func TestCallsAreLogged(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "calls.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "orders", Protocol: "http", SessionName: "fixtures"}, config.MockConfig{}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "r1", Protocol: "REST", Method: "POST", Endpoint: "/orders", RequestHeaders: "{}", ResponseStatus: 201, ResponseHeaders: "{}"}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	checkpoint := calllog.Default.Checkpoint()
+	for _, path := range []string{"/orders", "/unknown"} {
+		req, _ := http.NewRequest("POST", path, nil)
+		engine.HandleRequest(httptest.NewRecorder(), req)
+	}
+
+	// Misses are logged too, marked as such
+	calls, err := calllog.Default.Find(calllog.Matcher{Proxy: "orders", Since: checkpoint})
+	if err != nil {
+		t.Fatalf("Find failed: %v", err)
+	}
+	if len(calls) != 2 || calls[0].Path != "/orders" || !calls[0].Matched || calls[1].Path != "/unknown" || calls[1].Matched {
+		t.Errorf("Expected the served POST /orders and the missed POST /unknown to be logged, got %+v", calls)
+	}
+}
+
+func TestMockSessionHeader(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "checkout-v1"}, config.MockConfig{MatchingStrategy: "exact"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	v2, err := db.GetOrCreateSession("checkout-v2", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	for i, recording := range []struct {
+		sessionID int
+		body      string
+	}{{engine.session.ID, "v1"}, {v2.ID, "v2 first"}, {v2.ID, "v2 second"}} {
+		interaction := &storage.Interaction{SessionID: recording.sessionID, RequestID: "cart-" + strconv.Itoa(i), Protocol: "REST", Method: "GET", Endpoint: "/cart",
+			RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{}`, ResponseBody: []byte(recording.body)}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	serve := func(session string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/cart", nil)
+		if session != "" {
+			req.Header.Set("X-Mimic-Session", session)
+		}
+		w := httptest.NewRecorder()
+		engine.HandleRequest(w, req)
+		return w
+	}
+
+	// The named session is answered from, with its own sequence, and the header is not matched on
+	for i, tc := range []struct{ session, body string }{
+		{"checkout-v2", "v2 first"},
+		{"", "v1"},
+		{"checkout-v2", "v2 second"},
+		{"checkout-v1", "v1"},
+	} {
+		if w := serve(tc.session); w.Code != 200 || w.Body.String() != tc.body {
+			t.Errorf("Request %d for session %q: expected %q, got %d %q", i, tc.session, tc.body, w.Code, w.Body.String())
+		}
+	}
+	if w := serve("missing"); w.Code != 404 {
+		t.Errorf("Expected an unknown session to be a miss, got %d", w.Code)
+	}
+}
+
+func TestMockSessionHeaderRecorded(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "checkout-v1"}, config.MockConfig{MatchingStrategy: "exact"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	// A client that sent the header while recording left it in the recording
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "cart", Protocol: "REST", Method: "GET", Endpoint: "/cart",
+		RequestHeaders: `{"X-Mimic-Session":"checkout-v1"}`, ResponseStatus: 200, ResponseHeaders: `{}`, ResponseBody: []byte("v1")}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	for _, session := range []string{"", "checkout-v1"} {
+		req := httptest.NewRequest("GET", "/cart", nil)
+		if session != "" {
+			req.Header.Set("X-Mimic-Session", session)
+		}
+		w := httptest.NewRecorder()
+		engine.HandleRequest(w, req)
+		if w.Code != 200 || w.Body.String() != "v1" {
+			t.Errorf("Expected the recording matched with session header %q, got %d %q", session, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestMockResponseDatedByVirtualClock(t *testing.T) {
+	interaction := &storage.Interaction{ResponseStatus: 200, ResponseHeaders: `{"Date": "Mon, 01 Jan 2024 00:00:00 GMT"}`}
+	engine := &MockEngine{}
+
+	recorder := httptest.NewRecorder()
+	engine.sendMockResponse(recorder, httptest.NewRequest("GET", "/", nil), interaction, fault.Outcome{})
+	if got := recorder.Header().Get("Date"); got != "Mon, 01 Jan 2024 00:00:00 GMT" {
+		t.Errorf("Expected the recorded date while the clock is real, got %q", got)
+	}
+
+	clock.Default.Set(time.Date(2030, 6, 1, 9, 30, 0, 0, time.UTC), true)
+	defer clock.Default.Reset()
+	recorder = httptest.NewRecorder()
+	engine.sendMockResponse(recorder, httptest.NewRequest("GET", "/", nil), interaction, fault.Outcome{})
+	if got := recorder.Header().Get("Date"); got != "Sat, 01 Jun 2030 09:30:00 GMT" {
+		t.Errorf("Expected the virtual clock's date, got %q", got)
+	}
+}
+
+func TestMockRendersResponseTemplates(t *testing.T) {
+	clock.Default.Set(time.Date(2030, 6, 1, 9, 30, 0, 0, time.UTC), true)
+	defer clock.Default.Reset()
+
+	interaction := &storage.Interaction{
+		ResponseStatus:  201,
+		ResponseHeaders: `{"Content-Type": "application/json", "Content-Length": "99"}`,
+		ResponseBody: []byte(`{"id":"{{ index .Segments 1 }}","page":"{{ .Query.Get "page" }}","trace":"{{ .Headers.Get "X-Trace-Id" }}",` +
+			`"name":"{{ .Body.user.name }}","user":{{ json .Body.user }},"at":"{{ now.Format "2006-01-02" }}","ref":"{{ uuid }}"}`),
+	}
+	interaction.SetMetadataValue(storage.MetadataTemplate, true)
+
+	request := func() *http.Request {
+		req := httptest.NewRequest("POST", "/orders/42?page=3", strings.NewReader(`{"user":{"name":"Ada"}}`))
+		req.Header.Set("X-Trace-Id", "abc")
+		return req
+	}
+	serve := func(engine *MockEngine, interaction *storage.Interaction) map[string]interface{} {
+		recorder := httptest.NewRecorder()
+		if err := engine.sendMockResponse(recorder, request(), interaction, fault.Outcome{}); err != nil {
+			t.Fatalf("sendMockResponse failed: %v", err)
+		}
+		if recorder.Header().Get("Content-Length") != strconv.Itoa(recorder.Body.Len()) {
+			t.Errorf("Expected Content-Length to match the rendered body, got %s for %d bytes", recorder.Header().Get("Content-Length"), recorder.Body.Len())
+		}
+		var body map[string]interface{}
+		if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+			t.Fatalf("Expected a JSON body, got %q: %v", recorder.Body.String(), err)
+		}
+		return body
+	}
+
+	body := serve(&MockEngine{}, interaction)
+	for key, expected := range map[string]interface{}{"id": "42", "page": "3", "trace": "abc", "name": "Ada", "at": "2030-06-01"} {
+		if body[key] != expected {
+			t.Errorf("Expected %s to be %v, got %v", key, expected, body[key])
+		}
+	}
+	if !reflect.DeepEqual(body["user"], map[string]interface{}{"name": "Ada"}) {
+		t.Errorf("Expected the user object echoed, got %v", body["user"])
+	}
+	if ref, _ := body["ref"].(string); len(ref) != 36 {
+		t.Errorf("Expected a UUID, got %v", body["ref"])
+	}
+
+	// Recordings not marked as templates are served as recorded unless templating is on
+	plain := *interaction
+	plain.Metadata = ""
+	recorder := httptest.NewRecorder()
+	(&MockEngine{}).sendMockResponse(recorder, request(), &plain, fault.Outcome{})
+	if recorder.Body.String() != string(plain.ResponseBody) {
+		t.Errorf("Expected the unmarked recording as recorded, got %s", recorder.Body.String())
+	}
+	if body := serve(&MockEngine{mockConfig: &config.MockConfig{Templating: true}}, &plain); body["id"] != "42" {
+		t.Errorf("Expected mock.templating to render every recording, got %v", body)
+	}
+}
+
+func TestResponseDelay(t *testing.T) {
+	timed := func(ms int) storage.Interaction {
+		interaction := storage.Interaction{}
+		interaction.SetTiming(time.Now(), time.Duration(ms)*time.Millisecond)
+		return interaction
+	}
+	endpoint := []storage.Interaction{timed(100), timed(400), timed(200), timed(300), {}}
+	selected := &endpoint[0]
+
+	tests := []struct {
+		name     string
+		latency  config.LatencyConfig
+		expected time.Duration
+	}{
+		{"None", config.LatencyConfig{}, 0},
+		{"Recorded", config.LatencyConfig{Mode: LatencyRecorded}, 100 * time.Millisecond},
+		{"Recorded scaled", config.LatencyConfig{Mode: LatencyRecorded, Scale: 0.5}, 50 * time.Millisecond},
+		{"Fixed", config.LatencyConfig{Mode: LatencyFixed, FixedMS: 250}, 250 * time.Millisecond},
+		{"Random without spread", config.LatencyConfig{Mode: LatencyRandom, MinMS: 70, MaxMS: 70}, 70 * time.Millisecond},
+		{"Median", config.LatencyConfig{Mode: LatencyPercentile, Percentile: 50}, 200 * time.Millisecond},
+		{"P95", config.LatencyConfig{Mode: LatencyPercentile, Percentile: 95}, 400 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := &MockEngine{mockConfig: &config.MockConfig{Latency: tt.latency}}
+			if delay := engine.responseDelay(selected, endpoint); delay != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, delay)
+			}
+		})
+	}
+
+	engine := &MockEngine{mockConfig: &config.MockConfig{Latency: config.LatencyConfig{Mode: LatencyRandom, MinMS: 10, MaxMS: 20}}}
+	for i := 0; i < 20; i++ {
+		if delay := engine.responseDelay(selected, endpoint); delay < 10*time.Millisecond || delay > 20*time.Millisecond {
+			t.Fatalf("Expected a random delay between 10ms and 20ms, got %v", delay)
+		}
+	}
+	if delay := (&MockEngine{mockConfig: &config.MockConfig{Latency: config.LatencyConfig{Mode: LatencyRecorded}}}).responseDelay(&endpoint[4], endpoint); delay != 0 {
+		t.Errorf("Expected no delay for a recording without timing, got %v", delay)
+	}
+}
+
+func TestStreamingPlaybackSpeed(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "llm", Protocol: "http", SessionName: "streams"}, config.MockConfig{
+		MatchingStrategy: "exact",
+		Streaming:        config.StreamingConfig{Speed: 2},
+		Rules: []config.MatchRule{
+			{Path: "/capped", Streaming: &config.StreamingConfig{Speed: 1, MaxChunkDelayMS: 10}},
+			{Path: "/instant", Streaming: &config.StreamingConfig{Instant: true}},
+		},
+	}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	for _, path := range []string{"/paced", "/capped", "/instant"} {
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: path, Protocol: "REST", Method: "POST", Endpoint: path,
+			RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{"Content-Type":"text/event-stream"}`, IsStreaming: true, SequenceNumber: 1}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+		var chunks []*storage.StreamChunk
+		for i := 0; i < 3; i++ {
+			chunks = append(chunks, &storage.StreamChunk{InteractionID: interaction.ID, ChunkIndex: i, Data: []byte("data: " + strconv.Itoa(i) + "\n\n"), TimeDelta: 200})
+		}
+		if err := db.RecordStreamChunks(chunks); err != nil {
+			t.Fatalf("Failed to record stream chunks: %v", err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		min, max time.Duration
+	}{
+		{"/paced", 200 * time.Millisecond, 390 * time.Millisecond}, // Two 100ms gaps at twice the recorded pace
+		{"/capped", 20 * time.Millisecond, 190 * time.Millisecond}, // Two gaps capped at 10ms
+		{"/instant", 0, 190 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		start := time.Now()
+		engine.HandleRequest(w, httptest.NewRequest("POST", tt.path, nil))
+		elapsed := time.Since(start)
+		if w.Body.String() != "data: 0\n\ndata: 1\n\ndata: 2\n\n" {
+			t.Errorf("%s: expected every chunk, got %q", tt.path, w.Body.String())
+		}
+		if elapsed < tt.min || elapsed > tt.max {
+			t.Errorf("%s: expected playback between %v and %v, took %v", tt.path, tt.min, tt.max, elapsed)
+		}
+	}
+}
+
+func TestMockChunkedStreamPlayback(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "ndjson"}, config.MockConfig{MatchingStrategy: "exact"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "1", Protocol: "REST", Method: "GET", Endpoint: "/export",
+		RequestHeaders: `{}`, ResponseStatus: 202, ResponseHeaders: `{"Content-Type":"application/x-ndjson","X-Export":"7","Transfer-Encoding":"chunked"}`,
+		IsStreaming: true, SequenceNumber: 1}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+	if err := db.RecordStreamChunks([]*storage.StreamChunk{
+		{InteractionID: interaction.ID, ChunkIndex: 0, Data: []byte("{\"row\":1}\n")},
+		{InteractionID: interaction.ID, ChunkIndex: 1, Data: []byte("{\"row\":2}\n")},
+	}); err != nil {
+		t.Fatalf("Failed to record stream chunks: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("GET", "/export", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "{\"row\":1}\n{\"row\":2}\n" {
+		t.Fatalf("Expected the recorded status and chunks, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "application/x-ndjson" || w.Header().Get("X-Export") != "7" || w.Header().Get("Transfer-Encoding") != "" {
+		t.Errorf("Expected the recorded end-to-end headers, got %v", w.Header())
+	}
+}
+
+func TestMockFaults(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	rule := func(path string, faults config.MockFaultConfig) config.MatchRule {
+		return config.MatchRule{Path: path, Faults: &faults}
+	}
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "faults"}, config.MockConfig{
+		MatchingStrategy: "exact",
+		IgnoreHeaders:    []string{"User-Agent", "Accept-Encoding"},
+		Rules: []config.MatchRule{
+			rule("/error", config.MockFaultConfig{ErrorRate: 1, ErrorStatus: 502}),
+			rule("/reset", config.MockFaultConfig{ResetRate: 1}),
+			rule("/truncate", config.MockFaultConfig{TruncateRate: 1}),
+			rule("/malformed", config.MockFaultConfig{MalformedRate: 1}),
+			rule("/drip", config.MockFaultConfig{DripRate: 1, DripBytes: 10, DripIntervalMS: 20}),
+		},
+	}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	body := `{"items":[1,2,3],"next":null}`
+	for _, path := range []string{"/error", "/reset", "/truncate", "/malformed", "/drip", "/healthy"} {
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: path, Protocol: "REST", Method: "GET", Endpoint: path,
+			RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{"Content-Type": "application/json"}`, ResponseBody: []byte(body), SequenceNumber: 1}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+	server := httptest.NewServer(http.HandlerFunc(engine.HandleRequest))
+	defer server.Close()
+
+	get := func(path string) (*http.Response, []byte, error) {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			return nil, nil, err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return resp, data, err
+	}
+
+	if resp, _, err := get("/error"); err != nil || resp.StatusCode != 502 {
+		t.Errorf("Expected an injected 502, got %v, %v", resp, err)
+	}
+	if _, _, err := get("/reset"); err == nil {
+		t.Error("Expected the connection to be reset")
+	}
+	if _, data, err := get("/truncate"); err == nil || len(data) != len(body)/2 {
+		t.Errorf("Expected the body cut off halfway with an error, got %q, %v", data, err)
+	}
+	resp, data, err := get("/malformed")
+	if err != nil || len(data) != len(body)/2 || json.Valid(data) || resp.ContentLength != int64(len(data)) {
+		t.Errorf("Expected half the body sent as if complete, got %q, %v", data, err)
+	}
+	start := time.Now()
+	if _, data, err := get("/drip"); err != nil || string(data) != body {
+		t.Errorf("Expected the whole body dripped out, got %q, %v", data, err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("Expected the drip to take at least 40ms, took %v", elapsed)
+	}
+	if _, data, err := get("/healthy"); err != nil || string(data) != body {
+		t.Errorf("Expected endpoints without faults to be served as recorded, got %q, %v", data, err)
+	}
+}
+
+func TestMockQuota(t *testing.T) {
+	clock.Default.Set(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC), true)
+	defer clock.Default.Reset()
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "quota"}, config.MockConfig{
+		MatchingStrategy: "exact",
+		SequenceMode:     "repeat-last",
+		IgnoreHeaders:    []string{"X-Api-Key"},
+		Quota:            config.QuotaConfig{Requests: 2, WindowSeconds: 10},
+		Rules: []config.MatchRule{
+			{Path: "/search", Quota: &config.QuotaConfig{Requests: 1, ClientHeader: "X-Api-Key"}},
+		},
+	}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	recordings := []struct {
+		path   string
+		status int
+		body   string
+	}{
+		{"/users", 200, `{"users":[]}`},
+		{"/search", 200, `{"results":[]}`},
+		{"/search", 429, `{"error":{"code":"quota_exceeded"}}`},
+	}
+	for i, recording := range recordings {
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: strconv.Itoa(i), Protocol: "REST", Method: "GET", Endpoint: recording.path,
+			RequestHeaders: `{}`, ResponseStatus: recording.status, ResponseHeaders: `{"Content-Type":"application/json"}`, ResponseBody: []byte(recording.body), SequenceNumber: 1}
+		if recording.status == 429 {
+			// Only served once the quota is spent, never in the sequence
+			interaction.RequestHeaders = `{"X-Never":"sent"}`
+		}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+	get := func(path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("X-Api-Key", key)
+		engine.HandleRequest(w, r)
+		return w
+	}
+
+	for i := 0; i < 2; i++ {
+		if w := get("/users", ""); w.Code != 200 {
+			t.Fatalf("Expected request %d within the quota to be served, got %d", i+1, w.Code)
+		}
+	}
+	clock.Default.Advance(4 * time.Second)
+	w := get("/users", "")
+	if w.Code != 429 || w.Header().Get("Retry-After") != "6" || w.Header().Get("X-RateLimit-Limit") != "2" || !strings.Contains(w.Body.String(), "rate limit exceeded") {
+		t.Errorf("Expected a synthesized 429 with Retry-After 6, got %d %v %s", w.Code, w.Header(), w.Body.String())
+	}
+	clock.Default.Advance(6 * time.Second)
+	if w := get("/users", ""); w.Code != 200 {
+		t.Errorf("Expected a new window to let the request through, got %d", w.Code)
+	}
+
+	// The rule's quota counts per API key, and answers with the recorded quota error
+	if w := get("/search", "a"); w.Code != 200 {
+		t.Errorf("Expected the first search to be served, got %d", w.Code)
+	}
+	if w := get("/search", "a"); w.Code != 429 || w.Body.String() != `{"error":{"code":"quota_exceeded"}}` {
+		t.Errorf("Expected the recorded 429, got %d %s", w.Code, w.Body.String())
+	}
+	if w := get("/search", "b"); w.Code != 200 {
+		t.Errorf("Expected another key to have its own quota, got %d", w.Code)
+	}
+
+	engine.ResetQuotas()
+	if w := get("/search", "a"); w.Code != 200 {
+		t.Errorf("Expected a reset to restore the quota, got %d", w.Code)
+	}
+}
+
+func TestMockFallback(t *testing.T) {
+	upstreamCalls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamCalls++
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"live":true}`))
+	}))
+	defer upstream.Close()
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatalf("Failed to parse upstream URL: %v", err)
+	}
+	port, _ := strconv.Atoi(target.Port())
+
+	for _, tc := range []struct {
+		fallback  string
+		wantCalls int // Upstream calls for the same request sent twice
+	}{
+		{FallbackPassthrough, 2},
+		{FallbackRecord, 1}, // The second is mocked from the first's recording
+	} {
+		t.Run(tc.fallback, func(t *testing.T) {
+			db, err := storage.NewMemoryDatabase()
+			if err != nil {
+				t.Fatalf("Failed to create database: %v", err)
+			}
+			defer db.Close()
+			upstreamCalls = 0
+
+			proxyConfig := config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "fallback", TargetHost: target.Hostname(), TargetPort: port}
+			engine, err := NewMockEngine(proxyConfig, config.MockConfig{MatchingStrategy: "exact", Fallback: tc.fallback}, db)
+			if err != nil {
+				t.Fatalf("Failed to create mock engine: %v", err)
+			}
+
+			for i := 0; i < 2; i++ {
+				w := httptest.NewRecorder()
+				engine.HandleRequest(w, httptest.NewRequest("GET", "/users/42", nil))
+				if w.Code != 200 || w.Body.String() != `{"live":true}` {
+					t.Fatalf("Request %d: expected the live response, got %d %q", i+1, w.Code, w.Body.String())
+				}
+			}
+			if upstreamCalls != tc.wantCalls {
+				t.Errorf("Expected %d upstream calls, got %d", tc.wantCalls, upstreamCalls)
+			}
+		})
+	}
+}
+
+func TestMockServesStubs(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	file := &stub.File{Session: "stubs", Stubs: []stub.Stub{
+		{
+			Request:  stub.Request{Method: "GET", Path: "/users/42", Headers: map[string]string{"X-Api-Key": "secret"}},
+			Response: stub.Response{Body: map[string]interface{}{"id": 42}},
+		},
+		{
+			Request:  stub.Request{Method: "POST", Path: "/users", Body: map[string]interface{}{"name": "Ada"}},
+			Response: stub.Response{Status: 201, Body: "created"},
+		},
+	}}
+	if _, err := stub.Save(db, file); err != nil {
+		t.Fatalf("Failed to save stubs: %v", err)
+	}
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "stubs"}, config.MockConfig{MatchingStrategy: "exact"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+
+	serve := func(method, path, body string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		w := httptest.NewRecorder()
+		engine.HandleRequest(w, req)
+		return w
+	}
+
+	// Headers the stub does not list are not compared
+	w := serve("GET", "/users/42", "", map[string]string{"X-Api-Key": "secret", "User-Agent": "test", "Accept": "*/*"})
+	if w.Code != 200 || w.Body.String() != `{"id":42}` || w.Header().Get("Content-Type") != "application/json" {
+		t.Errorf("Expected the stubbed user, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("GET", "/users/42", "", map[string]string{"X-Api-Key": "wrong"}); w.Code != 404 {
+		t.Errorf("Expected a miss for a different listed header, got %d", w.Code)
+	}
+
+	if w := serve("POST", "/users", `{"name":"Ada"}`, nil); w.Code != 201 || w.Body.String() != "created" {
+		t.Errorf("Expected the stubbed creation, got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve("POST", "/users", `{"name":"Bob"}`, nil); w.Code != 404 {
+		t.Errorf("Expected a miss for a different body, got %d", w.Code)
+	}
+}
+
+func TestMockConditionalResponses(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	payment := stub.Request{Method: "POST", Path: "/payments"}
+	file := &stub.File{Session: "conditions", Stubs: []stub.Stub{
+		{Request: payment, Response: stub.Response{Status: 201, Body: "accepted"}},
+		{
+			Request:  payment,
+			Response: stub.Response{Status: 422, Body: "over limit"},
+			Metadata: map[string]interface{}{"when": []interface{}{"body.amount > 1000", "header.X-Tier != gold"}},
+		},
+		{
+			Request:  payment,
+			Response: stub.Response{Status: 400, Body: "no currency"},
+			Metadata: map[string]interface{}{"when": "body.currency missing"},
+		},
+		{
+			Request:  stub.Request{Method: "GET", Path: "/payments"},
+			Response: stub.Response{Body: "page two"},
+			Metadata: map[string]interface{}{"when": `query.page == "2"`},
+		},
+	}}
+	if _, err := stub.Save(db, file); err != nil {
+		t.Fatalf("Failed to save stubs: %v", err)
+	}
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "conditions"}, config.MockConfig{MatchingStrategy: "exact"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+
+	for _, tc := range []struct {
+		method, path, body, tier string
+		status                   int
+		response                 string
+	}{
+		{"POST", "/payments", `{"amount":50,"currency":"EUR"}`, "", 201, "accepted"},
+		{"POST", "/payments", `{"amount":5000,"currency":"EUR"}`, "", 422, "over limit"},
+		{"POST", "/payments", `{"amount":5000,"currency":"EUR"}`, "gold", 201, "accepted"},
+		{"POST", "/payments", `{"amount":50}`, "", 400, "no currency"},
+		{"GET", "/payments?page=2", "", "", 200, "page two"},
+		{"GET", "/payments?page=3", "", "", 404, ""},
+	} {
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		if tc.tier != "" {
+			req.Header.Set("X-Tier", tc.tier)
+		}
+		w := httptest.NewRecorder()
+		engine.HandleRequest(w, req)
+		if w.Code != tc.status || (tc.response != "" && w.Body.String() != tc.response) {
+			t.Errorf("%s %s %s (tier %q): expected %d %q, got %d %q", tc.method, tc.path, tc.body, tc.tier, tc.status, tc.response, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestParseCondition(t *testing.T) {
+	for _, text := range []string{"body.amount", "body.amount ~ 3", "cookie.id == 1", "query == 1", "body.amount >", "header.X-Id exists 1", "path matches ["} {
+		if _, err := parseCondition(text); err == nil {
+			t.Errorf("Expected %q to be rejected", text)
+		}
+	}
+
+	c, err := parseCondition(`body.user.name == "Ada Lovelace"`)
+	if err != nil || c.value != "Ada Lovelace" || len(c.bodyPath) != 2 {
+		t.Errorf("Expected a quoted value with spaces, got %+v, %v", c, err)
+	}
+}
+
+func TestMockResponseOverrides(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "overrides"}, config.MockConfig{
+		MatchingStrategy: "exact",
+		Overrides: []config.ResponseOverride{
+			{SetHeaders: map[string]string{"x-served-by": "mimic"}, RemoveHeaders: []string{"set-cookie"}},
+			{
+				Path: "/token",
+				Set: []config.BodyValue{
+					{Path: "expires_at", Value: "{{ (now.AddDate 1 0 0).Format \"2006\" }}"},
+					{Path: "scopes[*].granted", Value: true},
+				},
+				Delete: []string{"debug.trace"},
+			},
+			{Path: "/token", Method: "POST", Status: 201},
+		},
+	}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	recorded := &storage.Interaction{SessionID: engine.session.ID, RequestID: "token", Protocol: "REST", Method: "GET", Endpoint: "/token",
+		RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{"Content-Type":"application/json","Set-Cookie":"id=1","Content-Length":"93"}`,
+		ResponseBody: []byte(`{"token":"abc","expires_at":"2020-01-01","scopes":[{"name":"read"},{"name":"write"}],"debug":{"trace":"x","id":12345678901234567890}}`)}
+	if err := db.RecordInteraction(recorded); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("GET", "/token", nil))
+	year := clock.Now().AddDate(1, 0, 0).Format("2006")
+	expected := `{"debug":{"id":12345678901234567890},"expires_at":"` + year + `","scopes":[{"granted":true,"name":"read"},{"granted":true,"name":"write"}],"token":"abc"}`
+	if w.Code != 200 || w.Body.String() != expected {
+		t.Errorf("Expected the overridden body %s, got %d %s", expected, w.Code, w.Body.String())
+	}
+	if w.Header().Get("X-Served-By") != "mimic" || w.Header().Get("Set-Cookie") != "" || w.Header().Get("Content-Length") != strconv.Itoa(len(expected)) {
+		t.Errorf("Expected the overridden headers, got %v", w.Header())
+	}
+
+	stored, err := db.GetInteraction(recorded.ID)
+	if err != nil || !strings.Contains(string(stored.ResponseBody), "2020-01-01") {
+		t.Errorf("Expected the recording itself to be left alone, got %s, %v", stored.ResponseBody, err)
+	}
+}
+
+func TestMockStreamOverrides(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "llm", Protocol: "http", SessionName: "streams"}, config.MockConfig{
+		MatchingStrategy: "exact",
+		IgnoreHeaders:    []string{"X-Run"},
+		Overrides: []config.ResponseOverride{{
+			Path:          "/v1/chat/completions",
+			StreamSet:     []config.BodyValue{{Path: "id", Value: "{{ .Headers.Get \"X-Run\" }}"}, {Path: "choices[*].index", Value: 7}},
+			StreamReplace: []config.ChunkReplace{{Pattern: `chatcmpl-(\w+)`, Replacement: "run-$1"}},
+		}},
+	}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "stream", Protocol: "REST", Method: "POST", Endpoint: "/v1/chat/completions",
+		RequestHeaders: `{}`, ResponseStatus: 200, ResponseHeaders: `{"Content-Type":"text/event-stream"}`, IsStreaming: true, SequenceNumber: 1}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+	chunks := []*storage.StreamChunk{
+		{InteractionID: interaction.ID, ChunkIndex: 0, Data: []byte("event: delta\ndata: {\"id\":\"abc\",\"object\":\"chatcmpl-xyz\",\"choices\":[{\"index\":0}]}\n\n")},
+		{InteractionID: interaction.ID, ChunkIndex: 1, Data: []byte("data: [DONE]\n\n")},
+	}
+	if err := db.RecordStreamChunks(chunks); err != nil {
+		t.Fatalf("Failed to record stream chunks: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest("POST", "/v1/chat/completions", nil)
+	r.Header.Set("X-Run", "42")
+	engine.HandleRequest(w, r)
+	expected := "event: delta\ndata: {\"choices\":[{\"index\":7}],\"id\":\"42\",\"object\":\"run-xyz\"}\n\ndata: [DONE]\n\n"
+	if w.Body.String() != expected {
+		t.Errorf("Expected the edited stream %q, got %q", expected, w.Body.String())
+	}
+
+	stored, err := db.GetStreamChunks(interaction.ID)
+	if err != nil || !strings.Contains(string(stored[0].Data), `"id":"abc"`) {
+		t.Errorf("Expected the recorded chunks to be left alone, got %v, %v", stored, err)
+	}
+}
+
+func TestMockTimeShift(t *testing.T) {
+	clock.Default.Set(time.Date(2030, 6, 1, 12, 0, 0, 0, time.UTC), true)
+	defer clock.Default.Reset()
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "auth", Protocol: "http", SessionName: "dated"}, config.MockConfig{
+		MatchingStrategy: "exact",
+		TimeShift:        config.TimeShiftConfig{EpochFields: []string{"exp"}},
+	}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	body := `{"issued_at":"2024-01-01T12:00:00Z","expires_at":"2024-01-01T13:30:00.500+02:00","exp":1704114000,"id":1704114000}`
+	for _, endpoint := range []string{"/shifted", "/recorded"} {
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: endpoint, Protocol: "REST", Method: "GET", Endpoint: endpoint,
+			RequestHeaders: `{}`, ResponseStatus: 200, ResponseBody: []byte(body),
+			ResponseHeaders: `{"Expires":"Mon, 01 Jan 2024 13:00:00 GMT","Set-Cookie":"sid=1; Expires=Mon, 01-Jan-2024 14:00:00 GMT; Path=/","Content-Length":"` + strconv.Itoa(len(body)) + `"}`}
+		if err := interaction.SetTiming(time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC), 0); err != nil {
+			t.Fatalf("Failed to set timing: %v", err)
+		}
+		if endpoint == "/shifted" {
+			if err := interaction.SetMetadataValue(storage.MetadataTimeShift, true); err != nil {
+				t.Fatalf("Failed to set metadata: %v", err)
+			}
+		}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("GET", "/shifted", nil))
+	expected := `{"issued_at":"2030-06-01T12:00:00Z","expires_at":"2030-06-01T13:30:00.500+02:00","exp":1906549200,"id":1704114000}`
+	if w.Body.String() != expected || w.Header().Get("Content-Length") != strconv.Itoa(len(expected)) {
+		t.Errorf("Expected the shifted body %s, got %s %v", expected, w.Body.String(), w.Header())
+	}
+	if w.Header().Get("Expires") != "Sat, 01 Jun 2030 13:00:00 GMT" || w.Header().Get("Set-Cookie") != "sid=1; Expires=Sat, 01 Jun 2030 14:00:00 GMT; Path=/" {
+		t.Errorf("Expected shifted header dates, got %v", w.Header())
+	}
+
+	// Without the metadata or mock.time_shift.enabled, dates are served as recorded
+	w = httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("GET", "/recorded", nil))
+	if w.Body.String() != body || w.Header().Get("Expires") != "Mon, 01 Jan 2024 13:00:00 GMT" {
+		t.Errorf("Expected the recorded dates, got %s %v", w.Body.String(), w.Header())
+	}
+}
+
+func TestMockServesConsumerSlice(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "inventory", Protocol: "http", SessionName: "shared"}, config.MockConfig{}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	for i, name := range []string{"checkout", "search"} {
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: name, Protocol: "REST", Method: "GET", Endpoint: "/stock",
+			RequestHeaders: `{"X-Mimic-Consumer":"` + name + `"}`, ResponseStatus: 200, ResponseHeaders: "{}", ResponseBody: []byte(name), SequenceNumber: i + 1}
+		interaction.SetMetadataValue(storage.MetadataConsumer, name)
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	tests := []struct {
+		consumer string
+		status   int
+		body     string
+	}{
+		{"search", http.StatusOK, "search"},
+		{"billing", http.StatusNotFound, ""}, // No fallback to other consumers' interactions
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/stock", nil)
+		req.Header.Set("X-Mimic-Consumer", tt.consumer)
+		recorder := httptest.NewRecorder()
+		engine.HandleRequest(recorder, req)
+		if recorder.Code != tt.status || (tt.body != "" && recorder.Body.String() != tt.body) {
+			t.Errorf("Expected %d %q for consumer %s, got %d %q", tt.status, tt.body, tt.consumer, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestMockServesTaggedScenario(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "shop", Protocol: "http", SessionName: "checkout"}, config.MockConfig{Tag: "checkout-happy-path"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	for i, scenario := range []string{"checkout-happy-path", "payment-declined"} {
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: scenario, Protocol: "REST", Method: "POST", Endpoint: "/orders",
+			RequestHeaders: "{}", ResponseStatus: 200, ResponseHeaders: "{}", ResponseBody: []byte(scenario), SequenceNumber: i + 1}
+		interaction.SetMetadataValue(storage.MetadataTags, []string{scenario})
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	tests := []struct {
+		tag    string
+		status int
+		body   string
+	}{
+		{"", http.StatusOK, "checkout-happy-path"}, // The configured tag
+		{"payment-declined", http.StatusOK, "payment-declined"},
+		{"refund", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "/orders", nil)
+		if tt.tag != "" {
+			req.Header.Set("X-Mimic-Tag", tt.tag)
+		}
+		recorder := httptest.NewRecorder()
+		engine.HandleRequest(recorder, req)
+		if recorder.Code != tt.status || (tt.body != "" && recorder.Body.String() != tt.body) {
+			t.Errorf("Expected %d %q for tag %q, got %d %q", tt.status, tt.body, tt.tag, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestMockServesBodyFiles(t *testing.T) {
+	db, err := storage.Open(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "mimic.db"), BodyFileThreshold: 8})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "files", Protocol: "http", SessionName: "uploads"}, config.MockConfig{}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	store := func(body string) string {
+		writer, err := db.BodyFiles().Create()
+		if err != nil {
+			t.Fatalf("Failed to create body file: %v", err)
+		}
+		writer.Write([]byte(body))
+		name, err := writer.Commit()
+		if err != nil {
+			t.Fatalf("Failed to commit body file: %v", err)
+		}
+		return name
+	}
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "r1", Protocol: "REST", Method: "PUT", Endpoint: "/files/report",
+		RequestHeaders: "{}", ResponseStatus: 200, ResponseHeaders: "{}"}
+	interaction.SetRequestBodyFile(store("uploaded report contents"), 24)
+	interaction.SetResponseBodyFile(store("stored report receipt"), 21)
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	for _, tt := range []struct {
+		body   string
+		status int
+	}{{"uploaded report contents", http.StatusOK}, {"a different report", http.StatusNotFound}} {
+		req, _ := http.NewRequest("PUT", "/files/report", bytes.NewBufferString(tt.body))
+		recorder := httptest.NewRecorder()
+		engine.HandleRequest(recorder, req)
+		if recorder.Code != tt.status {
+			t.Errorf("Expected %d for body %q, got %d", tt.status, tt.body, recorder.Code)
+		}
+		if tt.status == http.StatusOK && (recorder.Body.String() != "stored report receipt" || recorder.Header().Get("Content-Length") != "21") {
+			t.Errorf("Expected the response streamed from its body file, got %q", recorder.Body.String())
+		}
+	}
+}
+
+func TestMatchesQuery(t *testing.T) {
+	recorded := storage.Interaction{}
+	recorded.SetMetadataValue(storage.MetadataQuery, "page=2&sort=name&_=1700000000")
+	tests := []struct {
+		mode     string
+		query    string
+		expected bool
+	}{
+		{"", "page=1", true},
+		{QueryExact, "sort=name&page=2&_=1700000999", true},
+		{QueryExact, "page=1&sort=name", false},
+		{QueryExact, "page=2&sort=name&limit=10", false},
+		{QuerySubset, "page=2&sort=name&limit=10", true},
+		{QuerySubset, "page=2", false},
+	}
+	for _, tt := range tests {
+		engine := &MockEngine{mockConfig: &config.MockConfig{QueryMatching: tt.mode, IgnoreQueryParams: []string{"_"}}}
+		req, _ := http.NewRequest("GET", "/api/items?"+tt.query, nil)
+		if got := engine.matchesQuery(recorded, req); got != tt.expected {
+			t.Errorf("Expected %q to match=%v in mode %q, got %v", tt.query, tt.expected, tt.mode, got)
+		}
+	}
+}
+
+func TestMockMatchesQueryString(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "items", Protocol: "http", SessionName: "pages"}, config.MockConfig{QueryMatching: QueryExact}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	for _, page := range []string{"1", "2"} {
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "page" + page, Protocol: "REST", Method: "GET", Endpoint: "/api/items",
+			RequestHeaders: "{}", ResponseStatus: 200, ResponseHeaders: "{}", ResponseBody: []byte("page " + page)}
+		interaction.SetMetadataValue(storage.MetadataQuery, "page="+page)
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	for _, page := range []string{"2", "1", "2"} {
+		req, _ := http.NewRequest("GET", "/api/items?page="+page, nil)
+		recorder := httptest.NewRecorder()
+		engine.HandleRequest(recorder, req)
+		if recorder.Body.String() != "page "+page {
+			t.Errorf("Expected the recording for page %s, got %d %q", page, recorder.Code, recorder.Body.String())
+		}
+	}
+}
+
+func TestFuzzyIgnorePaths(t *testing.T) {
+	mockEngine := &MockEngine{mockConfig: &config.MockConfig{
+		MatchingStrategy:  "fuzzy",
+		FuzzyIgnoreFields: []string{"contents[*].parts[*].functionResponse.response.result", "$.meta.*.at", "items[0]"},
+	}}
+
+	recorded := []byte(`{"contents":[{"parts":[{"functionResponse":{"name":"weather","response":{"result":"sunny"}}}]}],"result":"ok","meta":{"a":{"at":1},"b":{"at":2}},"items":[1,2]}`)
+	tests := []struct {
+		name     string
+		current  string
+		expected bool
+	}{
+		{"ignored path differs", `{"contents":[{"parts":[{"functionResponse":{"name":"weather","response":{"result":"rain"}}}]}],"result":"ok","meta":{"a":{"at":5},"b":{"at":6}},"items":[9,2]}`, true},
+		{"same name elsewhere differs", `{"contents":[{"parts":[{"functionResponse":{"name":"weather","response":{"result":"sunny"}}}]}],"result":"error","meta":{"a":{"at":1},"b":{"at":2}},"items":[1,2]}`, false},
+		{"sibling differs", `{"contents":[{"parts":[{"functionResponse":{"name":"time","response":{"result":"sunny"}}}]}],"result":"ok","meta":{"a":{"at":1},"b":{"at":2}},"items":[1,2]}`, false},
+		{"other element differs", `{"contents":[{"parts":[{"functionResponse":{"name":"weather","response":{"result":"sunny"}}}]}],"result":"ok","meta":{"a":{"at":1},"b":{"at":2}},"items":[1,3]}`, false},
+	}
+	for _, tt := range tests {
+		if got := mockEngine.fuzzyMatchBody(recorded, []byte(tt.current)); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+
+	if _, ok := parseIgnorePath("result"); ok {
+		t.Error("Expected a plain field name not to be parsed as a path")
+	}
+}
+
+func TestFuzzySubsetMatching(t *testing.T) {
+	recorded := []byte(`{"model":"gpt","messages":[{"role":"user","content":"hi"}]}`)
+	tests := []struct {
+		name     string
+		strategy string
+		current  string
+		expected bool
+	}{
+		{"extra top-level field", "fuzzy-subset", `{"model":"gpt","messages":[{"role":"user","content":"hi"}],"temperature":0.2}`, true},
+		{"extra nested field", "fuzzy-subset", `{"model":"gpt","messages":[{"role":"user","content":"hi","name":"bob"}]}`, true},
+		{"missing field", "fuzzy-subset", `{"messages":[{"role":"user","content":"hi"}],"temperature":0.2}`, false},
+		{"different value", "fuzzy-subset", `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`, false},
+		{"extra array element", "fuzzy-subset", `{"model":"gpt","messages":[{"role":"user","content":"hi"},{"role":"user","content":"again"}]}`, false},
+		{"extra field without subset", "fuzzy", `{"model":"gpt","messages":[{"role":"user","content":"hi"}],"temperature":0.2}`, false},
+	}
+	for _, tt := range tests {
+		mockEngine := &MockEngine{mockConfig: &config.MockConfig{MatchingStrategy: tt.strategy}}
+		if got := mockEngine.matchesBody(recorded, createMockRequest([]byte(tt.current))); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestFuzzyNumericTolerance(t *testing.T) {
+	recorded := []byte(`{"temperature":0.7,"max_tokens":100,"score":1000}`)
+	tests := []struct {
+		name               string
+		absolute, relative float64
+		current            string
+		expected           bool
+	}{
+		{"integer and float", 0, 0, `{"temperature":0.7,"max_tokens":100.0,"score":1e3}`, true},
+		{"drift without tolerance", 0, 0, `{"temperature":0.70000001,"max_tokens":100,"score":1000}`, false},
+		{"drift within absolute", 0.001, 0, `{"temperature":0.7005,"max_tokens":100,"score":1000}`, true},
+		{"drift beyond absolute", 0.001, 0, `{"temperature":0.7,"max_tokens":100,"score":1005}`, false},
+		{"drift within relative", 0, 0.01, `{"temperature":0.7,"max_tokens":100,"score":1005}`, true},
+		{"number and string", 0.001, 0.01, `{"temperature":"0.7","max_tokens":100,"score":1000}`, false},
+	}
+	for _, tt := range tests {
+		mockEngine := &MockEngine{mockConfig: &config.MockConfig{MatchingStrategy: "fuzzy", NumericTolerance: tt.absolute, NumericRelativeTolerance: tt.relative}}
+		if got := mockEngine.fuzzyMatchBody(recorded, []byte(tt.current)); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestFormBodyMatching(t *testing.T) {
+	form := func(contentType, body string) *http.Request {
+		req := createMockRequest([]byte(body))
+		req.Header.Set("Content-Type", contentType)
+		return req
+	}
+	tests := []struct {
+		name     string
+		strategy string
+		ignore   []string
+		recorded string
+		request  *http.Request
+		expected bool
+	}{
+		{"fields in another order", "exact", nil, "a=1&b=2&b=3", form("application/x-www-form-urlencoded", "b=2&b=3&a=1"), true},
+		{"repeated values in another order", "exact", nil, "a=1&b=2&b=3", form("application/x-www-form-urlencoded", "a=1&b=3&b=2"), false},
+		{"ignored field differs", "fuzzy", []string{"nonce"}, "a=1&nonce=x", form("application/x-www-form-urlencoded", "nonce=y&a=1"), true},
+		{"ignored field differs without fuzzy", "exact", []string{"nonce"}, "a=1&nonce=x", form("application/x-www-form-urlencoded", "nonce=y&a=1"), false},
+		{"other boundary", "exact", nil, multipartBody(t, "recorded-boundary", "hello"), form("multipart/form-data; boundary=live-boundary", multipartBody(t, "live-boundary", "hello")), true},
+		{"other file content", "exact", nil, multipartBody(t, "recorded-boundary", "hello"), form("multipart/form-data; boundary=live-boundary", multipartBody(t, "live-boundary", "bye")), false},
+		{"ignored part differs", "fuzzy", []string{"file"}, multipartBody(t, "recorded-boundary", "hello"), form("multipart/form-data; boundary=live-boundary", multipartBody(t, "live-boundary", "bye")), true},
+	}
+	for _, tt := range tests {
+		mockEngine := &MockEngine{mockConfig: &config.MockConfig{MatchingStrategy: tt.strategy, FuzzyIgnoreFields: tt.ignore}}
+		if got := mockEngine.matchesBody([]byte(tt.recorded), tt.request); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+
+	headers, _ := json.Marshal(map[string]string{"Content-Type": "multipart/form-data; boundary=recorded-boundary", "Content-Length": "300"})
+	mockEngine := &MockEngine{mockConfig: &config.MockConfig{MatchingStrategy: "exact"}, restHandler: proxy.NewRESTHandler([]string{})}
+	if !mockEngine.matchesHeaders(string(headers), http.Header{"Content-Type": {"multipart/form-data; boundary=live-boundary"}, "Content-Length": {"290"}}) {
+		t.Error("Expected multipart headers to match regardless of the boundary")
+	}
+}
+
+// multipartBody builds a form with a text field and a file
+func multipartBody(t *testing.T, boundary, content string) string {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.SetBoundary(boundary); err != nil {
+		t.Fatal(err)
+	}
+	writer.WriteField("title", "greeting")
+	file, _ := writer.CreateFormFile("file", "greeting.txt")
+	file.Write([]byte(content))
+	writer.Close()
+	return body.String()
+}
+
+func TestFuzzyMatchXML(t *testing.T) {
+	recorded := `<?xml version="1.0"?>
+<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:orders">
+  <soap:Header><m:MessageID>a1</m:MessageID></soap:Header>
+  <soap:Body>
+    <m:GetOrder id="7"><m:Item>book</m:Item><m:Item>pen</m:Item><m:MessageID>keep</m:MessageID></m:GetOrder>
+  </soap:Body>
+</soap:Envelope>`
+	tests := []struct {
+		name     string
+		strategy string
+		current  string
+		expected bool
+	}{
+		{"other prefixes and whitespace", "fuzzy", `<env:Envelope xmlns:env="http://schemas.xmlsoap.org/soap/envelope/"><env:Header><MessageID xmlns="urn:orders">b2</MessageID></env:Header><env:Body><o:GetOrder xmlns:o="urn:orders" id="7"><o:Item>book</o:Item><o:Item>pen</o:Item><o:MessageID>keep</o:MessageID></o:GetOrder></env:Body></env:Envelope>`, true},
+		{"same name outside the ignored path", "fuzzy", `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:orders"><soap:Header><m:MessageID>a1</m:MessageID></soap:Header><soap:Body><m:GetOrder id="7"><m:Item>book</m:Item><m:Item>pen</m:Item><m:MessageID>changed</m:MessageID></m:GetOrder></soap:Body></soap:Envelope>`, false},
+		{"attribute differs", "fuzzy", `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:orders"><soap:Header><m:MessageID>a1</m:MessageID></soap:Header><soap:Body><m:GetOrder id="8"><m:Item>book</m:Item><m:Item>pen</m:Item><m:MessageID>keep</m:MessageID></m:GetOrder></soap:Body></soap:Envelope>`, false},
+		{"elements reordered", "fuzzy", `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:orders"><soap:Header><m:MessageID>a1</m:MessageID></soap:Header><soap:Body><m:GetOrder id="7"><m:Item>pen</m:Item><m:Item>book</m:Item><m:MessageID>keep</m:MessageID></m:GetOrder></soap:Body></soap:Envelope>`, false},
+		{"elements reordered, unordered", "fuzzy-unordered", `<soap:Envelope xmlns:soap="http://schemas.xmlsoap.org/soap/envelope/" xmlns:m="urn:orders"><soap:Header><m:MessageID>a1</m:MessageID></soap:Header><soap:Body><m:GetOrder id="7"><m:Item>pen</m:Item><m:Item>book</m:Item><m:MessageID>keep</m:MessageID></m:GetOrder></soap:Body></soap:Envelope>`, true},
+		{"not XML", "fuzzy", `<soap:Envelope`, false},
+	}
+	for _, tt := range tests {
+		mockEngine := &MockEngine{mockConfig: &config.MockConfig{MatchingStrategy: tt.strategy, FuzzyIgnoreFields: []string{"Envelope.Header.MessageID"}}}
+		req := createMockRequest([]byte(tt.current))
+		req.Header.Set("Content-Type", "text/xml; charset=utf-8")
+		if got := mockEngine.matchesBody([]byte(recorded), req); got != tt.expected {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestMockMatchesCompressedBodies(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "gzip"}, config.MockConfig{MatchingStrategy: "exact"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	recordedRequest, _ := storage.Compress([]byte(`{"q":"search"}`), storage.EncodingGzip)
+	recordedResponse, _ := storage.Compress([]byte(`{"results":[]}`), storage.EncodingGzip)
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "r1", Protocol: "REST", Method: "POST", Endpoint: "/search",
+		RequestHeaders: `{"Content-Encoding":"gzip","Content-Length":"40"}`, RequestBody: recordedRequest,
+		ResponseStatus: 200, ResponseHeaders: `{"Content-Encoding":"gzip","Content-Length":"38"}`, ResponseBody: recordedResponse}
+	if err := interaction.DecompressBodies(); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	// The client compresses the same body differently this time
+	var live bytes.Buffer
+	writer, _ := gzip.NewWriterLevel(&live, gzip.BestCompression)
+	writer.Write([]byte(`{"q":"search"}`))
+	writer.Close()
+	req, _ := http.NewRequest("POST", "/search", bytes.NewReader(live.Bytes()))
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Content-Length", strconv.Itoa(live.Len()))
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Expected the compressed request to match, got %d %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder.Header().Get("Content-Length") != strconv.Itoa(recorder.Body.Len()) {
+		t.Errorf("Expected Content-Length %d, got %s", recorder.Body.Len(), recorder.Header().Get("Content-Length"))
+	}
+	body, err := storage.Decompress(recorder.Body.Bytes(), "gzip")
+	if err != nil || string(body) != `{"results":[]}` {
+		t.Errorf("Expected the response compressed again, got %q (%v)", body, err)
+	}
+}
+
+func TestMockMatchesRedactedRecordings(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "redacted"}, config.MockConfig{MatchingStrategy: "exact"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	redactor, err := redact.New(nil, []string{redact.PresetAuthorization, redact.PresetEmail})
+	if err != nil {
+		t.Fatalf("Failed to build redactor: %v", err)
+	}
+	engine.SetRedactor(redactor)
+
+	// Recorded with the secrets masked
+	if err := db.RecordInteraction(&storage.Interaction{SessionID: engine.session.ID, RequestID: "r1", Protocol: "REST", Method: "POST", Endpoint: "/invite",
+		RequestHeaders: `{"Authorization":"[REDACTED]"}`, RequestBody: []byte(`{"email":"[REDACTED]"}`),
+		ResponseStatus: 201, ResponseHeaders: `{}`, ResponseBody: []byte(`{"invited":true}`)}); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	// Live requests carry other secrets, which are masked the same way before comparing
+	req, _ := http.NewRequest("POST", "/invite", strings.NewReader(`{"email":"grace@example.com"}`))
+	req.Header.Set("Authorization", "Bearer another-token")
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, req)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("Expected the redacted recording to match, got %d %s", recorder.Code, recorder.Body.String())
+	}
+
+	// Anything outside the secrets still has to match
+	req, _ = http.NewRequest("POST", "/invite", strings.NewReader(`{"email":"grace@example.com","admin":true}`))
+	req.Header.Set("Authorization", "Bearer another-token")
+	recorder = httptest.NewRecorder()
+	engine.HandleRequest(recorder, req)
+	if recorder.Code == http.StatusCreated {
+		t.Error("Expected a request with a different body not to match")
+	}
+}
+
+func TestNearMissDiagnostics(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	t.Cleanup(nearmiss.Default.Reset)
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "misses"},
+		config.MockConfig{MatchingStrategy: "fuzzy", QueryMatching: "exact", Debug: true}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	if err := db.RecordInteraction(&storage.Interaction{SessionID: engine.session.ID, RequestID: "r1", Protocol: "REST", Method: "POST", Endpoint: "/users",
+		RequestHeaders: `{"Content-Type":"application/json","X-Tenant":"acme"}`, RequestBody: []byte(`{"user":{"id":"a","roles":["admin"]},"notify":true}`),
+		ResponseStatus: 201, ResponseHeaders: `{}`, ResponseBody: []byte(`{}`), Metadata: `{"query":"dry_run=1"}`}); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/users?dry_run=0", strings.NewReader(`{"user":{"id":"b","roles":["admin"]},"extra":1,"notify":true}`))
+	req.Header.Set("Content-Type", "application/json")
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, req)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("Expected a miss, got %d", recorder.Code)
+	}
+
+	var body struct {
+		Reason     string               `json:"reason"`
+		NearMisses []nearmiss.Candidate `json:"near_misses"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to decode the 404 body: %v", err)
+	}
+	if len(body.NearMisses) != 1 || body.NearMisses[0].RequestID != "r1" {
+		t.Fatalf("Expected the recording as the near miss, got %+v", body)
+	}
+	var fields []string
+	for _, difference := range body.NearMisses[0].Differences {
+		fields = append(fields, difference.Part+" "+difference.Field)
+	}
+	expected := []string{"query dry_run", "header X-Tenant", "body $.extra", "body $.user.id"}
+	if !reflect.DeepEqual(fields, expected) {
+		t.Errorf("Expected differences in %v, got %v", expected, fields)
+	}
+
+	// A path nothing was recorded for gets the nearest recorded endpoints
+	recorder = httptest.NewRecorder()
+	engine.HandleRequest(recorder, httptest.NewRequest("PUT", "/users", nil))
+	misses := nearmiss.Default.Misses("api", "misses")
+	if len(misses) != 2 {
+		t.Fatalf("Expected both misses kept, got %d", len(misses))
+	}
+	if candidates := misses[1].Candidates; len(candidates) != 1 || len(candidates[0].Differences) != 1 || candidates[0].Differences[0].Part != nearmiss.PartMethod {
+		t.Errorf("Expected the POST recording with a method difference, got %+v", candidates)
+	}
+}
+
+func TestScenarioStates(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "items"}, config.MockConfig{MatchingStrategy: "exact"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	recordings := []struct {
+		method, requiredState, newState, body string
+		status                                int
+	}{
+		{"POST", "", "created", `{"id":1}`, 201},
+		{"GET", "created", "", `{"id":1}`, 200},
+		{"DELETE", "created", "deleted", ``, 204},
+		{"GET", "deleted", "", `{"error":"gone"}`, 404},
+	}
+	for i, recording := range recordings {
+		endpoint := "/items/1"
+		if recording.method == "POST" {
+			endpoint = "/items"
+		}
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: strconv.Itoa(i), Protocol: "REST", Method: recording.method, Endpoint: endpoint,
+			RequestHeaders: `{}`, ResponseStatus: recording.status, ResponseHeaders: `{}`, ResponseBody: []byte(recording.body), SequenceNumber: i + 1}
+		interaction.SetMetadataValue(storage.MetadataScenario, "items")
+		if recording.requiredState != "" {
+			interaction.SetMetadataValue(storage.MetadataRequiredState, recording.requiredState)
+		}
+		if recording.newState != "" {
+			interaction.SetMetadataValue(storage.MetadataNewState, recording.newState)
+		}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	serve := func(method, path string) (int, string) {
+		recorder := httptest.NewRecorder()
+		engine.HandleRequest(recorder, httptest.NewRequest(method, path, nil))
+		return recorder.Code, strings.TrimSpace(recorder.Body.String())
+	}
+	steps := []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/items/1", 404, `{"error":"Recording not found"}`},
+		{"POST", "/items", 201, `{"id":1}`},
+		{"GET", "/items/1", 200, `{"id":1}`},
+		{"GET", "/items/1", 200, `{"id":1}`},
+		{"DELETE", "/items/1", 204, ``},
+		{"GET", "/items/1", 404, `{"error":"gone"}`},
+	}
+	for i, step := range steps {
+		if status, body := serve(step.method, step.path); status != step.status || body != step.body {
+			t.Fatalf("Step %d, %s %s: expected %d %s, got %d %s", i, step.method, step.path, step.status, step.body, status, body)
+		}
+	}
+	if states := engine.ScenarioStates(); !reflect.DeepEqual(states, map[string]string{"items": "deleted"}) {
+		t.Errorf("Expected the items scenario to be deleted, got %v", states)
+	}
+
+	if reset := engine.ResetScenarios(""); reset != 1 {
+		t.Errorf("Expected one scenario reset, got %d", reset)
+	}
+	if states := engine.ScenarioStates(); states["items"] != ScenarioStarted {
+		t.Errorf("Expected the items scenario back in %s, got %v", ScenarioStarted, states)
+	}
+	engine.SetScenarioState("items", "created")
+	if status, _ := serve("GET", "/items/1"); status != 200 {
+		t.Errorf("Expected the recording for the created state after setting it, got %d", status)
+	}
+}
+
+func TestResolveRule(t *testing.T) {
+	rules, err := compileMatchRules(config.MockConfig{MatchingStrategy: "exact", Rules: []config.MatchRule{
+		{PathRegex: "/v[12]/.*", MatchingStrategy: "fuzzy"},
+		{Path: "/v1/**", MatchingStrategy: "fuzzy"},
+		{Path: "/v1/chat/*", MatchingStrategy: "fuzzy"},
+		{Path: "/v1/chat/*", Method: "post", MatchingStrategy: "fuzzy-unordered"},
+		{Path: "/v1/chat/completions", FuzzyIgnoreFields: []string{"user"}},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to compile rules: %v", err)
+	}
+	engine := &MockEngine{rules: rules}
+
+	tests := []struct {
+		method, path string
+		expected     int // Index of the rule, -1 for none
+	}{
+		{"GET", "/v1/chat/completions", 4},
+		{"GET", "/v1/chat/stream", 2},
+		{"POST", "/v1/chat/stream", 3},
+		{"GET", "/v1/models/gpt/versions", 1},
+		{"GET", "/v2/models", 0},
+		{"GET", "/v3/models", -1},
+	}
+	for _, tt := range tests {
+		rule := engine.resolveRule(tt.method, tt.path)
+		if (tt.expected < 0 && rule != nil) || (tt.expected >= 0 && rule != rules[tt.expected]) {
+			t.Errorf("%s %s: expected rule %d, got %+v", tt.method, tt.path, tt.expected, rule)
+		}
+	}
+	if settings := rules[4].settings; settings.MatchingStrategy != "exact" || len(settings.FuzzyIgnoreFields) != 1 {
+		t.Errorf("Expected the rule to inherit the strategy and set ignored fields, got %+v", settings)
+	}
+}
+
+func TestMockMatchesWithEndpointRules(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	mockConfig := config.MockConfig{MatchingStrategy: "exact", Rules: []config.MatchRule{
+		{Path: "/v1/chat/completions", MatchingStrategy: "fuzzy", FuzzyIgnoreFields: []string{"user"}},
+	}}
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "llm", Protocol: "http", SessionName: "chat"}, mockConfig, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	for _, endpoint := range []string{"/v1/chat/completions", "/v1/embeddings"} {
+		interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: endpoint, Protocol: "REST", Method: "POST", Endpoint: endpoint,
+			RequestHeaders: "{}", RequestBody: []byte(`{"input":"hi","user":"alice"}`), ResponseStatus: 200, ResponseHeaders: "{}", ResponseBody: []byte("ok")}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	tests := []struct {
+		endpoint string
+		expected int
+	}{
+		{"/v1/chat/completions", http.StatusOK},
+		{"/v1/embeddings", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", tt.endpoint, strings.NewReader(`{"user": "bob", "input": "hi"}`))
+		recorder := httptest.NewRecorder()
+		engine.HandleRequest(recorder, req)
+		if recorder.Code != tt.expected {
+			t.Errorf("%s: expected %d, got %d", tt.endpoint, tt.expected, recorder.Code)
+		}
+	}
+}
+
+func TestMockTruncatedBodies(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "api", Protocol: "http", SessionName: "truncated"}, config.MockConfig{MatchingStrategy: "exact", SequenceMode: "repeat-last"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "1", Protocol: "REST", Method: "POST", Endpoint: "/upload",
+		RequestHeaders: `{}`, RequestBody: []byte("HEAD"), ResponseStatus: 200, ResponseHeaders: `{"Content-Length":"1000","Content-Type":"application/octet-stream"}`,
+		ResponseBody: []byte("0123"), SequenceNumber: 1}
+	for key, value := range map[string]interface{}{storage.MetadataRequestTruncated: true, storage.MetadataRequestFullSize: 16,
+		storage.MetadataResponseTruncated: true, storage.MetadataResponseFullSize: 1000} {
+		if err := interaction.SetMetadataValue(key, value); err != nil {
+			t.Fatalf("Failed to set metadata: %v", err)
+		}
+	}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("POST", "/upload", strings.NewReader("HEADER and more")))
+	if w.Code != http.StatusOK || w.Body.String() != "0123" {
+		t.Fatalf("Expected a body starting with the recorded prefix to match, got %d %q", w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Length") != "4" || w.Header().Get(TruncatedHeader) != "true" {
+		t.Errorf("Expected the truncated body served with its recorded length and marked, got Content-Length %q, %s %q",
+			w.Header().Get("Content-Length"), TruncatedHeader, w.Header().Get(TruncatedHeader))
+	}
+
+	w = httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("POST", "/upload", strings.NewReader("HEX")))
+	if w.Code == http.StatusOK {
+		t.Errorf("Expected a body not starting with the recorded prefix not to match, got %d %q", w.Code, w.Body.String())
+	}
+
+	// With the whole body's hash recorded, only the same body matches
+	sum := sha256.Sum256([]byte("HEADER and more"))
+	if err := db.UpdateInteractionMetadata(interaction.ID, map[string]interface{}{storage.MetadataRequestSHA256: hex.EncodeToString(sum[:])}); err != nil {
+		t.Fatalf("Failed to update metadata: %v", err)
+	}
+	w = httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("POST", "/upload", strings.NewReader("HEADER and less")))
+	if w.Code == http.StatusOK {
+		t.Errorf("Expected a different body with the recorded prefix not to match its hash, got %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("POST", "/upload", strings.NewReader("HEADER and more")))
+	if w.Code != http.StatusOK {
+		t.Errorf("Expected the recorded body to match its hash, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestMockWebSocketPlayback(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewMockEngine(config.ProxyConfig{Name: "chat", Protocol: "http", SessionName: "sockets"}, config.MockConfig{MatchingStrategy: "exact", RespectStreamingTiming: true}, db)
+	if err != nil {
+		t.Fatalf("Failed to create mock engine: %v", err)
+	}
+	interaction := &storage.Interaction{SessionID: engine.session.ID, RequestID: "1", Protocol: "REST", Method: "GET", Endpoint: "/chat",
+		RequestHeaders:  `{"Connection":"Upgrade","Upgrade":"websocket","Sec-Websocket-Version":"13","Sec-Websocket-Key":"cmVjb3JkZWQ=","Sec-Websocket-Protocol":"chat.v1","User-Agent":"Go-http-client/1.1"}`,
+		ResponseStatus:  http.StatusSwitchingProtocols,
+		ResponseHeaders: `{"Connection":"Upgrade","Upgrade":"websocket","Sec-Websocket-Accept":"recorded","Sec-Websocket-Protocol":"chat.v1","X-Room":"lobby"}`,
+		Metadata:        `{"websocket":true}`, SequenceNumber: 1}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+	frames := []*storage.WebSocketFrame{
+		{Direction: storage.FrameFromServer, MessageType: websocket.TextMessage, Data: []byte("welcome")},
+		{Direction: storage.FrameFromClient, MessageType: websocket.TextMessage, Data: []byte("hello")},
+		{Direction: storage.FrameFromServer, MessageType: websocket.TextMessage, Data: []byte("hi there"), TimeDelta: 100},
+		{Direction: storage.FrameFromServer, MessageType: websocket.CloseMessage, Data: websocket.FormatCloseMessage(websocket.CloseNormalClosure, "bye")},
+	}
+	for i, frame := range frames {
+		frame.InteractionID, frame.FrameIndex = interaction.ID, i
+	}
+	if err := db.RecordWebSocketFrames(frames); err != nil {
+		t.Fatalf("Failed to record frames: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(engine.HandleRequest))
+	defer server.Close()
+	dialer := websocket.Dialer{Subprotocols: []string{"chat.v1"}}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/chat", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial mock: %v", err)
+	}
+	defer conn.Close()
+	if conn.Subprotocol() != "chat.v1" || resp.Header.Get("X-Room") != "lobby" {
+		t.Errorf("Expected the recorded handshake, got subprotocol %q and X-Room %q", conn.Subprotocol(), resp.Header.Get("X-Room"))
+	}
+
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "welcome" {
+		t.Fatalf("Expected the greeting first, got %q, %v", data, err)
+	}
+	sent := time.Now()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("anything")); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "hi there" {
+		t.Fatalf("Expected the reply after the client's message, got %q, %v", data, err)
+	}
+	if elapsed := time.Since(sent); elapsed < 100*time.Millisecond {
+		t.Errorf("Expected the reply to keep its recorded gap, came after %v", elapsed)
+	}
+	_, _, err = conn.ReadMessage()
+	if !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("Expected the recorded close, got %v", err)
+	}
+}
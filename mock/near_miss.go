@@ -0,0 +1,435 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+
+	"mimic/nearmiss"
+	"mimic/storage"
+	"mimic/tag"
+)
+
+// maxExcerptBytes is how much of a value a difference's message quotes
+const maxExcerptBytes = 200
+
+// reportMiss logs a request no recording answers along with the closest candidates, and keeps it
+// for /api/match-misses
+func (m *MockEngine) reportMiss(r *http.Request, reason string, candidates []nearmiss.Candidate) *nearmiss.Miss {
+	if candidates == nil {
+		candidates = []nearmiss.Candidate{}
+	}
+	miss := &nearmiss.Miss{
+		Proxy:      m.proxyConfig.Name,
+		Session:    m.session.SessionName,
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		Query:      r.URL.RawQuery,
+		Reason:     reason,
+		Candidates: candidates,
+	}
+	nearmiss.Record(miss)
+	log.Printf("Mock miss for %s", miss.Summary())
+	return miss
+}
+
+// closestRecordings diffs a request against recordings of its endpoint, returning those with the
+// fewest differences, closest first
+func (m *MockEngine) closestRecordings(r *http.Request, interactions []storage.Interaction) []nearmiss.Candidate {
+	matcher := m.forRequest(r)
+	var currentBody []byte
+	if r.Body != nil {
+		currentBody, _ = io.ReadAll(r.Body)
+		r.Body = io.NopCloser(bytes.NewBuffer(currentBody))
+	}
+	currentBody = m.restHandler.Redactor().Bytes(plainBody(currentBody, r.Header))
+
+	candidates := make([]nearmiss.Candidate, 0, len(interactions))
+	for _, interaction := range interactions {
+		candidate := newCandidate(interaction)
+		candidate.Differences = matcher.requestDifferences(interaction, r, currentBody)
+		candidates = append(candidates, candidate)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return len(candidates[i].Differences) < len(candidates[j].Differences)
+	})
+	return candidates[:min(len(candidates), nearmiss.MaxCandidates)]
+}
+
+// closestEndpoints finds the recorded endpoints nearest to a request none was recorded for: the
+// same path with another method, or paths that differ in the fewest segments
+func (m *MockEngine) closestEndpoints(r *http.Request) []nearmiss.Candidate {
+	interactions, err := m.database.GetInteractionsBySession(m.session.ID)
+	if err != nil {
+		log.Printf("Error listing interactions for near misses: %v", err)
+		return nil
+	}
+
+	type scored struct {
+		candidate nearmiss.Candidate
+		distance  int
+	}
+	seen := make(map[string]bool)
+	var endpoints []scored
+	for _, interaction := range interactions {
+		key := interaction.Method + " " + interaction.Endpoint
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		candidate := newCandidate(interaction)
+		distance := pathDistance(interaction.Endpoint, r.URL.Path)
+		if !strings.EqualFold(interaction.Method, r.Method) {
+			distance++
+			candidate.Differences = append(candidate.Differences, nearmiss.Difference{
+				Part: nearmiss.PartMethod, Recorded: interaction.Method, Actual: r.Method,
+				Message: fmt.Sprintf("recorded %s, got %s", interaction.Method, r.Method),
+			})
+		}
+		if interaction.Endpoint != r.URL.Path {
+			candidate.Differences = append(candidate.Differences, nearmiss.Difference{
+				Part: nearmiss.PartPath, Recorded: interaction.Endpoint, Actual: r.URL.Path,
+				Message: fmt.Sprintf("recorded %s, got %s", interaction.Endpoint, r.URL.Path),
+			})
+		}
+		endpoints = append(endpoints, scored{candidate, distance})
+	}
+	sort.SliceStable(endpoints, func(i, j int) bool {
+		return endpoints[i].distance < endpoints[j].distance
+	})
+
+	candidates := make([]nearmiss.Candidate, 0, nearmiss.MaxCandidates)
+	for _, endpoint := range endpoints[:min(len(endpoints), nearmiss.MaxCandidates)] {
+		candidates = append(candidates, endpoint.candidate)
+	}
+	return candidates
+}
+
+func newCandidate(interaction storage.Interaction) nearmiss.Candidate {
+	return nearmiss.Candidate{
+		InteractionID:  interaction.ID,
+		RequestID:      interaction.RequestID,
+		Method:         interaction.Method,
+		Endpoint:       interaction.Endpoint,
+		SequenceNumber: interaction.SequenceNumber,
+		Differences:    []nearmiss.Difference{},
+	}
+}
+
+// pathDistance counts the segments two paths differ in
+func pathDistance(recorded, current string) int {
+	recordedSegments := strings.Split(strings.Trim(recorded, "/"), "/")
+	currentSegments := strings.Split(strings.Trim(current, "/"), "/")
+	distance := len(recordedSegments) - len(currentSegments)
+	if distance < 0 {
+		distance = -distance
+	}
+	for i := 0; i < min(len(recordedSegments), len(currentSegments)); i++ {
+		if recordedSegments[i] != currentSegments[i] {
+			distance++
+		}
+	}
+	return distance
+}
+
+// requestDifferences lists why a recording of the request's endpoint does not match it, in the
+// terms matchesRequestContent compares them
+func (m *MockEngine) requestDifferences(interaction storage.Interaction, r *http.Request, currentBody []byte) []nearmiss.Difference {
+	differences := []nearmiss.Difference{}
+	if consumerName := m.requestedConsumer(r); consumerName != "" && interaction.Consumer() != consumerName {
+		differences = append(differences, nearmiss.Difference{
+			Part: nearmiss.PartHeader, Field: m.consumerHeader(), Recorded: interaction.Consumer(), Actual: consumerName,
+			Message: fmt.Sprintf("recorded for consumer %q, requested by %q", interaction.Consumer(), consumerName),
+		})
+	}
+	if tagName := m.requestedTag(r); tagName != "" && !interaction.HasTag(tagName) {
+		recorded := strings.Join(interaction.Tags(), ",")
+		differences = append(differences, nearmiss.Difference{
+			Part: nearmiss.PartHeader, Field: tag.Header, Recorded: recorded, Actual: tagName,
+			Message: fmt.Sprintf("recorded with tags %q, requested %q", recorded, tagName),
+		})
+	}
+
+	if state := interaction.RequiredState(); state != "" && m.scenarios != nil {
+		if current := m.scenarios.state(interaction.Scenario()); current != state {
+			differences = append(differences, nearmiss.Difference{
+				Part: nearmiss.PartState, Field: interaction.Scenario(), Recorded: state, Actual: current,
+				Message: fmt.Sprintf("served in state %q, scenario is in %q", state, current),
+			})
+		}
+	}
+
+	if mode := m.queryMatching(); mode != QueryIgnore && !m.matchesQuery(interaction, r) {
+		differences = append(differences, valuesDifferences(nearmiss.PartQuery,
+			m.queryParams(interaction.Query()), m.queryParams(r.URL.RawQuery), mode == QuerySubset, sameValues)...)
+	}
+
+	if !m.matchesHeaders(interaction.RequestHeaders, r.Header) {
+		differences = append(differences, m.headerDifferences(interaction.RequestHeaders, r.Header)...)
+	}
+
+	if interaction.RequestTruncated() {
+		if !matchesTruncatedBody(interaction, r) {
+			message := "does not begin with the truncated recorded body"
+			if interaction.RequestSHA256() != "" {
+				message = "differs from the truncated recorded body, compared by SHA-256"
+			}
+			differences = append(differences, nearmiss.Difference{Part: nearmiss.PartBody, Message: message})
+		}
+	} else if name := interaction.RequestBodyFile(); name != "" {
+		if !matchesBodyFile(name, r) {
+			differences = append(differences, nearmiss.Difference{
+				Part: nearmiss.PartBody, Message: fmt.Sprintf("differs from the recorded body file %s", name),
+			})
+		}
+	} else if recordedBody := interaction.PlainRequestBody(); !m.matchesBody(recordedBody, r) {
+		differences = append(differences, m.bodyDifferences(m.restHandler.Redactor().Bytes(recordedBody), currentBody, r.Header.Get("Content-Type"))...)
+	}
+	return differences
+}
+
+// headerDifferences lists the compared headers that differ, redacted as they are for matching
+func (m *MockEngine) headerDifferences(recordedHeaders string, requestHeaders http.Header) []nearmiss.Difference {
+	recorded, current, err := m.comparedHeaders(recordedHeaders, requestHeaders)
+	if err != nil {
+		return []nearmiss.Difference{{Part: nearmiss.PartHeader, Message: fmt.Sprintf("recorded headers are unreadable: %v", err)}}
+	}
+	recorded, current = m.redactHeaders(recorded), m.redactHeaders(current)
+
+	var differences []nearmiss.Difference
+	for _, name := range unionKeys(recorded, current) {
+		recordedValue, inRecorded := recorded[name]
+		currentValue, inCurrent := current[name]
+		if inRecorded && inCurrent && recordedValue == currentValue {
+			continue
+		}
+		differences = append(differences, valueDifference(nearmiss.PartHeader, name, recordedValue, inRecorded, currentValue, inCurrent))
+	}
+	if len(differences) == 0 {
+		differences = append(differences, nearmiss.Difference{Part: nearmiss.PartHeader, Message: "headers differ after redaction"})
+	}
+	return differences
+}
+
+// redactHeaders applies the redaction patterns to headers the way matchesHeaders does, to their
+// JSON, keeping them as they were if the result is no longer JSON
+func (m *MockEngine) redactHeaders(headers map[string]string) map[string]string {
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return headers
+	}
+	var redacted map[string]string
+	if json.Unmarshal([]byte(m.redactSensitiveData(string(encoded))), &redacted) != nil {
+		return headers
+	}
+	return redacted
+}
+
+// bodyDifferences lists how a request body differs from the recorded one: field by field for JSON
+// and url-encoded forms, as a whole otherwise
+func (m *MockEngine) bodyDifferences(recordedBody, currentBody []byte, contentType string) []nearmiss.Difference {
+	var differences []nearmiss.Difference
+	var recordedJSON, currentJSON interface{}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	switch {
+	case mediaType == "application/x-www-form-urlencoded":
+		recorded, recordedErr := url.ParseQuery(string(recordedBody))
+		current, currentErr := url.ParseQuery(string(currentBody))
+		if recordedErr == nil && currentErr == nil {
+			for _, values := range []url.Values{recorded, current} {
+				for key := range values {
+					if m.shouldIgnoreField([]string{key}) {
+						delete(values, key)
+					}
+				}
+			}
+			differences = valuesDifferences(nearmiss.PartBody, recorded, current, m.mockConfig.MatchingStrategy == "fuzzy-subset", m.formValuesMatch)
+		}
+	case json.Unmarshal(recordedBody, &recordedJSON) == nil && json.Unmarshal(currentBody, &currentJSON) == nil:
+		differences = m.jsonDifferences(recordedJSON, currentJSON, nil)
+		if len(differences) == 0 && !m.fuzzyMatching() {
+			differences = append(differences, nearmiss.Difference{
+				Part:    nearmiss.PartBody,
+				Message: "equal as JSON but not byte for byte; the fuzzy strategies compare JSON by structure",
+			})
+		}
+	}
+	if len(differences) == 0 {
+		differences = append(differences, nearmiss.Difference{
+			Part: nearmiss.PartBody, Recorded: excerpt(string(recordedBody)), Actual: excerpt(string(currentBody)),
+			Message: fmt.Sprintf("recorded %q, got %q", excerpt(string(recordedBody)), excerpt(string(currentBody))),
+		})
+	}
+	return differences
+}
+
+// sameValues compares a param's values in order, as matchesQuery does
+func sameValues(recorded, current []string) bool {
+	return reflect.DeepEqual(recorded, current)
+}
+
+// formValuesMatch compares a url-encoded field's values the way matchesFormValues does
+func (m *MockEngine) formValuesMatch(recorded, current []string) bool {
+	if !m.fuzzyMatching() {
+		return reflect.DeepEqual(recorded, current)
+	}
+	if len(recorded) != len(current) {
+		return false
+	}
+	for i, value := range recorded {
+		if normalizeStringValue(value) != normalizeStringValue(current[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// jsonDifferences walks two JSON values down to the fields that differ, comparing them as the
+// matching strategy does. Their paths are in the form fuzzy_ignore_fields takes.
+func (m *MockEngine) jsonDifferences(recorded, current interface{}, path []string) []nearmiss.Difference {
+	unordered := m.mockConfig.MatchingStrategy == "fuzzy-unordered"
+	if m.fuzzyMatching() {
+		if m.fuzzyMatchJSONValue(recorded, current, false, unordered, path) {
+			return nil
+		}
+	} else if reflect.DeepEqual(recorded, current) {
+		return nil
+	}
+
+	field := formatPath(path)
+	var differences []nearmiss.Difference
+	switch recordedValue := recorded.(type) {
+	case map[string]interface{}:
+		currentValue, ok := current.(map[string]interface{})
+		if !ok {
+			break
+		}
+		for _, key := range unionKeys(recordedValue, currentValue) {
+			keyPath := appendPath(path, key)
+			recordedField, inRecorded := recordedValue[key]
+			currentField, inCurrent := currentValue[key]
+			switch {
+			case m.shouldIgnoreField(keyPath):
+			case inRecorded && inCurrent:
+				differences = append(differences, m.jsonDifferences(recordedField, currentField, keyPath)...)
+			case inRecorded || m.mockConfig.MatchingStrategy != "fuzzy-subset":
+				differences = append(differences, valueDifference(nearmiss.PartBody, formatPath(keyPath), recordedField, inRecorded, currentField, inCurrent))
+			}
+		}
+	case []interface{}:
+		currentValue, ok := current.([]interface{})
+		if !ok {
+			break
+		}
+		if len(recordedValue) != len(currentValue) {
+			return []nearmiss.Difference{{
+				Part: nearmiss.PartBody, Field: field,
+				Message: fmt.Sprintf("recorded %d elements, got %d", len(recordedValue), len(currentValue)),
+			}}
+		}
+		if unordered {
+			return []nearmiss.Difference{{Part: nearmiss.PartBody, Field: field, Message: "no pairing of the elements in any order matches"}}
+		}
+		for i := range recordedValue {
+			differences = append(differences, m.jsonDifferences(recordedValue[i], currentValue[i], appendPath(path, indexSegment(i)))...)
+		}
+	}
+	if len(differences) == 0 {
+		differences = append(differences, valueDifference(nearmiss.PartBody, field, recorded, true, current, true))
+	}
+	return differences
+}
+
+// valuesDifferences lists the params or form fields that differ; with subset, the request may
+// have ones the recording lacks
+func valuesDifferences(part string, recorded, current url.Values, subset bool, same func(recorded, current []string) bool) []nearmiss.Difference {
+	var differences []nearmiss.Difference
+	for _, key := range unionKeys(recorded, current) {
+		recordedValues, inRecorded := recorded[key]
+		currentValues, inCurrent := current[key]
+		if (inRecorded && inCurrent && same(recordedValues, currentValues)) || (!inRecorded && subset) {
+			continue
+		}
+		differences = append(differences, valueDifference(part, key, paramValue(recordedValues), inRecorded, paramValue(currentValues), inCurrent))
+	}
+	return differences
+}
+
+// valueDifference describes a field that differs or is missing from one side
+func valueDifference(part, field string, recorded interface{}, inRecorded bool, current interface{}, inCurrent bool) nearmiss.Difference {
+	difference := nearmiss.Difference{Part: part, Field: field}
+	switch {
+	case !inCurrent:
+		difference.Recorded = recorded
+		difference.Message = fmt.Sprintf("recorded %s, missing from the request", describeValue(recorded))
+	case !inRecorded:
+		difference.Actual = current
+		difference.Message = fmt.Sprintf("got %s, not in the recording", describeValue(current))
+	default:
+		difference.Recorded, difference.Actual = recorded, current
+		difference.Message = fmt.Sprintf("recorded %s, got %s", describeValue(recorded), describeValue(current))
+	}
+	return difference
+}
+
+// paramValue shows a param's values as a string when there is only one
+func paramValue(values []string) interface{} {
+	if len(values) == 1 {
+		return values[0]
+	}
+	return values
+}
+
+// describeValue renders a value as JSON for a message, cut short when long
+func describeValue(value interface{}) string {
+	encoded, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprintf("%v", value)
+	}
+	return excerpt(string(encoded))
+}
+
+func excerpt(s string) string {
+	if len(s) <= maxExcerptBytes {
+		return s
+	}
+	return s[:maxExcerptBytes] + "..."
+}
+
+// formatPath renders a path of field names and indexes as a JSON path, e.g. $.items[0].id
+func formatPath(path []string) string {
+	var formatted strings.Builder
+	formatted.WriteString("$")
+	for _, segment := range path {
+		if !isIndexSegment(segment) {
+			formatted.WriteString(".")
+		}
+		formatted.WriteString(segment)
+	}
+	return formatted.String()
+}
+
+// unionKeys returns the keys of two maps with string keys, sorted
+func unionKeys(a, b interface{}) []string {
+	seen := make(map[string]bool)
+	var keys []string
+	for _, m := range []interface{}{a, b} {
+		for _, key := range reflect.ValueOf(m).MapKeys() {
+			if !seen[key.String()] {
+				seen[key.String()] = true
+				keys = append(keys, key.String())
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,360 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mimic/config"
+	"mimic/proxy"
+	"mimic/storage"
+)
+
+// responseOverride is a compiled mock.overrides entry
+type responseOverride struct {
+	covers        *matchRule // The requests it applies to
+	config        config.ResponseOverride
+	set           []ignorePath // Paths of config.Set, in order
+	delete        []ignorePath
+	streamSet     []ignorePath     // Paths of config.StreamSet, in order
+	streamReplace []*regexp.Regexp // Patterns of config.StreamReplace, in order
+}
+
+// compileOverrides compiles the response overrides of a mock config, in order
+func compileOverrides(cfg config.MockConfig) ([]*responseOverride, error) {
+	overrides := make([]*responseOverride, 0, len(cfg.Overrides))
+	for i, override := range cfg.Overrides {
+		path := override.Path
+		if path == "" && override.PathRegex == "" {
+			path = "/**"
+		}
+		covers, err := newMatchRule(override.Method, path, override.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path in mock override %d: %w", i, err)
+		}
+
+		compiled := &responseOverride{covers: covers, config: override}
+		for _, value := range override.Set {
+			path, ok := parseBodyPath(value.Path)
+			if !ok {
+				return nil, fmt.Errorf("invalid set path in mock override %d: %s", i, value.Path)
+			}
+			compiled.set = append(compiled.set, path)
+		}
+		for _, expr := range override.Delete {
+			path, ok := parseBodyPath(expr)
+			if !ok {
+				return nil, fmt.Errorf("invalid delete path in mock override %d: %s", i, expr)
+			}
+			compiled.delete = append(compiled.delete, path)
+		}
+		for _, value := range override.StreamSet {
+			path, ok := parseBodyPath(value.Path)
+			if !ok {
+				return nil, fmt.Errorf("invalid stream_set path in mock override %d: %s", i, value.Path)
+			}
+			compiled.streamSet = append(compiled.streamSet, path)
+		}
+		for _, replace := range override.StreamReplace {
+			pattern, err := regexp.Compile(replace.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid stream_replace pattern in mock override %d: %w", i, err)
+			}
+			compiled.streamReplace = append(compiled.streamReplace, pattern)
+		}
+		overrides = append(overrides, compiled)
+	}
+	return overrides, nil
+}
+
+// parseBodyPath parses a path through a JSON body, where a plain field name is a field of the
+// top-level object
+func parseBodyPath(expr string) (ignorePath, bool) {
+	if path, ok := parseIgnorePath(expr); ok {
+		return path, true
+	}
+	name := strings.TrimPrefix(strings.TrimPrefix(expr, "$"), ".")
+	return ignorePath{name}, name != ""
+}
+
+// applyOverrides returns the interaction as the overrides covering the request would serve it: a
+// copy with their status, headers, and body edits, or the interaction itself when none covers it
+func (m *MockEngine) applyOverrides(interaction *storage.Interaction, r *http.Request) (*storage.Interaction, error) {
+	covering := m.coveringOverrides(r)
+	if len(covering) == 0 {
+		return interaction, nil
+	}
+
+	headers := make(map[string]string)
+	if interaction.ResponseHeaders != "" {
+		if err := json.Unmarshal([]byte(interaction.ResponseHeaders), &headers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response headers: %w", err)
+		}
+	}
+
+	served := *interaction
+	editsBody := false
+	for _, override := range covering {
+		if override.config.Status != 0 {
+			served.ResponseStatus = override.config.Status
+		}
+		for name, value := range override.config.SetHeaders {
+			removeHeader(headers, name)
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+		for _, name := range override.config.RemoveHeaders {
+			removeHeader(headers, name)
+		}
+		editsBody = editsBody || len(override.set) > 0 || len(override.delete) > 0
+	}
+
+	// Bodies kept in body files and streamed responses are served as recorded
+	if editsBody && !interaction.IsStreaming && interaction.ResponseBodyFile() == "" {
+		body, err := m.editBody(interaction.ResponseBody, covering, r)
+		if err != nil {
+			log.Printf("Serving %s %s without its body overrides: %v", r.Method, r.URL.Path, err)
+		} else {
+			served.ResponseBody = body
+			if removeHeader(headers, "Content-Length") {
+				headers["Content-Length"] = strconv.Itoa(len(body))
+			}
+		}
+	}
+
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response headers: %w", err)
+	}
+	served.ResponseHeaders = string(encoded)
+	return &served, nil
+}
+
+// coveringOverrides returns the overrides that apply to a request, in order
+func (m *MockEngine) coveringOverrides(r *http.Request) []*responseOverride {
+	var covering []*responseOverride
+	for _, override := range m.overrides {
+		if override.covers.covers(r.Method, r.URL.Path) {
+			covering = append(covering, override)
+		}
+	}
+	return covering
+}
+
+// removeHeader removes a header from recorded headers, whatever case its name was recorded in,
+// reporting whether it was there
+func removeHeader(headers map[string]string, name string) bool {
+	removed := false
+	for recorded := range headers {
+		if strings.EqualFold(recorded, name) {
+			delete(headers, recorded)
+			removed = true
+		}
+	}
+	return removed
+}
+
+// editBody applies the body edits of overrides to a JSON body
+func (m *MockEngine) editBody(body []byte, overrides []*responseOverride, r *http.Request) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // Numbers the overrides leave alone keep their precision
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("response body is not JSON: %w", err)
+	}
+
+	for _, override := range overrides {
+		for i, path := range override.set {
+			newValue := override.config.Set[i].Value
+			if text, ok := newValue.(string); ok {
+				rendered, err := m.renderTemplate([]byte(text), r)
+				if err != nil {
+					return nil, err
+				}
+				newValue = string(rendered)
+			}
+			value = setBodyPath(value, path, newValue)
+		}
+		for _, path := range override.delete {
+			value = deleteBodyPath(value, path)
+		}
+	}
+
+	edited, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response body: %w", err)
+	}
+	return edited, nil
+}
+
+// setBodyPath sets the values at a path of a decoded JSON body, creating the objects missing
+// along it, and returns the body
+func setBodyPath(value interface{}, path ignorePath, newValue interface{}) interface{} {
+	if len(path) == 0 {
+		return newValue
+	}
+	segment, rest := path[0], path[1:]
+	switch {
+	case segment == "[*]":
+		if array, ok := value.([]interface{}); ok {
+			for i := range array {
+				array[i] = setBodyPath(array[i], rest, newValue)
+			}
+		}
+	case isIndexSegment(segment):
+		array, ok := value.([]interface{})
+		if i, err := strconv.Atoi(segment[1 : len(segment)-1]); ok && err == nil && i >= 0 && i < len(array) {
+			array[i] = setBodyPath(array[i], rest, newValue)
+		}
+	case segment == "*":
+		if object, ok := value.(map[string]interface{}); ok {
+			for name := range object {
+				object[name] = setBodyPath(object[name], rest, newValue)
+			}
+		}
+	default:
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			if value != nil {
+				return value
+			}
+			object = make(map[string]interface{})
+		}
+		object[segment] = setBodyPath(object[segment], rest, newValue)
+		return object
+	}
+	return value
+}
+
+// deleteBodyPath removes the values at a path of a decoded JSON body and returns the body
+func deleteBodyPath(value interface{}, path ignorePath) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	segment, rest := path[0], path[1:]
+	switch object := value.(type) {
+	case map[string]interface{}:
+		for name := range object {
+			if segment != "*" && segment != name {
+				continue
+			}
+			if len(rest) == 0 {
+				delete(object, name)
+			} else {
+				object[name] = deleteBodyPath(object[name], rest)
+			}
+		}
+	case []interface{}:
+		kept := object[:0]
+		for i, element := range object {
+			if segment != "[*]" && segment != indexSegment(i) {
+				kept = append(kept, element)
+				continue
+			}
+			if len(rest) > 0 {
+				kept = append(kept, deleteBodyPath(element, rest))
+			}
+		}
+		return kept
+	}
+	return value
+}
+
+// transformChunks applies the stream edits of the overrides covering a request to the chunks of
+// a streamed response as they are replayed: first the JSON edits inside data: payloads, then the
+// replacements in each chunk's text. Chunks are left alone when a value fails to render.
+func (m *MockEngine) transformChunks(chunks []*proxy.SSEChunk, r *http.Request) error {
+	var editing []*responseOverride
+	for _, override := range m.coveringOverrides(r) {
+		if len(override.streamSet) > 0 || len(override.streamReplace) > 0 {
+			editing = append(editing, override)
+		}
+	}
+	if len(editing) == 0 {
+		return nil
+	}
+
+	// Values and replacements are rendered once, so every chunk gets the same ones
+	values := make([][]interface{}, len(editing))
+	replacements := make([][][]byte, len(editing))
+	for i, override := range editing {
+		for _, value := range override.config.StreamSet {
+			newValue := value.Value
+			if text, ok := newValue.(string); ok {
+				rendered, err := m.renderTemplate([]byte(text), r)
+				if err != nil {
+					return err
+				}
+				newValue = string(rendered)
+			}
+			values[i] = append(values[i], newValue)
+		}
+		for _, replace := range override.config.StreamReplace {
+			rendered, err := m.renderTemplate([]byte(replace.Replacement), r)
+			if err != nil {
+				return err
+			}
+			replacements[i] = append(replacements[i], rendered)
+		}
+	}
+
+	for _, chunk := range chunks {
+		data := chunk.RawData
+		for i, override := range editing {
+			if len(override.streamSet) > 0 {
+				data = editDataLines(data, override.streamSet, values[i])
+			}
+			for j, pattern := range override.streamReplace {
+				data = pattern.ReplaceAll(data, replacements[i][j])
+			}
+		}
+		chunk.RawData = data
+	}
+	return nil
+}
+
+// editDataLines sets values at paths of the JSON payloads on the data: lines of an SSE chunk.
+// Lines that are not JSON, such as data: [DONE], are left alone.
+func editDataLines(chunk []byte, paths []ignorePath, values []interface{}) []byte {
+	lines := bytes.Split(chunk, []byte("\n"))
+	for i, line := range lines {
+		payload, ok := bytes.CutPrefix(line, []byte("data:"))
+		if !ok {
+			continue
+		}
+		prefix := "data:"
+		if trimmed, spaced := bytes.CutPrefix(payload, []byte(" ")); spaced {
+			prefix, payload = "data: ", trimmed
+		}
+		payload, carriageReturn := bytes.CutSuffix(payload, []byte("\r"))
+
+		decoder := json.NewDecoder(bytes.NewReader(payload))
+		decoder.UseNumber()
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			continue
+		}
+		if _, isObject := value.(map[string]interface{}); !isObject {
+			if _, isArray := value.([]interface{}); !isArray {
+				continue
+			}
+		}
+		for j, path := range paths {
+			value = setBodyPath(value, path, values[j])
+		}
+		edited, err := json.Marshal(value)
+		if err != nil {
+			continue
+		}
+		line = append([]byte(prefix), edited...)
+		if carriageReturn {
+			line = append(line, '\r')
+		}
+		lines[i] = line
+	}
+	return bytes.Join(lines, []byte("\n"))
+}
@@ -0,0 +1,64 @@
+package mock
+
+import (
+	"net/http"
+	"net/url"
+	"reflect"
+
+	"mimic/storage"
+)
+
+// Query matching modes
+const (
+	QueryIgnore = "ignore" // The query string plays no part in matching (default)
+	QueryExact  = "exact"  // The request must have exactly the recorded params
+	QuerySubset = "subset" // The request must have every recorded param, and may have more
+)
+
+// queryMatching returns the configured query matching mode
+func (m *MockEngine) queryMatching() string {
+	if m.mockConfig == nil || m.mockConfig.QueryMatching == "" {
+		return QueryIgnore
+	}
+	return m.mockConfig.QueryMatching
+}
+
+// matchesQuery compares the request's query params with those the interaction was recorded with.
+// Params on the ignore list are left out, and a param's values must match in order.
+func (m *MockEngine) matchesQuery(interaction storage.Interaction, r *http.Request) bool {
+	mode := m.queryMatching()
+	if mode == QueryIgnore {
+		return true
+	}
+	recorded := m.queryParams(interaction.Query())
+	current := m.queryParams(r.URL.RawQuery)
+
+	if mode == QueryExact && len(recorded) != len(current) {
+		return false
+	}
+	for key, values := range recorded {
+		if !reflect.DeepEqual(values, current[key]) {
+			return false
+		}
+	}
+	return true
+}
+
+// queryParams parses a raw query without the ignored params. Malformed pairs are skipped, as
+// they are by net/http.
+func (m *MockEngine) queryParams(rawQuery string) url.Values {
+	params, _ := url.ParseQuery(rawQuery)
+	for _, name := range m.mockConfig.IgnoreQueryParams {
+		params.Del(name)
+	}
+	return params
+}
+
+// querySignature is the part of a request's sequence signature taken from its query, so that
+// requests matched to different recordings by their query advance separately
+func (m *MockEngine) querySignature(r *http.Request) string {
+	if m.queryMatching() == QueryIgnore {
+		return ""
+	}
+	return m.queryParams(r.URL.RawQuery).Encode()
+}
@@ -0,0 +1,137 @@
+package mock
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"mimic/fault"
+	"mimic/storage"
+)
+
+// Default window over which mock.quota counts requests
+const defaultQuotaWindow = time.Minute
+
+// quotaWindow counts a client's requests to an endpoint since the window started
+type quotaWindow struct {
+	start time.Time
+	count int
+}
+
+// quotaCounters tracks the requests each client has made to each endpoint under mock.quota.
+// Counts are kept in process, so each replica counts on its own.
+type quotaCounters struct {
+	mutex   sync.Mutex
+	windows map[string]*quotaWindow
+}
+
+func newQuotaCounters() *quotaCounters {
+	return &quotaCounters{windows: make(map[string]*quotaWindow)}
+}
+
+// admit counts a request made at now against a limit per window, or returns how long until the
+// window lets another through when the limit is spent. Windows follow the engine's clock, so
+// advancing a virtual clock past the window lets requests through again.
+func (q *quotaCounters) admit(key string, limit int, window time.Duration, now time.Time) (time.Duration, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	current := q.windows[key]
+	if current == nil || !now.Before(current.start.Add(window)) || now.Before(current.start) {
+		current = &quotaWindow{start: now}
+		q.windows[key] = current
+	}
+	if current.count >= limit {
+		return current.start.Add(window).Sub(now), false
+	}
+	current.count++
+	return 0, true
+}
+
+// reset forgets every count, so every client starts with a full quota
+func (q *quotaCounters) reset() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	q.windows = make(map[string]*quotaWindow)
+}
+
+// quotaWindowLength is the window mock.quota counts requests over
+func (m *MockEngine) quotaWindowLength() time.Duration {
+	if seconds := m.mockConfig.Quota.WindowSeconds; seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return defaultQuotaWindow
+}
+
+// quotaClient names the client a request counts against: the value of mock.quota.client_header,
+// or else the client's IP address
+func (m *MockEngine) quotaClient(r *http.Request) string {
+	if header := m.mockConfig.Quota.ClientHeader; header != "" {
+		return r.Header.Get(header)
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// checkQuota counts a request against its client's quota for the endpoint and, once the quota is
+// spent, answers it the way the upstream did: with a recording of the endpoint answered with the
+// quota status, or else a synthesized response. It reports whether it answered the request.
+func (m *MockEngine) checkQuota(w http.ResponseWriter, r *http.Request, endpoint []storage.Interaction) bool {
+	if m.mockConfig == nil || m.mockConfig.Quota.Requests <= 0 {
+		return false
+	}
+	quota := m.mockConfig.Quota
+	key := strings.Join([]string{m.quotaClient(r), r.Method, r.URL.Path}, " ")
+	retryAfter, ok := m.quotas.admit(key, quota.Requests, m.quotaWindowLength(), m.now())
+	if ok {
+		return false
+	}
+
+	status := quota.Status
+	if status == 0 {
+		status = http.StatusTooManyRequests
+	}
+	log.Printf("[MOCK] Quota of %d requests spent for %s %s, answering %d", quota.Requests, r.Method, r.URL.Path, status)
+
+	// The latest recording of the quota error is served as recorded, Retry-After included
+	for i := len(endpoint) - 1; i >= 0; i-- {
+		if endpoint[i].ResponseStatus != status {
+			continue
+		}
+		m.recordCall(r, true)
+		if err := m.sendMockResponse(w, r, &endpoint[i], fault.Outcome{}); err != nil {
+			log.Printf("Error sending recorded quota response: %v", err)
+		}
+		return true
+	}
+
+	m.recordCall(r, false)
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(quota.Requests))
+	w.Header().Set("X-RateLimit-Remaining", "0")
+	w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(m.now().Add(retryAfter).Unix(), 10))
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"error": "rate limit exceeded"}); err != nil {
+		log.Printf("Error encoding quota response: %v", err)
+	}
+	return true
+}
+
+// ResetQuotas gives every client a full quota again
+func (m *MockEngine) ResetQuotas() {
+	m.quotas.reset()
+}
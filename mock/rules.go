@@ -0,0 +1,153 @@
+package mock
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"mimic/config"
+)
+
+// How a rule picks its endpoints, from least to most specific
+const (
+	ruleRegex = iota
+	ruleGlob
+	ruleExact
+)
+
+// matchRule is a compiled MatchRule along with the mock config it matches requests with
+type matchRule struct {
+	pattern  *regexp.Regexp
+	method   string
+	kind     int
+	literals int // Characters of a glob that are not wildcards
+	settings *config.MockConfig
+}
+
+// compileMatchRules compiles the rules of a mock config, in order
+func compileMatchRules(cfg config.MockConfig) ([]*matchRule, error) {
+	rules := make([]*matchRule, 0, len(cfg.Rules))
+	for i, rule := range cfg.Rules {
+		compiled, err := newMatchRule(rule.Method, rule.Path, rule.PathRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path in mock rule %d: %w", i, err)
+		}
+		compiled.settings = ruleSettings(cfg, rule)
+		rules = append(rules, compiled)
+	}
+	return rules, nil
+}
+
+// newMatchRule compiles the endpoints a rule covers, without settings
+func newMatchRule(method, path, pathRegex string) (*matchRule, error) {
+	compiled := &matchRule{method: strings.ToUpper(method)}
+	var err error
+	if pathRegex != "" {
+		compiled.kind = ruleRegex
+		compiled.pattern, err = regexp.Compile("^(?:" + pathRegex + ")$")
+	} else {
+		compiled.kind, compiled.literals = ruleGlob, len(strings.ReplaceAll(path, "*", ""))
+		if compiled.literals == len(path) {
+			compiled.kind = ruleExact
+		}
+		compiled.pattern, err = regexp.Compile(config.GlobPattern(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// ruleSettings is the mock config with the settings a rule sets in place of its own
+func ruleSettings(cfg config.MockConfig, rule config.MatchRule) *config.MockConfig {
+	settings := cfg
+	settings.Rules = nil
+	if rule.MatchingStrategy != "" {
+		settings.MatchingStrategy = rule.MatchingStrategy
+	}
+	if rule.FuzzyIgnoreFields != nil {
+		settings.FuzzyIgnoreFields = rule.FuzzyIgnoreFields
+	}
+	if rule.QueryMatching != "" {
+		settings.QueryMatching = rule.QueryMatching
+	}
+	if rule.IgnoreQueryParams != nil {
+		settings.IgnoreQueryParams = rule.IgnoreQueryParams
+	}
+	if rule.MatchHeaders != nil {
+		settings.MatchHeaders = rule.MatchHeaders
+	}
+	if rule.IgnoreHeaders != nil {
+		settings.IgnoreHeaders = rule.IgnoreHeaders
+	}
+	if rule.NumericTolerance != 0 {
+		settings.NumericTolerance = rule.NumericTolerance
+	}
+	if rule.NumericRelativeTolerance != 0 {
+		settings.NumericRelativeTolerance = rule.NumericRelativeTolerance
+	}
+	if rule.Latency != nil {
+		settings.Latency = *rule.Latency
+	}
+	if rule.Faults != nil {
+		settings.Faults = *rule.Faults
+	}
+	if rule.Streaming != nil {
+		settings.Streaming = *rule.Streaming
+	}
+	if rule.Quota != nil {
+		settings.Quota = *rule.Quota
+	}
+	return &settings
+}
+
+// covers reports whether the rule applies to a request
+func (r *matchRule) covers(method, path string) bool {
+	if r.method != "" && r.method != strings.ToUpper(method) {
+		return false
+	}
+	return r.pattern.MatchString(path)
+}
+
+// moreSpecificThan orders rules covering the same request: an exact path beats a glob, which
+// beats a regex; among globs, the one with more literal characters wins; and then a rule for the
+// request's method beats one for every method
+func (r *matchRule) moreSpecificThan(other *matchRule) bool {
+	if r.kind != other.kind {
+		return r.kind > other.kind
+	}
+	if r.literals != other.literals {
+		return r.literals > other.literals
+	}
+	return r.method != "" && other.method == ""
+}
+
+// resolveRule returns the most specific rule covering a request, the first listed on a tie, or
+// nil when none does
+func (m *MockEngine) resolveRule(method, path string) *matchRule {
+	var best *matchRule
+	for _, rule := range m.rules {
+		if rule.covers(method, path) && (best == nil || rule.moreSpecificThan(best)) {
+			best = rule
+		}
+	}
+	return best
+}
+
+// forRequest returns the engine to match a request with: this one, or when a rule covers the
+// request, a copy that matches with the rule's settings
+func (m *MockEngine) forRequest(r *http.Request) *MockEngine {
+	rule := m.resolveRule(r.Method, r.URL.Path)
+	if rule == nil {
+		return m
+	}
+	return m.withSettings(rule.settings)
+}
+
+// withSettings returns a copy of the engine that matches with other settings
+func (m *MockEngine) withSettings(settings *config.MockConfig) *MockEngine {
+	matcher := *m
+	matcher.mockConfig = settings
+	return &matcher
+}
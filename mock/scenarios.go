@@ -0,0 +1,131 @@
+package mock
+
+import (
+	"log"
+	"sync"
+
+	"mimic/storage"
+)
+
+// ScenarioStarted is the state every scenario starts in, and returns to when reset
+const ScenarioStarted = "Started"
+
+// scenarioStates tracks the current state of each scenario a mock proxy serves. States are kept
+// in process, so each replica walks its scenarios on its own.
+type scenarioStates struct {
+	mutex  sync.Mutex
+	states map[string]string
+}
+
+func newScenarioStates() *scenarioStates {
+	return &scenarioStates{states: make(map[string]string)}
+}
+
+func (s *scenarioStates) state(scenario string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if state, ok := s.states[scenario]; ok {
+		return state
+	}
+	return ScenarioStarted
+}
+
+func (s *scenarioStates) set(scenario, state string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.states[scenario] = state
+}
+
+func (s *scenarioStates) snapshot() map[string]string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	states := make(map[string]string, len(s.states))
+	for scenario, state := range s.states {
+		states[scenario] = state
+	}
+	return states
+}
+
+// reset returns scenarios to ScenarioStarted, every one for an empty name, reporting how many
+// had moved
+func (s *scenarioStates) reset(scenario string) int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if scenario == "" {
+		reset := len(s.states)
+		s.states = make(map[string]string)
+		return reset
+	}
+	if _, ok := s.states[scenario]; !ok {
+		return 0
+	}
+	delete(s.states, scenario)
+	return 1
+}
+
+// inScenarioState narrows matching recordings to those their scenario's current state allows.
+// Recordings that require the current state are preferred over those served in any state, so a
+// recording made after a state change wins over the one made before it.
+func (m *MockEngine) inScenarioState(interactions []storage.Interaction) []storage.Interaction {
+	var required, unconditional []storage.Interaction
+	for _, interaction := range interactions {
+		switch state := interaction.RequiredState(); {
+		case state == "":
+			unconditional = append(unconditional, interaction)
+		case state == m.scenarios.state(interaction.Scenario()):
+			required = append(required, interaction)
+		}
+	}
+	if len(required) > 0 {
+		return required
+	}
+	return unconditional
+}
+
+// advanceScenario moves the scenario of a served recording to the recording's new state
+func (m *MockEngine) advanceScenario(interaction *storage.Interaction) {
+	state := interaction.NewState()
+	if state == "" {
+		return
+	}
+	scenario := interaction.Scenario()
+	if previous := m.scenarios.state(scenario); previous != state {
+		log.Printf("Scenario %q moved from %q to %q", scenario, previous, state)
+	}
+	m.scenarios.set(scenario, state)
+}
+
+// ScenarioStates returns the current state of each scenario, including those the session's
+// recordings name that have not left ScenarioStarted
+func (m *MockEngine) ScenarioStates() map[string]string {
+	states := make(map[string]string)
+	if interactions, err := m.database.GetInteractionsBySession(m.session.ID); err == nil {
+		for _, interaction := range interactions {
+			if interaction.RequiredState() != "" || interaction.NewState() != "" {
+				states[interaction.Scenario()] = ScenarioStarted
+			}
+		}
+	} else {
+		log.Printf("Error listing interactions for scenario states: %v", err)
+	}
+	for scenario, state := range m.scenarios.snapshot() {
+		states[scenario] = state
+	}
+	return states
+}
+
+// SetScenarioState moves a scenario to a state, as if a recording had
+func (m *MockEngine) SetScenarioState(scenario, state string) {
+	m.scenarios.set(scenario, state)
+	log.Printf("Set scenario %q to %q", scenario, state)
+}
+
+// ResetScenarios returns a scenario to ScenarioStarted, every scenario for an empty name,
+// reporting how many had moved
+func (m *MockEngine) ResetScenarios(scenario string) int {
+	reset := m.scenarios.reset(scenario)
+	log.Printf("Reset %d scenario state(s)", reset)
+	return reset
+}
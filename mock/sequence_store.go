@@ -0,0 +1,188 @@
+package mock
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"mimic/storage"
+)
+
+// How repeated requests pick among their matching recordings
+const (
+	SequenceOrdered      = "ordered"       // Same as SequenceOrderedCycle
+	SequenceOrderedCycle = "ordered-cycle" // In recorded order, starting over once every recording is served
+	SequenceRepeatLast   = "repeat-last"   // In recorded order, then the last recording from then on
+	SequenceStrict       = "strict"        // In recorded order, then the sequence exhausted response
+	SequenceRandom       = "random"        // Any recording, picked at random each time
+)
+
+// How sequence positions are kept across restarts
+const (
+	PersistSequencesOff       = "off"       // In memory only; a restart rewinds every sequence
+	PersistSequencesImmediate = "immediate" // Written to the database as each request advances
+	PersistSequencesInterval  = "interval"  // Kept in memory and written to the database periodically
+)
+
+// sequenceStore tracks how far ordered playback has progressed for each request signature
+type sequenceStore interface {
+	// advance passes the signature's current position to next and stores the position it returns
+	advance(signature string, next func(current int) int)
+	positions() map[string]int
+	reset()
+	resetSignature(signature string) bool
+}
+
+// memorySequenceStore keeps positions in process, which is all a single replica needs
+type memorySequenceStore struct {
+	mutex sync.Mutex
+	state map[string]int
+}
+
+func newMemorySequenceStore() *memorySequenceStore {
+	return &memorySequenceStore{state: make(map[string]int)}
+}
+
+func (s *memorySequenceStore) advance(signature string, next func(current int) int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state[signature] = next(s.state[signature])
+}
+
+func (s *memorySequenceStore) positions() map[string]int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	state := make(map[string]int, len(s.state))
+	for key, value := range s.state {
+		state[key] = value
+	}
+	return state
+}
+
+func (s *memorySequenceStore) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.state = make(map[string]int)
+}
+
+func (s *memorySequenceStore) resetSignature(signature string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.state[signature]; !ok {
+		return false
+	}
+	delete(s.state, signature)
+	return true
+}
+
+// databaseSequenceStore keeps positions in the shared database so every replica
+// serving the same proxy and session walks one sequence together
+type databaseSequenceStore struct {
+	database *storage.Database
+	scope    string
+}
+
+func newDatabaseSequenceStore(db *storage.Database, proxyName, sessionName string) *databaseSequenceStore {
+	return &databaseSequenceStore{database: db, scope: proxyName + "/" + sessionName}
+}
+
+func (s *databaseSequenceStore) advance(signature string, next func(current int) int) {
+	if _, err := s.database.AdvanceSequence(s.scope, signature, next); err != nil {
+		// Still answer the request, starting from the beginning of the sequence
+		log.Printf("Failed to advance shared sequence state for %q: %v", signature, err)
+		next(0)
+	}
+}
+
+func (s *databaseSequenceStore) positions() map[string]int {
+	state, err := s.database.GetSequenceState(s.scope)
+	if err != nil {
+		log.Printf("Failed to read shared sequence state: %v", err)
+		return make(map[string]int)
+	}
+	return state
+}
+
+func (s *databaseSequenceStore) reset() {
+	if _, err := s.database.ResetSequenceState(s.scope, ""); err != nil {
+		log.Printf("Failed to reset shared sequence state: %v", err)
+	}
+}
+
+func (s *databaseSequenceStore) resetSignature(signature string) bool {
+	reset, err := s.database.ResetSequenceState(s.scope, signature)
+	if err != nil {
+		log.Printf("Failed to reset shared sequence state for %q: %v", signature, err)
+		return false
+	}
+	return reset > 0
+}
+
+// flushingSequenceStore keeps positions in memory, restored from the database when created, and
+// writes them back at most once per interval after they change
+type flushingSequenceStore struct {
+	*memorySequenceStore
+	database *storage.Database
+	scope    string
+	interval time.Duration
+
+	flushMutex sync.Mutex
+	pending    bool
+}
+
+func newFlushingSequenceStore(db *storage.Database, proxyName, sessionName string, interval time.Duration) *flushingSequenceStore {
+	s := &flushingSequenceStore{
+		memorySequenceStore: newMemorySequenceStore(),
+		database:            db,
+		scope:               proxyName + "/" + sessionName,
+		interval:            interval,
+	}
+	if state, err := db.GetSequenceState(s.scope); err != nil {
+		log.Printf("Failed to restore sequence state: %v", err)
+	} else {
+		s.state = state
+	}
+	return s
+}
+
+func (s *flushingSequenceStore) advance(signature string, next func(current int) int) {
+	s.memorySequenceStore.advance(signature, next)
+	s.scheduleFlush()
+}
+
+func (s *flushingSequenceStore) reset() {
+	s.memorySequenceStore.reset()
+	s.scheduleFlush()
+}
+
+func (s *flushingSequenceStore) resetSignature(signature string) bool {
+	if !s.memorySequenceStore.resetSignature(signature) {
+		return false
+	}
+	s.scheduleFlush()
+	return true
+}
+
+// scheduleFlush writes the positions once the interval passes, unless a write is already due
+func (s *flushingSequenceStore) scheduleFlush() {
+	s.flushMutex.Lock()
+	defer s.flushMutex.Unlock()
+	if s.pending {
+		return
+	}
+	s.pending = true
+	time.AfterFunc(s.interval, s.flush)
+}
+
+// flush writes the current positions to the database
+func (s *flushingSequenceStore) flush() {
+	s.flushMutex.Lock()
+	s.pending = false
+	s.flushMutex.Unlock()
+
+	if err := s.database.SaveSequenceState(s.scope, s.positions()); err != nil {
+		log.Printf("Failed to persist sequence state: %v", err)
+	}
+}
@@ -0,0 +1,99 @@
+package mock
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"mimic/storage"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultSessionHeader is the request header naming the session a mock answers from, unless
+// mock.session_header names another
+const DefaultSessionHeader = "X-Mimic-Session"
+
+// sessionKeySeparator ends the session name that sequence keys of requests naming a session start
+// with, keeping their positions apart from those of the engine's own session
+const sessionKeySeparator = "|"
+
+// splitSequenceKey splits a sequence key into the session a request named, "" for the engine's
+// own session, and the request signature
+func splitSequenceKey(key string) (session, signature string) {
+	// The separator has to come before the signature's first field, since headers and bodies can hold it
+	index := strings.Index(key, sessionKeySeparator)
+	if index < 0 || strings.Contains(key[:index], ":") {
+		return "", key
+	}
+	return key[:index], key[index+len(sessionKeySeparator):]
+}
+
+// sessionHeader is the header naming the session a request is answered from, "" when requests
+// cannot name one
+func (m *MockEngine) sessionHeader() string {
+	if m.mockConfig == nil {
+		return DefaultSessionHeader
+	}
+	switch header := m.mockConfig.SessionHeader; header {
+	case "":
+		return DefaultSessionHeader
+	case "none":
+		return ""
+	default:
+		return header
+	}
+}
+
+// requestedSessionName names the session a request asks to be answered from, "" when it names none
+func (m *MockEngine) requestedSessionName(r *http.Request) string {
+	header := m.sessionHeader()
+	if header == "" {
+		return ""
+	}
+	return strings.TrimSpace(r.Header.Get(header))
+}
+
+// forSession returns the engine that answers a request: a copy answering from the session the
+// request names, or the engine itself when it names none or the proxy's own. It returns nil when
+// the named session does not exist.
+func (m *MockEngine) forSession(r *http.Request) *MockEngine {
+	name := m.requestedSessionName(r)
+	if name == "" || name == m.session.SessionName {
+		return m
+	}
+	session, err := m.database.GetSession(name)
+	if err != nil {
+		return nil
+	}
+	engine := *m
+	engine.session = session
+	engine.requestedSession = true
+	return &engine
+}
+
+// sessionFromContext returns the session a gRPC call names in its metadata, or else the route's
+// session. It returns nil when the named session does not exist.
+func sessionFromContext(ctx context.Context, db *storage.Database, header string, session *storage.Session) *storage.Session {
+	if header == "" {
+		return session
+	}
+	name := metadataValue(ctx, header)
+	if name == "" || name == session.SessionName {
+		return session
+	}
+	requested, err := db.GetSession(name)
+	if err != nil {
+		return nil
+	}
+	return requested
+}
+
+// metadataValue is the first value of a gRPC call's metadata key, trimmed, "" when it has none
+func metadataValue(ctx context.Context, key string) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(key); len(values) > 0 {
+		return strings.TrimSpace(values[0])
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"mimic/storage"
+
+	"github.com/google/uuid"
+)
+
+// templateRequest is what response templates are filled from, e.g. {{ index .Segments 1 }},
+// {{ .Query.Get "page" }}, {{ .Headers.Get "X-Request-Id" }}, or {{ .Body.user.id }}
+type templateRequest struct {
+	Method   string
+	Path     string
+	Segments []string    // Path segments, so /users/42 has "users" and "42"
+	Query    url.Values  // Query params
+	Headers  http.Header // Request headers
+	Body     interface{} // JSON request body, decoded; nil when the body is not JSON
+	Form     url.Values  // Fields of a urlencoded request body
+	RawBody  string      // Request body as sent, decompressed
+}
+
+// templateFuncs are the functions response templates can call besides the text/template builtins
+func templateFuncs(now func() time.Time) template.FuncMap {
+	return template.FuncMap{
+		// now is the current time, virtual when a test has moved the clock: {{ now.Format "2006-01-02" }}
+		"now": now,
+		// uuid is a new random UUID each call
+		"uuid": func() string { return uuid.NewString() },
+		// json encodes a value, such as an object from the request body, as JSON
+		"json": func(value interface{}) (string, error) {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err
+		},
+	}
+}
+
+// templated reports whether an interaction's response body is rendered as a template, as
+// mock.templating has every one rendered or the recording's metadata asks
+func (m *MockEngine) templated(interaction *storage.Interaction) bool {
+	if interaction.IsStreaming || interaction.ResponseBodyFile() != "" {
+		return false
+	}
+	return (m.mockConfig != nil && m.mockConfig.Templating) || interaction.Templated()
+}
+
+// renderTemplate fills a response body's placeholders from the request it answers
+func (m *MockEngine) renderTemplate(body []byte, r *http.Request) ([]byte, error) {
+	if !bytes.Contains(body, []byte("{{")) {
+		return body, nil
+	}
+	tmpl, err := template.New("response").Funcs(templateFuncs(m.now)).Parse(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse response template: %w", err)
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, newTemplateRequest(r)); err != nil {
+		return nil, fmt.Errorf("failed to render response template: %w", err)
+	}
+	return rendered.Bytes(), nil
+}
+
+func newTemplateRequest(r *http.Request) templateRequest {
+	data := templateRequest{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Query:   r.URL.Query(),
+		Headers: r.Header,
+		Form:    url.Values{},
+	}
+	for _, segment := range strings.Split(strings.Trim(r.URL.Path, "/"), "/") {
+		if segment != "" {
+			data.Segments = append(data.Segments, segment)
+		}
+	}
+
+	if r.Body == nil {
+		return data
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return data
+	}
+	r.Body = io.NopCloser(bytes.NewBuffer(body))
+
+	body = plainBody(body, r.Header)
+	data.RawBody = string(body)
+	if json.Unmarshal(body, &data.Body) != nil {
+		data.Body = nil
+	}
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err == nil && mediaType == "application/x-www-form-urlencoded" {
+		if form, err := url.ParseQuery(string(body)); err == nil {
+			data.Form = form
+		}
+	}
+	return data
+}
@@ -0,0 +1,155 @@
+package mock
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"mimic/storage"
+)
+
+// rfc3339Pattern finds RFC 3339 timestamps in a body, such as 2024-05-01T12:00:00.123Z
+var rfc3339Pattern = regexp.MustCompile(`\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})`)
+
+// cookieExpiresPattern finds the Expires attribute of a Set-Cookie header
+var cookieExpiresPattern = regexp.MustCompile(`(?i)(expires=)([^;]+)`)
+
+// Unix times further than this from the recording are not taken for times of it
+const epochWindow = 10 * 365 * 24 * time.Hour
+
+// timeShifted reports whether the dates in a recording move with the time since it was recorded
+func (m *MockEngine) timeShifted(interaction *storage.Interaction) bool {
+	return (m.mockConfig != nil && m.mockConfig.TimeShift.Enabled) || interaction.TimeShifted()
+}
+
+// recordedAt is when an interaction was recorded: when its request started, if that was kept
+func recordedAt(interaction *storage.Interaction) time.Time {
+	if startedAt, _, ok := interaction.Timing(); ok {
+		return startedAt
+	}
+	return interaction.Timestamp
+}
+
+// applyTimeShift returns the interaction with the dates in its response headers and body moved
+// by the time between its recording and mimic's clock, or the interaction itself when its dates
+// stay as recorded
+func (m *MockEngine) applyTimeShift(interaction *storage.Interaction) (*storage.Interaction, error) {
+	recorded := recordedAt(interaction)
+	if !m.timeShifted(interaction) || recorded.IsZero() {
+		return interaction, nil
+	}
+	shift := m.now().Sub(recorded)
+
+	headers := make(map[string]string)
+	if interaction.ResponseHeaders != "" {
+		if err := json.Unmarshal([]byte(interaction.ResponseHeaders), &headers); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response headers: %w", err)
+		}
+	}
+	for name, value := range headers {
+		headers[name] = shiftHeader(name, value, shift)
+	}
+
+	served := *interaction
+	// Bodies kept in body files and streamed responses are served as recorded
+	if !interaction.IsStreaming && interaction.ResponseBodyFile() == "" && len(interaction.ResponseBody) > 0 {
+		body := shiftTimestamps(interaction.ResponseBody, shift)
+		if m.mockConfig != nil {
+			body = shiftEpochFields(body, m.mockConfig.TimeShift.EpochFields, recorded, shift)
+		}
+		served.ResponseBody = body
+		if removeHeader(headers, "Content-Length") {
+			headers["Content-Length"] = strconv.Itoa(len(body))
+		}
+	}
+
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response headers: %w", err)
+	}
+	served.ResponseHeaders = string(encoded)
+	return &served, nil
+}
+
+// shiftHeader moves a header value that is an HTTP date, or the expiry of a cookie
+func shiftHeader(name, value string, shift time.Duration) string {
+	if strings.EqualFold(name, "Set-Cookie") {
+		return cookieExpiresPattern.ReplaceAllStringFunc(value, func(attribute string) string {
+			parts := cookieExpiresPattern.FindStringSubmatch(attribute)
+			expires, err := parseCookieTime(strings.TrimSpace(parts[2]))
+			if err != nil {
+				return attribute
+			}
+			return parts[1] + expires.Add(shift).UTC().Format(http.TimeFormat)
+		})
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return date.Add(shift).UTC().Format(http.TimeFormat)
+	}
+	return value
+}
+
+// parseCookieTime parses a cookie expiry, which some servers write with dashes in the date
+func parseCookieTime(value string) (time.Time, error) {
+	if t, err := http.ParseTime(value); err == nil {
+		return t, nil
+	}
+	return time.Parse("Mon, 02-Jan-2006 15:04:05 MST", value)
+}
+
+// shiftTimestamps moves every RFC 3339 timestamp in a body, keeping each one's precision and zone
+func shiftTimestamps(body []byte, shift time.Duration) []byte {
+	return rfc3339Pattern.ReplaceAllFunc(body, func(match []byte) []byte {
+		t, err := time.Parse(time.RFC3339Nano, string(match))
+		if err != nil {
+			return match
+		}
+		layout := "2006-01-02T15:04:05"
+		if dot := rfc3339Pattern.FindSubmatch(match)[1]; len(dot) > 0 {
+			layout += "." + strings.Repeat("0", len(dot)-1)
+		}
+		if match[len(match)-1] == 'Z' {
+			layout += "Z07:00"
+		} else {
+			layout += "-07:00"
+		}
+		return []byte(t.Add(shift).Format(layout))
+	})
+}
+
+// shiftEpochFields moves the Unix times, in seconds or milliseconds, held by the named fields of a
+// JSON body, wherever they appear in it. Numbers too far from the recording to be times of it are
+// left alone.
+func shiftEpochFields(body []byte, fields []string, recorded time.Time, shift time.Duration) []byte {
+	for _, field := range fields {
+		pattern, err := regexp.Compile(`("` + regexp.QuoteMeta(field) + `"\s*:\s*)(\d{10}|\d{13})\b`)
+		if err != nil {
+			continue
+		}
+		body = pattern.ReplaceAllFunc(body, func(match []byte) []byte {
+			parts := pattern.FindSubmatch(match)
+			n, err := strconv.ParseInt(string(parts[2]), 10, 64)
+			if err != nil {
+				return match
+			}
+			millis := len(parts[2]) == 13
+			t := time.Unix(n, 0)
+			if millis {
+				t = time.UnixMilli(n)
+			}
+			if gap := t.Sub(recorded); gap > epochWindow || gap < -epochWindow {
+				return match
+			}
+			shifted := t.Add(shift).Unix()
+			if millis {
+				shifted = t.Add(shift).UnixMilli()
+			}
+			return []byte(string(parts[1]) + strconv.FormatInt(shifted, 10))
+		})
+	}
+	return body
+}
@@ -0,0 +1,158 @@
+package mock
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+)
+
+// xmlNode is an element of an XML body, reduced to what fuzzy matching compares
+type xmlNode struct {
+	name     xml.Name
+	attrs    map[xml.Name]string // Without namespace declarations
+	children []*xmlNode
+	text     string // Character data directly inside the element, trimmed
+}
+
+// isXMLContentType reports whether a Content-Type is XML, such as text/xml for SOAP 1.1,
+// application/soap+xml for SOAP 1.2, or application/xml
+func isXMLContentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/xml" || mediaType == "text/xml" || strings.HasSuffix(mediaType, "+xml")
+}
+
+// parseXML reads an XML body into its root element. Comments, processing instructions, and
+// whitespace between elements are dropped, and names carry their namespace URI rather than
+// their prefix.
+func parseXML(body []byte) (*xmlNode, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(body))
+	var root *xmlNode
+	var stack []*xmlNode
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			node := &xmlNode{name: t.Name, attrs: make(map[xml.Name]string)}
+			for _, attr := range t.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					continue
+				}
+				node.attrs[attr.Name] = attr.Value
+			}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, node)
+			} else if root != nil {
+				return nil, fmt.Errorf("more than one root element")
+			} else {
+				root = node
+			}
+			stack = append(stack, node)
+		case xml.EndElement:
+			node := stack[len(stack)-1]
+			node.text = strings.TrimSpace(node.text)
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text += string(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, fmt.Errorf("no root element")
+	}
+	return root, nil
+}
+
+// fuzzyMatchXML compares XML bodies by structure, the way fuzzyMatchJSON compares JSON. Elements
+// named in fuzzy_ignore_fields, by name or by a path of names from the root, are skipped, and
+// fuzzy-unordered lets sibling elements come in any order. Bodies that are not well-formed XML
+// must be equal.
+func (m *MockEngine) fuzzyMatchXML(recordedBody, currentBody []byte) bool {
+	recorded, recordedErr := parseXML(recordedBody)
+	current, currentErr := parseXML(currentBody)
+	if recordedErr != nil || currentErr != nil {
+		return bytes.Equal(recordedBody, currentBody)
+	}
+	unordered := m.mockConfig.MatchingStrategy == "fuzzy-unordered"
+	return m.fuzzyMatchXMLNode(recorded, current, unordered, nil)
+}
+
+func (m *MockEngine) fuzzyMatchXMLNode(recorded, current *xmlNode, unordered bool, parent []string) bool {
+	if recorded.name != current.name {
+		return false
+	}
+	path := appendPath(parent, recorded.name.Local)
+	if m.shouldIgnoreField(path) {
+		return true
+	}
+
+	if len(recorded.attrs) != len(current.attrs) {
+		return false
+	}
+	for name, value := range recorded.attrs {
+		other, exists := current.attrs[name]
+		if !exists {
+			return false
+		}
+		if !m.shouldIgnoreField([]string{name.Local}) && !m.xmlValuesMatch(value, other) {
+			return false
+		}
+	}
+	if !m.xmlValuesMatch(recorded.text, current.text) {
+		return false
+	}
+
+	if len(recorded.children) != len(current.children) {
+		return false
+	}
+	if !unordered {
+		for i, child := range recorded.children {
+			if !m.fuzzyMatchXMLNode(child, current.children[i], unordered, path) {
+				return false
+			}
+		}
+		return true
+	}
+
+	// Each recorded element must match a different current one
+	matched := make([]bool, len(current.children))
+	for _, child := range recorded.children {
+		found := false
+		for j, other := range current.children {
+			if !matched[j] && m.fuzzyMatchXMLNode(child, other, unordered, path) {
+				matched[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// xmlValuesMatch compares text or attribute values, with UUIDs normalized and numbers compared
+// within the numeric tolerances
+func (m *MockEngine) xmlValuesMatch(recorded, current string) bool {
+	if normalizeStringValue(recorded) == normalizeStringValue(current) {
+		return true
+	}
+	recordedNum, recordedErr := strconv.ParseFloat(recorded, 64)
+	currentNum, currentErr := strconv.ParseFloat(current, 64)
+	return recordedErr == nil && currentErr == nil && m.numbersMatch(recordedNum, currentNum)
+}
@@ -0,0 +1,137 @@
+// Package nearmiss keeps the requests mock proxies could not answer, each with the recordings
+// that came closest to matching and what kept them from it.
+package nearmiss
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCapacity is how many misses the default log keeps; older misses are dropped first
+const DefaultCapacity = 200
+
+// MaxCandidates is how many of the closest recordings are kept for each miss
+const MaxCandidates = 3
+
+// Parts of a request a difference can be in
+const (
+	PartMethod = "method"
+	PartPath   = "path"
+	PartQuery  = "query"
+	PartHeader = "header"
+	PartBody   = "body"
+	PartState  = "state" // The state a recording's scenario must be in
+)
+
+// Difference is one reason a recording did not match. Recorded and Actual are left out when the
+// field is missing from that side.
+type Difference struct {
+	Part     string      `json:"part"`
+	Field    string      `json:"field,omitempty"` // Param or header name, or a JSON path such as $.user.id
+	Recorded interface{} `json:"recorded,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+	Message  string      `json:"message"`
+}
+
+// String describes the difference, e.g. `body $.user.id: recorded "a", got "b"`
+func (d Difference) String() string {
+	if d.Field == "" {
+		return fmt.Sprintf("%s: %s", d.Part, d.Message)
+	}
+	return fmt.Sprintf("%s %s: %s", d.Part, d.Field, d.Message)
+}
+
+// Candidate is a recording that came close to matching a missed request
+type Candidate struct {
+	InteractionID  int          `json:"interaction_id"`
+	RequestID      string       `json:"request_id"`
+	Method         string       `json:"method"`
+	Endpoint       string       `json:"endpoint"`
+	SequenceNumber int          `json:"sequence_number"`
+	Differences    []Difference `json:"differences"`
+}
+
+// Miss is a request a mock proxy answered with not found
+type Miss struct {
+	Seq        uint64      `json:"seq"`
+	Proxy      string      `json:"proxy"`
+	Session    string      `json:"session"`
+	Time       time.Time   `json:"time"`
+	Method     string      `json:"method"`
+	Path       string      `json:"path"`
+	Query      string      `json:"query,omitempty"`
+	Reason     string      `json:"reason"`
+	Candidates []Candidate `json:"candidates"` // Closest first
+}
+
+// Summary describes the miss and its closest candidate on one line, for the log
+func (m Miss) Summary() string {
+	summary := fmt.Sprintf("%s %s: %s", m.Method, m.Path, m.Reason)
+	if len(m.Candidates) == 0 {
+		return summary
+	}
+	closest := m.Candidates[0]
+	differences := make([]string, len(closest.Differences))
+	for i, difference := range closest.Differences {
+		differences[i] = difference.String()
+	}
+	return fmt.Sprintf("%s; closest recording %d (%s %s) differs in %s", summary, closest.InteractionID,
+		closest.Method, closest.Endpoint, strings.Join(differences, "; "))
+}
+
+// Log holds the most recent misses
+type Log struct {
+	mutex    sync.RWMutex
+	misses   []Miss
+	next     uint64
+	capacity int
+}
+
+// Default is the log the mock proxies record misses into
+var Default = NewLog(DefaultCapacity)
+
+func NewLog(capacity int) *Log {
+	return &Log{capacity: capacity}
+}
+
+// Record adds a miss, numbering and timestamping it
+func (l *Log) Record(miss *Miss) {
+	miss.Time = time.Now()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.next++
+	miss.Seq = l.next
+	l.misses = append(l.misses, *miss)
+	if excess := len(l.misses) - l.capacity; excess > 0 {
+		l.misses = l.misses[excess:]
+	}
+}
+
+// Misses returns the kept misses, oldest first, limited to a proxy and session when given
+func (l *Log) Misses(proxyName, sessionName string) []Miss {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	misses := []Miss{}
+	for _, miss := range l.misses {
+		if (proxyName == "" || miss.Proxy == proxyName) && (sessionName == "" || miss.Session == sessionName) {
+			misses = append(misses, miss)
+		}
+	}
+	return misses
+}
+
+// Reset forgets the kept misses
+func (l *Log) Reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.misses = nil
+}
+
+// Record adds a miss to the default log
+func Record(miss *Miss) {
+	Default.Record(miss)
+}
@@ -0,0 +1,49 @@
+package nearmiss
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLogKeepsLatestMisses(t *testing.T) {
+	log := NewLog(2)
+	log.Record(&Miss{Proxy: "api", Session: "one", Method: "GET", Path: "/a"})
+	log.Record(&Miss{Proxy: "api", Session: "two", Method: "GET", Path: "/b"})
+	log.Record(&Miss{Proxy: "billing", Session: "two", Method: "GET", Path: "/c"})
+
+	misses := log.Misses("", "")
+	if len(misses) != 2 || misses[0].Path != "/b" || misses[1].Path != "/c" || misses[1].Seq != 3 {
+		t.Fatalf("Expected the two latest misses, got %+v", misses)
+	}
+	if misses := log.Misses("api", "two"); len(misses) != 1 || misses[0].Path != "/b" {
+		t.Errorf("Expected the miss of proxy api in session two, got %+v", misses)
+	}
+
+	log.Reset()
+	if misses := log.Misses("", ""); len(misses) != 0 {
+		t.Errorf("Expected no misses after a reset, got %+v", misses)
+	}
+}
+
+func TestMissSummary(t *testing.T) {
+	miss := Miss{
+		Method: "POST",
+		Path:   "/users",
+		Reason: "no recording matches the query, headers, and body",
+		Candidates: []Candidate{{
+			InteractionID: 7,
+			Method:        "POST",
+			Endpoint:      "/users",
+			Differences: []Difference{
+				{Part: PartBody, Field: "$.user.id", Message: `recorded "a", got "b"`},
+				{Part: PartHeader, Field: "X-Tenant", Message: `recorded "acme", missing from the request`},
+			},
+		}},
+	}
+	summary := miss.Summary()
+	for _, expected := range []string{"POST /users", "closest recording 7", `body $.user.id: recorded "a", got "b"`, "header X-Tenant"} {
+		if !strings.Contains(summary, expected) {
+			t.Errorf("Expected %q in the summary, got %s", expected, summary)
+		}
+	}
+}
@@ -0,0 +1,282 @@
+// Package pii finds likely personal data in recorded sessions, such as emails, phone numbers,
+// card numbers, and JWTs, and suggests redact patterns that would keep it out of recordings.
+package pii
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mimic/storage"
+)
+
+// Kinds of personal data the scanner looks for
+const (
+	KindEmail      = "email"
+	KindPhone      = "phone"
+	KindCreditCard = "credit_card"
+	KindJWT        = "jwt"
+)
+
+// Replacement is what redaction puts in place of a match, as recording redaction does
+const Replacement = "[REDACTED]"
+
+// detector finds one kind of PII. pattern doubles as the suggested redact pattern; valid weeds
+// out matches the pattern alone cannot, like numbers failing the card checksum.
+type detector struct {
+	kind    string
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+	mask    func(match string) string
+}
+
+var detectors = []detector{
+	{
+		kind:    KindEmail,
+		pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`),
+		mask: func(match string) string {
+			local, domain, _ := strings.Cut(match, "@")
+			return local[:1] + "***@" + domain
+		},
+	},
+	{
+		kind:    KindJWT,
+		pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		mask: func(match string) string {
+			return match[:10] + "…"
+		},
+	},
+	{
+		kind:    KindCreditCard,
+		pattern: regexp.MustCompile(`\b[3-6]\d{3}(?:[ -]?\d{4}){2}[ -]?\d{1,7}\b`),
+		valid: func(match string) bool {
+			digits := onlyDigits(match)
+			return len(digits) >= 13 && len(digits) <= 19 && luhn(digits)
+		},
+		mask: func(match string) string {
+			digits := onlyDigits(match)
+			return "**** " + digits[len(digits)-4:]
+		},
+	},
+	{
+		kind:    KindPhone,
+		pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{3}\) ?|\b\d{3}[ .-])\d{3}[ .-]\d{4}\b|\+\d{10,14}\b`),
+		mask: func(match string) string {
+			digits := onlyDigits(match)
+			return "***" + digits[len(digits)-4:]
+		},
+	},
+}
+
+// Kinds lists the kinds of PII the scanner looks for
+func Kinds() []string {
+	kinds := make([]string, len(detectors))
+	for i, d := range detectors {
+		kinds[i] = d.kind
+	}
+	return kinds
+}
+
+// ValidateKinds rejects kinds the scanner does not know
+func ValidateKinds(kinds []string) error {
+	for _, kind := range kinds {
+		if findDetector(kind) == nil {
+			return fmt.Errorf("unknown PII kind %q (expected one of %s)", kind, strings.Join(Kinds(), ", "))
+		}
+	}
+	return nil
+}
+
+func findDetector(kind string) *detector {
+	for i := range detectors {
+		if detectors[i].kind == kind {
+			return &detectors[i]
+		}
+	}
+	return nil
+}
+
+// Finding is one kind of PII found in one part of an interaction
+type Finding struct {
+	InteractionID int    `json:"interaction_id"`
+	Method        string `json:"method"`
+	Endpoint      string `json:"endpoint"`
+	Kind          string `json:"kind"`
+	Location      string `json:"location"` // request_headers, request_body, response_headers, response_body, or stream
+	Count         int    `json:"count"`
+	Sample        string `json:"sample"` // The first match, masked
+}
+
+// Suggestion is a redact pattern that would mask one kind of PII found in the session
+type Suggestion struct {
+	Kind         string `json:"kind"`
+	Pattern      string `json:"pattern"` // For recording.redact_patterns
+	Matches      int    `json:"matches"`
+	Interactions int    `json:"interactions"`
+}
+
+// Report is what a scan found in a session
+type Report struct {
+	Session      string       `json:"session"`
+	Interactions int          `json:"interactions"`
+	Findings     []Finding    `json:"findings"`
+	Suggestions  []Suggestion `json:"suggestions"`
+}
+
+// RedactResult is what redacting a session changed
+type RedactResult struct {
+	Session      string   `json:"session"`
+	Kinds        []string `json:"kinds"`
+	Interactions int      `json:"interactions"` // Interactions that had something redacted
+}
+
+// ScanSession looks for PII in the headers, bodies, and stream chunks of a session
+func ScanSession(db *storage.Database, sessionName string) (*Report, error) {
+	session, err := db.GetSession(sessionName)
+	if err != nil {
+		return nil, err
+	}
+	interactions, err := db.GetInteractionsBySession(session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{Session: sessionName, Interactions: len(interactions), Findings: []Finding{}, Suggestions: []Suggestion{}}
+	for _, interaction := range interactions {
+		parts := []struct{ location, text string }{
+			{"request_headers", interaction.RequestHeaders},
+			{"request_body", string(interaction.RequestBody)},
+			{"response_headers", interaction.ResponseHeaders},
+			{"response_body", string(interaction.ResponseBody)},
+		}
+		if interaction.IsStreaming {
+			chunks, err := db.GetStreamChunks(interaction.ID)
+			if err != nil {
+				return nil, err
+			}
+			var stream strings.Builder
+			for _, chunk := range chunks {
+				stream.Write(chunk.Data)
+			}
+			parts = append(parts, struct{ location, text string }{"stream", stream.String()})
+		}
+
+		for _, part := range parts {
+			for _, d := range detectors {
+				matches := d.find(part.text)
+				if len(matches) == 0 {
+					continue
+				}
+				report.Findings = append(report.Findings, Finding{
+					InteractionID: interaction.ID,
+					Method:        interaction.Method,
+					Endpoint:      interaction.Endpoint,
+					Kind:          d.kind,
+					Location:      part.location,
+					Count:         len(matches),
+					Sample:        d.mask(matches[0]),
+				})
+			}
+		}
+	}
+
+	report.Suggestions = suggest(report.Findings)
+	return report, nil
+}
+
+// suggest totals the findings into one redact pattern per kind found
+func suggest(findings []Finding) []Suggestion {
+	suggestions := []Suggestion{}
+	for _, d := range detectors {
+		suggestion := Suggestion{Kind: d.kind, Pattern: d.pattern.String()}
+		interactions := make(map[int]bool)
+		for _, finding := range findings {
+			if finding.Kind == d.kind {
+				suggestion.Matches += finding.Count
+				interactions[finding.InteractionID] = true
+			}
+		}
+		if suggestion.Matches > 0 {
+			suggestion.Interactions = len(interactions)
+			suggestions = append(suggestions, suggestion)
+		}
+	}
+	return suggestions
+}
+
+// RedactSession replaces the given kinds of PII in a session's headers, bodies, and stream chunks
+// with Replacement, in place. Without kinds, every kind is redacted.
+func RedactSession(db *storage.Database, sessionName string, kinds []string) (*RedactResult, error) {
+	if err := ValidateKinds(kinds); err != nil {
+		return nil, err
+	}
+	if len(kinds) == 0 {
+		kinds = Kinds()
+	}
+	sort.Strings(kinds)
+
+	selected := make([]*detector, len(kinds))
+	for i, kind := range kinds {
+		selected[i] = findDetector(kind)
+	}
+	changed, err := db.RewriteSession(sessionName, func(text string) string {
+		for _, d := range selected {
+			text = d.redact(text)
+		}
+		return text
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to redact session: %w", err)
+	}
+	return &RedactResult{Session: sessionName, Kinds: kinds, Interactions: changed}, nil
+}
+
+// find returns the valid matches in text
+func (d *detector) find(text string) []string {
+	var matches []string
+	for _, match := range d.pattern.FindAllString(text, -1) {
+		if d.valid == nil || d.valid(match) {
+			matches = append(matches, match)
+		}
+	}
+	return matches
+}
+
+// redact replaces the valid matches in text
+func (d *detector) redact(text string) string {
+	return d.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if d.valid != nil && !d.valid(match) {
+			return match
+		}
+		return Replacement
+	})
+}
+
+func onlyDigits(s string) string {
+	var digits strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			digits.WriteRune(r)
+		}
+	}
+	return digits.String()
+}
+
+// luhn reports whether a card number passes its checksum
+func luhn(digits string) bool {
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := int(digits[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,134 @@
+package protoschema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// BufTokenEnv names the variable holding the token for private Buf modules, as the buf CLI does
+const BufTokenEnv = "BUF_TOKEN"
+
+// bufFetchTimeout bounds a registry request made without a deadline
+const bufFetchTimeout = 30 * time.Second
+
+// BufModule is a module in a Buf Schema Registry, written remote/owner/module[:ref]
+type BufModule struct {
+	Remote string // e.g. buf.build
+	Owner  string
+	Module string
+	Ref    string // Label, commit, or tag; the default label when empty
+}
+
+// ParseBufModule parses a module reference such as buf.build/acme/payments:main
+func ParseBufModule(name string) (BufModule, error) {
+	parts := strings.Split(name, "/")
+	if len(parts) != 3 {
+		return BufModule{}, fmt.Errorf("invalid buf module %q: expected remote/owner/module[:ref]", name)
+	}
+	module, ref, _ := strings.Cut(parts[2], ":")
+	if parts[0] == "" || parts[1] == "" || module == "" {
+		return BufModule{}, fmt.Errorf("invalid buf module %q: expected remote/owner/module[:ref]", name)
+	}
+	return BufModule{Remote: parts[0], Owner: parts[1], Module: module, Ref: ref}, nil
+}
+
+func (m BufModule) String() string {
+	name := m.Remote + "/" + m.Owner + "/" + m.Module
+	if m.Ref != "" {
+		name += ":" + m.Ref
+	}
+	return name
+}
+
+// bufRequest is the Connect JSON body of FileDescriptorSetService.GetFileDescriptorSet
+type bufRequest struct {
+	ResourceRef struct {
+		Name struct {
+			Owner  string `json:"owner"`
+			Module string `json:"module"`
+			Ref    string `json:"ref,omitempty"`
+		} `json:"name"`
+	} `json:"resourceRef"`
+}
+
+// FetchBufModule downloads the FileDescriptorSet of a module, imports included, from its
+// registry's API. client defaults to http.DefaultClient; token may be empty for public modules.
+func FetchBufModule(ctx context.Context, client *http.Client, name, token string) (*descriptorpb.FileDescriptorSet, error) {
+	module, err := ParseBufModule(name)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, bufFetchTimeout)
+		defer cancel()
+	}
+
+	var body bufRequest
+	body.ResourceRef.Name.Owner = module.Owner
+	body.ResourceRef.Name.Module = module.Module
+	body.ResourceRef.Name.Ref = module.Ref
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	url := "https://" + module.Remote + "/buf.registry.module.v1.FileDescriptorSetService/GetFileDescriptorSet"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connect-Protocol-Version", "1")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach %s: %w", module.Remote, err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response from %s: %w", module.Remote, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Connect errors are {"code": "...", "message": "..."}
+		var connectErr struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(data, &connectErr) == nil && connectErr.Message != "" {
+			return nil, fmt.Errorf("%s answered %s: %s", module.Remote, connectErr.Code, connectErr.Message)
+		}
+		return nil, fmt.Errorf("%s answered %s", module.Remote, resp.Status)
+	}
+
+	var response struct {
+		FileDescriptorSet json.RawMessage `json:"fileDescriptorSet"`
+	}
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response from %s: %w", module.Remote, err)
+	}
+	if len(response.FileDescriptorSet) == 0 {
+		return nil, fmt.Errorf("%s returned no descriptors for %s", module.Remote, module)
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(response.FileDescriptorSet, set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptors of %s: %w", module, err)
+	}
+	return set, nil
+}
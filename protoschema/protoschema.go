@@ -0,0 +1,259 @@
+// Package protoschema holds the protobuf descriptors mimic uses to decode gRPC messages, loaded at
+// startup from compiled FileDescriptorSets (buf build -o, protoc --descriptor_set_out) or fetched
+// from a Buf Schema Registry module, so no .proto sources need to be on disk.
+package protoschema
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	// The well-known types, for descriptor sets built without their imports
+	_ "google.golang.org/protobuf/types/known/anypb"
+	_ "google.golang.org/protobuf/types/known/durationpb"
+	_ "google.golang.org/protobuf/types/known/emptypb"
+	_ "google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "google.golang.org/protobuf/types/known/structpb"
+	_ "google.golang.org/protobuf/types/known/timestamppb"
+	_ "google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// Direction selects a method's input or output message
+type Direction int
+
+const (
+	Request Direction = iota
+	Response
+)
+
+// Registry resolves gRPC methods to their message types
+type Registry struct {
+	mutex    sync.RWMutex
+	files    map[string]*descriptorpb.FileDescriptorProto // By path, first one loaded wins
+	types    *protoregistry.Files
+	messages *protoregistry.Types // Resolves the types named in google.protobuf.Any fields
+}
+
+// Default is the registry the proxies, exports, and admin API decode with
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{files: make(map[string]*descriptorpb.FileDescriptorProto), types: new(protoregistry.Files), messages: new(protoregistry.Types)}
+}
+
+// LoadFile reads a binary FileDescriptorSet from path
+func LoadFile(path string) (*descriptorpb.FileDescriptorSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(data, set); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptor set %s: %w", path, err)
+	}
+	return set, nil
+}
+
+// Add merges the files of set into the registry. Imports missing from the set, such as the
+// well-known types left out by buf build --exclude-imports, are taken from those compiled into mimic.
+func (r *Registry) Add(set *descriptorpb.FileDescriptorSet) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	merged := make(map[string]*descriptorpb.FileDescriptorProto, len(r.files)+len(set.GetFile()))
+	for name, file := range r.files {
+		merged[name] = file
+	}
+	for _, file := range set.GetFile() {
+		if _, ok := merged[file.GetName()]; !ok {
+			merged[file.GetName()] = file
+		}
+	}
+	for _, file := range set.GetFile() {
+		addBuiltinImports(merged, file)
+	}
+
+	combined := &descriptorpb.FileDescriptorSet{}
+	for _, file := range merged {
+		combined.File = append(combined.File, file)
+	}
+	types, err := protodesc.NewFiles(combined)
+	if err != nil {
+		return fmt.Errorf("failed to resolve descriptors: %w", err)
+	}
+	r.files, r.types, r.messages = merged, types, messageTypes(types)
+	return nil
+}
+
+// addBuiltinImports adds the imports of file that are compiled into mimic and not yet in files
+func addBuiltinImports(files map[string]*descriptorpb.FileDescriptorProto, file *descriptorpb.FileDescriptorProto) {
+	for _, dependency := range file.GetDependency() {
+		if _, ok := files[dependency]; ok {
+			continue
+		}
+		builtin, err := protoregistry.GlobalFiles.FindFileByPath(dependency)
+		if err != nil {
+			continue // protodesc reports it as missing
+		}
+		files[dependency] = protodesc.ToFileDescriptorProto(builtin)
+		addBuiltinImports(files, files[dependency])
+	}
+}
+
+// Reset forgets every descriptor
+func (r *Registry) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.files, r.types, r.messages = make(map[string]*descriptorpb.FileDescriptorProto), new(protoregistry.Files), new(protoregistry.Types)
+}
+
+// Services lists the full names of the services the registry knows, sorted
+func (r *Registry) Services() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	services := []string{}
+	r.types.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		for i := 0; i < file.Services().Len(); i++ {
+			services = append(services, string(file.Services().Get(i).FullName()))
+		}
+		return true
+	})
+	sort.Strings(services)
+	return services
+}
+
+// Method finds a method by its gRPC path, e.g. /acme.payments.v1.Payments/Charge
+func (r *Registry) Method(fullMethod string) (protoreflect.MethodDescriptor, bool) {
+	service, method, ok := strings.Cut(strings.TrimPrefix(fullMethod, "/"), "/")
+	if !ok {
+		return nil, false
+	}
+
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	descriptor, err := r.types.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, false
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, false
+	}
+	methodDescriptor := serviceDescriptor.Methods().ByName(protoreflect.Name(method))
+	return methodDescriptor, methodDescriptor != nil
+}
+
+// message returns the input or output type of a method
+func (r *Registry) message(fullMethod string, direction Direction) (protoreflect.MessageDescriptor, error) {
+	method, ok := r.Method(fullMethod)
+	if !ok {
+		return nil, fmt.Errorf("no descriptor for %s", fullMethod)
+	}
+	if direction == Response {
+		return method.Output(), nil
+	}
+	return method.Input(), nil
+}
+
+// Decode renders a method's request or response message as protobuf JSON
+func (r *Registry) Decode(fullMethod string, direction Direction, data []byte) (json.RawMessage, error) {
+	descriptor, err := r.message(fullMethod, direction)
+	if err != nil {
+		return nil, err
+	}
+	message := dynamicpb.NewMessage(descriptor)
+	if err := proto.Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", descriptor.FullName(), err)
+	}
+	// protojson varies its spacing on purpose; compacting makes the output stable
+	encoded, err := protojson.MarshalOptions{Resolver: r.resolver()}.Marshal(message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %w", descriptor.FullName(), err)
+	}
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, encoded); err != nil {
+		return nil, err
+	}
+	return compacted.Bytes(), nil
+}
+
+// Encode builds a method's request or response message from protobuf JSON
+func (r *Registry) Encode(fullMethod string, direction Direction, data []byte) ([]byte, error) {
+	descriptor, err := r.message(fullMethod, direction)
+	if err != nil {
+		return nil, err
+	}
+	message := dynamicpb.NewMessage(descriptor)
+	if err := (protojson.UnmarshalOptions{Resolver: r.resolver()}).Unmarshal(data, message); err != nil {
+		return nil, fmt.Errorf("failed to encode %s: %w", descriptor.FullName(), err)
+	}
+	return proto.Marshal(message)
+}
+
+// resolver returns the message types of the registry
+func (r *Registry) resolver() *protoregistry.Types {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.messages
+}
+
+// messageTypes collects the message types declared in files
+func messageTypes(files *protoregistry.Files) *protoregistry.Types {
+	types := new(protoregistry.Types)
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		registerMessages(types, file.Messages())
+		return true
+	})
+	return types
+}
+
+func registerMessages(types *protoregistry.Types, messages protoreflect.MessageDescriptors) {
+	for i := 0; i < messages.Len(); i++ {
+		types.RegisterMessage(dynamicpb.NewMessageType(messages.Get(i)))
+		registerMessages(types, messages.Get(i).Messages())
+	}
+}
+
+// Load fills the registry from descriptor set files and Buf modules, keeping what loads and
+// returning an error for each source that does not
+func (r *Registry) Load(ctx context.Context, descriptorSets, bufModules []string) []error {
+	var errs []error
+	for _, path := range descriptorSets {
+		set, err := LoadFile(path)
+		if err == nil {
+			err = r.Add(set)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("descriptor set %s: %w", path, err))
+		}
+	}
+	for _, name := range bufModules {
+		set, err := FetchBufModule(ctx, nil, name, os.Getenv(BufTokenEnv))
+		if err == nil {
+			err = r.Add(set)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("buf module %s: %w", name, err))
+		}
+	}
+	return errs
+}
+
+// Load fills the default registry
+func Load(ctx context.Context, descriptorSets, bufModules []string) []error {
+	return Default.Load(ctx, descriptorSets, bufModules)
+}
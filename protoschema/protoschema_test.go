@@ -0,0 +1,156 @@
+package protoschema
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// greeterSet describes test.v1.Greeter/SayHello. It imports timestamp.proto without including it,
+// as buf build --exclude-imports would.
+func greeterSet() *descriptorpb.FileDescriptorSet {
+	field := func(name string, number int32, kind descriptorpb.FieldDescriptorProto_Type, typeName string) *descriptorpb.FieldDescriptorProto {
+		f := &descriptorpb.FieldDescriptorProto{
+			Name:     proto.String(name),
+			JsonName: proto.String(name),
+			Number:   proto.Int32(number),
+			Label:    descriptorpb.FieldDescriptorProto_LABEL_OPTIONAL.Enum(),
+			Type:     kind.Enum(),
+		}
+		if typeName != "" {
+			f.TypeName = proto.String(typeName)
+		}
+		return f
+	}
+	return &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{{
+		Name:       proto.String("test/v1/greeter.proto"),
+		Package:    proto.String("test.v1"),
+		Syntax:     proto.String("proto3"),
+		Dependency: []string{"google/protobuf/timestamp.proto"},
+		MessageType: []*descriptorpb.DescriptorProto{
+			{Name: proto.String("HelloRequest"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("name", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+			}},
+			{Name: proto.String("HelloReply"), Field: []*descriptorpb.FieldDescriptorProto{
+				field("message", 1, descriptorpb.FieldDescriptorProto_TYPE_STRING, ""),
+				field("sent", 2, descriptorpb.FieldDescriptorProto_TYPE_MESSAGE, ".google.protobuf.Timestamp"),
+			}},
+		},
+		Service: []*descriptorpb.ServiceDescriptorProto{{
+			Name: proto.String("Greeter"),
+			Method: []*descriptorpb.MethodDescriptorProto{{
+				Name:       proto.String("SayHello"),
+				InputType:  proto.String(".test.v1.HelloRequest"),
+				OutputType: proto.String(".test.v1.HelloReply"),
+			}},
+		}},
+	}}}
+}
+
+func TestDecodeAndEncode(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.Add(greeterSet()); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if services := registry.Services(); len(services) != 1 || services[0] != "test.v1.Greeter" {
+		t.Errorf("Services() = %v, want [test.v1.Greeter]", services)
+	}
+
+	const method = "/test.v1.Greeter/SayHello"
+	reply := `{"message":"hello, ada","sent":"2026-01-02T03:04:05Z"}`
+	encoded, err := registry.Encode(method, Response, []byte(reply))
+	if err != nil {
+		t.Fatalf("Encode failed: %v", err)
+	}
+	decoded, err := registry.Decode(method, Response, encoded)
+	if err != nil {
+		t.Fatalf("Decode failed: %v", err)
+	}
+	if string(decoded) != reply {
+		t.Errorf("Decode() = %s, want %s", decoded, reply)
+	}
+
+	// The request type differs, so the reply's timestamp field is unknown to it
+	if decoded, err := registry.Decode(method, Request, encoded); err != nil || string(decoded) != `{"name":"hello, ada"}` {
+		t.Errorf("Decode(Request) = %s, %v", decoded, err)
+	}
+
+	if _, err := registry.Decode("/test.v1.Greeter/SayGoodbye", Request, nil); err == nil {
+		t.Error("expected an error for an unknown method")
+	}
+	registry.Reset()
+	if _, ok := registry.Method(method); ok {
+		t.Error("expected Reset to forget the method")
+	}
+}
+
+func TestLoadDescriptorSetFile(t *testing.T) {
+	data, err := proto.Marshal(greeterSet())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "greeter.binpb")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	registry := NewRegistry()
+	errs := registry.Load(context.Background(), []string{path, filepath.Join(t.TempDir(), "missing.binpb")}, nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "missing.binpb") {
+		t.Errorf("Load errors = %v, want one for the missing file", errs)
+	}
+	if _, ok := registry.Method("/test.v1.Greeter/SayHello"); !ok {
+		t.Error("expected the method from the loaded file")
+	}
+}
+
+func TestFetchBufModule(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/buf.registry.module.v1.FileDescriptorSetService/GetFileDescriptorSet" {
+			http.NotFound(w, r)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if want := `{"resourceRef":{"name":{"owner":"acme","module":"greeter","ref":"main"}}}`; string(body) != want {
+			t.Errorf("request body = %s, want %s", body, want)
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"unauthenticated","message":"token required"}`))
+			return
+		}
+		set, _ := protojson.Marshal(greeterSet())
+		json.NewEncoder(w).Encode(map[string]interface{}{"fileDescriptorSet": json.RawMessage(set), "commit": map[string]string{"id": "abc"}})
+	}))
+	defer server.Close()
+
+	name := strings.TrimPrefix(server.URL, "https://") + "/acme/greeter:main"
+	set, err := FetchBufModule(context.Background(), server.Client(), name, "secret")
+	if err != nil {
+		t.Fatalf("FetchBufModule failed: %v", err)
+	}
+	registry := NewRegistry()
+	if err := registry.Add(set); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if _, ok := registry.Method("/test.v1.Greeter/SayHello"); !ok {
+		t.Error("expected the method from the fetched module")
+	}
+
+	if _, err := FetchBufModule(context.Background(), server.Client(), name, ""); err == nil || !strings.Contains(err.Error(), "token required") {
+		t.Errorf("expected the registry's error without a token, got %v", err)
+	}
+	if _, err := ParseBufModule("buf.build/acme"); err == nil {
+		t.Error("expected an error for a module without an owner")
+	}
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"mimic/config"
+)
+
+// defaultCircuitOpen is how long a circuit stays open when the config leaves it at zero
+const defaultCircuitOpen = 30 * time.Second
+
+// circuitProbeWait is the Retry-After given while one request tries whether the target is back
+const circuitProbeWait = time.Second
+
+// circuitBreaker stops sending requests to a target after enough failed attempts in a row. Once
+// it has been open for a while, one request tries the target again: success closes the circuit,
+// failure keeps it open for another period.
+type circuitBreaker struct {
+	mutex     sync.Mutex
+	name      string // Proxy the breaker guards, for logs
+	threshold int
+	openFor   time.Duration
+	failures  int       // Failed attempts in a row
+	openUntil time.Time // Zero while the circuit is closed
+	probing   bool      // A request is trying the target while the circuit is open
+	now       func() time.Time
+}
+
+// newCircuitBreaker reads a proxy's circuit breaker settings, or returns nil when it has none
+func newCircuitBreaker(name string, cfg config.CircuitBreakerConfig) *circuitBreaker {
+	if cfg.FailureThreshold <= 0 {
+		return nil
+	}
+	openFor := time.Duration(cfg.OpenSeconds) * time.Second
+	if openFor == 0 {
+		openFor = defaultCircuitOpen
+	}
+	return &circuitBreaker{name: name, threshold: cfg.FailureThreshold, openFor: openFor, now: time.Now}
+}
+
+// allow reports whether a request may go to the target and, when it may not, how long until it
+// might. A nil breaker allows every request.
+func (b *circuitBreaker) allow() (bool, time.Duration) {
+	if b == nil {
+		return true, 0
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.openUntil.IsZero() {
+		return true, 0
+	}
+	if wait := b.openUntil.Sub(b.now()); wait > 0 {
+		return false, wait
+	}
+	if b.probing {
+		return false, circuitProbeWait
+	}
+	b.probing = true
+	return true, 0
+}
+
+// record counts the outcome of an attempt to reach the target
+func (b *circuitBreaker) record(succeeded bool) {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.probing = false
+	if succeeded {
+		if !b.openUntil.IsZero() {
+			log.Printf("Circuit for proxy '%s' closed: the target is answering again", b.name)
+		}
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		if b.openUntil.IsZero() {
+			log.Printf("Circuit for proxy '%s' opened after %d failed attempts; failing fast for %s", b.name, b.failures, b.openFor)
+		}
+		b.openUntil = b.now().Add(b.openFor)
+	}
+}
+
+// abandon ends an attempt whose outcome says nothing about the target, such as one the client
+// cancelled, so another request can try the target
+func (b *circuitBreaker) abandon() {
+	if b == nil {
+		return
+	}
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.probing = false
+}
@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	breaker := newCircuitBreaker("payments", config.CircuitBreakerConfig{FailureThreshold: 2, OpenSeconds: 10})
+	breaker.now = func() time.Time { return now }
+
+	breaker.record(false)
+	if allowed, _ := breaker.allow(); !allowed {
+		t.Fatal("Expected the circuit to stay closed below the threshold")
+	}
+	breaker.record(false)
+	if allowed, wait := breaker.allow(); allowed || wait != 10*time.Second {
+		t.Fatalf("Expected the circuit open for 10s, got allowed=%v wait=%v", allowed, wait)
+	}
+
+	// Once the open period is over, one request tries the target
+	now = now.Add(11 * time.Second)
+	if allowed, _ := breaker.allow(); !allowed {
+		t.Fatal("Expected a probe once the circuit has been open long enough")
+	}
+	if allowed, _ := breaker.allow(); allowed {
+		t.Error("Expected only one probe at a time")
+	}
+	breaker.record(false)
+	if allowed, _ := breaker.allow(); allowed {
+		t.Error("Expected a failed probe to keep the circuit open")
+	}
+
+	now = now.Add(11 * time.Second)
+	breaker.allow()
+	breaker.record(true)
+	if allowed, _ := breaker.allow(); !allowed {
+		t.Error("Expected a successful probe to close the circuit")
+	}
+
+	if newCircuitBreaker("payments", config.CircuitBreakerConfig{}) != nil {
+		t.Error("Expected no threshold to leave the breaker off")
+	}
+}
+
+func TestProxyEngineFailsFastWhileCircuitOpen(t *testing.T) {
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Nothing listens on the target, so every attempt fails
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "payments", Protocol: "http", TargetHost: "127.0.0.1", TargetPort: 1, SessionName: "breaker",
+		CircuitBreaker: config.CircuitBreakerConfig{FailureThreshold: 1, OpenSeconds: 60}}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, httptest.NewRequest("GET", "/charges", nil))
+	if recorder.Code != http.StatusBadGateway {
+		t.Fatalf("Expected the failed attempt to answer 502, got %d", recorder.Code)
+	}
+
+	recorder = httptest.NewRecorder()
+	engine.HandleRequest(recorder, httptest.NewRequest("GET", "/charges", nil))
+	if recorder.Code != http.StatusServiceUnavailable || recorder.Header().Get("Retry-After") != "60" {
+		t.Errorf("Expected 503 with Retry-After 60 while the circuit is open, got %d %q", recorder.Code, recorder.Header().Get("Retry-After"))
+	}
+}
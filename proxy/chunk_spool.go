@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"mimic/storage"
+)
+
+// chunkSpool collects the chunks of a streamed response for the recording. Their data is kept in
+// memory until it outgrows the body file threshold, and written to a temporary file after that,
+// so a long stream such as an LLM transcript does not accumulate in memory.
+type chunkSpool struct {
+	files  *storage.BodyFiles
+	chunks []spooledChunk
+	memory int64 // Bytes of chunk data held in memory
+	file   *os.File
+	offset int64 // End of the data written to file
+}
+
+// spooledChunk is one chunk, with its data either in memory or at an offset in the spool file
+type spooledChunk struct {
+	data      []byte
+	offset    int64
+	length    int64
+	spilled   bool
+	timestamp time.Time
+	timeDelta int64
+}
+
+// newChunkSpool spools to the directory of files; nil files keeps every chunk in memory
+func newChunkSpool(files *storage.BodyFiles) *chunkSpool {
+	return &chunkSpool{files: files}
+}
+
+// add takes a chunk as it is forwarded
+func (s *chunkSpool) add(chunk *SSEChunk) {
+	spooled := spooledChunk{data: chunk.RawData, length: int64(len(chunk.RawData)), timestamp: chunk.Timestamp, timeDelta: chunk.TimeDelta}
+	if s.file == nil && s.files != nil && s.memory+spooled.length > s.files.Threshold() {
+		s.open()
+	}
+	if s.file != nil {
+		if _, err := s.file.WriteAt(chunk.RawData, s.offset); err != nil {
+			log.Printf("Error spilling stream chunk to disk, keeping it in memory: %v", err)
+		} else {
+			spooled.data = nil
+			spooled.offset = s.offset
+			spooled.spilled = true
+			s.offset += spooled.length
+		}
+	}
+	if !spooled.spilled {
+		s.memory += spooled.length
+	}
+	s.chunks = append(s.chunks, spooled)
+}
+
+func (s *chunkSpool) open() {
+	if err := os.MkdirAll(s.files.Dir(), 0755); err != nil {
+		log.Printf("Error creating stream spool directory: %v", err)
+		s.files = nil
+		return
+	}
+	file, err := os.CreateTemp(s.files.Dir(), ".incoming-stream-")
+	if err != nil {
+		log.Printf("Error creating stream spool, keeping the stream in memory: %v", err)
+		s.files = nil
+		return
+	}
+	s.file = file
+}
+
+// len is the number of chunks collected
+func (s *chunkSpool) len() int {
+	return len(s.chunks)
+}
+
+// size is the total chunk data in bytes
+func (s *chunkSpool) size() int64 {
+	return s.memory + s.offset
+}
+
+// streamChunk reads chunk i back as a chunk of the interaction
+func (s *chunkSpool) streamChunk(interactionID, i int) (*storage.StreamChunk, error) {
+	spooled := s.chunks[i]
+	data := spooled.data
+	if spooled.spilled {
+		data = make([]byte, spooled.length)
+		if _, err := s.file.ReadAt(data, spooled.offset); err != nil {
+			return nil, fmt.Errorf("failed to read spooled chunk: %w", err)
+		}
+	}
+	return &storage.StreamChunk{
+		InteractionID: interactionID,
+		ChunkIndex:    i,
+		Data:          data,
+		Timestamp:     spooled.timestamp,
+		TimeDelta:     spooled.timeDelta,
+	}, nil
+}
+
+// remove deletes the spool file, if any
+func (s *chunkSpool) remove() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
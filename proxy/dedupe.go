@@ -0,0 +1,125 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+// volatileHeaders differ between otherwise identical exchanges, so they are left out of the
+// comparison; Content-Length follows the body, which is compared normalized
+var volatileHeaders = []string{"Date", "Age", "Expires", "Content-Length", "X-Request-Id", "Traceparent", "Tracestate", "X-Amzn-Trace-Id"}
+
+// Deduper keeps a recording from saving an exchange its session already holds, under
+// recording.dedupe. It compares the exchanges saved for the same method and endpoint, so
+// duplicates are found across restarts and replicas as well.
+type Deduper struct {
+	mutex         sync.Mutex // Keeps two copies of one exchange from both being saved
+	countHits     bool
+	ignoreHeaders map[string]bool
+}
+
+// NewDeduper builds a deduper from a dedupe config, or returns nil when it is disabled
+func NewDeduper(cfg config.DedupeConfig) *Deduper {
+	if !cfg.Enabled {
+		return nil
+	}
+	d := &Deduper{countHits: cfg.CountHits, ignoreHeaders: make(map[string]bool)}
+	for _, headers := range [][]string{volatileHeaders, cfg.IgnoreHeaders} {
+		for _, header := range headers {
+			d.ignoreHeaders[http.CanonicalHeaderKey(header)] = true
+		}
+	}
+	return d
+}
+
+// save records an interaction unless its session already holds the same exchange, in which case
+// that one's hits are counted instead. It reports whether the interaction was saved.
+func (d *Deduper) save(db *storage.Database, interaction *storage.Interaction) (bool, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	existing, err := db.FindMatchingInteractions(interaction.SessionID, interaction.Method, interaction.Endpoint)
+	if err != nil {
+		return false, fmt.Errorf("failed to look up recorded interactions: %w", err)
+	}
+	signature := d.signature(interaction)
+	for i := range existing {
+		if existing[i].IsStreaming || d.signature(&existing[i]) != signature {
+			continue
+		}
+		if d.countHits {
+			if err := db.UpdateInteractionMetadata(existing[i].ID, map[string]interface{}{storage.MetadataHits: existing[i].Hits() + 1}); err != nil {
+				return false, fmt.Errorf("failed to count hit: %w", err)
+			}
+		}
+		interaction.ID = existing[i].ID
+		return false, nil
+	}
+	return true, db.RecordInteraction(interaction)
+}
+
+// signature sums up what makes an exchange: its request and its response, with volatile headers
+// left out, JSON bodies normalized, and bodies in body files named by their content hash
+func (d *Deduper) signature(interaction *storage.Interaction) string {
+	hash := sha256.New()
+	for _, part := range [][]byte{
+		[]byte(interaction.Method),
+		[]byte(interaction.Endpoint),
+		[]byte(interaction.Query()),
+		d.headers(interaction.RequestHeaders),
+		normalizedBody(interaction.RequestBody, interaction.RequestBodyFile()),
+		[]byte(strconv.Itoa(interaction.ResponseStatus)),
+		d.headers(interaction.ResponseHeaders),
+		normalizedBody(interaction.ResponseBody, interaction.ResponseBodyFile()),
+	} {
+		// Each part is prefixed with its length so that parts cannot run into each other
+		hash.Write([]byte(strconv.Itoa(len(part)) + ":"))
+		hash.Write(part)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// headers encodes recorded headers without the ignored ones, in a stable order
+func (d *Deduper) headers(recorded string) []byte {
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(recorded), &headers); err != nil {
+		return []byte(recorded)
+	}
+	for key := range headers {
+		if d.ignoreHeaders[http.CanonicalHeaderKey(key)] {
+			delete(headers, key)
+		}
+	}
+	encoded, _ := json.Marshal(headers) // Map keys are encoded sorted
+	return encoded
+}
+
+// normalizedBody is a body as compared: JSON with its keys sorted and whitespace dropped, and a
+// body file by its name
+func normalizedBody(body []byte, file string) []byte {
+	if file != "" {
+		return []byte("file:" + file)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return body
+	}
+	if encoded, err := json.Marshal(value); err == nil {
+		return encoded
+	}
+	return body
+}
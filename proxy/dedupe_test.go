@@ -0,0 +1,63 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestProxyEngineDedupe(t *testing.T) {
+	calls := 0
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-Request-Id", strconv.Itoa(calls))
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case calls > 3:
+			w.Write([]byte(`{"state":"done"}`))
+		case calls%2 == 0:
+			w.Write([]byte(`{"state": "running", "progress": 50}`))
+		default:
+			w.Write([]byte(`{"progress":50,"state":"running"}`))
+		}
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "api", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "polling"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+	engine.SetDeduper(NewDeduper(config.DedupeConfig{Enabled: true, CountHits: true}))
+
+	for i := 0; i < 5; i++ {
+		w := httptest.NewRecorder()
+		engine.HandleRequest(w, httptest.NewRequest("GET", "/jobs/1", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected every poll to be forwarded, got %d", w.Code)
+		}
+	}
+
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 2 {
+		t.Fatalf("Expected the running and done responses recorded once each, got %d (%v)", len(interactions), err)
+	}
+	if interactions[0].Hits() != 3 || interactions[1].Hits() != 2 {
+		t.Errorf("Expected 3 and 2 hits, got %d and %d", interactions[0].Hits(), interactions[1].Hits())
+	}
+
+	if NewDeduper(config.DedupeConfig{}) != nil {
+		t.Error("Expected no deduper when dedupe is disabled")
+	}
+}
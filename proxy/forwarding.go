@@ -0,0 +1,90 @@
+package proxy
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"mimic/config"
+)
+
+// hopHeaders only apply to a single connection and must not be forwarded (RFC 9110 section 7.6.1)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// copyEndToEndHeaders adds every header from src to dst except hop-by-hop headers
+func copyEndToEndHeaders(dst, src http.Header) {
+	// Headers named in Connection are hop-by-hop too
+	skip := make(map[string]bool, len(hopHeaders))
+	for _, name := range hopHeaders {
+		skip[name] = true
+	}
+	for _, value := range src.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				skip[http.CanonicalHeaderKey(name)] = true
+			}
+		}
+	}
+
+	for key, values := range src {
+		if skip[http.CanonicalHeaderKey(key)] {
+			continue
+		}
+		for _, value := range values {
+			dst.Add(key, value)
+		}
+	}
+}
+
+// applyForwarding sets the Host and X-Forwarded-* headers of an upstream request from the proxy's options
+func applyForwarding(out, in *http.Request, proxyConfig *config.ProxyConfig) {
+	switch {
+	case proxyConfig.HostHeader != "":
+		out.Host = proxyConfig.HostHeader
+	case proxyConfig.PreserveHost:
+		out.Host = in.Host
+	}
+
+	mode := proxyConfig.XForwarded
+	if mode == "" || mode == "off" {
+		return
+	}
+
+	clientIP, _, err := net.SplitHostPort(in.RemoteAddr)
+	if err != nil {
+		clientIP = in.RemoteAddr
+	}
+	proto := "http"
+	if in.TLS != nil {
+		proto = "https"
+	}
+	host := in.Host
+
+	forwardedFor := clientIP
+	if mode == "append" {
+		if prior := in.Header.Values("X-Forwarded-For"); len(prior) > 0 {
+			forwardedFor = strings.Join(prior, ", ") + ", " + clientIP
+		}
+		// Proto and Host describe the original client request, so an earlier proxy's values win
+		if prior := in.Header.Get("X-Forwarded-Proto"); prior != "" {
+			proto = prior
+		}
+		if prior := in.Header.Get("X-Forwarded-Host"); prior != "" {
+			host = prior
+		}
+	}
+
+	out.Header.Set("X-Forwarded-For", forwardedFor)
+	out.Header.Set("X-Forwarded-Proto", proto)
+	out.Header.Set("X-Forwarded-Host", host)
+}
@@ -0,0 +1,82 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mimic/config"
+)
+
+func TestCopyRequestStripsHopByHopHeaders(t *testing.T) {
+	handler := NewRESTHandler([]string{})
+
+	req := httptest.NewRequest(http.MethodGet, "http://mimic.local/users", nil)
+	req.Header.Set("Connection", "keep-alive, X-Session-Hop")
+	req.Header.Set("X-Session-Hop", "drop me")
+	req.Header.Set("Keep-Alive", "timeout=5")
+	req.Header.Set("Proxy-Authorization", "Basic secret")
+	req.Header.Set("Te", "trailers")
+	req.Header.Set("Authorization", "Bearer keep")
+
+	out, err := handler.CopyRequest(req, "http://upstream.local:8080/users")
+	if err != nil {
+		t.Fatalf("CopyRequest failed: %v", err)
+	}
+
+	for _, name := range []string{"Connection", "X-Session-Hop", "Keep-Alive", "Proxy-Authorization"} {
+		if value := out.Header.Get(name); value != "" {
+			t.Errorf("Expected %s to be stripped, got %q", name, value)
+		}
+	}
+	if out.Header.Get("Authorization") != "Bearer keep" {
+		t.Errorf("Expected Authorization to be forwarded, got %q", out.Header.Get("Authorization"))
+	}
+	if out.Header.Get("Te") != "trailers" {
+		t.Errorf("Expected TE: trailers to be kept, got %q", out.Header.Get("Te"))
+	}
+	if out.Host != "upstream.local:8080" {
+		t.Errorf("Expected Host to default to the target, got %q", out.Host)
+	}
+}
+
+func TestApplyForwarding(t *testing.T) {
+	newRequests := func() (*http.Request, *http.Request) {
+		in := httptest.NewRequest(http.MethodGet, "http://mimic.local/users", nil)
+		in.RemoteAddr = "10.0.0.7:51234"
+		in.Header.Set("X-Forwarded-For", "203.0.113.9")
+		in.Header.Set("X-Forwarded-Proto", "https")
+		out, _ := http.NewRequest(http.MethodGet, "http://upstream.local/users", nil)
+		out.Header.Set("X-Forwarded-For", "203.0.113.9")
+		return in, out
+	}
+
+	tests := []struct {
+		name          string
+		proxyConfig   config.ProxyConfig
+		expectedHost  string
+		expectedFor   string
+		expectedProto string
+	}{
+		{"off", config.ProxyConfig{}, "upstream.local", "203.0.113.9", ""},
+		{"append", config.ProxyConfig{XForwarded: "append", PreserveHost: true}, "mimic.local", "203.0.113.9, 10.0.0.7", "https"},
+		{"set", config.ProxyConfig{XForwarded: "set", HostHeader: "api.internal"}, "api.internal", "10.0.0.7", "http"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in, out := newRequests()
+			applyForwarding(out, in, &tt.proxyConfig)
+
+			if out.Host != tt.expectedHost {
+				t.Errorf("Expected Host %q, got %q", tt.expectedHost, out.Host)
+			}
+			if got := out.Header.Get("X-Forwarded-For"); got != tt.expectedFor {
+				t.Errorf("Expected X-Forwarded-For %q, got %q", tt.expectedFor, got)
+			}
+			if got := out.Header.Get("X-Forwarded-Proto"); got != tt.expectedProto {
+				t.Errorf("Expected X-Forwarded-Proto %q, got %q", tt.expectedProto, got)
+			}
+		})
+	}
+}
@@ -17,21 +17,30 @@ import (
 	"google.golang.org/protobuf/types/dynamicpb"
 
 	"github.com/google/uuid"
+	"mimic/redact"
 	"mimic/storage"
 )
 
 type GRPCHandler struct {
-	redactPatterns []*regexp.Regexp
+	redactor *redact.Redactor // Masks secrets in recorded metadata; nil records it as it is
 }
 
 func NewGRPCHandler(redactPatterns []string) *GRPCHandler {
-	patterns := make([]*regexp.Regexp, len(redactPatterns))
-	for i, pattern := range redactPatterns {
-		if compiled, err := regexp.Compile(pattern); err == nil {
-			patterns[i] = compiled
-		}
+	redactor, _ := redact.New(redactPatterns, nil) // Without presets only bad patterns can fail, and those are skipped
+	return &GRPCHandler{redactor: redactor}
+}
+
+// SetRedactor masks what a redactor matches in the metadata the handler records
+func (h *GRPCHandler) SetRedactor(redactor *redact.Redactor) {
+	h.redactor = redactor
+}
+
+// Redactor is what the handler masks metadata with, or nil
+func (h *GRPCHandler) Redactor() *redact.Redactor {
+	if h == nil {
+		return nil
 	}
-	return &GRPCHandler{redactPatterns: patterns}
+	return h.redactor
 }
 
 type GRPCRequest struct {
@@ -180,15 +189,7 @@ func (h *GRPCHandler) fuzzyGRPCMatch(method string, interaction *storage.Interac
 }
 
 func (h *GRPCHandler) redactSensitiveData(data string) string {
-	result := data
-	for _, pattern := range h.redactPatterns {
-		result = pattern.ReplaceAllString(result, "[REDACTED]")
-	}
-	return result
-}
-
-func (h *GRPCHandler) GetRedactPatterns() []*regexp.Regexp {
-	return h.redactPatterns
+	return h.redactor.Headers(data)
 }
 
 // GRPCInterceptor creates a grpc.UnaryServerInterceptor for recording
@@ -15,18 +15,26 @@ import (
 	"google.golang.org/grpc/credentials/insecure"
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"mimic/accesslog"
 	"mimic/config"
+	"mimic/consumer"
+	"mimic/metrics"
+	"mimic/protoschema"
+	"mimic/redact"
 	"mimic/storage"
+	"mimic/tag"
+	"mimic/webhook"
 )
 
 // RawGRPCProxy implements raw byte-level gRPC proxying
 type RawGRPCProxy struct {
 	config    *config.ProxyConfig
-	mode      string // Global mode: "record" or "mock"
+	mode      string // Mode the proxy is served in; only "record" saves calls
 	database  *storage.Database
 	session   *storage.Session
 	handler   *GRPCHandler
 	webServer WebBroadcaster
+	filter    *RecordFilter // Calls it leaves out are forwarded without recording
 }
 
 func NewRawGRPCProxy(proxyConfig *config.ProxyConfig, mode string, db *storage.Database, session *storage.Session, grpcHandler *GRPCHandler) *RawGRPCProxy {
@@ -44,6 +52,16 @@ func (p *RawGRPCProxy) SetWebBroadcaster(wb WebBroadcaster) {
 	p.webServer = wb
 }
 
+// SetRedactor masks what a redactor matches in the metadata of the calls the proxy saves
+func (p *RawGRPCProxy) SetRedactor(redactor *redact.Redactor) {
+	p.handler.SetRedactor(redactor)
+}
+
+// SetRecordFilter limits the calls the proxy saves to those a filter selects, by full method name
+func (p *RawGRPCProxy) SetRecordFilter(filter *RecordFilter) {
+	p.filter = filter
+}
+
 // GetUnknownServiceHandler returns a handler that can proxy any gRPC service using raw bytes
 func (p *RawGRPCProxy) GetUnknownServiceHandler() grpc.StreamHandler {
 	// Register our raw codec
@@ -59,17 +77,27 @@ func (p *RawGRPCProxy) GetUnknownServiceHandler() grpc.StreamHandler {
 
 		// Create connection to target
 		targetAddr := fmt.Sprintf("%s:%d", p.config.TargetHost, p.config.TargetPort)
-		ctx := stream.Context()
+		accesslog.Annotate(stream.Context(), p.session.SessionName, "")
+
+		// The upstream call inherits the client's deadline and is cancelled with it, or when this handler returns
+		ctx, cancel := context.WithCancel(stream.Context())
+		defer cancel()
 
 		// Determine if we should use TLS based on port
 		var creds credentials.TransportCredentials
 		if p.config.TargetPort == 443 || p.config.Protocol == "https" {
-			creds = credentials.NewTLS(nil) // Use system root CAs
+			creds = upstreamGRPCCredentials(p.config.Transport)
 		} else {
 			creds = insecure.NewCredentials()
 		}
 
-		conn, err := grpc.DialContext(ctx, targetAddr,
+		outboundOpts, err := OutboundGRPCDialOptions(p.config.OutboundProxy)
+		if err != nil {
+			return status.Errorf(codes.Internal, "invalid outbound proxy: %v", err)
+		}
+
+		dialOpts := append(outboundOpts, grpcKeepaliveDialOptions(p.config.Transport)...)
+		conn, err := grpc.DialContext(ctx, targetAddr, append(dialOpts,
 			grpc.WithTransportCredentials(creds),
 			grpc.WithInitialWindowSize(64*1024*1024),     // 64MB initial window
 			grpc.WithInitialConnWindowSize(64*1024*1024), // 64MB connection window
@@ -79,7 +107,7 @@ func (p *RawGRPCProxy) GetUnknownServiceHandler() grpc.StreamHandler {
 				grpc.MaxCallRecvMsgSize(64*1024*1024),
 				grpc.MaxCallSendMsgSize(64*1024*1024),
 			),
-		)
+		)...)
 		if err != nil {
 			return status.Errorf(codes.Unavailable, "failed to connect to backend %s: %v", targetAddr, err)
 		}
@@ -89,9 +117,10 @@ func (p *RawGRPCProxy) GetUnknownServiceHandler() grpc.StreamHandler {
 		if p.isLikelyUnaryCall(fullMethodName) {
 			return p.handleUnaryCall(ctx, conn, stream, fullMethodName)
 		}
-		// Create client stream using raw codec
+		// Create client stream using raw codec, forwarding the client's metadata
+		md, _ := metadata.FromIncomingContext(ctx)
 		clientStream, err := conn.NewStream(
-			ctx,
+			metadata.NewOutgoingContext(ctx, md),
 			&grpc.StreamDesc{
 				StreamName:    fullMethodName,
 				ServerStreams: true,
@@ -108,31 +137,32 @@ func (p *RawGRPCProxy) GetUnknownServiceHandler() grpc.StreamHandler {
 	}
 }
 
-// proxyRawStream proxies using raw message handling
+// proxyRawStream relays messages in both directions until the target finishes the call. The client
+// half-closing its side does not end the call, and the target's headers, trailers, and status are
+// passed back unchanged so deadline and cancellation codes reach the client.
 func (p *RawGRPCProxy) proxyRawStream(serverStream grpc.ServerStream, clientStream grpc.ClientStream, method string) error {
-	errCh := make(chan error, 2)
+	requestDone := make(chan error, 1)
+	responseDone := make(chan error, 1)
 
 	// Proxy client->server (requests)
 	go func() {
-		defer func() {
-			clientStream.CloseSend()
-		}()
-
 		for {
 			var msg RawMessage
 			if err := serverStream.RecvMsg(&msg); err != nil {
 				if err == io.EOF {
-					errCh <- nil
+					clientStream.CloseSend()
+					requestDone <- nil
 					return
 				}
-				errCh <- fmt.Errorf("server recv error: %w", err)
+				requestDone <- err
 				return
 			}
 
 			log.Printf("→ %s: %d bytes", method, len(msg.Data))
 
 			if err := clientStream.SendMsg(msg); err != nil {
-				errCh <- fmt.Errorf("client send error: %w", err)
+				// The target's status is reported by RecvMsg on the response side
+				requestDone <- nil
 				return
 			}
 		}
@@ -140,27 +170,46 @@ func (p *RawGRPCProxy) proxyRawStream(serverStream grpc.ServerStream, clientStre
 
 	// Proxy server->client (responses)
 	go func() {
+		if header, err := clientStream.Header(); err == nil && len(header) > 0 {
+			if err := serverStream.SendHeader(header); err != nil {
+				responseDone <- err
+				return
+			}
+		}
+
 		for {
 			var msg RawMessage
 			if err := clientStream.RecvMsg(&msg); err != nil {
 				if err == io.EOF {
-					errCh <- nil
+					responseDone <- nil
 					return
 				}
-				errCh <- fmt.Errorf("client recv error: %w", err)
+				responseDone <- err
 				return
 			}
 
 			log.Printf("← %s: %d bytes", method, len(msg.Data))
 
 			if err := serverStream.SendMsg(msg); err != nil {
-				errCh <- fmt.Errorf("server send error: %w", err)
+				responseDone <- fmt.Errorf("server send error: %w", err)
 				return
 			}
 		}
 	}()
 
-	return <-errCh
+	for {
+		select {
+		case err := <-requestDone:
+			if err != nil {
+				// The client cancelled or broke the call; returning cancels the upstream call too
+				return err
+			}
+			requestDone = nil // Keep relaying responses after the client half-closes
+		case err := <-responseDone:
+			serverStream.SetTrailer(clientStream.Trailer())
+			return err
+		}
+	}
 }
 
 func (p *RawGRPCProxy) metadataToJSON(md metadata.MD) string {
@@ -216,42 +265,67 @@ func (p *RawGRPCProxy) isLikelyUnaryCall(method string) bool {
 	return true
 }
 
+// describeRawMessage renders a message for the web UI, as JSON when its descriptor is loaded
+func describeRawMessage(method string, direction protoschema.Direction, data []byte) string {
+	if decoded, err := protoschema.Default.Decode(method, direction, data); err == nil {
+		return string(decoded)
+	}
+	return fmt.Sprintf("gRPC raw message (%d bytes)", len(data))
+}
+
 // handleUnaryCall handles unary gRPC calls
 func (p *RawGRPCProxy) handleUnaryCall(ctx context.Context, conn *grpc.ClientConn, stream grpc.ServerStream, method string) error {
 	// Receive the request from client
 	var requestMsg RawMessage
 	if err := stream.RecvMsg(&requestMsg); err != nil {
+		if ctx.Err() != nil {
+			return status.FromContextError(ctx.Err()).Err()
+		}
 		return status.Errorf(codes.Internal, "failed to receive request: %v", err)
 	}
 
-	if p.mode == "record" {
+	recording := p.mode == "record" && p.filter.Records("", method)
+	if recording {
 		log.Printf("→ %s: %d bytes (unary)", method, len(requestMsg.Data))
 	}
 
-	// Extract and forward metadata
+	// Extract and forward metadata, less mimic's own consumer and tag headers
+	consumerName := consumer.FromContext(stream.Context(), p.config.Consumers)
+	tags := tag.FromContext(stream.Context())
 	md, _ := metadata.FromIncomingContext(stream.Context())
+	md = md.Copy()
+	md.Delete(tag.Header)
+	if header := consumer.Header(p.config.Consumers); consumer.Internal(header) {
+		md.Delete(header)
+	}
 	outCtx := metadata.NewOutgoingContext(ctx, md)
 
 	// Create interaction record for database storage
 	var interaction *storage.Interaction
-	if p.mode == "record" {
+	if recording {
 		interaction = &storage.Interaction{
 			RequestID:      GenerateRequestID(),
 			SessionID:      p.session.ID,
 			Protocol:       "gRPC",
 			Method:         method,
 			Endpoint:       method,
-			RequestHeaders: p.metadataToJSON(md),
+			RequestHeaders: p.handler.Redactor().Headers(p.metadataToJSON(md)),
 			RequestBody:    requestMsg.Data,
 			Timestamp:      time.Now(),
 		}
+		if err := consumer.Tag(interaction, consumerName); err != nil {
+			log.Printf("Error attributing gRPC interaction to consumer: %v", err)
+		}
+		if err := tag.Apply(interaction, tags); err != nil {
+			log.Printf("Error tagging gRPC interaction: %v", err)
+		}
 
 		// Broadcast request event to web UI
 		if p.webServer != nil {
 			log.Printf("[DEBUG] Broadcasting gRPC request to web UI: %s", method)
 			headers := p.metadataToMap(md)
-			body := fmt.Sprintf("gRPC raw message (%d bytes)", len(requestMsg.Data))
-			p.webServer.BroadcastRequest(method, method, p.session.SessionName, "grpc-client", interaction.RequestID, headers, body)
+			body := describeRawMessage(method, protoschema.Request, requestMsg.Data)
+			p.webServer.BroadcastRequest(p.config.Name, method, method, p.session.SessionName, "grpc-client", interaction.RequestID, headers, body)
 		} else {
 			log.Printf("[DEBUG] No webServer available for broadcasting gRPC request")
 		}
@@ -259,10 +333,12 @@ func (p *RawGRPCProxy) handleUnaryCall(ctx context.Context, conn *grpc.ClientCon
 
 	// Forward the unary call to target server
 	var responseMsg RawMessage
+	upstreamStart := time.Now()
 	err := conn.Invoke(outCtx, method, &requestMsg, &responseMsg, grpc.ForceCodec(GetRawCodec()))
+	metrics.ObserveUpstreamLatency(p.config.Name, "grpc", upstreamStart)
 
 	// Handle recording and response
-	if p.mode == "record" {
+	if recording {
 		statusCode := 0
 		if err != nil {
 			if st, ok := status.FromError(err); ok {
@@ -280,20 +356,29 @@ func (p *RawGRPCProxy) handleUnaryCall(ctx context.Context, conn *grpc.ClientCon
 		interaction.ResponseStatus = statusCode
 		interaction.ResponseHeaders = "{}" // Empty metadata for now
 		interaction.ResponseBody = responseMsg.Data
+		if err := interaction.SetTiming(interaction.Timestamp, time.Since(interaction.Timestamp)); err != nil {
+			log.Printf("Error recording gRPC interaction timing: %v", err)
+		}
 
-		// Save to database
-		if recordErr := p.database.RecordInteraction(interaction); recordErr != nil {
+		// Save to database, unless sampling passes the call over
+		if !p.filter.Samples(statusCode) {
+			metrics.RecordSampledOut(p.config.Name)
+			accesslog.Annotate(ctx, "", accesslog.MatchPassthrough)
+		} else if recordErr := p.database.RecordInteraction(interaction); recordErr != nil {
 			log.Printf("Error recording gRPC interaction: %v", recordErr)
 		} else {
 			log.Printf("Recorded gRPC interaction: %s -> %d", method, statusCode)
+			metrics.RecordInteraction(p.config.Name, len(interaction.RequestBody)+len(interaction.ResponseBody))
+			webhook.RecordingComplete(p.config.Name, p.session.SessionName, interaction)
+			accesslog.Annotate(ctx, "", accesslog.MatchRecorded)
 		}
 
 		// Broadcast response event to web UI
 		if p.webServer != nil {
 			log.Printf("[DEBUG] Broadcasting gRPC response to web UI: %s", method)
 			responseHeaders := make(map[string]interface{})
-			responseBody := fmt.Sprintf("gRPC raw message (%d bytes)", len(responseMsg.Data))
-			p.webServer.BroadcastResponse(method, method, p.session.SessionName, "grpc-client", interaction.RequestID, statusCode, responseHeaders, responseBody)
+			responseBody := describeRawMessage(method, protoschema.Response, responseMsg.Data)
+			p.webServer.BroadcastResponse(p.config.Name, method, method, p.session.SessionName, "grpc-client", interaction.RequestID, statusCode, responseHeaders, responseBody)
 		} else {
 			log.Printf("[DEBUG] No webServer available for broadcasting gRPC response")
 		}
@@ -0,0 +1,151 @@
+package proxy
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// startRawProxy serves upstream on one listener and a raw proxy to it on another, returning a client conn to the proxy
+func startRawProxy(t *testing.T, upstream grpc.StreamHandler) *grpc.ClientConn {
+	t.Helper()
+	RegisterRawCodec()
+
+	upstreamListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	upstreamServer := grpc.NewServer(grpc.UnknownServiceHandler(upstream))
+	go upstreamServer.Serve(upstreamListener)
+	t.Cleanup(upstreamServer.Stop)
+
+	proxyConfig := config.ProxyConfig{
+		Name:          "raw",
+		Protocol:      "grpc",
+		TargetHost:    "127.0.0.1",
+		TargetPort:    upstreamListener.Addr().(*net.TCPAddr).Port,
+		OutboundProxy: "none",
+		Transport:     config.TransportConfig{GRPCKeepaliveSeconds: 30},
+	}
+	rawProxy := NewRawGRPCProxy(&proxyConfig, "proxy", nil, &storage.Session{SessionName: "raw-session"}, nil)
+
+	proxyListener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	proxyServer := grpc.NewServer(grpc.UnknownServiceHandler(rawProxy.GetUnknownServiceHandler()))
+	go proxyServer.Serve(proxyListener)
+	t.Cleanup(proxyServer.Stop)
+
+	conn, err := grpc.Dial(proxyListener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func newRawStream(ctx context.Context, conn *grpc.ClientConn, method string) (grpc.ClientStream, error) {
+	return conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, method, grpc.ForceCodec(GetRawCodec()))
+}
+
+func TestRawGRPCProxyPropagatesDeadline(t *testing.T) {
+	type upstreamCall struct {
+		hasDeadline bool
+		token       []string
+		err         error
+	}
+	calls := make(chan upstreamCall, 1)
+
+	conn := startRawProxy(t, func(srv interface{}, stream grpc.ServerStream) error {
+		_, hasDeadline := stream.Context().Deadline()
+		md, _ := metadata.FromIncomingContext(stream.Context())
+		<-stream.Context().Done()
+		calls <- upstreamCall{hasDeadline: hasDeadline, token: md.Get("x-token"), err: stream.Context().Err()}
+		return nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-token", "secret")
+
+	stream, err := newRawStream(ctx, conn, "/test.Events/StreamEvents")
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	var msg RawMessage
+	if err := stream.RecvMsg(&msg); status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Expected DEADLINE_EXCEEDED, got %v", err)
+	}
+
+	select {
+	case call := <-calls:
+		if !call.hasDeadline {
+			t.Error("Expected the upstream call to carry the client's deadline")
+		}
+		if len(call.token) != 1 || call.token[0] != "secret" {
+			t.Errorf("Expected the client's metadata upstream, got %v", call.token)
+		}
+		if call.err == nil {
+			t.Error("Expected the upstream call to be cancelled")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Upstream call kept running after the client's deadline")
+	}
+}
+
+func TestRawGRPCProxyRelaysAfterHalfClose(t *testing.T) {
+	conn := startRawProxy(t, func(srv interface{}, stream grpc.ServerStream) error {
+		var received int
+		for {
+			var msg RawMessage
+			if err := stream.RecvMsg(&msg); err == io.EOF {
+				break
+			} else if err != nil {
+				return err
+			}
+			received++
+		}
+		// Answer only after the client has finished sending
+		time.Sleep(50 * time.Millisecond)
+		stream.SetTrailer(metadata.Pairs("x-received", strconv.Itoa(received)))
+		return stream.SendMsg(&RawMessage{Data: []byte("done")})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := newRawStream(ctx, conn, "/test.Events/StreamUpload")
+	if err != nil {
+		t.Fatalf("Failed to open stream: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := stream.SendMsg(&RawMessage{Data: []byte("chunk")}); err != nil {
+			t.Fatalf("SendMsg failed: %v", err)
+		}
+	}
+	stream.CloseSend()
+
+	var msg RawMessage
+	if err := stream.RecvMsg(&msg); err != nil || string(msg.Data) != "done" {
+		t.Fatalf("Expected the response sent after the half-close, got %q (%v)", msg.Data, err)
+	}
+	if err := stream.RecvMsg(&msg); err != io.EOF {
+		t.Errorf("Expected a clean end of stream, got %v", err)
+	}
+	if got := stream.Trailer().Get("x-received"); len(got) != 1 || got[0] != "2" {
+		t.Errorf("Expected the upstream trailer to be relayed, got %v", got)
+	}
+}
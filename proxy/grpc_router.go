@@ -9,7 +9,12 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
+	"mimic/accesslog"
 	"mimic/config"
+	"mimic/fault"
+	"mimic/metrics"
+	"mimic/ratelimit"
+	"mimic/redact"
 	"mimic/storage"
 )
 
@@ -30,6 +35,26 @@ type GRPCRouter struct {
 	defaultRoute *GRPCRoute // Fallback route if no patterns match
 }
 
+// SetRecordFilter limits the calls every route saves to those a filter selects
+func (r *GRPCRouter) SetRecordFilter(filter *RecordFilter) {
+	for _, route := range r.routes {
+		route.Proxy.SetRecordFilter(filter)
+	}
+	if r.defaultRoute != nil {
+		r.defaultRoute.Proxy.SetRecordFilter(filter)
+	}
+}
+
+// SetRedactor masks what a redactor matches in the metadata every route saves
+func (r *GRPCRouter) SetRedactor(redactor *redact.Redactor) {
+	for _, route := range r.routes {
+		route.Proxy.SetRedactor(redactor)
+	}
+	if r.defaultRoute != nil {
+		r.defaultRoute.Proxy.SetRedactor(redactor)
+	}
+}
+
 // NewGRPCRouter creates a new gRPC router with multiple routes
 func NewGRPCRouter(routeConfigs map[string]config.ProxyConfig, mode string, db *storage.Database, webServer WebBroadcaster) (*GRPCRouter, error) {
 	router := &GRPCRouter{
@@ -39,12 +64,13 @@ func NewGRPCRouter(routeConfigs map[string]config.ProxyConfig, mode string, db *
 	}
 
 	for name, proxyConfig := range routeConfigs {
+		proxyConfig := proxyConfig // Each route keeps its own copy
 		session, err := db.GetOrCreateSession(proxyConfig.SessionName, fmt.Sprintf("Proxy session for %s", name))
 		if err != nil {
 			return nil, fmt.Errorf("failed to create session for route %s: %w", name, err)
 		}
 
-		grpcHandler := NewGRPCHandler([]string{}) // Use empty redact patterns for now
+		grpcHandler := NewGRPCHandler(nil) // Redaction is set with SetRedactor
 		rawProxy := NewRawGRPCProxy(&proxyConfig, mode, db, session, grpcHandler)
 
 		if webServer != nil {
@@ -119,8 +145,23 @@ func (r *GRPCRouter) GetUnknownServiceHandler() grpc.StreamHandler {
 
 		log.Printf("gRPC Router: matched route '%s' for %s", route.Name, fullMethodName)
 
+		accesslog.SetProxy(stream.Context(), route.Name)
+		release, err := ratelimit.Acquire(route.Name)
+		if err != nil {
+			metrics.RecordGRPCRequest(route.Name, err)
+			return err
+		}
+		defer release()
+
+		if err := fault.InjectGRPC(stream.Context(), route.Name); err != nil {
+			metrics.RecordGRPCRequest(route.Name, err)
+			return err
+		}
+
 		// Delegate to the route's proxy handler
-		return route.Proxy.GetUnknownServiceHandler()(srv, stream)
+		err = route.Proxy.GetUnknownServiceHandler()(srv, stream)
+		metrics.RecordGRPCRequest(route.Name, err)
+		return err
 	}
 }
 
@@ -15,8 +15,8 @@ func TestNewGRPCHandler(t *testing.T) {
 		t.Fatal("Expected non-nil gRPC handler")
 	}
 
-	if len(handler.redactPatterns) != 2 {
-		t.Errorf("Expected 2 redact patterns, got %d", len(handler.redactPatterns))
+	if handler.redactor == nil {
+		t.Error("Expected the redact patterns to build a redactor")
 	}
 }
 
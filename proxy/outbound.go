@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// OutboundProxyNone disables egress proxies, including any from the environment
+const OutboundProxyNone = "none"
+
+// OutboundProxyFunc returns the http.Transport proxy selector for an outbound_proxy setting.
+// An empty setting honors HTTP_PROXY, HTTPS_PROXY, and NO_PROXY.
+func OutboundProxyFunc(setting string) (func(*http.Request) (*url.URL, error), error) {
+	switch setting {
+	case "":
+		return http.ProxyFromEnvironment, nil
+	case OutboundProxyNone:
+		return nil, nil
+	}
+
+	proxyURL, err := parseOutboundProxy(setting)
+	if err != nil {
+		return nil, err
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// OutboundGRPCDialOptions returns the dial options that route gRPC connections for an outbound_proxy setting.
+// gRPC already honors HTTPS_PROXY and NO_PROXY, so an empty setting adds nothing.
+func OutboundGRPCDialOptions(setting string) ([]grpc.DialOption, error) {
+	switch setting {
+	case "":
+		return nil, nil
+	case OutboundProxyNone:
+		return []grpc.DialOption{grpc.WithNoProxy()}, nil
+	}
+
+	proxyURL, err := parseOutboundProxy(setting)
+	if err != nil {
+		return nil, err
+	}
+	if proxyURL.Scheme != "http" {
+		return nil, fmt.Errorf("gRPC targets only support http:// outbound proxies, got %s", proxyURL.Scheme)
+	}
+	return []grpc.DialOption{grpc.WithContextDialer(connectDialer(proxyURL))}, nil
+}
+
+func parseOutboundProxy(setting string) (*url.URL, error) {
+	proxyURL, err := url.Parse(setting)
+	if err != nil {
+		return nil, fmt.Errorf("invalid outbound proxy %q: %w", setting, err)
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid outbound proxy %q: scheme must be http, https, or socks5", setting)
+	}
+	if proxyURL.Host == "" {
+		return nil, fmt.Errorf("invalid outbound proxy %q: missing host", setting)
+	}
+	return proxyURL, nil
+}
+
+// connectDialer opens connections through an HTTP proxy using CONNECT tunnels
+func connectDialer(proxyURL *url.URL) func(ctx context.Context, addr string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", proxyURL.Host)
+		if err != nil {
+			return nil, fmt.Errorf("failed to dial outbound proxy %s: %w", proxyURL.Host, err)
+		}
+
+		if deadline, ok := ctx.Deadline(); ok {
+			conn.SetDeadline(deadline)
+			defer conn.SetDeadline(time.Time{})
+		}
+
+		req := &http.Request{
+			Method: http.MethodConnect,
+			URL:    &url.URL{Host: addr},
+			Host:   addr,
+			Header: make(http.Header),
+		}
+		if user := proxyURL.User; user != nil {
+			password, _ := user.Password()
+			credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+			req.Header.Set("Proxy-Authorization", "Basic "+credentials)
+		}
+		if err := req.Write(conn); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to send CONNECT to outbound proxy: %w", err)
+		}
+
+		reader := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(reader, req)
+		if err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to read CONNECT response from outbound proxy: %w", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			conn.Close()
+			return nil, fmt.Errorf("outbound proxy refused CONNECT to %s: %s", addr, resp.Status)
+		}
+
+		if reader.Buffered() > 0 {
+			return &bufferedConn{Conn: conn, reader: reader}, nil
+		}
+		return conn, nil
+	}
+}
+
+// bufferedConn replays bytes the CONNECT response reader consumed past the headers
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"mimic/config"
+)
+
+func TestUpstreamClientUsesOutboundProxy(t *testing.T) {
+	var proxiedURL string
+	egress := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedURL = r.URL.String()
+		w.Write([]byte("via egress"))
+	}))
+	defer egress.Close()
+
+	client, err := newUpstreamClient(config.TransportConfig{}, egress.URL)
+	if err != nil {
+		t.Fatalf("newUpstreamClient failed: %v", err)
+	}
+
+	resp, err := client.Get("http://api.unreachable.example/users")
+	if err != nil {
+		t.Fatalf("Request through outbound proxy failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+
+	if string(body) != "via egress" || proxiedURL != "http://api.unreachable.example/users" {
+		t.Errorf("Expected the request to go through the egress proxy, got %q for %q", body, proxiedURL)
+	}
+}
+
+func TestOutboundProxyRejectsInvalidSettings(t *testing.T) {
+	for _, setting := range []string{"ftp://egress:21", "egress:3128", "http://"} {
+		if _, err := OutboundProxyFunc(setting); err == nil {
+			t.Errorf("Expected %q to be rejected", setting)
+		}
+	}
+	if _, err := OutboundGRPCDialOptions("socks5://egress:1080"); err == nil {
+		t.Error("Expected socks5 to be rejected for gRPC targets")
+	}
+}
+
+func TestConnectDialerTunnelsThroughProxy(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer listener.Close()
+
+	requests := make(chan *http.Request, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		reader := bufio.NewReader(conn)
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		requests <- req
+		// Send the tunnel's first bytes together with the CONNECT response
+		conn.Write([]byte("HTTP/1.1 200 Connection established\r\n\r\nhello"))
+	}()
+
+	proxyURL, _ := url.Parse("http://user:secret@" + listener.Addr().String())
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	conn, err := connectDialer(proxyURL)(ctx, "grpc.internal:443")
+	if err != nil {
+		t.Fatalf("connectDialer failed: %v", err)
+	}
+	defer conn.Close()
+
+	req := <-requests
+	if req.Method != http.MethodConnect || req.Host != "grpc.internal:443" {
+		t.Errorf("Expected CONNECT grpc.internal:443, got %s %s", req.Method, req.Host)
+	}
+	if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpzZWNyZXQ=" {
+		t.Errorf("Expected basic proxy credentials, got %q", req.Header.Get("Proxy-Authorization"))
+	}
+
+	greeting := make([]byte, 5)
+	if _, err := io.ReadFull(conn, greeting); err != nil || string(greeting) != "hello" {
+		t.Errorf("Expected buffered tunnel bytes to be readable, got %q (%v)", greeting, err)
+	}
+}
@@ -4,14 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"log"
+	"mime"
 	"net"
 	"net/http"
 	"strings"
 	"time"
 
+	"mimic/accesslog"
 	"mimic/config"
+	"mimic/conformance"
+	"mimic/consumer"
+	"mimic/metrics"
+	"mimic/redact"
 	"mimic/storage"
+	"mimic/tag"
+	"mimic/webhook"
 
+	"github.com/gorilla/websocket"
 	"google.golang.org/grpc"
 )
 
@@ -24,11 +33,17 @@ type ProxyEngine struct {
 	client      *http.Client
 	grpcServer  *grpc.Server
 	webServer   WebBroadcaster
+	passthrough bool             // Forward without recording
+	filter      *RecordFilter    // Requests it leaves out are forwarded without recording
+	dedupe      *Deduper         // Exchanges the session already holds are not saved again
+	rotation    *SessionRotation // Starts a new session once the current one is old or large enough
+	retry       *retryPolicy     // Sends requests again after failed attempts
+	breaker     *circuitBreaker  // Fails fast while the target keeps failing
 }
 
 type WebBroadcaster interface {
-	BroadcastRequest(method, endpoint, sessionName, remoteAddr, requestID string, headers map[string]interface{}, body string)
-	BroadcastResponse(method, endpoint, sessionName, remoteAddr, requestID string, status int, headers map[string]interface{}, body string)
+	BroadcastRequest(proxyName, method, endpoint, sessionName, remoteAddr, requestID string, headers map[string]interface{}, body string)
+	BroadcastResponse(proxyName, method, endpoint, sessionName, remoteAddr, requestID string, status int, headers map[string]interface{}, body string)
 }
 
 func NewProxyEngine(proxyConfig config.ProxyConfig, db *storage.Database) (*ProxyEngine, error) {
@@ -41,17 +56,13 @@ func NewProxyEngineWithBroadcaster(proxyConfig config.ProxyConfig, db *storage.D
 		return nil, fmt.Errorf("failed to get or create session: %w", err)
 	}
 
-	restHandler := NewRESTHandler([]string{}) // Use empty redact patterns for now
-	grpcHandler := NewGRPCHandler([]string{}) // Use empty redact patterns for now
+	restHandler := NewRESTHandler(nil) // Redaction is set with SetRedactor
+	restHandler.StoreLargeBodies(db)
+	grpcHandler := NewGRPCHandler(nil)
 
-	client := &http.Client{
-		Timeout: 30 * time.Second,
-		Transport: &http.Transport{
-			MaxIdleConns:        100,
-			IdleConnTimeout:     90 * time.Second,
-			DisableCompression:  true,
-			MaxIdleConnsPerHost: 10,
-		},
+	client, err := newUpstreamClient(proxyConfig.Transport, proxyConfig.OutboundProxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure upstream client: %w", err)
 	}
 
 	var grpcServer *grpc.Server
@@ -84,9 +95,61 @@ func NewProxyEngineWithBroadcaster(proxyConfig config.ProxyConfig, db *storage.D
 		client:      client,
 		grpcServer:  grpcServer,
 		webServer:   webServer,
+		retry:       newRetryPolicy(proxyConfig.Retry),
+		breaker:     newCircuitBreaker(proxyConfig.Name, proxyConfig.CircuitBreaker),
 	}, nil
 }
 
+// NewPassthroughEngine forwards requests to the proxy's target the way a recording proxy does,
+// but saves nothing
+func NewPassthroughEngine(proxyConfig config.ProxyConfig, db *storage.Database, webServer WebBroadcaster) (*ProxyEngine, error) {
+	engine, err := NewProxyEngineWithBroadcaster(proxyConfig, db, webServer)
+	if err != nil {
+		return nil, err
+	}
+	engine.restHandler = NewRESTHandler([]string{}) // Bodies are only kept for previews, never spooled to body files
+	engine.passthrough = true
+	return engine, nil
+}
+
+// Retries reports whether the engine may send a request to its target more than once, so its body
+// must be kept rather than streamed
+func (p *ProxyEngine) Retries() bool {
+	return p.retry != nil
+}
+
+// SetRecordFilter limits the requests the engine saves to those a filter selects; the rest are
+// forwarded as a passthrough engine would
+func (p *ProxyEngine) SetRecordFilter(filter *RecordFilter) {
+	p.filter = filter
+}
+
+// SetRedactor masks what a redactor matches in the headers, bodies, stream chunks, and WebSocket
+// frames the engine saves
+func (p *ProxyEngine) SetRedactor(redactor *redact.Redactor) {
+	p.restHandler.SetRedactor(redactor)
+	p.grpcHandler.SetRedactor(redactor)
+}
+
+// SetSessionRotation records to the sessions of a rotation, starting with the engine's own
+func (p *ProxyEngine) SetSessionRotation(rotation *SessionRotation) {
+	p.rotation = rotation
+	if rotation != nil {
+		rotation.start(p.session)
+	}
+}
+
+// SetDeduper skips saving exchanges the session already holds, as a deduper finds them
+func (p *ProxyEngine) SetDeduper(dedupe *Deduper) {
+	p.dedupe = dedupe
+}
+
+// LimitBodies records at most request bytes of each request body and response bytes of each
+// response body; see RESTHandler.LimitBodies
+func (p *ProxyEngine) LimitBodies(request, response int64) {
+	p.restHandler.LimitBodies(request, response)
+}
+
 func (p *ProxyEngine) Start() error {
 	address := "0.0.0.0:8080" // This method shouldn't be used in multi-proxy mode
 
@@ -132,11 +195,34 @@ func (p *ProxyEngine) startGRPCServer(address string) error {
 
 // HandleRequest implements the ProxyHandler interface
 func (p *ProxyEngine) HandleRequest(w http.ResponseWriter, r *http.Request) {
+	if p.rotation != nil && !p.passthrough {
+		// Each request records to the session current when it arrives
+		rotated := *p
+		rotated.session = p.rotation.current(p.database)
+		rotated.handleRequest(w, r)
+		return
+	}
 	p.handleRequest(w, r)
 }
 
 func (p *ProxyEngine) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if !p.passthrough && !p.filter.Records(r.Method, r.URL.Path) {
+		unrecorded := *p
+		unrecorded.restHandler = NewRESTHandler([]string{}) // Bodies are only kept for previews, never spooled to body files
+		unrecorded.passthrough = true
+		unrecorded.handleRequest(w, r)
+		return
+	}
+
 	log.Printf("[%s] %s %s", r.Method, r.URL.Path, r.RemoteAddr)
+	startTime := time.Now()
+
+	consumerName := consumer.FromRequest(r, p.proxyConfig.Consumers)
+	if header := consumer.Header(p.proxyConfig.Consumers); consumer.Internal(header) {
+		r.Header.Del(header)
+	}
+	tags := tag.FromRequest(r)
+	r.Header.Del(tag.Header)
 
 	interaction, err := p.restHandler.ExtractRequest(r)
 	if err != nil {
@@ -146,80 +232,221 @@ func (p *ProxyEngine) handleRequest(w http.ResponseWriter, r *http.Request) {
 	}
 
 	interaction.SessionID = p.session.ID
+	if err := consumer.Tag(interaction, consumerName); err != nil {
+		log.Printf("Error attributing interaction to consumer: %v", err)
+	}
+	if err := tag.Apply(interaction, tags); err != nil {
+		log.Printf("Error tagging interaction: %v", err)
+	}
+	accesslog.Annotate(r.Context(), p.session.SessionName, "")
 
 	// Broadcast request event if web server is available
 	if p.webServer != nil {
 		var requestHeaders map[string]interface{}
 		json.Unmarshal([]byte(interaction.RequestHeaders), &requestHeaders)
-		body := string(interaction.RequestBody)
-		p.webServer.BroadcastRequest(interaction.Method, interaction.Endpoint, p.session.SessionName, r.RemoteAddr, interaction.RequestID, requestHeaders, body)
+		body := bodyPreview(interaction.RequestBody, interaction.RequestBodyFile())
+		if _, ok := r.Body.(*StreamedBody); ok {
+			body = "[Body streamed to the target]"
+		}
+		p.webServer.BroadcastRequest(p.proxyConfig.Name, interaction.Method, interaction.Endpoint, p.session.SessionName, r.RemoteAddr, interaction.RequestID, requestHeaders, body)
 	}
 
-	// Build target URL using the (possibly modified) URL path and query string
-	targetPath := r.URL.Path
+	// Build target URL using the (possibly modified) URL path and query string, in the target's addressing
+	targetPath := rewritePath(r.URL.Path, p.proxyConfig.Rewrite)
 	if r.URL.RawQuery != "" {
 		targetPath += "?" + r.URL.RawQuery
 	}
 
+	if websocket.IsWebSocketUpgrade(r) {
+		p.handleWebSocket(w, r, interaction, targetPath, startTime)
+		return
+	}
+
 	targetURL := fmt.Sprintf("%s://%s:%d%s",
 		p.proxyConfig.Protocol,
 		p.proxyConfig.TargetHost,
 		p.proxyConfig.TargetPort,
 		targetPath)
 
+	// A target that keeps failing is not sent more requests until it has had time to recover
+	if allowed, wait := p.breaker.allow(); !allowed {
+		if streamed, ok := r.Body.(*StreamedBody); ok {
+			streamed.discard()
+		}
+		metrics.RecordCircuitRejection(p.proxyConfig.Name)
+		accesslog.Annotate(r.Context(), "", accesslog.MatchPassthrough)
+		w.Header().Set("Retry-After", retryAfterSeconds(wait))
+		http.Error(w, "Service Unavailable: circuit open", http.StatusServiceUnavailable)
+		return
+	}
+
 	proxyReq, err := p.restHandler.CopyRequest(r, targetURL)
 	if err != nil {
+		p.breaker.abandon()
 		log.Printf("Error copying request: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
-
-	resp, err := p.client.Do(proxyReq)
+	applyForwarding(proxyReq, r, p.proxyConfig)
+	applyRewriteHeaders(proxyReq, p.proxyConfig.Rewrite)
+
+	resp, retried, err := p.forward(proxyReq)
+	if len(retried) > 0 {
+		log.Printf("Sent %s %s %d times: %s", interaction.Method, interaction.Endpoint, len(retried)+1, strings.Join(retried, "; "))
+		if err := interaction.SetMetadataValue(storage.MetadataAttempts, len(retried)+1); err != nil {
+			log.Printf("Error recording attempts: %v", err)
+		}
+		if err := interaction.SetMetadataValue(storage.MetadataRetries, retried); err != nil {
+			log.Printf("Error recording retries: %v", err)
+		}
+	}
 	if err != nil {
+		if streamed, ok := r.Body.(*StreamedBody); ok {
+			streamed.discard()
+		}
+		if IsBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+			return
+		}
 		log.Printf("Error forwarding request: %v", err)
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 		return
 	}
 	defer resp.Body.Close()
+	if p.proxyConfig.Rewrite.Location {
+		rewriteLocations(resp.Header, r, p.proxyConfig)
+	}
 
-	// Check if streaming is enabled for this proxy and response is SSE
-	if p.proxyConfig.EnableStreaming && p.restHandler.IsStreamingResponse(resp) {
-		log.Printf("Streaming enabled - handling SSE response for %s %s", interaction.Method, interaction.Endpoint)
-		p.handleStreamingResponse(w, r, resp, interaction)
+	// Check if streaming is enabled for this proxy and the response is a stream
+	if p.streamsResponse(resp) {
+		log.Printf("Streaming enabled - handling streamed response for %s %s", interaction.Method, interaction.Endpoint)
+		if err := p.restHandler.finishStreamedRequest(r, interaction); err != nil {
+			log.Printf("Error storing request body: %v", err)
+		}
+		p.handleStreamingResponse(w, r, resp, interaction, startTime)
 		return
 	}
 
-	status, headers, body, err := p.restHandler.ExtractResponse(resp)
+	headers, err := p.restHandler.ExtractResponseHeaders(resp)
 	if err != nil {
 		log.Printf("Error extracting response: %v", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	interaction.ResponseStatus = status
+	// Stream the body to the client as it arrives, keeping a bounded copy for the recording
+	capture := p.restHandler.newResponseCapture()
+	if err := p.restHandler.teeResponse(resp, w, capture); err != nil {
+		log.Printf("Error copying response: %v", err)
+	}
+	if err := p.restHandler.finishStreamedRequest(r, interaction); err != nil {
+		log.Printf("Error storing request body: %v", err)
+	}
+
+	interaction.ResponseStatus = resp.StatusCode
 	interaction.ResponseHeaders = headers
-	interaction.ResponseBody = body
+	if err := capture.storeResponse(interaction); err != nil {
+		log.Printf("Error storing response body file: %v", err)
+		capture.truncated = true
+	}
+	if err := interaction.SetTiming(startTime, time.Since(startTime)); err != nil {
+		log.Printf("Error recording interaction timing: %v", err)
+	}
+	if capture.truncated {
+		log.Printf("Recorded body of %s %s is incomplete (%d bytes kept)", interaction.Method, interaction.Endpoint, capture.size)
+		if err := interaction.SetMetadataValue(storage.MetadataResponseTruncated, true); err != nil {
+			log.Printf("Error marking truncated response: %v", err)
+		}
+		// A failed copy leaves the full size unknown
+		if capture.seen > capture.size {
+			if err := interaction.SetMetadataValue(storage.MetadataResponseFullSize, capture.seen); err != nil {
+				log.Printf("Error recording full response size: %v", err)
+			}
+		}
+	}
+	// Compressed bodies are recorded decompressed, so they can be read, edited, and matched
+	if err := interaction.DecompressBodies(); err != nil {
+		log.Printf("Error recording decompressed bodies: %v", err)
+	}
+	p.restHandler.redactBodies(interaction)
 
 	// Broadcast response event if web server is available
 	if p.webServer != nil {
 		var responseHeaders map[string]interface{}
 		json.Unmarshal([]byte(interaction.ResponseHeaders), &responseHeaders)
-		responseBody := string(interaction.ResponseBody)
-		p.webServer.BroadcastResponse(interaction.Method, interaction.Endpoint, p.session.SessionName, r.RemoteAddr, interaction.RequestID, status, responseHeaders, responseBody)
+		responseBody := bodyPreview(interaction.ResponseBody, interaction.ResponseBodyFile())
+		p.webServer.BroadcastResponse(p.proxyConfig.Name, interaction.Method, interaction.Endpoint, p.session.SessionName, r.RemoteAddr, interaction.RequestID, interaction.ResponseStatus, responseHeaders, responseBody)
 	}
 
-	if err := p.database.RecordInteraction(interaction); err != nil {
-		log.Printf("Error recording interaction: %v", err)
+	if p.passthrough {
+		log.Printf("Passed through interaction: %s %s -> %d", interaction.Method, interaction.Endpoint, interaction.ResponseStatus)
+		accesslog.Annotate(r.Context(), "", accesslog.MatchPassthrough)
+		return
+	}
+	if !p.filter.RecordsStatus(interaction.ResponseStatus) {
+		log.Printf("Not recording %s %s -> %d: status left out by the recording filters", interaction.Method, interaction.Endpoint, interaction.ResponseStatus)
+		accesslog.Annotate(r.Context(), "", accesslog.MatchPassthrough)
+		return
+	}
+	if !p.sampled(interaction.ResponseStatus) {
+		accesslog.Annotate(r.Context(), "", accesslog.MatchPassthrough)
+		return
+	}
+
+	saved := true
+	if p.dedupe != nil {
+		saved, err = p.dedupe.save(p.database, interaction)
 	} else {
-		log.Printf("Recorded interaction: %s %s -> %d", interaction.Method, interaction.Endpoint, interaction.ResponseStatus)
+		err = p.database.RecordInteraction(interaction)
 	}
+	if err != nil {
+		log.Printf("Error recording interaction: %v", err)
+		return
+	}
+	if !saved {
+		log.Printf("Not recording %s %s -> %d: the session already holds it as interaction %d", interaction.Method, interaction.Endpoint, interaction.ResponseStatus, interaction.ID)
+		accesslog.Annotate(r.Context(), "", accesslog.MatchPassthrough)
+		return
+	}
+	log.Printf("Recorded interaction: %s %s -> %d", interaction.Method, interaction.Endpoint, interaction.ResponseStatus)
+	metrics.RecordInteraction(p.proxyConfig.Name, recordedBodyBytes(interaction))
+	p.rotation.add(recordedBodyBytes(interaction))
+	webhook.RecordingComplete(p.proxyConfig.Name, p.session.SessionName, interaction)
+	accesslog.Annotate(r.Context(), "", accesslog.MatchRecorded)
+	checkConformance(p.proxyConfig.Name, p.session.SessionName, interaction)
+}
 
-	if err := p.restHandler.CopyResponse(resp, w); err != nil {
-		log.Printf("Error copying response: %v", err)
+// sampled picks whether a response the filters keep is recorded, counting those it passes over
+func (p *ProxyEngine) sampled(status int) bool {
+	if p.filter.Samples(status) {
+		return true
+	}
+	metrics.RecordSampledOut(p.proxyConfig.Name)
+	return false
+}
+
+// streamsResponse reports whether a response is forwarded and recorded chunk by chunk: SSE, the
+// configured streaming content types, and with stream_chunked any response without a length
+func (p *ProxyEngine) streamsResponse(resp *http.Response) bool {
+	if !p.proxyConfig.EnableStreaming {
+		return false
+	}
+	if p.restHandler.IsStreamingResponse(resp) {
+		return true
+	}
+	if p.proxyConfig.StreamChunked && resp.ContentLength < 0 && resp.Body != http.NoBody {
+		return true
 	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	for _, contentType := range p.proxyConfig.StreamingContentTypes {
+		if strings.EqualFold(mediaType, contentType) {
+			return true
+		}
+	}
+	return false
 }
 
-func (p *ProxyEngine) handleStreamingResponse(w http.ResponseWriter, r *http.Request, resp *http.Response, interaction *storage.Interaction) {
+func (p *ProxyEngine) handleStreamingResponse(w http.ResponseWriter, r *http.Request, resp *http.Response, interaction *storage.Interaction, startTime time.Time) {
 	// Extract response headers
 	headers := make(map[string]string)
 	for key, values := range resp.Header {
@@ -234,8 +461,22 @@ func (p *ProxyEngine) handleStreamingResponse(w http.ResponseWriter, r *http.Req
 	}
 
 	interaction.ResponseStatus = resp.StatusCode
-	interaction.ResponseHeaders = string(headersJSON)
+	interaction.ResponseHeaders = p.restHandler.redactSensitiveData(string(headersJSON))
+	p.restHandler.redactBodies(interaction)
 	interaction.IsStreaming = true
+	// Duration is provisional (time to headers) until the stream finishes
+	if err := interaction.SetTiming(startTime, time.Since(startTime)); err != nil {
+		log.Printf("Error recording interaction timing: %v", err)
+	}
+
+	// Streams the recording filters or sampling leave out are forwarded the way passthrough engines do
+	if p.passthrough || !p.filter.RecordsStatus(resp.StatusCode) || !p.sampled(resp.StatusCode) {
+		accesslog.Annotate(r.Context(), "", accesslog.MatchPassthrough)
+		if err := p.restHandler.copyStreamingResponse(resp, w, func(*SSEChunk) {}); err != nil {
+			log.Printf("Error copying streaming response: %v", err)
+		}
+		return
+	}
 
 	// Record the interaction first (without response body for streaming)
 	if err := p.database.RecordInteraction(interaction); err != nil {
@@ -243,50 +484,61 @@ func (p *ProxyEngine) handleStreamingResponse(w http.ResponseWriter, r *http.Req
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
+	accesslog.Annotate(r.Context(), "", accesslog.MatchRecorded)
 
 	log.Printf("Recorded streaming interaction: %s %s (ID: %d)", interaction.Method, interaction.Endpoint, interaction.ID)
 
-	// Copy and capture the streaming response
-	chunks, err := p.restHandler.CopyStreamingResponse(resp, w)
-	if err != nil {
+	// Copy and capture the streaming response, spooling long streams to disk
+	chunks := newChunkSpool(p.database.BodyFiles())
+	defer chunks.remove()
+	collect := func(chunk *SSEChunk) {
+		// The chunk is forwarded after it is collected, so only the recorded copy is masked
+		recorded := *chunk
+		recorded.RawData = p.restHandler.redactor.Bytes(chunk.RawData)
+		chunks.add(&recorded)
+	}
+	if err := p.restHandler.copyStreamingResponse(resp, w, collect); err != nil {
 		// Check if it's a broken pipe (client disconnected)
 		if strings.Contains(err.Error(), "broken pipe") || strings.Contains(err.Error(), "connection reset") {
-			log.Printf("Client disconnected during streaming response (captured %d chunks before disconnect)", len(chunks))
+			log.Printf("Client disconnected during streaming response (captured %d chunks before disconnect)", chunks.len())
 		} else {
 			log.Printf("Error copying streaming response: %v", err)
 		}
 		// Continue to save whatever chunks we captured before the error
 	}
 
-	log.Printf("Captured %d streaming chunks for %s %s", len(chunks), interaction.Method, interaction.Endpoint)
+	log.Printf("Captured %d streaming chunks for %s %s", chunks.len(), interaction.Method, interaction.Endpoint)
 
-	// Store all chunks atomically in a single transaction
-	streamChunks := make([]*storage.StreamChunk, len(chunks))
-	for i, chunk := range chunks {
-		streamChunks[i] = &storage.StreamChunk{
-			InteractionID: interaction.ID,
-			ChunkIndex:    i,
-			Data:          chunk.RawData,
-			Timestamp:     chunk.Timestamp,
-			TimeDelta:     chunk.TimeDelta,
-		}
+	if err := p.database.UpdateInteractionMetadata(interaction.ID, map[string]interface{}{
+		storage.MetadataDurationMs: float64(time.Since(startTime)) / float64(time.Millisecond),
+	}); err != nil {
+		log.Printf("Error recording streaming duration: %v", err)
 	}
 
-	// Use transactional batch insertion to ensure atomicity
-	if err := p.database.RecordStreamChunks(streamChunks); err != nil {
+	metrics.RecordInteraction(p.proxyConfig.Name, recordedBodyBytes(interaction)+int(chunks.size()))
+	p.rotation.add(recordedBodyBytes(interaction) + int(chunks.size()))
+
+	// Store all chunks atomically in a single transaction, reading spooled ones back one at a time
+	if err := p.database.RecordStreamChunksFrom(chunks.len(), func(i int) (*storage.StreamChunk, error) {
+		return chunks.streamChunk(interaction.ID, i)
+	}); err != nil {
 		log.Printf("Error recording stream chunks atomically: %v", err)
 		// Mark interaction as failed since no chunks were persisted
 		if err := p.database.MarkInteractionAsPartial(interaction.ID, []int{}); err != nil {
 			log.Printf("Error marking interaction as partial: %v", err)
 		}
+	} else {
+		metrics.RecordStreamChunks(p.proxyConfig.Name, chunks.len())
 	}
+	webhook.RecordingComplete(p.proxyConfig.Name, p.session.SessionName, interaction)
+	checkConformance(p.proxyConfig.Name, p.session.SessionName, interaction)
 
 	// Broadcast streaming completion if web server is available
 	if p.webServer != nil {
 		var responseHeaders map[string]interface{}
 		json.Unmarshal([]byte(interaction.ResponseHeaders), &responseHeaders)
-		responseBody := fmt.Sprintf("[Streaming response with %d chunks]", len(chunks))
-		p.webServer.BroadcastResponse(interaction.Method, interaction.Endpoint, p.session.SessionName, r.RemoteAddr, interaction.RequestID, resp.StatusCode, responseHeaders, responseBody)
+		responseBody := fmt.Sprintf("[Streaming response with %d chunks]", chunks.len())
+		p.webServer.BroadcastResponse(p.proxyConfig.Name, interaction.Method, interaction.Endpoint, p.session.SessionName, r.RemoteAddr, interaction.RequestID, resp.StatusCode, responseHeaders, responseBody)
 	}
 }
 
@@ -300,3 +552,34 @@ func (p *ProxyEngine) Stop() error {
 func (p *ProxyEngine) GetGRPCServer() *grpc.Server {
 	return p.grpcServer
 }
+
+// recordedBodyBytes is the size of an interaction's bodies, whether in the database or in body files
+func recordedBodyBytes(interaction *storage.Interaction) int {
+	size := len(interaction.RequestBody) + len(interaction.ResponseBody)
+	metadata := interaction.MetadataMap()
+	for _, key := range []string{storage.MetadataRequestBodySize, storage.MetadataResponseBodySize} {
+		if n, ok := metadata[key].(float64); ok {
+			size += int(n)
+		}
+	}
+	return size
+}
+
+// bodyPreview is a body as shown in the web UI's live feed, which does not load body files
+func bodyPreview(body []byte, file string) string {
+	if file != "" {
+		return fmt.Sprintf("[Body stored in file %s]", file)
+	}
+	return string(body)
+}
+
+// checkConformance checks a recorded exchange against the proxy's OpenAPI spec, if it has one
+func checkConformance(proxyName, sessionName string, interaction *storage.Interaction) {
+	exchange, ok := conformance.ExchangeFromInteraction(interaction)
+	if !ok {
+		return
+	}
+	for _, violation := range conformance.Observe(proxyName, sessionName, exchange) {
+		log.Printf("OpenAPI violation: %s %s: %s", violation.Method, violation.Path, violation.Message)
+	}
+}
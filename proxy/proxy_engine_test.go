@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mimic/config"
+	"mimic/redact"
+	"mimic/storage"
+)
+
+func TestProxyEngineRedactsRecordings(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer live-token" {
+			t.Errorf("Expected the upstream to get the real credentials, got %q", r.Header.Get("Authorization"))
+		}
+		w.Header().Set("Set-Cookie", "session=abc123")
+		w.Write([]byte(`{"owner":"ada@example.com"}`))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "billing", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "redacted"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+	redactor, err := redact.New(nil, []string{redact.PresetAuthorization, redact.PresetCreditCard, redact.PresetEmail})
+	if err != nil {
+		t.Fatalf("Failed to build redactor: %v", err)
+	}
+	engine.SetRedactor(redactor)
+
+	req := httptest.NewRequest("POST", "/charges", strings.NewReader(`{"card":"4111111111111111"}`))
+	req.Header.Set("Authorization", "Bearer live-token")
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, req)
+
+	// The client gets the response as it was sent
+	if body, _ := io.ReadAll(recorder.Body); string(body) != `{"owner":"ada@example.com"}` {
+		t.Errorf("Expected the unredacted response, got %s", body)
+	}
+
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected one recorded interaction, got %d (%v)", len(interactions), err)
+	}
+	recorded := interactions[0]
+	if strings.Contains(recorded.RequestHeaders, "live-token") || !strings.Contains(recorded.RequestHeaders, redact.Replacement) {
+		t.Errorf("Expected the Authorization header redacted, got %s", recorded.RequestHeaders)
+	}
+	if strings.Contains(recorded.ResponseHeaders, "abc123") {
+		t.Errorf("Expected the Set-Cookie header redacted, got %s", recorded.ResponseHeaders)
+	}
+	if string(recorded.RequestBody) != `{"card":"[REDACTED]"}` {
+		t.Errorf("Expected the card number redacted, got %s", recorded.RequestBody)
+	}
+	if string(recorded.ResponseBody) != `{"owner":"[REDACTED]"}` {
+		t.Errorf("Expected the email redacted, got %s", recorded.ResponseBody)
+	}
+}
+
+func TestProxyEngineRecordsTags(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Mimic-Tag") != "" {
+			t.Errorf("Expected the tag header not to be forwarded, got %q", r.Header.Get("X-Mimic-Tag"))
+		}
+		w.Write([]byte(`{"status":"paid"}`))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "shop", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "tagged"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/orders", strings.NewReader(`{}`))
+	req.Header.Set("X-Mimic-Tag", "checkout-happy-path")
+	engine.HandleRequest(httptest.NewRecorder(), req)
+
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected one recorded interaction, got %d (%v)", len(interactions), err)
+	}
+	if tags := interactions[0].Tags(); len(tags) != 1 || tags[0] != "checkout-happy-path" {
+		t.Errorf("Expected the interaction tagged checkout-happy-path, got %q", tags)
+	}
+	if strings.Contains(interactions[0].RequestHeaders, "X-Mimic-Tag") {
+		t.Errorf("Expected the tag header left out of the recording, got %s", interactions[0].RequestHeaders)
+	}
+}
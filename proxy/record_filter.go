@@ -0,0 +1,168 @@
+package proxy
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"mimic/config"
+)
+
+// RecordFilter decides which requests a recording proxy saves, from recording.include and
+// recording.exclude, and which responses, from recording.keep_statuses,
+// recording.skip_statuses, and recording.sample. Requests it leaves out are still forwarded.
+type RecordFilter struct {
+	include []*endpointPattern
+	exclude []*endpointPattern
+	keep    []string // Status patterns such as 5xx or 404, in lower case
+	skip    []string
+	rate    float64 // Share of responses sampled, 1 for all
+	rates   []statusRate
+	random  func() float64 // Uniform in [0, 1)
+}
+
+// statusRate is a compiled config.StatusRate
+type statusRate struct {
+	pattern string
+	rate    float64
+}
+
+// endpointPattern is a compiled config.EndpointFilter
+type endpointPattern struct {
+	method string
+	path   *regexp.Regexp
+}
+
+// NewRecordFilter compiles the filters of a recording config, or returns nil when it sets none
+func NewRecordFilter(cfg config.RecordingConfig) (*RecordFilter, error) {
+	if len(cfg.Include) == 0 && len(cfg.Exclude) == 0 && len(cfg.KeepStatuses) == 0 && len(cfg.SkipStatuses) == 0 &&
+		cfg.Sample.Rate == 0 && len(cfg.Sample.Statuses) == 0 {
+		return nil, nil
+	}
+	include, err := compileEndpointFilters(cfg.Include)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recording include: %w", err)
+	}
+	exclude, err := compileEndpointFilters(cfg.Exclude)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recording exclude: %w", err)
+	}
+	filter := &RecordFilter{include: include, exclude: exclude, rate: cfg.Sample.Rate, random: rand.Float64}
+	if filter.rate == 0 {
+		filter.rate = 1
+	}
+	for _, rule := range cfg.Sample.Statuses {
+		filter.rates = append(filter.rates, statusRate{pattern: strings.ToLower(rule.Status), rate: rule.Rate})
+	}
+	for _, pattern := range cfg.KeepStatuses {
+		filter.keep = append(filter.keep, strings.ToLower(pattern))
+	}
+	for _, pattern := range cfg.SkipStatuses {
+		filter.skip = append(filter.skip, strings.ToLower(pattern))
+	}
+	return filter, nil
+}
+
+func compileEndpointFilters(filters []config.EndpointFilter) ([]*endpointPattern, error) {
+	patterns := make([]*endpointPattern, 0, len(filters))
+	for i, filter := range filters {
+		expr := filter.PathRegex
+		if expr != "" {
+			expr = "^(?:" + expr + ")$"
+		} else {
+			expr = config.GlobPattern(filter.Path)
+		}
+		path, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, fmt.Errorf("filter %d: %w", i, err)
+		}
+		patterns = append(patterns, &endpointPattern{method: strings.ToUpper(filter.Method), path: path})
+	}
+	return patterns, nil
+}
+
+// covers reports whether the pattern applies to a request; gRPC calls have no method
+func (e *endpointPattern) covers(method, path string) bool {
+	if e.method != "" && e.method != strings.ToUpper(method) {
+		return false
+	}
+	return e.path.MatchString(path)
+}
+
+// Records reports whether a request is saved: when no exclude filter covers it and, if there are
+// include filters, one of them does. A nil filter records everything.
+func (f *RecordFilter) Records(method, path string) bool {
+	if f == nil {
+		return true
+	}
+	for _, pattern := range f.exclude {
+		if pattern.covers(method, path) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if pattern.covers(method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordsStatus reports whether a response with a status is saved: when no skip pattern covers it
+// and, if there are keep patterns, one of them does. A nil filter records every status.
+func (f *RecordFilter) RecordsStatus(status int) bool {
+	if f == nil {
+		return true
+	}
+	for _, pattern := range f.skip {
+		if statusCovered(pattern, status) {
+			return false
+		}
+	}
+	if len(f.keep) == 0 {
+		return true
+	}
+	for _, pattern := range f.keep {
+		if statusCovered(pattern, status) {
+			return true
+		}
+	}
+	return false
+}
+
+// Samples picks whether a response the filter keeps is saved, at the rate of the first sample rule
+// covering its status or else the base rate. gRPC codes are covered by no rule. A nil filter
+// samples every response.
+func (f *RecordFilter) Samples(status int) bool {
+	if f == nil {
+		return true
+	}
+	rate := f.rate
+	for _, rule := range f.rates {
+		if statusCovered(rule.pattern, status) {
+			rate = rule.rate
+			break
+		}
+	}
+	return rate >= 1 || f.random() < rate
+}
+
+// statusCovered reports whether a status pattern, a class such as 5xx or a status such as 404,
+// covers a status
+func statusCovered(pattern string, status int) bool {
+	code := strconv.Itoa(status)
+	if len(code) != len(pattern) {
+		return false
+	}
+	for i := range pattern {
+		if pattern[i] != 'x' && pattern[i] != code[i] {
+			return false
+		}
+	}
+	return true
+}
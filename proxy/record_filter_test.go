@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestRecordFilter(t *testing.T) {
+	filter, err := NewRecordFilter(config.RecordingConfig{
+		Include: []config.EndpointFilter{{Path: "/api/**"}, {PathRegex: "/grpc\\.health\\.v1\\.Health/.*"}},
+		Exclude: []config.EndpointFilter{{Path: "/api/static/**"}, {Path: "/api/users", Method: "delete"}, {Path: "/grpc.health.v1.Health/Watch"}},
+	})
+	if err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+
+	tests := []struct {
+		method, path string
+		expected     bool
+	}{
+		{"GET", "/api/users", true},
+		{"DELETE", "/api/users", false},
+		{"GET", "/api/static/app.js", false},
+		{"GET", "/health", false},
+		{"", "/grpc.health.v1.Health/Check", true},
+		{"", "/grpc.health.v1.Health/Watch", false},
+	}
+	for _, tt := range tests {
+		if recorded := filter.Records(tt.method, tt.path); recorded != tt.expected {
+			t.Errorf("%s %s: expected recorded=%v, got %v", tt.method, tt.path, tt.expected, recorded)
+		}
+	}
+
+	statuses, err := NewRecordFilter(config.RecordingConfig{KeepStatuses: []string{"2xx", "4XX"}, SkipStatuses: []string{"429"}})
+	if err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+	for status, expected := range map[int]bool{200: true, 204: true, 404: true, 429: false, 302: false, 503: false} {
+		if recorded := statuses.RecordsStatus(status); recorded != expected {
+			t.Errorf("Status %d: expected recorded=%v, got %v", status, expected, recorded)
+		}
+	}
+
+	if filter, err := NewRecordFilter(config.RecordingConfig{}); err != nil || filter != nil || !filter.Records("GET", "/anything") {
+		t.Errorf("Expected no filter to record everything, got %v, %v", filter, err)
+	}
+}
+
+func TestRecordFilterSamples(t *testing.T) {
+	filter, err := NewRecordFilter(config.RecordingConfig{Sample: config.SampleConfig{
+		Rate:     0.05,
+		Statuses: []config.StatusRate{{Status: "5xx", Rate: 1}, {Status: "404", Rate: 0.5}, {Status: "4xx", Rate: 1}},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+
+	tests := []struct {
+		status   int
+		draw     float64
+		expected bool
+	}{
+		{200, 0.01, true},
+		{200, 0.2, false},
+		{503, 0.99, true},
+		{404, 0.4, true}, // The first rule covering the status applies
+		{404, 0.6, false},
+		{400, 0.99, true},
+		{0, 0.2, false}, // gRPC codes are sampled at the base rate
+	}
+	for _, tt := range tests {
+		filter.random = func() float64 { return tt.draw }
+		if sampled := filter.Samples(tt.status); sampled != tt.expected {
+			t.Errorf("Status %d with draw %g: expected sampled=%v, got %v", tt.status, tt.draw, tt.expected, sampled)
+		}
+	}
+
+	// Rules alone leave the other statuses sampled in full
+	successes, err := NewRecordFilter(config.RecordingConfig{Sample: config.SampleConfig{Statuses: []config.StatusRate{{Status: "2xx", Rate: 0.1}}}})
+	if err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+	successes.random = func() float64 { return 0.5 }
+	if successes.Samples(200) || !successes.Samples(500) {
+		t.Error("Expected only 2xx responses to be sampled")
+	}
+}
+
+func TestProxyEngineForwardsFilteredRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/flaky" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		w.Write([]byte("ok " + r.URL.Path))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "api", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "filtered"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+	filter, err := NewRecordFilter(config.RecordingConfig{Exclude: []config.EndpointFilter{{Path: "/health"}}, SkipStatuses: []string{"5xx"}})
+	if err != nil {
+		t.Fatalf("Failed to compile filter: %v", err)
+	}
+	engine.SetRecordFilter(filter)
+
+	for _, path := range []string{"/health", "/users", "/flaky"} {
+		w := httptest.NewRecorder()
+		engine.HandleRequest(w, httptest.NewRequest("GET", path, nil))
+		if w.Body.String() != "ok "+path {
+			t.Errorf("Expected %s to be forwarded, got %d %q", path, w.Code, w.Body.String())
+		}
+	}
+
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 1 || interactions[0].Endpoint != "/users" {
+		t.Errorf("Expected only /users to be recorded, got %v, %v", interactions, err)
+	}
+}
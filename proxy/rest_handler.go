@@ -2,6 +2,8 @@ package proxy
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -10,23 +12,78 @@ import (
 	"strings"
 	"time"
 
+	"mimic/redact"
 	"mimic/storage"
 
 	"github.com/google/uuid"
 )
 
 type RESTHandler struct {
-	redactPatterns []*regexp.Regexp
+	redactor *redact.Redactor // Masks secrets in what is recorded; nil records everything as it is
+
+	// Where bodies too large for the database are recorded, if anywhere; see StoreLargeBodies
+	bodyFiles    *storage.BodyFiles
+	maxBodyBytes int64
+
+	// Lower limits for each direction, from recording.max_request_body_bytes and
+	// recording.max_response_body_bytes; see LimitBodies
+	maxRequestBytes  int64
+	maxResponseBytes int64
 }
 
 func NewRESTHandler(redactPatterns []string) *RESTHandler {
-	patterns := make([]*regexp.Regexp, len(redactPatterns))
-	for i, pattern := range redactPatterns {
-		if compiled, err := regexp.Compile(pattern); err == nil {
-			patterns[i] = compiled
-		}
+	redactor, _ := redact.New(redactPatterns, nil) // Without presets only bad patterns can fail, and those are skipped
+	return &RESTHandler{redactor: redactor}
+}
+
+// SetRedactor masks what a redactor matches in the headers and bodies the handler records
+func (h *RESTHandler) SetRedactor(redactor *redact.Redactor) {
+	h.redactor = redactor
+}
+
+// Redactor is what the handler masks recordings with, or nil
+func (h *RESTHandler) Redactor() *redact.Redactor {
+	if h == nil {
+		return nil
+	}
+	return h.redactor
+}
+
+// StoreLargeBodies records bodies up to the database's size limit, writing those beyond its
+// threshold to body files. Without it at most DefaultRecordBufferBytes of a body is kept, in memory.
+func (h *RESTHandler) StoreLargeBodies(db *storage.Database) {
+	h.bodyFiles = db.BodyFiles()
+	h.maxBodyBytes = db.MaxRecordedBodyBytes()
+}
+
+// LimitBodies records at most request bytes of request bodies and response bytes of response
+// bodies, where lower than the database's limit; 0 leaves a direction at that limit
+func (h *RESTHandler) LimitBodies(request, response int64) {
+	h.maxRequestBytes = request
+	h.maxResponseBytes = response
+}
+
+// newCapture starts capturing a body for the recording, kept to limit bytes when that is set
+// and lower than the database's limit
+func (h *RESTHandler) newCapture(limit int64) *bodyCapture {
+	capture := newBodyCapture(h.maxBodyBytes, h.bodyFiles)
+	if h.maxBodyBytes == 0 {
+		capture = newBodyCapture(DefaultRecordBufferBytes, nil)
 	}
-	return &RESTHandler{redactPatterns: patterns}
+	if limit > 0 && limit < capture.limit {
+		capture.limit = limit
+	}
+	return capture
+}
+
+// newRequestCapture starts capturing a request body for the recording
+func (h *RESTHandler) newRequestCapture() *bodyCapture {
+	return h.newCapture(h.maxRequestBytes)
+}
+
+// newResponseCapture starts capturing a response body for the recording
+func (h *RESTHandler) newResponseCapture() *bodyCapture {
+	return h.newCapture(h.maxResponseBytes)
 }
 
 func (h *RESTHandler) ExtractRequest(req *http.Request) (*storage.Interaction, error) {
@@ -45,39 +102,86 @@ func (h *RESTHandler) ExtractRequest(req *http.Request) (*storage.Interaction, e
 	headersStr := string(headersJSON)
 	headersStr = h.redactSensitiveData(headersStr)
 
-	var body []byte
-	if req.Body != nil {
-		body, err = io.ReadAll(req.Body)
-		if err != nil {
-			return nil, fmt.Errorf("failed to read request body: %w", err)
-		}
-		req.Body = io.NopCloser(bytes.NewBuffer(body))
-	}
-
-	return &storage.Interaction{
+	interaction := &storage.Interaction{
 		RequestID:      requestID,
 		Protocol:       "REST",
 		Method:         req.Method,
 		Endpoint:       req.URL.Path,
 		RequestHeaders: headersStr,
-		RequestBody:    body,
 		Timestamp:      time.Now(),
-	}, nil
+	}
+	if req.URL.RawQuery != "" {
+		if err := interaction.SetMetadataValue(storage.MetadataQuery, req.URL.RawQuery); err != nil {
+			return nil, err
+		}
+	}
+
+	switch body := req.Body.(type) {
+	case *StreamedBody:
+		// The body is recorded as the upstream reads it; see finishStreamedRequest
+		body.captureInto(h.newRequestCapture())
+	case *SpooledBody:
+		// Keep a bounded copy for the recording; the whole body is forwarded from the spool
+		capture := h.newRequestCapture()
+		hash := sha256.New()
+		if _, err := io.Copy(io.MultiWriter(capture, hash), body.NewReader()); err != nil {
+			capture.discard()
+			return nil, fmt.Errorf("failed to read spooled body: %w", err)
+		}
+		if err := capture.storeRequest(interaction); err != nil {
+			return nil, err
+		}
+		if capture.truncated {
+			if err := markRequestTruncated(interaction, body.Size(), hash.Sum(nil)); err != nil {
+				return nil, err
+			}
+		}
+	case nil:
+	default:
+		bodyBytes, err := io.ReadAll(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes))
+		interaction.RequestBody = bodyBytes
+		if limit := h.maxRequestBytes; limit > 0 && int64(len(bodyBytes)) > limit {
+			interaction.RequestBody = bodyBytes[:limit:limit]
+			sum := sha256.Sum256(bodyBytes)
+			if err := markRequestTruncated(interaction, int64(len(bodyBytes)), sum[:]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return interaction, nil
 }
 
-func (h *RESTHandler) ExtractResponse(resp *http.Response) (int, string, []byte, error) {
-	headers := make(map[string]string)
-	for key, values := range resp.Header {
-		headers[key] = strings.Join(values, ", ")
+// markRequestTruncated notes that only part of the request body was recorded, along with the
+// size and SHA-256 of the whole body so that replays can still match it exactly
+func markRequestTruncated(interaction *storage.Interaction, fullSize int64, sum []byte) error {
+	if err := interaction.SetMetadataValue(storage.MetadataRequestTruncated, true); err != nil {
+		return err
 	}
+	if err := interaction.SetMetadataValue(storage.MetadataRequestFullSize, fullSize); err != nil {
+		return err
+	}
+	return interaction.SetMetadataValue(storage.MetadataRequestSHA256, hex.EncodeToString(sum))
+}
 
-	headersJSON, err := json.Marshal(headers)
-	if err != nil {
-		return 0, "", nil, fmt.Errorf("failed to marshal response headers: %w", err)
+// finishStreamedRequest records what the upstream read of a streamed request body. It is called
+// once the response is in, when the upstream is done with the body.
+func (h *RESTHandler) finishStreamedRequest(req *http.Request, interaction *storage.Interaction) error {
+	streamed, ok := req.Body.(*StreamedBody)
+	if !ok {
+		return nil
 	}
+	return streamed.finish(interaction)
+}
 
-	headersStr := string(headersJSON)
-	headersStr = h.redactSensitiveData(headersStr)
+func (h *RESTHandler) ExtractResponse(resp *http.Response) (int, string, []byte, error) {
+	headersStr, err := h.ExtractResponseHeaders(resp)
+	if err != nil {
+		return 0, "", nil, err
+	}
 
 	var body []byte
 	if resp.Body != nil {
@@ -91,6 +195,21 @@ func (h *RESTHandler) ExtractResponse(resp *http.Response) (int, string, []byte,
 	return resp.StatusCode, headersStr, body, nil
 }
 
+// ExtractResponseHeaders encodes the response headers for recording, without touching the body
+func (h *RESTHandler) ExtractResponseHeaders(resp *http.Response) (string, error) {
+	headers := make(map[string]string)
+	for key, values := range resp.Header {
+		headers[key] = strings.Join(values, ", ")
+	}
+
+	headersJSON, err := json.Marshal(headers)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal response headers: %w", err)
+	}
+
+	return h.redactSensitiveData(string(headersJSON)), nil
+}
+
 func (h *RESTHandler) CreateResponse(interaction *storage.Interaction) *http.Response {
 	body := io.NopCloser(bytes.NewBuffer(interaction.ResponseBody))
 
@@ -191,20 +310,23 @@ func (h *RESTHandler) isNumericOrUUID(s string) bool {
 }
 
 func (h *RESTHandler) redactSensitiveData(data string) string {
-	result := data
-	for _, pattern := range h.redactPatterns {
-		result = pattern.ReplaceAllString(result, "[REDACTED]")
-	}
-	return result
+	return h.redactor.Headers(data)
 }
 
-func (h *RESTHandler) GetRedactPatterns() []*regexp.Regexp {
-	return h.redactPatterns
+// redactBodies masks secrets in the recorded bodies of an interaction. Bodies recorded to body
+// files are left as they are.
+func (h *RESTHandler) redactBodies(interaction *storage.Interaction) {
+	interaction.RequestBody = h.redactor.Bytes(interaction.RequestBody)
+	interaction.ResponseBody = h.redactor.Bytes(interaction.ResponseBody)
 }
 
 func (h *RESTHandler) CopyRequest(req *http.Request, targetURL string) (*http.Request, error) {
 	var body io.Reader
-	if req.Body != nil {
+	if streamed, ok := req.Body.(*StreamedBody); ok {
+		body = streamed
+	} else if spooled, ok := req.Body.(*SpooledBody); ok {
+		body = spooled.NewReader()
+	} else if req.Body != nil {
 		bodyBytes, err := io.ReadAll(req.Body)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read request body: %w", err)
@@ -217,10 +339,20 @@ func (h *RESTHandler) CopyRequest(req *http.Request, targetURL string) (*http.Re
 	if err != nil {
 		return nil, fmt.Errorf("failed to create new request: %w", err)
 	}
+	if spooled, ok := req.Body.(*SpooledBody); ok {
+		newReq.ContentLength = spooled.Size()
+		newReq.GetBody = func() (io.ReadCloser, error) { return spooled.NewReader(), nil }
+	}
+	if _, ok := req.Body.(*StreamedBody); ok {
+		// Sent as it arrives, so it is only read once and a failed attempt cannot be retried
+		newReq.ContentLength = req.ContentLength
+	}
 
-	for key, values := range req.Header {
-		for _, value := range values {
-			newReq.Header.Add(key, value)
+	copyEndToEndHeaders(newReq.Header, req.Header)
+	// Keep "TE: trailers" like net/http/httputil does; upstreams use it to opt in to trailers
+	for _, value := range req.Header.Values("Te") {
+		if strings.EqualFold(strings.TrimSpace(value), "trailers") {
+			newReq.Header.Set("Te", "trailers")
 		}
 	}
 
@@ -228,11 +360,7 @@ func (h *RESTHandler) CopyRequest(req *http.Request, targetURL string) (*http.Re
 }
 
 func (h *RESTHandler) CopyResponse(resp *http.Response, writer http.ResponseWriter) error {
-	for key, values := range resp.Header {
-		for _, value := range values {
-			writer.Header().Add(key, value)
-		}
-	}
+	copyEndToEndHeaders(writer.Header(), resp.Header)
 
 	writer.WriteHeader(resp.StatusCode)
 
@@ -246,6 +374,44 @@ func (h *RESTHandler) CopyResponse(resp *http.Response, writer http.ResponseWrit
 	return nil
 }
 
+// TeeResponse streams the response to the client while capturing up to limit bytes of the body.
+// truncated reports whether the captured body is incomplete, either because it hit the limit or the copy failed.
+func (h *RESTHandler) TeeResponse(resp *http.Response, writer http.ResponseWriter, limit int) (body []byte, truncated bool, err error) {
+	capture := newBodyCapture(int64(limit), nil)
+	err = h.teeResponse(resp, writer, capture)
+	return capture.Bytes(), capture.truncated, err
+}
+
+// teeResponse is TeeResponse into a capture, which is marked truncated if the copy fails
+func (h *RESTHandler) teeResponse(resp *http.Response, writer http.ResponseWriter, capture *bodyCapture) error {
+	copyEndToEndHeaders(writer.Header(), resp.Header)
+	writer.WriteHeader(resp.StatusCode)
+
+	if resp.Body == nil {
+		return nil
+	}
+
+	var destination io.Writer = writer
+	if resp.ContentLength < 0 {
+		// Unknown length usually means a chunked stream; don't hold chunks back in the write buffer
+		flusher, _ := writer.(http.Flusher)
+		destination = &flushWriter{writer: writer, flusher: flusher}
+	}
+
+	if _, err := io.Copy(destination, io.TeeReader(resp.Body, capture)); err != nil {
+		capture.truncated = true
+		return fmt.Errorf("failed to stream response body: %w", err)
+	}
+
+	// Trailers are only known once the body is read, so they go out undeclared
+	for key, values := range resp.Trailer {
+		for _, value := range values {
+			writer.Header().Add(http.TrailerPrefix+key, value)
+		}
+	}
+	return nil
+}
+
 // IsStreamingResponse checks if a response is a streaming response (SSE)
 func (h *RESTHandler) IsStreamingResponse(resp *http.Response) bool {
 	contentType := resp.Header.Get("Content-Type")
@@ -267,28 +433,70 @@ func (h *RESTHandler) CaptureStreamingResponse(resp *http.Response) ([]*SSEChunk
 	return chunks, nil
 }
 
-// ReplayStreamingResponse replays a streaming response to a client
-func (h *RESTHandler) ReplayStreamingResponse(writer http.ResponseWriter, chunks []*SSEChunk, respectTiming bool) error {
+// StreamPacing says how replayed chunks follow the timing they were recorded with. The zero
+// value sends every chunk at once.
+type StreamPacing struct {
+	Speed    float64       // Playback speed: 1 keeps the recorded gaps, 2 halves them, 0.5 doubles them
+	MaxDelay time.Duration // Longest wait before any chunk, when set
+}
+
+// delay is how long to wait before a chunk recorded this long after the one before it
+func (p StreamPacing) delay(recorded time.Duration) time.Duration {
+	if p.Speed <= 0 || recorded <= 0 {
+		return 0
+	}
+	delay := time.Duration(float64(recorded) / p.Speed)
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		return p.MaxDelay
+	}
+	return delay
+}
+
+// ReplayStreamingResponse replays a streaming response to a client, paced as asked
+func (h *RESTHandler) ReplayStreamingResponse(writer http.ResponseWriter, chunks []*SSEChunk, pacing StreamPacing) error {
 	// Set SSE headers
 	writer.Header().Set("Content-Type", "text/event-stream")
 	writer.Header().Set("Cache-Control", "no-cache")
 	writer.Header().Set("Connection", "keep-alive")
 	writer.Header().Set("X-Accel-Buffering", "no")
 
+	return writeChunks(writer, http.StatusOK, chunks, pacing)
+}
+
+// ReplayChunkedResponse replays a stream other than SSE, such as NDJSON, with its recorded
+// status and headers, paced as asked
+func (h *RESTHandler) ReplayChunkedResponse(writer http.ResponseWriter, status int, headers map[string]string, chunks []*SSEChunk, pacing StreamPacing) error {
+	for key, value := range headers {
+		writer.Header().Set(key, value)
+	}
+	for _, name := range hopHeaders {
+		writer.Header().Del(name)
+	}
+	// Sent chunked, as the target sent it
+	writer.Header().Del("Content-Length")
+
+	return writeChunks(writer, status, chunks, pacing)
+}
+
+// writeChunks sends the chunks of a stream, flushing each one and following the recorded gaps
+// between them
+func writeChunks(writer http.ResponseWriter, status int, chunks []*SSEChunk, pacing StreamPacing) error {
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
 		return fmt.Errorf("streaming not supported")
 	}
 
-	writer.WriteHeader(http.StatusOK)
+	writer.WriteHeader(status)
 	flusher.Flush()
 
 	sseWriter := NewSSEStreamWriter(writer, flusher)
 
 	for i, chunk := range chunks {
-		// Respect original timing if requested (but skip the first chunk's delta)
-		if respectTiming && i > 0 && chunk.TimeDelta > 0 {
-			time.Sleep(time.Duration(chunk.TimeDelta) * time.Millisecond)
+		// Follow the recorded gaps between chunks, but not the wait for the first one
+		if i > 0 {
+			if delay := pacing.delay(time.Duration(chunk.TimeDelta) * time.Millisecond); delay > 0 {
+				time.Sleep(delay)
+			}
 		}
 
 		if err := sseWriter.WriteChunk(chunk); err != nil {
@@ -305,51 +513,54 @@ func (h *RESTHandler) ReplayStreamingResponse(writer http.ResponseWriter, chunks
 
 // CopyStreamingResponse copies a streaming response while capturing it
 func (h *RESTHandler) CopyStreamingResponse(resp *http.Response, writer http.ResponseWriter) ([]*SSEChunk, error) {
+	var chunks []*SSEChunk
+	err := h.copyStreamingResponse(resp, writer, func(chunk *SSEChunk) {
+		chunks = append(chunks, chunk)
+	})
+	return chunks, err
+}
+
+// copyStreamingResponse copies a streaming response, handing each chunk to collect as it is forwarded
+func (h *RESTHandler) copyStreamingResponse(resp *http.Response, writer http.ResponseWriter, collect func(*SSEChunk)) error {
 	// Set SSE headers
-	for key, values := range resp.Header {
-		for _, value := range values {
-			writer.Header().Add(key, value)
-		}
-	}
+	copyEndToEndHeaders(writer.Header(), resp.Header)
 
 	writer.WriteHeader(resp.StatusCode)
 
 	flusher, ok := writer.(http.Flusher)
 	if !ok {
-		return nil, fmt.Errorf("streaming not supported")
+		return fmt.Errorf("streaming not supported")
 	}
 
 	flusher.Flush()
 
 	// Read and forward the stream directly
-	reader := NewSSEStreamReader(resp.Body)
-	var chunks []*SSEChunk
-
+	reader := newStreamReader(resp)
 	for {
 		chunk, err := reader.ReadChunk()
 		if err == io.EOF {
 			if chunk != nil {
-				chunks = append(chunks, chunk)
+				collect(chunk)
 				// Write the last chunk
 				if _, writeErr := writer.Write(chunk.RawData); writeErr != nil {
-					return chunks, fmt.Errorf("failed to write final chunk: %w", writeErr)
+					return fmt.Errorf("failed to write final chunk: %w", writeErr)
 				}
 				flusher.Flush()
 			}
 			break
 		}
 		if err != nil {
-			return chunks, fmt.Errorf("failed to read chunk: %w", err)
+			return fmt.Errorf("failed to read chunk: %w", err)
 		}
 
-		chunks = append(chunks, chunk)
+		collect(chunk)
 
 		// Forward the chunk to the client
 		if _, writeErr := writer.Write(chunk.RawData); writeErr != nil {
-			return chunks, fmt.Errorf("failed to write chunk: %w", writeErr)
+			return fmt.Errorf("failed to write chunk: %w", writeErr)
 		}
 		flusher.Flush()
 	}
 
-	return chunks, nil
+	return nil
 }
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"mimic/config"
+	"mimic/metrics"
+)
+
+// Retry defaults, used when the proxy's retry settings are left at zero
+const (
+	defaultRetryBackoff    = 100 * time.Millisecond
+	defaultRetryMaxBackoff = 2 * time.Second
+	// Bytes of a retried response read before its connection is given up rather than reused
+	retryDrainLimit = 64 * 1024
+)
+
+// defaultRetryMethods are retried unless the config names others: those a server handles the same
+// however many times they arrive
+var defaultRetryMethods = []string{"GET", "HEAD", "OPTIONS", "PUT", "DELETE"}
+
+// retryPolicy decides whether a failed attempt to reach the target is made again, and when
+type retryPolicy struct {
+	attempts   int
+	backoff    time.Duration
+	maxBackoff time.Duration
+	statuses   []string // Status patterns such as 5xx or 503, in lower case
+	methods    map[string]bool
+}
+
+// newRetryPolicy reads a proxy's retry settings, or returns nil when they never retry
+func newRetryPolicy(cfg config.RetryConfig) *retryPolicy {
+	if cfg.MaxAttempts <= 1 {
+		return nil
+	}
+	policy := &retryPolicy{
+		attempts:   cfg.MaxAttempts,
+		backoff:    time.Duration(cfg.BackoffMS) * time.Millisecond,
+		maxBackoff: time.Duration(cfg.MaxBackoffMS) * time.Millisecond,
+		methods:    make(map[string]bool),
+	}
+	if policy.backoff == 0 {
+		policy.backoff = defaultRetryBackoff
+	}
+	if policy.maxBackoff == 0 {
+		policy.maxBackoff = defaultRetryMaxBackoff
+	}
+	for _, pattern := range cfg.Statuses {
+		policy.statuses = append(policy.statuses, strings.ToLower(pattern))
+	}
+	methods := cfg.Methods
+	if len(methods) == 0 {
+		methods = defaultRetryMethods
+	}
+	for _, method := range methods {
+		policy.methods[strings.ToUpper(strings.TrimSpace(method))] = true
+	}
+	return policy
+}
+
+// retries reports whether an attempt's outcome is tried again: when attempts are left, the request
+// can be sent again, and the attempt failed to connect or got a listed status
+func (p *retryPolicy) retries(req *http.Request, attempt int, resp *http.Response, err error) bool {
+	if p == nil || attempt >= p.attempts || !p.methods[req.Method] || req.Context().Err() != nil {
+		return false
+	}
+	// A body already sent can only go again if it can be read again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return !IsBodyTooLarge(err)
+	}
+	for _, pattern := range p.statuses {
+		if statusCovered(pattern, resp.StatusCode) {
+			return true
+		}
+	}
+	return false
+}
+
+// delay is how long to wait before an attempt after the given one, doubling each time up to the cap
+func (p *retryPolicy) delay(attempt int) time.Duration {
+	delay := p.backoff
+	for i := 1; i < attempt && delay < p.maxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > p.maxBackoff {
+		return p.maxBackoff
+	}
+	return delay
+}
+
+// forward sends a request to the target, trying it again as the proxy's retry policy allows. It
+// returns the last attempt's response or error, and why each earlier attempt was retried.
+func (p *ProxyEngine) forward(req *http.Request) (*http.Response, []string, error) {
+	var retried []string
+	for attempt := 1; ; attempt++ {
+		upstreamStart := time.Now()
+		resp, err := p.client.Do(req)
+		metrics.ObserveUpstreamLatency(p.proxyConfig.Name, "http", upstreamStart)
+		if req.Context().Err() != nil || IsBodyTooLarge(err) {
+			p.breaker.abandon() // The client gave up or sent too much, which says nothing about the target
+		} else {
+			p.breaker.record(err == nil && !unavailableStatus(resp.StatusCode))
+		}
+
+		if !p.retry.retries(req, attempt, resp, err) {
+			return resp, retried, err
+		}
+		if allowed, _ := p.breaker.allow(); !allowed {
+			return resp, retried, err
+		}
+
+		reason := ""
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, io.LimitReader(resp.Body, retryDrainLimit))
+			resp.Body.Close()
+		}
+		retried = append(retried, reason)
+		metrics.RecordUpstreamRetry(p.proxyConfig.Name)
+
+		select {
+		case <-time.After(p.retry.delay(attempt)):
+		case <-req.Context().Done():
+			return nil, retried, req.Context().Err()
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, retried, fmt.Errorf("failed to read request body again: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+// unavailableStatus reports whether a response says the target cannot serve requests right now,
+// which counts against it in the circuit breaker
+func unavailableStatus(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+}
+
+// retryAfterSeconds formats a wait for the Retry-After header, rounding up to whole seconds
+func retryAfterSeconds(wait time.Duration) string {
+	seconds := int((wait + time.Second - 1) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strconv.Itoa(seconds)
+}
@@ -0,0 +1,83 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestProxyEngineRetriesUpstreamFailures(t *testing.T) {
+	var calls atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("saved " + string(body)))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "orders", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "retried",
+		Retry: config.RetryConfig{MaxAttempts: 3, BackoffMS: 1, Statuses: []string{"503"}}}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, httptest.NewRequest("PUT", "/orders/7", strings.NewReader("order 7")))
+	if recorder.Code != http.StatusOK || recorder.Body.String() != "saved order 7" {
+		t.Fatalf("Expected the third attempt's answer with the body sent again, got %d %q", recorder.Code, recorder.Body.String())
+	}
+
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected one recorded interaction, got %d (%v)", len(interactions), err)
+	}
+	metadata := interactions[0].MetadataMap()
+	if metadata[storage.MetadataAttempts] != float64(3) {
+		t.Errorf("Expected 3 attempts in the metadata, got %v", metadata[storage.MetadataAttempts])
+	}
+	expected := []interface{}{"503 Service Unavailable", "503 Service Unavailable"}
+	if !reflect.DeepEqual(metadata[storage.MetadataRetries], expected) {
+		t.Errorf("Expected the retried statuses in the metadata, got %v", metadata[storage.MetadataRetries])
+	}
+
+	// Methods that are not retried get the first answer
+	calls.Store(0)
+	recorder = httptest.NewRecorder()
+	engine.HandleRequest(recorder, httptest.NewRequest("POST", "/orders", strings.NewReader("order 8")))
+	if recorder.Code != http.StatusServiceUnavailable || calls.Load() != 1 {
+		t.Errorf("Expected POST to be sent once, got %d after %d calls", recorder.Code, calls.Load())
+	}
+}
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := newRetryPolicy(config.RetryConfig{MaxAttempts: 5, BackoffMS: 100, MaxBackoffMS: 300})
+	for attempt, expected := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 4: 300 * time.Millisecond} {
+		if delay := policy.delay(attempt); delay != expected {
+			t.Errorf("Attempt %d: expected %v, got %v", attempt, expected, delay)
+		}
+	}
+	if newRetryPolicy(config.RetryConfig{MaxAttempts: 1}) != nil {
+		t.Error("Expected a single attempt to leave retries off")
+	}
+}
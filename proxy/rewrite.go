@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"mimic/config"
+)
+
+type routePrefixKey struct{}
+
+// WithRoutePrefix notes the path prefix a request reached the proxy under, such as /proxy/orders,
+// which the server strips before the proxy sees the request
+func WithRoutePrefix(ctx context.Context, prefix string) context.Context {
+	return context.WithValue(ctx, routePrefixKey{}, prefix)
+}
+
+func routePrefix(ctx context.Context) string {
+	prefix, _ := ctx.Value(routePrefixKey{}).(string)
+	return prefix
+}
+
+// rewritePath maps a client's request path to the target's: the strip prefix is removed, then the
+// add prefix put in front
+func rewritePath(path string, rewrite config.RewriteConfig) string {
+	if prefix := strings.TrimSuffix(rewrite.StripPathPrefix, "/"); prefix != "" && hasPathPrefix(path, prefix) {
+		path = strings.TrimPrefix(path, prefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	if prefix := strings.TrimSuffix(rewrite.AddPathPrefix, "/"); prefix != "" {
+		path = prefix + path
+	}
+	return path
+}
+
+// unrewritePath maps a path on the target back to the one its clients use; paths outside the add
+// prefix are left alone
+func unrewritePath(path string, rewrite config.RewriteConfig) string {
+	if prefix := strings.TrimSuffix(rewrite.AddPathPrefix, "/"); prefix != "" {
+		if !hasPathPrefix(path, prefix) {
+			return path
+		}
+		path = strings.TrimPrefix(path, prefix)
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+	}
+	if prefix := strings.TrimSuffix(rewrite.StripPathPrefix, "/"); prefix != "" {
+		path = prefix + path
+	}
+	return path
+}
+
+// hasPathPrefix reports whether a path starts with a prefix at a segment boundary, so /api covers
+// /api and /api/users but not /apis
+func hasPathPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// applyRewriteHeaders removes and sets the request headers the proxy's rewrite rules name
+func applyRewriteHeaders(out *http.Request, rewrite config.RewriteConfig) {
+	for _, name := range rewrite.RemoveHeaders {
+		out.Header.Del(name)
+	}
+	for name, value := range rewrite.SetHeaders {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			out.Host = value
+			continue
+		}
+		out.Header.Set(name, value)
+	}
+}
+
+// rewriteLocations points the Location and Content-Location headers of a target's response at the
+// address the client used, when they name the target or a path on it
+func rewriteLocations(header http.Header, in *http.Request, proxyConfig *config.ProxyConfig) {
+	for _, name := range []string{"Location", "Content-Location"} {
+		if value := header.Get(name); value != "" {
+			header.Set(name, rewriteLocation(value, in, proxyConfig))
+		}
+	}
+}
+
+func rewriteLocation(value string, in *http.Request, proxyConfig *config.ProxyConfig) string {
+	location, err := url.Parse(value)
+	if err != nil {
+		return value
+	}
+	switch {
+	case location.IsAbs():
+		if !namesTarget(location, proxyConfig) {
+			return value // Another site
+		}
+		location.Scheme = "http"
+		if in.TLS != nil {
+			location.Scheme = "https"
+		}
+		location.Host = in.Host
+	case location.Host != "" || !strings.HasPrefix(location.Path, "/"):
+		return value // Scheme-relative, or relative to the request's own path
+	}
+	location.Path = routePrefix(in.Context()) + unrewritePath(location.Path, proxyConfig.Rewrite)
+	location.RawPath = ""
+	return location.String()
+}
+
+// namesTarget reports whether an absolute URL points at the proxy's target, by its address or the
+// Host header it is sent
+func namesTarget(location *url.URL, proxyConfig *config.ProxyConfig) bool {
+	port := location.Port()
+	if port == "" {
+		port = "80"
+		if location.Scheme == "https" {
+			port = "443"
+		}
+	}
+	if strings.EqualFold(location.Hostname(), proxyConfig.TargetHost) && port == strconv.Itoa(proxyConfig.TargetPort) {
+		return true
+	}
+	host := proxyConfig.HostHeader
+	for name, value := range proxyConfig.Rewrite.SetHeaders {
+		if http.CanonicalHeaderKey(name) == "Host" {
+			host = value
+		}
+	}
+	return host != "" && strings.EqualFold(location.Host, host)
+}
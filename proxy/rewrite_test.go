@@ -0,0 +1,131 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestRewritePath(t *testing.T) {
+	rewrite := config.RewriteConfig{StripPathPrefix: "/api", AddPathPrefix: "/v2/"}
+	tests := []struct {
+		path, rewritten string
+	}{
+		{"/api/orders", "/v2/orders"},
+		{"/api", "/v2/"},
+		{"/apis/orders", "/v2/apis/orders"},
+		{"/health", "/v2/health"},
+	}
+	for _, test := range tests {
+		if got := rewritePath(test.path, rewrite); got != test.rewritten {
+			t.Errorf("rewritePath(%q) = %q, expected %q", test.path, got, test.rewritten)
+		}
+	}
+
+	if got := unrewritePath("/v2/orders/7", rewrite); got != "/api/orders/7" {
+		t.Errorf("Expected /v2/orders/7 mapped back to /api/orders/7, got %q", got)
+	}
+	if got := unrewritePath("/static/logo.png", rewrite); got != "/static/logo.png" {
+		t.Errorf("Expected a path outside the add prefix left alone, got %q", got)
+	}
+}
+
+func TestRewriteLocation(t *testing.T) {
+	proxyConfig := &config.ProxyConfig{
+		TargetHost: "orders.internal",
+		TargetPort: 8443,
+		Rewrite:    config.RewriteConfig{AddPathPrefix: "/v2", SetHeaders: map[string]string{"host": "orders.example.com"}},
+	}
+	in := httptest.NewRequest("GET", "/orders", nil)
+	in.Host = "localhost:8080"
+	in = in.WithContext(WithRoutePrefix(in.Context(), "/proxy/orders"))
+
+	tests := []struct {
+		location, rewritten string
+	}{
+		{"https://orders.internal:8443/v2/orders/7?expand=items", "http://localhost:8080/proxy/orders/orders/7?expand=items"},
+		{"https://orders.example.com/v2/orders/7", "http://localhost:8080/proxy/orders/orders/7"},
+		{"/v2/orders/7", "/proxy/orders/orders/7"},
+		{"https://orders.internal/v2/orders/7", "https://orders.internal/v2/orders/7"},
+		{"https://login.example.com/authorize", "https://login.example.com/authorize"},
+		{"items", "items"},
+	}
+	for _, test := range tests {
+		if got := rewriteLocation(test.location, in, proxyConfig); got != test.rewritten {
+			t.Errorf("rewriteLocation(%q) = %q, expected %q", test.location, got, test.rewritten)
+		}
+	}
+}
+
+func TestProxyEngineRewritesRequests(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/orders" {
+			t.Errorf("Expected the upstream to get /v2/orders, got %s", r.URL.Path)
+		}
+		if r.Host != "orders.example.com" {
+			t.Errorf("Expected the upstream to get Host orders.example.com, got %s", r.Host)
+		}
+		if r.Header.Get("X-Api-Version") != "2" || r.Header.Get("Cookie") != "" {
+			t.Errorf("Expected X-Api-Version set and Cookie removed, got %v", r.Header)
+		}
+		w.Header().Set("Location", "http://orders.example.com/v2/orders/7")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewProxyEngine(config.ProxyConfig{
+		Name: "orders", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "rewritten",
+		Rewrite: config.RewriteConfig{
+			StripPathPrefix: "/api",
+			AddPathPrefix:   "/v2",
+			SetHeaders:      map[string]string{"Host": "orders.example.com", "X-Api-Version": "2"},
+			RemoveHeaders:   []string{"Cookie"},
+			Location:        true,
+		},
+	}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/orders", strings.NewReader(`{}`))
+	req.Host = "localhost:8080"
+	req.Header.Set("Cookie", "session=abc123")
+	req = req.WithContext(WithRoutePrefix(req.Context(), "/proxy/orders"))
+	recorder := httptest.NewRecorder()
+	engine.HandleRequest(recorder, req)
+
+	const location = "http://localhost:8080/proxy/orders/api/orders/7"
+	if got := recorder.Header().Get("Location"); got != location {
+		t.Errorf("Expected the client sent Location %s, got %s", location, got)
+	}
+
+	// The recording keeps the client's side of the exchange, so mocks serve the same clients
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected one recorded interaction, got %d (%v)", len(interactions), err)
+	}
+	recorded := interactions[0]
+	if recorded.Endpoint != "/api/orders" {
+		t.Errorf("Expected the client's path recorded, got %s", recorded.Endpoint)
+	}
+	if !strings.Contains(recorded.RequestHeaders, "session=abc123") {
+		t.Errorf("Expected the client's headers recorded, got %s", recorded.RequestHeaders)
+	}
+	if !strings.Contains(recorded.ResponseHeaders, location) {
+		t.Errorf("Expected the rewritten Location recorded, got %s", recorded.ResponseHeaders)
+	}
+}
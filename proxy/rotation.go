@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+// Layout of the time suffix rotated sessions are named with, in UTC
+const rotatedSessionLayout = "20060102-150405"
+
+// SessionRotation starts a new recording session once the current one has been recorded to for
+// long enough or has grown large enough, under recording.rotate, so a long-running recording
+// proxy does not grow one session without bound
+type SessionRotation struct {
+	mutex       sync.Mutex
+	baseName    string
+	maxAge      time.Duration
+	maxRequests int
+	maxBytes    int64
+	now         func() time.Time
+
+	session  *storage.Session
+	started  time.Time
+	requests int   // Interactions saved to the current session
+	bytes    int64 // Body bytes saved to the current session
+}
+
+// NewSessionRotation builds a rotation of sessions named after baseName from a rotate config, or
+// returns nil when rotation is off
+func NewSessionRotation(cfg config.RotateConfig, baseName string) *SessionRotation {
+	if !cfg.Enabled() {
+		return nil
+	}
+	return &SessionRotation{
+		baseName:    baseName,
+		maxAge:      time.Duration(cfg.MaxHours) * time.Hour,
+		maxRequests: cfg.MaxRequests,
+		maxBytes:    int64(cfg.MaxMB) * 1024 * 1024,
+		now:         time.Now,
+	}
+}
+
+// NextName names a session of the rotation starting now: the base name with the time as a suffix,
+// such as checkout-20260315-140502
+func (s *SessionRotation) NextName() string {
+	return s.baseName + "-" + s.now().UTC().Format(rotatedSessionLayout)
+}
+
+// start makes a session the rotation's current one, as of now
+func (s *SessionRotation) start(session *storage.Session) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.reset(session)
+}
+
+func (s *SessionRotation) reset(session *storage.Session) {
+	s.session = session
+	s.started = s.now()
+	s.requests = 0
+	s.bytes = 0
+}
+
+// current returns the session to record to, starting a new one first when the current one is
+// due. Requests already under way finish in the session they started in.
+func (s *SessionRotation) current(db *storage.Database) *storage.Session {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.due() {
+		return s.session
+	}
+
+	next, err := s.createSession(db)
+	if err != nil {
+		// Recording carries on in the old session, and rotating is tried again on the next request
+		log.Printf("Error rotating recording session: %v", err)
+		return s.session
+	}
+	log.Printf("Rotated recording session %s to %s", s.session.SessionName, next.SessionName)
+	s.reset(next)
+	return next
+}
+
+// createSession creates the next session of the rotation. Sessions started within the same
+// second, as busy proxies with a low max_requests do, are told apart by a counter.
+func (s *SessionRotation) createSession(db *storage.Database) (*storage.Session, error) {
+	stamped := s.NextName()
+	name := stamped
+	for i := 2; ; i++ {
+		if _, err := db.GetSession(name); err != nil {
+			break
+		}
+		name = fmt.Sprintf("%s-%d", stamped, i)
+	}
+	session, err := db.CreateSession(name, "Proxy recording session, rotated from "+s.baseName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create session %s: %w", name, err)
+	}
+	return session, nil
+}
+
+// due reports whether the current session is old or large enough to rotate
+func (s *SessionRotation) due() bool {
+	switch {
+	case s.maxAge > 0 && s.now().Sub(s.started) >= s.maxAge:
+		return true
+	case s.maxRequests > 0 && s.requests >= s.maxRequests:
+		return true
+	case s.maxBytes > 0 && s.bytes >= s.maxBytes:
+		return true
+	}
+	return false
+}
+
+// add counts an interaction saved to the current session, with the size of its bodies
+func (s *SessionRotation) add(bytes int) {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests++
+	s.bytes += int64(bytes)
+}
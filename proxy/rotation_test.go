@@ -0,0 +1,73 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestProxyEngineRotatesSessions(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	now := time.Date(2026, 3, 15, 14, 5, 2, 0, time.UTC)
+	rotation := NewSessionRotation(config.RotateConfig{MaxHours: 6, MaxRequests: 2}, "orders")
+	rotation.now = func() time.Time { return now }
+	proxyConfig := config.ProxyConfig{Name: "orders", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: rotation.NextName()}
+	engine, err := NewProxyEngine(proxyConfig, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+	engine.SetSessionRotation(rotation)
+
+	send := func() {
+		engine.HandleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/orders", nil))
+	}
+	counts := func() map[string]int {
+		sessions, err := db.ListSessions()
+		if err != nil {
+			t.Fatalf("Failed to list sessions: %v", err)
+		}
+		counts := make(map[string]int)
+		for _, session := range sessions {
+			interactions, _ := db.GetInteractionsBySession(session.ID)
+			counts[session.SessionName] = len(interactions)
+		}
+		return counts
+	}
+
+	// The third request starts a new session, in the same second as the first
+	send()
+	send()
+	send()
+	if got := counts(); got["orders-20260315-140502"] != 2 || got["orders-20260315-140502-2"] != 1 || len(got) != 2 {
+		t.Fatalf("Expected two requests in the first session and one in the second, got %v", got)
+	}
+
+	// A session that has run long enough rotates before reaching max_requests
+	now = now.Add(6 * time.Hour)
+	send()
+	if got := counts(); got["orders-20260315-200502"] != 1 || len(got) != 3 {
+		t.Fatalf("Expected a session started by age, got %v", got)
+	}
+
+	if NewSessionRotation(config.RotateConfig{}, "orders") != nil {
+		t.Error("Expected no rotation without limits")
+	}
+}
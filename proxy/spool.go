@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+
+	"mimic/storage"
+)
+
+// SpooledBody holds a request body in memory up to a threshold and in a temporary file beyond it,
+// so large uploads can be read more than once without being held in memory
+type SpooledBody struct {
+	memory []byte
+	file   *os.File
+	size   int64
+	reader io.Reader
+}
+
+// SpoolBody drains r, spilling to a temporary file in dir once more than memoryLimit bytes arrive
+func SpoolBody(r io.Reader, memoryLimit int64, dir string) (*SpooledBody, error) {
+	var buffer bytes.Buffer
+	n, err := io.Copy(&buffer, io.LimitReader(r, memoryLimit+1))
+	if err != nil {
+		return nil, err
+	}
+
+	body := &SpooledBody{size: n}
+	if n <= memoryLimit {
+		body.memory = buffer.Bytes()
+		body.reader = bytes.NewReader(body.memory)
+		return body, nil
+	}
+
+	file, err := os.CreateTemp(dir, "mimic-body-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+	body.file = file
+
+	if _, err := file.Write(buffer.Bytes()); err != nil {
+		body.Remove()
+		return nil, fmt.Errorf("failed to write spill file: %w", err)
+	}
+	rest, err := io.Copy(file, r)
+	if err != nil {
+		body.Remove()
+		return nil, err
+	}
+	body.size += rest
+	body.reader = io.NewSectionReader(file, 0, body.size)
+	return body, nil
+}
+
+// Read reads the body once from the start, like the original request body
+func (b *SpooledBody) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+// Close leaves the spooled data in place so it can be read again; see Remove
+func (b *SpooledBody) Close() error {
+	return nil
+}
+
+// Size is the total body length in bytes
+func (b *SpooledBody) Size() int64 {
+	return b.size
+}
+
+// Spilled reports whether the body was written to disk
+func (b *SpooledBody) Spilled() bool {
+	return b.file != nil
+}
+
+// NewReader returns an independent reader over the whole body
+func (b *SpooledBody) NewReader() io.ReadCloser {
+	if b.file == nil {
+		return io.NopCloser(bytes.NewReader(b.memory))
+	}
+	return io.NopCloser(io.NewSectionReader(b.file, 0, b.size))
+}
+
+// Prefix returns up to limit bytes from the start of the body and whether the body was longer
+func (b *SpooledBody) Prefix(limit int64) ([]byte, bool, error) {
+	if b.size <= limit {
+		limit = b.size
+	}
+	prefix := make([]byte, limit)
+	if _, err := io.ReadFull(b.NewReader(), prefix); err != nil {
+		return nil, false, fmt.Errorf("failed to read spooled body: %w", err)
+	}
+	return prefix, b.size > limit, nil
+}
+
+// Remove deletes the spill file, if any
+func (b *SpooledBody) Remove() error {
+	if b.file == nil {
+		return nil
+	}
+	b.file.Close()
+	return os.Remove(b.file.Name())
+}
+
+// StreamedBody passes a request body on to the upstream as the client sends it, keeping a bounded
+// copy and a SHA-256 of the whole body for the recording, so uploads of any size are proxied
+// without being held in memory or written to a spill file first
+type StreamedBody struct {
+	body     io.ReadCloser
+	mutex    sync.Mutex // The transport may still be sending the body when the response arrives
+	capture  *bodyCapture
+	hash     hash.Hash
+	complete bool
+}
+
+// StreamBody wraps a request body so it is read only once, by the upstream request
+func StreamBody(body io.ReadCloser) *StreamedBody {
+	return &StreamedBody{body: body, hash: sha256.New()}
+}
+
+func (b *StreamedBody) Read(p []byte) (int, error) {
+	n, err := b.body.Read(p)
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.hash.Write(p[:n])
+	if b.capture != nil {
+		b.capture.Write(p[:n])
+	}
+	if err == io.EOF {
+		b.complete = true
+	}
+	return n, err
+}
+
+func (b *StreamedBody) Close() error {
+	return b.body.Close()
+}
+
+// captureInto keeps a bounded copy of what is read from here on
+func (b *StreamedBody) captureInto(capture *bodyCapture) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.capture = capture
+}
+
+// finish stores what was captured on the interaction. A body the upstream answered without
+// reading to the end is recorded as truncated, with no full size or hash since neither is known.
+func (b *StreamedBody) finish(interaction *storage.Interaction) error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	capture := b.capture
+	if capture == nil {
+		return nil
+	}
+	b.capture = nil // Reads from here on are not recorded
+
+	if err := capture.storeRequest(interaction); err != nil {
+		return err
+	}
+	if !b.complete {
+		return interaction.SetMetadataValue(storage.MetadataRequestTruncated, true)
+	}
+	if !capture.truncated {
+		return nil
+	}
+	return markRequestTruncated(interaction, capture.seen, b.hash.Sum(nil))
+}
+
+// discard drops the capture of a request that will not be recorded
+func (b *StreamedBody) discard() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.capture != nil {
+		b.capture.discard()
+		b.capture = nil
+	}
+}
+
+// IsBodyTooLarge reports whether err came from reading past an http.MaxBytesReader limit
+func IsBodyTooLarge(err error) bool {
+	var maxBytesError *http.MaxBytesError
+	return errors.As(err, &maxBytesError)
+}
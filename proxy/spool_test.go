@@ -0,0 +1,155 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestSpoolBodyInMemory(t *testing.T) {
+	body, err := SpoolBody(strings.NewReader("small body"), 64, t.TempDir())
+	if err != nil {
+		t.Fatalf("SpoolBody failed: %v", err)
+	}
+	defer body.Remove()
+
+	if body.Spilled() {
+		t.Error("Expected a body under the threshold to stay in memory")
+	}
+	if data, _ := io.ReadAll(body); string(data) != "small body" {
+		t.Errorf("Expected the body to read back unchanged, got %q", data)
+	}
+}
+
+func TestSpoolBodySpillsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	payload := bytes.Repeat([]byte("0123456789"), 100)
+
+	body, err := SpoolBody(bytes.NewReader(payload), 64, dir)
+	if err != nil {
+		t.Fatalf("SpoolBody failed: %v", err)
+	}
+
+	if !body.Spilled() || body.Size() != int64(len(payload)) {
+		t.Fatalf("Expected a spilled body of %d bytes, got spilled=%v size=%d", len(payload), body.Spilled(), body.Size())
+	}
+
+	// Every reader sees the whole body
+	for i := 0; i < 2; i++ {
+		if data, _ := io.ReadAll(body.NewReader()); !bytes.Equal(data, payload) {
+			t.Fatalf("Expected reader %d to return the full body, got %d bytes", i, len(data))
+		}
+	}
+
+	prefix, truncated, err := body.Prefix(10)
+	if err != nil || string(prefix) != "0123456789" || !truncated {
+		t.Errorf("Expected a truncated 10 byte prefix, got %q truncated=%v (%v)", prefix, truncated, err)
+	}
+
+	if err := body.Remove(); err != nil {
+		t.Fatalf("Remove failed: %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the spill file to be removed, found %d files", len(entries))
+	}
+}
+
+func TestSpoolBodyLimit(t *testing.T) {
+	reader := http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader("too many bytes")), 4)
+
+	_, err := SpoolBody(reader, 64, t.TempDir())
+	if !IsBodyTooLarge(err) {
+		t.Errorf("Expected a body-too-large error, got %v", err)
+	}
+}
+
+func TestCopyRequestFromSpool(t *testing.T) {
+	payload := bytes.Repeat([]byte("x"), 200)
+	body, err := SpoolBody(bytes.NewReader(payload), 16, t.TempDir())
+	if err != nil {
+		t.Fatalf("SpoolBody failed: %v", err)
+	}
+	defer body.Remove()
+
+	req := httptest.NewRequest("POST", "/upload", nil)
+	req.Body = body
+	handler := NewRESTHandler(nil)
+
+	interaction, err := handler.ExtractRequest(req)
+	if err != nil {
+		t.Fatalf("ExtractRequest failed: %v", err)
+	}
+	if len(interaction.RequestBody) != len(payload) {
+		t.Errorf("Expected the whole body to be recorded under the record limit, got %d bytes", len(interaction.RequestBody))
+	}
+	if _, ok := interaction.MetadataMap()[storage.MetadataRequestTruncated]; ok {
+		t.Error("Expected no truncation marker for a body under the record limit")
+	}
+
+	out, err := handler.CopyRequest(req, "http://upstream.local/upload")
+	if err != nil {
+		t.Fatalf("CopyRequest failed: %v", err)
+	}
+	if out.ContentLength != int64(len(payload)) {
+		t.Errorf("Expected Content-Length %d, got %d", len(payload), out.ContentLength)
+	}
+	if data, _ := io.ReadAll(out.Body); !bytes.Equal(data, payload) {
+		t.Errorf("Expected the upstream request to carry the full body, got %d bytes", len(data))
+	}
+}
+
+func TestProxyEngineStreamsRequestBody(t *testing.T) {
+	payload := bytes.Repeat([]byte("0123456789"), 1000)
+	var received []byte
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = io.ReadAll(r.Body)
+		w.Write([]byte("stored"))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "api", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "uploads"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+	engine.LimitBodies(16, 0)
+
+	req := httptest.NewRequest("PUT", "/upload", nil)
+	req.Body = StreamBody(io.NopCloser(bytes.NewReader(payload)))
+	req.ContentLength = int64(len(payload))
+	w := httptest.NewRecorder()
+	engine.HandleRequest(w, req)
+	if w.Body.String() != "stored" || !bytes.Equal(received, payload) {
+		t.Fatalf("Expected the upstream to receive all %d bytes, got %d and answered %d %q", len(payload), len(received), w.Code, w.Body.String())
+	}
+
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected one recorded interaction, got %v, %v", interactions, err)
+	}
+	recorded := interactions[0]
+	sum := sha256.Sum256(payload)
+	if string(recorded.RequestBody) != "0123456789012345" || !recorded.RequestTruncated() || recorded.RequestSHA256() != hex.EncodeToString(sum[:]) {
+		t.Errorf("Expected the first 16 bytes recorded with the upload's hash, got %q with %s", recorded.RequestBody, recorded.Metadata)
+	}
+	if size := recorded.MetadataMap()[storage.MetadataRequestFullSize]; size != float64(len(payload)) {
+		t.Errorf("Expected a full size of %d, got %v", len(payload), size)
+	}
+}
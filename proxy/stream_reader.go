@@ -0,0 +1,62 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"time"
+)
+
+// Most bytes of a stream other than SSE taken as one chunk
+const maxRawChunkBytes = 32 * 1024
+
+// streamReader splits a streamed response into the chunks that are forwarded and recorded
+type streamReader interface {
+	ReadChunk() (*SSEChunk, error)
+}
+
+// newStreamReader reads SSE event by event, and any other stream, such as NDJSON or chunked
+// JSON, in the pieces the target flushed
+func newStreamReader(resp *http.Response) streamReader {
+	if IsSSEResponse(resp.Header.Get("Content-Type")) {
+		return NewSSEStreamReader(resp.Body)
+	}
+	return &rawChunkReader{reader: resp.Body, buffer: make([]byte, maxRawChunkBytes), lastTime: time.Now()}
+}
+
+// rawChunkReader takes whatever each read returns as a chunk, which is what the target sent in
+// one go unless it outgrows the buffer
+type rawChunkReader struct {
+	reader   io.Reader
+	buffer   []byte
+	lastTime time.Time
+	err      error // A read error held back until its chunk is handed out
+}
+
+// ReadChunk returns the next chunk, with io.EOF alongside the last one like SSEStreamReader
+func (r *rawChunkReader) ReadChunk() (*SSEChunk, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	for {
+		n, err := r.reader.Read(r.buffer)
+		if n == 0 {
+			if err == nil {
+				continue
+			}
+			return nil, err
+		}
+
+		now := time.Now()
+		chunk := &SSEChunk{
+			RawData:   append([]byte(nil), r.buffer[:n]...),
+			Timestamp: now,
+			TimeDelta: now.Sub(r.lastTime).Milliseconds(),
+		}
+		r.lastTime = now
+		if err != nil && err != io.EOF {
+			r.err = err
+			return chunk, nil
+		}
+		return chunk, err
+	}
+}
@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestProxyEngineStreamsNDJSON(t *testing.T) {
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/chunked" {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+		}
+		w.WriteHeader(http.StatusAccepted)
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, "{\"line\":%d}\n", i)
+			w.(http.Flusher).Flush()
+			if i == 1 {
+				<-release // The client must see the first line before the rest is sent
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for _, test := range []struct {
+		name   string
+		path   string
+		config config.ProxyConfig
+	}{
+		{"content type", "/events", config.ProxyConfig{StreamingContentTypes: []string{"application/x-ndjson"}}},
+		{"chunked", "/chunked", config.ProxyConfig{StreamChunked: true}},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			proxyConfig := test.config
+			proxyConfig.Name, proxyConfig.Protocol, proxyConfig.TargetHost, proxyConfig.TargetPort = "api", "http", target.Hostname(), port
+			proxyConfig.SessionName, proxyConfig.EnableStreaming = "ndjson-"+test.path[1:], true
+			engine, err := NewProxyEngine(proxyConfig, db)
+			if err != nil {
+				t.Fatalf("Failed to create proxy engine: %v", err)
+			}
+			server := httptest.NewServer(http.HandlerFunc(engine.HandleRequest))
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + test.path)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			defer resp.Body.Close()
+			reader := bufio.NewReader(resp.Body)
+			if line, err := reader.ReadString('\n'); err != nil || line != "{\"line\":1}\n" {
+				t.Fatalf("Expected the first line while the stream is open, got %q, %v", line, err)
+			}
+			release <- struct{}{}
+			for i := 2; i <= 3; i++ {
+				if line, _ := reader.ReadString('\n'); line != fmt.Sprintf("{\"line\":%d}\n", i) {
+					t.Errorf("Expected line %d, got %q", i, line)
+				}
+			}
+			if resp.StatusCode != http.StatusAccepted {
+				t.Errorf("Expected the upstream's status, got %d", resp.StatusCode)
+			}
+
+			// The chunks are saved once the stream ends
+			var interactions []storage.Interaction
+			var chunks []storage.StreamChunk
+			for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+				interactions, _ = db.GetInteractionsBySession(engine.session.ID)
+				if len(interactions) == 1 {
+					if chunks, _ = db.GetStreamChunks(interactions[0].ID); len(chunks) > 0 {
+						break
+					}
+				}
+			}
+			if len(interactions) != 1 || !interactions[0].IsStreaming || interactions[0].EventStream() {
+				t.Fatalf("Expected one streaming interaction that is not SSE, got %+v", interactions)
+			}
+			if len(chunks) != 3 {
+				t.Fatalf("Expected a chunk per flushed line, got %d", len(chunks))
+			}
+			for i, chunk := range chunks {
+				if string(chunk.Data) != fmt.Sprintf("{\"line\":%d}\n", i+1) {
+					t.Errorf("Chunk %d: got %q", i, chunk.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyEngineBuffersUnconfiguredStreams(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.Write([]byte("{\"line\":1}\n"))
+		w.(http.Flusher).Flush()
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "api", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "plain", EnableStreaming: true}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+
+	engine.HandleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/events", nil))
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 1 || interactions[0].IsStreaming || string(interactions[0].ResponseBody) != "{\"line\":1}\n" {
+		t.Fatalf("Expected a plain interaction without stream_chunked or a streaming content type, got %+v, %v", interactions, err)
+	}
+}
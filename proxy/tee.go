@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+
+	"mimic/storage"
+)
+
+// DefaultRecordBufferBytes caps how much of a body is kept for the recording when the database
+// has no body files to spill it to
+const DefaultRecordBufferBytes = storage.MaxInlineBodyBytes
+
+// bodyCapture keeps the first limit bytes written to it and silently drops the rest. Given body
+// files, it moves the body to a file once it outgrows their threshold, so large bodies are
+// recorded without being held in memory.
+type bodyCapture struct {
+	memory    bytes.Buffer
+	files     *storage.BodyFiles
+	file      *storage.BodyFileWriter
+	size      int64 // Bytes kept
+	seen      int64 // Bytes written, kept or not
+	limit     int64
+	truncated bool
+}
+
+func newBodyCapture(limit int64, files *storage.BodyFiles) *bodyCapture {
+	return &bodyCapture{limit: limit, files: files}
+}
+
+// Write never fails so that a full buffer or a failing disk cannot interrupt the client's copy
+func (c *bodyCapture) Write(p []byte) (int, error) {
+	n := len(p)
+	c.seen += int64(n)
+	if room := c.limit - c.size; room < int64(len(p)) {
+		c.truncated = true
+		if room <= 0 {
+			return n, nil
+		}
+		p = p[:room]
+	}
+	if c.file == nil && c.files != nil && int64(c.memory.Len()+len(p)) > c.files.Threshold() {
+		c.spill()
+	}
+	if c.file != nil {
+		if _, err := c.file.Write(p); err != nil {
+			log.Printf("Error writing body file, recording stops at %d bytes: %v", c.size, err)
+			c.file.Abort()
+			c.file = nil
+			c.memory.Reset()
+			c.truncated = true
+			c.limit = c.size
+			return n, nil
+		}
+	} else {
+		c.memory.Write(p)
+	}
+	c.size += int64(len(p))
+	return n, nil
+}
+
+// spill moves what was captured so far into a new body file
+func (c *bodyCapture) spill() {
+	file, err := c.files.Create()
+	if err == nil {
+		_, err = file.Write(c.memory.Bytes())
+	}
+	if err != nil {
+		log.Printf("Error spilling body to disk, keeping up to %d bytes in memory: %v", DefaultRecordBufferBytes, err)
+		if file != nil {
+			file.Abort()
+		}
+		c.files = nil
+		c.limit = min(c.limit, DefaultRecordBufferBytes)
+		return
+	}
+	c.file = file
+	c.memory = bytes.Buffer{}
+}
+
+// Bytes returns the captured prefix of the stream, empty once it spilled to a file
+func (c *bodyCapture) Bytes() []byte {
+	return c.memory.Bytes()
+}
+
+// storeRequest puts the captured body on the interaction as its request body
+func (c *bodyCapture) storeRequest(interaction *storage.Interaction) error {
+	if c.file == nil {
+		interaction.RequestBody = c.Bytes()
+		return nil
+	}
+	name, err := c.file.Commit()
+	if err != nil {
+		return err
+	}
+	return interaction.SetRequestBodyFile(name, c.size)
+}
+
+// storeResponse puts the captured body on the interaction as its response body
+func (c *bodyCapture) storeResponse(interaction *storage.Interaction) error {
+	if c.file == nil {
+		interaction.ResponseBody = c.Bytes()
+		return nil
+	}
+	name, err := c.file.Commit()
+	if err != nil {
+		return err
+	}
+	return interaction.SetResponseBodyFile(name, c.size)
+}
+
+// discard drops a capture that will not be recorded
+func (c *bodyCapture) discard() {
+	if c.file != nil {
+		c.file.Abort()
+	}
+}
+
+// flushWriter flushes after every write so bodies of unknown length reach the client as they arrive
+type flushWriter struct {
+	writer  http.ResponseWriter
+	flusher http.Flusher
+}
+
+func (f *flushWriter) Write(p []byte) (int, error) {
+	n, err := f.writer.Write(p)
+	if f.flusher != nil {
+		f.flusher.Flush()
+	}
+	return n, err
+}
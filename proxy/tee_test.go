@@ -0,0 +1,189 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestTeeResponseStreamsFullBodyAndCapturesPrefix(t *testing.T) {
+	handler := NewRESTHandler([]string{})
+	payload := strings.Repeat("0123456789", 100)
+
+	resp := &http.Response{
+		StatusCode:    http.StatusOK,
+		Header:        http.Header{"Content-Type": {"application/octet-stream"}, "Connection": {"close"}},
+		Body:          io.NopCloser(strings.NewReader(payload)),
+		ContentLength: -1,
+	}
+	recorder := httptest.NewRecorder()
+
+	body, truncated, err := handler.TeeResponse(resp, recorder, 64)
+	if err != nil {
+		t.Fatalf("TeeResponse failed: %v", err)
+	}
+
+	if recorder.Body.String() != payload {
+		t.Errorf("Expected the client to receive all %d bytes, got %d", len(payload), recorder.Body.Len())
+	}
+	if !recorder.Flushed {
+		t.Error("Expected a body of unknown length to be flushed")
+	}
+	if recorder.Header().Get("Connection") != "" {
+		t.Error("Expected hop-by-hop headers to be stripped from the response")
+	}
+	if !truncated || string(body) != payload[:64] {
+		t.Errorf("Expected the first 64 bytes to be captured and marked truncated, got %d bytes (truncated=%v)", len(body), truncated)
+	}
+}
+
+func TestTeeResponseWithinLimit(t *testing.T) {
+	handler := NewRESTHandler([]string{})
+	resp := &http.Response{
+		StatusCode:    http.StatusCreated,
+		Header:        http.Header{},
+		Body:          io.NopCloser(strings.NewReader(`{"id":1}`)),
+		ContentLength: 8,
+	}
+	recorder := httptest.NewRecorder()
+
+	body, truncated, err := handler.TeeResponse(resp, recorder, DefaultRecordBufferBytes)
+	if err != nil {
+		t.Fatalf("TeeResponse failed: %v", err)
+	}
+	if recorder.Code != http.StatusCreated {
+		t.Errorf("Expected status 201, got %d", recorder.Code)
+	}
+	if truncated || string(body) != `{"id":1}` {
+		t.Errorf("Expected the full body to be captured, got %q (truncated=%v)", body, truncated)
+	}
+}
+
+func TestLargeBodiesGoToBodyFiles(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.Open(config.DatabaseConfig{Path: filepath.Join(dir, "mimic.db"), BodyFileThreshold: 16, MaxBodyBytes: 100})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+	handler := NewRESTHandler(nil)
+	handler.StoreLargeBodies(db)
+
+	payload := strings.Repeat("0123456789", 15)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: io.NopCloser(strings.NewReader(payload)), ContentLength: int64(len(payload))}
+	recorder := httptest.NewRecorder()
+	capture := handler.newResponseCapture()
+	if err := handler.teeResponse(resp, recorder, capture); err != nil {
+		t.Fatalf("teeResponse failed: %v", err)
+	}
+	interaction := &storage.Interaction{}
+	if err := capture.storeResponse(interaction); err != nil {
+		t.Fatalf("storeResponse failed: %v", err)
+	}
+
+	if recorder.Body.String() != payload {
+		t.Errorf("Expected the client to receive all %d bytes, got %d", len(payload), recorder.Body.Len())
+	}
+	if !capture.truncated || len(interaction.ResponseBody) != 0 || interaction.ResponseBodyFile() == "" {
+		t.Fatalf("Expected the first 100 bytes in a body file and marked truncated, got %d bytes in memory (truncated=%v)", len(interaction.ResponseBody), capture.truncated)
+	}
+	if err := db.LoadBodyFiles(interaction); err != nil || string(interaction.ResponseBody) != payload[:100] {
+		t.Errorf("Expected the body file to hold the first 100 bytes, got %q (%v)", interaction.ResponseBody, err)
+	}
+
+	// Bodies under the threshold stay in memory
+	small := handler.newResponseCapture()
+	small.Write([]byte("tiny"))
+	interaction = &storage.Interaction{}
+	if err := small.storeResponse(interaction); err != nil || string(interaction.ResponseBody) != "tiny" || interaction.ResponseBodyFile() != "" {
+		t.Errorf("Expected a small body kept inline, got %q in file %q (%v)", interaction.ResponseBody, interaction.ResponseBodyFile(), err)
+	}
+}
+
+func TestChunkSpool(t *testing.T) {
+	dir := t.TempDir()
+	db, err := storage.Open(config.DatabaseConfig{Path: filepath.Join(dir, "mimic.db"), BodyFileThreshold: 12})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	spool := newChunkSpool(db.BodyFiles())
+	defer spool.remove()
+	chunks := []string{"data: one\n\n", "data: two\n\n", "data: three\n\n"}
+	for i, data := range chunks {
+		spool.add(&SSEChunk{RawData: []byte(data), TimeDelta: int64(i)})
+	}
+
+	if spool.file == nil || spool.memory != int64(len(chunks[0])) {
+		t.Fatalf("Expected chunks past the threshold to be spilled, %d bytes held in memory", spool.memory)
+	}
+	if spool.len() != 3 || spool.size() != int64(len(strings.Join(chunks, ""))) {
+		t.Errorf("Expected 3 chunks of %d bytes, got %d of %d", len(strings.Join(chunks, "")), spool.len(), spool.size())
+	}
+	for i, data := range chunks {
+		chunk, err := spool.streamChunk(7, i)
+		if err != nil {
+			t.Fatalf("streamChunk failed: %v", err)
+		}
+		if string(chunk.Data) != data || chunk.InteractionID != 7 || chunk.ChunkIndex != i || chunk.TimeDelta != int64(i) {
+			t.Errorf("Expected chunk %d to read back as %q, got %+v", i, data, chunk)
+		}
+	}
+
+	name := spool.file.Name()
+	spool.remove()
+	if _, err := os.Stat(name); !os.IsNotExist(err) {
+		t.Errorf("Expected the spool file to be removed, got %v", err)
+	}
+}
+
+func TestLimitBodies(t *testing.T) {
+	payload := strings.Repeat("0123456789", 10)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte(payload))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "api", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "limited"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+	engine.LimitBodies(4, 10)
+
+	w := httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("POST", "/upload", strings.NewReader("a request body")))
+	if w.Body.String() != payload {
+		t.Errorf("Expected the client to receive all %d bytes, got %d", len(payload), w.Body.Len())
+	}
+
+	interactions, err := db.GetInteractionsBySession(engine.session.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected one recorded interaction, got %v, %v", interactions, err)
+	}
+	recorded := interactions[0]
+	metadata := recorded.MetadataMap()
+	if string(recorded.RequestBody) != "a re" || !recorded.RequestTruncated() || metadata[storage.MetadataRequestFullSize] != float64(14) {
+		t.Errorf("Expected the first 4 request bytes recorded of 14, got %q with %v", recorded.RequestBody, metadata)
+	}
+	if string(recorded.ResponseBody) != payload[:10] || !recorded.ResponseTruncated() || metadata[storage.MetadataResponseFullSize] != float64(100) {
+		t.Errorf("Expected the first 10 response bytes recorded of 100, got %q with %v", recorded.ResponseBody, metadata)
+	}
+}
@@ -0,0 +1,135 @@
+package proxy
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"mimic/config"
+
+	"golang.org/x/net/http2"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// Upstream transport defaults, used when the proxy's transport settings are left at zero
+const (
+	defaultDialTimeout           = 30 * time.Second
+	defaultTLSHandshakeTimeout   = 10 * time.Second
+	defaultResponseHeaderTimeout = 30 * time.Second
+	defaultKeepAlive             = 30 * time.Second
+	defaultMaxIdleConns          = 100
+	defaultMaxIdleConnsPerHost   = 10
+	defaultIdleConnTimeout       = 90 * time.Second
+	defaultGRPCKeepaliveTimeout  = 20 * time.Second
+	// gRPC's own default of 5 minutes disconnects clients that keep long streams alive with frequent pings
+	defaultMinClientPing = 10 * time.Second
+)
+
+// newUpstreamClient builds the HTTP client a proxy uses to reach its target
+func newUpstreamClient(transportConfig config.TransportConfig, outboundProxy string) (*http.Client, error) {
+	proxyFunc, err := OutboundProxyFunc(outboundProxy)
+	if err != nil {
+		return nil, err
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   secondsOr(transportConfig.DialTimeoutSeconds, defaultDialTimeout),
+		KeepAlive: secondsOr(transportConfig.KeepAliveSeconds, defaultKeepAlive),
+	}
+
+	transport := &http.Transport{
+		Proxy:                 proxyFunc,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   secondsOr(transportConfig.TLSHandshakeTimeoutSeconds, defaultTLSHandshakeTimeout),
+		ResponseHeaderTimeout: secondsOr(transportConfig.ResponseHeaderTimeoutSeconds, defaultResponseHeaderTimeout),
+		DisableKeepAlives:     transportConfig.DisableKeepAlives,
+		MaxIdleConns:          intOr(transportConfig.MaxIdleConns, defaultMaxIdleConns),
+		MaxIdleConnsPerHost:   intOr(transportConfig.MaxIdleConnsPerHost, defaultMaxIdleConnsPerHost),
+		IdleConnTimeout:       secondsOr(transportConfig.IdleConnTimeoutSeconds, defaultIdleConnTimeout),
+		MaxConnsPerHost:       transportConfig.MaxConnsPerHost,
+		DisableCompression:    true,
+	}
+	if transportConfig.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	client := &http.Client{
+		// Zero means no overall limit, so long-lived streams are only bounded by the upstream
+		Timeout:   time.Duration(transportConfig.RequestTimeoutSeconds) * time.Second,
+		Transport: transport,
+	}
+
+	switch transportConfig.HTTP2 {
+	case "auto":
+		// A custom dialer keeps net/http from offering HTTP/2 unless it is forced
+		transport.ForceAttemptHTTP2 = true
+	case "h2c":
+		// Cleartext HTTP/2 starts with the connection preface, so every request shares one
+		// multiplexed connection per target and the outbound proxy does not apply
+		client.Transport = &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, address string, _ *tls.Config) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, address)
+			},
+			DisableCompression: true,
+		}
+	}
+	return client, nil
+}
+
+// upstreamGRPCCredentials secures a gRPC target's connection with TLS, verifying its certificate
+// unless the proxy's transport says otherwise
+func upstreamGRPCCredentials(transportConfig config.TransportConfig) credentials.TransportCredentials {
+	if transportConfig.InsecureSkipVerify {
+		return credentials.NewTLS(&tls.Config{InsecureSkipVerify: true})
+	}
+	return credentials.NewTLS(nil) // Use system root CAs
+}
+
+// grpcKeepaliveDialOptions pings a gRPC target on idle connections when the proxy's transport enables it
+func grpcKeepaliveDialOptions(transportConfig config.TransportConfig) []grpc.DialOption {
+	if transportConfig.GRPCKeepaliveSeconds <= 0 {
+		return nil
+	}
+	return []grpc.DialOption{grpc.WithKeepaliveParams(keepalive.ClientParameters{
+		Time:    time.Duration(transportConfig.GRPCKeepaliveSeconds) * time.Second,
+		Timeout: secondsOr(transportConfig.GRPCKeepaliveTimeoutSeconds, defaultGRPCKeepaliveTimeout),
+	})}
+}
+
+// GRPCServerKeepaliveOptions applies the listener keepalive settings; zero durations keep gRPC's defaults
+func GRPCServerKeepaliveOptions(keepaliveConfig config.GRPCKeepaliveConfig) []grpc.ServerOption {
+	seconds := func(value int) time.Duration {
+		return time.Duration(value) * time.Second
+	}
+	return []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:                  seconds(keepaliveConfig.TimeSeconds),
+			Timeout:               seconds(keepaliveConfig.TimeoutSeconds),
+			MaxConnectionIdle:     seconds(keepaliveConfig.MaxConnectionIdleSeconds),
+			MaxConnectionAge:      seconds(keepaliveConfig.MaxConnectionAgeSeconds),
+			MaxConnectionAgeGrace: seconds(keepaliveConfig.MaxConnectionAgeGraceSeconds),
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             secondsOr(keepaliveConfig.MinClientPingSeconds, defaultMinClientPing),
+			PermitWithoutStream: keepaliveConfig.PermitWithoutStream,
+		}),
+	}
+}
+
+func secondsOr(seconds int, fallback time.Duration) time.Duration {
+	if seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	return fallback
+}
+
+func intOr(value, fallback int) int {
+	if value > 0 {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestNewUpstreamClientDefaults(t *testing.T) {
+	client, err := newUpstreamClient(config.TransportConfig{}, "")
+	if err != nil {
+		t.Fatalf("newUpstreamClient failed: %v", err)
+	}
+	transport := client.Transport.(*http.Transport)
+
+	if client.Timeout != 0 {
+		t.Errorf("Expected no overall timeout by default, got %v", client.Timeout)
+	}
+	if transport.ResponseHeaderTimeout != defaultResponseHeaderTimeout {
+		t.Errorf("Expected response header timeout %v, got %v", defaultResponseHeaderTimeout, transport.ResponseHeaderTimeout)
+	}
+	if transport.MaxIdleConns != defaultMaxIdleConns || transport.MaxIdleConnsPerHost != defaultMaxIdleConnsPerHost {
+		t.Errorf("Expected default pool sizes, got %d/%d", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if transport.Proxy == nil {
+		t.Error("Expected the environment proxy settings to be honored by default")
+	}
+	if !transport.DisableCompression {
+		t.Error("Expected compression to stay disabled so bodies are recorded as sent")
+	}
+}
+
+func TestNewUpstreamClientOverrides(t *testing.T) {
+	client, err := newUpstreamClient(config.TransportConfig{
+		TLSHandshakeTimeoutSeconds:   3,
+		ResponseHeaderTimeoutSeconds: 120,
+		RequestTimeoutSeconds:        600,
+		DisableKeepAlives:            true,
+		MaxIdleConns:                 5,
+		MaxIdleConnsPerHost:          2,
+		MaxConnsPerHost:              8,
+		IdleConnTimeoutSeconds:       15,
+	}, OutboundProxyNone)
+	if err != nil {
+		t.Fatalf("newUpstreamClient failed: %v", err)
+	}
+	transport := client.Transport.(*http.Transport)
+
+	if client.Timeout != 600*time.Second {
+		t.Errorf("Expected overall timeout 600s, got %v", client.Timeout)
+	}
+	if transport.TLSHandshakeTimeout != 3*time.Second || transport.ResponseHeaderTimeout != 120*time.Second {
+		t.Errorf("Expected TLS 3s and header 120s timeouts, got %v and %v", transport.TLSHandshakeTimeout, transport.ResponseHeaderTimeout)
+	}
+	if transport.Proxy != nil {
+		t.Error("Expected outbound_proxy 'none' to connect directly")
+	}
+	if !transport.DisableKeepAlives {
+		t.Error("Expected keep-alives to be disabled")
+	}
+	if transport.MaxIdleConns != 5 || transport.MaxIdleConnsPerHost != 2 || transport.IdleConnTimeout != 15*time.Second {
+		t.Errorf("Expected pool 5/2 with 15s idle timeout, got %d/%d with %v", transport.MaxIdleConns, transport.MaxIdleConnsPerHost, transport.IdleConnTimeout)
+	}
+	if transport.MaxConnsPerHost != 8 {
+		t.Errorf("Expected at most 8 connections per host, got %d", transport.MaxConnsPerHost)
+	}
+}
+
+func TestNewUpstreamClientInsecureSkipVerify(t *testing.T) {
+	target := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer target.Close()
+
+	for _, skipVerify := range []bool{false, true} {
+		client, err := newUpstreamClient(config.TransportConfig{InsecureSkipVerify: skipVerify}, OutboundProxyNone)
+		if err != nil {
+			t.Fatalf("newUpstreamClient failed: %v", err)
+		}
+		resp, err := client.Get(target.URL)
+		if err == nil {
+			resp.Body.Close()
+		}
+		if (err == nil) != skipVerify {
+			t.Errorf("insecure_skip_verify %v: expected the self-signed target accepted only when skipping verification, got %v", skipVerify, err)
+		}
+	}
+}
+
+func TestNewUpstreamClientHTTP2(t *testing.T) {
+	client, err := newUpstreamClient(config.TransportConfig{HTTP2: "auto"}, "")
+	if err != nil {
+		t.Fatalf("newUpstreamClient failed: %v", err)
+	}
+	if !client.Transport.(*http.Transport).ForceAttemptHTTP2 {
+		t.Error("Expected http2 'auto' to offer HTTP/2 to TLS targets")
+	}
+
+	// An h2c-only target answers through the proxy, trailers included
+	upstream := httptest.NewServer(h2c.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Write([]byte(r.Proto))
+		w.Header().Set("Grpc-Status", "0")
+	}), &http2.Server{}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "h2", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "h2c",
+		Transport: config.TransportConfig{HTTP2: "h2c"}}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+
+	w := httptest.NewRecorder()
+	engine.HandleRequest(w, httptest.NewRequest("GET", "/status", nil))
+	resp := w.Result()
+	if w.Body.String() != "HTTP/2.0" {
+		t.Errorf("Expected the upstream to be reached over HTTP/2, got %d %q", w.Code, w.Body.String())
+	}
+	if resp.Trailer.Get("Grpc-Status") != "0" {
+		t.Errorf("Expected the upstream's trailer to be forwarded, got %v", resp.Trailer)
+	}
+}
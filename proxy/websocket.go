@@ -0,0 +1,311 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"mimic/accesslog"
+	"mimic/metrics"
+	"mimic/redact"
+	"mimic/storage"
+	"mimic/webhook"
+
+	"github.com/gorilla/websocket"
+)
+
+// websocketHandshakeHeaders belong to one side's handshake; the dialer and upgrader set their own
+var websocketHandshakeHeaders = []string{
+	"Sec-Websocket-Key",
+	"Sec-Websocket-Version",
+	"Sec-Websocket-Extensions",
+	"Sec-Websocket-Protocol",
+	"Sec-Websocket-Accept",
+}
+
+// How long the other side has to answer a close before both connections are dropped
+const websocketCloseTimeout = 5 * time.Second
+
+// How long the upstream has to complete its handshake
+const websocketHandshakeTimeout = 45 * time.Second
+
+// handleWebSocket connects an upgrade request to the upstream and relays messages both ways,
+// recording the handshake as an interaction and every message as one of its frames
+func (p *ProxyEngine) handleWebSocket(w http.ResponseWriter, r *http.Request, interaction *storage.Interaction, targetPath string, startTime time.Time) {
+	scheme := "ws"
+	if p.proxyConfig.Protocol == "https" {
+		scheme = "wss"
+	}
+	targetURL := fmt.Sprintf("%s://%s:%d%s", scheme, p.proxyConfig.TargetHost, p.proxyConfig.TargetPort, targetPath)
+
+	// The upstream handshake carries the client's headers, the way plain requests do
+	proxyReq, err := p.restHandler.CopyRequest(r, targetURL)
+	if err != nil {
+		log.Printf("Error copying request: %v", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	applyForwarding(proxyReq, r, p.proxyConfig)
+	applyRewriteHeaders(proxyReq, p.proxyConfig.Rewrite)
+	header := proxyReq.Header
+	for _, name := range websocketHandshakeHeaders {
+		header.Del(name)
+	}
+	if proxyReq.Host != proxyReq.URL.Host {
+		header.Set("Host", proxyReq.Host)
+	}
+
+	upstreamStart := time.Now()
+	upstream, resp, err := p.websocketDialer(r).DialContext(r.Context(), targetURL, header)
+	metrics.ObserveUpstreamLatency(p.proxyConfig.Name, "websocket", upstreamStart)
+	if err != nil {
+		// An upstream that turns the upgrade down answers like any other request
+		if resp != nil {
+			log.Printf("Upstream refused WebSocket upgrade for %s: %d", interaction.Endpoint, resp.StatusCode)
+			defer resp.Body.Close()
+			copyEndToEndHeaders(w.Header(), resp.Header)
+			w.WriteHeader(resp.StatusCode)
+			io.Copy(w, resp.Body)
+			return
+		}
+		log.Printf("Error dialing WebSocket upstream: %v", err)
+		http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+
+	// The client gets the upstream's handshake answer and subprotocol
+	responseHeader := http.Header{}
+	copyEndToEndHeaders(responseHeader, resp.Header)
+	for _, name := range websocketHandshakeHeaders {
+		responseHeader.Del(name)
+	}
+	if subprotocol := upstream.Subprotocol(); subprotocol != "" {
+		responseHeader.Set("Sec-Websocket-Protocol", subprotocol)
+	}
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(*http.Request) bool { return true }, // The upstream decides which origins it accepts
+	}
+	client, err := upgrader.Upgrade(w, r, responseHeader)
+	if err != nil {
+		log.Printf("Error upgrading client to WebSocket: %v", err)
+		return
+	}
+	defer client.Close()
+
+	if interaction.ResponseHeaders, err = p.restHandler.ExtractResponseHeaders(resp); err != nil {
+		log.Printf("Error extracting response: %v", err)
+	}
+	interaction.ResponseStatus = http.StatusSwitchingProtocols
+	if err := interaction.SetMetadataValue(storage.MetadataWebSocket, true); err != nil {
+		log.Printf("Error marking WebSocket interaction: %v", err)
+	}
+	// Duration is provisional (time to upgrade) until the connection closes
+	if err := interaction.SetTiming(startTime, time.Since(startTime)); err != nil {
+		log.Printf("Error recording interaction timing: %v", err)
+	}
+
+	// Record the handshake first, so the frames have an interaction to belong to
+	recording := !p.passthrough && p.filter.RecordsStatus(interaction.ResponseStatus) && p.sampled(interaction.ResponseStatus)
+	if recording {
+		if err := p.database.RecordInteraction(interaction); err != nil {
+			log.Printf("Error recording WebSocket interaction: %v", err)
+			recording = false
+		}
+	}
+	if recording {
+		accesslog.Annotate(r.Context(), "", accesslog.MatchRecorded)
+		log.Printf("Recorded WebSocket interaction: %s %s (ID: %d)", interaction.Method, interaction.Endpoint, interaction.ID)
+	} else {
+		accesslog.Annotate(r.Context(), "", accesslog.MatchPassthrough)
+	}
+
+	frames := newFrameCapture(interaction.ID, p.restHandler.Redactor())
+	done := make(chan struct{}, 2)
+	go relayWebSocket(client, upstream, storage.FrameFromClient, frames, done)
+	go relayWebSocket(upstream, client, storage.FrameFromServer, frames, done)
+
+	// Once one side hangs up, the other gets a moment to answer the close
+	<-done
+	select {
+	case <-done:
+	case <-time.After(websocketCloseTimeout):
+		client.Close()
+		upstream.Close()
+		<-done
+	}
+
+	log.Printf("Captured %d WebSocket frames for %s %s", len(frames.frames), interaction.Method, interaction.Endpoint)
+	if !recording {
+		return
+	}
+
+	if err := p.database.UpdateInteractionMetadata(interaction.ID, map[string]interface{}{
+		storage.MetadataDurationMs: float64(time.Since(startTime)) / float64(time.Millisecond),
+	}); err != nil {
+		log.Printf("Error recording WebSocket duration: %v", err)
+	}
+	if err := p.database.RecordWebSocketFrames(frames.frames); err != nil {
+		log.Printf("Error recording WebSocket frames: %v", err)
+		if err := p.database.MarkInteractionAsPartial(interaction.ID, []int{}); err != nil {
+			log.Printf("Error marking interaction as partial: %v", err)
+		}
+	}
+	metrics.RecordInteraction(p.proxyConfig.Name, recordedBodyBytes(interaction)+frames.size)
+	p.rotation.add(recordedBodyBytes(interaction) + frames.size)
+	webhook.RecordingComplete(p.proxyConfig.Name, p.session.SessionName, interaction)
+
+	// Broadcast the finished conversation if web server is available
+	if p.webServer != nil {
+		var responseHeaders map[string]interface{}
+		json.Unmarshal([]byte(interaction.ResponseHeaders), &responseHeaders)
+		responseBody := fmt.Sprintf("[WebSocket conversation with %d frames]", len(frames.frames))
+		p.webServer.BroadcastResponse(p.proxyConfig.Name, interaction.Method, interaction.Endpoint, p.session.SessionName, r.RemoteAddr, interaction.RequestID, interaction.ResponseStatus, responseHeaders, responseBody)
+	}
+}
+
+// websocketDialer dials the upstream through the same proxy and dialer as plain requests,
+// offering the subprotocols the client asked for
+func (p *ProxyEngine) websocketDialer(r *http.Request) *websocket.Dialer {
+	dialer := &websocket.Dialer{
+		Proxy:            http.ProxyFromEnvironment,
+		HandshakeTimeout: websocketHandshakeTimeout,
+		Subprotocols:     websocket.Subprotocols(r),
+	}
+	if transport, ok := p.client.Transport.(*http.Transport); ok {
+		dialer.Proxy = transport.Proxy
+		dialer.NetDialContext = transport.DialContext
+		if transport.TLSClientConfig != nil {
+			// A transport that speaks HTTP/2 offers it over TLS, but handshakes are HTTP/1.1
+			dialer.TLSClientConfig = transport.TLSClientConfig.Clone()
+			dialer.TLSClientConfig.NextProtos = nil
+		}
+	}
+	return dialer
+}
+
+// relayWebSocket copies messages from one connection to the other until either closes, passing
+// a close on with its code so each side sees how the other hung up
+func relayWebSocket(src, dst *websocket.Conn, direction string, frames *frameCapture, done chan<- struct{}) {
+	defer func() { done <- struct{}{} }()
+	for {
+		messageType, data, err := src.ReadMessage()
+		if err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) {
+				message := websocket.FormatCloseMessage(closeErr.Code, closeErr.Text)
+				frames.add(direction, websocket.CloseMessage, message)
+				dst.WriteControl(websocket.CloseMessage, message, time.Now().Add(websocketCloseTimeout))
+			} else {
+				dst.Close()
+			}
+			return
+		}
+		frames.add(direction, messageType, data)
+		if err := dst.WriteMessage(messageType, data); err != nil {
+			return
+		}
+	}
+}
+
+// frameCapture collects the frames of a conversation from both directions, in the order they
+// arrived and with the time since the one before
+type frameCapture struct {
+	mutex         sync.Mutex
+	interactionID int
+	redactor      *redact.Redactor // Masks text frames as they are recorded
+	frames        []*storage.WebSocketFrame
+	last          time.Time
+	size          int
+}
+
+func newFrameCapture(interactionID int, redactor *redact.Redactor) *frameCapture {
+	return &frameCapture{interactionID: interactionID, redactor: redactor, last: time.Now()}
+}
+
+func (f *frameCapture) add(direction string, messageType int, data []byte) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	now := time.Now()
+	f.size += len(data)
+	if messageType == websocket.TextMessage {
+		// The message is forwarded after it is captured, so only the recorded copy is masked
+		data = f.redactor.Bytes(data)
+	}
+	f.frames = append(f.frames, &storage.WebSocketFrame{
+		InteractionID: f.interactionID,
+		FrameIndex:    len(f.frames),
+		Direction:     direction,
+		MessageType:   messageType,
+		Data:          data,
+		Timestamp:     now,
+		TimeDelta:     now.Sub(f.last).Milliseconds(),
+	})
+	f.last = now
+}
+
+// ReplayWebSocket upgrades a client and plays a recorded conversation back to it. Server frames
+// are sent after their recorded gaps, paced as asked, and each client frame waits for the client
+// to send a message, whatever it holds.
+func ReplayWebSocket(writer http.ResponseWriter, r *http.Request, recordedHeaders map[string]string, frames []storage.WebSocketFrame, pacing StreamPacing) error {
+	// The upgrader writes the handshake itself, keeping the recorded subprotocol
+	responseHeader := http.Header{}
+	for key, value := range recordedHeaders {
+		responseHeader.Set(key, value)
+	}
+	for _, name := range hopHeaders {
+		responseHeader.Del(name)
+	}
+	for _, name := range websocketHandshakeHeaders {
+		if name != "Sec-Websocket-Protocol" {
+			responseHeader.Del(name)
+		}
+	}
+	upgrader := websocket.Upgrader{CheckOrigin: func(*http.Request) bool { return true }}
+	conn, err := upgrader.Upgrade(writer, r, responseHeader)
+	if err != nil {
+		return fmt.Errorf("failed to upgrade to WebSocket: %w", err)
+	}
+	defer conn.Close()
+
+	for _, frame := range frames {
+		if frame.Direction == storage.FrameFromClient {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return nil // The client hung up
+			}
+			continue
+		}
+
+		if delay := pacing.delay(time.Duration(frame.TimeDelta) * time.Millisecond); delay > 0 {
+			time.Sleep(delay)
+		}
+		if frame.MessageType == websocket.CloseMessage {
+			if err := conn.WriteControl(websocket.CloseMessage, frame.Data, time.Now().Add(websocketCloseTimeout)); err != nil {
+				return fmt.Errorf("failed to write WebSocket close: %w", err)
+			}
+			// Give the client a moment to answer the close
+			conn.SetReadDeadline(time.Now().Add(websocketCloseTimeout))
+			for {
+				if _, _, err := conn.ReadMessage(); err != nil {
+					return nil
+				}
+			}
+		}
+		if err := conn.WriteMessage(frame.MessageType, frame.Data); err != nil {
+			return fmt.Errorf("failed to write WebSocket frame %d: %w", frame.FrameIndex, err)
+		}
+	}
+
+	// With the recording played out, the connection stays open until the client closes it
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			return nil
+		}
+	}
+}
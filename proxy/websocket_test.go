@@ -0,0 +1,113 @@
+package proxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/storage"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestProxyEngineRecordsWebSocket(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{Subprotocols: []string{"echo.v1"}}
+		conn, err := upgrader.Upgrade(w, r, http.Header{"X-Upstream": {"yes"}})
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			messageType, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			conn.WriteMessage(messageType, append([]byte("echo: "), data...))
+		}
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	engine, err := NewProxyEngine(config.ProxyConfig{Name: "chat", Protocol: "http", TargetHost: target.Hostname(), TargetPort: port, SessionName: "sockets"}, db)
+	if err != nil {
+		t.Fatalf("Failed to create proxy engine: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(engine.HandleRequest))
+	defer server.Close()
+
+	dialer := websocket.Dialer{Subprotocols: []string{"echo.v1"}}
+	conn, resp, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/socket?room=1", nil)
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	if conn.Subprotocol() != "echo.v1" || resp.Header.Get("X-Upstream") != "yes" {
+		t.Errorf("Expected the upstream's handshake, got subprotocol %q and X-Upstream %q", conn.Subprotocol(), resp.Header.Get("X-Upstream"))
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte("hello")); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "echo: hello" {
+		t.Fatalf("Expected the upstream's echo, got %q, %v", data, err)
+	}
+	if err := conn.WriteMessage(websocket.BinaryMessage, []byte{0, 1, 2}); err != nil {
+		t.Fatalf("Failed to send message: %v", err)
+	}
+	if _, data, err := conn.ReadMessage(); err != nil || string(data) != "echo: \x00\x01\x02" {
+		t.Fatalf("Expected the upstream's binary echo, got %q, %v", data, err)
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, "done"))
+	if _, _, err := conn.ReadMessage(); !websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+		t.Errorf("Expected the close to come back from the upstream, got %v", err)
+	}
+	conn.Close()
+
+	// The frames are saved once both sides have hung up
+	var frames []storage.WebSocketFrame
+	var interactions []storage.Interaction
+	for deadline := time.Now().Add(2 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		interactions, _ = db.GetInteractionsBySession(engine.session.ID)
+		if len(interactions) == 1 {
+			if frames, _ = db.GetWebSocketFrames(interactions[0].ID); len(frames) > 0 {
+				break
+			}
+		}
+	}
+	if len(interactions) != 1 || !interactions[0].WebSocket() || interactions[0].ResponseStatus != http.StatusSwitchingProtocols || interactions[0].Query() != "room=1" {
+		t.Fatalf("Expected one WebSocket interaction, got %+v", interactions)
+	}
+
+	expected := []struct {
+		direction   string
+		messageType int
+		data        string
+	}{
+		{storage.FrameFromClient, websocket.TextMessage, "hello"},
+		{storage.FrameFromServer, websocket.TextMessage, "echo: hello"},
+		{storage.FrameFromClient, websocket.BinaryMessage, "\x00\x01\x02"},
+		{storage.FrameFromServer, websocket.BinaryMessage, "echo: \x00\x01\x02"},
+		{storage.FrameFromClient, websocket.CloseMessage, string(websocket.FormatCloseMessage(websocket.CloseNormalClosure, "done"))},
+		{storage.FrameFromServer, websocket.CloseMessage, string(websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))},
+	}
+	if len(frames) != len(expected) {
+		t.Fatalf("Expected %d frames, got %+v", len(expected), frames)
+	}
+	for i, want := range expected {
+		if frames[i].Direction != want.direction || frames[i].MessageType != want.messageType || string(frames[i].Data) != want.data {
+			t.Errorf("Frame %d: expected %s %d %q, got %s %d %q", i, want.direction, want.messageType, want.data,
+				frames[i].Direction, frames[i].MessageType, frames[i].Data)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Package ratelimit caps how fast and how concurrently each proxy accepts requests,
+// so a runaway test loop cannot overwhelm the database writer or the upstreams.
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"mimic/clock"
+	"mimic/config"
+	"mimic/metrics"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Reasons a request can be turned away
+const (
+	ReasonRate        = "rate"
+	ReasonConcurrency = "concurrency"
+)
+
+// LimitError reports a request rejected by a proxy's limits
+type LimitError struct {
+	Proxy      string
+	Reason     string        // ReasonRate or ReasonConcurrency
+	RetryAfter time.Duration // Suggested wait before trying again
+}
+
+func (e *LimitError) Error() string {
+	if e.Reason == ReasonRate {
+		return fmt.Sprintf("rate limit exceeded for proxy '%s'", e.Proxy)
+	}
+	return fmt.Sprintf("too many concurrent requests for proxy '%s'", e.Proxy)
+}
+
+// GRPCStatus lets gRPC handlers return the error directly: RESOURCE_EXHAUSTED for the rate, UNAVAILABLE for concurrency
+func (e *LimitError) GRPCStatus() *status.Status {
+	if e.Reason == ReasonRate {
+		return status.New(codes.ResourceExhausted, e.Error())
+	}
+	return status.New(codes.Unavailable, e.Error())
+}
+
+// RetryAfterSeconds rounds RetryAfter up to whole seconds for a Retry-After header
+func (e *LimitError) RetryAfterSeconds() int {
+	seconds := int(math.Ceil(e.RetryAfter.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// Limiter enforces the concurrency cap and token bucket of one proxy
+type Limiter struct {
+	name          string
+	mutex         sync.Mutex
+	maxConcurrent int
+	inFlight      int
+	rate          float64 // Tokens added per second; 0 disables rate limiting
+	burst         float64
+	tokens        float64
+	last          time.Time
+	now           func() time.Time
+}
+
+// NewLimiter creates a limiter from a proxy's settings, or returns nil if it sets no limits
+func NewLimiter(name string, proxyConfig config.ProxyConfig) *Limiter {
+	if proxyConfig.MaxConcurrentRequests <= 0 && proxyConfig.RateLimitRPS <= 0 {
+		return nil
+	}
+
+	burst := float64(proxyConfig.RateLimitBurst)
+	if burst <= 0 {
+		burst = math.Ceil(proxyConfig.RateLimitRPS)
+	}
+
+	return &Limiter{
+		name:          name,
+		maxConcurrent: proxyConfig.MaxConcurrentRequests,
+		rate:          proxyConfig.RateLimitRPS,
+		burst:         burst,
+		tokens:        burst,
+		last:          clock.Now(),
+		now:           clock.Now,
+	}
+}
+
+// Acquire admits a request, returning a release func to call when it finishes, or a *LimitError
+func (l *Limiter) Acquire() (func(), error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.maxConcurrent > 0 && l.inFlight >= l.maxConcurrent {
+		return nil, &LimitError{Proxy: l.name, Reason: ReasonConcurrency, RetryAfter: time.Second}
+	}
+
+	if l.rate > 0 {
+		now := l.now()
+		// The clock can be set back; that refills nothing rather than draining the bucket
+		elapsed := math.Max(0, now.Sub(l.last).Seconds())
+		l.tokens = math.Min(l.burst, l.tokens+elapsed*l.rate)
+		l.last = now
+		if l.tokens < 1 {
+			wait := time.Duration((1 - l.tokens) / l.rate * float64(time.Second))
+			return nil, &LimitError{Proxy: l.name, Reason: ReasonRate, RetryAfter: wait}
+		}
+		l.tokens--
+	}
+
+	l.inFlight++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mutex.Lock()
+			l.inFlight--
+			l.mutex.Unlock()
+		})
+	}, nil
+}
+
+// Registry holds the limiters of all configured proxies
+type Registry struct {
+	mutex    sync.RWMutex
+	limiters map[string]*Limiter
+}
+
+// Default is the registry consulted by the proxy listeners
+var Default = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{limiters: make(map[string]*Limiter)}
+}
+
+// Configure replaces the limiters with ones built from the proxies' settings
+func (r *Registry) Configure(proxies map[string]config.ProxyConfig) {
+	limiters := make(map[string]*Limiter)
+	for name, proxyConfig := range proxies {
+		if limiter := NewLimiter(name, proxyConfig); limiter != nil {
+			limiters[name] = limiter
+		}
+	}
+
+	r.mutex.Lock()
+	r.limiters = limiters
+	r.mutex.Unlock()
+}
+
+// Acquire admits a request to a proxy; proxies without limits always admit
+func (r *Registry) Acquire(proxyName string) (func(), error) {
+	r.mutex.RLock()
+	limiter := r.limiters[proxyName]
+	r.mutex.RUnlock()
+
+	if limiter == nil {
+		return func() {}, nil
+	}
+	release, err := limiter.Acquire()
+	if err != nil {
+		metrics.RecordLimited(proxyName, err.(*LimitError).Reason)
+	}
+	return release, err
+}
+
+// Configure replaces the default registry's limiters
+func Configure(proxies map[string]config.ProxyConfig) {
+	Default.Configure(proxies)
+}
+
+// Acquire admits a request through the default registry
+func Acquire(proxyName string) (func(), error) {
+	return Default.Acquire(proxyName)
+}
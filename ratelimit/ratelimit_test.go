@@ -0,0 +1,88 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"mimic/config"
+)
+
+func TestLimiterRate(t *testing.T) {
+	limiter := NewLimiter("api", config.ProxyConfig{RateLimitRPS: 2, RateLimitBurst: 2})
+	now := time.Unix(0, 0)
+	limiter.last = now
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		if _, err := limiter.Acquire(); err != nil {
+			t.Fatalf("Expected request %d within the burst to be admitted, got %v", i, err)
+		}
+	}
+
+	_, err := limiter.Acquire()
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Reason != ReasonRate {
+		t.Fatalf("Expected a rate limit error past the burst, got %v", err)
+	}
+	if limitErr.RetryAfter != 500*time.Millisecond || limitErr.RetryAfterSeconds() != 1 {
+		t.Errorf("Expected a 500ms retry hint rounded to 1s, got %v", limitErr.RetryAfter)
+	}
+
+	// Half a second refills one token at 2 rps
+	now = now.Add(500 * time.Millisecond)
+	if _, err := limiter.Acquire(); err != nil {
+		t.Errorf("Expected a request after the refill to be admitted, got %v", err)
+	}
+
+	// Setting the clock back neither refills nor drains the bucket
+	now = now.Add(-time.Hour)
+	if _, err := limiter.Acquire(); !errors.As(err, &limitErr) {
+		t.Errorf("Expected the bucket to stay empty after the clock went back, got %v", err)
+	}
+	now = now.Add(500 * time.Millisecond)
+	if _, err := limiter.Acquire(); err != nil {
+		t.Errorf("Expected the bucket to refill from the new time, got %v", err)
+	}
+}
+
+func TestLimiterConcurrency(t *testing.T) {
+	limiter := NewLimiter("api", config.ProxyConfig{MaxConcurrentRequests: 1})
+
+	release, err := limiter.Acquire()
+	if err != nil {
+		t.Fatalf("Expected the first request to be admitted, got %v", err)
+	}
+
+	_, err = limiter.Acquire()
+	var limitErr *LimitError
+	if !errors.As(err, &limitErr) || limitErr.Reason != ReasonConcurrency {
+		t.Fatalf("Expected a concurrency error while a request is in flight, got %v", err)
+	}
+
+	// Releasing twice must not free an extra slot
+	release()
+	release()
+	if _, err := limiter.Acquire(); err != nil {
+		t.Errorf("Expected a request after release to be admitted, got %v", err)
+	}
+	if _, err := limiter.Acquire(); err == nil {
+		t.Error("Expected the cap to hold after a double release")
+	}
+}
+
+func TestRegistryWithoutLimits(t *testing.T) {
+	registry := NewRegistry()
+	registry.Configure(map[string]config.ProxyConfig{"open": {}})
+
+	if NewLimiter("open", config.ProxyConfig{}) != nil {
+		t.Error("Expected no limiter for a proxy without limits")
+	}
+	for i := 0; i < 100; i++ {
+		release, err := registry.Acquire("open")
+		if err != nil {
+			t.Fatalf("Expected an unlimited proxy to admit every request, got %v", err)
+		}
+		release()
+	}
+}
@@ -0,0 +1,211 @@
+// Package redact masks secrets and personal data in recorded traffic, with the regexes from
+// recording.redact_patterns and built-in presets chosen by name in recording.redact_presets.
+package redact
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// Replacement is what redaction puts in place of a secret
+const Replacement = "[REDACTED]"
+
+// Built-in presets
+const (
+	PresetAuthorization = "authorization" // Values of credential headers such as Authorization, Cookie, and X-Api-Key
+	PresetJWT           = "jwt"           // JSON Web Tokens anywhere
+	PresetAWSKey        = "aws_key"       // AWS access key IDs, and secret access keys given by name
+	PresetCreditCard    = "credit_card"   // Card numbers that pass the Luhn checksum
+	PresetEmail         = "email"         // Email addresses
+)
+
+// credentialHeaders are the headers the authorization preset blanks, lowercased
+var credentialHeaders = []string{
+	"authorization",
+	"proxy-authorization",
+	"cookie",
+	"set-cookie",
+	"x-api-key",
+	"api-key",
+	"x-auth-token",
+	"x-amz-security-token",
+}
+
+// rule replaces the matches of pattern that valid accepts, or all of them without valid
+type rule struct {
+	pattern *regexp.Regexp
+	valid   func(match string) bool
+	keep    bool // The pattern's first group is kept, such as the name before a secret
+}
+
+// presetRules holds the text rules of each preset; the authorization preset works on headers
+var presetRules = map[string][]rule{
+	PresetAuthorization: nil,
+	PresetJWT: {
+		{pattern: regexp.MustCompile(`eyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`)},
+	},
+	PresetAWSKey: {
+		{pattern: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{pattern: regexp.MustCompile(`(?i)(aws_?secret_?access_?key["']?\s*[:=]\s*["']?)[A-Za-z0-9/+=]{40}`), keep: true},
+	},
+	PresetCreditCard: {
+		{pattern: regexp.MustCompile(`\b[3-6]\d{3}(?:[ -]?\d{4}){2}[ -]?\d{1,7}\b`), valid: CardNumber},
+	},
+	PresetEmail: {
+		{pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`)},
+	},
+}
+
+// Presets lists the built-in presets in the order they are documented
+func Presets() []string {
+	return []string{PresetAuthorization, PresetJWT, PresetAWSKey, PresetCreditCard, PresetEmail}
+}
+
+// ValidatePresets rejects preset names that are not built in
+func ValidatePresets(names []string) error {
+	for _, name := range names {
+		if _, ok := presetRules[name]; !ok {
+			return fmt.Errorf("unknown redact preset %q (expected one of %s)", name, strings.Join(Presets(), ", "))
+		}
+	}
+	return nil
+}
+
+// Redactor masks what the patterns and presets it was built with match. A nil Redactor leaves
+// everything as it is.
+type Redactor struct {
+	rules   []rule
+	headers map[string]bool
+}
+
+// New builds a redactor from regex patterns and preset names. Patterns that do not compile are
+// skipped, as config lint reports them; unknown presets are an error.
+func New(patterns, presets []string) (*Redactor, error) {
+	if err := ValidatePresets(presets); err != nil {
+		return nil, err
+	}
+
+	r := &Redactor{headers: make(map[string]bool)}
+	for _, pattern := range patterns {
+		if compiled, err := regexp.Compile(pattern); err == nil {
+			r.rules = append(r.rules, rule{pattern: compiled})
+		}
+	}
+	for _, name := range presets {
+		r.rules = append(r.rules, presetRules[name]...)
+		if name == PresetAuthorization {
+			for _, header := range credentialHeaders {
+				r.headers[header] = true
+			}
+		}
+	}
+	if len(r.rules) == 0 && len(r.headers) == 0 {
+		return nil, nil
+	}
+	return r, nil
+}
+
+// String masks every match in text
+func (r *Redactor) Text(text string) string {
+	if r == nil {
+		return text
+	}
+	for _, rule := range r.rules {
+		text = rule.redact(text)
+	}
+	return text
+}
+
+// Bytes masks every match in a body. Bodies that are not UTF-8 text, such as images or
+// protobuf messages, are left alone rather than risk corrupting them.
+func (r *Redactor) Bytes(body []byte) []byte {
+	if r == nil || len(body) == 0 || !utf8.Valid(body) {
+		return body
+	}
+	return []byte(r.Text(string(body)))
+}
+
+// Headers masks recorded headers, JSON objects of header names to a value or a list of values:
+// credential headers are blanked whole, and everything else is masked like text
+func (r *Redactor) Headers(headersJSON string) string {
+	if r == nil {
+		return headersJSON
+	}
+	if len(r.headers) > 0 {
+		var headers map[string]interface{}
+		if json.Unmarshal([]byte(headersJSON), &headers) == nil {
+			if r.RedactHeaderMap(headers) {
+				if encoded, err := json.Marshal(headers); err == nil {
+					headersJSON = string(encoded)
+				}
+			}
+		}
+	}
+	return r.Text(headersJSON)
+}
+
+// RedactHeaderMap blanks the credential headers in a decoded header map in place, reporting
+// whether it changed anything. Other values are left for Text.
+func (r *Redactor) RedactHeaderMap(headers map[string]interface{}) bool {
+	if r == nil {
+		return false
+	}
+	changed := false
+	for key, value := range headers {
+		if !r.headers[strings.ToLower(key)] {
+			continue
+		}
+		switch values := value.(type) {
+		case []interface{}:
+			for i := range values {
+				values[i] = Replacement
+			}
+		default:
+			headers[key] = Replacement
+		}
+		changed = true
+	}
+	return changed
+}
+
+func (r rule) redact(text string) string {
+	return r.pattern.ReplaceAllStringFunc(text, func(match string) string {
+		if r.valid != nil && !r.valid(match) {
+			return match
+		}
+		if r.keep {
+			return r.pattern.FindStringSubmatch(match)[1] + Replacement
+		}
+		return Replacement
+	})
+}
+
+// CardNumber reports whether text is a card number: 13 to 19 digits, with any spaces or dashes
+// between them, passing the Luhn checksum
+func CardNumber(text string) bool {
+	var digits []int
+	for _, r := range text {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, int(r-'0'))
+		}
+	}
+	if len(digits) < 13 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		n := digits[i]
+		if (len(digits)-1-i)%2 == 1 {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,249 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Contract mismatch types
+const (
+	MismatchStatus = "status"
+	MismatchHeader = "header"
+	MismatchBody   = "body"
+)
+
+// ContractMismatch is one way a provider's response breaks the recorded contract
+type ContractMismatch struct {
+	Type    string `json:"type"`           // status, header, or body
+	Path    string `json:"path,omitempty"` // JSON path of a body mismatch, e.g. $.items[0].id
+	Message string `json:"message"`
+}
+
+func (m ContractMismatch) String() string {
+	if m.Path != "" {
+		return m.Path + ": " + m.Message
+	}
+	return m.Message
+}
+
+// contractMatch validates a response the way a consumer contract does: the status must be the same,
+// the Content-Type must have the same media type, and a JSON body must have every field the
+// recording had, with values of the same type. Values may differ and extra fields are allowed.
+func (r *ReplayEngine) contractMatch(result *ReplayResult) (bool, string) {
+	result.Mismatches = compareContract(result)
+	if len(result.Mismatches) == 0 {
+		return true, ""
+	}
+
+	messages := make([]string, len(result.Mismatches))
+	for i, mismatch := range result.Mismatches {
+		messages[i] = mismatch.String()
+	}
+	return false, strings.Join(messages, "; ")
+}
+
+func compareContract(result *ReplayResult) []ContractMismatch {
+	var mismatches []ContractMismatch
+	if result.ActualStatus != result.ExpectedStatus {
+		mismatches = append(mismatches, ContractMismatch{
+			Type:    MismatchStatus,
+			Message: fmt.Sprintf("expected status %d, got %d", result.ExpectedStatus, result.ActualStatus),
+		})
+	}
+
+	if expected := mediaType(recordedHeader(result.Interaction.ResponseHeaders, "Content-Type")); expected != "" {
+		if actual := mediaType(result.ActualHeaders["Content-Type"]); actual != expected {
+			mismatches = append(mismatches, ContractMismatch{
+				Type:    MismatchHeader,
+				Message: fmt.Sprintf("expected Content-Type %s, got %q", expected, actual),
+			})
+		}
+	}
+
+	if len(result.ExpectedBody) == 0 || result.Interaction.IsStreaming {
+		return mismatches
+	}
+	var expected interface{}
+	if json.Unmarshal(result.ExpectedBody, &expected) != nil {
+		if len(result.ActualBody) == 0 {
+			mismatches = append(mismatches, ContractMismatch{Type: MismatchBody, Message: "expected a body, got none"})
+		}
+		return mismatches
+	}
+	var actual interface{}
+	if err := json.Unmarshal(result.ActualBody, &actual); err != nil {
+		return append(mismatches, ContractMismatch{Type: MismatchBody, Message: "expected a JSON body, got invalid JSON"})
+	}
+	return matchShape("$", expected, actual, mismatches)
+}
+
+// matchShape appends the places where actual lacks a field of expected or holds a value of a
+// different type. Array elements beyond the recorded ones are matched against the first.
+func matchShape(path string, expected, actual interface{}, mismatches []ContractMismatch) []ContractMismatch {
+	if expected == nil {
+		return mismatches
+	}
+	if jsonType(expected) != jsonType(actual) {
+		return append(mismatches, ContractMismatch{
+			Type:    MismatchBody,
+			Path:    path,
+			Message: fmt.Sprintf("expected %s, got %s", jsonType(expected), jsonType(actual)),
+		})
+	}
+
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		actualValue := actual.(map[string]interface{})
+		keys := make([]string, 0, len(expectedValue))
+		for key := range expectedValue {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			expectedChild := expectedValue[key]
+			childPath := path + "." + key
+			actualChild, ok := actualValue[key]
+			if !ok {
+				mismatches = append(mismatches, ContractMismatch{Type: MismatchBody, Path: childPath, Message: "missing"})
+				continue
+			}
+			mismatches = matchShape(childPath, expectedChild, actualChild, mismatches)
+		}
+	case []interface{}:
+		actualValue := actual.([]interface{})
+		if len(expectedValue) == 0 {
+			break
+		}
+		if len(actualValue) == 0 {
+			return append(mismatches, ContractMismatch{Type: MismatchBody, Path: path, Message: "expected at least 1 element, got none"})
+		}
+		for i, actualChild := range actualValue {
+			expectedChild := expectedValue[0]
+			if i < len(expectedValue) {
+				expectedChild = expectedValue[i]
+			}
+			mismatches = matchShape(fmt.Sprintf("%s[%d]", path, i), expectedChild, actualChild, mismatches)
+		}
+	}
+	return mismatches
+}
+
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// recordedHeader looks up a header in a recording's JSON headers, ignoring case
+func recordedHeader(headersJSON, name string) string {
+	var headers map[string]interface{}
+	json.Unmarshal([]byte(headersJSON), &headers)
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
+
+func mediaType(contentType string) string {
+	if contentType == "" {
+		return ""
+	}
+	if parsed, _, err := mime.ParseMediaType(contentType); err == nil {
+		return parsed
+	}
+	return strings.ToLower(strings.TrimSpace(contentType))
+}
+
+// ContractReport is the outcome of verifying a provider against a consumer contract, in the spirit
+// of a Pact verification result
+type ContractReport struct {
+	Consumer     string                `json:"consumer"`
+	Provider     string                `json:"provider"`
+	ProviderURL  string                `json:"provider_url"`
+	VerifiedAt   time.Time             `json:"verified_at"`
+	Success      bool                  `json:"success"`
+	Interactions []ContractInteraction `json:"interactions"`
+	Unmet        []string              `json:"unmet"` // Descriptions of the interactions the provider did not satisfy
+}
+
+// ContractInteraction is the verification of one interaction of the contract
+type ContractInteraction struct {
+	Description    string             `json:"description"`
+	Method         string             `json:"method"`
+	Endpoint       string             `json:"endpoint"`
+	ExpectedStatus int                `json:"expected_status"`
+	ActualStatus   int                `json:"actual_status,omitempty"`
+	Success        bool               `json:"success"`
+	Mismatches     []ContractMismatch `json:"mismatches,omitempty"`
+	Error          string             `json:"error,omitempty"` // Set when the request could not be made
+}
+
+// NewContractReport summarizes a replay made with the contract matching strategy
+func NewContractReport(consumer, provider, providerURL string, session *ReplaySession) *ContractReport {
+	report := &ContractReport{
+		Consumer:     consumer,
+		Provider:     provider,
+		ProviderURL:  providerURL,
+		VerifiedAt:   session.EndTime,
+		Interactions: []ContractInteraction{},
+		Unmet:        []string{},
+	}
+
+	// Results arrive in completion order; describe them in recorded order
+	results := make([]*ReplayResult, len(session.Results))
+	copy(results, session.Results)
+	sort.SliceStable(results, func(i, j int) bool {
+		if !results[i].Interaction.Timestamp.Equal(results[j].Interaction.Timestamp) {
+			return results[i].Interaction.Timestamp.Before(results[j].Interaction.Timestamp)
+		}
+		return results[i].Interaction.ID < results[j].Interaction.ID
+	})
+
+	occurrences := make(map[string]int)
+	for _, result := range results {
+		description := result.Interaction.Method + " " + result.Interaction.Endpoint
+		occurrences[description]++
+		if n := occurrences[description]; n > 1 {
+			description = fmt.Sprintf("%s (call %d)", description, n)
+		}
+
+		interaction := ContractInteraction{
+			Description:    description,
+			Method:         result.Interaction.Method,
+			Endpoint:       result.Interaction.Endpoint,
+			ExpectedStatus: result.ExpectedStatus,
+			ActualStatus:   result.ActualStatus,
+			Success:        result.Success,
+			Mismatches:     result.Mismatches,
+			Error:          result.ErrorMessage,
+		}
+		if !interaction.Success && len(interaction.Mismatches) == 0 && interaction.Error == "" {
+			interaction.Error = result.ValidationError
+		}
+		report.Interactions = append(report.Interactions, interaction)
+		if !interaction.Success {
+			report.Unmet = append(report.Unmet, description)
+		}
+	}
+	report.Success = len(report.Unmet) == 0 && len(report.Interactions) > 0
+	return report
+}
@@ -0,0 +1,87 @@
+package replay
+
+import (
+	"testing"
+	"time"
+
+	"mimic/storage"
+)
+
+func TestCompareContract(t *testing.T) {
+	expected := `{"id": 1, "name": "widget", "tags": ["a"], "owner": {"id": 7}, "note": null}`
+
+	tests := []struct {
+		name         string
+		status       int
+		contentType  string
+		body         string
+		wantMismatch []string
+	}{
+		{"same shape, different values", 200, "application/json; charset=utf-8", `{"id": 2, "name": "gadget", "tags": ["b", "c"], "owner": {"id": 9}, "note": "x", "extra": true}`, nil},
+		{"status", 500, "application/json", expected, []string{"expected status 200, got 500"}},
+		{"content type", 200, "text/html", expected, []string{`expected Content-Type application/json, got "text/html"`}},
+		{"missing and retyped fields", 200, "application/json", `{"id": "1", "tags": [], "owner": {}}`, []string{
+			"$.id: expected number, got string",
+			"$.name: missing",
+			"$.note: missing",
+			"$.owner.id: missing",
+			"$.tags: expected at least 1 element, got none",
+		}},
+		{"array elements", 200, "application/json", `{"id": 1, "name": "w", "tags": ["a", 2], "owner": {"id": 7}, "note": null}`, []string{"$.tags[1]: expected string, got number"}},
+		{"not JSON", 200, "application/json", `oops`, []string{"expected a JSON body, got invalid JSON"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := &ReplayResult{
+				Interaction:    &storage.Interaction{ResponseHeaders: `{"Content-Type": "application/json"}`},
+				ExpectedStatus: 200,
+				ExpectedBody:   []byte(expected),
+				ActualStatus:   tt.status,
+				ActualHeaders:  map[string]string{"Content-Type": tt.contentType},
+				ActualBody:     []byte(tt.body),
+			}
+			mismatches := compareContract(result)
+			if len(mismatches) != len(tt.wantMismatch) {
+				t.Fatalf("Expected %d mismatches, got %v", len(tt.wantMismatch), mismatches)
+			}
+			for i, mismatch := range mismatches {
+				if mismatch.String() != tt.wantMismatch[i] {
+					t.Errorf("Mismatch %d: expected %q, got %q", i, tt.wantMismatch[i], mismatch.String())
+				}
+			}
+		})
+	}
+}
+
+func TestNewContractReport(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	session := &ReplaySession{
+		EndTime: base.Add(time.Minute),
+		// Completion order differs from recorded order
+		Results: []*ReplayResult{
+			{Interaction: &storage.Interaction{ID: 3, Method: "GET", Endpoint: "/items", Timestamp: base.Add(2 * time.Second)}, ExpectedStatus: 200, ActualStatus: 500, Success: false,
+				Mismatches: []ContractMismatch{{Type: MismatchStatus, Message: "expected status 200, got 500"}}},
+			{Interaction: &storage.Interaction{ID: 1, Method: "GET", Endpoint: "/items", Timestamp: base}, ExpectedStatus: 200, ActualStatus: 200, Success: true},
+			{Interaction: &storage.Interaction{ID: 2, Method: "POST", Endpoint: "/orders", Timestamp: base.Add(time.Second)}, ExpectedStatus: 201, Success: false,
+				ErrorMessage: "request failed: connection refused"},
+		},
+	}
+
+	report := NewContractReport("web", "orders", "http://localhost:3000", session)
+	if report.Success {
+		t.Error("Expected the report to fail")
+	}
+	descriptions := []string{"GET /items", "POST /orders", "GET /items (call 2)"}
+	for i, interaction := range report.Interactions {
+		if interaction.Description != descriptions[i] {
+			t.Errorf("Interaction %d: expected %q, got %q", i, descriptions[i], interaction.Description)
+		}
+	}
+	if len(report.Unmet) != 2 || report.Unmet[0] != "POST /orders" || report.Unmet[1] != "GET /items (call 2)" {
+		t.Errorf("Unexpected unmet interactions: %v", report.Unmet)
+	}
+	if report.Interactions[1].Error != "request failed: connection refused" {
+		t.Errorf("Expected the request error to be kept, got %q", report.Interactions[1].Error)
+	}
+}
@@ -3,12 +3,14 @@ package replay
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -19,9 +21,12 @@ import (
 	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
+	"mimic/accesslog"
 	"mimic/config"
+	"mimic/metrics"
 	"mimic/proxy"
 	"mimic/storage"
+	"mimic/tag"
 )
 
 // ReplayResult represents the result of replaying a single interaction
@@ -32,11 +37,17 @@ type ReplayResult struct {
 	ActualStatus    int                  `json:"actual_status"`
 	ExpectedBody    []byte               `json:"expected_body"`
 	ActualBody      []byte               `json:"actual_body"`
+	ActualHeaders   map[string]string    `json:"actual_headers,omitempty"`
 	ResponseTime    time.Duration        `json:"response_time"`
-	Error           error                `json:"error,omitempty"`
+	Error           error                `json:"-"`
+	ErrorMessage    string               `json:"error,omitempty"` // Error rendered as text for JSON consumers
 	ValidationError string               `json:"validation_error,omitempty"`
+	Mismatches      []ContractMismatch   `json:"mismatches,omitempty"` // Set by the contract matching strategy
 }
 
+// ProgressFunc is invoked after each interaction has been replayed
+type ProgressFunc func(result *ReplayResult, completed, total int)
+
 // ReplaySession represents the overall replay session results
 type ReplaySession struct {
 	SessionName   string          `json:"session_name"`
@@ -58,6 +69,8 @@ type ReplayEngine struct {
 	grpcConn *grpc.ClientConn
 	results  []*ReplayResult
 	mutex    sync.RWMutex
+	total    int
+	progress ProgressFunc
 }
 
 // NewReplayEngine creates a new replay engine
@@ -67,8 +80,19 @@ func NewReplayEngine(replayConfig *config.ReplayConfig, db *storage.Database) (*
 		return nil, fmt.Errorf("failed to get session '%s': %w", replayConfig.SessionName, err)
 	}
 
+	proxyFunc, err := proxy.OutboundProxyFunc(replayConfig.OutboundProxy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure outbound proxy: %w", err)
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxyFunc
+	if replayConfig.InsecureSkipVerify {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+
 	httpClient := &http.Client{
-		Timeout: time.Duration(replayConfig.TimeoutSeconds) * time.Second,
+		Timeout:   time.Duration(replayConfig.TimeoutSeconds) * time.Second,
+		Transport: transport,
 	}
 
 	var grpcConn *grpc.ClientConn
@@ -96,7 +120,7 @@ func NewReplayEngine(replayConfig *config.ReplayConfig, db *storage.Database) (*
 			creds = insecure.NewCredentials()
 		} else {
 			// Use TLS credentials for secure connections
-			creds = credentials.NewTLS(nil)
+			creds = credentials.NewTLS(&tls.Config{InsecureSkipVerify: replayConfig.InsecureSkipVerify})
 		}
 
 		target := fmt.Sprintf("%s:%d", replayConfig.TargetHost, replayConfig.TargetPort)
@@ -121,6 +145,12 @@ func NewReplayEngine(replayConfig *config.ReplayConfig, db *storage.Database) (*
 			grpc.WithWriteBufferSize(maxSize),
 		}
 
+		outboundOpts, err := proxy.OutboundGRPCDialOptions(replayConfig.OutboundProxy)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure outbound proxy: %w", err)
+		}
+		dialOpts = append(dialOpts, outboundOpts...)
+
 		conn, err := grpc.Dial(target, dialOpts...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create gRPC connection: %w", err)
@@ -138,6 +168,11 @@ func NewReplayEngine(replayConfig *config.ReplayConfig, db *storage.Database) (*
 	}, nil
 }
 
+// SetProgressCallback registers a callback that is notified as each interaction completes
+func (r *ReplayEngine) SetProgressCallback(fn ProgressFunc) {
+	r.progress = fn
+}
+
 // Replay replays all interactions from the session against the target server
 func (r *ReplayEngine) Replay() (*ReplaySession, error) {
 	log.Printf("Starting replay of session '%s' against %s://%s:%d",
@@ -148,6 +183,13 @@ func (r *ReplayEngine) Replay() (*ReplaySession, error) {
 		return nil, fmt.Errorf("failed to get interactions: %w", err)
 	}
 
+	if r.config.Tag != "" {
+		interactions = tag.Filter(interactions, r.config.Tag)
+		if len(interactions) == 0 {
+			return nil, fmt.Errorf("no interactions tagged '%s' found in session '%s'", r.config.Tag, r.config.SessionName)
+		}
+	}
+
 	if len(interactions) == 0 {
 		return nil, fmt.Errorf("no interactions found in session '%s'", r.config.SessionName)
 	}
@@ -157,6 +199,8 @@ func (r *ReplayEngine) Replay() (*ReplaySession, error) {
 		return interactions[i].Timestamp.Before(interactions[j].Timestamp)
 	})
 
+	r.total = len(interactions)
+
 	replaySession := &ReplaySession{
 		SessionName:   r.config.SessionName,
 		TotalRequests: len(interactions),
@@ -197,7 +241,10 @@ func (r *ReplayEngine) Replay() (*ReplaySession, error) {
 func (r *ReplayEngine) replaySequential(interactions []storage.Interaction, replaySession *ReplaySession) error {
 	var baseTime *time.Time
 
-	for i, interaction := range interactions {
+	for i := range interactions {
+		// Results keep a pointer to the interaction, so take it from the slice rather than the loop variable
+		interaction := &interactions[i]
+
 		// Calculate delay based on original timestamps
 		if !r.config.IgnoreTimestamps && baseTime != nil {
 			delay := interaction.Timestamp.Sub(*baseTime)
@@ -209,7 +256,7 @@ func (r *ReplayEngine) replaySequential(interactions []storage.Interaction, repl
 			baseTime = &interaction.Timestamp
 		}
 
-		result := r.replayInteraction(&interaction)
+		result := r.replayInteraction(interaction)
 		r.addResult(result)
 
 		if !result.Success && r.config.FailFast {
@@ -256,8 +303,30 @@ func (r *ReplayEngine) replayConcurrent(interactions []storage.Interaction, repl
 	return firstError
 }
 
+// ReplayInteraction replays a single interaction outside of a full session replay
+func (r *ReplayEngine) ReplayInteraction(interaction *storage.Interaction) *ReplayResult {
+	result := r.replayInteraction(interaction)
+	if result.Error != nil {
+		result.ErrorMessage = result.Error.Error()
+	}
+	return result
+}
+
+// Close releases connections held by the engine
+func (r *ReplayEngine) Close() error {
+	if r.grpcConn != nil {
+		return r.grpcConn.Close()
+	}
+	return nil
+}
+
 // replayInteraction replays a single interaction and validates the response
 func (r *ReplayEngine) replayInteraction(interaction *storage.Interaction) *ReplayResult {
+	// Replaying sends and compares whole bodies, including those kept in body files
+	if err := r.database.LoadBodyFiles(interaction); err != nil {
+		return &ReplayResult{Interaction: interaction, Error: err}
+	}
+
 	result := &ReplayResult{
 		Interaction:    interaction,
 		ExpectedStatus: interaction.ResponseStatus,
@@ -268,10 +337,32 @@ func (r *ReplayEngine) replayInteraction(interaction *storage.Interaction) *Repl
 
 	// Handle gRPC interactions differently from HTTP
 	if interaction.Protocol == "gRPC" {
-		return r.replayGRPCInteraction(interaction, result, startTime)
+		result = r.replayGRPCInteraction(interaction, result, startTime)
 	} else {
-		return r.replayHTTPInteraction(interaction, result, startTime)
+		result = r.replayHTTPInteraction(interaction, result, startTime)
 	}
+	r.logAccess(result, startTime)
+	return result
+}
+
+// logAccess writes the access log entry of a replayed interaction
+func (r *ReplayEngine) logAccess(result *ReplayResult, startTime time.Time) {
+	match := accesslog.MatchFailed
+	if result.Success {
+		match = accesslog.MatchPassed
+	}
+	accesslog.Log(&accesslog.Entry{
+		Time:     startTime,
+		Mode:     "replay",
+		Method:   result.Interaction.Method,
+		Path:     result.Interaction.Endpoint,
+		Protocol: result.Interaction.Protocol,
+		Status:   result.ActualStatus,
+		Bytes:    int64(len(result.ActualBody)),
+		Latency:  time.Since(startTime),
+		Session:  r.config.SessionName,
+		Match:    match,
+	})
 }
 
 // replayHTTPInteraction handles HTTP/HTTPS replay
@@ -282,10 +373,15 @@ func (r *ReplayEngine) replayHTTPInteraction(interaction *storage.Interaction, r
 	}
 
 	// Construct the request URL
-	url := fmt.Sprintf("%s://%s:%d%s", r.config.Protocol, r.config.TargetHost, r.config.TargetPort, interaction.Endpoint)
+	url := fmt.Sprintf("%s://%s:%d%s%s", r.config.Protocol, r.config.TargetHost, r.config.TargetPort, strings.TrimSuffix(r.config.BasePath, "/"), interaction.Endpoint)
 
-	// Create the HTTP request
-	req, err := http.NewRequest(interaction.Method, url, bytes.NewBuffer(interaction.RequestBody))
+	// Create the HTTP request, compressing the body again if it was recorded decompressed
+	requestBody, err := interaction.RequestBodyAsSent()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	req, err := http.NewRequest(interaction.Method, url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		result.Error = fmt.Errorf("failed to create request: %w", err)
 		return result
@@ -312,6 +408,7 @@ func (r *ReplayEngine) replayHTTPInteraction(interaction *storage.Interaction, r
 
 	result.ResponseTime = time.Since(startTime)
 	result.ActualStatus = resp.StatusCode
+	result.ActualHeaders = flattenHeaders(resp.Header)
 
 	// Read response body
 	var actualBody bytes.Buffer
@@ -321,19 +418,31 @@ func (r *ReplayEngine) replayHTTPInteraction(interaction *storage.Interaction, r
 	}
 	result.ActualBody = actualBody.Bytes()
 
+	// The recorded body was decompressed, so the actual one is compared decompressed too
+	if encoding := resp.Header.Get("Content-Encoding"); interaction.ResponseEncoding() != "" && storage.Compressed(encoding) {
+		if decoded, err := storage.Decompress(result.ActualBody, encoding); err == nil {
+			result.ActualBody = decoded
+		}
+	}
+
 	// Validate the response based on matching strategy
 	result.Success, result.ValidationError = r.validateResponse(result)
 
 	return result
 }
 
-// replayStreamingInteraction handles streaming SSE replay
+// replayStreamingInteraction handles streaming replay, of SSE and other chunked streams
 func (r *ReplayEngine) replayStreamingInteraction(interaction *storage.Interaction, result *ReplayResult, startTime time.Time) *ReplayResult {
 	// Construct the request URL
-	url := fmt.Sprintf("%s://%s:%d%s", r.config.Protocol, r.config.TargetHost, r.config.TargetPort, interaction.Endpoint)
+	url := fmt.Sprintf("%s://%s:%d%s%s", r.config.Protocol, r.config.TargetHost, r.config.TargetPort, strings.TrimSuffix(r.config.BasePath, "/"), interaction.Endpoint)
 
-	// Create the HTTP request
-	req, err := http.NewRequest(interaction.Method, url, bytes.NewBuffer(interaction.RequestBody))
+	// Create the HTTP request, compressing the body again if it was recorded decompressed
+	requestBody, err := interaction.RequestBodyAsSent()
+	if err != nil {
+		result.Error = err
+		return result
+	}
+	req, err := http.NewRequest(interaction.Method, url, bytes.NewBuffer(requestBody))
 	if err != nil {
 		result.Error = fmt.Errorf("failed to create request: %w", err)
 		return result
@@ -359,6 +468,7 @@ func (r *ReplayEngine) replayStreamingInteraction(interaction *storage.Interacti
 	defer resp.Body.Close()
 
 	result.ActualStatus = resp.StatusCode
+	result.ActualHeaders = flattenHeaders(resp.Header)
 
 	// Retrieve the expected stream chunks from the database
 	expectedChunks, err := r.database.GetStreamChunks(interaction.ID)
@@ -384,6 +494,18 @@ func (r *ReplayEngine) replayStreamingInteraction(interaction *storage.Interacti
 		result.ActualBody = actualBody.Bytes()
 		result.ResponseTime = time.Since(startTime)
 
+		// Streams other than SSE split wherever the target flushed, which no replay reproduces,
+		// so they are compared whole
+		if !interaction.EventStream() {
+			var expectedBody bytes.Buffer
+			for _, chunk := range expectedChunks {
+				expectedBody.Write(chunk.Data)
+			}
+			result.ExpectedBody = expectedBody.Bytes()
+			result.Success, result.ValidationError = r.validateStreamingResponse(result, min(len(expectedChunks), 1), min(len(result.ActualBody), 1))
+			return result
+		}
+
 		// Validate that we expected a streaming response
 		if len(expectedChunks) > 0 {
 			result.Success = false
@@ -476,6 +598,15 @@ func (r *ReplayEngine) validateStreamingResponse(result *ReplayResult, expectedC
 		}
 		return true, ""
 
+	case "contract":
+		if ok, message := r.contractMatch(result); !ok {
+			return false, message
+		}
+		if actualChunks == 0 && expectedChunks > 0 {
+			return false, "expected streaming response but got no chunks"
+		}
+		return true, ""
+
 	case "status_code":
 		// Only validate status code
 		if result.ActualStatus != result.ExpectedStatus {
@@ -573,6 +704,8 @@ func (r *ReplayEngine) validateResponse(result *ReplayResult) (bool, string) {
 		return r.fuzzyMatch(result)
 	case "status_code":
 		return r.statusCodeMatch(result)
+	case "contract":
+		return r.contractMatch(result)
 	default:
 		return r.exactMatch(result)
 	}
@@ -623,6 +756,15 @@ func (r *ReplayEngine) statusCodeMatch(result *ReplayResult) (bool, string) {
 	return true, ""
 }
 
+// flattenHeaders joins repeated header values the way recordings store them
+func flattenHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string, len(header))
+	for key, values := range header {
+		headers[key] = strings.Join(values, ", ")
+	}
+	return headers
+}
+
 // isJSON checks if the given bytes represent valid JSON
 func (r *ReplayEngine) isJSON(data []byte) bool {
 	var js interface{}
@@ -631,9 +773,20 @@ func (r *ReplayEngine) isJSON(data []byte) bool {
 
 // addResult adds a result to the engine's results slice (thread-safe)
 func (r *ReplayEngine) addResult(result *ReplayResult) {
+	if result.Error != nil {
+		result.ErrorMessage = result.Error.Error()
+	}
+
+	metrics.RecordReplayResult(result.Success)
+
 	r.mutex.Lock()
-	defer r.mutex.Unlock()
 	r.results = append(r.results, result)
+	completed := len(r.results)
+	r.mutex.Unlock()
+
+	if r.progress != nil {
+		r.progress(result, completed, r.total)
+	}
 }
 
 // countSuccesses counts the number of successful replays
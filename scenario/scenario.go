@@ -0,0 +1,237 @@
+// Package scenario checks recorded sessions against expected call sequences declared in YAML,
+// e.g. "POST /login, then GET /profile, then at least one GET /items".
+package scenario
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a scenario file
+type File struct {
+	Session   string     `yaml:"session"` // Default session for scenarios that do not name one
+	Scenarios []Scenario `yaml:"scenarios"`
+}
+
+// Scenario is a named list of steps a session must satisfy
+type Scenario struct {
+	Name    string `yaml:"name"`
+	Session string `yaml:"session"`
+	// Ordered steps must be called in order; calls matching no step are ignored either way.
+	// Defaults to true.
+	Ordered *bool  `yaml:"ordered"`
+	Steps   []Step `yaml:"steps"`
+}
+
+// IsOrdered reports whether the steps must happen in order
+func (s Scenario) IsOrdered() bool {
+	return s.Ordered == nil || *s.Ordered
+}
+
+// Unbounded is the Max of a step without an upper limit
+const Unbounded = -1
+
+// Step is a call the scenario expects, written "[count] METHOD /path" or "[count] /path". The
+// count is N, >=N (or ≥N), <=N (or ≤N), or N-M, and defaults to >=1. Method * matches any method,
+// and path segments * and {name} match any one segment, with a final ** matching the rest.
+type Step struct {
+	Call   string // As written, without the count
+	Method string // Upper case; empty matches any method
+	Path   string
+	Min    int
+	Max    int // Unbounded for no upper limit
+}
+
+// UnmarshalYAML reads a step from its string form, or from a mapping with call and times
+func (s *Step) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.ScalarNode {
+		step, err := ParseStep(node.Value)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", node.Line, err)
+		}
+		*s = step
+		return nil
+	}
+
+	var fields struct {
+		Call  string `yaml:"call"`
+		Times string `yaml:"times"`
+	}
+	if err := node.Decode(&fields); err != nil {
+		return err
+	}
+	text := fields.Call
+	if fields.Times != "" {
+		text = fields.Times + " " + fields.Call
+	}
+	step, err := ParseStep(text)
+	if err != nil {
+		return fmt.Errorf("line %d: %w", node.Line, err)
+	}
+	*s = step
+	return nil
+}
+
+// ParseStep parses the string form of a step
+func ParseStep(text string) (Step, error) {
+	fields := strings.Fields(text)
+	step := Step{Min: 1, Max: Unbounded}
+	if len(fields) > 0 && !strings.HasPrefix(fields[0], "/") && isCount(fields[0]) {
+		low, high, err := parseCount(fields[0])
+		if err != nil {
+			return Step{}, fmt.Errorf("invalid step %q: %w", text, err)
+		}
+		step.Min, step.Max = low, high
+		fields = fields[1:]
+	}
+
+	switch len(fields) {
+	case 1:
+		step.Path = fields[0]
+	case 2:
+		step.Method, step.Path = strings.ToUpper(fields[0]), fields[1]
+		if step.Method == "*" {
+			step.Method = ""
+		}
+	default:
+		return Step{}, fmt.Errorf("invalid step %q: expected [count] METHOD /path", text)
+	}
+	if !strings.HasPrefix(step.Path, "/") {
+		return Step{}, fmt.Errorf("invalid step %q: path must start with /", text)
+	}
+	step.Call = strings.Join(fields, " ")
+	return step, nil
+}
+
+// isCount reports whether a step's first word is a count rather than a method
+func isCount(word string) bool {
+	first, _ := utf8.DecodeRuneInString(word)
+	return strings.ContainsRune("0123456789<>≤≥", first)
+}
+
+// parseCount parses N, >=N, ≥N, <=N, ≤N, or N-M
+func parseCount(count string) (int, int, error) {
+	number := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid count %q", count)
+		}
+		return n, nil
+	}
+
+	for _, prefix := range []string{">=", "≥"} {
+		if rest, ok := strings.CutPrefix(count, prefix); ok {
+			n, err := number(rest)
+			return n, Unbounded, err
+		}
+	}
+	for _, prefix := range []string{"<=", "≤"} {
+		if rest, ok := strings.CutPrefix(count, prefix); ok {
+			n, err := number(rest)
+			return 0, n, err
+		}
+	}
+	if lowText, highText, ok := strings.Cut(count, "-"); ok {
+		low, err := number(lowText)
+		if err != nil {
+			return 0, 0, err
+		}
+		high, err := number(highText)
+		if err != nil {
+			return 0, 0, err
+		}
+		if high < low {
+			return 0, 0, fmt.Errorf("invalid count %q: the upper bound is below the lower", count)
+		}
+		return low, high, nil
+	}
+	n, err := number(count)
+	return n, n, err
+}
+
+// Matches reports whether a recorded call satisfies the step
+func (s Step) Matches(method, endpoint string) bool {
+	if s.Method != "" && !strings.EqualFold(s.Method, method) {
+		return false
+	}
+	return MatchPath(s.Path, endpoint)
+}
+
+// MatchPath reports whether a path fits a step's path pattern, where segments * and {name} match
+// any one segment and a final ** matches the rest
+func MatchPath(pattern, path string) bool {
+	patternSegments := strings.Split(strings.Trim(pattern, "/"), "/")
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+	for i, segment := range patternSegments {
+		if segment == "**" && i == len(patternSegments)-1 {
+			return true
+		}
+		if i >= len(pathSegments) {
+			return false
+		}
+		if segment == "*" || (strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")) {
+			continue
+		}
+		if segment != pathSegments[i] {
+			return false
+		}
+	}
+	return len(patternSegments) == len(pathSegments)
+}
+
+// describeCount describes how many calls a step expects
+func (s Step) describeCount() string {
+	switch {
+	case s.Max == 0:
+		return "no calls"
+	case s.Min == s.Max:
+		return fmt.Sprintf("exactly %d %s", s.Min, callsWord(s.Min))
+	case s.Max == Unbounded:
+		return fmt.Sprintf("at least %d %s", s.Min, callsWord(s.Min))
+	case s.Min == 0:
+		return fmt.Sprintf("at most %d %s", s.Max, callsWord(s.Max))
+	default:
+		return fmt.Sprintf("%d to %d calls", s.Min, s.Max)
+	}
+}
+
+func callsWord(n int) string {
+	if n == 1 {
+		return "call"
+	}
+	return "calls"
+}
+
+// Load reads and checks a scenario file
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scenarios: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse reads and checks the contents of a scenario file
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse scenarios: %w", err)
+	}
+	if len(file.Scenarios) == 0 {
+		return nil, fmt.Errorf("no scenarios defined")
+	}
+	for i, scenario := range file.Scenarios {
+		if scenario.Name == "" {
+			file.Scenarios[i].Name = fmt.Sprintf("scenario %d", i+1)
+		}
+		if len(scenario.Steps) == 0 {
+			return nil, fmt.Errorf("%s has no steps", file.Scenarios[i].Name)
+		}
+	}
+	return &file, nil
+}
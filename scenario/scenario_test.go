@@ -0,0 +1,125 @@
+package scenario
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"mimic/storage"
+)
+
+func TestParseStep(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected Step
+	}{
+		{"POST /login", Step{Call: "POST /login", Method: "POST", Path: "/login", Min: 1, Max: Unbounded}},
+		{"2 get /items/{id}", Step{Call: "get /items/{id}", Method: "GET", Path: "/items/{id}", Min: 2, Max: 2}},
+		{"≥1 GET /items", Step{Call: "GET /items", Method: "GET", Path: "/items", Min: 1, Max: Unbounded}},
+		{"<=3 * /health", Step{Call: "* /health", Path: "/health", Min: 0, Max: 3}},
+		{"1-2 /users.UserService/GetUser", Step{Call: "/users.UserService/GetUser", Path: "/users.UserService/GetUser", Min: 1, Max: 2}},
+	}
+	for _, tt := range tests {
+		step, err := ParseStep(tt.text)
+		if err != nil {
+			t.Errorf("ParseStep(%q) failed: %v", tt.text, err)
+			continue
+		}
+		if !reflect.DeepEqual(step, tt.expected) {
+			t.Errorf("ParseStep(%q): expected %+v, got %+v", tt.text, tt.expected, step)
+		}
+	}
+
+	for _, text := range []string{"", "GET", "GET items", "3-1 GET /items", ">=x GET /items", "GET /a /b"} {
+		if _, err := ParseStep(text); err == nil {
+			t.Errorf("Expected ParseStep(%q) to fail", text)
+		}
+	}
+}
+
+func TestStepMatches(t *testing.T) {
+	tests := []struct {
+		step, method, endpoint string
+		expected               bool
+	}{
+		{"GET /items", "GET", "/items", true},
+		{"GET /items", "POST", "/items", false},
+		{"GET /items/{id}", "GET", "/items/42", true},
+		{"GET /items/*", "GET", "/items", false},
+		{"* /admin/**", "DELETE", "/admin/users/7", true},
+		{"/users.UserService/GetUser", "/users.UserService/GetUser", "/users.UserService/GetUser", true},
+	}
+	for _, tt := range tests {
+		step, err := ParseStep(tt.step)
+		if err != nil {
+			t.Fatalf("ParseStep(%q) failed: %v", tt.step, err)
+		}
+		if got := step.Matches(tt.method, tt.endpoint); got != tt.expected {
+			t.Errorf("%q matching %s %s: expected %v, got %v", tt.step, tt.method, tt.endpoint, tt.expected, got)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	file, err := Parse([]byte(`
+session: checkout
+scenarios:
+  - name: sign in and browse
+    steps:
+      - POST /login
+      - GET /profile
+      - call: GET /items/{id}
+        times: ">=1"
+  - name: no admin calls
+    ordered: false
+    steps:
+      - 0 * /admin/**
+      - 1 GET /profile
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+
+	start := time.Now()
+	session := func(calls ...string) []storage.Interaction {
+		interactions := make([]storage.Interaction, len(calls))
+		for i := 0; i < len(calls); i += 2 {
+			interactions[i/2] = storage.Interaction{ID: i/2 + 1, Method: calls[i], Endpoint: calls[i+1], Timestamp: start.Add(time.Duration(i) * time.Second)}
+		}
+		return interactions[:len(calls)/2]
+	}
+
+	tests := []struct {
+		name         string
+		scenario     int
+		interactions []storage.Interaction
+		failures     []string
+	}{
+		{"passes, ignoring unrelated calls", 0, session("POST", "/login", "GET", "/config", "GET", "/profile", "GET", "/items/1", "GET", "/items/2"), nil},
+		{"skipped call", 0, session("POST", "/login", "GET", "/items/1"),
+			[]string{"step 2 (GET /profile): expected at least 1 call, got 0 before GET /items/1 (interaction 2)"}},
+		{"out of order", 0, session("POST", "/login", "GET", "/profile", "GET", "/items/1", "POST", "/login"),
+			[]string{"unexpected POST /login (interaction 4) after the last step"}},
+		{"missing final step", 0, session("POST", "/login", "GET", "/profile"),
+			[]string{"step 3 (GET /items/{id}): expected at least 1 call, got 0"}},
+		{"counts", 1, session("GET", "/profile", "DELETE", "/admin/cache", "GET", "/profile"),
+			[]string{"step 1 (* /admin/**): expected no calls, got 1", "step 2 (GET /profile): expected exactly 1 call, got 2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Verify(file.Scenarios[tt.scenario], file.Session, tt.interactions)
+			if result.Passed != (len(tt.failures) == 0) || !reflect.DeepEqual(result.Failures, tt.failures) {
+				t.Errorf("Expected failures %q, got %q (passed %v)", tt.failures, result.Failures, result.Passed)
+			}
+		})
+	}
+}
+
+func TestParseRejectsEmptyScenarios(t *testing.T) {
+	if _, err := Parse([]byte("scenarios: []")); err == nil {
+		t.Error("Expected a file without scenarios to be rejected")
+	}
+	if _, err := Parse([]byte("scenarios:\n  - name: empty\n")); err == nil {
+		t.Error("Expected a scenario without steps to be rejected")
+	}
+}
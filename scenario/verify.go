@@ -0,0 +1,129 @@
+package scenario
+
+import (
+	"fmt"
+	"sort"
+
+	"mimic/storage"
+)
+
+// Result is the outcome of checking one scenario against a session
+type Result struct {
+	Scenario string   `json:"scenario"`
+	Session  string   `json:"session"`
+	Passed   bool     `json:"passed"`
+	Calls    int      `json:"calls"`              // Recorded calls matching any of the scenario's steps
+	Failures []string `json:"failures,omitempty"` // Why the scenario failed, e.g. "step 2 (GET /profile): ..."
+}
+
+// Verify checks the interactions of a session against a scenario. Interactions are taken in the
+// order they were recorded.
+func Verify(scenario Scenario, session string, interactions []storage.Interaction) Result {
+	ordered := make([]storage.Interaction, len(interactions))
+	copy(ordered, interactions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if !ordered[i].Timestamp.Equal(ordered[j].Timestamp) {
+			return ordered[i].Timestamp.Before(ordered[j].Timestamp)
+		}
+		return ordered[i].ID < ordered[j].ID
+	})
+
+	// Calls that match no step are not part of the scenario
+	var relevant []storage.Interaction
+	for _, interaction := range ordered {
+		for _, step := range scenario.Steps {
+			if step.Matches(interaction.Method, interaction.Endpoint) {
+				relevant = append(relevant, interaction)
+				break
+			}
+		}
+	}
+
+	result := Result{Scenario: scenario.Name, Session: session, Calls: len(relevant)}
+	if scenario.IsOrdered() {
+		if failure := verifyOrdered(scenario.Steps, relevant); failure != "" {
+			result.Failures = append(result.Failures, failure)
+		}
+	} else {
+		result.Failures = verifyCounts(scenario.Steps, relevant)
+	}
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// verifyCounts checks how often each step was called, regardless of order
+func verifyCounts(steps []Step, calls []storage.Interaction) []string {
+	var failures []string
+	for i, step := range steps {
+		count := 0
+		for _, call := range calls {
+			if step.Matches(call.Method, call.Endpoint) {
+				count++
+			}
+		}
+		if count < step.Min || (step.Max != Unbounded && count > step.Max) {
+			failures = append(failures, fmt.Sprintf("step %d (%s): expected %s, got %d", i+1, step.Call, step.describeCount(), count))
+		}
+	}
+	return failures
+}
+
+// verifyOrdered checks that the calls are a run of each step in turn, with each run's length within
+// the step's count. A call may match several steps, so every way of splitting the calls into runs
+// is tried. On failure it explains the furthest point any split reached.
+func verifyOrdered(steps []Step, calls []storage.Interaction) string {
+	// reached[j][i]: the first j steps can account for the first i calls
+	reached := make([][]bool, len(steps)+1)
+	for j := range reached {
+		reached[j] = make([]bool, len(calls)+1)
+	}
+	reached[0][0] = true
+	for j, step := range steps {
+		for i := 0; i <= len(calls); i++ {
+			if !reached[j][i] {
+				continue
+			}
+			for run := 0; i+run <= len(calls); run++ {
+				if run >= step.Min && (step.Max == Unbounded || run <= step.Max) {
+					reached[j+1][i+run] = true
+				}
+				if i+run == len(calls) || !step.Matches(calls[i+run].Method, calls[i+run].Endpoint) {
+					break
+				}
+			}
+		}
+	}
+	if reached[len(steps)][len(calls)] {
+		return ""
+	}
+
+	// Explain from the furthest state reached: the most steps done, then the most calls used
+	for j := len(steps); j >= 0; j-- {
+		for i := len(calls); i >= 0; i-- {
+			if !reached[j][i] {
+				continue
+			}
+			if j == len(steps) {
+				return fmt.Sprintf("unexpected %s after the last step", describeCall(calls[i]))
+			}
+			step := steps[j]
+			run := 0
+			for i+run < len(calls) && step.Matches(calls[i+run].Method, calls[i+run].Endpoint) {
+				run++
+			}
+			message := fmt.Sprintf("step %d (%s): expected %s, got %d", j+1, step.Call, step.describeCount(), run)
+			if i+run < len(calls) {
+				message += fmt.Sprintf(" before %s", describeCall(calls[i+run]))
+			}
+			return message
+		}
+	}
+	return ""
+}
+
+func describeCall(call storage.Interaction) string {
+	if call.Method == call.Endpoint {
+		return fmt.Sprintf("%s (interaction %d)", call.Endpoint, call.ID)
+	}
+	return fmt.Sprintf("%s %s (interaction %d)", call.Method, call.Endpoint, call.ID)
+}
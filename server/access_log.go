@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"mimic/accesslog"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// newHTTPAccessEntry starts the access log entry of a request to an HTTP proxy, before its path is rewritten
+func (s *MultiProxyServer) newHTTPAccessEntry(proxyName string, r *http.Request) *accesslog.Entry {
+	return &accesslog.Entry{
+		Time:       time.Now(),
+		Proxy:      proxyName,
+		Mode:       s.ProxyMode(proxyName),
+		RemoteAddr: r.RemoteAddr,
+		Method:     r.Method,
+		Path:       r.URL.RequestURI(),
+		Protocol:   r.Proto,
+	}
+}
+
+// newGRPCAccessEntry starts the access log entry of a gRPC call; the proxy and its mode are filled in once routed
+func (s *MultiProxyServer) newGRPCAccessEntry(stream grpc.ServerStream) *accesslog.Entry {
+	entry := &accesslog.Entry{
+		Time:     time.Now(),
+		Method:   "POST",
+		Protocol: "gRPC",
+	}
+	entry.Path, _ = grpc.MethodFromServerStream(stream)
+	if p, ok := peer.FromContext(stream.Context()); ok && p.Addr != nil {
+		entry.RemoteAddr = p.Addr.String()
+	}
+	return entry
+}
+
+// finishGRPCAccessEntry completes and writes the entry of a gRPC call that returned err
+func finishGRPCAccessEntry(entry *accesslog.Entry, err error) {
+	entry.Status = int(status.Code(err))
+	entry.Latency = time.Since(entry.Time)
+	accesslog.Log(entry)
+}
+
+// loggedServerStream carries the access log entry in the stream's context
+type loggedServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggedServerStream) Context() context.Context {
+	return s.ctx
+}
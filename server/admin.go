@@ -0,0 +1,278 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"mimic/fault"
+	"mimic/mock"
+	"mimic/web"
+)
+
+// SequenceState reports the sequence position of every signature tracked by the mock proxies
+func (s *MultiProxyServer) SequenceState() []web.SequenceEntry {
+	entries := []web.SequenceEntry{}
+	for name, engine := range s.mockEngines() {
+		for _, position := range engine.SequencePositions() {
+			entries = append(entries, web.SequenceEntry{
+				Proxy:     name,
+				Session:   position.Session,
+				Signature: position.Signature,
+				Method:    position.Method,
+				Path:      position.Path,
+				Position:  position.Position,
+			})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Proxy != entries[j].Proxy {
+			return entries[i].Proxy < entries[j].Proxy
+		}
+		return entries[i].Signature < entries[j].Signature
+	})
+	return entries
+}
+
+// ResetSequence rewinds mock sequences, optionally limited to one proxy, one session, and one
+// signature. A session covers the sequences of requests that named it with X-Mimic-Session too.
+func (s *MultiProxyServer) ResetSequence(proxyName, sessionName, signature string) (int, error) {
+	engines := s.mockEngines()
+	if proxyName != "" {
+		engine, ok := engines[proxyName]
+		if !ok {
+			return 0, fmt.Errorf("no mock proxy named '%s'", proxyName)
+		}
+		engines = map[string]*mock.MockEngine{proxyName: engine}
+	}
+
+	reset, serving := 0, false
+	for _, engine := range engines {
+		switch {
+		case sessionName != "":
+			reset += engine.ResetSessionSequences(sessionName, signature)
+			serving = serving || engine.SessionName() == sessionName
+		case signature == "":
+			reset += len(engine.GetSequenceState())
+			engine.ResetSequenceState()
+		case engine.ResetSequenceSignature(signature):
+			reset++
+		}
+	}
+
+	// Requests can name any recorded session, so only one that no proxy could answer from is unknown
+	if sessionName != "" && reset == 0 && !serving {
+		if _, err := s.database.GetSession(sessionName); err != nil {
+			return 0, fmt.Errorf("no mock proxy serves session '%s'", sessionName)
+		}
+	}
+
+	if signature != "" && reset == 0 {
+		return 0, fmt.Errorf("signature not tracked: %s", signature)
+	}
+	return reset, nil
+}
+
+// ScenarioStates reports the current state of every scenario of the mock proxies
+func (s *MultiProxyServer) ScenarioStates() []web.ScenarioEntry {
+	entries := []web.ScenarioEntry{}
+	for name, engine := range s.mockEngines() {
+		for scenario, state := range engine.ScenarioStates() {
+			entries = append(entries, web.ScenarioEntry{Proxy: name, Scenario: scenario, State: state})
+		}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Proxy != entries[j].Proxy {
+			return entries[i].Proxy < entries[j].Proxy
+		}
+		return entries[i].Scenario < entries[j].Scenario
+	})
+	return entries
+}
+
+// SetScenarioState moves a scenario of a mock proxy to a state
+func (s *MultiProxyServer) SetScenarioState(proxyName, scenario, state string) error {
+	engine, ok := s.mockEngines()[proxyName]
+	if !ok {
+		return fmt.Errorf("no mock proxy named '%s'", proxyName)
+	}
+	engine.SetScenarioState(scenario, state)
+	return nil
+}
+
+// ResetScenarios returns mock scenarios to their starting state, optionally limited to one proxy
+// and one scenario
+func (s *MultiProxyServer) ResetScenarios(proxyName, scenario string) (int, error) {
+	engines := s.mockEngines()
+	if proxyName != "" {
+		engine, ok := engines[proxyName]
+		if !ok {
+			return 0, fmt.Errorf("no mock proxy named '%s'", proxyName)
+		}
+		engines = map[string]*mock.MockEngine{proxyName: engine}
+	}
+
+	reset := 0
+	for _, engine := range engines {
+		reset += engine.ResetScenarios(scenario)
+	}
+	return reset, nil
+}
+
+// Mode returns the global mode the proxies are currently served in
+func (s *MultiProxyServer) Mode() string {
+	s.proxiesMux.RLock()
+	defer s.proxiesMux.RUnlock()
+	return s.mode
+}
+
+// ProxyMode returns the mode a proxy is currently served in: its own mode if it has one, else the global mode
+func (s *MultiProxyServer) ProxyMode(proxyName string) string {
+	s.proxiesMux.RLock()
+	defer s.proxiesMux.RUnlock()
+	return s.config.Proxies[proxyName].EffectiveMode(s.mode)
+}
+
+// SetMode rebuilds the handlers of the proxies following the global mode without restarting the server.
+// Proxies with their own mode keep serving in it.
+func (s *MultiProxyServer) SetMode(mode string) error {
+	if mode != "record" && mode != "mock" && mode != "replay" {
+		return fmt.Errorf("invalid mode: %s (must be 'record', 'mock', or 'replay')", mode)
+	}
+
+	s.proxiesMux.Lock()
+	defer s.proxiesMux.Unlock()
+
+	if mode == s.mode {
+		return nil
+	}
+
+	// Build all handlers first so a failure leaves the current mode untouched
+	handlers := make(map[string]ProxyHandler, len(s.proxies))
+	for name, handler := range s.proxies {
+		proxyConfig := s.config.Proxies[name]
+		if proxyConfig.Mode == "" {
+			var err error
+			if handler, err = s.newProxyHandler(mode, name, proxyConfig); err != nil {
+				return err
+			}
+		}
+		handlers[name] = handler
+	}
+
+	followers := 0
+	for _, proxyConfig := range s.grpcProxies {
+		if proxyConfig.Mode == "" {
+			followers++
+		}
+	}
+	if followers > 0 && mode != "replay" {
+		if s.grpcServer != nil {
+			if err := s.useGRPCRouter(mode); err != nil {
+				return err
+			}
+		} else {
+			log.Printf("gRPC proxies were not started in %s mode; restart mimic to serve them in %s mode", s.mode, mode)
+		}
+	}
+
+	log.Printf("Switching mode from %s to %s", s.mode, mode)
+	s.proxies = handlers
+	s.mode = mode
+	return nil
+}
+
+// ProxySession returns the session a proxy records into or mocks from
+func (s *MultiProxyServer) ProxySession(proxyName string) string {
+	s.proxiesMux.RLock()
+	defer s.proxiesMux.RUnlock()
+
+	proxyConfig := s.config.Proxies[proxyName]
+	if sessionName, ok := s.mockSessions[proxyName]; ok && proxyConfig.EffectiveMode(s.mode) == "mock" {
+		return sessionName
+	}
+	return proxyConfig.SessionName
+}
+
+// SetProxySession points a mock proxy at another recorded session with its sequences rewound.
+// The swap lasts until restart, including across mode switches back to mock.
+func (s *MultiProxyServer) SetProxySession(proxyName, sessionName string) error {
+	proxyConfig, ok := s.config.Proxies[proxyName]
+	if !ok {
+		return fmt.Errorf("no proxy named '%s'", proxyName)
+	}
+	session, err := s.database.GetSession(sessionName)
+	if err != nil {
+		return err
+	}
+
+	s.proxiesMux.Lock()
+	defer s.proxiesMux.Unlock()
+
+	if mode := proxyConfig.EffectiveMode(s.mode); mode != "mock" {
+		return fmt.Errorf("proxy '%s' is not serving mocks in %s mode", proxyName, mode)
+	}
+
+	if _, isGRPC := s.grpcProxies[proxyName]; isGRPC {
+		if s.grpcMockRouter == nil || !s.grpcMockRouter.SetSession(proxyName, session) {
+			return fmt.Errorf("gRPC proxy '%s' is not being served", proxyName)
+		}
+	} else {
+		proxyConfig.SessionName = sessionName
+		// A fresh engine starts every sequence from the beginning; in-flight requests finish on the old one
+		engine, err := mock.NewMockEngineWithBroadcaster(proxyConfig, s.config.Mock, s.database, s.webServer)
+		if err != nil {
+			return fmt.Errorf("failed to create mock engine for '%s': %w", proxyName, err)
+		}
+		redactor, err := s.newRedactor()
+		if err != nil {
+			return err
+		}
+		engine.SetRedactor(redactor)
+		engine.ResetSequenceState()
+		s.proxies[proxyName] = engine
+	}
+
+	s.mockSessions[proxyName] = sessionName
+	log.Printf("Mock proxy '%s' now serves session '%s'", proxyName, sessionName)
+	return nil
+}
+
+// ProxyFaults returns the faults currently injected into a proxy's traffic
+func (s *MultiProxyServer) ProxyFaults(proxyName string) fault.Settings {
+	return fault.Get(proxyName)
+}
+
+// SetProxyFaults changes the faults injected into a proxy's traffic from its next request on.
+// The change lasts until restart and applies in every mode.
+func (s *MultiProxyServer) SetProxyFaults(proxyName string, settings fault.Settings) error {
+	if _, ok := s.config.Proxies[proxyName]; !ok {
+		return fmt.Errorf("no proxy named '%s'", proxyName)
+	}
+	if err := fault.Set(proxyName, settings); err != nil {
+		return err
+	}
+	if settings.Active() {
+		log.Printf("Proxy '%s' now injects faults: latency %dms (+%dms jitter), error rate %g, drop rate %g",
+			proxyName, settings.LatencyMS, settings.LatencyJitterMS, settings.ErrorRate, settings.DropRate)
+	} else {
+		log.Printf("Proxy '%s' no longer injects faults", proxyName)
+	}
+	return nil
+}
+
+// mockEngines returns the HTTP proxies currently served by a mock engine
+func (s *MultiProxyServer) mockEngines() map[string]*mock.MockEngine {
+	s.proxiesMux.RLock()
+	defer s.proxiesMux.RUnlock()
+
+	engines := make(map[string]*mock.MockEngine)
+	for name, handler := range s.proxies {
+		if engine, ok := handler.(*mock.MockEngine); ok {
+			engines[name] = engine
+		}
+	}
+	return engines
+}
@@ -0,0 +1,288 @@
+package server
+
+import (
+	"context"
+	"strings"
+
+	"mimic/adminpb"
+	"mimic/fault"
+	"mimic/storage"
+	"mimic/web"
+	"mimic/webhook"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// adminReadMethods are the RPCs open to viewers; every other admin RPC needs the admin role
+var adminReadMethods = map[string]bool{
+	adminpb.AdminService_ListSessions_FullMethodName:    true,
+	adminpb.AdminService_GetMode_FullMethodName:         true,
+	adminpb.AdminService_ListSequences_FullMethodName:   true,
+	adminpb.AdminService_GetProxySession_FullMethodName: true,
+	adminpb.AdminService_GetProxyFaults_FullMethodName:  true,
+	adminpb.AdminService_GetReplayRun_FullMethodName:    true,
+	adminpb.AdminService_ListReplayRuns_FullMethodName:  true,
+}
+
+// adminService serves the admin API over gRPC, backed by the same state as the HTTP admin API
+type adminService struct {
+	adminpb.UnimplementedAdminServiceServer
+	server *MultiProxyServer
+}
+
+// newAdminGRPCServer builds the gRPC server exposing AdminService
+func (s *MultiProxyServer) newAdminGRPCServer() *grpc.Server {
+	grpcServer := grpc.NewServer(grpc.UnaryInterceptor(s.authorizeAdminRPC))
+	adminpb.RegisterAdminServiceServer(grpcServer, &adminService{server: s})
+	return grpcServer
+}
+
+// authorizeAdminRPC applies the web UI's token roles to admin RPCs
+func (s *MultiProxyServer) authorizeAdminRPC(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	required := web.RoleAdmin
+	if adminReadMethods[info.FullMethod] {
+		required = web.RoleViewer
+	}
+
+	granted := s.webServer.RoleForToken(metadataToken(ctx))
+	if granted < required {
+		if granted == web.RoleNone {
+			return nil, status.Error(codes.Unauthenticated, "authentication required")
+		}
+		return nil, status.Error(codes.PermissionDenied, "admin role required")
+	}
+	return handler(ctx, req)
+}
+
+// metadataToken reads a bearer token from the authorization metadata
+func metadataToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+	for _, value := range md.Get("authorization") {
+		if token, ok := strings.CutPrefix(value, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return ""
+}
+
+func (a *adminService) ListSessions(ctx context.Context, req *adminpb.ListSessionsRequest) (*adminpb.ListSessionsResponse, error) {
+	sessions, err := a.server.database.GetAllSessions()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to get sessions: %v", err)
+	}
+
+	resp := &adminpb.ListSessionsResponse{}
+	for i := range sessions {
+		resp.Sessions = append(resp.Sessions, sessionToProto(&sessions[i]))
+	}
+	return resp, nil
+}
+
+func (a *adminService) CreateSession(ctx context.Context, req *adminpb.CreateSessionRequest) (*adminpb.Session, error) {
+	name := strings.TrimSpace(req.GetName())
+	if name == "" {
+		return nil, status.Error(codes.InvalidArgument, "name is required")
+	}
+	if _, err := a.server.database.GetSession(name); err == nil {
+		return nil, status.Errorf(codes.AlreadyExists, "session already exists: %s", name)
+	}
+
+	session, err := a.server.database.CreateSession(name, req.GetDescription())
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to create session: %v", err)
+	}
+	return sessionToProto(session), nil
+}
+
+func (a *adminService) DeleteSession(ctx context.Context, req *adminpb.DeleteSessionRequest) (*adminpb.DeleteSessionResponse, error) {
+	if _, err := a.server.database.GetSession(req.GetName()); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err := a.server.database.ClearSession(req.GetName()); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to delete session: %v", err)
+	}
+	webhook.SessionCleared(req.GetName())
+	return &adminpb.DeleteSessionResponse{}, nil
+}
+
+func (a *adminService) ClearSessions(ctx context.Context, req *adminpb.ClearSessionsRequest) (*adminpb.ClearSessionsResponse, error) {
+	if err := a.server.database.ClearAllSessions(); err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to clear sessions: %v", err)
+	}
+	webhook.SessionCleared("")
+	return &adminpb.ClearSessionsResponse{}, nil
+}
+
+func (a *adminService) GetMode(ctx context.Context, req *adminpb.GetModeRequest) (*adminpb.ModeResponse, error) {
+	return &adminpb.ModeResponse{Mode: a.server.Mode()}, nil
+}
+
+func (a *adminService) SetMode(ctx context.Context, req *adminpb.SetModeRequest) (*adminpb.ModeResponse, error) {
+	if err := a.server.SetMode(req.GetMode()); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	a.server.webServer.BroadcastEvent("mode_changed", map[string]string{"mode": req.GetMode()})
+	return &adminpb.ModeResponse{Mode: a.server.Mode()}, nil
+}
+
+func (a *adminService) ListSequences(ctx context.Context, req *adminpb.ListSequencesRequest) (*adminpb.ListSequencesResponse, error) {
+	resp := &adminpb.ListSequencesResponse{}
+	for _, entry := range a.server.SequenceState() {
+		resp.Entries = append(resp.Entries, &adminpb.SequenceEntry{
+			Proxy:     entry.Proxy,
+			Signature: entry.Signature,
+			Method:    entry.Method,
+			Path:      entry.Path,
+			Position:  int32(entry.Position),
+			Session:   entry.Session,
+		})
+	}
+	return resp, nil
+}
+
+func (a *adminService) ResetSequences(ctx context.Context, req *adminpb.ResetSequencesRequest) (*adminpb.ResetSequencesResponse, error) {
+	reset, err := a.server.ResetSequence(req.GetProxy(), req.GetSession(), req.GetSignature())
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	a.server.webServer.BroadcastEvent("sequence_reset", map[string]interface{}{
+		"proxy":     req.GetProxy(),
+		"session":   req.GetSession(),
+		"signature": req.GetSignature(),
+		"reset":     reset,
+	})
+	return &adminpb.ResetSequencesResponse{Count: int32(reset)}, nil
+}
+
+func (a *adminService) GetProxySession(ctx context.Context, req *adminpb.GetProxySessionRequest) (*adminpb.ProxySession, error) {
+	if _, ok := a.server.config.Proxies[req.GetProxy()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "no proxy named '%s'", req.GetProxy())
+	}
+	return &adminpb.ProxySession{Proxy: req.GetProxy(), Session: a.server.ProxySession(req.GetProxy())}, nil
+}
+
+func (a *adminService) SetProxySession(ctx context.Context, req *adminpb.SetProxySessionRequest) (*adminpb.ProxySession, error) {
+	if _, ok := a.server.config.Proxies[req.GetProxy()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "no proxy named '%s'", req.GetProxy())
+	}
+	if _, err := a.server.database.GetSession(req.GetSession()); err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err := a.server.SetProxySession(req.GetProxy(), req.GetSession()); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	a.server.webServer.BroadcastEvent("proxy_session_changed", map[string]string{"proxy": req.GetProxy(), "session": req.GetSession()})
+	return &adminpb.ProxySession{Proxy: req.GetProxy(), Session: a.server.ProxySession(req.GetProxy())}, nil
+}
+
+func (a *adminService) GetProxyFaults(ctx context.Context, req *adminpb.GetProxyFaultsRequest) (*adminpb.ProxyFaults, error) {
+	if _, ok := a.server.config.Proxies[req.GetProxy()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "no proxy named '%s'", req.GetProxy())
+	}
+	return &adminpb.ProxyFaults{Proxy: req.GetProxy(), Faults: faultsToProto(a.server.ProxyFaults(req.GetProxy()))}, nil
+}
+
+func (a *adminService) SetProxyFaults(ctx context.Context, req *adminpb.SetProxyFaultsRequest) (*adminpb.ProxyFaults, error) {
+	if _, ok := a.server.config.Proxies[req.GetProxy()]; !ok {
+		return nil, status.Errorf(codes.NotFound, "no proxy named '%s'", req.GetProxy())
+	}
+	settings := faultsFromProto(req.GetFaults())
+	if err := a.server.SetProxyFaults(req.GetProxy(), settings); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	a.server.webServer.BroadcastEvent("proxy_faults_changed", map[string]interface{}{"proxy": req.GetProxy(), "faults": settings})
+	return &adminpb.ProxyFaults{Proxy: req.GetProxy(), Faults: faultsToProto(a.server.ProxyFaults(req.GetProxy()))}, nil
+}
+
+func (a *adminService) StartReplay(ctx context.Context, req *adminpb.StartReplayRequest) (*adminpb.ReplayRun, error) {
+	run, err := a.server.webServer.StartReplay(web.ReplayRunRequest{
+		SessionName:        req.GetSessionName(),
+		TargetHost:         req.GetTargetHost(),
+		TargetPort:         int(req.GetTargetPort()),
+		Protocol:           req.GetProtocol(),
+		MatchingStrategy:   req.GetMatchingStrategy(),
+		FailFast:           req.GetFailFast(),
+		TimeoutSeconds:     int(req.GetTimeoutSeconds()),
+		MaxConcurrency:     int(req.GetMaxConcurrency()),
+		IgnoreTimestamps:   req.GetIgnoreTimestamps(),
+		InsecureSkipVerify: req.GetInsecureSkipVerify(),
+		GRPCInsecure:       req.GetGrpcInsecure(),
+	})
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return replayRunToProto(run), nil
+}
+
+func (a *adminService) GetReplayRun(ctx context.Context, req *adminpb.GetReplayRunRequest) (*adminpb.ReplayRun, error) {
+	run, ok := a.server.webServer.ReplayRunByID(req.GetId())
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "replay run not found: %s", req.GetId())
+	}
+	return replayRunToProto(run), nil
+}
+
+func (a *adminService) ListReplayRuns(ctx context.Context, req *adminpb.ListReplayRunsRequest) (*adminpb.ListReplayRunsResponse, error) {
+	resp := &adminpb.ListReplayRunsResponse{}
+	for _, run := range a.server.webServer.ReplayRuns() {
+		resp.Runs = append(resp.Runs, replayRunToProto(run))
+	}
+	return resp, nil
+}
+
+func sessionToProto(session *storage.Session) *adminpb.Session {
+	return &adminpb.Session{
+		Id:          int64(session.ID),
+		Name:        session.SessionName,
+		Description: session.Description,
+		CreatedAt:   timestamppb.New(session.CreatedAt),
+	}
+}
+
+func replayRunToProto(run web.ReplayRun) *adminpb.ReplayRun {
+	pb := &adminpb.ReplayRun{
+		Id:               run.ID,
+		Status:           run.Status,
+		SessionName:      run.SessionName,
+		TargetHost:       run.TargetHost,
+		TargetPort:       int32(run.TargetPort),
+		Protocol:         run.Protocol,
+		MatchingStrategy: run.Strategy,
+		Completed:        int32(run.Completed),
+		Total:            int32(run.Total),
+		Failures:         int32(run.Failures),
+		Error:            run.Error,
+		StartedAt:        timestamppb.New(run.StartedAt),
+	}
+	if run.FinishedAt != nil {
+		pb.FinishedAt = timestamppb.New(*run.FinishedAt)
+	}
+	return pb
+}
+
+func faultsToProto(settings fault.Settings) *adminpb.Faults {
+	return &adminpb.Faults{
+		LatencyMs:       int32(settings.LatencyMS),
+		LatencyJitterMs: int32(settings.LatencyJitterMS),
+		ErrorRate:       settings.ErrorRate,
+		ErrorStatus:     int32(settings.ErrorStatus),
+		DropRate:        settings.DropRate,
+	}
+}
+
+func faultsFromProto(faults *adminpb.Faults) fault.Settings {
+	return fault.Settings{
+		LatencyMS:       int(faults.GetLatencyMs()),
+		LatencyJitterMS: int(faults.GetLatencyJitterMs()),
+		ErrorRate:       faults.GetErrorRate(),
+		ErrorStatus:     int(faults.GetErrorStatus()),
+		DropRate:        faults.GetDropRate(),
+	}
+}
@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"mimic/adminpb"
+	"mimic/config"
+	"mimic/storage"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func startAdminService(t *testing.T) adminpb.AdminServiceClient {
+	t.Helper()
+
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "admin.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := config.DefaultConfig()
+	cfg.Mode = "mock"
+	cfg.Proxies = map[string]config.ProxyConfig{
+		"api": {Name: "api", Protocol: "http", SessionName: "api"},
+	}
+	cfg.Auth.AdminTokens = []string{"admin-token"}
+	cfg.Auth.ViewerTokens = []string{"viewer-token"}
+
+	multiServer, err := NewMultiProxyServer(cfg, db)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	grpcServer := multiServer.newAdminGRPCServer()
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("Failed to dial admin API: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return adminpb.NewAdminServiceClient(conn)
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestAdminGRPCAuthorization(t *testing.T) {
+	client := startAdminService(t)
+
+	if _, err := client.GetMode(context.Background(), &adminpb.GetModeRequest{}); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("Expected Unauthenticated without a token, got %v", err)
+	}
+	if _, err := client.GetMode(withToken("viewer-token"), &adminpb.GetModeRequest{}); err != nil {
+		t.Errorf("Expected viewers to read the mode, got %v", err)
+	}
+	if _, err := client.SetMode(withToken("viewer-token"), &adminpb.SetModeRequest{Mode: "record"}); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("Expected PermissionDenied for a viewer switching modes, got %v", err)
+	}
+}
+
+func TestAdminGRPCService(t *testing.T) {
+	client := startAdminService(t)
+	ctx := withToken("admin-token")
+
+	if _, err := client.CreateSession(ctx, &adminpb.CreateSessionRequest{Name: "checkout", Description: "Checkout flow"}); err != nil {
+		t.Fatalf("CreateSession failed: %v", err)
+	}
+	if _, err := client.CreateSession(ctx, &adminpb.CreateSessionRequest{Name: "checkout"}); status.Code(err) != codes.AlreadyExists {
+		t.Errorf("Expected AlreadyExists for a duplicate session, got %v", err)
+	}
+
+	sessions, err := client.ListSessions(ctx, &adminpb.ListSessionsRequest{})
+	if err != nil {
+		t.Fatalf("ListSessions failed: %v", err)
+	}
+	found := false
+	for _, session := range sessions.Sessions {
+		if session.Name == "checkout" && session.Description == "Checkout flow" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the created session to be listed, got %v", sessions.Sessions)
+	}
+
+	if _, err := client.DeleteSession(ctx, &adminpb.DeleteSessionRequest{Name: "checkout"}); err != nil {
+		t.Errorf("DeleteSession failed: %v", err)
+	}
+	if _, err := client.DeleteSession(ctx, &adminpb.DeleteSessionRequest{Name: "checkout"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound deleting a missing session, got %v", err)
+	}
+
+	mode, err := client.SetMode(ctx, &adminpb.SetModeRequest{Mode: "record"})
+	if err != nil || mode.Mode != "record" {
+		t.Fatalf("Expected mode record, got %v (%v)", mode, err)
+	}
+	if _, err := client.SetMode(ctx, &adminpb.SetModeRequest{Mode: "bogus"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an unknown mode, got %v", err)
+	}
+
+	if _, err := client.ResetSequences(ctx, &adminpb.ResetSequencesRequest{Proxy: "api"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound resetting a proxy that is no longer mocked, got %v", err)
+	}
+
+	if _, err := client.StartReplay(ctx, &adminpb.StartReplayRequest{SessionName: "api"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for a replay without a target, got %v", err)
+	}
+	if _, err := client.GetReplayRun(ctx, &adminpb.GetReplayRunRequest{Id: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound for an unknown replay run, got %v", err)
+	}
+}
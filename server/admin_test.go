@@ -0,0 +1,247 @@
+package server
+
+import (
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/proxy"
+	"mimic/storage"
+	"mimic/web"
+)
+
+func TestSetProxySession(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "swap.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for _, name := range []string{"fixtures-a", "fixtures-b"} {
+		session, err := db.CreateSession(name, "")
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		interaction := &storage.Interaction{
+			SessionID:       session.ID,
+			RequestID:       name,
+			Protocol:        "REST",
+			Method:          "GET",
+			Endpoint:        "/users",
+			RequestHeaders:  "{}",
+			ResponseStatus:  200,
+			ResponseHeaders: "{}",
+			ResponseBody:    []byte(name),
+			Timestamp:       time.Now(),
+		}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Mode = "mock"
+	cfg.Proxies = map[string]config.ProxyConfig{
+		"api": {Name: "api", Protocol: "http", SessionName: "fixtures-a"},
+	}
+	s, err := NewMultiProxyServer(cfg, db)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	get := func() string {
+		recorder := httptest.NewRecorder()
+		s.proxyHandler("api").HandleRequest(recorder, httptest.NewRequest("GET", "/users", nil))
+		return recorder.Body.String()
+	}
+
+	if body := get(); body != "fixtures-a" {
+		t.Fatalf("Expected the configured session to be served, got %q", body)
+	}
+
+	if err := s.SetProxySession("api", "missing"); err == nil {
+		t.Error("Expected an error for a session that does not exist")
+	}
+	if err := s.SetProxySession("api", "fixtures-b"); err != nil {
+		t.Fatalf("SetProxySession failed: %v", err)
+	}
+	if body := get(); body != "fixtures-b" {
+		t.Errorf("Expected the swapped session to be served, got %q", body)
+	}
+	if len(s.SequenceState()) != 1 {
+		t.Errorf("Expected sequences to start over on the new session, got %v", s.SequenceState())
+	}
+
+	// The swap survives a round trip through another mode
+	if err := s.SetMode("record"); err != nil {
+		t.Fatalf("SetMode failed: %v", err)
+	}
+	if s.ProxySession("api") != "fixtures-a" {
+		t.Errorf("Expected record mode to use the configured session, got %q", s.ProxySession("api"))
+	}
+	if err := s.SetProxySession("api", "fixtures-a"); err == nil {
+		t.Error("Expected swapping to fail outside mock mode")
+	}
+	if err := s.SetMode("mock"); err != nil {
+		t.Fatalf("SetMode failed: %v", err)
+	}
+	if body := get(); body != "fixtures-b" {
+		t.Errorf("Expected the swap to persist across mode switches, got %q", body)
+	}
+}
+
+func TestPerProxyMode(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "modes.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	if _, err := db.CreateSession("fixtures", ""); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Mode = "record"
+	cfg.Proxies = map[string]config.ProxyConfig{
+		"live":    {Name: "live", Protocol: "http", TargetHost: "localhost", TargetPort: 1, SessionName: "capture"},
+		"fixture": {Name: "fixture", Protocol: "http", SessionName: "fixtures", Mode: "mock"},
+		"sandbox": {Name: "sandbox", Protocol: "http", TargetHost: "localhost", TargetPort: 1, SessionName: "unsaved", Mode: "passthrough"},
+	}
+	s, err := NewMultiProxyServer(cfg, db)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	if s.ProxyMode("live") != "record" || s.ProxyMode("fixture") != "mock" || s.ProxyMode("sandbox") != "passthrough" {
+		t.Fatalf("Expected live to record, fixture to mock, and sandbox to pass through, got %s, %s, and %s", s.ProxyMode("live"), s.ProxyMode("fixture"), s.ProxyMode("sandbox"))
+	}
+	if _, ok := s.proxyHandler("sandbox").(*proxy.ProxyEngine); !ok {
+		t.Errorf("Expected sandbox to be served by a proxy engine, got %T", s.proxyHandler("sandbox"))
+	}
+	pinned := s.proxyHandler("fixture")
+
+	if err := s.SetMode("replay"); err != nil {
+		t.Fatalf("SetMode failed: %v", err)
+	}
+	if s.ProxyMode("live") != "replay" {
+		t.Errorf("Expected live to follow the global mode, got %s", s.ProxyMode("live"))
+	}
+	if s.ProxyMode("fixture") != "mock" || s.proxyHandler("fixture") != pinned {
+		t.Error("Expected the pinned proxy to keep its mock engine across mode switches")
+	}
+	if err := s.SetProxySession("fixture", "fixtures"); err != nil {
+		t.Errorf("Expected the pinned mock proxy to accept a session swap in replay mode: %v", err)
+	}
+}
+
+func TestResetSequenceBySession(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "reset.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for _, name := range []string{"fixtures-a", "fixtures-b"} {
+		session, err := db.CreateSession(name, "")
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		interaction := &storage.Interaction{SessionID: session.ID, RequestID: name, Protocol: "REST", Method: "GET", Endpoint: "/users",
+			RequestHeaders: "{}", ResponseStatus: 200, ResponseHeaders: "{}", ResponseBody: []byte(name), Timestamp: time.Now()}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Mode = "mock"
+	cfg.Proxies = map[string]config.ProxyConfig{
+		"a": {Name: "a", Protocol: "http", SessionName: "fixtures-a"},
+		"b": {Name: "b", Protocol: "http", SessionName: "fixtures-b"},
+	}
+	s, err := NewMultiProxyServer(cfg, db)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	for _, name := range []string{"a", "b"} {
+		s.proxyHandler(name).HandleRequest(httptest.NewRecorder(), httptest.NewRequest("GET", "/users", nil))
+	}
+
+	if _, err := s.ResetSequence("", "missing", ""); err == nil {
+		t.Error("Expected an error for a session no proxy serves")
+	}
+	reset, err := s.ResetSequence("", "fixtures-a", "")
+	if err != nil || reset != 1 {
+		t.Fatalf("Expected one signature reset, got %d, %v", reset, err)
+	}
+	entries := s.SequenceState()
+	if len(entries) != 1 || entries[0].Proxy != "b" || entries[0].Session != "fixtures-b" {
+		t.Errorf("Expected only the other session's sequence to remain, got %v", entries)
+	}
+}
+
+func TestResetSequenceOfRequestedSession(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "requested.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	for _, name := range []string{"fixtures", "checkout-flow-v2"} {
+		session, err := db.CreateSession(name, "")
+		if err != nil {
+			t.Fatalf("Failed to create session: %v", err)
+		}
+		interaction := &storage.Interaction{SessionID: session.ID, RequestID: name, Protocol: "REST", Method: "GET", Endpoint: "/users",
+			RequestHeaders: "{}", ResponseStatus: 200, ResponseHeaders: "{}", ResponseBody: []byte(name), Timestamp: time.Now()}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+	}
+
+	cfg := config.DefaultConfig()
+	cfg.Mode = "mock"
+	cfg.Proxies = map[string]config.ProxyConfig{"api": {Name: "api", Protocol: "http", SessionName: "fixtures"}}
+	s, err := NewMultiProxyServer(cfg, db)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	for _, session := range []string{"", "checkout-flow-v2"} {
+		req := httptest.NewRequest("GET", "/users", nil)
+		if session != "" {
+			req.Header.Set("X-Mimic-Session", session)
+		}
+		s.proxyHandler("api").HandleRequest(httptest.NewRecorder(), req)
+	}
+
+	// The position of the request that named a session is listed under that session
+	entries := s.SequenceState()
+	if len(entries) != 2 {
+		t.Fatalf("Expected a sequence for each session, got %v", entries)
+	}
+	sessions := map[string]web.SequenceEntry{}
+	for _, entry := range entries {
+		sessions[entry.Session] = entry
+	}
+	requested, ok := sessions["checkout-flow-v2"]
+	if !ok || requested.Proxy != "api" || requested.Method != "GET" || requested.Path != "/users" || requested.Position != 1 {
+		t.Errorf("Expected GET /users at position 1 for session checkout-flow-v2, got %v", entries)
+	}
+
+	reset, err := s.ResetSequence("", "checkout-flow-v2", "")
+	if err != nil || reset != 1 {
+		t.Fatalf("Expected the requested session's sequence reset, got %d, %v", reset, err)
+	}
+	entries = s.SequenceState()
+	if len(entries) != 1 || entries[0].Session != "fixtures" {
+		t.Errorf("Expected only the proxy's own session's sequence to remain, got %v", entries)
+	}
+	if reset, err := s.ResetSequence("", "checkout-flow-v2", ""); err != nil || reset != 0 {
+		t.Errorf("Expected nothing left to reset for the session, got %d, %v", reset, err)
+	}
+	if _, err := s.ResetSequence("", "missing", ""); err == nil {
+		t.Error("Expected an error for a session that does not exist")
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"regexp"
+	"strings"
+
+	"mimic/config"
+)
+
+// grpcRouteTable matches gRPC calls to proxies the way the routers do, so a call can be handed to the
+// router for its proxy's mode when proxies run in different modes
+type grpcRouteTable struct {
+	routes       []grpcRoute
+	defaultRoute string
+}
+
+type grpcRoute struct {
+	name           string
+	servicePattern *regexp.Regexp
+	methodPattern  *regexp.Regexp
+}
+
+func newGRPCRouteTable(proxies map[string]config.ProxyConfig) *grpcRouteTable {
+	table := &grpcRouteTable{}
+	for name, proxyConfig := range proxies {
+		if proxyConfig.IsDefault {
+			table.defaultRoute = name
+			continue
+		}
+
+		route := grpcRoute{name: name}
+		// Invalid patterns are logged by the routers, which skip them the same way
+		if proxyConfig.ServicePattern != "" {
+			route.servicePattern, _ = regexp.Compile(proxyConfig.ServicePattern)
+		}
+		if proxyConfig.MethodPattern != "" {
+			route.methodPattern, _ = regexp.Compile(proxyConfig.MethodPattern)
+		}
+		table.routes = append(table.routes, route)
+	}
+	return table
+}
+
+// match returns the proxy serving a call such as /package.Service/Method
+func (t *grpcRouteTable) match(fullMethodName string) (string, bool) {
+	serviceName, methodName, ok := strings.Cut(strings.TrimPrefix(fullMethodName, "/"), "/")
+	if !ok {
+		return "", false
+	}
+
+	for _, route := range t.routes {
+		if route.servicePattern != nil && !route.servicePattern.MatchString(serviceName) {
+			continue
+		}
+		if route.methodPattern != nil && !route.methodPattern.MatchString(methodName) {
+			continue
+		}
+		return route.name, true
+	}
+
+	if t.defaultRoute != "" {
+		return t.defaultRoute, true
+	}
+	return "", false
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+
+	"mimic/config"
+)
+
+func TestGRPCRouteTable(t *testing.T) {
+	table := newGRPCRouteTable(map[string]config.ProxyConfig{
+		"users":    {ServicePattern: `users\..*`},
+		"admin":    {ServicePattern: `orders\..*`, MethodPattern: `^Admin`},
+		"fallback": {IsDefault: true},
+	})
+
+	cases := map[string]string{
+		"/users.UserService/Get":        "users",
+		"/orders.OrderService/AdminGet": "admin",
+		"/orders.OrderService/Get":      "fallback",
+	}
+	for method, expected := range cases {
+		if name, ok := table.match(method); !ok || name != expected {
+			t.Errorf("Expected %s to route to %s, got %q", method, expected, name)
+		}
+	}
+
+	if _, ok := newGRPCRouteTable(map[string]config.ProxyConfig{"users": {ServicePattern: `users\..*`}}).match("/orders.OrderService/Get"); ok {
+		t.Error("Expected no route without a default")
+	}
+}
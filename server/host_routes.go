@@ -0,0 +1,70 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"mimic/config"
+)
+
+// hostRouteTable matches requests to HTTP proxies by their Host header, for proxies that set a
+// host_pattern, so clients can reach a proxy at its own host name with paths left as they are
+type hostRouteTable struct {
+	routes []hostRoute
+}
+
+type hostRoute struct {
+	name    string
+	glob    string
+	pattern *regexp.Regexp
+}
+
+func newHostRouteTable(proxies map[string]config.ProxyConfig) *hostRouteTable {
+	table := &hostRouteTable{}
+	for name, proxyConfig := range proxies {
+		if proxyConfig.HostPattern == "" || proxyConfig.Protocol == "grpc" {
+			continue
+		}
+		// Patterns are checked when the config is loaded
+		pattern, err := regexp.Compile(config.HostPattern(proxyConfig.HostPattern))
+		if err != nil {
+			continue
+		}
+		table.routes = append(table.routes, hostRoute{name: name, glob: strings.ToLower(proxyConfig.HostPattern), pattern: pattern})
+	}
+
+	// Exact host names win over wildcards, and wildcards with fewer stars over those with more
+	sort.Slice(table.routes, func(i, j int) bool {
+		a, b := table.routes[i], table.routes[j]
+		if starsA, starsB := strings.Count(a.glob, "*"), strings.Count(b.glob, "*"); starsA != starsB {
+			return starsA < starsB
+		}
+		if len(a.glob) != len(b.glob) {
+			return len(a.glob) > len(b.glob)
+		}
+		return a.name < b.name
+	})
+	return table
+}
+
+// match returns the proxy serving a request's Host, ignoring its port
+func (t *hostRouteTable) match(r *http.Request) (string, bool) {
+	if len(t.routes) == 0 {
+		return "", false
+	}
+	host := r.Host
+	if name, _, err := net.SplitHostPort(host); err == nil {
+		host = name
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	for _, route := range t.routes {
+		if route.pattern.MatchString(host) {
+			return route.name, true
+		}
+	}
+	return "", false
+}
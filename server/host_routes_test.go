@@ -0,0 +1,80 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"mimic/config"
+	"mimic/storage"
+)
+
+func TestHostRouteTable(t *testing.T) {
+	table := newHostRouteTable(map[string]config.ProxyConfig{
+		"exact":    {HostPattern: "api.example.test"},
+		"label":    {HostPattern: "*.example.test"},
+		"anywhere": {HostPattern: "**.test"},
+		"grpc":     {HostPattern: "grpc.example.test", Protocol: "grpc"},
+		"unrouted": {},
+	})
+
+	cases := map[string]string{
+		"api.example.test":      "exact",
+		"API.example.test:8080": "exact",
+		"web.example.test":      "label",
+		"a.b.example.test":      "anywhere",
+		"grpc.example.test":     "label",
+	}
+	for host, expected := range cases {
+		if name, ok := table.match(&http.Request{Host: host}); !ok || name != expected {
+			t.Errorf("Expected %s to route to %s, got %q", host, expected, name)
+		}
+	}
+	if name, ok := table.match(&http.Request{Host: "localhost:8080"}); ok {
+		t.Errorf("Expected no route for localhost, got %s", name)
+	}
+}
+
+func TestRouteByHost(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upstream " + r.URL.Path))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "hosts.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Mode = "passthrough"
+	cfg.Proxies = map[string]config.ProxyConfig{
+		"api": {Name: "api", Protocol: "http", SessionName: "default", TargetHost: target.Hostname(), TargetPort: port, HostPattern: "api.example.test"},
+	}
+	s, err := NewMultiProxyServer(cfg, db)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	handler := s.routeByHost(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ui " + r.URL.Path))
+	}))
+
+	for host, expected := range map[string]string{
+		"api.example.test:8080": "upstream /v1/users",
+		"localhost:8080":        "ui /v1/users",
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/v1/users", nil)
+		r.Host = host
+		handler.ServeHTTP(w, r)
+		if w.Body.String() != expected {
+			t.Errorf("Expected %s to be answered with %q, got %d %q", host, expected, w.Code, w.Body.String())
+		}
+	}
+}
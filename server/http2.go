@@ -0,0 +1,19 @@
+package server
+
+import (
+	"log"
+	"net/http"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// acceptCleartextHTTP2 lets the listener speak HTTP/2 without TLS under server.h2c, both to
+// clients that start with the HTTP/2 preface and to those that upgrade from HTTP/1.1
+func (s *MultiProxyServer) acceptCleartextHTTP2(next http.Handler) http.Handler {
+	if !s.config.Server.H2C {
+		return next
+	}
+	log.Printf("Accepting cleartext HTTP/2 (h2c) on the HTTP listener")
+	return h2c.NewHandler(next, &http2.Server{})
+}
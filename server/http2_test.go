@@ -0,0 +1,54 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"mimic/config"
+
+	"golang.org/x/net/http2"
+)
+
+func TestAcceptCleartextHTTP2(t *testing.T) {
+	echoProto := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Proto))
+	})
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, address string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, address)
+		},
+	}}
+
+	for _, enabled := range []bool{true, false} {
+		s := &MultiProxyServer{config: &config.Config{Server: config.ServerConfig{H2C: enabled}}}
+		listener := httptest.NewServer(s.acceptCleartextHTTP2(echoProto))
+
+		resp, err := h2cClient.Get(listener.URL)
+		if enabled {
+			if err != nil {
+				t.Fatalf("Expected an h2c request to succeed, got %v", err)
+			}
+			if resp.ProtoMajor != 2 {
+				t.Errorf("Expected HTTP/2, got %s", resp.Proto)
+			}
+			resp.Body.Close()
+		} else if err == nil {
+			resp.Body.Close()
+			t.Errorf("Expected h2c to be refused without server.h2c, got %s", resp.Proto)
+		}
+
+		// HTTP/1.1 clients are served either way
+		resp, err = http.Get(listener.URL)
+		if err != nil || resp.ProtoMajor != 1 {
+			t.Errorf("Expected HTTP/1.1 to keep working, got %v, %v", resp, err)
+		} else {
+			resp.Body.Close()
+		}
+		listener.Close()
+	}
+}
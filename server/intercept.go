@@ -0,0 +1,179 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"mimic/intercept"
+)
+
+// serveIntercepted runs a proxy handler, pausing at the manager's matching request and response
+// breakpoints
+func serveIntercepted(manager *intercept.Manager, proxyName string, handler ProxyHandler, w http.ResponseWriter, r *http.Request) {
+	if breakpointID := manager.Match(proxyName, intercept.StageRequest, r.Method, r.URL.Path); breakpointID != "" {
+		if !pauseRequest(manager, breakpointID, proxyName, w, r) {
+			return
+		}
+	}
+
+	breakpointID := manager.Match(proxyName, intercept.StageResponse, r.Method, r.URL.Path)
+	if breakpointID == "" {
+		handler.HandleRequest(w, r)
+		return
+	}
+
+	// Buffer the whole response so it can be edited; streaming responses arrive all at once
+	buffer := &responseBuffer{header: make(http.Header), status: http.StatusOK}
+	handler.HandleRequest(buffer, r)
+
+	decision := manager.Pause(r.Context(), intercept.Exchange{
+		BreakpointID: breakpointID,
+		Proxy:        proxyName,
+		Stage:        intercept.StageResponse,
+		Method:       r.Method,
+		Endpoint:     r.URL.Path,
+		Query:        r.URL.RawQuery,
+		Headers:      flattenHeader(buffer.header),
+		Body:         buffer.body.String(),
+		Status:       buffer.status,
+	})
+	if decision.Action == intercept.ActionAbort {
+		writeAborted(w, decision)
+		return
+	}
+
+	header := buffer.header
+	if decision.Headers != nil {
+		header = expandHeader(decision.Headers, buffer.header)
+	}
+	body := buffer.body.Bytes()
+	if decision.Body != nil {
+		body = []byte(*decision.Body)
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	status := buffer.status
+	if decision.Status != 0 {
+		status = decision.Status
+	}
+
+	for key, values := range header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// pauseRequest holds a request for the user and applies their edits; it returns false if the request was aborted
+func pauseRequest(manager *intercept.Manager, breakpointID, proxyName string, w http.ResponseWriter, r *http.Request) bool {
+	var body []byte
+	if r.Body != nil {
+		body, _ = io.ReadAll(r.Body)
+		r.Body.Close()
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	decision := manager.Pause(r.Context(), intercept.Exchange{
+		BreakpointID: breakpointID,
+		Proxy:        proxyName,
+		Stage:        intercept.StageRequest,
+		Method:       r.Method,
+		Endpoint:     r.URL.Path,
+		Query:        r.URL.RawQuery,
+		Headers:      flattenHeader(r.Header),
+		Body:         string(body),
+	})
+	if decision.Action == intercept.ActionAbort {
+		writeAborted(w, decision)
+		return false
+	}
+
+	if decision.Method != "" {
+		r.Method = strings.ToUpper(decision.Method)
+	}
+	if decision.Endpoint != "" {
+		r.URL.Path = decision.Endpoint
+	}
+	if decision.Query != nil {
+		r.URL.RawQuery = *decision.Query
+	}
+	if decision.Headers != nil {
+		r.Header = expandHeader(decision.Headers, r.Header)
+	}
+	if decision.Body != nil {
+		r.Body = io.NopCloser(strings.NewReader(*decision.Body))
+		r.ContentLength = int64(len(*decision.Body))
+		r.Header.Set("Content-Length", strconv.FormatInt(r.ContentLength, 10))
+	}
+	return true
+}
+
+// writeAborted answers the client directly when the user aborts an exchange
+func writeAborted(w http.ResponseWriter, decision intercept.Decision) {
+	status := decision.Status
+	if status == 0 {
+		status = http.StatusServiceUnavailable
+	}
+	for key, value := range decision.Headers {
+		w.Header().Set(key, value)
+	}
+	if decision.Body == nil {
+		http.Error(w, "Request aborted by mimic intercept", status)
+		return
+	}
+	w.WriteHeader(status)
+	io.WriteString(w, *decision.Body)
+}
+
+// flattenHeader shows each header as one value, its values joined the way HTTP allows
+func flattenHeader(header http.Header) map[string]string {
+	flat := make(map[string]string, len(header))
+	for key, values := range header {
+		flat[key] = strings.Join(values, ", ")
+	}
+	return flat
+}
+
+// expandHeader turns edited headers back into an http.Header. A header left as flattenHeader
+// showed it keeps its original values, since some, such as several Set-Cookie, cannot be joined.
+func expandHeader(flat map[string]string, original http.Header) http.Header {
+	header := make(http.Header, len(flat))
+	for key, value := range flat {
+		key = http.CanonicalHeaderKey(key)
+		if values, ok := original[key]; ok && strings.Join(values, ", ") == value {
+			header[key] = append([]string(nil), values...)
+			continue
+		}
+		header.Set(key, value)
+	}
+	return header
+}
+
+// responseBuffer captures a handler's response so it can be paused and edited before sending
+type responseBuffer struct {
+	header      http.Header
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *responseBuffer) Write(data []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(data)
+}
+
+// Flush is a no-op so streaming handlers can run against the buffer
+func (b *responseBuffer) Flush() {}
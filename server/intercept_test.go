@@ -0,0 +1,145 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mimic/intercept"
+)
+
+// handlerFunc adapts a function to a ProxyHandler
+type handlerFunc func(w http.ResponseWriter, r *http.Request)
+
+func (f handlerFunc) HandleRequest(w http.ResponseWriter, r *http.Request) { f(w, r) }
+
+// serveWithDecision serves a request through a breakpoint on the given stage, resolving the
+// exchange it pauses with the decision
+func serveWithDecision(t *testing.T, stage string, handler ProxyHandler, r *http.Request, decision intercept.Decision) *httptest.ResponseRecorder {
+	t.Helper()
+	manager := intercept.NewManager(time.Minute)
+	if _, err := manager.AddBreakpoint(intercept.Breakpoint{Proxy: "api", Stage: stage}); err != nil {
+		t.Fatalf("Failed to add breakpoint: %v", err)
+	}
+
+	recorder := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		serveIntercepted(manager, "api", handler, recorder, r)
+	}()
+
+	deadline := time.Now().Add(time.Second)
+	for len(manager.Paused()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the exchange to pause")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := manager.Resolve(manager.Paused()[0].ID, decision); err != nil {
+		t.Fatalf("Failed to resolve exchange: %v", err)
+	}
+	<-done
+	return recorder
+}
+
+func TestInterceptAppliesRequestEdits(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	handler := handlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+	})
+
+	query, body := "dry_run=true", `{"amount":5}`
+	req := httptest.NewRequest("POST", "/charges?dry_run=false", strings.NewReader(`{"amount":500}`))
+	serveWithDecision(t, intercept.StageRequest, handler, req, intercept.Decision{
+		Method:   "put",
+		Endpoint: "/charges/7",
+		Query:    &query,
+		Body:     &body,
+	})
+
+	if got == nil {
+		t.Fatal("Expected the edited request to reach the handler")
+	}
+	if got.Method != "PUT" || got.URL.Path != "/charges/7" || got.URL.RawQuery != query {
+		t.Errorf("Expected PUT /charges/7?%s, got %s %s?%s", query, got.Method, got.URL.Path, got.URL.RawQuery)
+	}
+	if gotBody != body {
+		t.Errorf("Expected the edited body, got %s", gotBody)
+	}
+	if got.ContentLength != int64(len(body)) || got.Header.Get("Content-Length") != "12" {
+		t.Errorf("Expected a Content-Length of 12, got %d (%q)", got.ContentLength, got.Header.Get("Content-Length"))
+	}
+}
+
+func TestInterceptAppliesResponseEdits(t *testing.T) {
+	handler := handlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "session=abc; Path=/")
+		w.Header().Add("Set-Cookie", "theme=dark; Path=/")
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"status":"paid"}`))
+	})
+
+	body := `{"status":"declined"}`
+	recorder := serveWithDecision(t, intercept.StageResponse, handler, httptest.NewRequest("GET", "/charges/7", nil), intercept.Decision{
+		Status: http.StatusPaymentRequired,
+		Body:   &body,
+	})
+
+	if recorder.Code != http.StatusPaymentRequired || recorder.Body.String() != body {
+		t.Errorf("Expected 402 %s, got %d %s", body, recorder.Code, recorder.Body.String())
+	}
+	if recorder.Header().Get("Content-Length") != "21" {
+		t.Errorf("Expected the Content-Length of the edited body, got %q", recorder.Header().Get("Content-Length"))
+	}
+	if cookies := recorder.Header().Values("Set-Cookie"); len(cookies) != 2 {
+		t.Errorf("Expected both cookies kept apart, got %q", cookies)
+	}
+}
+
+func TestInterceptKeepsUneditedMultiValueHeaders(t *testing.T) {
+	handler := handlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Set-Cookie", "session=abc; Path=/")
+		w.Header().Add("Set-Cookie", "theme=dark; Path=/")
+		w.Header().Set("X-Trace", "1")
+	})
+
+	// The user sends the headers back as shown, changing only one of them
+	recorder := serveWithDecision(t, intercept.StageResponse, handler, httptest.NewRequest("GET", "/", nil), intercept.Decision{
+		Headers: map[string]string{"Set-Cookie": "session=abc; Path=/, theme=dark; Path=/", "X-Trace": "2"},
+	})
+
+	if cookies := recorder.Header().Values("Set-Cookie"); len(cookies) != 2 || cookies[0] != "session=abc; Path=/" || cookies[1] != "theme=dark; Path=/" {
+		t.Errorf("Expected both cookies kept apart, got %q", cookies)
+	}
+	if recorder.Header().Get("X-Trace") != "2" {
+		t.Errorf("Expected the edited header, got %q", recorder.Header().Get("X-Trace"))
+	}
+}
+
+func TestInterceptAbort(t *testing.T) {
+	for _, stage := range []string{intercept.StageRequest, intercept.StageResponse} {
+		t.Run(stage, func(t *testing.T) {
+			reached := false
+			handler := handlerFunc(func(w http.ResponseWriter, r *http.Request) { reached = true })
+
+			recorder := serveWithDecision(t, stage, handler, httptest.NewRequest("GET", "/", nil), intercept.Decision{
+				Action: intercept.ActionAbort,
+				Status: http.StatusTeapot,
+			})
+			if recorder.Code != http.StatusTeapot {
+				t.Errorf("Expected the abort status, got %d", recorder.Code)
+			}
+			if stage == intercept.StageRequest && reached {
+				t.Error("Expected an aborted request not to reach the handler")
+			}
+		})
+	}
+}
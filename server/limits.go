@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"mimic/config"
+	"mimic/fault"
+	"mimic/intercept"
+	"mimic/proxy"
+	"mimic/ratelimit"
+)
+
+// serveProxy applies the proxy's admission limits, injected faults, and body limits before handing the request to its handler
+func (s *MultiProxyServer) serveProxy(proxyName string, w http.ResponseWriter, r *http.Request) {
+	release, err := ratelimit.Acquire(proxyName)
+	if err != nil {
+		writeLimitError(w, err)
+		return
+	}
+	defer release()
+
+	outcome, err := fault.Inject(r.Context(), proxyName)
+	if err != nil {
+		return // The client gave up during injected latency
+	}
+	switch outcome.Kind {
+	case fault.KindError:
+		http.Error(w, fmt.Sprintf("Fault injected by proxy '%s'", proxyName), outcome.Status)
+		return
+	case fault.KindDrop:
+		dropConnection(w)
+		return
+	}
+
+	handler := s.proxyHandler(proxyName)
+	if streamsRequestBody(proxyName, handler, r) {
+		if !s.streamRequestBody(w, r) {
+			return
+		}
+	} else {
+		body := s.spoolRequestBody(w, r)
+		if body == nil {
+			return
+		}
+		defer body.Remove()
+	}
+
+	serveIntercepted(intercept.Default, proxyName, handler, w, r)
+}
+
+// streamsRequestBody reports whether the request body can go straight to the upstream. Recording
+// and passthrough engines read it only once, unless a breakpoint holds the request for editing or
+// the engine retries failed attempts; mock engines may read it for every candidate interaction.
+func streamsRequestBody(proxyName string, handler ProxyHandler, r *http.Request) bool {
+	engine, ok := handler.(*proxy.ProxyEngine)
+	if !ok || engine.Retries() || r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return false
+	}
+	return intercept.Default.Match(proxyName, intercept.StageRequest, r.Method, r.URL.Path) == ""
+}
+
+// dropConnection closes the client connection without answering; where the connection cannot be
+// taken over (HTTP/2), the stream is reset instead
+func dropConnection(w http.ResponseWriter) {
+	if recorder, ok := w.(*statusRecorder); ok {
+		recorder.status = 0 // Logged as no status at all
+	}
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	conn.Close()
+}
+
+// writeLimitError answers 429 for rate limits and 503 for concurrency limits, with a Retry-After hint
+func writeLimitError(w http.ResponseWriter, err error) {
+	var limitErr *ratelimit.LimitError
+	if !errors.As(err, &limitErr) {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	status := http.StatusServiceUnavailable
+	if limitErr.Reason == ratelimit.ReasonRate {
+		status = http.StatusTooManyRequests
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(limitErr.RetryAfterSeconds()))
+	http.Error(w, limitErr.Error(), status)
+}
+
+// streamRequestBody enforces the request size limit on a body that is read as it is forwarded;
+// it returns false after replying with an error
+func (s *MultiProxyServer) streamRequestBody(w http.ResponseWriter, r *http.Request) bool {
+	maxBytes := s.config.Limits.MaxRequestBodyBytes
+	if maxBytes > 0 && r.ContentLength > maxBytes {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return false
+	}
+
+	reader := r.Body
+	if maxBytes > 0 {
+		reader = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+	r.Body = proxy.StreamBody(reader)
+	return true
+}
+
+// spoolRequestBody enforces the request size limit and buffers the body so handlers can read it
+// more than once. It returns nil after replying with an error; callers must Remove the body when done.
+func (s *MultiProxyServer) spoolRequestBody(w http.ResponseWriter, r *http.Request) *proxy.SpooledBody {
+	limits := s.config.Limits
+	maxBytes := limits.MaxRequestBodyBytes
+	if maxBytes > 0 && r.ContentLength > maxBytes {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return nil
+	}
+
+	reader := r.Body
+	if maxBytes > 0 {
+		reader = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+
+	memoryBytes := limits.MemoryBufferBytes
+	if memoryBytes <= 0 {
+		memoryBytes = config.DefaultMemoryBufferBytes
+	}
+
+	body, err := proxy.SpoolBody(reader, memoryBytes, limits.SpillDir)
+	if err != nil {
+		if proxy.IsBodyTooLarge(err) {
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		} else {
+			log.Printf("Error reading request body for %s %s: %v", r.Method, r.URL.Path, err)
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+		}
+		return nil
+	}
+
+	r.Body = body
+	r.ContentLength = body.Size()
+	return body
+}
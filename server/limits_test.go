@@ -0,0 +1,121 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"mimic/config"
+	"mimic/fault"
+	"mimic/proxy"
+	"mimic/storage"
+)
+
+func TestSpoolRequestBodyLimit(t *testing.T) {
+	s := &MultiProxyServer{config: &config.Config{Limits: config.LimitsConfig{
+		MaxRequestBodyBytes: 8,
+		MemoryBufferBytes:   4,
+		SpillDir:            t.TempDir(),
+	}}}
+
+	// Declared too large: rejected before reading
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+	if body := s.spoolRequestBody(recorder, req); body != nil || recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a large Content-Length, got %d", recorder.Code)
+	}
+
+	// Chunked and too large: rejected while reading
+	recorder = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+	req.ContentLength = -1
+	if body := s.spoolRequestBody(recorder, req); body != nil || recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a large chunked body, got %d", recorder.Code)
+	}
+
+	// Within the limit but past the memory buffer: spilled
+	recorder = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/upload", strings.NewReader("012345"))
+	body := s.spoolRequestBody(recorder, req)
+	if body == nil {
+		t.Fatalf("Expected a body within the limit to be accepted, got %d", recorder.Code)
+	}
+	defer body.Remove()
+	if !body.Spilled() || req.ContentLength != 6 {
+		t.Errorf("Expected a spilled 6 byte body, got spilled=%v length=%d", body.Spilled(), req.ContentLength)
+	}
+}
+
+func TestStreamRequestBodyLimit(t *testing.T) {
+	s := &MultiProxyServer{config: &config.Config{Limits: config.LimitsConfig{MaxRequestBodyBytes: 8}}}
+
+	// Declared too large: rejected before reading
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+	if s.streamRequestBody(recorder, req) || recorder.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected 413 for a large Content-Length, got %d", recorder.Code)
+	}
+
+	// Chunked: passed on as it is read, failing once it outgrows the limit
+	recorder = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/upload", strings.NewReader("0123456789"))
+	req.ContentLength = -1
+	if !s.streamRequestBody(recorder, req) {
+		t.Fatalf("Expected a chunked body to be streamed, got %d", recorder.Code)
+	}
+	if _, ok := req.Body.(*proxy.StreamedBody); !ok {
+		t.Fatalf("Expected a streamed body, got %T", req.Body)
+	}
+	if _, err := io.ReadAll(req.Body); !proxy.IsBodyTooLarge(err) {
+		t.Errorf("Expected a body-too-large error, got %v", err)
+	}
+}
+
+func TestServeProxyFaults(t *testing.T) {
+	db, err := storage.NewDatabase(filepath.Join(t.TempDir(), "faults.db"))
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	cfg := config.DefaultConfig()
+	cfg.Mode = "mock"
+	cfg.Proxies = map[string]config.ProxyConfig{
+		"api": {Name: "api", Protocol: "http", SessionName: "default"},
+	}
+	s, err := NewMultiProxyServer(cfg, db)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	t.Cleanup(func() { s.SetProxyFaults("api", fault.Settings{}) })
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.serveProxy("api", newStatusRecorder(w), r)
+	}))
+	defer upstream.Close()
+
+	if err := s.SetProxyFaults("api", fault.Settings{ErrorRate: 1, ErrorStatus: http.StatusBadGateway}); err != nil {
+		t.Fatalf("SetProxyFaults failed: %v", err)
+	}
+	resp, err := http.Get(upstream.URL + "/users")
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("Expected the injected 502, got %d", resp.StatusCode)
+	}
+
+	s.SetProxyFaults("api", fault.Settings{DropRate: 1})
+	if resp, err := http.Get(upstream.URL + "/users"); err == nil {
+		resp.Body.Close()
+		t.Errorf("Expected the connection to be dropped, got %d", resp.StatusCode)
+	}
+
+	if err := s.SetProxyFaults("missing", fault.Settings{}); err == nil {
+		t.Error("Expected an error for an unknown proxy")
+	}
+}
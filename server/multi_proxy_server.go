@@ -1,29 +1,55 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"strings"
+	"sync"
+	"time"
 
+	"mimic/accesslog"
+	"mimic/calllog"
 	"mimic/config"
+	"mimic/conformance"
+	"mimic/fault"
+	"mimic/intercept"
+	"mimic/metrics"
 	"mimic/mock"
+	"mimic/protoschema"
 	"mimic/proxy"
+	"mimic/ratelimit"
+	"mimic/redact"
 	"mimic/storage"
+	"mimic/stub"
 	"mimic/web"
+	"mimic/webhook"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
 )
 
 type MultiProxyServer struct {
 	config         *config.Config
 	database       *storage.Database
 	webServer      *web.Server
+	mode           string // Current global mode, may be switched at runtime; proxies with their own mode ignore it
 	proxies        map[string]ProxyHandler
-	grpcServer     *grpc.Server         // Single gRPC server with routing
-	grpcRouter     *proxy.GRPCRouter    // For gRPC record proxies
-	grpcMockRouter *mock.GRPCMockRouter // For gRPC mock proxies
+	proxiesMux     sync.RWMutex                  // Guards mode, proxies, and the gRPC routers
+	grpcProxies    map[string]config.ProxyConfig // gRPC proxies served by the routers
+	grpcRoutes     *grpcRouteTable               // Picks the proxy, and so the router, serving each gRPC call
+	grpcServer     *grpc.Server                  // Single gRPC server with routing
+	grpcRouter     *proxy.GRPCRouter             // For gRPC record proxies
+	grpcMockRouter *mock.GRPCMockRouter          // For gRPC mock proxies
+	grpcHandlers   map[string]grpc.StreamHandler // Handler of each router created so far, by mode
+	adminServer    *grpc.Server                  // Admin gRPC API, when admin_grpc_port is set
+	mockSessions   map[string]string             // Sessions swapped in at runtime for mock proxies, by proxy name
+	tlsConfig      *tls.Config                   // Served on the HTTP and gRPC listeners, when server.tls is set
 }
 
 type ProxyHandler interface {
@@ -34,19 +60,60 @@ func NewMultiProxyServer(cfg *config.Config, db *storage.Database) (*MultiProxyS
 	webServer := web.NewServer(cfg, db)
 
 	server := &MultiProxyServer{
-		config:    cfg,
-		database:  db,
-		webServer: webServer,
-		proxies:   make(map[string]ProxyHandler),
+		config:       cfg,
+		database:     db,
+		webServer:    webServer,
+		mode:         cfg.Mode,
+		proxies:      make(map[string]ProxyHandler),
+		grpcProxies:  make(map[string]config.ProxyConfig),
+		grpcHandlers: make(map[string]grpc.StreamHandler),
+		mockSessions: make(map[string]string),
+	}
+	webServer.SetAdminController(server)
+	intercept.Default.SetNotifier(webServer.BroadcastEvent)
+	webhook.Configure(cfg.Webhooks)
+	ratelimit.Configure(cfg.Proxies)
+	fault.Configure(cfg.Proxies)
+	loadDescriptors(cfg.GRPC)
+	conformance.Default.SetNotifier(webServer.BroadcastEvent)
+	for _, err := range conformance.Configure(cfg.Proxies) {
+		log.Printf("Warning: OpenAPI conformance checks are off for %v", err)
+	}
+	if err := accesslog.Configure(cfg.AccessLog); err != nil {
+		return nil, err
+	}
+	tlsConfig, err := loadTLSConfig(cfg.Server.TLS)
+	if err != nil {
+		return nil, err
+	}
+	server.tlsConfig = tlsConfig
+	if cfg.Intercept.TimeoutSeconds > 0 {
+		intercept.Default.SetTimeout(time.Duration(cfg.Intercept.TimeoutSeconds) * time.Second)
+	}
+
+	// Stubs are in their sessions before any proxy serves from them
+	for _, path := range cfg.Mock.StubFiles {
+		file, err := stub.Load(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load stub file %s: %w", path, err)
+		}
+		saved, err := stub.Save(db, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to save stubs from %s: %w", path, err)
+		}
+		log.Printf("Loaded %d stubs from %s", saved, path)
+	}
+
+	if cfg.Mock.Journal == "database" {
+		calllog.Default.UseDatabase(db)
 	}
 
 	// Separate HTTP and gRPC proxies
 	httpProxies := make(map[string]config.ProxyConfig)
-	grpcProxies := make(map[string]config.ProxyConfig)
 
 	for name, proxyConfig := range cfg.Proxies {
 		if proxyConfig.Protocol == "grpc" {
-			grpcProxies[name] = proxyConfig
+			server.grpcProxies[name] = proxyConfig
 		} else {
 			// HTTP/HTTPS proxies - handle individually
 			httpProxies[name] = proxyConfig
@@ -55,74 +122,39 @@ func NewMultiProxyServer(cfg *config.Config, db *storage.Database) (*MultiProxyS
 
 	// Initialize HTTP proxies (existing logic)
 	for name, proxyConfig := range httpProxies {
-		var handler ProxyHandler
-
-		switch cfg.Mode {
-		case "record":
-			if proxyEngine, err := proxy.NewProxyEngineWithBroadcaster(proxyConfig, db, webServer); err == nil {
-				handler = proxyEngine
-			} else {
-				return nil, fmt.Errorf("failed to create proxy engine for '%s': %w", name, err)
-			}
-		case "mock":
-			if mockEngine, err := mock.NewMockEngineWithBroadcaster(proxyConfig, cfg.Mock, db, webServer); err == nil {
-				handler = mockEngine
-			} else {
-				return nil, fmt.Errorf("failed to create mock engine for '%s': %w", name, err)
-			}
-		case "replay":
-			// For replay mode, we create a special handler that provides replay endpoints
-			if replayHandler, err := NewReplayHandler(&cfg.Replay, db, webServer); err == nil {
-				handler = replayHandler
-			} else {
-				return nil, fmt.Errorf("failed to create replay handler for '%s': %w", name, err)
-			}
-		default:
-			return nil, fmt.Errorf("invalid global mode: %s", cfg.Mode)
+		mode := proxyConfig.EffectiveMode(cfg.Mode)
+		handler, err := server.newProxyHandler(mode, name, proxyConfig)
+		if err != nil {
+			return nil, err
 		}
 
 		server.proxies[name] = handler
-		log.Printf("Initialized HTTP proxy '%s' in %s mode", name, cfg.Mode)
+		log.Printf("Initialized HTTP proxy '%s' in %s mode", name, mode)
 	}
 
-	// Initialize single gRPC server with routing (if any gRPC proxies exist and not in replay mode)
-	if len(grpcProxies) > 0 && cfg.Mode != "replay" {
-		var unknownServiceHandler grpc.StreamHandler
-
-		// Create gRPC router for record mode
-		if cfg.Mode == "record" {
-			router, err := proxy.NewGRPCRouter(grpcProxies, cfg.Mode, db, webServer)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create gRPC router: %w", err)
-			}
-			server.grpcRouter = router
-			unknownServiceHandler = router.GetUnknownServiceHandler()
-
-		}
-
-		// Create gRPC mock router for mock mode
-		if cfg.Mode == "mock" {
-			mockRouter, err := mock.NewGRPCMockRouter(grpcProxies, db, webServer)
-			if err != nil {
-				return nil, fmt.Errorf("failed to create gRPC mock router: %w", err)
-			}
-			server.grpcMockRouter = mockRouter
-			// If we don't have a record router, use the mock router as the handler
-			if unknownServiceHandler == nil {
-				unknownServiceHandler = mockRouter.GetUnknownServiceHandler()
+	// Initialize single gRPC server with routing (if any gRPC proxy is served outside replay mode)
+	grpcModes := server.grpcModes(cfg.Mode)
+	if len(grpcModes) > 0 {
+		server.grpcRoutes = newGRPCRouteTable(server.grpcProxies)
+		for _, mode := range grpcModes {
+			if err := server.useGRPCRouter(mode); err != nil {
+				return nil, err
 			}
 		}
-		log.Printf("Initialized gRPC router with %d %s routes", len(grpcProxies), cfg.Mode)
 
 		// Create single gRPC server with routing
-		server.grpcServer = grpc.NewServer(
+		options := append(proxy.GRPCServerKeepaliveOptions(cfg.GRPC.Keepalive),
 			grpc.MaxRecvMsgSize(64*1024*1024),        // 64MB max receive message size
 			grpc.MaxSendMsgSize(64*1024*1024),        // 64MB max send message size
 			grpc.MaxHeaderListSize(64*1024*1024),     // 64MB max header list size
 			grpc.InitialWindowSize(64*1024*1024),     // 64MB initial window
 			grpc.InitialConnWindowSize(64*1024*1024), // 64MB connection window
-			grpc.UnknownServiceHandler(unknownServiceHandler),
+			grpc.UnknownServiceHandler(server.handleGRPCStream),
 		)
+		if tlsConfig != nil {
+			options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig)))
+		}
+		server.grpcServer = grpc.NewServer(options...)
 
 		log.Printf("Created single gRPC server with routing")
 	}
@@ -130,6 +162,175 @@ func NewMultiProxyServer(cfg *config.Config, db *storage.Database) (*MultiProxyS
 	return server, nil
 }
 
+// newProxyHandler builds the HTTP handler serving one proxy in the given mode
+func (s *MultiProxyServer) newProxyHandler(mode, name string, proxyConfig config.ProxyConfig) (ProxyHandler, error) {
+	switch mode {
+	case "record":
+		// With rotation every session is named with the time it started, the first one included
+		rotation := proxy.NewSessionRotation(s.config.Recording.Rotate, proxyConfig.SessionName)
+		if rotation != nil {
+			proxyConfig.SessionName = rotation.NextName()
+		}
+		proxyEngine, err := proxy.NewProxyEngineWithBroadcaster(proxyConfig, s.database, s.webServer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create proxy engine for '%s': %w", name, err)
+		}
+		proxyEngine.SetSessionRotation(rotation)
+		filter, err := proxy.NewRecordFilter(s.config.Recording)
+		if err != nil {
+			return nil, err
+		}
+		proxyEngine.SetRecordFilter(filter)
+		proxyEngine.LimitBodies(s.config.Recording.MaxRequestBodyBytes, s.config.Recording.MaxResponseBodyBytes)
+		proxyEngine.SetDeduper(proxy.NewDeduper(s.config.Recording.Dedupe))
+		redactor, err := s.newRedactor()
+		if err != nil {
+			return nil, err
+		}
+		proxyEngine.SetRedactor(redactor)
+		return proxyEngine, nil
+	case "passthrough":
+		passthroughEngine, err := proxy.NewPassthroughEngine(proxyConfig, s.database, s.webServer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create passthrough engine for '%s': %w", name, err)
+		}
+		return passthroughEngine, nil
+	case "mock":
+		if sessionName, ok := s.mockSessions[name]; ok {
+			proxyConfig.SessionName = sessionName
+		}
+		mockEngine, err := mock.NewMockEngineWithBroadcaster(proxyConfig, s.config.Mock, s.database, s.webServer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create mock engine for '%s': %w", name, err)
+		}
+		redactor, err := s.newRedactor()
+		if err != nil {
+			return nil, err
+		}
+		mockEngine.SetRedactor(redactor)
+		return mockEngine, nil
+	case "replay":
+		// For replay mode, we create a special handler that provides replay endpoints
+		replayHandler, err := NewReplayHandler(&s.config.Replay, s.database, s.webServer)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create replay handler for '%s': %w", name, err)
+		}
+		return replayHandler, nil
+	default:
+		return nil, fmt.Errorf("invalid global mode: %s", mode)
+	}
+}
+
+// newRedactor builds the redactor recordings are masked with, from recording.redact_patterns and
+// recording.redact_presets
+func (s *MultiProxyServer) newRedactor() (*redact.Redactor, error) {
+	redactor, err := redact.New(s.config.Recording.RedactPatterns, s.config.Recording.RedactPresets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure redaction: %w", err)
+	}
+	return redactor, nil
+}
+
+// useGRPCRouter creates the gRPC router for a mode on first use. The router holds every gRPC proxy
+// that can run in the mode: those pinned to it and those following the global mode.
+// Callers other than the constructor must hold proxiesMux.
+func (s *MultiProxyServer) useGRPCRouter(mode string) error {
+	if _, ok := s.grpcHandlers[mode]; ok {
+		return nil
+	}
+
+	routeConfigs := make(map[string]config.ProxyConfig)
+	for name, proxyConfig := range s.grpcProxies {
+		if proxyConfig.Mode == "" || proxyConfig.Mode == mode {
+			routeConfigs[name] = proxyConfig
+		}
+	}
+
+	switch mode {
+	case "record":
+		router, err := proxy.NewGRPCRouter(routeConfigs, mode, s.database, s.webServer)
+		if err != nil {
+			return fmt.Errorf("failed to create gRPC router: %w", err)
+		}
+		filter, err := proxy.NewRecordFilter(s.config.Recording)
+		if err != nil {
+			return err
+		}
+		router.SetRecordFilter(filter)
+		redactor, err := s.newRedactor()
+		if err != nil {
+			return err
+		}
+		router.SetRedactor(redactor)
+		s.grpcRouter = router
+		s.grpcHandlers[mode] = router.GetUnknownServiceHandler()
+	case "passthrough":
+		// Routers forward without recording in any mode but record
+		router, err := proxy.NewGRPCRouter(routeConfigs, mode, s.database, s.webServer)
+		if err != nil {
+			return fmt.Errorf("failed to create gRPC passthrough router: %w", err)
+		}
+		s.grpcHandlers[mode] = router.GetUnknownServiceHandler()
+	case "mock":
+		mockRouter, err := mock.NewGRPCMockRouter(routeConfigs, s.config.Mock, s.database, s.webServer)
+		if err != nil {
+			return fmt.Errorf("failed to create gRPC mock router: %w", err)
+		}
+		s.grpcMockRouter = mockRouter
+		s.grpcHandlers[mode] = mockRouter.GetUnknownServiceHandler()
+	default:
+		return nil
+	}
+	log.Printf("Initialized gRPC router with %d %s routes", len(routeConfigs), mode)
+	return nil
+}
+
+// grpcModes returns the modes the gRPC proxies run in under a global mode, leaving out replay,
+// which does not serve gRPC
+func (s *MultiProxyServer) grpcModes(globalMode string) []string {
+	var modes []string
+	for _, mode := range []string{"record", "mock", "passthrough"} {
+		for _, proxyConfig := range s.grpcProxies {
+			if proxyConfig.EffectiveMode(globalMode) == mode {
+				modes = append(modes, mode)
+				break
+			}
+		}
+	}
+	return modes
+}
+
+// handleGRPCStream dispatches every gRPC call to the router for its proxy's mode
+func (s *MultiProxyServer) handleGRPCStream(srv interface{}, stream grpc.ServerStream) (err error) {
+	entry := s.newGRPCAccessEntry(stream)
+	stream = &loggedServerStream{ServerStream: stream, ctx: accesslog.NewContext(stream.Context(), entry)}
+	defer func() { finishGRPCAccessEntry(entry, err) }()
+
+	fullMethodName, _ := grpc.MethodFromServerStream(stream)
+	proxyName, ok := s.grpcRoutes.match(fullMethodName)
+	if !ok {
+		return status.Errorf(codes.Unimplemented, "no route found for %s", fullMethodName)
+	}
+
+	mode := s.ProxyMode(proxyName)
+	entry.Mode = mode
+	s.proxiesMux.RLock()
+	handler := s.grpcHandlers[mode]
+	s.proxiesMux.RUnlock()
+
+	if handler == nil {
+		return status.Errorf(codes.Unavailable, "gRPC proxy '%s' is not served in %s mode", proxyName, mode)
+	}
+	return handler(srv, stream)
+}
+
+// proxyHandler returns the handler currently serving an HTTP proxy
+func (s *MultiProxyServer) proxyHandler(name string) ProxyHandler {
+	s.proxiesMux.RLock()
+	defer s.proxiesMux.RUnlock()
+	return s.proxies[name]
+}
+
 func (s *MultiProxyServer) Start() error {
 	// Start single gRPC server with routing if any gRPC proxies exist
 	var grpcAddress string
@@ -186,30 +387,42 @@ func (s *MultiProxyServer) Start() error {
 		}()
 	}
 
+	if s.config.Server.AdminGRPCPort > 0 {
+		adminAddress := fmt.Sprintf("%s:%d", s.config.Server.ListenHost, s.config.Server.AdminGRPCPort)
+		lis, err := net.Listen("tcp", adminAddress)
+		if err != nil {
+			return fmt.Errorf("failed to listen for the admin gRPC API on %s: %w", adminAddress, err)
+		}
+
+		s.adminServer = s.newAdminGRPCServer()
+		go func() {
+			if err := s.adminServer.Serve(lis); err != nil {
+				log.Printf("Admin gRPC server failed: %v", err)
+			}
+		}()
+		log.Printf("Admin gRPC API listening on %s", adminAddress)
+	}
+
 	// Set up HTTP server for web UI and HTTP proxies
 	mux := http.NewServeMux()
 
 	// Register HTTP proxy routes FIRST (before web UI catch-all routes)
 	httpProxyCount := 0
 
-	for name, proxyHandler := range s.proxies {
-		// Capture the name and handler in the closure
+	for name := range s.proxies {
+		// Capture the name in the closure; the handler is looked up per request so mode switches apply immediately
 		proxyName := name
-		handler := proxyHandler
 
 		proxyPath := fmt.Sprintf("/proxy/%s/", proxyName)
 
 		// Regular HTTP proxy
 		mux.HandleFunc(proxyPath, func(w http.ResponseWriter, r *http.Request) {
-			// Strip the proxy path prefix and forward to the proxy handler
-			originalPath := r.URL.Path
-			r.URL.Path = strings.TrimPrefix(originalPath, fmt.Sprintf("/proxy/%s", proxyName))
-			if r.URL.Path == "" {
-				r.URL.Path = "/"
-			}
-			handler.HandleRequest(w, r)
+			s.serveHTTPProxy(proxyName, fmt.Sprintf("/proxy/%s", proxyName), w, r)
 		})
 		log.Printf("Registered HTTP proxy '%s' at path %s", proxyName, proxyPath)
+		if pattern := s.config.Proxies[proxyName].HostPattern; pattern != "" {
+			log.Printf("Registered HTTP proxy '%s' for host %s", proxyName, pattern)
+		}
 		httpProxyCount++
 	}
 
@@ -231,12 +444,16 @@ func (s *MultiProxyServer) Start() error {
 	}
 
 	httpAddress := fmt.Sprintf("%s:%d", s.config.Server.ListenHost, s.config.Server.ListenPort)
+	scheme := "http"
+	if s.tlsConfig != nil {
+		scheme = "https"
+	}
 
 	log.Printf("Starting multi-proxy server")
-	log.Printf("Web UI available at http://%s/", httpAddress)
+	log.Printf("Web UI available at %s://%s/", scheme, httpAddress)
 
 	if httpProxyCount > 0 {
-		log.Printf("HTTP proxies (%d) available at http://%s/proxy/<name>/", httpProxyCount, httpAddress)
+		log.Printf("HTTP proxies (%d) available at %s://%s/proxy/<name>/", httpProxyCount, scheme, httpAddress)
 	}
 
 	if s.grpcServer != nil {
@@ -244,13 +461,84 @@ func (s *MultiProxyServer) Start() error {
 		log.Printf("gRPC info available at http://%s/grpc/info", httpAddress)
 	}
 
-	return http.ListenAndServe(httpAddress, mux)
+	httpServer := &http.Server{Addr: httpAddress, Handler: s.acceptCleartextHTTP2(s.routeByHost(mux)), TLSConfig: s.tlsConfig}
+	if s.tlsConfig != nil {
+		return httpServer.ListenAndServeTLS("", "")
+	}
+	return httpServer.ListenAndServe()
+}
+
+// routeByHost hands requests whose Host matches a proxy's host_pattern to that proxy with their
+// path untouched, and the rest to next
+func (s *MultiProxyServer) routeByHost(next http.Handler) http.Handler {
+	s.proxiesMux.RLock()
+	proxies := make(map[string]config.ProxyConfig, len(s.proxies))
+	for name := range s.proxies {
+		proxies[name] = s.config.Proxies[name]
+	}
+	s.proxiesMux.RUnlock()
+
+	hosts := newHostRouteTable(proxies)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if proxyName, ok := hosts.match(r); ok {
+			s.serveHTTPProxy(proxyName, "", w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// serveHTTPProxy serves a request to an HTTP proxy with the path prefix it was reached under
+// stripped, counting and logging it
+func (s *MultiProxyServer) serveHTTPProxy(proxyName, prefix string, w http.ResponseWriter, r *http.Request) {
+	entry := s.newHTTPAccessEntry(proxyName, r)
+	r = r.WithContext(accesslog.NewContext(r.Context(), entry))
+
+	if prefix != "" {
+		r = r.WithContext(proxy.WithRoutePrefix(r.Context(), prefix))
+		r.URL.Path = strings.TrimPrefix(r.URL.Path, prefix)
+		if r.URL.Path == "" {
+			r.URL.Path = "/"
+		}
+	}
+
+	recorder := newStatusRecorder(w)
+	s.serveProxy(proxyName, recorder, r)
+	metrics.RecordRequest(proxyName, recorder.status)
+
+	entry.Status, entry.Bytes, entry.Latency = recorder.status, recorder.bytes, time.Since(entry.Time)
+	accesslog.Log(entry)
+}
+
+// FlushSequenceState writes the sequence positions mock proxies persist on an interval, so a
+// restart resumes from them
+func (s *MultiProxyServer) FlushSequenceState() {
+	for _, engine := range s.mockEngines() {
+		engine.FlushSequenceState()
+	}
 }
 
 // Stop gracefully stops the server
 func (s *MultiProxyServer) Stop() error {
+	s.FlushSequenceState()
 	if s.grpcServer != nil {
 		s.grpcServer.GracefulStop()
 	}
+	if s.adminServer != nil {
+		s.adminServer.GracefulStop()
+	}
 	return nil
 }
+
+// loadDescriptors replaces the protobuf descriptors used to decode gRPC messages with those
+// configured; sources that fail to load are skipped with a warning so the proxy still starts
+func loadDescriptors(cfg config.GRPCConfig) {
+	protoschema.Default.Reset()
+	if len(cfg.DescriptorSets) == 0 && len(cfg.BufModules) == 0 {
+		return
+	}
+	for _, err := range protoschema.Load(context.Background(), cfg.DescriptorSets, cfg.BufModules) {
+		log.Printf("Warning: %v", err)
+	}
+	log.Printf("Loaded protobuf descriptors for %d services", len(protoschema.Default.Services()))
+}
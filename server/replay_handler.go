@@ -11,6 +11,7 @@ import (
 	"mimic/replay"
 	"mimic/storage"
 	"mimic/web"
+	"mimic/webhook"
 )
 
 // ReplayHandler handles HTTP requests for replay functionality
@@ -35,6 +36,9 @@ func (h *ReplayHandler) HandleRequest(w http.ResponseWriter, r *http.Request) {
 	case "GET":
 		h.handleStatus(w, r)
 	case "POST":
+		if h.webServer != nil && !h.webServer.RequireRole(w, r, web.RoleAdmin) {
+			return
+		}
 		h.handleReplay(w, r)
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -113,19 +117,33 @@ func (h *ReplayHandler) handleReplay(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Starting replay of session '%s' against %s://%s:%d",
-		replayConfig.SessionName, replayConfig.Protocol, replayConfig.TargetHost, replayConfig.TargetPort)
-
-	// Execute the replay
-	replaySession, err := engine.Replay()
+	// With a web UI the replay is tracked as a run, so its progress events carry a run ID like those
+	// of runs started from the UI, and the run announces its own completion
+	var replaySession *replay.ReplaySession
+	if h.webServer != nil {
+		replaySession, err = h.webServer.RunReplay(engine, &replayConfig, "proxy")
+	} else {
+		log.Printf("Starting replay of session '%s' against %s://%s:%d",
+			replayConfig.SessionName, replayConfig.Protocol, replayConfig.TargetHost, replayConfig.TargetPort)
+		if replaySession, err = engine.Replay(); replaySession != nil {
+			webhook.ReplayFinished(webhook.ReplayFinishedEvent{
+				Source:     "proxy",
+				Session:    replaySession.SessionName,
+				Target:     fmt.Sprintf("%s://%s:%d", replayConfig.Protocol, replayConfig.TargetHost, replayConfig.TargetPort),
+				Total:      replaySession.TotalRequests,
+				Successes:  replaySession.SuccessCount,
+				Failures:   replaySession.FailureCount,
+				DurationMs: replaySession.Duration.Milliseconds(),
+			})
+		}
+	}
 	if err != nil {
 		// Even if there were failures, we want to return the results
 		log.Printf("Replay completed with errors: %v", err)
 	}
-
-	// Broadcast replay results to web clients if available
-	if h.webServer != nil {
-		h.webServer.BroadcastEvent("replay_completed", replaySession)
+	if replaySession == nil {
+		http.Error(w, fmt.Sprintf("Replay failed: %v", err), http.StatusInternalServerError)
+		return
 	}
 
 	// Return the replay results
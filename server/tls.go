@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+
+	"mimic/config"
+)
+
+// Names a self-signed certificate covers when server.tls.hosts lists none
+var defaultCertificateHosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// How long a self-signed certificate is valid
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// loadTLSConfig builds the TLS config the listeners serve with, or returns nil when TLS is off.
+// A self-signed certificate is generated unless its files already hold one from an earlier run.
+func loadTLSConfig(cfg config.TLSConfig) (*tls.Config, error) {
+	if !cfg.Enabled() {
+		return nil, nil
+	}
+
+	var err error
+	if cfg.CertFile, err = config.ExpandHome(cfg.CertFile); err != nil {
+		return nil, err
+	}
+	if cfg.KeyFile, err = config.ExpandHome(cfg.KeyFile); err != nil {
+		return nil, err
+	}
+
+	var certificate tls.Certificate
+	if cfg.SelfSigned && !fileExists(cfg.CertFile) {
+		certificate, err = selfSignedCertificate(cfg)
+	} else {
+		certificate, err = tls.LoadX509KeyPair(cfg.CertFile, cfg.KeyFile)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	return &tls.Config{Certificates: []tls.Certificate{certificate}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// selfSignedCertificate generates a certificate for the configured hosts, writing it to the
+// configured files when there are any
+func selfSignedCertificate(cfg config.TLSConfig) (tls.Certificate, error) {
+	hosts := cfg.Hosts
+	if len(hosts) == 0 {
+		hosts = defaultCertificateHosts
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	// The certificate is its own CA, so clients can trust it directly
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: hosts[0], Organization: []string{"mimic"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return tls.Certificate{}, fmt.Errorf("failed to encode key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+
+	if cfg.CertFile != "" {
+		if err := writePEM(cfg.CertFile, certPEM, 0644); err != nil {
+			return tls.Certificate{}, err
+		}
+		if err := writePEM(cfg.KeyFile, keyPEM, 0600); err != nil {
+			return tls.Certificate{}, err
+		}
+		log.Printf("Wrote self-signed certificate for %v to %s", hosts, cfg.CertFile)
+	} else {
+		log.Printf("Generated self-signed certificate for %v", hosts)
+	}
+	return tls.X509KeyPair(certPEM, keyPEM)
+}
+
+func writePEM(path string, data []byte, perm os.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create directory for %s: %w", path, err)
+	}
+	if err := os.WriteFile(path, data, perm); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"mimic/config"
+)
+
+func TestLoadTLSConfigSelfSigned(t *testing.T) {
+	if tlsConfig, err := loadTLSConfig(config.TLSConfig{}); tlsConfig != nil || err != nil {
+		t.Fatalf("Expected no TLS without server.tls, got %v, %v", tlsConfig, err)
+	}
+
+	dir := t.TempDir()
+	cfg := config.TLSConfig{SelfSigned: true, CertFile: filepath.Join(dir, "tls", "cert.pem"), KeyFile: filepath.Join(dir, "tls", "key.pem")}
+	first, err := loadTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("Failed to generate certificate: %v", err)
+	}
+	second, err := loadTLSConfig(cfg)
+	if err != nil {
+		t.Fatalf("Failed to load generated certificate: %v", err)
+	}
+	if !bytes.Equal(first.Certificates[0].Certificate[0], second.Certificates[0].Certificate[0]) {
+		t.Error("Expected the certificate written on the first run to be reused")
+	}
+
+	// Clients that trust the written certificate can reach the listener at localhost
+	certPEM, err := os.ReadFile(cfg.CertFile)
+	if err != nil {
+		t.Fatalf("Failed to read certificate: %v", err)
+	}
+	roots := x509.NewCertPool()
+	roots.AppendCertsFromPEM(certPEM)
+	listener := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("secure"))
+	}))
+	listener.TLS = second
+	listener.StartTLS()
+	defer listener.Close()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, ServerName: "localhost"}}}
+	resp, err := client.Get(listener.URL)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected 200, got %d", resp.StatusCode)
+	}
+}
@@ -0,0 +1,279 @@
+// Package sessiondiff compares two recorded sessions: which endpoints each covers, which
+// interactions were added or removed, and which responses changed.
+package sessiondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"mimic/storage"
+)
+
+// Endpoint states
+const (
+	StateAdded     = "added"     // Only recorded in the head session
+	StateRemoved   = "removed"   // Only recorded in the base session
+	StateChanged   = "changed"   // Recorded in both, with different interactions or responses
+	StateUnchanged = "unchanged" // Recorded in both with the same responses
+)
+
+// maxDifferences caps the body differences listed per change
+const maxDifferences = 20
+
+// Result is the comparison of a base session with a head session
+type Result struct {
+	Base      string         `json:"base"`
+	Head      string         `json:"head"`
+	Endpoints []EndpointDiff `json:"endpoints"`
+	Added     []Entry        `json:"added"`   // Interactions only in head
+	Removed   []Entry        `json:"removed"` // Interactions only in base
+	Changed   []Change       `json:"changed"`
+	Unchanged int            `json:"unchanged"`
+}
+
+// Identical reports whether the sessions recorded the same responses
+func (r *Result) Identical() bool {
+	return len(r.Added) == 0 && len(r.Removed) == 0 && len(r.Changed) == 0
+}
+
+// EndpointDiff summarizes one method and endpoint across both sessions
+type EndpointDiff struct {
+	Method    string `json:"method"`
+	Endpoint  string `json:"endpoint"`
+	BaseCount int    `json:"base_count"`
+	HeadCount int    `json:"head_count"`
+	State     string `json:"state"`
+}
+
+// Entry is an interaction recorded in only one of the sessions
+type Entry struct {
+	ID         int    `json:"id"`
+	Method     string `json:"method"`
+	Endpoint   string `json:"endpoint"`
+	Occurrence int    `json:"occurrence"` // 1 for the first call to the endpoint in the session, 2 for the second, ...
+	Status     int    `json:"status"`
+}
+
+// Change is an interaction recorded in both sessions with a different response
+type Change struct {
+	Method      string   `json:"method"`
+	Endpoint    string   `json:"endpoint"`
+	Occurrence  int      `json:"occurrence"`
+	BaseID      int      `json:"base_id"`
+	HeadID      int      `json:"head_id"`
+	BaseStatus  int      `json:"base_status"`
+	HeadStatus  int      `json:"head_status"`
+	Differences []string `json:"differences"` // Human-readable, e.g. "$.total: 10 → 12"
+}
+
+type endpointKey struct {
+	method, endpoint string
+}
+
+// Compare pairs the interactions of two sessions by method, endpoint, and the order they were
+// recorded in, and reports what differs. Streaming responses are compared by their body, so
+// callers should fill it in from the stream chunks first.
+func Compare(baseName string, base []storage.Interaction, headName string, head []storage.Interaction) *Result {
+	result := &Result{
+		Base:      baseName,
+		Head:      headName,
+		Endpoints: []EndpointDiff{},
+		Added:     []Entry{},
+		Removed:   []Entry{},
+		Changed:   []Change{},
+	}
+
+	baseGroups, headGroups := group(base), group(head)
+	keys := make([]endpointKey, 0, len(baseGroups)+len(headGroups))
+	for key := range baseGroups {
+		keys = append(keys, key)
+	}
+	for key := range headGroups {
+		if _, ok := baseGroups[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].endpoint != keys[j].endpoint {
+			return keys[i].endpoint < keys[j].endpoint
+		}
+		return keys[i].method < keys[j].method
+	})
+
+	for _, key := range keys {
+		baseCalls, headCalls := baseGroups[key], headGroups[key]
+		endpoint := EndpointDiff{Method: key.method, Endpoint: key.endpoint, BaseCount: len(baseCalls), HeadCount: len(headCalls), State: StateUnchanged}
+
+		for i := 0; i < len(baseCalls) || i < len(headCalls); i++ {
+			switch {
+			case i >= len(headCalls):
+				result.Removed = append(result.Removed, newEntry(baseCalls[i], i+1))
+			case i >= len(baseCalls):
+				result.Added = append(result.Added, newEntry(headCalls[i], i+1))
+			default:
+				if differences := compareResponses(baseCalls[i], headCalls[i]); len(differences) > 0 {
+					result.Changed = append(result.Changed, Change{
+						Method:      key.method,
+						Endpoint:    key.endpoint,
+						Occurrence:  i + 1,
+						BaseID:      baseCalls[i].ID,
+						HeadID:      headCalls[i].ID,
+						BaseStatus:  baseCalls[i].ResponseStatus,
+						HeadStatus:  headCalls[i].ResponseStatus,
+						Differences: differences,
+					})
+					endpoint.State = StateChanged
+				} else {
+					result.Unchanged++
+				}
+			}
+		}
+
+		switch {
+		case len(baseCalls) == 0:
+			endpoint.State = StateAdded
+		case len(headCalls) == 0:
+			endpoint.State = StateRemoved
+		case len(baseCalls) != len(headCalls):
+			endpoint.State = StateChanged
+		}
+		result.Endpoints = append(result.Endpoints, endpoint)
+	}
+	return result
+}
+
+// group buckets interactions by method and endpoint, each bucket in recorded order
+func group(interactions []storage.Interaction) map[endpointKey][]*storage.Interaction {
+	groups := make(map[endpointKey][]*storage.Interaction)
+	for i := range interactions {
+		interaction := &interactions[i]
+		key := endpointKey{interaction.Method, interaction.Endpoint}
+		groups[key] = append(groups[key], interaction)
+	}
+	for _, calls := range groups {
+		sort.SliceStable(calls, func(i, j int) bool {
+			if calls[i].SequenceNumber != calls[j].SequenceNumber {
+				return calls[i].SequenceNumber < calls[j].SequenceNumber
+			}
+			return calls[i].Timestamp.Before(calls[j].Timestamp)
+		})
+	}
+	return groups
+}
+
+func newEntry(interaction *storage.Interaction, occurrence int) Entry {
+	return Entry{
+		ID:         interaction.ID,
+		Method:     interaction.Method,
+		Endpoint:   interaction.Endpoint,
+		Occurrence: occurrence,
+		Status:     interaction.ResponseStatus,
+	}
+}
+
+// compareResponses lists how head's response differs from base's: status, content type, and body.
+// Other headers, such as Date, change between recordings and are ignored.
+func compareResponses(base, head *storage.Interaction) []string {
+	var differences []string
+	if base.ResponseStatus != head.ResponseStatus {
+		differences = append(differences, fmt.Sprintf("status: %d → %d", base.ResponseStatus, head.ResponseStatus))
+	}
+	if baseType, headType := contentType(base.ResponseHeaders), contentType(head.ResponseHeaders); baseType != headType {
+		differences = append(differences, fmt.Sprintf("content-type: %q → %q", baseType, headType))
+	}
+	if bytes.Equal(base.ResponseBody, head.ResponseBody) {
+		return differences
+	}
+
+	var baseValue, headValue interface{}
+	if json.Unmarshal(base.ResponseBody, &baseValue) == nil && json.Unmarshal(head.ResponseBody, &headValue) == nil {
+		bodyDifferences := diffJSON("$", baseValue, headValue, nil)
+		if len(bodyDifferences) > maxDifferences {
+			more := len(bodyDifferences) - maxDifferences
+			bodyDifferences = append(bodyDifferences[:maxDifferences], fmt.Sprintf("... and %d more", more))
+		}
+		return append(differences, bodyDifferences...)
+	}
+	return append(differences, fmt.Sprintf("body: %d bytes → %d bytes", len(base.ResponseBody), len(head.ResponseBody)))
+}
+
+// diffJSON appends the paths at which two decoded JSON values differ
+func diffJSON(path string, base, head interface{}, differences []string) []string {
+	switch baseValue := base.(type) {
+	case map[string]interface{}:
+		headValue, ok := head.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(baseValue)+len(headValue))
+		for key := range baseValue {
+			keys = append(keys, key)
+		}
+		for key := range headValue {
+			if _, ok := baseValue[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := path + "." + key
+			baseChild, inBase := baseValue[key]
+			headChild, inHead := headValue[key]
+			switch {
+			case !inHead:
+				differences = append(differences, fmt.Sprintf("%s: removed (was %s)", childPath, formatValue(baseChild)))
+			case !inBase:
+				differences = append(differences, fmt.Sprintf("%s: added %s", childPath, formatValue(headChild)))
+			default:
+				differences = diffJSON(childPath, baseChild, headChild, differences)
+			}
+		}
+		return differences
+	case []interface{}:
+		headValue, ok := head.([]interface{})
+		if !ok {
+			break
+		}
+		for i := 0; i < len(baseValue) || i < len(headValue); i++ {
+			childPath := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(headValue):
+				differences = append(differences, fmt.Sprintf("%s: removed (was %s)", childPath, formatValue(baseValue[i])))
+			case i >= len(baseValue):
+				differences = append(differences, fmt.Sprintf("%s: added %s", childPath, formatValue(headValue[i])))
+			default:
+				differences = diffJSON(childPath, baseValue[i], headValue[i], differences)
+			}
+		}
+		return differences
+	}
+
+	if !reflect.DeepEqual(base, head) {
+		differences = append(differences, fmt.Sprintf("%s: %s → %s", path, formatValue(base), formatValue(head)))
+	}
+	return differences
+}
+
+// formatValue renders a JSON value compactly, shortening long ones
+func formatValue(value interface{}) string {
+	encoded, _ := json.Marshal(value)
+	if len(encoded) > 60 {
+		return string(encoded[:57]) + "..."
+	}
+	return string(encoded)
+}
+
+func contentType(headersJSON string) string {
+	var headers map[string]interface{}
+	json.Unmarshal([]byte(headersJSON), &headers)
+	for name, value := range headers {
+		if strings.EqualFold(name, "Content-Type") {
+			return fmt.Sprint(value)
+		}
+	}
+	return ""
+}
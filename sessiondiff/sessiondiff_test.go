@@ -0,0 +1,79 @@
+package sessiondiff
+
+import (
+	"reflect"
+	"testing"
+
+	"mimic/storage"
+)
+
+func interaction(id int, method, endpoint string, sequence, status int, body string) storage.Interaction {
+	return storage.Interaction{
+		ID:              id,
+		Method:          method,
+		Endpoint:        endpoint,
+		SequenceNumber:  sequence,
+		ResponseStatus:  status,
+		ResponseHeaders: `{"Content-Type":"application/json","Date":"Mon, 01 Jan 2026 00:00:00 GMT"}`,
+		ResponseBody:    []byte(body),
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := []storage.Interaction{
+		interaction(1, "GET", "/cart", 1, 200, `{"items":[{"sku":"a","qty":1}],"total":10}`),
+		interaction(2, "GET", "/cart", 2, 200, `{"items":[],"total":0}`),
+		interaction(3, "GET", "/health", 1, 200, `ok`),
+		interaction(4, "DELETE", "/cart", 1, 204, ``),
+	}
+	head := []storage.Interaction{
+		interaction(11, "GET", "/cart", 1, 200, `{"items":[{"sku":"a","qty":2}],"total":20,"currency":"EUR"}`),
+		interaction(12, "GET", "/cart", 2, 200, `{"items":[],"total":0}`),
+		interaction(13, "GET", "/health", 1, 503, `down`),
+		interaction(14, "POST", "/orders", 1, 201, `{}`),
+	}
+	head[1].ResponseHeaders = `{"Content-Type":"application/json","Date":"Tue, 02 Jan 2026 00:00:00 GMT"}`
+
+	result := Compare("v1", base, "v2", head)
+
+	if result.Unchanged != 1 {
+		t.Errorf("Expected the second cart call to be unchanged despite its Date header, got %d unchanged", result.Unchanged)
+	}
+	if len(result.Added) != 1 || result.Added[0].ID != 14 {
+		t.Errorf("Expected POST /orders to be added, got %+v", result.Added)
+	}
+	if len(result.Removed) != 1 || result.Removed[0].ID != 4 {
+		t.Errorf("Expected DELETE /cart to be removed, got %+v", result.Removed)
+	}
+	if len(result.Changed) != 2 {
+		t.Fatalf("Expected 2 changes, got %+v", result.Changed)
+	}
+
+	cart := result.Changed[0]
+	expected := []string{`$.currency: added "EUR"`, `$.items[0].qty: 1 → 2`, `$.total: 10 → 20`}
+	if cart.BaseID != 1 || cart.HeadID != 11 || !reflect.DeepEqual(cart.Differences, expected) {
+		t.Errorf("Expected the first cart call's field changes, got %+v", cart)
+	}
+	health := result.Changed[1]
+	if health.Differences[0] != "status: 200 → 503" || health.Differences[1] != "body: 2 bytes → 4 bytes" {
+		t.Errorf("Expected the health status and body to change, got %v", health.Differences)
+	}
+
+	states := map[string]string{}
+	for _, endpoint := range result.Endpoints {
+		states[endpoint.Method+" "+endpoint.Endpoint] = endpoint.State
+	}
+	expectedStates := map[string]string{
+		"GET /cart":    StateChanged,
+		"DELETE /cart": StateRemoved,
+		"GET /health":  StateChanged,
+		"POST /orders": StateAdded,
+	}
+	if !reflect.DeepEqual(states, expectedStates) {
+		t.Errorf("Expected endpoint states %v, got %v", expectedStates, states)
+	}
+
+	if !Compare("v1", base, "v1", base).Identical() {
+		t.Error("Expected a session to be identical to itself")
+	}
+}
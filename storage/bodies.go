@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"mimic/config"
+)
+
+// MaxInlineBodyBytes caps recorded bodies when they can only be kept in the database, since they
+// are held in memory until recorded
+const MaxInlineBodyBytes = 32 * 1024 * 1024 // 32MB
+
+// bodyFileGrace keeps unreferenced body files this young, since a body is written before the
+// interaction that refers to it is recorded
+const bodyFileGrace = time.Hour
+
+var bodyFileName = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// BodyFiles keeps recorded bodies too large for the database as files, named by the SHA-256 of
+// their content so a body recorded many times is stored once
+type BodyFiles struct {
+	dir       string
+	threshold int64
+}
+
+// Dir is the directory body files are kept in
+func (b *BodyFiles) Dir() string {
+	return b.dir
+}
+
+// Threshold is the body size in bytes beyond which bodies go to files
+func (b *BodyFiles) Threshold() int64 {
+	return b.threshold
+}
+
+// Create starts a new body file; Commit it once the body is written, or Abort it
+func (b *BodyFiles) Create() (*BodyFileWriter, error) {
+	if err := os.MkdirAll(b.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create body directory: %w", err)
+	}
+	file, err := os.CreateTemp(b.dir, ".incoming-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create body file: %w", err)
+	}
+	return &BodyFileWriter{dir: b.dir, file: file, hash: sha256.New()}, nil
+}
+
+// Open opens the body file with the given name
+func (b *BodyFiles) Open(name string) (*os.File, error) {
+	if !bodyFileName.MatchString(name) {
+		return nil, fmt.Errorf("invalid body file name: %q", name)
+	}
+	return os.Open(filepath.Join(b.dir, name))
+}
+
+// BodyFileWriter writes one body file, hashing it as it goes
+type BodyFileWriter struct {
+	dir  string
+	file *os.File
+	hash hash.Hash
+	size int64
+}
+
+func (w *BodyFileWriter) Write(p []byte) (int, error) {
+	n, err := w.file.Write(p)
+	w.hash.Write(p[:n])
+	w.size += int64(n)
+	return n, err
+}
+
+// Size is the number of bytes written so far
+func (w *BodyFileWriter) Size() int64 {
+	return w.size
+}
+
+// Commit finishes the file and moves it into place, returning its name
+func (w *BodyFileWriter) Commit() (string, error) {
+	if err := w.file.Close(); err != nil {
+		os.Remove(w.file.Name())
+		return "", fmt.Errorf("failed to write body file: %w", err)
+	}
+	name := hex.EncodeToString(w.hash.Sum(nil))
+	path := filepath.Join(w.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		// The same body was stored before; refresh it so pruning leaves it alone
+		os.Remove(w.file.Name())
+		now := time.Now()
+		os.Chtimes(path, now, now)
+		return name, nil
+	}
+	if err := os.Rename(w.file.Name(), path); err != nil {
+		os.Remove(w.file.Name())
+		return "", fmt.Errorf("failed to store body file: %w", err)
+	}
+	return name, nil
+}
+
+// Abort discards the file
+func (w *BodyFileWriter) Abort() {
+	w.file.Close()
+	os.Remove(w.file.Name())
+}
+
+// configureBodyFiles applies the database config's body settings. SQLite databases keep body
+// files next to the database file by default; others only when body_dir is set.
+func (d *Database) configureBodyFiles(cfg config.DatabaseConfig) {
+	d.maxBodyBytes = cfg.MaxBodyBytes
+	if d.maxBodyBytes == 0 {
+		d.maxBodyBytes = config.DefaultMaxBodyBytes
+	}
+	threshold := cfg.BodyFileThreshold
+	if threshold == 0 {
+		threshold = config.DefaultBodyFileThreshold
+	}
+	if threshold < 0 {
+		return
+	}
+
+	dir := cfg.BodyDir
+	if dir == "" && d.path != "" {
+		dir = filepath.Join(filepath.Dir(d.path), "bodies")
+	}
+	if dir == "" {
+		return
+	}
+	if strings.HasPrefix(dir, "~") {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(homeDir, dir[1:])
+		}
+	}
+	d.bodyFiles = &BodyFiles{dir: dir, threshold: threshold}
+}
+
+// BodyFiles returns where large bodies are written, nil when every body is kept in the database
+func (d *Database) BodyFiles() *BodyFiles {
+	return d.bodyFiles
+}
+
+// MaxRecordedBodyBytes is how much of a body is recorded before it is marked truncated
+func (d *Database) MaxRecordedBodyBytes() int64 {
+	limit := d.maxBodyBytes
+	if limit <= 0 {
+		limit = config.DefaultMaxBodyBytes
+	}
+	if d.bodyFiles == nil {
+		return min(limit, MaxInlineBodyBytes)
+	}
+	return limit
+}
+
+// OpenResponseBody returns a reader over an interaction's recorded response body, whether it is
+// kept in the database or in a body file, along with its size
+func (d *Database) OpenResponseBody(interaction *Interaction) (io.ReadCloser, int64, error) {
+	name := interaction.ResponseBodyFile()
+	if name == "" {
+		return io.NopCloser(bytes.NewReader(interaction.ResponseBody)), int64(len(interaction.ResponseBody)), nil
+	}
+	file, err := d.openBodyFile(name)
+	if err != nil {
+		return nil, 0, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, 0, fmt.Errorf("failed to read body file: %w", err)
+	}
+	return file, info.Size(), nil
+}
+
+// LoadBodyFiles reads an interaction's body files into its RequestBody and ResponseBody, for
+// tools that need whole bodies in memory such as export
+func (d *Database) LoadBodyFiles(interaction *Interaction) error {
+	if name := interaction.RequestBodyFile(); name != "" {
+		body, err := d.readBodyFile(name)
+		if err != nil {
+			return err
+		}
+		interaction.RequestBody = body
+	}
+	if name := interaction.ResponseBodyFile(); name != "" {
+		body, err := d.readBodyFile(name)
+		if err != nil {
+			return err
+		}
+		interaction.ResponseBody = body
+	}
+	return nil
+}
+
+func (d *Database) openBodyFile(name string) (*os.File, error) {
+	if d.bodyFiles == nil {
+		return nil, fmt.Errorf("body file %s cannot be read: body files are disabled", name)
+	}
+	file, err := d.bodyFiles.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open body file: %w", err)
+	}
+	return file, nil
+}
+
+func (d *Database) readBodyFile(name string) ([]byte, error) {
+	file, err := d.openBodyFile(name)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	body, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read body file: %w", err)
+	}
+	return body, nil
+}
+
+// PruneBodyFiles deletes body files no interaction refers to any more, returning how many were
+// deleted. Files written in the last hour are kept, since their interactions may not be recorded yet.
+func (d *Database) PruneBodyFiles() (int, error) {
+	if d.bodyFiles == nil {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(d.bodyFiles.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to list body files: %w", err)
+	}
+
+	referenced, err := d.referencedBodyFiles()
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-bodyFileGrace)
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if referenced[name] || (!bodyFileName.MatchString(name) && !strings.HasPrefix(name, ".incoming-")) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(d.bodyFiles.dir, name)); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// referencedBodyFiles returns the names of the body files recorded interactions refer to
+func (d *Database) referencedBodyFiles() (map[string]bool, error) {
+	rows, err := d.db.Query(`SELECT metadata FROM interactions WHERE metadata LIKE '%_body_file%'`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find body files: %w", err)
+	}
+	defer rows.Close()
+
+	referenced := make(map[string]bool)
+	for rows.Next() {
+		var interaction Interaction
+		if err := rows.Scan(&interaction.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to scan metadata: %w", err)
+		}
+		for _, name := range []string{interaction.RequestBodyFile(), interaction.ResponseBodyFile()} {
+			if name != "" {
+				referenced[name] = true
+			}
+		}
+	}
+	return referenced, rows.Err()
+}
@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"mimic/config"
+)
+
+func writeBodyFile(t *testing.T, files *BodyFiles, body string) string {
+	t.Helper()
+	writer, err := files.Create()
+	if err != nil {
+		t.Fatalf("Failed to create body file: %v", err)
+	}
+	if _, err := io.Copy(writer, strings.NewReader(body)); err != nil {
+		t.Fatalf("Failed to write body file: %v", err)
+	}
+	name, err := writer.Commit()
+	if err != nil {
+		t.Fatalf("Failed to commit body file: %v", err)
+	}
+	return name
+}
+
+func TestBodyFiles(t *testing.T) {
+	dir := t.TempDir()
+	db, err := Open(config.DatabaseConfig{Path: filepath.Join(dir, "mimic.db"), BodyFileThreshold: 16})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	files := db.BodyFiles()
+	if files == nil || files.Dir() != filepath.Join(dir, "bodies") || files.Threshold() != 16 {
+		t.Fatalf("Expected body files over 16 bytes next to the database, got %+v", files)
+	}
+	if db.MaxRecordedBodyBytes() != config.DefaultMaxBodyBytes {
+		t.Errorf("Expected the default limit, got %d", db.MaxRecordedBodyBytes())
+	}
+
+	body := strings.Repeat("large body ", 10)
+	name := writeBodyFile(t, files, body)
+	if again := writeBodyFile(t, files, body); again != name {
+		t.Errorf("Expected the same body to get the same file, got %s and %s", name, again)
+	}
+	entries, _ := os.ReadDir(files.Dir())
+	if len(entries) != 1 {
+		t.Errorf("Expected one body file, got %d", len(entries))
+	}
+
+	session, err := db.CreateSession("uploads", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	interaction := &Interaction{SessionID: session.ID, RequestID: "r1", Protocol: "REST", Method: "GET", Endpoint: "/file",
+		RequestHeaders: "{}", ResponseStatus: 200, ResponseHeaders: "{}"}
+	if err := interaction.SetResponseBodyFile(name, int64(len(body))); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	reader, size, err := db.OpenResponseBody(interaction)
+	if err != nil {
+		t.Fatalf("OpenResponseBody failed: %v", err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != body || size != int64(len(body)) {
+		t.Errorf("Expected the body file's %d bytes, got %d (size %d)", len(body), len(data), size)
+	}
+	if err := db.LoadBodyFiles(interaction); err != nil || string(interaction.ResponseBody) != body {
+		t.Errorf("Expected the body loaded from its file, got %q (%v)", interaction.ResponseBody, err)
+	}
+
+	// Age the files past the grace period so pruning considers them
+	orphan := writeBodyFile(t, files, "nobody refers to this body")
+	old := time.Now().Add(-2 * bodyFileGrace)
+	for _, file := range []string{name, orphan} {
+		os.Chtimes(filepath.Join(files.Dir(), file), old, old)
+	}
+	if removed, err := db.PruneBodyFiles(); err != nil || removed != 1 {
+		t.Errorf("Expected the orphan to be pruned, removed %d (%v)", removed, err)
+	}
+	if _, err := os.Stat(filepath.Join(files.Dir(), name)); err != nil {
+		t.Errorf("Expected the referenced body file to be kept: %v", err)
+	}
+
+	if err := db.ClearSession("uploads"); err != nil {
+		t.Fatalf("ClearSession failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(files.Dir(), name)); !os.IsNotExist(err) {
+		t.Errorf("Expected the body file to be pruned with its session, got %v", err)
+	}
+}
+
+func TestBodyFilesDisabled(t *testing.T) {
+	db, err := Open(config.DatabaseConfig{Path: filepath.Join(t.TempDir(), "mimic.db"), BodyFileThreshold: -1})
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	defer db.Close()
+
+	if db.BodyFiles() != nil {
+		t.Error("Expected no body files with a threshold of -1")
+	}
+	if db.MaxRecordedBodyBytes() != MaxInlineBodyBytes {
+		t.Errorf("Expected bodies kept in the database to be capped at %d, got %d", MaxInlineBodyBytes, db.MaxRecordedBodyBytes())
+	}
+	if _, err := (&BodyFiles{dir: t.TempDir()}).Open("../mimic.db"); err == nil {
+		t.Error("Expected a name outside the body directory to be rejected")
+	}
+}
@@ -2,17 +2,49 @@ package storage
 
 import (
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	_ "github.com/lib/pq"
 	_ "github.com/mattn/go-sqlite3"
+
+	"mimic/config"
+	"mimic/metrics"
 )
 
 type Database struct {
-	db *sql.DB
+	db     *conn
+	driver string
+	path   string // SQLite database file, "" in memory and on Postgres
+
+	// Large bodies are kept as files; see bodies.go
+	bodyFiles    *BodyFiles
+	maxBodyBytes int64
+
+	// sequenceMutex serializes sequence state updates on SQLite, which has no advisory locks
+	sequenceMutex sync.Mutex
+}
+
+// Open connects to the database described by the config, defaulting to SQLite
+func Open(cfg config.DatabaseConfig) (*Database, error) {
+	var database *Database
+	var err error
+	switch cfg.Driver {
+	case "", DriverSQLite:
+		database, err = NewDatabase(cfg.Path)
+	case DriverPostgres:
+		database, err = NewPostgresDatabase(cfg.URL, cfg.ConnectionPoolSize)
+	default:
+		return nil, fmt.Errorf("unsupported database driver: %s", cfg.Driver)
+	}
+	if err != nil {
+		return nil, err
+	}
+	database.configureBodyFiles(cfg)
+	return database, nil
 }
 
 func NewDatabase(dbPath string) (*Database, error) {
@@ -46,71 +78,90 @@ func NewDatabase(dbPath string) (*Database, error) {
 	db.SetMaxOpenConns(10)
 	db.SetMaxIdleConns(5)
 
-	database := &Database{db: db}
+	database := &Database{db: &conn{DB: db, driver: DriverSQLite}, driver: DriverSQLite, path: dbPath}
+	if err := database.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return database, nil
+}
+
+// NewMemoryDatabase opens an empty SQLite database that lives in memory and disappears when closed,
+// for serving imported fixtures without touching the filesystem
+func NewMemoryDatabase() (*Database, error) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+
+	// Every connection to :memory: gets its own database, so keep exactly one open for good
+	db.SetMaxOpenConns(1)
+	db.SetMaxIdleConns(1)
+	db.SetConnMaxLifetime(0)
+	db.SetConnMaxIdleTime(0)
+
+	database := &Database{db: &conn{DB: db, driver: DriverSQLite}, driver: DriverSQLite}
+	if err := database.createTables(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create tables: %w", err)
+	}
+
+	return database, nil
+}
+
+// NewPostgresDatabase connects to a Postgres database that several mimic replicas can share
+func NewPostgresDatabase(url string, poolSize int) (*Database, error) {
+	if url == "" {
+		return nil, fmt.Errorf("database url cannot be empty")
+	}
+
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if poolSize <= 0 {
+		poolSize = 10
+	}
+	db.SetMaxOpenConns(poolSize)
+	db.SetMaxIdleConns(poolSize / 2)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	database := &Database{db: &conn{DB: db, driver: DriverPostgres}, driver: DriverPostgres}
 	if err := database.createTables(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
 
 	return database, nil
 }
 
+// Shared reports whether other mimic replicas may be using the same database
+func (d *Database) Shared() bool {
+	return d.driver == DriverPostgres
+}
+
 func (d *Database) createTables() error {
-	sessionsTable := `
-	CREATE TABLE IF NOT EXISTS sessions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		session_name TEXT NOT NULL,
-		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		description TEXT
-	);`
-
-	interactionsTable := `
-	CREATE TABLE IF NOT EXISTS interactions (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		session_id INTEGER NOT NULL,
-		request_id TEXT UNIQUE NOT NULL,
-		protocol TEXT NOT NULL CHECK(protocol IN ('REST', 'gRPC')),
-		method TEXT NOT NULL,
-		endpoint TEXT NOT NULL,
-		request_headers TEXT,
-		request_body BLOB,
-		response_status INTEGER,
-		response_headers TEXT,
-		response_body BLOB,
-		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		sequence_number INTEGER NOT NULL,
-		metadata TEXT,
-		is_streaming INTEGER DEFAULT 0,
-		FOREIGN KEY (session_id) REFERENCES sessions(id)
-	);`
-
-	streamChunksTable := `
-	CREATE TABLE IF NOT EXISTS stream_chunks (
-		id INTEGER PRIMARY KEY AUTOINCREMENT,
-		interaction_id INTEGER NOT NULL,
-		chunk_index INTEGER NOT NULL,
-		data BLOB,
-		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-		time_delta INTEGER DEFAULT 0,
-		FOREIGN KEY (interaction_id) REFERENCES interactions(id) ON DELETE CASCADE
-	);`
+	schema := sqliteSchema
+	if d.driver == DriverPostgres {
+		schema = postgresSchema
+	}
 
 	indexes := []string{
 		"CREATE INDEX IF NOT EXISTS idx_endpoint_method ON interactions(endpoint, method);",
 		"CREATE INDEX IF NOT EXISTS idx_session_sequence ON interactions(session_id, sequence_number);",
 		"CREATE INDEX IF NOT EXISTS idx_request_id ON interactions(request_id);",
 		"CREATE INDEX IF NOT EXISTS idx_stream_chunks ON stream_chunks(interaction_id, chunk_index);",
+		"CREATE INDEX IF NOT EXISTS idx_websocket_frames ON websocket_frames(interaction_id, frame_index);",
 	}
 
-	if _, err := d.db.Exec(sessionsTable); err != nil {
-		return fmt.Errorf("failed to create sessions table: %w", err)
-	}
-
-	if _, err := d.db.Exec(interactionsTable); err != nil {
-		return fmt.Errorf("failed to create interactions table: %w", err)
-	}
-
-	if _, err := d.db.Exec(streamChunksTable); err != nil {
-		return fmt.Errorf("failed to create stream_chunks table: %w", err)
+	for _, table := range schema {
+		if _, err := d.db.Exec(table.ddl); err != nil {
+			return fmt.Errorf("failed to create %s table: %w", table.name, err)
+		}
 	}
 
 	for _, index := range indexes {
@@ -119,7 +170,7 @@ func (d *Database) createTables() error {
 		}
 	}
 
-	return nil
+	return d.stampSchemaVersion()
 }
 
 func (d *Database) Close() error {
@@ -127,19 +178,14 @@ func (d *Database) Close() error {
 }
 
 func (d *Database) CreateSession(sessionName, description string) (*Session, error) {
-	query := `INSERT INTO sessions (session_name, description) VALUES (?, ?)`
-	result, err := d.db.Exec(query, sessionName, description)
-	if err != nil {
+	query := `INSERT INTO sessions (session_name, description) VALUES (?, ?) RETURNING id`
+	var id int
+	if err := d.db.QueryRow(query, sessionName, description).Scan(&id); err != nil {
 		return nil, fmt.Errorf("failed to create session: %w", err)
 	}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get session ID: %w", err)
-	}
-
 	return &Session{
-		ID:          int(id),
+		ID:          id,
 		SessionName: sessionName,
 		CreatedAt:   time.Now(),
 		Description: description,
@@ -162,6 +208,22 @@ func (d *Database) GetSession(sessionName string) (*Session, error) {
 	return &session, nil
 }
 
+func (d *Database) GetSessionByID(sessionID int) (*Session, error) {
+	query := `SELECT id, session_name, created_at, description FROM sessions WHERE id = ?`
+	row := d.db.QueryRow(query, sessionID)
+
+	var session Session
+	err := row.Scan(&session.ID, &session.SessionName, &session.CreatedAt, &session.Description)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("session not found: %d", sessionID)
+		}
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return &session, nil
+}
+
 func (d *Database) GetOrCreateSession(sessionName, description string) (*Session, error) {
 	session, err := d.GetSession(sessionName)
 	if err != nil {
@@ -195,6 +257,8 @@ func (d *Database) ListSessions() ([]Session, error) {
 }
 
 func (d *Database) RecordInteraction(interaction *Interaction) error {
+	defer metrics.ObserveDBWrite("record_interaction", time.Now())
+
 	tx, err := d.db.Begin()
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
@@ -214,9 +278,10 @@ func (d *Database) RecordInteraction(interaction *Interaction) error {
 			session_id, request_id, protocol, method, endpoint,
 			request_headers, request_body, response_status, response_headers,
 			response_body, timestamp, sequence_number, metadata, is_streaming
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`
 
-	result, err := tx.Exec(query,
+	err = tx.QueryRow(query,
 		interaction.SessionID,
 		interaction.RequestID,
 		interaction.Protocol,
@@ -231,22 +296,15 @@ func (d *Database) RecordInteraction(interaction *Interaction) error {
 		interaction.SequenceNumber,
 		interaction.Metadata,
 		interaction.IsStreaming,
-	)
+	).Scan(&interaction.ID)
 	if err != nil {
 		return fmt.Errorf("failed to record interaction: %w", err)
 	}
 
-	// Get the interaction ID for potential stream chunks
-	interactionID, err := result.LastInsertId()
-	if err != nil {
-		return fmt.Errorf("failed to get interaction ID: %w", err)
-	}
-	interaction.ID = int(interactionID)
-
 	return tx.Commit()
 }
 
-func (d *Database) getNextSequenceNumber(tx *sql.Tx, sessionID int, endpoint string) (int, error) {
+func (d *Database) getNextSequenceNumber(tx *txn, sessionID int, endpoint string) (int, error) {
 	query := `SELECT COALESCE(MAX(sequence_number), 0) + 1 FROM interactions WHERE session_id = ? AND endpoint = ?`
 	row := tx.QueryRow(query, sessionID, endpoint)
 
@@ -347,6 +405,42 @@ func (d *Database) GetInteractionsBySession(sessionID int) ([]Interaction, error
 	return interactions, nil
 }
 
+func (d *Database) GetInteraction(interactionID int) (*Interaction, error) {
+	query := `
+		SELECT id, session_id, request_id, protocol, method, endpoint,
+			   request_headers, request_body, response_status, response_headers,
+			   response_body, timestamp, sequence_number, metadata, is_streaming
+		FROM interactions
+		WHERE id = ?`
+
+	var interaction Interaction
+	err := d.db.QueryRow(query, interactionID).Scan(
+		&interaction.ID,
+		&interaction.SessionID,
+		&interaction.RequestID,
+		&interaction.Protocol,
+		&interaction.Method,
+		&interaction.Endpoint,
+		&interaction.RequestHeaders,
+		&interaction.RequestBody,
+		&interaction.ResponseStatus,
+		&interaction.ResponseHeaders,
+		&interaction.ResponseBody,
+		&interaction.Timestamp,
+		&interaction.SequenceNumber,
+		&interaction.Metadata,
+		&interaction.IsStreaming,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("interaction not found: %d", interactionID)
+		}
+		return nil, fmt.Errorf("failed to get interaction: %w", err)
+	}
+
+	return &interaction, nil
+}
+
 func (d *Database) GetAllSessions() ([]Session, error) {
 	query := `
 		SELECT id, session_name, created_at, description
@@ -384,12 +478,17 @@ func (d *Database) ClearAllSessions() error {
 	}
 	defer tx.Rollback()
 
-	// Delete all stream chunks and interactions first (due to foreign key constraints)
+	// Delete all stream chunks, WebSocket frames and interactions first (due to foreign key constraints)
 	_, err = tx.Exec("DELETE FROM stream_chunks")
 	if err != nil {
 		return fmt.Errorf("failed to delete stream chunks: %w", err)
 	}
 
+	_, err = tx.Exec("DELETE FROM websocket_frames")
+	if err != nil {
+		return fmt.Errorf("failed to delete WebSocket frames: %w", err)
+	}
+
 	_, err = tx.Exec("DELETE FROM interactions")
 	if err != nil {
 		return fmt.Errorf("failed to delete interactions: %w", err)
@@ -401,7 +500,11 @@ func (d *Database) ClearAllSessions() error {
 		return fmt.Errorf("failed to delete sessions: %w", err)
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.PruneBodyFiles() // Best effort; files left behind go on a later prune
+	return nil
 }
 
 func (d *Database) ClearSession(sessionName string) error {
@@ -416,10 +519,13 @@ func (d *Database) ClearSession(sessionName string) error {
 	}
 	defer tx.Rollback()
 
-	// Delete stream chunks first (due to foreign key constraints)
+	// Delete stream chunks and WebSocket frames first (due to foreign key constraints)
 	if _, err := tx.Exec("DELETE FROM stream_chunks WHERE interaction_id IN (SELECT id FROM interactions WHERE session_id = ?)", session.ID); err != nil {
 		return fmt.Errorf("failed to delete stream chunks: %w", err)
 	}
+	if _, err := tx.Exec("DELETE FROM websocket_frames WHERE interaction_id IN (SELECT id FROM interactions WHERE session_id = ?)", session.ID); err != nil {
+		return fmt.Errorf("failed to delete WebSocket frames: %w", err)
+	}
 
 	if _, err := tx.Exec("DELETE FROM interactions WHERE session_id = ?", session.ID); err != nil {
 		return fmt.Errorf("failed to delete interactions: %w", err)
@@ -429,7 +535,69 @@ func (d *Database) ClearSession(sessionName string) error {
 		return fmt.Errorf("failed to delete session: %w", err)
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	d.PruneBodyFiles() // Best effort; files left behind go on a later prune
+	return nil
+}
+
+// DeleteInteractions removes interactions and their stream chunks and frames, returning how many were deleted
+func (d *Database) DeleteInteractions(interactionIDs []int) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var deleted int64
+	for _, id := range interactionIDs {
+		if _, err := tx.Exec("DELETE FROM stream_chunks WHERE interaction_id = ?", id); err != nil {
+			return 0, fmt.Errorf("failed to delete stream chunks: %w", err)
+		}
+		if _, err := tx.Exec("DELETE FROM websocket_frames WHERE interaction_id = ?", id); err != nil {
+			return 0, fmt.Errorf("failed to delete WebSocket frames: %w", err)
+		}
+		result, err := tx.Exec("DELETE FROM interactions WHERE id = ?", id)
+		if err != nil {
+			return 0, fmt.Errorf("failed to delete interaction %d: %w", id, err)
+		}
+		affected, _ := result.RowsAffected()
+		deleted += affected
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	d.PruneBodyFiles() // Best effort; files left behind go on a later prune
+	return deleted, nil
+}
+
+// DeleteInteractionsByRequestID removes a session's interactions with the given request ID and
+// their stream chunks and frames, returning how many were deleted
+func (d *Database) DeleteInteractionsByRequestID(sessionID int, requestID string) (int64, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM stream_chunks WHERE interaction_id IN (SELECT id FROM interactions WHERE session_id = ? AND request_id = ?)", sessionID, requestID); err != nil {
+		return 0, fmt.Errorf("failed to delete stream chunks: %w", err)
+	}
+	if _, err := tx.Exec("DELETE FROM websocket_frames WHERE interaction_id IN (SELECT id FROM interactions WHERE session_id = ? AND request_id = ?)", sessionID, requestID); err != nil {
+		return 0, fmt.Errorf("failed to delete WebSocket frames: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM interactions WHERE session_id = ? AND request_id = ?", sessionID, requestID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete interactions: %w", err)
+	}
+	deleted, _ := result.RowsAffected()
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return deleted, nil
 }
 
 func (d *Database) ImportInteractions(sessionName string, interactions []Interaction) error {
@@ -490,38 +658,11 @@ func (d *Database) ImportInteractionWithChunks(sessionName string, interaction I
 	}
 	defer tx.Rollback()
 
+	// Keep the newly created interaction ID for the stream chunks
 	interaction.SessionID = session.ID
-	query := `
-		INSERT INTO interactions (
-			session_id, request_id, protocol, method, endpoint,
-			request_headers, request_body, response_status, response_headers,
-			response_body, timestamp, sequence_number, metadata, is_streaming
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
-
-	result, err := tx.Exec(query,
-		interaction.SessionID,
-		interaction.RequestID,
-		interaction.Protocol,
-		interaction.Method,
-		interaction.Endpoint,
-		interaction.RequestHeaders,
-		interaction.RequestBody,
-		interaction.ResponseStatus,
-		interaction.ResponseHeaders,
-		interaction.ResponseBody,
-		interaction.Timestamp,
-		interaction.SequenceNumber,
-		interaction.Metadata,
-		interaction.IsStreaming,
-	)
-	if err != nil {
-		return fmt.Errorf("failed to import interaction: %w", err)
-	}
-
-	// Get the newly created interaction ID
-	interactionID, err := result.LastInsertId()
+	interactionID, err := insertImportedInteraction(tx, &interaction)
 	if err != nil {
-		return fmt.Errorf("failed to get interaction ID: %w", err)
+		return err
 	}
 
 	// Import stream chunks if any
@@ -554,13 +695,55 @@ func (d *Database) ImportInteractionWithChunks(sessionName string, interaction I
 	return tx.Commit()
 }
 
+// insertImportedInteraction inserts an imported interaction as it is, returning its new ID
+func insertImportedInteraction(tx *txn, interaction *Interaction) (int, error) {
+	query := `
+		INSERT INTO interactions (
+			session_id, request_id, protocol, method, endpoint,
+			request_headers, request_body, response_status, response_headers,
+			response_body, timestamp, sequence_number, metadata, is_streaming
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`
+
+	var interactionID int
+	err := tx.QueryRow(query,
+		interaction.SessionID,
+		interaction.RequestID,
+		interaction.Protocol,
+		interaction.Method,
+		interaction.Endpoint,
+		interaction.RequestHeaders,
+		interaction.RequestBody,
+		interaction.ResponseStatus,
+		interaction.ResponseHeaders,
+		interaction.ResponseBody,
+		interaction.Timestamp,
+		interaction.SequenceNumber,
+		interaction.Metadata,
+		interaction.IsStreaming,
+	).Scan(&interactionID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to import interaction: %w", err)
+	}
+	return interactionID, nil
+}
+
 // RecordStreamChunks stores multiple chunks of a streaming response atomically within a transaction.
 // This ensures all-or-nothing semantics: either all chunks succeed or none are persisted.
 // This is the preferred method for recording streaming data to prevent partial data corruption.
 func (d *Database) RecordStreamChunks(chunks []*StreamChunk) error {
-	if len(chunks) == 0 {
+	return d.RecordStreamChunksFrom(len(chunks), func(i int) (*StreamChunk, error) {
+		return chunks[i], nil
+	})
+}
+
+// RecordStreamChunksFrom is RecordStreamChunks for chunks produced one at a time, so a long stream
+// spilled to disk need not be read back into memory at once
+func (d *Database) RecordStreamChunksFrom(count int, chunkAt func(i int) (*StreamChunk, error)) error {
+	if count == 0 {
 		return nil
 	}
+	defer metrics.ObserveDBWrite("record_stream_chunks", time.Now())
 
 	tx, err := d.db.Begin()
 	if err != nil {
@@ -579,8 +762,12 @@ func (d *Database) RecordStreamChunks(chunks []*StreamChunk) error {
 	}
 	defer stmt.Close()
 
-	for i, chunk := range chunks {
-		_, err := stmt.Exec(
+	for i := 0; i < count; i++ {
+		chunk, err := chunkAt(i)
+		if err != nil {
+			return fmt.Errorf("failed to read stream chunk %d: %w", i, err)
+		}
+		_, err = stmt.Exec(
 			chunk.InteractionID,
 			chunk.ChunkIndex,
 			chunk.Data,
@@ -636,22 +823,64 @@ func (d *Database) GetStreamChunks(interactionID int) ([]StreamChunk, error) {
 // MarkInteractionAsPartial updates an interaction's metadata to indicate that
 // some chunks failed to record, leaving the interaction in a partial state.
 func (d *Database) MarkInteractionAsPartial(interactionID int, failedChunks []int) error {
-	// Build metadata struct and marshal to JSON
-	metadata := map[string]interface{}{
+	err := d.UpdateInteractionMetadata(interactionID, map[string]interface{}{
 		"status":        "partial",
 		"failed_chunks": failedChunks,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to mark interaction as partial: %w", err)
 	}
 
-	metadataBytes, err := json.Marshal(metadata)
+	return nil
+}
+
+// UpdateSessionDescription replaces a session's description
+func (d *Database) UpdateSessionDescription(sessionID int, description string) error {
+	result, err := d.db.Exec(`UPDATE sessions SET description = ? WHERE id = ?`, description, sessionID)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return fmt.Errorf("failed to update session description: %w", err)
 	}
 
-	query := `UPDATE interactions SET metadata = ? WHERE id = ?`
-	_, err = d.db.Exec(query, string(metadataBytes), interactionID)
+	rows, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to mark interaction as partial: %w", err)
+		return fmt.Errorf("failed to get affected rows: %w", err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("session not found: %d", sessionID)
 	}
 
 	return nil
 }
+
+// UpdateInteractionMetadata merges values into an interaction's existing metadata; nil values remove keys
+func (d *Database) UpdateInteractionMetadata(interactionID int, values map[string]interface{}) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var current sql.NullString
+	if err := tx.QueryRow(`SELECT metadata FROM interactions WHERE id = ?`, interactionID).Scan(&current); err != nil {
+		return fmt.Errorf("failed to read metadata: %w", err)
+	}
+
+	interaction := Interaction{Metadata: current.String}
+	for key, value := range values {
+		var err error
+		if value == nil {
+			err = interaction.DeleteMetadataValue(key)
+		} else {
+			err = interaction.SetMetadataValue(key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.Exec(`UPDATE interactions SET metadata = ? WHERE id = ?`, interaction.Metadata, interactionID); err != nil {
+		return fmt.Errorf("failed to update metadata: %w", err)
+	}
+
+	return tx.Commit()
+}
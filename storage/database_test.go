@@ -3,6 +3,7 @@ package storage
 import (
 	"path/filepath"
 	"strconv"
+	"sync"
 	"testing"
 	"time"
 )
@@ -277,3 +278,234 @@ func TestConcurrentStreamChunkRecording(t *testing.T) {
 		}
 	}
 }
+
+func TestGetInteractionByID(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	session, err := db.CreateSession("lookup-session", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	interaction := &Interaction{
+		SessionID:      session.ID,
+		RequestID:      "lookup-request",
+		Protocol:       "REST",
+		Method:         "POST",
+		Endpoint:       "/api/items",
+		RequestHeaders: `{"Content-Type":"application/json"}`,
+		RequestBody:    []byte(`{"name":"widget"}`),
+		ResponseStatus: 201,
+		Timestamp:      time.Now(),
+	}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	found, err := db.GetInteraction(interaction.ID)
+	if err != nil {
+		t.Fatalf("Failed to get interaction: %v", err)
+	}
+	if found.RequestID != "lookup-request" || found.ResponseStatus != 201 || string(found.RequestBody) != `{"name":"widget"}` {
+		t.Errorf("Unexpected interaction: %+v", found)
+	}
+
+	foundSession, err := db.GetSessionByID(found.SessionID)
+	if err != nil {
+		t.Fatalf("Failed to get session by ID: %v", err)
+	}
+	if foundSession.SessionName != "lookup-session" {
+		t.Errorf("Expected session 'lookup-session', got %s", foundSession.SessionName)
+	}
+
+	if _, err := db.GetInteraction(interaction.ID + 100); err == nil {
+		t.Error("Expected error for missing interaction")
+	}
+}
+
+func TestUpdateInteractionMetadataMerges(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	session, err := db.CreateSession("metadata-session", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	startedAt := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	interaction := &Interaction{
+		SessionID: session.ID,
+		RequestID: "metadata-request",
+		Protocol:  "REST",
+		Method:    "GET",
+		Endpoint:  "/api/stream",
+	}
+	if err := interaction.SetTiming(startedAt, 150*time.Millisecond); err != nil {
+		t.Fatalf("Failed to set timing: %v", err)
+	}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	if err := db.MarkInteractionAsPartial(interaction.ID, []int{2}); err != nil {
+		t.Fatalf("Failed to mark interaction as partial: %v", err)
+	}
+
+	found, err := db.GetInteraction(interaction.ID)
+	if err != nil {
+		t.Fatalf("Failed to get interaction: %v", err)
+	}
+
+	gotStart, gotDuration, ok := found.Timing()
+	if !ok {
+		t.Fatal("Expected timing to survive metadata update")
+	}
+	if !gotStart.Equal(startedAt) || gotDuration != 150*time.Millisecond {
+		t.Errorf("Unexpected timing: start %v, duration %v", gotStart, gotDuration)
+	}
+	if found.MetadataMap()["status"] != "partial" {
+		t.Errorf("Expected partial status in metadata, got %s", found.Metadata)
+	}
+}
+
+func TestSessionDescriptionAndAnnotation(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	session, err := db.CreateSession("notes-session", "original")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if err := db.UpdateSessionDescription(session.ID, "updated notes"); err != nil {
+		t.Fatalf("Failed to update description: %v", err)
+	}
+	updated, err := db.GetSessionByID(session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	if updated.Description != "updated notes" {
+		t.Errorf("Expected updated description, got %q", updated.Description)
+	}
+
+	interaction := &Interaction{
+		SessionID: session.ID,
+		RequestID: "annotated-request",
+		Protocol:  "REST",
+		Method:    "GET",
+		Endpoint:  "/api/users",
+	}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	if err := db.UpdateInteractionMetadata(interaction.ID, map[string]interface{}{MetadataAnnotation: "flaky upstream"}); err != nil {
+		t.Fatalf("Failed to set annotation: %v", err)
+	}
+	found, err := db.GetInteraction(interaction.ID)
+	if err != nil {
+		t.Fatalf("Failed to get interaction: %v", err)
+	}
+	if found.Annotation() != "flaky upstream" {
+		t.Errorf("Expected annotation, got %q", found.Annotation())
+	}
+
+	if err := db.UpdateInteractionMetadata(interaction.ID, map[string]interface{}{MetadataAnnotation: nil}); err != nil {
+		t.Fatalf("Failed to clear annotation: %v", err)
+	}
+	found, err = db.GetInteraction(interaction.ID)
+	if err != nil {
+		t.Fatalf("Failed to get interaction: %v", err)
+	}
+	if _, ok := found.MetadataMap()[MetadataAnnotation]; ok {
+		t.Errorf("Expected annotation to be removed, got %s", found.Metadata)
+	}
+}
+
+func TestDeleteInteractions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	session, err := db.CreateSession("prune-session", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	var ids []int
+	for _, requestID := range []string{"keep", "drop-1", "drop-2"} {
+		interaction := &Interaction{
+			SessionID:   session.ID,
+			RequestID:   requestID,
+			Protocol:    "REST",
+			Method:      "GET",
+			Endpoint:    "/" + requestID,
+			IsStreaming: true,
+			Timestamp:   time.Now(),
+		}
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+		if err := db.RecordStreamChunks([]*StreamChunk{{InteractionID: interaction.ID, Data: []byte("x"), Timestamp: time.Now()}}); err != nil {
+			t.Fatalf("Failed to record chunks: %v", err)
+		}
+		ids = append(ids, interaction.ID)
+	}
+
+	deleted, err := db.DeleteInteractions([]int{ids[1], ids[2], ids[2] + 100})
+	if err != nil {
+		t.Fatalf("DeleteInteractions failed: %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("Expected 2 deletions, got %d", deleted)
+	}
+
+	remaining, err := db.GetInteractionsBySession(session.ID)
+	if err != nil || len(remaining) != 1 || remaining[0].RequestID != "keep" {
+		t.Errorf("Expected only 'keep' to remain, got %v (%v)", remaining, err)
+	}
+	if chunks, _ := db.GetStreamChunks(ids[1]); len(chunks) != 0 {
+		t.Errorf("Expected the deleted interaction's chunks to be gone, got %d", len(chunks))
+	}
+	if chunks, _ := db.GetStreamChunks(ids[0]); len(chunks) != 1 {
+		t.Errorf("Expected the kept interaction's chunks to remain, got %d", len(chunks))
+	}
+}
+
+func TestMemoryDatabase(t *testing.T) {
+	db, err := NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("NewMemoryDatabase failed: %v", err)
+	}
+	defer db.Close()
+
+	session, err := db.GetOrCreateSession("fixtures", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	// Concurrent writers and readers must all see the same single in-memory database
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			interaction := &Interaction{SessionID: session.ID, RequestID: "req-" + strconv.Itoa(i), Protocol: "REST", Method: "GET", Endpoint: "/api/items", ResponseStatus: 200}
+			if err := db.RecordInteraction(interaction); err != nil {
+				t.Errorf("RecordInteraction failed: %v", err)
+			}
+			if _, err := db.FindMatchingInteractions(session.ID, "GET", "/api/items"); err != nil {
+				t.Errorf("FindMatchingInteractions failed: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	interactions, err := db.GetInteractionsBySession(session.ID)
+	if err != nil || len(interactions) != 10 {
+		t.Fatalf("Expected 10 interactions, got %d (%v)", len(interactions), err)
+	}
+	if err := db.IntegrityCheck(); err != nil {
+		t.Errorf("IntegrityCheck failed: %v", err)
+	}
+}
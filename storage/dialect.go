@@ -0,0 +1,87 @@
+package storage
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+)
+
+// Supported database drivers
+const (
+	DriverSQLite   = "sqlite"
+	DriverPostgres = "postgres"
+)
+
+// conn wraps *sql.DB so queries written with ? placeholders run on every supported driver
+type conn struct {
+	*sql.DB
+	driver string
+}
+
+func (c *conn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return c.DB.Exec(rebind(c.driver, query), args...)
+}
+
+func (c *conn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return c.DB.Query(rebind(c.driver, query), args...)
+}
+
+func (c *conn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return c.DB.QueryRow(rebind(c.driver, query), args...)
+}
+
+func (c *conn) Begin() (*txn, error) {
+	tx, err := c.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	return &txn{Tx: tx, driver: c.driver}, nil
+}
+
+// txn is the transaction counterpart of conn
+type txn struct {
+	*sql.Tx
+	driver string
+}
+
+func (t *txn) Exec(query string, args ...interface{}) (sql.Result, error) {
+	return t.Tx.Exec(rebind(t.driver, query), args...)
+}
+
+func (t *txn) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return t.Tx.Query(rebind(t.driver, query), args...)
+}
+
+func (t *txn) QueryRow(query string, args ...interface{}) *sql.Row {
+	return t.Tx.QueryRow(rebind(t.driver, query), args...)
+}
+
+func (t *txn) Prepare(query string) (*sql.Stmt, error) {
+	return t.Tx.Prepare(rebind(t.driver, query))
+}
+
+// rebind rewrites ? placeholders into the driver's native form ($1, $2, ... for Postgres)
+func rebind(driver, query string) string {
+	if driver != DriverPostgres || !strings.Contains(query, "?") {
+		return query
+	}
+
+	var b strings.Builder
+	b.Grow(len(query) + 16)
+	n := 0
+	inString := false
+	for _, r := range query {
+		switch {
+		case r == '\'':
+			inString = !inString
+			b.WriteRune(r)
+		case r == '?' && !inString:
+			n++
+			b.WriteByte('$')
+			b.WriteString(strconv.Itoa(n))
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,162 @@
+package storage
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Content encodings bodies are decompressed from for recording and matching
+const (
+	EncodingGzip    = "gzip"
+	EncodingDeflate = "deflate"
+)
+
+// Compressed reports whether a Content-Encoding is one whose bodies are recorded decompressed
+func Compressed(encoding string) bool {
+	encoding = strings.ToLower(strings.TrimSpace(encoding))
+	return encoding == EncodingGzip || encoding == "x-gzip" || encoding == EncodingDeflate
+}
+
+// Decompress decodes a body sent with a gzip or deflate Content-Encoding. Deflate bodies may be
+// zlib-wrapped, as the HTTP spec has them, or raw, as some servers send them. Decoded bodies
+// larger than MaxInlineBodyBytes are refused.
+func Decompress(body []byte, encoding string) ([]byte, error) {
+	var reader io.ReadCloser
+	var err error
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case EncodingGzip, "x-gzip":
+		reader, err = gzip.NewReader(bytes.NewReader(body))
+	case EncodingDeflate:
+		reader, err = zlib.NewReader(bytes.NewReader(body))
+		if err != nil {
+			reader, err = flate.NewReader(bytes.NewReader(body)), nil
+		}
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s body: %w", encoding, err)
+	}
+	defer reader.Close()
+
+	decoded, err := io.ReadAll(io.LimitReader(reader, MaxInlineBodyBytes+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress %s body: %w", encoding, err)
+	}
+	if len(decoded) > MaxInlineBodyBytes {
+		return nil, fmt.Errorf("decompressed %s body is larger than %d bytes", encoding, MaxInlineBodyBytes)
+	}
+	return decoded, nil
+}
+
+// Compress encodes a body with a gzip or deflate Content-Encoding
+func Compress(body []byte, encoding string) ([]byte, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case EncodingGzip, "x-gzip":
+		writer = gzip.NewWriter(&buffer)
+	case EncodingDeflate:
+		writer = zlib.NewWriter(&buffer)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding: %s", encoding)
+	}
+	if _, err := writer.Write(body); err != nil {
+		return nil, fmt.Errorf("failed to compress body: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress body: %w", err)
+	}
+	return buffer.Bytes(), nil
+}
+
+// RequestEncoding is the Content-Encoding the request body was decompressed from when recorded,
+// "" when it is recorded as sent
+func (i *Interaction) RequestEncoding() string {
+	encoding, _ := i.MetadataMap()[MetadataRequestEncoding].(string)
+	return encoding
+}
+
+// ResponseEncoding is the Content-Encoding the response body was decompressed from when
+// recorded, "" when it is recorded as sent
+func (i *Interaction) ResponseEncoding() string {
+	encoding, _ := i.MetadataMap()[MetadataResponseEncoding].(string)
+	return encoding
+}
+
+// PlainRequestBody returns the request body decompressed, whether that happened when it was
+// recorded or, for recordings kept compressed, happens now; bodies that fail to decode are
+// returned as they are
+func (i *Interaction) PlainRequestBody() []byte {
+	if i.RequestEncoding() != "" {
+		return i.RequestBody
+	}
+	if encoding := headerValue(i.RequestHeaders, "Content-Encoding"); Compressed(encoding) {
+		if decoded, err := Decompress(i.RequestBody, encoding); err == nil {
+			return decoded
+		}
+	}
+	return i.RequestBody
+}
+
+// RequestBodyAsSent returns the request body as the client sent it, compressed again when it
+// was decompressed for recording
+func (i *Interaction) RequestBodyAsSent() ([]byte, error) {
+	if encoding := i.RequestEncoding(); encoding != "" {
+		return Compress(i.RequestBody, encoding)
+	}
+	return i.RequestBody, nil
+}
+
+// ResponseBodyAsSent returns the response body as the server sent it, compressed again when it
+// was decompressed for recording
+func (i *Interaction) ResponseBodyAsSent() ([]byte, error) {
+	if encoding := i.ResponseEncoding(); encoding != "" {
+		return Compress(i.ResponseBody, encoding)
+	}
+	return i.ResponseBody, nil
+}
+
+// DecompressBodies decompresses the request and response bodies kept in the database that were
+// sent gzip or deflate encoded, marking each one so it can be compressed again for playback.
+// Truncated bodies, bodies in body files, and bodies that fail to decode are left as they were.
+func (i *Interaction) DecompressBodies() error {
+	values := i.MetadataMap()
+	if encoding := headerValue(i.RequestHeaders, "Content-Encoding"); Compressed(encoding) && values[MetadataRequestTruncated] == nil && i.RequestBodyFile() == "" && len(i.RequestBody) > 0 {
+		if decoded, err := Decompress(i.RequestBody, encoding); err == nil {
+			i.RequestBody = decoded
+			if err := i.SetMetadataValue(MetadataRequestEncoding, strings.ToLower(encoding)); err != nil {
+				return err
+			}
+		}
+	}
+	if encoding := headerValue(i.ResponseHeaders, "Content-Encoding"); Compressed(encoding) && values[MetadataResponseTruncated] == nil && i.ResponseBodyFile() == "" && len(i.ResponseBody) > 0 {
+		if decoded, err := Decompress(i.ResponseBody, encoding); err == nil {
+			i.ResponseBody = decoded
+			if err := i.SetMetadataValue(MetadataResponseEncoding, strings.ToLower(encoding)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// headerValue reads a header from recorded headers, matching its name case-insensitively
+func headerValue(recordedHeaders, name string) string {
+	var headers map[string]string
+	if json.Unmarshal([]byte(recordedHeaders), &headers) != nil {
+		return ""
+	}
+	for key, value := range headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestDecompressBodies(t *testing.T) {
+	body := []byte(`{"query":"compressed"}`)
+	for _, encoding := range []string{EncodingGzip, EncodingDeflate} {
+		compressed, err := Compress(body, encoding)
+		if err != nil {
+			t.Fatalf("Failed to compress: %v", err)
+		}
+		interaction := &Interaction{
+			RequestHeaders:  `{"Content-Encoding":"` + encoding + `"}`,
+			RequestBody:     compressed,
+			ResponseHeaders: `{"Content-Type":"application/json"}`,
+			ResponseBody:    []byte("plain"),
+		}
+		if !bytes.Equal(interaction.PlainRequestBody(), body) {
+			t.Errorf("%s: expected the plain body of a compressed recording, got %q", encoding, interaction.PlainRequestBody())
+		}
+		if err := interaction.DecompressBodies(); err != nil {
+			t.Fatalf("DecompressBodies failed: %v", err)
+		}
+		if !bytes.Equal(interaction.RequestBody, body) || interaction.RequestEncoding() != encoding {
+			t.Errorf("%s: expected the request recorded decompressed, got %q (%q)", encoding, interaction.RequestBody, interaction.RequestEncoding())
+		}
+		if string(interaction.ResponseBody) != "plain" || interaction.ResponseEncoding() != "" {
+			t.Errorf("%s: expected the uncompressed response left alone, got %q (%q)", encoding, interaction.ResponseBody, interaction.ResponseEncoding())
+		}
+
+		sent, err := interaction.RequestBodyAsSent()
+		if err != nil {
+			t.Fatalf("RequestBodyAsSent failed: %v", err)
+		}
+		if decoded, err := Decompress(sent, encoding); err != nil || !bytes.Equal(decoded, body) {
+			t.Errorf("%s: expected the body compressed again, got %q (%v)", encoding, decoded, err)
+		}
+	}
+
+	corrupt := &Interaction{RequestHeaders: `{"Content-Encoding":"gzip"}`, RequestBody: []byte("not gzip")}
+	if err := corrupt.DecompressBodies(); err != nil || string(corrupt.RequestBody) != "not gzip" || corrupt.RequestEncoding() != "" {
+		t.Errorf("Expected a body that fails to decode to be kept as sent, got %q (%v)", corrupt.RequestBody, err)
+	}
+}
@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strings"
+)
+
+// stampSchemaVersion records the schema version in a database that has none yet, or an older
+// one whose missing tables have just been created
+func (d *Database) stampSchemaVersion() error {
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version >= SchemaVersion {
+		return nil
+	}
+	if _, err := d.db.Exec(`INSERT INTO schema_version (version) VALUES (?)`, SchemaVersion); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	return nil
+}
+
+// SchemaVersion returns the schema version recorded in the database
+func (d *Database) SchemaVersion() (int, error) {
+	var version sql.NullInt64
+	if err := d.db.QueryRow(`SELECT MAX(version) FROM schema_version`).Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return int(version.Int64), nil
+}
+
+// MissingColumns returns the expected columns, as table.column, that the database lacks
+func (d *Database) MissingColumns() []string {
+	var missing []string
+	for _, table := range schemaColumns {
+		query := fmt.Sprintf(`SELECT %s FROM %s LIMIT 0`, strings.Join(table.columns, ", "), table.table)
+		rows, err := d.db.Query(query)
+		if err == nil {
+			rows.Close()
+			continue
+		}
+
+		// Narrow the failure down to the individual columns
+		for _, column := range table.columns {
+			rows, err := d.db.Query(fmt.Sprintf(`SELECT %s FROM %s LIMIT 0`, column, table.table))
+			if err != nil {
+				missing = append(missing, table.table+"."+column)
+				continue
+			}
+			rows.Close()
+		}
+	}
+	return missing
+}
+
+// IntegrityCheck verifies the database file on SQLite, or that the server answers on Postgres
+func (d *Database) IntegrityCheck() error {
+	if d.driver == DriverPostgres {
+		if err := d.db.Ping(); err != nil {
+			return fmt.Errorf("failed to reach database: %w", err)
+		}
+		return nil
+	}
+
+	rows, err := d.db.Query(`PRAGMA integrity_check`)
+	if err != nil {
+		return fmt.Errorf("failed to check database integrity: %w", err)
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return fmt.Errorf("failed to read integrity check: %w", err)
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to read integrity check: %w", err)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("database integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
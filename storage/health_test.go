@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+)
+
+func TestSchemaHealth(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	version, err := db.SchemaVersion()
+	if err != nil || version != SchemaVersion {
+		t.Errorf("Expected schema version %d, got %d (%v)", SchemaVersion, version, err)
+	}
+	if missing := db.MissingColumns(); len(missing) != 0 {
+		t.Errorf("Expected no missing columns in a fresh database, got %v", missing)
+	}
+	if err := db.IntegrityCheck(); err != nil {
+		t.Errorf("Expected a fresh database to pass the integrity check, got %v", err)
+	}
+}
+
+func TestMissingColumns(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "old.db")
+
+	// A sessions table from before descriptions existed
+	raw, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := raw.Exec(`CREATE TABLE sessions (id INTEGER PRIMARY KEY AUTOINCREMENT, session_name TEXT NOT NULL, created_at TIMESTAMP)`); err != nil {
+		t.Fatalf("Failed to create old table: %v", err)
+	}
+	raw.Close()
+
+	db, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	defer db.Close()
+
+	missing := db.MissingColumns()
+	if len(missing) != 1 || missing[0] != "sessions.description" {
+		t.Errorf("Expected sessions.description to be reported missing, got %v", missing)
+	}
+}
+
+func TestSchemaVersionUpgrade(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "v1.db")
+
+	// A database from before WebSocket frames were kept
+	raw, err := sql.Open("sqlite3", dbPath)
+	if err != nil {
+		t.Fatalf("Failed to open database: %v", err)
+	}
+	if _, err := raw.Exec(`CREATE TABLE schema_version (version INTEGER NOT NULL); INSERT INTO schema_version (version) VALUES (1)`); err != nil {
+		t.Fatalf("Failed to create old table: %v", err)
+	}
+	raw.Close()
+
+	db, err := NewDatabase(dbPath)
+	if err != nil {
+		t.Fatalf("NewDatabase failed: %v", err)
+	}
+	defer db.Close()
+
+	if version, err := db.SchemaVersion(); err != nil || version != SchemaVersion {
+		t.Errorf("Expected the database to be stamped with version %d, got %d (%v)", SchemaVersion, version, err)
+	}
+	if missing := db.MissingColumns(); len(missing) != 0 {
+		t.Errorf("Expected the new tables to be created, got %v missing", missing)
+	}
+}
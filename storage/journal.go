@@ -0,0 +1,89 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// JournalEntry is a request a mock proxy received, kept in the call journal
+type JournalEntry struct {
+	ID        int64
+	Proxy     string
+	Method    string
+	Path      string
+	Query     string
+	Headers   string // JSON object of header names to their values
+	Body      string
+	Matched   bool // Whether a recording answered the request
+	Timestamp time.Time
+}
+
+// AppendJournalEntry adds a request to the call journal, setting its ID and timestamp
+func (d *Database) AppendJournalEntry(entry *JournalEntry) error {
+	entry.Timestamp = time.Now()
+	err := d.db.QueryRow(`
+		INSERT INTO call_journal (proxy, method, path, query, headers, body, matched, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		entry.Proxy, entry.Method, entry.Path, entry.Query, entry.Headers, entry.Body, entry.Matched, entry.Timestamp,
+	).Scan(&entry.ID)
+	if err != nil {
+		return fmt.Errorf("failed to append to call journal: %w", err)
+	}
+	return nil
+}
+
+// GetJournalEntries returns the journal entries after an ID, oldest first
+func (d *Database) GetJournalEntries(afterID int64) ([]JournalEntry, error) {
+	rows, err := d.db.Query(`
+		SELECT id, proxy, method, path, query, headers, body, matched, timestamp
+		FROM call_journal WHERE id > ? ORDER BY id`, afterID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get call journal: %w", err)
+	}
+	defer rows.Close()
+
+	var entries []JournalEntry
+	for rows.Next() {
+		var entry JournalEntry
+		var query, headers, body sql.NullString
+		if err := rows.Scan(&entry.ID, &entry.Proxy, &entry.Method, &entry.Path, &query, &headers, &body, &entry.Matched, &entry.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to scan call journal: %w", err)
+		}
+		entry.Query, entry.Headers, entry.Body = query.String, headers.String, body.String
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+// LastJournalID returns the ID of the latest journal entry, 0 when the journal has never had one
+func (d *Database) LastJournalID() (int64, error) {
+	var id int64
+	// Entries may have been cleared, so ask the ID counter rather than the table
+	query := `SELECT COALESCE(MAX(seq), 0) FROM sqlite_sequence WHERE name = 'call_journal'`
+	if d.driver == DriverPostgres {
+		query = `SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM call_journal_id_seq`
+	}
+	if err := d.db.QueryRow(query).Scan(&id); err != nil {
+		return 0, fmt.Errorf("failed to get last call journal ID: %w", err)
+	}
+	return id, nil
+}
+
+// TrimJournal drops the oldest journal entries beyond the latest keep, returning how many it dropped
+func (d *Database) TrimJournal(keep int) (int64, error) {
+	result, err := d.db.Exec(`DELETE FROM call_journal WHERE id <= (SELECT COALESCE(MAX(id), 0) FROM call_journal) - ?`, keep)
+	if err != nil {
+		return 0, fmt.Errorf("failed to trim call journal: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ClearJournal drops every journal entry; IDs carry on from where they were
+func (d *Database) ClearJournal() error {
+	if _, err := d.db.Exec(`DELETE FROM call_journal`); err != nil {
+		return fmt.Errorf("failed to clear call journal: %w", err)
+	}
+	return nil
+}
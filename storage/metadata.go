@@ -0,0 +1,284 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Well-known interaction metadata keys
+const (
+	MetadataStartedAt         = "started_at"         // RFC 3339 time the request was received
+	MetadataDurationMs        = "duration_ms"        // Time until the full response was received, in milliseconds
+	MetadataAnnotation        = "annotation"         // Free-form note added by whoever recorded the session
+	MetadataResponseTruncated = "response_truncated" // Set when only part of the response body was recorded
+	MetadataRequestTruncated  = "request_truncated"  // Set when only part of the request body was recorded
+	MetadataResponseFullSize  = "response_full_size" // Size in bytes of a truncated response body as it was sent
+	MetadataRequestFullSize   = "request_full_size"  // Size in bytes of a truncated request body as it was sent
+	MetadataRequestSHA256     = "request_sha256"     // Hex SHA-256 of a truncated request body as it was sent
+	MetadataTags              = "tags"               // Labels for grouping and filtering interactions
+	MetadataConsumer          = "consumer"           // Client that made the request, for slicing shared sessions
+	MetadataQuery             = "query"              // Raw query string of the request, if it had one
+	MetadataRequestBodyFile   = "request_body_file"  // Body file holding a request body too large for the database
+	MetadataResponseBodyFile  = "response_body_file" // Body file holding a response body too large for the database
+	MetadataRequestBodySize   = "request_body_size"  // Size in bytes of a request body kept in a file
+	MetadataResponseBodySize  = "response_body_size" // Size in bytes of a response body kept in a file
+	MetadataRequestEncoding   = "request_encoding"   // Content-Encoding the request body was decompressed from
+	MetadataResponseEncoding  = "response_encoding"  // Content-Encoding the response body was decompressed from
+	MetadataTemplate          = "template"           // Set when the response body is a template filled from the request
+	MetadataStub              = "stub"               // Set when the interaction was defined by hand rather than recorded
+	MetadataWhen              = "when"               // Conditions on the request the interaction is served for, such as "body.amount > 1000"
+	MetadataTimeShift         = "time_shift"         // Set when the response's dates move with the time since recording
+	MetadataHits              = "hits"               // Times a deduplicating recording saw the exchange
+	MetadataWebSocket         = "websocket"          // Set when the exchange upgraded to a WebSocket, whose frames are kept apart
+	MetadataAttempts          = "attempts"           // Times the request was sent to the target, when it was retried
+	MetadataRetries           = "retries"            // Why each earlier attempt was retried, such as "503 Service Unavailable"
+
+	// Stateful mocking: an interaction is served only in its scenario's required state, and moves
+	// the scenario to its new state when served
+	MetadataScenario      = "scenario"
+	MetadataRequiredState = "required_state"
+	MetadataNewState      = "new_state"
+)
+
+// DefaultScenario is the scenario of interactions that have states but name no scenario
+const DefaultScenario = "default"
+
+// MetadataMap decodes the interaction's metadata, returning an empty map when unset or invalid
+func (i *Interaction) MetadataMap() map[string]interface{} {
+	values := make(map[string]interface{})
+	if i.Metadata != "" {
+		json.Unmarshal([]byte(i.Metadata), &values)
+	}
+	return values
+}
+
+// SetMetadataValue sets a single metadata key, preserving the others
+func (i *Interaction) SetMetadataValue(key string, value interface{}) error {
+	values := i.MetadataMap()
+	values[key] = value
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	i.Metadata = string(encoded)
+	return nil
+}
+
+// DeleteMetadataValue removes a single metadata key
+func (i *Interaction) DeleteMetadataValue(key string) error {
+	values := i.MetadataMap()
+	if _, ok := values[key]; !ok {
+		return nil
+	}
+	delete(values, key)
+
+	encoded, err := json.Marshal(values)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+	i.Metadata = string(encoded)
+	return nil
+}
+
+// Annotation returns the interaction's note, if any
+func (i *Interaction) Annotation() string {
+	annotation, _ := i.MetadataMap()[MetadataAnnotation].(string)
+	return annotation
+}
+
+// Tags returns the interaction's labels, if any
+func (i *Interaction) Tags() []string {
+	values, _ := i.MetadataMap()[MetadataTags].([]interface{})
+	tags := make([]string, 0, len(values))
+	for _, value := range values {
+		if tag, ok := value.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// HasTag reports whether the interaction is labelled with a tag
+func (i *Interaction) HasTag(tag string) bool {
+	for _, t := range i.Tags() {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Consumer returns the client the interaction was recorded for, if known
+func (i *Interaction) Consumer() string {
+	consumer, _ := i.MetadataMap()[MetadataConsumer].(string)
+	return consumer
+}
+
+// Query returns the raw query string the request was recorded with, "" when it had none
+func (i *Interaction) Query() string {
+	query, _ := i.MetadataMap()[MetadataQuery].(string)
+	return query
+}
+
+// Scenario returns the scenario whose state the interaction depends on or changes,
+// DefaultScenario when it names none
+func (i *Interaction) Scenario() string {
+	if scenario, _ := i.MetadataMap()[MetadataScenario].(string); scenario != "" {
+		return scenario
+	}
+	return DefaultScenario
+}
+
+// RequiredState returns the state the interaction's scenario must be in for it to be served, ""
+// when it is served in any state
+func (i *Interaction) RequiredState() string {
+	state, _ := i.MetadataMap()[MetadataRequiredState].(string)
+	return state
+}
+
+// NewState returns the state serving the interaction moves its scenario to, "" when it leaves
+// the state alone
+func (i *Interaction) NewState() string {
+	state, _ := i.MetadataMap()[MetadataNewState].(string)
+	return state
+}
+
+// Templated reports whether the interaction's response body is a template filled from the
+// request it answers
+func (i *Interaction) Templated() bool {
+	templated, _ := i.MetadataMap()[MetadataTemplate].(bool)
+	return templated
+}
+
+// TimeShifted reports whether the dates in the interaction's response move with the time since
+// it was recorded
+func (i *Interaction) TimeShifted() bool {
+	shifted, _ := i.MetadataMap()[MetadataTimeShift].(bool)
+	return shifted
+}
+
+// Conditions returns the conditions a request must meet for the interaction to be served, if
+// any; a single condition may be stored as a string rather than a list
+func (i *Interaction) Conditions() []string {
+	switch value := i.MetadataMap()[MetadataWhen].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		conditions := make([]string, 0, len(value))
+		for _, element := range value {
+			if condition, ok := element.(string); ok {
+				conditions = append(conditions, condition)
+			}
+		}
+		return conditions
+	}
+	return nil
+}
+
+// Stubbed reports whether the interaction is a stub defined by hand rather than recorded
+func (i *Interaction) Stubbed() bool {
+	stubbed, _ := i.MetadataMap()[MetadataStub].(bool)
+	return stubbed
+}
+
+// RequestTruncated reports whether only a prefix of the request body was recorded
+func (i *Interaction) RequestTruncated() bool {
+	truncated, _ := i.MetadataMap()[MetadataRequestTruncated].(bool)
+	return truncated
+}
+
+// RequestSHA256 is the hex SHA-256 of the whole request body when only part of it was recorded,
+// "" when it is not known
+func (i *Interaction) RequestSHA256() string {
+	sum, _ := i.MetadataMap()[MetadataRequestSHA256].(string)
+	return sum
+}
+
+// ResponseTruncated reports whether only a prefix of the response body was recorded
+func (i *Interaction) ResponseTruncated() bool {
+	truncated, _ := i.MetadataMap()[MetadataResponseTruncated].(bool)
+	return truncated
+}
+
+// Hits is how many times a deduplicating recording saw the exchange, 1 when it counted none
+func (i *Interaction) Hits() int {
+	if hits, ok := i.MetadataMap()[MetadataHits].(float64); ok && hits > 1 {
+		return int(hits)
+	}
+	return 1
+}
+
+// WebSocket reports whether the interaction is a WebSocket upgrade with recorded frames
+func (i *Interaction) WebSocket() bool {
+	websocket, _ := i.MetadataMap()[MetadataWebSocket].(bool)
+	return websocket
+}
+
+// EventStream reports whether a streaming interaction holds server-sent events, as streams recorded
+// without a Content-Type are taken to; other streams, such as NDJSON, keep their recorded headers
+func (i *Interaction) EventStream() bool {
+	contentType := headerValue(i.ResponseHeaders, "Content-Type")
+	return contentType == "" || strings.Contains(strings.ToLower(contentType), "text/event-stream")
+}
+
+// RequestBodyFile names the body file holding the request body, "" when it is in the database
+func (i *Interaction) RequestBodyFile() string {
+	name, _ := i.MetadataMap()[MetadataRequestBodyFile].(string)
+	return name
+}
+
+// ResponseBodyFile names the body file holding the response body, "" when it is in the database
+func (i *Interaction) ResponseBodyFile() string {
+	name, _ := i.MetadataMap()[MetadataResponseBodyFile].(string)
+	return name
+}
+
+// SetRequestBodyFile records that the request body was written to a body file
+func (i *Interaction) SetRequestBodyFile(name string, size int64) error {
+	i.RequestBody = nil
+	if err := i.SetMetadataValue(MetadataRequestBodyFile, name); err != nil {
+		return err
+	}
+	return i.SetMetadataValue(MetadataRequestBodySize, size)
+}
+
+// SetResponseBodyFile records that the response body was written to a body file
+func (i *Interaction) SetResponseBodyFile(name string, size int64) error {
+	i.ResponseBody = nil
+	if err := i.SetMetadataValue(MetadataResponseBodyFile, name); err != nil {
+		return err
+	}
+	return i.SetMetadataValue(MetadataResponseBodySize, size)
+}
+
+// SetTiming records when the exchange started and how long it took
+func (i *Interaction) SetTiming(startedAt time.Time, duration time.Duration) error {
+	if err := i.SetMetadataValue(MetadataStartedAt, startedAt.Format(time.RFC3339Nano)); err != nil {
+		return err
+	}
+	return i.SetMetadataValue(MetadataDurationMs, durationMillis(duration))
+}
+
+// Timing returns the recorded start time and duration, if the interaction has them
+func (i *Interaction) Timing() (time.Time, time.Duration, bool) {
+	values := i.MetadataMap()
+
+	startedAtStr, ok := values[MetadataStartedAt].(string)
+	if !ok {
+		return time.Time{}, 0, false
+	}
+	startedAt, err := time.Parse(time.RFC3339Nano, startedAtStr)
+	if err != nil {
+		return time.Time{}, 0, false
+	}
+
+	durationMs, _ := values[MetadataDurationMs].(float64)
+	return startedAt, time.Duration(durationMs * float64(time.Millisecond)), true
+}
+
+func durationMillis(duration time.Duration) float64 {
+	return float64(duration) / float64(time.Millisecond)
+}
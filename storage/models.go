@@ -39,6 +39,18 @@ type StreamChunk struct {
 	TimeDelta     int64     `json:"time_delta"` // Milliseconds since previous chunk
 }
 
+// WebSocketFrame is one message of a recorded WebSocket conversation
+type WebSocketFrame struct {
+	ID            int       `json:"id"`
+	InteractionID int       `json:"interaction_id"`
+	FrameIndex    int       `json:"frame_index"`
+	Direction     string    `json:"direction"`    // FrameFromClient or FrameFromServer
+	MessageType   int       `json:"message_type"` // WebSocket opcode: 1 text, 2 binary, 8 close
+	Data          []byte    `json:"data"`
+	Timestamp     time.Time `json:"timestamp"`
+	TimeDelta     int64     `json:"time_delta"` // Milliseconds since previous frame
+}
+
 type InteractionRequest struct {
 	Headers map[string]string `json:"headers"`
 	Body    interface{}       `json:"body"`
@@ -53,6 +65,8 @@ type InteractionResponse struct {
 type ExportData struct {
 	Version      string              `json:"version"`
 	Session      Session             `json:"session"`
+	Consumer     string              `json:"consumer,omitempty"` // Set when only one consumer's interactions were exported
+	Tag          string              `json:"tag,omitempty"`      // Set when only the interactions with one tag were exported
 	Interactions []ExportInteraction `json:"interactions"`
 }
 
@@ -62,15 +76,25 @@ type ExportStreamChunk struct {
 	TimeDelta  int64  `json:"time_delta"` // Milliseconds since previous chunk
 }
 
+type ExportWebSocketFrame struct {
+	FrameIndex  int    `json:"frame_index"`
+	Direction   string `json:"direction"`
+	MessageType int    `json:"message_type"`
+	Data        string `json:"data"` // Base64 for binary frames
+	TimeDelta   int64  `json:"time_delta"`
+}
+
 type ExportInteraction struct {
-	RequestID      string              `json:"request_id"`
-	Protocol       string              `json:"protocol"`
-	Method         string              `json:"method"`
-	Endpoint       string              `json:"endpoint"`
-	Request        InteractionRequest  `json:"request"`
-	Response       InteractionResponse `json:"response"`
-	Timestamp      time.Time           `json:"timestamp"`
-	SequenceNumber int                 `json:"sequence_number"`
-	IsStreaming    bool                `json:"is_streaming,omitempty"`
-	StreamChunks   []ExportStreamChunk `json:"stream_chunks,omitempty"`
+	RequestID      string                 `json:"request_id"`
+	Protocol       string                 `json:"protocol"`
+	Method         string                 `json:"method"`
+	Endpoint       string                 `json:"endpoint"`
+	Request        InteractionRequest     `json:"request"`
+	Response       InteractionResponse    `json:"response"`
+	Timestamp      time.Time              `json:"timestamp"`
+	SequenceNumber int                    `json:"sequence_number"`
+	IsStreaming    bool                   `json:"is_streaming,omitempty"`
+	StreamChunks   []ExportStreamChunk    `json:"stream_chunks,omitempty"`
+	Frames         []ExportWebSocketFrame `json:"websocket_frames,omitempty"`
+	Metadata       map[string]interface{} `json:"metadata,omitempty"` // Annotations, timing, and other recorded metadata
 }
@@ -0,0 +1,172 @@
+package storage
+
+// SchemaVersion is the schema revision this build creates and expects; bump it when tables change
+const SchemaVersion = 2
+
+type tableDefinition struct {
+	name string
+	ddl  string
+}
+
+var sqliteSchema = []tableDefinition{
+	{"sessions", `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_name TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		description TEXT
+	);`},
+	{"interactions", `
+	CREATE TABLE IF NOT EXISTS interactions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		session_id INTEGER NOT NULL,
+		request_id TEXT UNIQUE NOT NULL,
+		protocol TEXT NOT NULL CHECK(protocol IN ('REST', 'gRPC')),
+		method TEXT NOT NULL,
+		endpoint TEXT NOT NULL,
+		request_headers TEXT,
+		request_body BLOB,
+		response_status INTEGER,
+		response_headers TEXT,
+		response_body BLOB,
+		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		sequence_number INTEGER NOT NULL,
+		metadata TEXT,
+		is_streaming INTEGER DEFAULT 0,
+		FOREIGN KEY (session_id) REFERENCES sessions(id)
+	);`},
+	{"stream_chunks", `
+	CREATE TABLE IF NOT EXISTS stream_chunks (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		interaction_id INTEGER NOT NULL,
+		chunk_index INTEGER NOT NULL,
+		data BLOB,
+		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		time_delta INTEGER DEFAULT 0,
+		FOREIGN KEY (interaction_id) REFERENCES interactions(id) ON DELETE CASCADE
+	);`},
+	{"websocket_frames", `
+	CREATE TABLE IF NOT EXISTS websocket_frames (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		interaction_id INTEGER NOT NULL,
+		frame_index INTEGER NOT NULL,
+		direction TEXT NOT NULL CHECK(direction IN ('client', 'server')),
+		message_type INTEGER NOT NULL,
+		data BLOB,
+		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		time_delta INTEGER DEFAULT 0,
+		FOREIGN KEY (interaction_id) REFERENCES interactions(id) ON DELETE CASCADE
+	);`},
+	{"sequence_state", `
+	CREATE TABLE IF NOT EXISTS sequence_state (
+		scope TEXT NOT NULL,
+		signature TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		PRIMARY KEY (scope, signature)
+	);`},
+	{"call_journal", `
+	CREATE TABLE IF NOT EXISTS call_journal (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		proxy TEXT NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		query TEXT,
+		headers TEXT,
+		body TEXT,
+		matched BOOLEAN NOT NULL,
+		timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	);`},
+	{"schema_version", `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER NOT NULL
+	);`},
+}
+
+var postgresSchema = []tableDefinition{
+	{"sessions", `
+	CREATE TABLE IF NOT EXISTS sessions (
+		id SERIAL PRIMARY KEY,
+		session_name TEXT NOT NULL,
+		created_at TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		description TEXT
+	);`},
+	{"interactions", `
+	CREATE TABLE IF NOT EXISTS interactions (
+		id SERIAL PRIMARY KEY,
+		session_id INTEGER NOT NULL REFERENCES sessions(id),
+		request_id TEXT UNIQUE NOT NULL,
+		protocol TEXT NOT NULL CHECK(protocol IN ('REST', 'gRPC')),
+		method TEXT NOT NULL,
+		endpoint TEXT NOT NULL,
+		request_headers TEXT,
+		request_body BYTEA,
+		response_status INTEGER,
+		response_headers TEXT,
+		response_body BYTEA,
+		timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		sequence_number INTEGER NOT NULL,
+		metadata TEXT,
+		is_streaming BOOLEAN DEFAULT FALSE
+	);`},
+	{"stream_chunks", `
+	CREATE TABLE IF NOT EXISTS stream_chunks (
+		id BIGSERIAL PRIMARY KEY,
+		interaction_id INTEGER NOT NULL REFERENCES interactions(id) ON DELETE CASCADE,
+		chunk_index INTEGER NOT NULL,
+		data BYTEA,
+		timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		time_delta BIGINT DEFAULT 0
+	);`},
+	{"websocket_frames", `
+	CREATE TABLE IF NOT EXISTS websocket_frames (
+		id BIGSERIAL PRIMARY KEY,
+		interaction_id INTEGER NOT NULL REFERENCES interactions(id) ON DELETE CASCADE,
+		frame_index INTEGER NOT NULL,
+		direction TEXT NOT NULL CHECK(direction IN ('client', 'server')),
+		message_type INTEGER NOT NULL,
+		data BYTEA,
+		timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP,
+		time_delta BIGINT DEFAULT 0
+	);`},
+	{"sequence_state", `
+	CREATE TABLE IF NOT EXISTS sequence_state (
+		scope TEXT NOT NULL,
+		signature TEXT NOT NULL,
+		position INTEGER NOT NULL,
+		PRIMARY KEY (scope, signature)
+	);`},
+	{"call_journal", `
+	CREATE TABLE IF NOT EXISTS call_journal (
+		id BIGSERIAL PRIMARY KEY,
+		proxy TEXT NOT NULL,
+		method TEXT NOT NULL,
+		path TEXT NOT NULL,
+		query TEXT,
+		headers TEXT,
+		body TEXT,
+		matched BOOLEAN NOT NULL,
+		timestamp TIMESTAMPTZ DEFAULT CURRENT_TIMESTAMP
+	);`},
+	{"schema_version", `
+	CREATE TABLE IF NOT EXISTS schema_version (
+		version INTEGER NOT NULL
+	);`},
+}
+
+// schemaColumns lists the columns each table must have, for checking databases created by older builds
+var schemaColumns = []struct {
+	table   string
+	columns []string
+}{
+	{"sessions", []string{"id", "session_name", "created_at", "description"}},
+	{"interactions", []string{
+		"id", "session_id", "request_id", "protocol", "method", "endpoint",
+		"request_headers", "request_body", "response_status", "response_headers", "response_body",
+		"timestamp", "sequence_number", "metadata", "is_streaming",
+	}},
+	{"stream_chunks", []string{"id", "interaction_id", "chunk_index", "data", "timestamp", "time_delta"}},
+	{"websocket_frames", []string{"id", "interaction_id", "frame_index", "direction", "message_type", "data", "timestamp", "time_delta"}},
+	{"sequence_state", []string{"scope", "signature", "position"}},
+	{"call_journal", []string{"id", "proxy", "method", "path", "query", "headers", "body", "matched", "timestamp"}},
+	{"schema_version", []string{"version"}},
+}
@@ -0,0 +1,113 @@
+package storage
+
+import (
+	"fmt"
+)
+
+// AdvanceSequence moves a signature's playback position within a scope. next receives the
+// current position (0 if none) and returns the new one. On Postgres the read-modify-write is
+// guarded by a transaction-scoped advisory lock, so replicas sharing the database never hand
+// out the same position twice.
+func (d *Database) AdvanceSequence(scope, signature string, next func(current int) int) (int, error) {
+	if d.driver != DriverPostgres {
+		d.sequenceMutex.Lock()
+		defer d.sequenceMutex.Unlock()
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if d.driver == DriverPostgres {
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext(?))`, scope+"\x00"+signature); err != nil {
+			return 0, fmt.Errorf("failed to lock sequence state: %w", err)
+		}
+	}
+
+	var current int
+	err = tx.QueryRow(`SELECT COALESCE(MAX(position), 0) FROM sequence_state WHERE scope = ? AND signature = ?`, scope, signature).Scan(&current)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read sequence state: %w", err)
+	}
+
+	position := next(current)
+	_, err = tx.Exec(`
+		INSERT INTO sequence_state (scope, signature, position) VALUES (?, ?, ?)
+		ON CONFLICT (scope, signature) DO UPDATE SET position = excluded.position`,
+		scope, signature, position)
+	if err != nil {
+		return 0, fmt.Errorf("failed to update sequence state: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit sequence state: %w", err)
+	}
+	return position, nil
+}
+
+// GetSequenceState returns every tracked signature's position within a scope
+func (d *Database) GetSequenceState(scope string) (map[string]int, error) {
+	rows, err := d.db.Query(`SELECT signature, position FROM sequence_state WHERE scope = ?`, scope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sequence state: %w", err)
+	}
+	defer rows.Close()
+
+	state := make(map[string]int)
+	for rows.Next() {
+		var signature string
+		var position int
+		if err := rows.Scan(&signature, &position); err != nil {
+			return nil, fmt.Errorf("failed to scan sequence state: %w", err)
+		}
+		state[signature] = position
+	}
+	return state, rows.Err()
+}
+
+// ResetSequenceState clears positions within a scope; an empty signature clears them all.
+// It returns the number of signatures that were reset.
+func (d *Database) ResetSequenceState(scope, signature string) (int64, error) {
+	query := `DELETE FROM sequence_state WHERE scope = ?`
+	args := []interface{}{scope}
+	if signature != "" {
+		query += ` AND signature = ?`
+		args = append(args, signature)
+	}
+
+	result, err := d.db.Exec(query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("failed to reset sequence state: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// SaveSequenceState replaces every position within a scope with the given ones
+func (d *Database) SaveSequenceState(scope string, state map[string]int) error {
+	if d.driver != DriverPostgres {
+		d.sequenceMutex.Lock()
+		defer d.sequenceMutex.Unlock()
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM sequence_state WHERE scope = ?`, scope); err != nil {
+		return fmt.Errorf("failed to clear sequence state: %w", err)
+	}
+	for signature, position := range state {
+		if _, err := tx.Exec(`INSERT INTO sequence_state (scope, signature, position) VALUES (?, ?, ?)`, scope, signature, position); err != nil {
+			return fmt.Errorf("failed to save sequence state: %w", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit sequence state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package storage
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestRebind(t *testing.T) {
+	query := `SELECT id FROM interactions WHERE method = ? AND endpoint = ? AND metadata != '?'`
+
+	if got := rebind(DriverSQLite, query); got != query {
+		t.Errorf("Expected SQLite queries to be left alone, got %s", got)
+	}
+
+	expected := `SELECT id FROM interactions WHERE method = $1 AND endpoint = $2 AND metadata != '?'`
+	if got := rebind(DriverPostgres, query); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+func TestAdvanceSequence(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	increment := func(current int) int { return current + 1 }
+
+	// Concurrent callers must each see a distinct position
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := db.AdvanceSequence("payments/default", "GET:/poll", increment); err != nil {
+				t.Errorf("AdvanceSequence failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := db.AdvanceSequence("orders/default", "GET:/poll", increment); err != nil {
+		t.Fatalf("AdvanceSequence failed: %v", err)
+	}
+
+	state, err := db.GetSequenceState("payments/default")
+	if err != nil {
+		t.Fatalf("GetSequenceState failed: %v", err)
+	}
+	if state["GET:/poll"] != 20 {
+		t.Errorf("Expected position 20 after 20 advances, got %d", state["GET:/poll"])
+	}
+
+	reset, err := db.ResetSequenceState("payments/default", "")
+	if err != nil || reset != 1 {
+		t.Fatalf("Expected one signature reset, got %d (%v)", reset, err)
+	}
+
+	// Other scopes are untouched by a reset
+	state, _ = db.GetSequenceState("orders/default")
+	if state["GET:/poll"] != 1 {
+		t.Errorf("Expected the other scope to keep position 1, got %d", state["GET:/poll"])
+	}
+
+	if err := db.SaveSequenceState("orders/default", map[string]int{"GET:/orders": 3}); err != nil {
+		t.Fatalf("SaveSequenceState failed: %v", err)
+	}
+	state, _ = db.GetSequenceState("orders/default")
+	if len(state) != 1 || state["GET:/orders"] != 3 {
+		t.Errorf("Expected saved state to replace the scope's positions, got %v", state)
+	}
+}
@@ -0,0 +1,247 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// RenameSession gives a session a new name, keeping its interactions and sequence state
+func (d *Database) RenameSession(oldName, newName string) error {
+	session, err := d.GetSession(oldName)
+	if err != nil {
+		return err
+	}
+	if err := d.ensureSessionAbsent(newName); err != nil {
+		return err
+	}
+
+	if _, err := d.db.Exec(`UPDATE sessions SET session_name = ? WHERE id = ?`, newName, session.ID); err != nil {
+		return fmt.Errorf("failed to rename session: %w", err)
+	}
+	return nil
+}
+
+// CopySession creates a new session holding a copy of every interaction in another, with the same
+// sequence numbers and stream chunks. Copies get new request IDs. It returns how many were copied.
+func (d *Database) CopySession(sourceName, targetName string) (int, error) {
+	source, err := d.GetSession(sourceName)
+	if err != nil {
+		return 0, err
+	}
+	if err := d.ensureSessionAbsent(targetName); err != nil {
+		return 0, err
+	}
+	interactions, err := d.GetInteractionsBySession(source.ID)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	var targetID int
+	err = tx.QueryRow(`INSERT INTO sessions (session_name, description) VALUES (?, ?) RETURNING id`,
+		targetName, source.Description).Scan(&targetID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	for _, interaction := range interactions {
+		if err := copyInteraction(tx, interaction, targetID, interaction.SequenceNumber); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return len(interactions), nil
+}
+
+// MergeSessions appends copies of the sources' interactions to the target session, creating it if
+// needed. Sequence numbers continue from the target's, so mocks serve the target's recordings of an
+// endpoint first and then each source's in turn. The sources are left as they are.
+func (d *Database) MergeSessions(targetName string, sourceNames []string) (int, error) {
+	var sources [][]Interaction
+	for _, sourceName := range sourceNames {
+		if sourceName == targetName {
+			return 0, fmt.Errorf("cannot merge session '%s' into itself", sourceName)
+		}
+		source, err := d.GetSession(sourceName)
+		if err != nil {
+			return 0, err
+		}
+		interactions, err := d.GetInteractionsBySession(source.ID)
+		if err != nil {
+			return 0, err
+		}
+		sources = append(sources, interactions)
+	}
+
+	target, err := d.GetOrCreateSession(targetName, "Merged session")
+	if err != nil {
+		return 0, fmt.Errorf("failed to get or create session: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	merged := 0
+	for _, interactions := range sources {
+		for _, interaction := range interactions {
+			sequenceNumber, err := d.getNextSequenceNumber(tx, target.ID, interaction.Endpoint)
+			if err != nil {
+				return 0, err
+			}
+			if err := copyInteraction(tx, interaction, target.ID, sequenceNumber); err != nil {
+				return 0, err
+			}
+			merged++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return merged, nil
+}
+
+// RewriteSession passes the headers and bodies of every interaction in a session, and its stream
+// chunks, through rewrite, and saves what changed in one transaction. It returns how many
+// interactions changed.
+func (d *Database) RewriteSession(sessionName string, rewrite func(string) string) (int, error) {
+	session, err := d.GetSession(sessionName)
+	if err != nil {
+		return 0, err
+	}
+	interactions, err := d.GetInteractionsBySession(session.ID)
+	if err != nil {
+		return 0, err
+	}
+	chunks := make(map[int][]StreamChunk)
+	for _, interaction := range interactions {
+		if interaction.IsStreaming {
+			if chunks[interaction.ID], err = d.GetStreamChunks(interaction.ID); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	changed := 0
+	for _, interaction := range interactions {
+		rewritten := interaction
+		rewritten.RequestHeaders = rewrite(interaction.RequestHeaders)
+		rewritten.RequestBody = []byte(rewrite(string(interaction.RequestBody)))
+		rewritten.ResponseHeaders = rewrite(interaction.ResponseHeaders)
+		rewritten.ResponseBody = []byte(rewrite(string(interaction.ResponseBody)))
+		interactionChanged := rewritten.RequestHeaders != interaction.RequestHeaders ||
+			!bytes.Equal(rewritten.RequestBody, interaction.RequestBody) ||
+			rewritten.ResponseHeaders != interaction.ResponseHeaders ||
+			!bytes.Equal(rewritten.ResponseBody, interaction.ResponseBody)
+		if interactionChanged {
+			_, err := tx.Exec(`
+				UPDATE interactions SET request_headers = ?, request_body = ?, response_headers = ?, response_body = ?
+				WHERE id = ?`,
+				rewritten.RequestHeaders, rewritten.RequestBody, rewritten.ResponseHeaders, rewritten.ResponseBody, interaction.ID)
+			if err != nil {
+				return 0, fmt.Errorf("failed to rewrite interaction %d: %w", interaction.ID, err)
+			}
+		}
+
+		for _, chunk := range chunks[interaction.ID] {
+			data := []byte(rewrite(string(chunk.Data)))
+			if bytes.Equal(data, chunk.Data) {
+				continue
+			}
+			if _, err := tx.Exec(`UPDATE stream_chunks SET data = ? WHERE id = ?`, data, chunk.ID); err != nil {
+				return 0, fmt.Errorf("failed to rewrite stream chunk %d: %w", chunk.ID, err)
+			}
+			interactionChanged = true
+		}
+		if interactionChanged {
+			changed++
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return changed, nil
+}
+
+func (d *Database) ensureSessionAbsent(sessionName string) error {
+	var count int
+	if err := d.db.QueryRow(`SELECT COUNT(*) FROM sessions WHERE session_name = ?`, sessionName).Scan(&count); err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	if count > 0 {
+		return fmt.Errorf("session already exists: %s", sessionName)
+	}
+	return nil
+}
+
+// copyInteraction inserts a copy of an interaction and its stream chunks into another session
+func copyInteraction(tx *txn, interaction Interaction, sessionID, sequenceNumber int) error {
+	query := `
+		INSERT INTO interactions (
+			session_id, request_id, protocol, method, endpoint,
+			request_headers, request_body, response_status, response_headers,
+			response_body, timestamp, sequence_number, metadata, is_streaming
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`
+
+	var copyID int
+	err := tx.QueryRow(query,
+		sessionID,
+		uuid.New().String(),
+		interaction.Protocol,
+		interaction.Method,
+		interaction.Endpoint,
+		interaction.RequestHeaders,
+		interaction.RequestBody,
+		interaction.ResponseStatus,
+		interaction.ResponseHeaders,
+		interaction.ResponseBody,
+		interaction.Timestamp,
+		sequenceNumber,
+		interaction.Metadata,
+		interaction.IsStreaming,
+	).Scan(&copyID)
+	if err != nil {
+		return fmt.Errorf("failed to copy interaction %d: %w", interaction.ID, err)
+	}
+
+	if interaction.IsStreaming {
+		_, err := tx.Exec(`
+			INSERT INTO stream_chunks (interaction_id, chunk_index, data, timestamp, time_delta)
+			SELECT ?, chunk_index, data, timestamp, time_delta FROM stream_chunks WHERE interaction_id = ?`,
+			copyID, interaction.ID)
+		if err != nil {
+			return fmt.Errorf("failed to copy stream chunks of interaction %d: %w", interaction.ID, err)
+		}
+	}
+	if interaction.WebSocket() {
+		_, err := tx.Exec(`
+			INSERT INTO websocket_frames (interaction_id, frame_index, direction, message_type, data, timestamp, time_delta)
+			SELECT ?, frame_index, direction, message_type, data, timestamp, time_delta FROM websocket_frames WHERE interaction_id = ?`,
+			copyID, interaction.ID)
+		if err != nil {
+			return fmt.Errorf("failed to copy WebSocket frames of interaction %d: %w", interaction.ID, err)
+		}
+	}
+	return nil
+}
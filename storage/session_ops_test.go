@@ -0,0 +1,143 @@
+package storage
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func recordTestInteraction(t *testing.T, db *Database, sessionID int, requestID, endpoint string, streaming bool) *Interaction {
+	t.Helper()
+	interaction := &Interaction{
+		SessionID:      sessionID,
+		RequestID:      requestID,
+		Protocol:       "REST",
+		Method:         "GET",
+		Endpoint:       endpoint,
+		ResponseStatus: 200,
+		ResponseBody:   []byte(requestID),
+		IsStreaming:    streaming,
+		Timestamp:      time.Now(),
+	}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+	if streaming {
+		if err := db.RecordStreamChunks([]*StreamChunk{{InteractionID: interaction.ID, Data: []byte("chunk"), Timestamp: time.Now()}}); err != nil {
+			t.Fatalf("Failed to record chunks: %v", err)
+		}
+	}
+	return interaction
+}
+
+func TestRenameAndCopySession(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	session, err := db.CreateSession("draft", "checkout fixtures")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	recordTestInteraction(t, db, session.ID, "a", "/cart", false)
+	recordTestInteraction(t, db, session.ID, "b", "/cart", true)
+	if _, err := db.CreateSession("taken", ""); err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	if err := db.RenameSession("draft", "taken"); err == nil {
+		t.Error("Expected renaming onto an existing session to fail")
+	}
+	if err := db.RenameSession("draft", "checkout"); err != nil {
+		t.Fatalf("RenameSession failed: %v", err)
+	}
+	if _, err := db.GetSession("draft"); err == nil {
+		t.Error("Expected the old name to be gone")
+	}
+
+	copied, err := db.CopySession("checkout", "checkout-copy")
+	if err != nil || copied != 2 {
+		t.Fatalf("Expected 2 interactions copied, got %d (%v)", copied, err)
+	}
+	copySession, err := db.GetSession("checkout-copy")
+	if err != nil || copySession.Description != "checkout fixtures" {
+		t.Fatalf("Expected the copy to keep the description, got %+v (%v)", copySession, err)
+	}
+	copies, _ := db.GetInteractionsBySession(copySession.ID)
+	if len(copies) != 2 || copies[0].SequenceNumber != 1 || copies[1].SequenceNumber != 2 {
+		t.Fatalf("Expected copies with the original sequence numbers, got %+v", copies)
+	}
+	if copies[0].RequestID == "a" {
+		t.Error("Expected copies to get new request IDs")
+	}
+	if chunks, _ := db.GetStreamChunks(copies[1].ID); len(chunks) != 1 || string(chunks[0].Data) != "chunk" {
+		t.Errorf("Expected the stream chunks to be copied, got %v", chunks)
+	}
+
+	if _, err := db.CopySession("checkout", "taken"); err == nil {
+		t.Error("Expected copying onto an existing session to fail")
+	}
+}
+
+func TestMergeSessions(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	base, _ := db.CreateSession("base", "")
+	extra, _ := db.CreateSession("extra", "")
+	recordTestInteraction(t, db, base.ID, "base-1", "/cart", false)
+	recordTestInteraction(t, db, extra.ID, "extra-1", "/cart", false)
+	recordTestInteraction(t, db, extra.ID, "extra-2", "/orders", false)
+
+	if _, err := db.MergeSessions("base", []string{"base"}); err == nil {
+		t.Error("Expected merging a session into itself to fail")
+	}
+
+	merged, err := db.MergeSessions("base", []string{"extra"})
+	if err != nil || merged != 2 {
+		t.Fatalf("Expected 2 interactions merged, got %d (%v)", merged, err)
+	}
+
+	interactions, _ := db.FindMatchingInteractions(base.ID, "GET", "/cart")
+	if len(interactions) != 2 || string(interactions[1].ResponseBody) != "extra-1" || interactions[1].SequenceNumber != 2 {
+		t.Errorf("Expected the merged /cart call to follow the target's, got %+v", interactions)
+	}
+	if remaining, _ := db.GetInteractionsBySession(extra.ID); len(remaining) != 2 {
+		t.Errorf("Expected the source to be left as it was, got %d interactions", len(remaining))
+	}
+
+	if _, err := db.MergeSessions("combined", []string{"base", "extra"}); err != nil {
+		t.Fatalf("Expected merging into a new session to create it: %v", err)
+	}
+}
+
+func TestRewriteSession(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	session, err := db.CreateSession("fixtures", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	plain := recordTestInteraction(t, db, session.ID, "plain", "/cart", false)
+	streamed := recordTestInteraction(t, db, session.ID, "streamed", "/events", true)
+	recordTestInteraction(t, db, session.ID, "other", "/health", false)
+
+	changed, err := db.RewriteSession("fixtures", func(text string) string {
+		return strings.NewReplacer("plain", "[REDACTED]", "chunk", "[REDACTED]").Replace(text)
+	})
+	if err != nil || changed != 2 {
+		t.Fatalf("Expected 2 interactions rewritten, got %d (%v)", changed, err)
+	}
+
+	rewritten, _ := db.GetInteraction(plain.ID)
+	if string(rewritten.ResponseBody) != "[REDACTED]" {
+		t.Errorf("Expected the body to be rewritten, got %q", rewritten.ResponseBody)
+	}
+	chunks, _ := db.GetStreamChunks(streamed.ID)
+	if len(chunks) != 1 || string(chunks[0].Data) != "[REDACTED]" {
+		t.Errorf("Expected the stream chunk to be rewritten, got %+v", chunks)
+	}
+	if _, err := db.RewriteSession("missing", strings.ToUpper); err == nil {
+		t.Error("Expected a missing session to be rejected")
+	}
+}
@@ -0,0 +1,138 @@
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// SessionStats summarizes what a session recorded
+type SessionStats struct {
+	Session       Session         `json:"session"`
+	Interactions  int             `json:"interactions"`
+	Streaming     int             `json:"streaming"`
+	FirstAt       *time.Time      `json:"first_at,omitempty"`
+	LastAt        *time.Time      `json:"last_at,omitempty"`
+	RequestBytes  int64           `json:"request_bytes"`
+	ResponseBytes int64           `json:"response_bytes"` // Includes streamed chunks
+	Protocols     map[string]int  `json:"protocols"`
+	Methods       map[string]int  `json:"methods"`
+	Statuses      map[int]int     `json:"statuses"`
+	Endpoints     []EndpointCount `json:"endpoints"` // Most recorded first
+	Tags          map[string]int  `json:"tags"`
+	Consumers     map[string]int  `json:"consumers"` // Interactions per consumer; unattributed ones are not counted
+}
+
+// EndpointCount is how many times one method and endpoint was recorded
+type EndpointCount struct {
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+	Count    int    `json:"count"`
+}
+
+// DescribeSession computes the stats of a session
+func (d *Database) DescribeSession(sessionName string) (*SessionStats, error) {
+	session, err := d.GetSession(sessionName)
+	if err != nil {
+		return nil, err
+	}
+	interactions, err := d.GetInteractionsBySession(session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := NewSessionStats(*session, interactions)
+
+	var chunkBytes int64
+	query := `
+		SELECT COALESCE(SUM(LENGTH(c.data)), 0) FROM stream_chunks c
+		JOIN interactions i ON i.id = c.interaction_id
+		WHERE i.session_id = ?`
+	if err := d.db.QueryRow(query, session.ID).Scan(&chunkBytes); err != nil {
+		return nil, fmt.Errorf("failed to sum stream chunks: %w", err)
+	}
+	stats.ResponseBytes += chunkBytes
+
+	return stats, nil
+}
+
+// NewSessionStats computes stats from a session's interactions; streamed chunks are not counted
+func NewSessionStats(session Session, interactions []Interaction) *SessionStats {
+	stats := &SessionStats{
+		Session:   session,
+		Protocols: make(map[string]int),
+		Methods:   make(map[string]int),
+		Statuses:  make(map[int]int),
+		Endpoints: []EndpointCount{},
+		Tags:      make(map[string]int),
+		Consumers: make(map[string]int),
+	}
+
+	endpoints := make(map[[2]string]int)
+	for i := range interactions {
+		interaction := &interactions[i]
+		stats.Interactions++
+		if interaction.IsStreaming {
+			stats.Streaming++
+		}
+		stats.RequestBytes += int64(len(interaction.RequestBody))
+		stats.ResponseBytes += int64(len(interaction.ResponseBody))
+		stats.Protocols[interaction.Protocol]++
+		stats.Methods[interaction.Method]++
+		stats.Statuses[interaction.ResponseStatus]++
+		endpoints[[2]string{interaction.Method, interaction.Endpoint}]++
+		for _, tag := range interaction.Tags() {
+			stats.Tags[tag]++
+		}
+		if consumer := interaction.Consumer(); consumer != "" {
+			stats.Consumers[consumer]++
+		}
+
+		timestamp := interaction.Timestamp
+		if stats.FirstAt == nil || timestamp.Before(*stats.FirstAt) {
+			stats.FirstAt = &timestamp
+		}
+		if stats.LastAt == nil || timestamp.After(*stats.LastAt) {
+			stats.LastAt = &timestamp
+		}
+	}
+
+	for key, count := range endpoints {
+		stats.Endpoints = append(stats.Endpoints, EndpointCount{Method: key[0], Endpoint: key[1], Count: count})
+	}
+	sort.Slice(stats.Endpoints, func(i, j int) bool {
+		a, b := stats.Endpoints[i], stats.Endpoints[j]
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		if a.Endpoint != b.Endpoint {
+			return a.Endpoint < b.Endpoint
+		}
+		return a.Method < b.Method
+	})
+	return stats
+}
+
+// SessionWatermark changes whenever interactions or stream chunks are added to or removed from a
+// session, so watchers can poll it cheaply instead of reading the whole session
+type SessionWatermark struct {
+	Interactions int
+	LastID       int
+	Chunks       int
+}
+
+// GetSessionWatermark returns the current watermark of a session; a session that does not exist
+// has the zero watermark
+func (d *Database) GetSessionWatermark(sessionName string) (SessionWatermark, error) {
+	var watermark SessionWatermark
+	query := `
+		SELECT COUNT(*), COALESCE(MAX(i.id), 0),
+			COALESCE(SUM((SELECT COUNT(*) FROM stream_chunks c WHERE c.interaction_id = i.id)), 0)
+		FROM interactions i
+		JOIN sessions s ON s.id = i.session_id
+		WHERE s.session_name = ?`
+	if err := d.db.QueryRow(query, sessionName).Scan(&watermark.Interactions, &watermark.LastID, &watermark.Chunks); err != nil {
+		return SessionWatermark{}, fmt.Errorf("failed to read session watermark: %w", err)
+	}
+	return watermark, nil
+}
@@ -0,0 +1,108 @@
+package storage
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDescribeSession(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	session, err := db.CreateSession("stats", "checkout flow")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+
+	start := time.Now()
+	record := func(method, endpoint string, status int, body string, streaming bool, metadata string) *Interaction {
+		interaction := &Interaction{
+			SessionID:       session.ID,
+			RequestID:       start.Format(time.RFC3339),
+			Protocol:        "REST",
+			Method:          method,
+			Endpoint:        endpoint,
+			RequestHeaders:  "{}",
+			RequestBody:     []byte("{}"),
+			ResponseStatus:  status,
+			ResponseHeaders: "{}",
+			ResponseBody:    []byte(body),
+			Timestamp:       start,
+			Metadata:        metadata,
+			IsStreaming:     streaming,
+		}
+		start = start.Add(time.Second)
+		if err := db.RecordInteraction(interaction); err != nil {
+			t.Fatalf("Failed to record interaction: %v", err)
+		}
+		return interaction
+	}
+
+	record("GET", "/cart", 200, "cart", false, `{"tags":["smoke"]}`)
+	record("GET", "/cart", 200, "cart", false, `{"tags":["smoke","slow"]}`)
+	record("POST", "/orders", 500, "oops", false, "")
+	stream := record("GET", "/events", 200, "", true, "")
+	if err := db.RecordStreamChunks([]*StreamChunk{{InteractionID: stream.ID, ChunkIndex: 0, Data: []byte("data: 1\n\n"), Timestamp: start}}); err != nil {
+		t.Fatalf("Failed to record chunks: %v", err)
+	}
+
+	stats, err := db.DescribeSession("stats")
+	if err != nil {
+		t.Fatalf("DescribeSession failed: %v", err)
+	}
+
+	if stats.Interactions != 4 || stats.Streaming != 1 {
+		t.Errorf("Expected 4 interactions with 1 streaming, got %d and %d", stats.Interactions, stats.Streaming)
+	}
+	if stats.RequestBytes != 8 || stats.ResponseBytes != 12+9 {
+		t.Errorf("Expected 8 request and 21 response bytes, got %d and %d", stats.RequestBytes, stats.ResponseBytes)
+	}
+	if stats.Methods["GET"] != 3 || stats.Statuses[500] != 1 || stats.Protocols["REST"] != 4 {
+		t.Errorf("Unexpected counts: methods %v, statuses %v, protocols %v", stats.Methods, stats.Statuses, stats.Protocols)
+	}
+	if stats.Tags["smoke"] != 2 || stats.Tags["slow"] != 1 {
+		t.Errorf("Expected tag counts smoke 2 and slow 1, got %v", stats.Tags)
+	}
+	if top := stats.Endpoints[0]; top.Method != "GET" || top.Endpoint != "/cart" || top.Count != 2 {
+		t.Errorf("Expected GET /cart to be the most recorded endpoint, got %+v", top)
+	}
+	if stats.FirstAt == nil || stats.LastAt.Before(*stats.FirstAt) {
+		t.Errorf("Expected a time range, got %v to %v", stats.FirstAt, stats.LastAt)
+	}
+
+	if _, err := db.DescribeSession("missing"); err == nil {
+		t.Error("Expected an error for a missing session")
+	}
+}
+
+func TestGetSessionWatermark(t *testing.T) {
+	db, cleanup := setupTestDB(t)
+	defer cleanup()
+
+	empty, err := db.GetSessionWatermark("fixtures")
+	if err != nil {
+		t.Fatalf("GetSessionWatermark failed: %v", err)
+	}
+	if empty != (SessionWatermark{}) {
+		t.Errorf("Expected the zero watermark for a missing session, got %+v", empty)
+	}
+
+	session, err := db.CreateSession("fixtures", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	recordTestInteraction(t, db, session.ID, "a", "/cart", false)
+	first, _ := db.GetSessionWatermark("fixtures")
+	stream := recordTestInteraction(t, db, session.ID, "b", "/events", true)
+	second, _ := db.GetSessionWatermark("fixtures")
+	if first.Interactions != 1 || second.Interactions != 2 || second.LastID != stream.ID || second.Chunks != 1 {
+		t.Errorf("Unexpected watermarks %+v and %+v", first, second)
+	}
+
+	if _, err := db.DeleteInteractions([]int{stream.ID}); err != nil {
+		t.Fatalf("Failed to delete interaction: %v", err)
+	}
+	if after, _ := db.GetSessionWatermark("fixtures"); after != first {
+		t.Errorf("Expected deleting the newest interaction to restore %+v, got %+v", first, after)
+	}
+}
@@ -0,0 +1,132 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+
+	"mimic/metrics"
+)
+
+// Directions a WebSocket frame travels in
+const (
+	FrameFromClient = "client"
+	FrameFromServer = "server"
+)
+
+// RecordWebSocketFrames stores the frames of a WebSocket conversation atomically, so a
+// conversation is replayed whole or not at all
+func (d *Database) RecordWebSocketFrames(frames []*WebSocketFrame) error {
+	if len(frames) == 0 {
+		return nil
+	}
+	defer metrics.ObserveDBWrite("record_websocket_frames", time.Now())
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := insertWebSocketFrames(tx, frames); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+// GetWebSocketFrames retrieves the frames of a WebSocket interaction in the order they were sent
+func (d *Database) GetWebSocketFrames(interactionID int) ([]WebSocketFrame, error) {
+	query := `
+		SELECT id, interaction_id, frame_index, direction, message_type, data, timestamp, time_delta
+		FROM websocket_frames
+		WHERE interaction_id = ?
+		ORDER BY frame_index ASC`
+
+	rows, err := d.db.Query(query, interactionID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get WebSocket frames: %w", err)
+	}
+	defer rows.Close()
+
+	var frames []WebSocketFrame
+	for rows.Next() {
+		var frame WebSocketFrame
+		err := rows.Scan(
+			&frame.ID,
+			&frame.InteractionID,
+			&frame.FrameIndex,
+			&frame.Direction,
+			&frame.MessageType,
+			&frame.Data,
+			&frame.Timestamp,
+			&frame.TimeDelta,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan WebSocket frame: %w", err)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, rows.Err()
+}
+
+// ImportInteractionWithFrames imports a single WebSocket interaction along with its frames
+func (d *Database) ImportInteractionWithFrames(sessionName string, interaction Interaction, frames []WebSocketFrame) error {
+	session, err := d.GetOrCreateSession(sessionName, "Imported session")
+	if err != nil {
+		return fmt.Errorf("failed to get or create session: %w", err)
+	}
+
+	tx, err := d.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	interaction.SessionID = session.ID
+	interactionID, err := insertImportedInteraction(tx, &interaction)
+	if err != nil {
+		return err
+	}
+
+	imported := make([]*WebSocketFrame, len(frames))
+	for i := range frames {
+		imported[i] = &frames[i]
+		imported[i].InteractionID = interactionID
+		if imported[i].Timestamp.IsZero() {
+			imported[i].Timestamp = time.Now()
+		}
+	}
+	if err := insertWebSocketFrames(tx, imported); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertWebSocketFrames(tx *txn, frames []*WebSocketFrame) error {
+	stmt, err := tx.Prepare(`
+		INSERT INTO websocket_frames (
+			interaction_id, frame_index, direction, message_type, data, timestamp, time_delta
+		) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare statement: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, frame := range frames {
+		_, err := stmt.Exec(
+			frame.InteractionID,
+			frame.FrameIndex,
+			frame.Direction,
+			frame.MessageType,
+			frame.Data,
+			frame.Timestamp,
+			frame.TimeDelta,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to record WebSocket frame %d: %w", frame.FrameIndex, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,238 @@
+// Package stub defines mock responses by hand, in YAML or JSON stub files or through
+// POST /api/stubs, and saves them into sessions alongside recorded interactions.
+package stub
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"mimic/storage"
+
+	"gopkg.in/yaml.v3"
+)
+
+// File is a stub file
+type File struct {
+	Session string `yaml:"session" json:"session"` // Default session for stubs that do not name one
+	Stubs   []Stub `yaml:"stubs" json:"stubs"`
+}
+
+// Stub is a mock response defined rather than recorded
+type Stub struct {
+	// Saving a stub again under the same ID replaces it. Defaults to one derived from the request,
+	// so reloading a file replaces its stubs rather than adding them twice.
+	ID       string                 `yaml:"id" json:"id,omitempty"`
+	Session  string                 `yaml:"session" json:"session,omitempty"`
+	Request  Request                `yaml:"request" json:"request"`
+	Response Response               `yaml:"response" json:"response"`
+	Metadata map[string]interface{} `yaml:"metadata" json:"metadata,omitempty"` // Interaction metadata, such as scenario states, template, or when
+}
+
+// Request is the request a stub answers. Unlike a recording, only the headers a stub lists are
+// compared, and a stub without a body answers any body.
+type Request struct {
+	Method  string            `yaml:"method" json:"method"`
+	Path    string            `yaml:"path" json:"path"`   // May carry the query, as in /users?page=2
+	Query   string            `yaml:"query" json:"query"` // Compared as mock.query_matching says
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+	Body    interface{}       `yaml:"body" json:"body,omitempty"` // A string is compared as it is, anything else as JSON
+}
+
+// Response is what a stub answers with
+type Response struct {
+	Status  int               `yaml:"status" json:"status"` // Default 200
+	Headers map[string]string `yaml:"headers" json:"headers,omitempty"`
+	Body    interface{}       `yaml:"body" json:"body,omitempty"` // A string is sent as it is, anything else as JSON
+}
+
+// Load reads a stub file; JSON files are read as the YAML they also are
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read stubs: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse reads and checks the contents of a stub file
+func Parse(data []byte) (*File, error) {
+	var file File
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse stubs: %w", err)
+	}
+	if err := file.Validate(); err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Validate checks that every stub names a request and a session to be saved into
+func (f *File) Validate() error {
+	if len(f.Stubs) == 0 {
+		return fmt.Errorf("no stubs defined")
+	}
+	for i, stub := range f.Stubs {
+		if stub.Request.Method == "" || stub.Request.Path == "" {
+			return fmt.Errorf("stub %d: request method and path are required", i+1)
+		}
+		if !strings.HasPrefix(stub.Request.Path, "/") {
+			return fmt.Errorf("stub %d: request path must start with /", i+1)
+		}
+		if stub.Session == "" && f.Session == "" {
+			return fmt.Errorf("stub %d: no session given for it or the file", i+1)
+		}
+		if status := stub.Response.Status; status != 0 && (status < 100 || status > 599) {
+			return fmt.Errorf("stub %d: invalid response status %d", i+1, status)
+		}
+	}
+	return nil
+}
+
+// Save saves the file's stubs into their sessions, creating sessions as needed and replacing
+// stubs saved before under the same IDs. It returns how many stubs were saved.
+func Save(db *storage.Database, file *File) (int, error) {
+	for i, stub := range file.Stubs {
+		sessionName := stub.Session
+		if sessionName == "" {
+			sessionName = file.Session
+		}
+		session, err := db.GetOrCreateSession(sessionName, "Stub session")
+		if err != nil {
+			return i, fmt.Errorf("failed to get or create session: %w", err)
+		}
+
+		interaction, err := stub.Interaction()
+		if err != nil {
+			return i, fmt.Errorf("stub %d: %w", i+1, err)
+		}
+		interaction.SessionID = session.ID
+		if _, err := db.DeleteInteractionsByRequestID(session.ID, interaction.RequestID); err != nil {
+			return i, fmt.Errorf("failed to replace stub %d: %w", i+1, err)
+		}
+		if err := db.RecordInteraction(interaction); err != nil {
+			return i, fmt.Errorf("failed to save stub %d: %w", i+1, err)
+		}
+	}
+	return len(file.Stubs), nil
+}
+
+// Interaction turns a stub into the interaction it is saved as
+func (s Stub) Interaction() (*storage.Interaction, error) {
+	path, query, _ := strings.Cut(s.Request.Path, "?")
+	if s.Request.Query != "" {
+		query = s.Request.Query
+	}
+
+	requestHeaders, err := json.Marshal(headerMap(s.Request.Headers))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request headers: %w", err)
+	}
+	requestBody, err := encodeBody(s.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+
+	responseHeaders := headerMap(s.Response.Headers)
+	responseBody, err := encodeBody(s.Response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response body: %w", err)
+	}
+	if _, isString := s.Response.Body.(string); s.Response.Body != nil && !isString && !hasHeader(responseHeaders, "Content-Type") {
+		responseHeaders["Content-Type"] = "application/json"
+	}
+	responseHeadersJSON, err := json.Marshal(responseHeaders)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal response headers: %w", err)
+	}
+
+	status := s.Response.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	id, err := s.id()
+	if err != nil {
+		return nil, err
+	}
+	interaction := &storage.Interaction{
+		RequestID:       "stub-" + id,
+		Protocol:        "REST",
+		Method:          strings.ToUpper(s.Request.Method),
+		Endpoint:        path,
+		RequestHeaders:  string(requestHeaders),
+		RequestBody:     requestBody,
+		ResponseStatus:  status,
+		ResponseHeaders: string(responseHeadersJSON),
+		ResponseBody:    responseBody,
+		Timestamp:       time.Now(),
+	}
+	for key, value := range s.Metadata {
+		if err := interaction.SetMetadataValue(key, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := interaction.SetMetadataValue(storage.MetadataStub, true); err != nil {
+		return nil, err
+	}
+	if query != "" {
+		if err := interaction.SetMetadataValue(storage.MetadataQuery, query); err != nil {
+			return nil, err
+		}
+	}
+	return interaction, nil
+}
+
+// id is the stub's ID, or one derived from its request and conditions when it has none
+func (s Stub) id() (string, error) {
+	if s.ID != "" {
+		return s.ID, nil
+	}
+	request := s.Request
+	request.Method = strings.ToUpper(request.Method)
+	encoded, err := json.Marshal(request)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+	// Stubs for the same request under different conditions are different stubs
+	if when, ok := s.Metadata[storage.MetadataWhen]; ok {
+		conditions, err := json.Marshal(when)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal conditions: %w", err)
+		}
+		encoded = append(encoded, conditions...)
+	}
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:6]), nil
+}
+
+// encodeBody returns a string body as it is and any other as JSON
+func encodeBody(body interface{}) ([]byte, error) {
+	switch body := body.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []byte(body), nil
+	default:
+		return json.Marshal(body)
+	}
+}
+
+// headerMap copies headers, with their names in canonical form
+func headerMap(headers map[string]string) map[string]string {
+	copied := make(map[string]string, len(headers))
+	for name, value := range headers {
+		copied[http.CanonicalHeaderKey(name)] = value
+	}
+	return copied
+}
+
+func hasHeader(headers map[string]string, name string) bool {
+	_, ok := headers[http.CanonicalHeaderKey(name)]
+	return ok
+}
@@ -0,0 +1,95 @@
+package stub
+
+import (
+	"testing"
+
+	"mimic/storage"
+)
+
+const stubFile = `
+session: payments
+stubs:
+  - request:
+      method: get
+      path: /v1/users/42?expand=orders
+      headers:
+        x-api-key: secret
+    response:
+      status: 200
+      body:
+        id: 42
+        name: Ada
+  - id: health
+    session: ops
+    request:
+      method: GET
+      path: /health
+    response:
+      headers:
+        Content-Type: text/plain
+      body: ok
+`
+
+func TestParseAndSave(t *testing.T) {
+	file, err := Parse([]byte(stubFile))
+	if err != nil {
+		t.Fatalf("Failed to parse stubs: %v", err)
+	}
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+
+	// Saving twice replaces the stubs rather than adding them again
+	for i := 0; i < 2; i++ {
+		if saved, err := Save(db, file); err != nil || saved != 2 {
+			t.Fatalf("Expected 2 stubs saved, got %d, %v", saved, err)
+		}
+	}
+
+	payments, err := db.GetSession("payments")
+	if err != nil {
+		t.Fatalf("Failed to get session: %v", err)
+	}
+	interactions, err := db.GetInteractionsBySession(payments.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected 1 stub in payments, got %d, %v", len(interactions), err)
+	}
+	user := interactions[0]
+	if user.Method != "GET" || user.Endpoint != "/v1/users/42" || user.Query() != "expand=orders" || !user.Stubbed() {
+		t.Errorf("Unexpected stub interaction: %+v", user)
+	}
+	if user.RequestHeaders != `{"X-Api-Key":"secret"}` {
+		t.Errorf("Expected canonical request headers, got %s", user.RequestHeaders)
+	}
+	if string(user.ResponseBody) != `{"id":42,"name":"Ada"}` || user.ResponseHeaders != `{"Content-Type":"application/json"}` {
+		t.Errorf("Expected a JSON response, got %s %s", user.ResponseHeaders, user.ResponseBody)
+	}
+
+	ops, err := db.GetSession("ops")
+	if err != nil {
+		t.Fatalf("Expected the stub's own session to be created: %v", err)
+	}
+	interactions, err = db.GetInteractionsBySession(ops.ID)
+	if err != nil || len(interactions) != 1 {
+		t.Fatalf("Expected 1 stub in ops, got %d, %v", len(interactions), err)
+	}
+	if health := interactions[0]; health.RequestID != "stub-health" || health.ResponseStatus != 200 || string(health.ResponseBody) != "ok" {
+		t.Errorf("Unexpected stub interaction: %+v", health)
+	}
+}
+
+func TestParseRejectsInvalidStubs(t *testing.T) {
+	for name, data := range map[string]string{
+		"no stubs":   `session: payments`,
+		"no path":    "session: s\nstubs:\n  - request: {method: GET}",
+		"no session": "stubs:\n  - request: {method: GET, path: /health}",
+		"bad status": "session: s\nstubs:\n  - request: {method: GET, path: /health}\n    response: {status: 42}",
+	} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("%s: expected Parse to fail", name)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+// Package tag segments a recording run into scenarios: a client names the scenario it is
+// exercising in the X-Mimic-Tag header, the tag is kept in the interactions' "tags" metadata, and
+// mocks, replays, and exports can then work on one scenario at a time.
+package tag
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"mimic/storage"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Header carries the tags of a request, separated by commas. Like mimic's other headers it is
+// neither forwarded upstream nor recorded.
+const Header = "X-Mimic-Tag"
+
+// maxTagLength bounds tags taken from requests
+const maxTagLength = 128
+
+// FromRequest returns the tags an HTTP request names, in order and without repeats
+func FromRequest(r *http.Request) []string {
+	return parse(r.Header.Values(Header))
+}
+
+// FromContext returns the tags a gRPC call names in its metadata
+func FromContext(ctx context.Context) []string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	return parse(md.Get(Header))
+}
+
+// Requested names the tag whose interactions a mock answers an HTTP request from: the first one
+// the request names, or else the configured serve. "" answers from the whole session.
+func Requested(r *http.Request, serve string) string {
+	if tags := FromRequest(r); len(tags) > 0 {
+		return tags[0]
+	}
+	return serve
+}
+
+// RequestedFromContext is Requested for gRPC calls
+func RequestedFromContext(ctx context.Context, serve string) string {
+	if tags := FromContext(ctx); len(tags) > 0 {
+		return tags[0]
+	}
+	return serve
+}
+
+// Apply adds tags to an interaction's "tags" metadata; no tags leaves it as it is
+func Apply(interaction *storage.Interaction, tags []string) error {
+	if len(tags) == 0 {
+		return nil
+	}
+	merged := interaction.Tags()
+	for _, t := range tags {
+		if !contains(merged, t) {
+			merged = append(merged, t)
+		}
+	}
+	return interaction.SetMetadataValue(storage.MetadataTags, merged)
+}
+
+// Filter returns the interactions carrying a tag, in order; an empty tag keeps them all
+func Filter(interactions []storage.Interaction, tag string) []storage.Interaction {
+	if tag == "" {
+		return interactions
+	}
+	var tagged []storage.Interaction
+	for _, interaction := range interactions {
+		if interaction.HasTag(tag) {
+			tagged = append(tagged, interaction)
+		}
+	}
+	return tagged
+}
+
+// parse splits header values into tags, trimming them, dropping control characters, and
+// bounding their length
+func parse(values []string) []string {
+	var tags []string
+	for _, value := range values {
+		for _, tag := range strings.Split(value, ",") {
+			tag = strings.Map(func(r rune) rune {
+				if r < 0x20 || r == 0x7f {
+					return -1
+				}
+				return r
+			}, strings.TrimSpace(tag))
+			if len(tag) > maxTagLength {
+				tag = strings.ToValidUTF8(tag[:maxTagLength], "")
+			}
+			if tag != "" && !contains(tags, tag) {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	return tags
+}
+
+func contains(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,72 @@
+package tag
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"mimic/storage"
+)
+
+func TestFromRequest(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   []string
+		expected []string
+	}{
+		{"single", []string{" checkout-happy-path "}, []string{"checkout-happy-path"}},
+		{"comma separated", []string{"checkout, payment-declined"}, []string{"checkout", "payment-declined"}},
+		{"repeated headers", []string{"checkout", "refund,checkout"}, []string{"checkout", "refund"}},
+		{"control characters", []string{"check\x00out\n"}, []string{"checkout"}},
+		{"empty", []string{" , "}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &http.Request{Header: http.Header{Header: tt.values}}
+			if got := FromRequest(r); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestRequested(t *testing.T) {
+	r := &http.Request{Header: http.Header{}}
+	if got := Requested(r, "checkout"); got != "checkout" {
+		t.Errorf("Expected the served tag, got %q", got)
+	}
+	r.Header.Set(Header, "refund,checkout")
+	if got := Requested(r, "checkout"); got != "refund" {
+		t.Errorf("Expected the first tag the request names, got %q", got)
+	}
+}
+
+func TestApplyAndFilter(t *testing.T) {
+	var interactions []storage.Interaction
+	for i, tags := range [][]string{{"checkout"}, nil, {"refund", "checkout"}} {
+		interaction := storage.Interaction{ID: i + 1}
+		if err := Apply(&interaction, tags); err != nil {
+			t.Fatalf("Apply failed: %v", err)
+		}
+		interactions = append(interactions, interaction)
+	}
+	if interactions[1].Metadata != "" {
+		t.Errorf("Expected no tags to leave the metadata alone, got %s", interactions[1].Metadata)
+	}
+
+	tagged := Filter(interactions, "checkout")
+	if len(tagged) != 2 || tagged[0].ID != 1 || tagged[1].ID != 3 {
+		t.Errorf("Expected interactions 1 and 3, got %+v", tagged)
+	}
+	if len(Filter(interactions, "")) != 3 {
+		t.Error("Expected an empty tag to keep every interaction")
+	}
+
+	// Tags added later join the ones the interaction already has
+	if err := Apply(&interactions[2], []string{"checkout", "smoke"}); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if got := interactions[2].Tags(); !reflect.DeepEqual(got, []string{"refund", "checkout", "smoke"}) {
+		t.Errorf("Expected the tags in order without repeats, got %q", got)
+	}
+}
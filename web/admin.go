@@ -0,0 +1,219 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"mimic/fault"
+)
+
+// AdminController lets the web UI inspect and manipulate the running proxies
+type AdminController interface {
+	SequenceState() []SequenceEntry
+	ResetSequence(proxyName, sessionName, signature string) (int, error)
+	ScenarioStates() []ScenarioEntry
+	SetScenarioState(proxyName, scenario, state string) error
+	ResetScenarios(proxyName, scenario string) (int, error)
+	Mode() string
+	SetMode(mode string) error
+	ProxyMode(proxyName string) string
+	ProxySession(proxyName string) string
+	SetProxySession(proxyName, sessionName string) error
+	ProxyFaults(proxyName string) fault.Settings
+	SetProxyFaults(proxyName string, settings fault.Settings) error
+}
+
+// SequenceEntry describes where one mock request signature currently sits in its recorded sequence
+type SequenceEntry struct {
+	Proxy     string `json:"proxy"`
+	Session   string `json:"session"` // Session the proxy serves
+	Signature string `json:"signature"`
+	Method    string `json:"method"`
+	Path      string `json:"path"`
+	Position  int    `json:"position"` // Sequence number of the recording served last
+}
+
+// sequenceResetRequest is the body accepted by POST /api/sequences/reset
+type sequenceResetRequest struct {
+	Proxy     string `json:"proxy"`     // Empty resets every mock proxy
+	Session   string `json:"session"`   // Limits the reset to the proxies serving this session
+	Signature string `json:"signature"` // Empty resets every signature of the proxy
+}
+
+// modeRequest is the body accepted by PUT /api/mode
+type modeRequest struct {
+	Mode string `json:"mode"`
+}
+
+// proxySessionRequest is the body accepted by PUT /api/proxies/{name}/session
+type proxySessionRequest struct {
+	Session string `json:"session"`
+}
+
+// proxyFaultsResponse is returned by /api/proxies/{name}/faults; PUT accepts the same fields without proxy
+type proxyFaultsResponse struct {
+	Proxy string `json:"proxy"`
+	fault.Settings
+}
+
+// SetAdminController attaches the controller backing the admin endpoints
+func (s *Server) SetAdminController(controller AdminController) {
+	s.admin = controller
+}
+
+func (s *Server) handleSequences(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	entries := []SequenceEntry{}
+	if s.admin != nil {
+		entries = s.admin.SequenceState()
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+func (s *Server) handleSequenceReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.admin == nil {
+		http.Error(w, "Sequence control is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req sequenceResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	reset, err := s.admin.ResetSequence(req.Proxy, req.Session, req.Signature)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.BroadcastEvent("sequence_reset", map[string]interface{}{
+		"proxy":     req.Proxy,
+		"session":   req.Session,
+		"signature": req.Signature,
+		"reset":     reset,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"reset": reset})
+}
+
+// handleMode reports (GET) or switches (PUT) the global proxy mode
+func (s *Server) handleMode(w http.ResponseWriter, r *http.Request) {
+	if s.admin == nil {
+		http.Error(w, "Mode control is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req modeRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := s.admin.SetMode(req.Mode); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.BroadcastEvent("mode_changed", modeRequest{Mode: req.Mode})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(modeRequest{Mode: s.admin.Mode()})
+}
+
+// handleProxyDetail serves the per-proxy admin endpoints under /api/proxies/{name}/
+func (s *Server) handleProxyDetail(w http.ResponseWriter, r *http.Request) {
+	name, resource, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/proxies/"), "/")
+	if !ok || name == "" || (resource != "session" && resource != "faults") {
+		http.NotFound(w, r)
+		return
+	}
+	if _, exists := s.config.Proxies[name]; !exists {
+		http.Error(w, fmt.Sprintf("No proxy named '%s'", name), http.StatusNotFound)
+		return
+	}
+	if s.admin == nil {
+		http.Error(w, "Proxy control is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	if resource == "faults" {
+		s.handleProxyFaults(w, r, name)
+	} else {
+		s.handleProxySession(w, r, name)
+	}
+}
+
+// handleProxySession reports the session a proxy serves (GET), or points a mock proxy at another
+// session and rewinds its sequences (PUT)
+func (s *Server) handleProxySession(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req proxySessionRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Session == "" {
+			http.Error(w, "Invalid request body: session is required", http.StatusBadRequest)
+			return
+		}
+		if _, err := s.database.GetSession(req.Session); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		if err := s.admin.SetProxySession(name, req.Session); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		s.BroadcastEvent("proxy_session_changed", map[string]string{"proxy": name, "session": req.Session})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"proxy": name, "session": s.admin.ProxySession(name)})
+}
+
+// handleProxyFaults reports (GET), replaces (PUT), or clears (DELETE) the faults injected into a proxy's traffic
+func (s *Server) handleProxyFaults(w http.ResponseWriter, r *http.Request, name string) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodDelete:
+		var settings fault.Settings
+		if r.Method == http.MethodPut {
+			if err := json.NewDecoder(r.Body).Decode(&settings); err != nil {
+				http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		if err := s.admin.SetProxyFaults(name, settings); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.BroadcastEvent("proxy_faults_changed", map[string]interface{}{"proxy": name, "faults": settings})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proxyFaultsResponse{Proxy: name, Settings: s.admin.ProxyFaults(name)})
+}
@@ -0,0 +1,124 @@
+package web
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// Role is the access level granted to a web UI or API caller
+type Role int
+
+const (
+	RoleNone   Role = iota // Not allowed to use the API
+	RoleViewer             // Read-only access to sessions, events, and metrics
+	RoleAdmin              // May also clear, edit, resend, replay, and reset state
+)
+
+func (r Role) String() string {
+	switch r {
+	case RoleAdmin:
+		return "admin"
+	case RoleViewer:
+		return "viewer"
+	default:
+		return "none"
+	}
+}
+
+// authEnabled reports whether any access tokens are configured
+func (s *Server) authEnabled() bool {
+	return len(s.config.Auth.AdminTokens) > 0 || len(s.config.Auth.ViewerTokens) > 0
+}
+
+// RoleFor resolves the role of a request from its bearer token or token query parameter
+func (s *Server) RoleFor(r *http.Request) Role {
+	return s.RoleForToken(requestToken(r))
+}
+
+// RoleForToken resolves the role an access token grants; an empty token is an anonymous caller
+func (s *Server) RoleForToken(token string) Role {
+	if !s.authEnabled() {
+		return RoleAdmin
+	}
+
+	if token != "" {
+		if matchesToken(token, s.config.Auth.AdminTokens) {
+			return RoleAdmin
+		}
+		if matchesToken(token, s.config.Auth.ViewerTokens) {
+			return RoleViewer
+		}
+		return RoleNone
+	}
+
+	// Without viewer tokens, reading stays open to anonymous users
+	if len(s.config.Auth.ViewerTokens) == 0 {
+		return RoleViewer
+	}
+	return RoleNone
+}
+
+// RequireRole writes an error response and returns false when the request lacks the given role
+func (s *Server) RequireRole(w http.ResponseWriter, r *http.Request, role Role) bool {
+	granted := s.RoleFor(r)
+	if granted >= role {
+		return true
+	}
+
+	if granted == RoleNone {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="mimic"`)
+		http.Error(w, "Authentication required", http.StatusUnauthorized)
+	} else {
+		http.Error(w, "Admin role required", http.StatusForbidden)
+	}
+	return false
+}
+
+// authorize wraps an API handler so reads need the viewer role and anything else needs admin
+func (s *Server) authorize(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !s.RequireRole(w, r, requiredRole(r)) {
+			return
+		}
+		next(w, r)
+	}
+}
+
+// handleAuth tells the UI which role its token grants
+func (s *Server) handleAuth(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"enabled": s.authEnabled(),
+		"role":    s.RoleFor(r).String(),
+	})
+}
+
+func requiredRole(r *http.Request) Role {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RoleViewer
+	default:
+		return RoleAdmin
+	}
+}
+
+// requestToken reads a bearer token, falling back to the token query parameter for WebSocket clients
+func requestToken(r *http.Request) string {
+	if header := r.Header.Get("Authorization"); header != "" {
+		if token, ok := strings.CutPrefix(header, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	return r.URL.Query().Get("token")
+}
+
+func matchesToken(token string, tokens []string) bool {
+	for _, candidate := range tokens {
+		if candidate != "" && subtle.ConstantTimeCompare([]byte(token), []byte(candidate)) == 1 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"mimic/config"
+	"mimic/storage"
+)
+
+// newAuthTestServer serves the web UI's real routes with the given tokens
+func newAuthTestServer(t *testing.T, auth config.AuthConfig) *httptest.Server {
+	t.Helper()
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := config.DefaultConfig()
+	cfg.Auth = auth
+	s := NewServer(cfg, db)
+	mux := http.NewServeMux()
+	s.RegisterRoutes(mux)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestAuthorizeRoles(t *testing.T) {
+	both := config.AuthConfig{AdminTokens: []string{"admin-token"}, ViewerTokens: []string{"viewer-token"}}
+	adminOnly := config.AuthConfig{AdminTokens: []string{"admin-token"}}
+
+	tests := []struct {
+		name   string
+		auth   config.AuthConfig
+		method string
+		path   string
+		token  string
+		status int
+	}{
+		{"viewer reads sessions", both, "GET", "/api/sessions", "viewer-token", http.StatusOK},
+		{"viewer may not clear", both, "POST", "/api/clear", "viewer-token", http.StatusForbidden},
+		{"admin clears", both, "POST", "/api/clear", "admin-token", http.StatusOK},
+		{"unknown token", both, "GET", "/api/sessions", "guess", http.StatusUnauthorized},
+		{"anonymous with viewer tokens", both, "GET", "/api/sessions", "", http.StatusUnauthorized},
+		{"anonymous reads with only admin tokens", adminOnly, "GET", "/api/sessions", "", http.StatusOK},
+		{"anonymous may not clear with only admin tokens", adminOnly, "POST", "/api/clear", "", http.StatusForbidden},
+		{"anonymous is admin without tokens", config.AuthConfig{}, "POST", "/api/clear", "", http.StatusOK},
+		{"auth stays open", both, "GET", "/api/auth", "", http.StatusOK},
+		{"OpenAPI document stays open", both, "GET", "/api/openapi.json", "", http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newAuthTestServer(t, test.auth)
+			req, _ := http.NewRequest(test.method, server.URL+test.path, nil)
+			if test.token != "" {
+				req.Header.Set("Authorization", "Bearer "+test.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.status {
+				t.Errorf("Expected %d, got %d", test.status, resp.StatusCode)
+			}
+			if test.status == http.StatusUnauthorized && resp.Header.Get("WWW-Authenticate") == "" {
+				t.Error("Expected a WWW-Authenticate challenge")
+			}
+		})
+	}
+}
+
+func TestAuthorizeWebSocketToken(t *testing.T) {
+	server := newAuthTestServer(t, config.AuthConfig{AdminTokens: []string{"admin-token"}, ViewerTokens: []string{"viewer-token"}})
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http") + "/ws"
+
+	tests := []struct {
+		name   string
+		url    string
+		header http.Header
+		status int
+	}{
+		{"bearer header", wsURL, http.Header{"Authorization": {"Bearer viewer-token"}}, http.StatusSwitchingProtocols},
+		{"token query parameter", wsURL + "?token=viewer-token", nil, http.StatusSwitchingProtocols},
+		{"anonymous", wsURL, nil, http.StatusUnauthorized},
+		{"unknown token", wsURL + "?token=guess", nil, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			conn, resp, err := websocket.DefaultDialer.Dial(test.url, test.header)
+			if conn != nil {
+				conn.Close()
+			}
+			if resp == nil {
+				t.Fatalf("Expected a handshake response, got %v", err)
+			}
+			if resp.StatusCode != test.status {
+				t.Errorf("Expected %d, got %d (%v)", test.status, resp.StatusCode, err)
+			}
+		})
+	}
+}
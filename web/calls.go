@@ -0,0 +1,175 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"mimic/calllog"
+)
+
+// callVerifyRequest is the body of POST /api/calls/verify
+type callVerifyRequest struct {
+	calllog.Matcher
+	calllog.Expectation
+}
+
+// handleCalls lists (GET) or forgets (DELETE) the calls received by the mock proxies
+func (s *Server) handleCalls(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		matcher, err := callMatcherFromQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		matched, err := calllog.Default.Find(matcher)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(matched)
+	case http.MethodDelete:
+		calllog.Default.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleCallCheckpoint returns a checkpoint to count later calls from
+func (s *Server) handleCallCheckpoint(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]uint64{"checkpoint": calllog.Default.Checkpoint()})
+}
+
+// handleCallCount counts the calls selected by the query parameters
+func (s *Server) handleCallCount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	matcher, err := callMatcherFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	count, err := calllog.Default.Count(matcher)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"count": count})
+}
+
+// handleCallVerify checks a call count against an expectation, from query parameters (GET) or a
+// JSON body (POST). A failed expectation is answered with 417 so scripts can fail on the status.
+func (s *Server) handleCallVerify(w http.ResponseWriter, r *http.Request) {
+	var req callVerifyRequest
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		matcher, err := callMatcherFromQuery(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		expectation, err := callExpectationFromQuery(query)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req = callVerifyRequest{Matcher: matcher, Expectation: expectation}
+	case http.MethodPost:
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	verification, err := calllog.Default.Verify(req.Matcher, req.Expectation)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if !verification.Passed {
+		w.WriteHeader(http.StatusExpectationFailed)
+	}
+	json.NewEncoder(w).Encode(verification)
+}
+
+// callMatcherFromQuery reads proxy, method, path (or endpoint), body_contains, since, matched,
+// and repeated header=Name:value parameters
+func callMatcherFromQuery(query url.Values) (calllog.Matcher, error) {
+	matcher := calllog.Matcher{
+		Proxy:        query.Get("proxy"),
+		Method:       query.Get("method"),
+		Path:         query.Get("path"),
+		BodyContains: query.Get("body_contains"),
+	}
+	if matcher.Path == "" {
+		matcher.Path = query.Get("endpoint")
+	}
+	if text := query.Get("matched"); text != "" {
+		matched, err := strconv.ParseBool(text)
+		if err != nil {
+			return calllog.Matcher{}, fmt.Errorf("invalid matched: %s", text)
+		}
+		matcher.Matched = &matched
+	}
+	if since := query.Get("since"); since != "" {
+		checkpoint, err := strconv.ParseUint(since, 10, 64)
+		if err != nil {
+			return calllog.Matcher{}, fmt.Errorf("invalid since: %s", since)
+		}
+		matcher.Since = checkpoint
+	}
+	for _, header := range query["header"] {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return calllog.Matcher{}, fmt.Errorf("invalid header %q: expected Name:value", header)
+		}
+		if matcher.Headers == nil {
+			matcher.Headers = make(map[string]string)
+		}
+		matcher.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return matcher, nil
+}
+
+// callExpectationFromQuery reads the exactly (or count), at_least, and at_most parameters
+func callExpectationFromQuery(query url.Values) (calllog.Expectation, error) {
+	var expectation calllog.Expectation
+	if query.Get("exactly") == "" && query.Get("count") != "" {
+		query.Set("exactly", query.Get("count"))
+	}
+	for name, field := range map[string]**int{"exactly": &expectation.Exactly, "at_least": &expectation.AtLeast, "at_most": &expectation.AtMost} {
+		text := query.Get(name)
+		if text == "" {
+			continue
+		}
+		n, err := strconv.Atoi(text)
+		if err != nil {
+			return calllog.Expectation{}, fmt.Errorf("invalid %s: %s", name, text)
+		}
+		*field = &n
+	}
+	return expectation, nil
+}
@@ -0,0 +1,160 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"mimic/metrics"
+)
+
+// clientSendBuffer bounds how many events may queue for a slow client before events are dropped
+const clientSendBuffer = 256
+
+// EventFilter narrows the traffic events a live client receives; empty fields match everything
+type EventFilter struct {
+	Proxy    string `json:"proxy"`
+	Session  string `json:"session"`
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"` // Regular expression matched against the endpoint
+
+	endpointPattern *regexp.Regexp
+}
+
+// compile validates the filter and prepares the endpoint pattern
+func (f *EventFilter) compile() error {
+	f.endpointPattern = nil
+	if f.Endpoint == "" {
+		return nil
+	}
+
+	pattern, err := regexp.Compile(f.Endpoint)
+	if err != nil {
+		return fmt.Errorf("invalid endpoint pattern: %w", err)
+	}
+	f.endpointPattern = pattern
+	return nil
+}
+
+// matches reports whether a broadcast should be delivered under this filter
+func (f *EventFilter) matches(message *broadcastMessage) bool {
+	// Non-traffic events (replay progress, resets, ...) are never filtered out
+	if !message.traffic {
+		return true
+	}
+	if f.Proxy != "" && f.Proxy != message.proxyName {
+		return false
+	}
+	if f.Session != "" && f.Session != message.sessionName {
+		return false
+	}
+	if f.Method != "" && !strings.EqualFold(f.Method, message.method) {
+		return false
+	}
+	if f.endpointPattern != nil && !f.endpointPattern.MatchString(message.endpoint) {
+		return false
+	}
+	return true
+}
+
+// filterFromQuery builds a filter from ?proxy=&session=&method=&endpoint= parameters
+func filterFromQuery(query url.Values) (EventFilter, error) {
+	filter := EventFilter{
+		Proxy:    query.Get("proxy"),
+		Session:  query.Get("session"),
+		Method:   query.Get("method"),
+		Endpoint: query.Get("endpoint"),
+	}
+	return filter, filter.compile()
+}
+
+// broadcastMessage is an encoded event plus the attributes used for filtering
+type broadcastMessage struct {
+	payload     []byte
+	traffic     bool
+	proxyName   string
+	sessionName string
+	method      string
+	endpoint    string
+}
+
+// liveClient is a subscriber to the live event stream
+type liveClient struct {
+	send      chan []byte
+	transport string // "websocket" or "sse"
+	mutex     sync.RWMutex
+	filter    EventFilter
+}
+
+func newLiveClient(transport string, filter EventFilter) *liveClient {
+	return &liveClient{
+		send:      make(chan []byte, clientSendBuffer),
+		transport: transport,
+		filter:    filter,
+	}
+}
+
+func (c *liveClient) setFilter(filter EventFilter) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.filter = filter
+}
+
+func (c *liveClient) wants(message *broadcastMessage) bool {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.filter.matches(message)
+}
+
+// clientCommand is a message sent by a WebSocket client
+type clientCommand struct {
+	Type   string      `json:"type"` // "subscribe"
+	Filter EventFilter `json:"filter"`
+}
+
+// handleClientCommand applies a subscribe command and returns the acknowledgement to send back
+func (c *liveClient) handleClientCommand(data []byte) []byte {
+	var command clientCommand
+	if err := json.Unmarshal(data, &command); err != nil {
+		return commandReply("error", map[string]string{"error": fmt.Sprintf("invalid command: %v", err)})
+	}
+
+	switch command.Type {
+	case "subscribe":
+		if err := command.Filter.compile(); err != nil {
+			return commandReply("error", map[string]string{"error": err.Error()})
+		}
+		c.setFilter(command.Filter)
+		return commandReply("subscribed", command.Filter)
+	default:
+		return commandReply("error", map[string]string{"error": fmt.Sprintf("unknown command: %s", command.Type)})
+	}
+}
+
+func commandReply(messageType string, data interface{}) []byte {
+	reply, _ := json.Marshal(Message{Type: messageType, Timestamp: time.Now(), Data: data})
+	return reply
+}
+
+// addClient registers a subscriber for broadcasts
+func (s *Server) addClient(client *liveClient) {
+	s.clientsMux.Lock()
+	s.clients[client] = true
+	s.clientsMux.Unlock()
+	metrics.LiveClientConnected(client.transport)
+}
+
+// removeClient unregisters a subscriber and closes its queue
+func (s *Server) removeClient(client *liveClient) {
+	s.clientsMux.Lock()
+	if s.clients[client] {
+		delete(s.clients, client)
+		close(client.send)
+		metrics.LiveClientDisconnected(client.transport)
+	}
+	s.clientsMux.Unlock()
+}
@@ -0,0 +1,129 @@
+package web
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"mimic/config"
+)
+
+func TestEventFilterMatches(t *testing.T) {
+	request := &broadcastMessage{traffic: true, proxyName: "billing", sessionName: "checkout", method: "POST", endpoint: "/v1/charges/42"}
+	tests := []struct {
+		name    string
+		filter  EventFilter
+		message *broadcastMessage
+		matches bool
+	}{
+		{"empty filter", EventFilter{}, request, true},
+		{"same proxy", EventFilter{Proxy: "billing"}, request, true},
+		{"other proxy", EventFilter{Proxy: "users"}, request, false},
+		{"same session", EventFilter{Session: "checkout"}, request, true},
+		{"other session", EventFilter{Session: "signup"}, request, false},
+		{"method in another case", EventFilter{Method: "post"}, request, true},
+		{"other method", EventFilter{Method: "GET"}, request, false},
+		{"endpoint pattern", EventFilter{Endpoint: `^/v1/charges/\d+$`}, request, true},
+		{"endpoint pattern missing", EventFilter{Endpoint: `^/v1/refunds`}, request, false},
+		{"every field", EventFilter{Proxy: "billing", Session: "checkout", Method: "POST", Endpoint: "charges"}, request, true},
+		{"non-traffic event", EventFilter{Proxy: "users", Method: "GET"}, &broadcastMessage{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := test.filter.compile(); err != nil {
+				t.Fatalf("Failed to compile filter: %v", err)
+			}
+			if got := test.filter.matches(test.message); got != test.matches {
+				t.Errorf("Expected matches to be %v, got %v", test.matches, got)
+			}
+		})
+	}
+}
+
+func TestFilterFromQuery(t *testing.T) {
+	filter, err := filterFromQuery(url.Values{"proxy": {"billing"}, "method": {"GET"}, "endpoint": {"^/users"}})
+	if err != nil {
+		t.Fatalf("Failed to build filter: %v", err)
+	}
+	if filter.Proxy != "billing" || filter.Method != "GET" || filter.endpointPattern == nil {
+		t.Errorf("Expected the query parameters in the filter, got %+v", filter)
+	}
+
+	if _, err := filterFromQuery(url.Values{"endpoint": {"(unclosed"}}); err == nil || !strings.Contains(err.Error(), "invalid endpoint pattern") {
+		t.Errorf("Expected an invalid endpoint pattern error, got %v", err)
+	}
+}
+
+func TestHandleClientCommand(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		reply   string
+		error   string
+	}{
+		{"subscribe", `{"type":"subscribe","filter":{"proxy":"billing","endpoint":"^/v1"}}`, "subscribed", ""},
+		{"bad endpoint pattern", `{"type":"subscribe","filter":{"endpoint":"(unclosed"}}`, "error", "invalid endpoint pattern"},
+		{"unknown command", `{"type":"unsubscribe"}`, "error", "unknown command: unsubscribe"},
+		{"invalid JSON", `{"type":`, "error", "invalid command"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			client := newLiveClient("websocket", EventFilter{Proxy: "users"})
+
+			var reply struct {
+				Type string                 `json:"type"`
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(client.handleClientCommand([]byte(test.command)), &reply); err != nil {
+				t.Fatalf("Failed to decode reply: %v", err)
+			}
+			if reply.Type != test.reply {
+				t.Errorf("Expected a %s reply, got %s", test.reply, reply.Type)
+			}
+
+			if test.error != "" {
+				if message, _ := reply.Data["error"].(string); !strings.Contains(message, test.error) {
+					t.Errorf("Expected an error containing %q, got %q", test.error, message)
+				}
+				if client.filter.Proxy != "users" {
+					t.Errorf("Expected a failed command to keep the filter, got %+v", client.filter)
+				}
+				return
+			}
+			if client.filter.Proxy != "billing" || client.filter.endpointPattern == nil {
+				t.Errorf("Expected the subscribed filter applied, got %+v", client.filter)
+			}
+		})
+	}
+}
+
+func TestBroadcastDeliversToMatchingClients(t *testing.T) {
+	s := NewServer(config.DefaultConfig(), nil)
+	go s.handleBroadcast()
+	defer close(s.broadcast)
+
+	billing := newLiveClient("websocket", EventFilter{Proxy: "billing"})
+	users := newLiveClient("sse", EventFilter{Proxy: "users"})
+	s.addClient(billing)
+	s.addClient(users)
+	defer s.removeClient(billing)
+	defer s.removeClient(users)
+
+	s.BroadcastRequest("billing", "POST", "/v1/charges", "checkout", "127.0.0.1", "req-1", nil, "{}")
+
+	select {
+	case payload := <-billing.send:
+		if !strings.Contains(string(payload), `"request_id":"req-1"`) {
+			t.Errorf("Expected the billing request event, got %s", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the matching client to receive the event")
+	}
+	select {
+	case payload := <-users.send:
+		t.Errorf("Expected the other client not to receive the event, got %s", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
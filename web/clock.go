@@ -0,0 +1,72 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"mimic/clock"
+)
+
+// clockRequest is the body of PUT /api/clock
+type clockRequest struct {
+	Now    time.Time `json:"now"`
+	Frozen bool      `json:"frozen"`
+}
+
+// clockAdvanceRequest is the body of POST /api/clock/advance
+type clockAdvanceRequest struct {
+	By string `json:"by"` // Go duration, e.g. "90m" or "-1h"
+}
+
+// handleClock reports (GET), sets (PUT), or resets to the wall clock (DELETE) the virtual clock
+func (s *Server) handleClock(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		var req clockRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := clock.Default.Set(req.Now, req.Frozen); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.BroadcastEvent("clock_changed", clock.Default.State())
+	case http.MethodDelete:
+		clock.Default.Reset()
+		s.BroadcastEvent("clock_changed", clock.Default.State())
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clock.Default.State())
+}
+
+// handleClockAdvance moves the virtual clock by a duration
+func (s *Server) handleClockAdvance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req clockAdvanceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	by, err := time.ParseDuration(req.By)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Invalid duration %q: %v", req.By, err), http.StatusBadRequest)
+		return
+	}
+	clock.Default.Advance(by)
+	s.BroadcastEvent("clock_changed", clock.Default.State())
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(clock.Default.State())
+}
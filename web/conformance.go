@@ -0,0 +1,61 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"mimic/conformance"
+)
+
+// handleConformance lists (GET) or forgets (DELETE) the OpenAPI violations seen in live traffic,
+// optionally limited with the proxy and session query parameters
+func (s *Server) handleConformance(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(conformance.Default.Events(query.Get("proxy"), query.Get("session")))
+	case http.MethodDelete:
+		conformance.Default.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleSessionConformance checks a recorded session against a proxy's OpenAPI spec
+// (GET /api/sessions/{id}/conformance). The proxy query parameter picks the spec; by default it is
+// the spec of the proxy serving the session, or the only one configured.
+func (s *Server) handleSessionConformance(w http.ResponseWriter, r *http.Request, sessionID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, err := s.database.GetSessionByID(sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	proxyName := r.URL.Query().Get("proxy")
+	if proxyName == "" {
+		if proxyName, err = conformance.SpecProxy(s.config.Proxies, session.SessionName); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	spec := conformance.Default.Spec(proxyName)
+	if spec == nil {
+		http.Error(w, fmt.Sprintf("Proxy '%s' has no loaded openapi_spec", proxyName), http.StatusBadRequest)
+		return
+	}
+
+	report, err := conformance.CheckSession(s.database, session.SessionName, spec)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
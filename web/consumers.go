@@ -0,0 +1,29 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"mimic/consumer"
+)
+
+// handleSessionConsumers lists the consumers a session's interactions are attributed to, with how
+// many each made (GET /api/sessions/{id}/consumers)
+func (s *Server) handleSessionConsumers(w http.ResponseWriter, r *http.Request, sessionID int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, err := s.database.GetSessionByID(sessionID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	interactions, err := s.database.GetInteractionsBySession(sessionID)
+	if err != nil {
+		http.Error(w, "Failed to get interactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(consumer.Counts(interactions))
+}
@@ -0,0 +1,51 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"mimic/protoschema"
+)
+
+// decodedInteraction is a gRPC interaction's messages rendered as protobuf JSON
+type decodedInteraction struct {
+	Method   string          `json:"method"`
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// handleInteractionDecoded renders a recorded gRPC interaction's messages as JSON using the
+// loaded descriptors (GET /api/interactions/{id}/decoded)
+func (s *Server) handleInteractionDecoded(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	interaction, err := s.database.GetInteraction(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if interaction.Protocol != "gRPC" {
+		http.Error(w, fmt.Sprintf("Interaction %d is not a gRPC call", id), http.StatusBadRequest)
+		return
+	}
+	if _, ok := protoschema.Default.Method(interaction.Method); !ok {
+		http.Error(w, fmt.Sprintf("No descriptor loaded for %s; set grpc.descriptor_sets or grpc.buf_modules", interaction.Method), http.StatusNotFound)
+		return
+	}
+
+	decoded := decodedInteraction{Method: interaction.Method}
+	if decoded.Request, err = protoschema.Default.Decode(interaction.Method, protoschema.Request, interaction.RequestBody); err == nil {
+		decoded.Response, err = protoschema.Default.Decode(interaction.Method, protoschema.Response, interaction.ResponseBody)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(decoded)
+}
@@ -0,0 +1,85 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"mimic/intercept"
+)
+
+// handleBreakpoints lists (GET) or adds (POST) intercept breakpoints
+func (s *Server) handleBreakpoints(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(intercept.Default.Breakpoints())
+	case http.MethodPost:
+		var req intercept.Breakpoint
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		breakpoint, err := intercept.Default.AddBreakpoint(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		s.BroadcastEvent("breakpoints_changed", intercept.Default.Breakpoints())
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(breakpoint)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleBreakpointDetail removes a breakpoint (DELETE /api/intercept/breakpoints/{id})
+func (s *Server) handleBreakpointDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/intercept/breakpoints/")
+	if !intercept.Default.RemoveBreakpoint(id) {
+		http.Error(w, "Breakpoint not found", http.StatusNotFound)
+		return
+	}
+	s.BroadcastEvent("breakpoints_changed", intercept.Default.Breakpoints())
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePaused lists the exchanges waiting on a decision
+func (s *Server) handlePaused(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(intercept.Default.Paused())
+}
+
+// handlePausedDetail resumes a paused exchange with optional edits (POST /api/intercept/paused/{id})
+func (s *Server) handlePausedDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var decision intercept.Decision
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/intercept/paused/")
+	if err := intercept.Default.Resolve(id, decision); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
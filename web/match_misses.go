@@ -0,0 +1,25 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"mimic/nearmiss"
+)
+
+// handleMatchMisses lists (GET) or forgets (DELETE) the requests the mock proxies could not
+// answer, with the closest recordings, optionally limited with the proxy and session query
+// parameters
+func (s *Server) handleMatchMisses(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		query := r.URL.Query()
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(nearmiss.Default.Misses(query.Get("proxy"), query.Get("session")))
+	case http.MethodDelete:
+		nearmiss.Default.Reset()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
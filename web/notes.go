@@ -0,0 +1,110 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"mimic/storage"
+)
+
+// handleCreateSession creates an empty session (POST /api/sessions)
+func (s *Server) handleCreateSession(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		SessionName string `json:"session_name"`
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	req.SessionName = strings.TrimSpace(req.SessionName)
+	if req.SessionName == "" {
+		http.Error(w, "session_name is required", http.StatusBadRequest)
+		return
+	}
+	if _, err := s.database.GetSession(req.SessionName); err == nil {
+		http.Error(w, fmt.Sprintf("session already exists: %s", req.SessionName), http.StatusConflict)
+		return
+	}
+
+	session, err := s.database.CreateSession(req.SessionName, req.Description)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(session)
+}
+
+// handleSessionDescription updates a session's description (PUT /api/sessions/{id})
+func (s *Server) handleSessionDescription(w http.ResponseWriter, r *http.Request, sessionID int) {
+	var req struct {
+		Description string `json:"description"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := s.database.UpdateSessionDescription(sessionID, req.Description); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	session, err := s.database.GetSessionByID(sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(session)
+}
+
+// handleInteractionAnnotation sets or clears an interaction's note (PUT /api/interactions/{id}/annotation)
+func (s *Server) handleInteractionAnnotation(w http.ResponseWriter, r *http.Request, interactionID int) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req struct {
+		Annotation string `json:"annotation"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	// An empty annotation removes the note entirely
+	var value interface{}
+	if annotation := strings.TrimSpace(req.Annotation); annotation != "" {
+		value = annotation
+	}
+
+	if _, err := s.database.GetInteraction(interactionID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := s.database.UpdateInteractionMetadata(interactionID, map[string]interface{}{
+		storage.MetadataAnnotation: value,
+	}); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	interaction, err := s.database.GetInteraction(interactionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.redactInteraction(interaction)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(interaction)
+}
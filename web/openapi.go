@@ -0,0 +1,18 @@
+package web
+
+import (
+	_ "embed"
+	"net/http"
+)
+
+// openAPIDocument describes the admin API; keep it in sync with registerAPIRoutes and the client
+// package, whose tests fail on an operation without a client method
+//
+//go:embed openapi.json
+var openAPIDocument []byte
+
+// handleOpenAPI serves the OpenAPI description of the admin API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(openAPIDocument)
+}
@@ -0,0 +1,58 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"mimic/pii"
+)
+
+// piiRedactRequest is the body of POST /api/sessions/{id}/pii/redact
+type piiRedactRequest struct {
+	Kinds []string `json:"kinds"` // Empty redacts every kind
+}
+
+// handleSessionPII scans a session for likely personal data (GET /api/sessions/{id}/pii), or
+// redacts it in place (POST /api/sessions/{id}/pii/redact)
+func (s *Server) handleSessionPII(w http.ResponseWriter, r *http.Request, sessionID int, action string) {
+	session, err := s.database.GetSessionByID(sessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		report, err := pii.ScanSession(s.database, session.SessionName)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(report)
+	case action == "redact" && r.Method == http.MethodPost:
+		var req piiRedactRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+			return
+		}
+		if err := pii.ValidateKinds(req.Kinds); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		result, err := pii.RedactSession(s.database, session.SessionName, req.Kinds)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		s.BroadcastEvent("session_redacted", result)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	case action == "" || action == "redact":
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	default:
+		http.NotFound(w, r)
+	}
+}
@@ -0,0 +1,360 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"mimic/config"
+	"mimic/replay"
+	"mimic/webhook"
+)
+
+// maxReplayRuns bounds how many finished replay runs are kept in memory for browsing
+const maxReplayRuns = 20
+
+// ReplayRun tracks a replay launched from the web UI
+type ReplayRun struct {
+	ID          string                `json:"id"`
+	Status      string                `json:"status"` // "running", "completed", or "failed"
+	SessionName string                `json:"session_name"`
+	TargetHost  string                `json:"target_host"`
+	TargetPort  int                   `json:"target_port"`
+	Protocol    string                `json:"protocol"`
+	Strategy    string                `json:"matching_strategy"`
+	Completed   int                   `json:"completed"`
+	Total       int                   `json:"total"`
+	Failures    int                   `json:"failures"`
+	Error       string                `json:"error,omitempty"`
+	StartedAt   time.Time             `json:"started_at"`
+	FinishedAt  *time.Time            `json:"finished_at,omitempty"`
+	Result      *replay.ReplaySession `json:"result,omitempty"`
+}
+
+// ReplayRunRequest is the body accepted by POST /api/replay/runs; zero values keep the configured replay settings
+type ReplayRunRequest struct {
+	SessionName        string `json:"session_name"`
+	TargetHost         string `json:"target_host"`
+	TargetPort         int    `json:"target_port"`
+	Protocol           string `json:"protocol"`
+	MatchingStrategy   string `json:"matching_strategy"`
+	FailFast           bool   `json:"fail_fast"`
+	TimeoutSeconds     int    `json:"timeout_seconds"`
+	MaxConcurrency     int    `json:"max_concurrency"`
+	IgnoreTimestamps   bool   `json:"ignore_timestamps"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	GRPCInsecure       bool   `json:"grpc_insecure"`
+}
+
+// replayProgressEvent is broadcast after every replayed interaction
+type replayProgressEvent struct {
+	RunID           string `json:"run_id"`
+	SessionName     string `json:"session_name"`
+	Completed       int    `json:"completed"`
+	Total           int    `json:"total"`
+	Method          string `json:"method"`
+	Endpoint        string `json:"endpoint"`
+	Success         bool   `json:"success"`
+	ValidationError string `json:"validation_error,omitempty"`
+}
+
+type replayRunStore struct {
+	mutex sync.RWMutex
+	runs  map[string]*ReplayRun
+}
+
+func newReplayRunStore() *replayRunStore {
+	return &replayRunStore{runs: make(map[string]*ReplayRun)}
+}
+
+func (rs *replayRunStore) add(run *ReplayRun) {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	rs.runs[run.ID] = run
+
+	// Evict the oldest finished runs once we exceed the limit
+	if len(rs.runs) > maxReplayRuns {
+		runs := make([]*ReplayRun, 0, len(rs.runs))
+		for _, r := range rs.runs {
+			runs = append(runs, r)
+		}
+		sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.Before(runs[j].StartedAt) })
+		for _, r := range runs {
+			if len(rs.runs) <= maxReplayRuns {
+				break
+			}
+			if r.Status != "running" {
+				delete(rs.runs, r.ID)
+			}
+		}
+	}
+}
+
+// update applies fn to the run under the store lock and returns a snapshot of the result
+func (rs *replayRunStore) update(id string, fn func(run *ReplayRun)) ReplayRun {
+	rs.mutex.Lock()
+	defer rs.mutex.Unlock()
+
+	run := rs.runs[id]
+	fn(run)
+	return *run
+}
+
+func (rs *replayRunStore) get(id string) (ReplayRun, bool) {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	run, ok := rs.runs[id]
+	if !ok {
+		return ReplayRun{}, false
+	}
+	return *run, true
+}
+
+// list returns summaries of all runs, newest first, without per-interaction results
+func (rs *replayRunStore) list() []ReplayRun {
+	rs.mutex.RLock()
+	defer rs.mutex.RUnlock()
+
+	runs := make([]ReplayRun, 0, len(rs.runs))
+	for _, run := range rs.runs {
+		summary := *run
+		summary.Result = nil
+		runs = append(runs, summary)
+	}
+	sort.Slice(runs, func(i, j int) bool { return runs[i].StartedAt.After(runs[j].StartedAt) })
+	return runs
+}
+
+// handleReplayRuns lists replay runs (GET) or launches a new one (POST)
+func (s *Server) handleReplayRuns(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(s.replayRuns.list())
+	case http.MethodPost:
+		s.startReplayRun(w, r)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleReplayRunDetail returns a single replay run including its per-interaction results
+func (s *Server) handleReplayRunDetail(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id := strings.TrimPrefix(r.URL.Path, "/api/replay/runs/")
+	run, ok := s.replayRuns.get(id)
+	if !ok {
+		http.Error(w, "Replay run not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(run)
+}
+
+func (s *Server) startReplayRun(w http.ResponseWriter, r *http.Request) {
+	var req ReplayRunRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	run, err := s.StartReplay(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(run)
+}
+
+// StartReplay launches a replay run in the background; errors describe an invalid request
+func (s *Server) StartReplay(req ReplayRunRequest) (ReplayRun, error) {
+	replayConfig := s.buildReplayConfig(req)
+	if err := validateReplayConfig(replayConfig); err != nil {
+		return ReplayRun{}, err
+	}
+
+	engine, err := replay.NewReplayEngine(replayConfig, s.database)
+	if err != nil {
+		return ReplayRun{}, fmt.Errorf("failed to create replay engine: %w", err)
+	}
+
+	started := s.beginReplayRun(engine, replayConfig)
+	go s.executeReplayRun(started.ID, engine, replayConfig, "api")
+
+	return started, nil
+}
+
+// RunReplay replays as a tracked run and waits for it to finish, for replays started outside the
+// web API. Its progress is broadcast and kept the same way as for runs started with StartReplay.
+func (s *Server) RunReplay(engine *replay.ReplayEngine, replayConfig *config.ReplayConfig, source string) (*replay.ReplaySession, error) {
+	started := s.beginReplayRun(engine, replayConfig)
+	return s.executeReplayRun(started.ID, engine, replayConfig, source)
+}
+
+// beginReplayRun registers a run for a replay about to start and broadcasts its progress
+func (s *Server) beginReplayRun(engine *replay.ReplayEngine, replayConfig *config.ReplayConfig) ReplayRun {
+	run := &ReplayRun{
+		ID:          uuid.New().String(),
+		Status:      "running",
+		SessionName: replayConfig.SessionName,
+		TargetHost:  replayConfig.TargetHost,
+		TargetPort:  replayConfig.TargetPort,
+		Protocol:    replayConfig.Protocol,
+		Strategy:    replayConfig.MatchingStrategy,
+		StartedAt:   time.Now(),
+	}
+	s.replayRuns.add(run)
+
+	engine.SetProgressCallback(func(result *replay.ReplayResult, completed, total int) {
+		s.replayRuns.update(run.ID, func(run *ReplayRun) {
+			run.Completed = completed
+			run.Total = total
+			if !result.Success {
+				run.Failures++
+			}
+		})
+
+		s.BroadcastEvent("replay_progress", replayProgressEvent{
+			RunID:           run.ID,
+			SessionName:     replayConfig.SessionName,
+			Completed:       completed,
+			Total:           total,
+			Method:          result.Interaction.Method,
+			Endpoint:        result.Interaction.Endpoint,
+			Success:         result.Success,
+			ValidationError: result.ValidationError,
+		})
+	})
+
+	started := *run
+	s.BroadcastEvent("replay_started", started)
+	return started
+}
+
+// ReplayRuns returns summaries of the retained replay runs, newest first
+func (s *Server) ReplayRuns() []ReplayRun {
+	return s.replayRuns.list()
+}
+
+// ReplayRunByID returns a replay run including its per-interaction results
+func (s *Server) ReplayRunByID(id string) (ReplayRun, bool) {
+	return s.replayRuns.get(id)
+}
+
+// executeReplayRun replays a registered run, recording and announcing how it ended; source names
+// where the replay was started for the webhook
+func (s *Server) executeReplayRun(id string, engine *replay.ReplayEngine, replayConfig *config.ReplayConfig, source string) (*replay.ReplaySession, error) {
+	log.Printf("Starting replay run %s of session '%s' against %s://%s:%d",
+		id, replayConfig.SessionName, replayConfig.Protocol, replayConfig.TargetHost, replayConfig.TargetPort)
+
+	replaySession, err := engine.Replay()
+
+	summary := s.replayRuns.update(id, func(run *ReplayRun) {
+		now := time.Now()
+		run.FinishedAt = &now
+		run.Result = replaySession
+		run.Status = "completed"
+		if err != nil {
+			run.Error = err.Error()
+			if replaySession == nil {
+				run.Status = "failed"
+			}
+		}
+		if replaySession != nil {
+			run.Total = replaySession.TotalRequests
+			run.Failures = replaySession.FailureCount
+		}
+	})
+	summary.Result = nil
+
+	s.BroadcastEvent("replay_completed", summary)
+
+	finished := webhook.ReplayFinishedEvent{
+		RunID:    id,
+		Source:   source,
+		Session:  summary.SessionName,
+		Target:   fmt.Sprintf("%s://%s:%d", summary.Protocol, summary.TargetHost, summary.TargetPort),
+		Total:    summary.Total,
+		Failures: summary.Failures,
+		Error:    summary.Error,
+	}
+	if replaySession != nil {
+		finished.Successes = replaySession.SuccessCount
+		finished.DurationMs = replaySession.Duration.Milliseconds()
+	}
+	webhook.ReplayFinished(finished)
+	log.Printf("Replay run %s finished: %s (%d/%d, %d failed)", id, summary.Status, summary.Completed, summary.Total, summary.Failures)
+	return replaySession, err
+}
+
+// buildReplayConfig overlays a UI request on top of the configured replay defaults
+func (s *Server) buildReplayConfig(req ReplayRunRequest) *config.ReplayConfig {
+	replayConfig := s.config.Replay // Copy the config
+
+	if req.SessionName != "" {
+		replayConfig.SessionName = req.SessionName
+	}
+	if req.TargetHost != "" {
+		replayConfig.TargetHost = req.TargetHost
+	}
+	if req.TargetPort != 0 {
+		replayConfig.TargetPort = req.TargetPort
+	}
+	if req.Protocol != "" {
+		replayConfig.Protocol = req.Protocol
+	}
+	if req.MatchingStrategy != "" {
+		replayConfig.MatchingStrategy = req.MatchingStrategy
+	}
+	if req.TimeoutSeconds > 0 {
+		replayConfig.TimeoutSeconds = req.TimeoutSeconds
+	}
+	if replayConfig.TimeoutSeconds <= 0 {
+		replayConfig.TimeoutSeconds = 30
+	}
+	replayConfig.FailFast = req.FailFast
+	replayConfig.MaxConcurrency = req.MaxConcurrency
+	replayConfig.IgnoreTimestamps = req.IgnoreTimestamps
+	replayConfig.InsecureSkipVerify = req.InsecureSkipVerify
+	replayConfig.GRPCInsecure = req.GRPCInsecure
+
+	return &replayConfig
+}
+
+func validateReplayConfig(replayConfig *config.ReplayConfig) error {
+	if replayConfig.SessionName == "" {
+		return fmt.Errorf("session_name is required")
+	}
+	if replayConfig.TargetHost == "" {
+		return fmt.Errorf("target_host is required")
+	}
+	if replayConfig.TargetPort <= 0 || replayConfig.TargetPort > 65535 {
+		return fmt.Errorf("invalid target_port: %d", replayConfig.TargetPort)
+	}
+	if replayConfig.Protocol != "http" && replayConfig.Protocol != "https" && replayConfig.Protocol != "grpc" {
+		return fmt.Errorf("invalid protocol: %s (must be 'http', 'https', or 'grpc')", replayConfig.Protocol)
+	}
+	if replayConfig.MatchingStrategy != "exact" && replayConfig.MatchingStrategy != "fuzzy" && replayConfig.MatchingStrategy != "status_code" && replayConfig.MatchingStrategy != "contract" {
+		return fmt.Errorf("invalid matching_strategy: %s (must be 'exact', 'fuzzy', 'status_code', or 'contract')", replayConfig.MatchingStrategy)
+	}
+	if replayConfig.MaxConcurrency < 0 {
+		return fmt.Errorf("max_concurrency cannot be negative")
+	}
+	return nil
+}
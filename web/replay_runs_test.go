@@ -0,0 +1,84 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"mimic/config"
+	"mimic/replay"
+	"mimic/storage"
+)
+
+func TestRunReplayTracksRun(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id":1}`))
+	}))
+	defer upstream.Close()
+	target, _ := url.Parse(upstream.URL)
+	port, _ := strconv.Atoi(target.Port())
+
+	db, err := storage.NewMemoryDatabase()
+	if err != nil {
+		t.Fatalf("Failed to create database: %v", err)
+	}
+	defer db.Close()
+	session, err := db.CreateSession("users", "")
+	if err != nil {
+		t.Fatalf("Failed to create session: %v", err)
+	}
+	interaction := &storage.Interaction{SessionID: session.ID, RequestID: "req-1", Protocol: "REST", Method: "GET", Endpoint: "/users/1",
+		RequestHeaders: "{}", ResponseStatus: 200, ResponseHeaders: "{}", ResponseBody: []byte(`{"id":1}`), Timestamp: time.Now()}
+	if err := db.RecordInteraction(interaction); err != nil {
+		t.Fatalf("Failed to record interaction: %v", err)
+	}
+
+	s := NewServer(config.DefaultConfig(), db)
+	go s.handleBroadcast()
+	defer close(s.broadcast)
+	client := newLiveClient("websocket", EventFilter{})
+	s.addClient(client)
+	defer s.removeClient(client)
+
+	replayConfig := &config.ReplayConfig{SessionName: "users", TargetHost: target.Hostname(), TargetPort: port, Protocol: "http",
+		MatchingStrategy: "status_code", TimeoutSeconds: 5, IgnoreTimestamps: true}
+	engine, err := replay.NewReplayEngine(replayConfig, db)
+	if err != nil {
+		t.Fatalf("Failed to create replay engine: %v", err)
+	}
+	replaySession, err := s.RunReplay(engine, replayConfig, "proxy")
+	if err != nil || replaySession.SuccessCount != 1 {
+		t.Fatalf("Expected the replay to succeed, got %v (%v)", replaySession, err)
+	}
+
+	runs := s.ReplayRuns()
+	if len(runs) != 1 || runs[0].Status != "completed" || runs[0].Completed != 1 {
+		t.Fatalf("Expected one completed run, got %+v", runs)
+	}
+
+	// Progress carries the run ID, as for runs started from the UI
+	for _, expected := range []string{"replay_started", "replay_progress", "replay_completed"} {
+		select {
+		case payload := <-client.send:
+			var message struct {
+				Type string                 `json:"type"`
+				Data map[string]interface{} `json:"data"`
+			}
+			if err := json.Unmarshal(payload, &message); err != nil {
+				t.Fatalf("Failed to decode event: %v", err)
+			}
+			if message.Type != expected {
+				t.Fatalf("Expected a %s event, got %s", expected, payload)
+			}
+			if expected == "replay_progress" && message.Data["run_id"] != runs[0].ID {
+				t.Errorf("Expected progress for run %s, got %s", runs[0].ID, payload)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Timed out waiting for a %s event", expected)
+		}
+	}
+}
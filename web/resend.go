@@ -0,0 +1,117 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+
+	"mimic/replay"
+)
+
+// resendRequest is the body accepted by POST /api/interactions/{id}/resend
+type resendRequest struct {
+	// Proxy sends the request back through the named mimic proxy instead of an explicit target
+	Proxy              string `json:"proxy"`
+	TargetHost         string `json:"target_host"`
+	TargetPort         int    `json:"target_port"`
+	Protocol           string `json:"protocol"`
+	BasePath           string `json:"base_path"`
+	MatchingStrategy   string `json:"matching_strategy"`
+	TimeoutSeconds     int    `json:"timeout_seconds"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	GRPCInsecure       bool   `json:"grpc_insecure"`
+}
+
+// handleResendInteraction sends one recorded request to a target and returns the live response
+func (s *Server) handleResendInteraction(w http.ResponseWriter, r *http.Request, id int) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req resendRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	interaction, err := s.database.GetInteraction(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	session, err := s.database.GetSessionByID(interaction.SessionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	runReq := ReplayRunRequest{
+		SessionName:        session.SessionName,
+		TargetHost:         req.TargetHost,
+		TargetPort:         req.TargetPort,
+		Protocol:           req.Protocol,
+		MatchingStrategy:   req.MatchingStrategy,
+		TimeoutSeconds:     req.TimeoutSeconds,
+		IgnoreTimestamps:   true,
+		InsecureSkipVerify: req.InsecureSkipVerify,
+		GRPCInsecure:       req.GRPCInsecure,
+	}
+	basePath := req.BasePath
+
+	if req.Proxy != "" {
+		if _, ok := s.config.Proxies[req.Proxy]; !ok {
+			http.Error(w, fmt.Sprintf("Unknown proxy: %s", req.Proxy), http.StatusBadRequest)
+			return
+		}
+		runReq.TargetHost = s.localHost()
+		// With server.tls the listeners serve their own certificate, which need not be trusted here
+		tlsEnabled := s.config.Server.TLS.Enabled()
+		runReq.InsecureSkipVerify = tlsEnabled
+		if interaction.Protocol == "gRPC" {
+			runReq.TargetPort = s.config.Server.GRPCPort
+			runReq.Protocol = "grpc"
+			runReq.GRPCInsecure = !tlsEnabled
+		} else {
+			runReq.TargetPort = s.config.Server.ListenPort
+			runReq.Protocol = "http"
+			if tlsEnabled {
+				runReq.Protocol = "https"
+			}
+			basePath = "/proxy/" + req.Proxy
+		}
+	}
+
+	replayConfig := s.buildReplayConfig(runReq)
+	replayConfig.BasePath = basePath
+	if err := validateReplayConfig(replayConfig); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	engine, err := replay.NewReplayEngine(replayConfig, s.database)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to create replay engine: %v", err), http.StatusBadRequest)
+		return
+	}
+	defer engine.Close()
+
+	log.Printf("Resending interaction %d (%s %s) to %s://%s:%d%s",
+		id, interaction.Method, interaction.Endpoint, replayConfig.Protocol, replayConfig.TargetHost, replayConfig.TargetPort, basePath)
+
+	result := engine.ReplayInteraction(interaction)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// localHost returns an address that reaches this mimic instance's own listeners
+func (s *Server) localHost() string {
+	host := s.config.Server.ListenHost
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		return "localhost"
+	}
+	return host
+}
@@ -0,0 +1,143 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"mimic/storage"
+)
+
+// ScenarioEntry is the current state of one scenario of a mock proxy
+type ScenarioEntry struct {
+	Proxy    string `json:"proxy"`
+	Scenario string `json:"scenario"`
+	State    string `json:"state"`
+}
+
+// scenarioResetRequest is the body accepted by POST /api/scenarios/reset
+type scenarioResetRequest struct {
+	Proxy    string `json:"proxy"`    // Empty resets every mock proxy
+	Scenario string `json:"scenario"` // Empty resets every scenario of the proxy
+}
+
+// interactionScenarioRequest is the body accepted by PUT /api/interactions/{id}/scenario
+type interactionScenarioRequest struct {
+	Scenario      string `json:"scenario"`
+	RequiredState string `json:"required_state"`
+	NewState      string `json:"new_state"`
+}
+
+// handleScenarios lists the scenario states of the mock proxies (GET) or moves one scenario to a
+// state (PUT, with a ScenarioEntry body)
+func (s *Server) handleScenarios(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		entries := []ScenarioEntry{}
+		if s.admin != nil {
+			entries = s.admin.ScenarioStates()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(entries)
+	case http.MethodPut:
+		if s.admin == nil {
+			http.Error(w, "Scenario control is not available", http.StatusServiceUnavailable)
+			return
+		}
+		var req ScenarioEntry
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Proxy == "" || req.Scenario == "" || req.State == "" {
+			http.Error(w, "Invalid request body: proxy, scenario, and state are required", http.StatusBadRequest)
+			return
+		}
+		if err := s.admin.SetScenarioState(req.Proxy, req.Scenario, req.State); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.BroadcastEvent("scenario_state_changed", req)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(req)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScenarioReset returns scenarios to their starting state
+func (s *Server) handleScenarioReset(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if s.admin == nil {
+		http.Error(w, "Scenario control is not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	var req scenarioResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	reset, err := s.admin.ResetScenarios(req.Proxy, req.Scenario)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	s.BroadcastEvent("scenario_reset", map[string]interface{}{
+		"proxy":    req.Proxy,
+		"scenario": req.Scenario,
+		"reset":    reset,
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"reset": reset})
+}
+
+// handleInteractionScenario sets the scenario state an interaction is served in and the state
+// serving it moves to. Empty fields are removed.
+func (s *Server) handleInteractionScenario(w http.ResponseWriter, r *http.Request, interactionID int) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req interactionScenarioRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	values := make(map[string]interface{})
+	for key, value := range map[string]string{
+		storage.MetadataScenario:      req.Scenario,
+		storage.MetadataRequiredState: req.RequiredState,
+		storage.MetadataNewState:      req.NewState,
+	} {
+		values[key] = nil
+		if value = strings.TrimSpace(value); value != "" {
+			values[key] = value
+		}
+	}
+
+	if _, err := s.database.GetInteraction(interactionID); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err := s.database.UpdateInteractionMetadata(interactionID, values); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	interaction, err := s.database.GetInteraction(interactionID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.redactInteraction(interaction)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(interaction)
+}
@@ -5,22 +5,32 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"sort"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"mimic/config"
+	"mimic/consumer"
+	"mimic/metrics"
+	"mimic/redact"
 	"mimic/storage"
+	"mimic/tag"
+	"mimic/webhook"
 )
 
 type Server struct {
 	config     *config.Config
 	database   *storage.Database
 	upgrader   websocket.Upgrader
-	clients    map[*websocket.Conn]bool
+	clients    map[*liveClient]bool
 	clientsMux sync.RWMutex
-	broadcast  chan []byte
+	broadcast  chan *broadcastMessage
+	replayRuns *replayRunStore
+	admin      AdminController
+	redactor   *redact.Redactor // Masks secrets in the traffic and recordings the UI shows
 }
 
 type Message struct {
@@ -31,6 +41,7 @@ type Message struct {
 
 type RequestResponseEvent struct {
 	Type        string                 `json:"type"` // "request" or "response"
+	ProxyName   string                 `json:"proxy_name,omitempty"`
 	Method      string                 `json:"method"`
 	Endpoint    string                 `json:"endpoint"`
 	Headers     map[string]interface{} `json:"headers"`
@@ -42,16 +53,20 @@ type RequestResponseEvent struct {
 }
 
 func NewServer(cfg *config.Config, db *storage.Database) *Server {
+	// Unknown presets are rejected when the config is loaded
+	redactor, _ := redact.New(cfg.Recording.RedactPatterns, cfg.Recording.RedactPresets)
 	return &Server{
 		config:   cfg,
 		database: db,
+		redactor: redactor,
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
 				return true // Allow all origins for development
 			},
 		},
-		clients:   make(map[*websocket.Conn]bool),
-		broadcast: make(chan []byte),
+		clients:    make(map[*liveClient]bool),
+		broadcast:  make(chan *broadcastMessage, clientSendBuffer),
+		replayRuns: newReplayRunStore(),
 	}
 }
 
@@ -69,13 +84,10 @@ func (s *Server) Start() error {
 	mux.HandleFunc("/", s.handleHome)
 
 	// WebSocket endpoint
-	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/ws", s.authorize(s.handleWebSocket))
 
 	// API endpoints
-	mux.HandleFunc("/api/sessions", s.handleSessions)
-	mux.HandleFunc("/api/sessions/", s.handleSessionDetail)
-	mux.HandleFunc("/api/interactions/", s.handleInteractions)
-	mux.HandleFunc("/api/clear", s.handleClear)
+	s.registerAPIRoutes(mux)
 
 	address := fmt.Sprintf("%s:%d", s.config.Server.ListenHost, s.config.Server.ListenPort) // Use same port as server
 	log.Printf("Starting web UI on http://%s", address)
@@ -94,17 +106,50 @@ func (s *Server) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("/", s.handleHome)
 
 	// WebSocket endpoint at /ws
-	mux.HandleFunc("/ws", s.handleWebSocket)
+	mux.HandleFunc("/ws", s.authorize(s.handleWebSocket))
 
 	// API endpoints at /api/
-	mux.HandleFunc("/api/sessions", s.handleSessions)
-	mux.HandleFunc("/api/sessions/", s.handleSessionDetail)
-	mux.HandleFunc("/api/interactions/", s.handleInteractions)
-	mux.HandleFunc("/api/clear", s.handleClear)
+	s.registerAPIRoutes(mux)
 
 	log.Printf("Web UI registered at top level")
 }
 
+// registerAPIRoutes adds the JSON API endpoints shared by Start and RegisterRoutes
+func (s *Server) registerAPIRoutes(mux *http.ServeMux) {
+	mux.HandleFunc("/api/auth", s.handleAuth)
+	mux.HandleFunc("/api/events", s.authorize(s.handleEventStream))
+	mux.HandleFunc("/api/sessions", s.authorize(s.handleSessions))
+	mux.HandleFunc("/api/sessions/", s.authorize(s.handleSessionDetail))
+	mux.HandleFunc("/api/interactions/", s.authorize(s.handleInteractions))
+	mux.HandleFunc("/api/clear", s.authorize(s.handleClear))
+	mux.HandleFunc("/api/proxies", s.authorize(s.handleProxies))
+	mux.HandleFunc("/api/proxies/", s.authorize(s.handleProxyDetail))
+	mux.HandleFunc("/api/replay/runs", s.authorize(s.handleReplayRuns))
+	mux.HandleFunc("/api/replay/runs/", s.authorize(s.handleReplayRunDetail))
+	mux.HandleFunc("/api/sequences", s.authorize(s.handleSequences))
+	mux.HandleFunc("/api/metrics", s.authorize(s.handleMetrics))
+	mux.HandleFunc("/metrics", s.authorize(s.handlePrometheusMetrics))
+	mux.HandleFunc("/api/sequences/reset", s.authorize(s.handleSequenceReset))
+	mux.HandleFunc("/api/scenarios", s.authorize(s.handleScenarios))
+	mux.HandleFunc("/api/scenarios/reset", s.authorize(s.handleScenarioReset))
+	mux.HandleFunc("/api/mode", s.authorize(s.handleMode))
+	mux.HandleFunc("/api/intercept/breakpoints", s.authorize(s.handleBreakpoints))
+	mux.HandleFunc("/api/intercept/breakpoints/", s.authorize(s.handleBreakpointDetail))
+	mux.HandleFunc("/api/intercept/paused", s.authorize(s.handlePaused))
+	mux.HandleFunc("/api/intercept/paused/", s.authorize(s.handlePausedDetail))
+	mux.HandleFunc("/api/calls", s.authorize(s.handleCalls))
+	mux.HandleFunc("/api/calls/checkpoint", s.authorize(s.handleCallCheckpoint))
+	mux.HandleFunc("/api/calls/count", s.authorize(s.handleCallCount))
+	mux.HandleFunc("/api/calls/verify", s.authorize(s.handleCallVerify))
+	mux.HandleFunc("/api/verify", s.authorize(s.handleCallVerify))
+	mux.HandleFunc("/api/clock", s.authorize(s.handleClock))
+	mux.HandleFunc("/api/clock/advance", s.authorize(s.handleClockAdvance))
+	mux.HandleFunc("/api/conformance", s.authorize(s.handleConformance))
+	mux.HandleFunc("/api/match-misses", s.authorize(s.handleMatchMisses))
+	mux.HandleFunc("/api/stubs", s.authorize(s.handleStubs))
+	mux.HandleFunc("/api/openapi.json", s.handleOpenAPI)
+}
+
 func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 	html := `<!DOCTYPE html>
 <html lang="en">
@@ -132,9 +177,38 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
                         Loading...
                     </div>
                     <button id="refresh-sessions" class="btn">Refresh</button>
-                    <button id="clear-all" class="btn btn-danger">Clear All</button>
+                    <button id="clear-all" class="btn btn-danger admin-only">Clear All</button>
                 </div>
                 
+                <div class="section">
+                    <h3>Live Filter</h3>
+                    <div class="live-filter">
+                        <select id="filter-proxy">
+                            <option value="">All proxies</option>
+                        </select>
+                        <input type="text" id="filter-session" placeholder="Session">
+                        <select id="filter-method">
+                            <option value="">All methods</option>
+                            <option>GET</option>
+                            <option>POST</option>
+                            <option>PUT</option>
+                            <option>PATCH</option>
+                            <option>DELETE</option>
+                        </select>
+                        <input type="text" id="filter-endpoint" placeholder="Endpoint regex">
+                    </div>
+                    <button id="apply-filter" class="btn">Apply</button>
+                    <button id="reset-filter" class="btn">Reset</button>
+                    <div id="filter-status" class="filter-status"></div>
+                </div>
+
+                <div class="section" id="auth-section" style="display: none;">
+                    <h3>Access</h3>
+                    <input type="password" id="auth-token" class="auth-token" placeholder="Access token">
+                    <button id="save-token" class="btn">Use Token</button>
+                    <div id="auth-role" class="filter-status"></div>
+                </div>
+
                 <div class="section">
                     <h3>Controls</h3>
                     <button id="clear-events" class="btn">Clear Events</button>
@@ -148,6 +222,12 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
                 <div class="tabs">
                     <button class="tab-btn active" data-tab="events">Live Events</button>
                     <button class="tab-btn" data-tab="interactions">Interactions</button>
+                    <button class="tab-btn" data-tab="replay">Replay</button>
+                    <button class="tab-btn" data-tab="timeline">Timeline</button>
+                    <button class="tab-btn" data-tab="sequences">Sequences</button>
+                    <button class="tab-btn" data-tab="metrics">Metrics</button>
+                    <button class="tab-btn" data-tab="intercept">Intercept <span id="paused-count" class="badge"></span></button>
+                    <button class="tab-btn" data-tab="conformance">Conformance <span id="violation-count" class="badge"></span></button>
                 </div>
 
                 <div id="events-tab" class="tab-content active">
@@ -171,6 +251,142 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
                         Loading...
                     </div>
                 </div>
+
+                <div id="replay-tab" class="tab-content">
+                    <div class="replay-header">
+                        <h3>Replay Sessions</h3>
+                    </div>
+                    <form id="replay-form" class="replay-form admin-only">
+                        <label>Session
+                            <select id="replay-session" required></select>
+                        </label>
+                        <label>Target host
+                            <input type="text" id="replay-target-host" placeholder="api.example.com" required>
+                        </label>
+                        <label>Port
+                            <input type="number" id="replay-target-port" value="443" min="1" max="65535" required>
+                        </label>
+                        <label>Protocol
+                            <select id="replay-protocol">
+                                <option value="https">https</option>
+                                <option value="http">http</option>
+                                <option value="grpc">grpc</option>
+                            </select>
+                        </label>
+                        <label>Strategy
+                            <select id="replay-strategy">
+                                <option value="exact">exact</option>
+                                <option value="fuzzy">fuzzy</option>
+                                <option value="status_code">status_code</option>
+                                <option value="contract">contract</option>
+                            </select>
+                        </label>
+                        <label>Concurrency
+                            <input type="number" id="replay-concurrency" value="0" min="0">
+                        </label>
+                        <label><input type="checkbox" id="replay-ignore-timestamps" checked> Ignore timestamps</label>
+                        <label><input type="checkbox" id="replay-fail-fast"> Fail fast</label>
+                        <label><input type="checkbox" id="replay-grpc-insecure"> gRPC insecure</label>
+                        <button type="submit" class="btn">Start Replay</button>
+                    </form>
+                    <div id="replay-progress" class="replay-progress"></div>
+                    <div class="replay-body">
+                        <div id="replay-runs" class="replay-runs"></div>
+                        <div id="replay-results" class="replay-results">
+                            <div class="no-events">Select a run to browse its results.</div>
+                        </div>
+                    </div>
+                </div>
+
+                <div id="timeline-tab" class="tab-content">
+                    <div class="interactions-header">
+                        <h3>Session Timeline</h3>
+                        <select id="timeline-session"></select>
+                    </div>
+                    <div class="timeline-legend">
+                        <span class="timeline-swatch timeline-bar"></span> Request
+                        <span class="timeline-swatch timeline-bar streaming"></span> Streaming
+                        <span class="timeline-swatch timeline-bar error"></span> 4xx/5xx
+                    </div>
+                    <div id="timeline-content" class="timeline-content">
+                        <div class="no-events">Select a session to see its timeline.</div>
+                    </div>
+                </div>
+
+                <div id="sequences-tab" class="tab-content">
+                    <div class="interactions-header">
+                        <h3>Mock Sequence State</h3>
+                        <div>
+                            <button id="refresh-sequences" class="btn">Refresh</button>
+                            <button id="reset-all-sequences" class="btn btn-danger admin-only">Reset All</button>
+                        </div>
+                    </div>
+                    <div id="sequences-list" class="interactions-list">
+                        Loading...
+                    </div>
+                </div>
+
+                <div id="intercept-tab" class="tab-content">
+                    <div class="interactions-header">
+                        <h3>Breakpoints</h3>
+                    </div>
+                    <form id="breakpoint-form" class="replay-form admin-only">
+                        <label>Proxy
+                            <select id="breakpoint-proxy">
+                                <option value="">All proxies</option>
+                            </select>
+                        </label>
+                        <label>Method
+                            <input type="text" id="breakpoint-method" placeholder="Any">
+                        </label>
+                        <label>Endpoint regex
+                            <input type="text" id="breakpoint-endpoint" placeholder="^/api/orders">
+                        </label>
+                        <label>Stage
+                            <select id="breakpoint-stage">
+                                <option value="request">request</option>
+                                <option value="response">response</option>
+                                <option value="both">both</option>
+                            </select>
+                        </label>
+                        <button type="submit" class="btn">Add Breakpoint</button>
+                    </form>
+                    <div id="breakpoints-list" class="breakpoints-list"></div>
+                    <h3>Paused</h3>
+                    <div id="paused-list" class="interactions-list">
+                        <div class="no-events">Nothing is paused.</div>
+                    </div>
+                </div>
+
+                <div id="conformance-tab" class="tab-content">
+                    <div class="interactions-header">
+                        <h3>Check Session Against OpenAPI Spec</h3>
+                        <div>
+                            <select id="conformance-session"></select>
+                            <button id="check-conformance" class="btn">Check</button>
+                        </div>
+                    </div>
+                    <div id="conformance-report" class="interactions-list">
+                        <div class="no-events">Pick a session to check it against its proxy's openapi_spec.</div>
+                    </div>
+                    <div class="interactions-header">
+                        <h3>Live Violations</h3>
+                        <button id="clear-violations" class="btn btn-danger admin-only">Clear</button>
+                    </div>
+                    <div id="violations-list" class="interactions-list">
+                        <div class="no-events">No violations seen.</div>
+                    </div>
+                </div>
+
+                <div id="metrics-tab" class="tab-content">
+                    <div class="interactions-header">
+                        <h3>Proxy Metrics</h3>
+                        <span id="metrics-window" class="event-count"></span>
+                    </div>
+                    <div id="metrics-content" class="metrics-content">
+                        Loading...
+                    </div>
+                </div>
             </div>
         </div>
     </div>
@@ -191,6 +407,13 @@ func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	// An initial filter may be passed as query parameters and changed later with a subscribe command
+	filter, err := filterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		log.Printf("WebSocket upgrade error: %v", err)
@@ -198,38 +421,61 @@ func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 	defer conn.Close()
 
-	s.clientsMux.Lock()
-	s.clients[conn] = true
-	s.clientsMux.Unlock()
+	client := newLiveClient("websocket", filter)
+	s.addClient(client)
 
 	log.Printf("WebSocket client connected from %s", r.RemoteAddr)
 
 	defer func() {
-		s.clientsMux.Lock()
-		delete(s.clients, conn)
-		s.clientsMux.Unlock()
+		s.removeClient(client)
 		log.Printf("WebSocket client disconnected")
 	}()
 
-	// Keep connection alive
+	// Writer: drain the client's queue onto the socket
+	replies := make(chan []byte, 1)
+	go func() {
+		for {
+			var message []byte
+			var ok bool
+			select {
+			case message, ok = <-client.send:
+			case message, ok = <-replies:
+			}
+			if !ok {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, message); err != nil {
+				log.Printf("WebSocket write error: %v", err)
+				conn.Close()
+				return
+			}
+		}
+	}()
+
+	// Reader: handle subscribe commands until the connection closes
 	for {
-		_, _, err := conn.ReadMessage()
+		_, data, err := conn.ReadMessage()
 		if err != nil {
 			break
 		}
+		select {
+		case replies <- client.handleClientCommand(data):
+		default:
+		}
 	}
 }
 
 func (s *Server) handleBroadcast() {
-	for {
-		message := <-s.broadcast
+	for message := range s.broadcast {
 		s.clientsMux.RLock()
 		for client := range s.clients {
-			err := client.WriteMessage(websocket.TextMessage, message)
-			if err != nil {
-				log.Printf("WebSocket write error: %v", err)
-				client.Close()
-				delete(s.clients, client)
+			if !client.wants(message) {
+				continue
+			}
+			select {
+			case client.send <- message.payload:
+			default:
+				// Client is too slow, drop the event rather than block everyone else
 			}
 		}
 		s.clientsMux.RUnlock()
@@ -237,6 +483,11 @@ func (s *Server) handleBroadcast() {
 }
 
 func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		s.handleCreateSession(w, r)
+		return
+	}
+
 	sessions, err := s.database.GetAllSessions()
 	if err != nil {
 		http.Error(w, "Failed to get sessions", http.StatusInternalServerError)
@@ -248,24 +499,66 @@ func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleSessionDetail(w http.ResponseWriter, r *http.Request) {
-	sessionID := r.URL.Path[len("/api/sessions/"):]
+	sessionID, view, _ := strings.Cut(r.URL.Path[len("/api/sessions/"):], "/")
 	id, err := strconv.Atoi(sessionID)
 	if err != nil {
 		http.Error(w, "Invalid session ID", http.StatusBadRequest)
 		return
 	}
 
+	if view == "timeline" {
+		s.handleSessionTimeline(w, r, id)
+		return
+	}
+	if resource, action, _ := strings.Cut(view, "/"); resource == "pii" {
+		s.handleSessionPII(w, r, id, action)
+		return
+	}
+	if view == "conformance" {
+		s.handleSessionConformance(w, r, id)
+		return
+	}
+	if view == "consumers" {
+		s.handleSessionConsumers(w, r, id)
+		return
+	}
+
+	if r.Method == http.MethodPut {
+		s.handleSessionDescription(w, r, id)
+		return
+	}
+
 	interactions, err := s.database.GetInteractionsBySession(id)
 	if err != nil {
 		http.Error(w, "Failed to get interactions", http.StatusInternalServerError)
 		return
 	}
+	if name := r.URL.Query().Get("consumer"); name != "" {
+		interactions = consumer.Filter(interactions, name)
+	}
+	if name := r.URL.Query().Get("tag"); name != "" {
+		interactions = tag.Filter(interactions, name)
+	}
+	if interactions == nil {
+		interactions = []storage.Interaction{}
+	}
 
+	s.redactInteractions(interactions)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(interactions)
 }
 
 func (s *Server) handleInteractions(w http.ResponseWriter, r *http.Request) {
+	if idStr, action, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/interactions/"), "/"); idStr != "" {
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "Invalid interaction ID", http.StatusBadRequest)
+			return
+		}
+		s.handleInteractionDetail(w, r, id, action)
+		return
+	}
+
 	sessions, err := s.database.GetAllSessions()
 	if err != nil {
 		http.Error(w, "Failed to get sessions", http.StatusInternalServerError)
@@ -281,10 +574,76 @@ func (s *Server) handleInteractions(w http.ResponseWriter, r *http.Request) {
 		allInteractions = append(allInteractions, interactions...)
 	}
 
+	s.redactInteractions(allInteractions)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(allInteractions)
 }
 
+func (s *Server) handleInteractionDetail(w http.ResponseWriter, r *http.Request, id int, action string) {
+	switch action {
+	case "resend":
+		s.handleResendInteraction(w, r, id)
+	case "annotation":
+		s.handleInteractionAnnotation(w, r, id)
+	case "scenario":
+		s.handleInteractionScenario(w, r, id)
+	case "decoded":
+		s.handleInteractionDecoded(w, r, id)
+	case "":
+		interaction, err := s.database.GetInteraction(id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		s.redactInteraction(interaction)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(interaction)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleProxies(w http.ResponseWriter, r *http.Request) {
+	names := make([]string, 0, len(s.config.Proxies))
+	for name := range s.config.Proxies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	proxies := make([]map[string]interface{}, 0, len(names))
+	for _, name := range names {
+		proxyConfig := s.config.Proxies[name]
+		sessionName, mode := proxyConfig.SessionName, proxyConfig.EffectiveMode(s.config.Mode)
+		if s.admin != nil {
+			sessionName, mode = s.admin.ProxySession(name), s.admin.ProxyMode(name)
+		}
+		proxies = append(proxies, map[string]interface{}{
+			"name":         name,
+			"protocol":     proxyConfig.Protocol,
+			"target_host":  proxyConfig.TargetHost,
+			"target_port":  proxyConfig.TargetPort,
+			"session_name": sessionName,
+			"mode":         mode,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(proxies)
+}
+
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics.Default.Snapshot())
+}
+
+// handlePrometheusMetrics serves the process metrics in the Prometheus text format (GET /metrics)
+func (s *Server) handlePrometheusMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", metrics.PrometheusContentType)
+	if err := metrics.WritePrometheus(w); err != nil {
+		log.Printf("Error writing Prometheus metrics: %v", err)
+	}
+}
+
 func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -296,6 +655,7 @@ func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "Failed to clear sessions", http.StatusInternalServerError)
 		return
 	}
+	webhook.SessionCleared("")
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{"status": "success"})
@@ -303,52 +663,103 @@ func (s *Server) handleClear(w http.ResponseWriter, r *http.Request) {
 
 // BroadcastEvent sends an event to all connected WebSocket clients
 func (s *Server) BroadcastEvent(eventType string, data interface{}) {
-	message := Message{
+	s.broadcastMessage(eventType, data, &broadcastMessage{})
+}
+
+// broadcastMessage encodes an event and queues it for delivery to matching clients
+func (s *Server) broadcastMessage(eventType string, data interface{}, message *broadcastMessage) {
+	payload, err := json.Marshal(Message{
 		Type:      eventType,
 		Timestamp: time.Now(),
 		Data:      data,
-	}
-
-	messageBytes, err := json.Marshal(message)
+	})
 	if err != nil {
 		log.Printf("Failed to marshal broadcast message: %v", err)
 		return
 	}
+	message.payload = payload
 
 	select {
-	case s.broadcast <- messageBytes:
+	case s.broadcast <- message:
 	default:
 		// Channel is full, skip this message
 	}
 }
 
+// broadcastTraffic broadcasts a request or response event with its filter attributes
+func (s *Server) broadcastTraffic(event RequestResponseEvent) {
+	s.broadcastMessage(event.Type, event, &broadcastMessage{
+		traffic:     true,
+		proxyName:   event.ProxyName,
+		sessionName: event.SessionName,
+		method:      event.Method,
+		endpoint:    event.Endpoint,
+	})
+}
+
 // BroadcastRequest broadcasts a request event
-func (s *Server) BroadcastRequest(method, endpoint, sessionName, remoteAddr, requestID string, headers map[string]interface{}, body string) {
+func (s *Server) BroadcastRequest(proxyName, method, endpoint, sessionName, remoteAddr, requestID string, headers map[string]interface{}, body string) {
 	event := RequestResponseEvent{
 		Type:        "request",
+		ProxyName:   proxyName,
 		Method:      method,
 		Endpoint:    endpoint,
-		Headers:     headers,
-		Body:        body,
+		Headers:     s.redactHeaders(headers),
+		Body:        s.redactor.Text(body),
 		SessionName: sessionName,
 		RemoteAddr:  remoteAddr,
 		RequestID:   requestID,
 	}
-	s.BroadcastEvent("request", event)
+	s.broadcastTraffic(event)
 }
 
 // BroadcastResponse broadcasts a response event
-func (s *Server) BroadcastResponse(method, endpoint, sessionName, remoteAddr, requestID string, status int, headers map[string]interface{}, body string) {
+func (s *Server) BroadcastResponse(proxyName, method, endpoint, sessionName, remoteAddr, requestID string, status int, headers map[string]interface{}, body string) {
 	event := RequestResponseEvent{
 		Type:        "response",
+		ProxyName:   proxyName,
 		Method:      method,
 		Endpoint:    endpoint,
-		Headers:     headers,
-		Body:        body,
+		Headers:     s.redactHeaders(headers),
+		Body:        s.redactor.Text(body),
 		Status:      status,
 		SessionName: sessionName,
 		RemoteAddr:  remoteAddr,
 		RequestID:   requestID,
 	}
-	s.BroadcastEvent("response", event)
+	s.broadcastTraffic(event)
+}
+
+// redactInteraction masks secrets in an interaction before the UI shows it, so recordings made
+// before redaction was set up are masked too. gRPC messages are binary and left as they are.
+func (s *Server) redactInteraction(interaction *storage.Interaction) {
+	interaction.RequestHeaders = s.redactor.Headers(interaction.RequestHeaders)
+	interaction.ResponseHeaders = s.redactor.Headers(interaction.ResponseHeaders)
+	if interaction.Protocol != "gRPC" {
+		interaction.RequestBody = s.redactor.Bytes(interaction.RequestBody)
+		interaction.ResponseBody = s.redactor.Bytes(interaction.ResponseBody)
+	}
+}
+
+// redactInteractions masks secrets in each of a list of interactions
+func (s *Server) redactInteractions(interactions []storage.Interaction) {
+	for i := range interactions {
+		s.redactInteraction(&interactions[i])
+	}
+}
+
+// redactHeaders masks secrets in the headers of a live traffic event, returning a copy
+func (s *Server) redactHeaders(headers map[string]interface{}) map[string]interface{} {
+	if s.redactor == nil || headers == nil {
+		return headers
+	}
+	encoded, err := json.Marshal(headers)
+	if err != nil {
+		return headers
+	}
+	var redacted map[string]interface{}
+	if json.Unmarshal([]byte(s.redactor.Headers(string(encoded))), &redacted) != nil {
+		return headers
+	}
+	return redacted
 }
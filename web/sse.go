@@ -0,0 +1,74 @@
+package web
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// sseKeepAlive is how often an idle event stream sends a comment so intermediaries keep it open
+const sseKeepAlive = 15 * time.Second
+
+// handleEventStream mirrors the WebSocket broadcast as Server-Sent Events for networks that block WebSockets.
+// The filter is taken from the query string; clients reconnect with new parameters to change it.
+func (s *Server) handleEventStream(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	filter, err := filterFromQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no") // Stop nginx from buffering the stream
+	w.WriteHeader(http.StatusOK)
+
+	client := newLiveClient("sse", filter)
+	s.addClient(client)
+	defer s.removeClient(client)
+
+	log.Printf("SSE client connected from %s", r.RemoteAddr)
+	defer log.Printf("SSE client disconnected")
+
+	// Acknowledge the filter the same way the WebSocket subscribe command does
+	fmt.Fprintf(w, "retry: 3000\n")
+	writeSSEData(w, commandReply("subscribed", filter))
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case message, ok := <-client.send:
+			if !ok {
+				return
+			}
+			writeSSEData(w, message)
+			flusher.Flush()
+		case <-keepAlive.C:
+			fmt.Fprintf(w, ": keep-alive\n\n")
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSEData frames one JSON message as an SSE event; the payload is a single line of JSON
+func writeSSEData(w http.ResponseWriter, payload []byte) {
+	fmt.Fprintf(w, "data: %s\n\n", payload)
+}
@@ -0,0 +1,86 @@
+package web
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"mimic/config"
+)
+
+func TestEventStreamFiltersByProxy(t *testing.T) {
+	s := NewServer(config.DefaultConfig(), nil)
+	go s.handleBroadcast()
+	defer close(s.broadcast)
+
+	server := httptest.NewServer(http.HandlerFunc(s.handleEventStream))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/api/events?proxy=billing", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Failed to open event stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if contentType := resp.Header.Get("Content-Type"); contentType != "text/event-stream" {
+		t.Fatalf("Expected an event stream, got %s", contentType)
+	}
+
+	events := make(chan string, 8)
+	go func() {
+		defer close(events)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				events <- data
+			}
+		}
+	}()
+	next := func() string {
+		select {
+		case data := <-events:
+			return data
+		case <-time.After(time.Second):
+			t.Fatal("Timed out waiting for an event")
+			return ""
+		}
+	}
+
+	// The client is registered by the time the filter is acknowledged
+	if ack := next(); !strings.Contains(ack, `"type":"subscribed"`) || !strings.Contains(ack, `"proxy":"billing"`) {
+		t.Fatalf("Expected the filter acknowledged, got %s", ack)
+	}
+
+	s.BroadcastRequest("users", "GET", "/users", "signup", "127.0.0.1", "req-users", nil, "")
+	s.BroadcastRequest("billing", "POST", "/v1/charges", "checkout", "127.0.0.1", "req-billing", nil, `{"amount":100}`)
+
+	// Events are delivered in order, so the first one through is the only match
+	if event := next(); !strings.Contains(event, `"request_id":"req-billing"`) || !strings.Contains(event, `"endpoint":"/v1/charges"`) {
+		t.Errorf("Expected the billing request event, got %s", event)
+	}
+
+	cancel()
+	for event := range events {
+		t.Errorf("Expected exactly one traffic event, also got %s", event)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		s.clientsMux.RLock()
+		remaining := len(s.clients)
+		s.clientsMux.RUnlock()
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected the client removed once the request was cancelled, %d remain", remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -6,26 +6,56 @@ class MimicUI {
         this.maxEvents = 1000;
         this.autoScroll = true;
         this.currentSession = null;
+        this.sessions = [];
+        this.proxies = [];
+        this.metricsTimer = null;
+        this.liveFilter = JSON.parse(localStorage.getItem('mimic.liveFilter') || '{}');
+        this.authToken = localStorage.getItem('mimic.authToken') || '';
+        this.role = 'admin';
+        this.transport = window.WebSocket ? 'websocket' : 'sse';
+        this.wsFailures = 0;
+        this.eventSource = null;
+        this.replayRuns = [];
+        this.selectedReplayRun = null;
         
         this.init();
     }
 
     init() {
-        this.setupWebSocket();
+        this.loadAuth();
+        this.connectLiveEvents();
         this.setupEventListeners();
         this.loadSessions();
         this.loadInteractions();
+        this.loadProxies();
+        this.loadIntercepts();
+        this.loadViolations();
+    }
+
+    // connectLiveEvents prefers WebSockets and falls back to Server-Sent Events when they are blocked
+    connectLiveEvents() {
+        if (this.transport === 'sse') {
+            this.setupEventSource();
+        } else {
+            this.setupWebSocket();
+        }
     }
 
     setupWebSocket() {
+        let opened = false;
         const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const wsUrl = `${protocol}//${window.location.host}/ws`;
+        const query = this.authToken ? `?token=${encodeURIComponent(this.authToken)}` : '';
+        const wsUrl = `${protocol}//${window.location.host}/ws${query}`;
         
         this.ws = new WebSocket(wsUrl);
         
         this.ws.onopen = () => {
             console.log('WebSocket connected');
+            opened = true;
+            this.wsFailures = 0;
             this.updateConnectionStatus(true);
+            // Restore the server-side filter after (re)connecting
+            this.sendLiveFilter();
         };
         
         this.ws.onmessage = (event) => {
@@ -40,8 +70,16 @@ class MimicUI {
         this.ws.onclose = () => {
             console.log('WebSocket disconnected');
             this.updateConnectionStatus(false);
+            // A socket that repeatedly fails to open is likely blocked by a proxy
+            if (!opened && ++this.wsFailures >= 2) {
+                console.log('WebSocket unavailable, falling back to Server-Sent Events');
+                this.transport = 'sse';
+                this.ws = null;
+                this.setupEventSource();
+                return;
+            }
             // Attempt to reconnect after 3 seconds
-            setTimeout(() => this.setupWebSocket(), 3000);
+            setTimeout(() => this.connectLiveEvents(), 3000);
         };
         
         this.ws.onerror = (error) => {
@@ -50,10 +88,50 @@ class MimicUI {
         };
     }
 
+    setupEventSource() {
+        if (this.eventSource) {
+            this.eventSource.close();
+        }
+
+        const params = new URLSearchParams();
+        Object.entries(this.liveFilter).forEach(([key, value]) => {
+            if (value) {
+                params.set(key, value);
+            }
+        });
+        if (this.authToken) {
+            params.set('token', this.authToken);
+        }
+
+        const eventSource = new EventSource(`/api/events?${params.toString()}`);
+        this.eventSource = eventSource;
+
+        eventSource.onopen = () => {
+            console.log('Event stream connected');
+            this.updateConnectionStatus(true);
+        };
+
+        eventSource.onmessage = (event) => {
+            try {
+                this.handleMessage(JSON.parse(event.data));
+            } catch (error) {
+                console.error('Failed to parse event stream message:', error);
+            }
+        };
+
+        eventSource.onerror = () => {
+            this.updateConnectionStatus(false);
+            // EventSource retries on its own unless the server rejected the stream outright
+            if (eventSource.readyState === EventSource.CLOSED && this.eventSource === eventSource) {
+                setTimeout(() => this.setupEventSource(), 3000);
+            }
+        };
+    }
+
     updateConnectionStatus(connected) {
         const statusEl = document.getElementById('connection-status');
         if (connected) {
-            statusEl.textContent = 'Connected';
+            statusEl.textContent = this.transport === 'sse' ? 'Connected (SSE)' : 'Connected';
             statusEl.className = 'status-connected';
         } else {
             statusEl.textContent = 'Disconnected';
@@ -67,6 +145,30 @@ class MimicUI {
             case 'response':
                 this.addEvent(message);
                 break;
+            case 'replay_started':
+            case 'replay_progress':
+            case 'replay_completed':
+                this.handleReplayEvent(message);
+                break;
+            case 'subscribed':
+                this.updateFilterStatus(message.data);
+                break;
+            case 'error':
+                document.getElementById('filter-status').textContent = message.data?.error || 'Error';
+                break;
+            case 'intercept_paused':
+            case 'intercept_resumed':
+            case 'breakpoints_changed':
+                this.loadIntercepts();
+                break;
+            case 'sequence_reset':
+                if (document.getElementById('sequences-tab').classList.contains('active')) {
+                    this.loadSequences();
+                }
+                break;
+            case 'conformance_violation':
+                this.loadViolations();
+                break;
             default:
                 console.log('Unknown message type:', message.type);
         }
@@ -179,6 +281,7 @@ class MimicUI {
                     <div class="event-timestamp">${timestamp}</div>
                 </div>
                 <div class="event-meta">
+                    ${event.proxy_name ? `<span>Proxy: ${this.escapeHtml(event.proxy_name)}</span>` : ''}
                     <span>Session: ${event.session_name}</span>
                     <span>From: ${event.remote_addr || 'unknown'}</span>
                     <span>Duration: ${durationText}</span>
@@ -220,7 +323,45 @@ class MimicUI {
         document.getElementById('event-count').textContent = this.events.length;
     }
 
+    // apiFetch calls the API with the stored access token, if any
+    apiFetch(url, options = {}) {
+        if (!this.authToken) {
+            return fetch(url, options);
+        }
+        const headers = { ...(options.headers || {}), 'Authorization': `Bearer ${this.authToken}` };
+        return fetch(url, { ...options, headers });
+    }
+
+    async loadAuth() {
+        try {
+            const response = await this.apiFetch('/api/auth');
+            const auth = await response.json();
+            this.role = auth.role;
+            document.getElementById('auth-section').style.display = auth.enabled ? 'block' : 'none';
+            document.getElementById('auth-role').textContent = auth.enabled ? `Role: ${auth.role}` : '';
+        } catch (error) {
+            console.error('Failed to load access role:', error);
+        }
+        document.body.classList.toggle('role-viewer', this.role !== 'admin');
+    }
+
+    saveAuthToken() {
+        this.authToken = document.getElementById('auth-token').value.trim();
+        if (this.authToken) {
+            localStorage.setItem('mimic.authToken', this.authToken);
+        } else {
+            localStorage.removeItem('mimic.authToken');
+        }
+        // Reconnect so the live events socket picks up the new token
+        window.location.reload();
+    }
+
     setupEventListeners() {
+        document.getElementById('auth-token').value = this.authToken;
+        document.getElementById('save-token').addEventListener('click', () => {
+            this.saveAuthToken();
+        });
+
         // Tab switching
         document.querySelectorAll('.tab-btn').forEach(btn => {
             btn.addEventListener('click', (e) => {
@@ -258,6 +399,67 @@ class MimicUI {
             this.filterInteractions(e.target.value);
         });
 
+        // Replay launcher
+        document.getElementById('replay-form').addEventListener('submit', (e) => {
+            e.preventDefault();
+            this.startReplay();
+        });
+
+        document.getElementById('breakpoint-form').addEventListener('submit', (e) => {
+            e.preventDefault();
+            this.addBreakpoint();
+        });
+
+        // Keep the default port in sync with the chosen protocol
+        document.getElementById('replay-protocol').addEventListener('change', (e) => {
+            const port = document.getElementById('replay-target-port');
+            if (e.target.value === 'https') port.value = 443;
+            else if (e.target.value === 'http') port.value = 80;
+        });
+
+        // Live event filter
+        document.getElementById('apply-filter').addEventListener('click', () => {
+            this.liveFilter = {
+                proxy: document.getElementById('filter-proxy').value,
+                session: document.getElementById('filter-session').value.trim(),
+                method: document.getElementById('filter-method').value,
+                endpoint: document.getElementById('filter-endpoint').value.trim()
+            };
+            this.sendLiveFilter();
+        });
+
+        document.getElementById('reset-filter').addEventListener('click', () => {
+            this.liveFilter = {};
+            this.populateFilterControls();
+            this.sendLiveFilter();
+        });
+
+        // Timeline session picker
+        document.getElementById('timeline-session').addEventListener('change', (e) => {
+            this.loadTimeline(e.target.value);
+        });
+
+        // Sequence state controls
+        document.getElementById('refresh-sequences').addEventListener('click', () => {
+            this.loadSequences();
+        });
+
+        document.getElementById('reset-all-sequences').addEventListener('click', () => {
+            if (confirm('Reset the sequence position of every mock signature?')) {
+                this.resetSequence('', '');
+            }
+        });
+
+        // Conformance controls
+        document.getElementById('check-conformance').addEventListener('click', () => {
+            this.checkConformance(document.getElementById('conformance-session').value);
+        });
+
+        document.getElementById('clear-violations').addEventListener('click', async () => {
+            await this.apiFetch('/api/conformance', { method: 'DELETE' });
+            this.loadViolations();
+        });
+
         // Modal close
         document.querySelector('.close').addEventListener('click', () => {
             document.getElementById('interaction-modal').style.display = 'none';
@@ -288,13 +490,36 @@ class MimicUI {
         // Load data if needed
         if (tabName === 'interactions') {
             this.loadInteractions();
+        } else if (tabName === 'replay') {
+            this.updateReplaySessionSelect();
+            this.loadReplayRuns();
+        } else if (tabName === 'sequences') {
+            this.loadSequences();
+        } else if (tabName === 'intercept') {
+            this.updateBreakpointProxySelect();
+            this.loadIntercepts();
+        } else if (tabName === 'timeline') {
+            this.updateTimelineSessionSelect();
+            this.loadTimeline(document.getElementById('timeline-session').value);
+        } else if (tabName === 'conformance') {
+            this.updateConformanceSessionSelect();
+            this.loadViolations();
+        }
+
+        // Poll metrics only while the tab is visible
+        clearInterval(this.metricsTimer);
+        this.metricsTimer = null;
+        if (tabName === 'metrics') {
+            this.loadMetrics();
+            this.metricsTimer = setInterval(() => this.loadMetrics(), 5000);
         }
     }
 
     async loadSessions() {
         try {
-            const response = await fetch('/api/sessions');
-            const sessions = await response.json();
+            const response = await this.apiFetch('/api/sessions');
+            const sessions = await response.json() || [];
+            this.sessions = sessions;
             this.renderSessions(sessions);
             this.updateSessionFilter(sessions);
         } catch (error) {
@@ -314,10 +539,13 @@ class MimicUI {
             const createdAt = new Date(session.created_at).toLocaleString();
             return `
                 <div class="session-item" data-session-id="${session.id}">
-                    <div class="session-name">${session.session_name}</div>
+                    <div class="session-name">
+                        ${this.escapeHtml(session.session_name)}
+                        <button class="edit-notes admin-only" data-session-id="${session.id}" title="Edit description">&#9998;</button>
+                    </div>
                     <div class="session-meta">
                         Created: ${createdAt}<br>
-                        ${session.description || 'No description'}
+                        <span class="session-description">${this.escapeHtml(session.description || 'No description')}</span>
                     </div>
                 </div>
             `;
@@ -331,6 +559,46 @@ class MimicUI {
                 this.selectSession(parseInt(item.dataset.sessionId));
             });
         });
+
+        sessionsList.querySelectorAll('.edit-notes').forEach(button => {
+            button.addEventListener('click', (e) => {
+                e.stopPropagation();
+                const session = sessions.find(s => s.id == button.dataset.sessionId);
+                this.editSessionDescription(session);
+            });
+        });
+    }
+
+    editSessionDescription(session) {
+        const modal = document.getElementById('interaction-modal');
+        const detail = document.getElementById('interaction-detail');
+
+        detail.innerHTML = `
+            <h2>${this.escapeHtml(session.session_name)}</h2>
+            <div class="detail-section">
+                <h4>Description</h4>
+                <textarea id="session-description-input" class="notes-input" rows="8"
+                    placeholder="What does this session cover? How was it recorded?">${this.escapeHtml(session.description || '')}</textarea>
+                <button class="btn" id="save-session-description">Save</button>
+            </div>
+        `;
+
+        document.getElementById('save-session-description').addEventListener('click', async () => {
+            const description = document.getElementById('session-description-input').value;
+            const response = await this.apiFetch(`/api/sessions/${session.id}`, {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ description })
+            });
+            if (!response.ok) {
+                alert(`Failed to save description: ${await response.text()}`);
+                return;
+            }
+            modal.style.display = 'none';
+            this.loadSessions();
+        });
+
+        modal.style.display = 'block';
     }
 
     updateSessionFilter(sessions) {
@@ -358,11 +626,16 @@ class MimicUI {
 
         // Load interactions for this session
         this.loadSessionInteractions(sessionId);
+
+        if (document.getElementById('timeline-tab').classList.contains('active')) {
+            this.updateTimelineSessionSelect();
+            this.loadTimeline(sessionId);
+        }
     }
 
     async loadSessionInteractions(sessionId) {
         try {
-            const response = await fetch(`/api/sessions/${sessionId}`);
+            const response = await this.apiFetch(`/api/sessions/${sessionId}`);
             const interactions = await response.json();
             console.log(`Loaded ${interactions.length} interactions for session ${sessionId}`);
         } catch (error) {
@@ -372,7 +645,7 @@ class MimicUI {
 
     async loadInteractions() {
         try {
-            const response = await fetch('/api/interactions/');
+            const response = await this.apiFetch('/api/interactions/');
             const interactions = await response.json();
             this.renderInteractions(interactions);
         } catch (error) {
@@ -409,8 +682,10 @@ class MimicUI {
                     <div class="event-meta">
                         <span>Sequence: ${interaction.sequence_number}</span>
                         <span>Protocol: ${interaction.protocol}</span>
+                        ${this.consumerOf(interaction) ? `<span>Consumer: ${this.escapeHtml(this.consumerOf(interaction))}</span>` : ''}
                         <span>ID: ${interaction.request_id.substring(0, 8)}...</span>
                     </div>
+                    ${this.annotationOf(interaction) ? `<div class="interaction-annotation">${this.escapeHtml(this.annotationOf(interaction))}</div>` : ''}
                 </div>
             `;
         }).join('');
@@ -446,10 +721,9 @@ class MimicUI {
             console.error('Failed to parse headers:', error);
         }
 
-        const requestBody = interaction.request_body ? 
-            new TextDecoder().decode(new Uint8Array(interaction.request_body)) : '';
-        const responseBody = interaction.response_body ? 
-            new TextDecoder().decode(new Uint8Array(interaction.response_body)) : '';
+        const requestBody = this.decodeBase64(interaction.request_body);
+        const responseBody = this.decodeBase64(interaction.response_body);
+        const isGRPC = interaction.protocol === 'gRPC';
 
         detail.innerHTML = `
             <h2>${interaction.method} ${interaction.endpoint}</h2>
@@ -457,7 +731,36 @@ class MimicUI {
             <p><strong>Timestamp:</strong> ${new Date(interaction.timestamp).toLocaleString()}</p>
             <p><strong>Sequence:</strong> ${interaction.sequence_number}</p>
             <p><strong>Status:</strong> ${interaction.response_status}</p>
+
+            <div class="detail-section">
+                <h4>Annotation</h4>
+                <textarea id="annotation-input" class="notes-input" rows="3"
+                    placeholder="Explain what this interaction covers">${this.escapeHtml(this.annotationOf(interaction))}</textarea>
+                <button class="btn admin-only" id="save-annotation">Save Annotation</button>
+            </div>
+
+            <div class="detail-section">
+                <h4>Reproduce</h4>
+                <div class="command-controls">
+                    <label>Target <input type="text" id="command-target" value="${this.escapeHtml(this.getCommandTarget())}"></label>
+                    <button class="btn" id="copy-command">${isGRPC ? 'Copy as grpcurl' : 'Copy as curl'}</button>
+                </div>
+                <div class="detail-content" id="command-preview"></div>
+            </div>
             
+            <div class="detail-section admin-only">
+                <h4>Resend</h4>
+                <div class="command-controls">
+                    <select id="resend-via">
+                        ${this.proxies.map(p => `<option value="${this.escapeHtml(p.name)}">Through proxy: ${this.escapeHtml(p.name)}</option>`).join('')}
+                        <option value="">Custom target</option>
+                    </select>
+                    <input type="text" id="resend-target" placeholder="https://api.example.com:443">
+                    <button class="btn" id="resend-interaction">Resend</button>
+                </div>
+                <div id="resend-result"></div>
+            </div>
+
             <div class="detail-section">
                 <h4>Request Headers</h4>
                 <div class="detail-content">${JSON.stringify(requestHeaders, null, 2)}</div>
@@ -465,7 +768,7 @@ class MimicUI {
             
             <div class="detail-section">
                 <h4>Request Body</h4>
-                <div class="detail-content">${this.escapeHtml(requestBody) || '(empty)'}</div>
+                <div class="detail-content" id="request-body">${this.escapeHtml(requestBody) || this.bodyFileNote(interaction, 'request')}</div>
             </div>
             
             <div class="detail-section">
@@ -475,16 +778,313 @@ class MimicUI {
             
             <div class="detail-section">
                 <h4>Response Body</h4>
-                <div class="detail-content">${this.escapeHtml(responseBody) || '(empty)'}</div>
+                <div class="detail-content" id="response-body">${this.escapeHtml(responseBody) || this.bodyFileNote(interaction, 'response')}</div>
             </div>
         `;
+
+        const targetInput = document.getElementById('command-target');
+        const preview = document.getElementById('command-preview');
+        const buildCommand = () => isGRPC
+            ? this.buildGrpcurlCommand(interaction, targetInput.value.trim())
+            : this.buildCurlCommand(interaction, targetInput.value.trim());
+
+        preview.textContent = buildCommand();
+        targetInput.addEventListener('input', () => {
+            localStorage.setItem('mimic.commandTarget', targetInput.value.trim());
+            preview.textContent = buildCommand();
+        });
+        document.getElementById('copy-command').addEventListener('click', (e) => {
+            this.copyToClipboard(buildCommand(), e.target);
+        });
+        if (isGRPC) {
+            this.loadDecodedMessages(interaction).then(() => {
+                preview.textContent = buildCommand();
+            });
+        }
+
+        document.getElementById('save-annotation').addEventListener('click', (e) => {
+            this.saveAnnotation(interaction, document.getElementById('annotation-input').value, e.target);
+        });
+
+        const resendVia = document.getElementById('resend-via');
+        const resendTarget = document.getElementById('resend-target');
+        const syncResendTarget = () => { resendTarget.disabled = resendVia.value !== ''; };
+        syncResendTarget();
+        resendVia.addEventListener('change', syncResendTarget);
+        document.getElementById('resend-interaction').addEventListener('click', () => {
+            this.resendInteraction(interaction, resendVia.value, resendTarget.value.trim());
+        });
         
         modal.style.display = 'block';
     }
 
+    // Replaces the raw protobuf bodies with their JSON form when the server has the descriptors
+    async loadDecodedMessages(interaction) {
+        try {
+            const response = await this.apiFetch(`/api/interactions/${interaction.id}/decoded`);
+            if (!response.ok) return;
+            interaction.decoded = await response.json();
+            document.getElementById('request-body').textContent = JSON.stringify(interaction.decoded.request, null, 2);
+            document.getElementById('response-body').textContent = JSON.stringify(interaction.decoded.response, null, 2);
+        } catch (error) {
+            console.error('Failed to decode gRPC messages:', error);
+        }
+    }
+
+    annotationOf(interaction) {
+        try {
+            return JSON.parse(interaction.metadata || '{}').annotation || '';
+        } catch (error) {
+            return '';
+        }
+    }
+
+    bodyFileNote(interaction, part) {
+        let metadata = {};
+        try {
+            metadata = JSON.parse(interaction.metadata || '{}');
+        } catch (error) {
+            return '(empty)';
+        }
+        const file = metadata[`${part}_body_file`];
+        if (!file) {
+            return '(empty)';
+        }
+        const size = metadata[`${part}_body_size`] || 0;
+        return `(${(size / 1024 / 1024).toFixed(1)} MB, stored in body file ${this.escapeHtml(file)})`;
+    }
+
+    consumerOf(interaction) {
+        try {
+            return JSON.parse(interaction.metadata || '{}').consumer || '';
+        } catch (error) {
+            return '';
+        }
+    }
+
+    async saveAnnotation(interaction, annotation, button) {
+        try {
+            const response = await this.apiFetch(`/api/interactions/${interaction.id}/annotation`, {
+                method: 'PUT',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ annotation })
+            });
+            if (!response.ok) {
+                alert(`Failed to save annotation: ${await response.text()}`);
+                return;
+            }
+            const updated = await response.json();
+            interaction.metadata = updated.metadata;
+
+            const label = button.textContent;
+            button.textContent = 'Saved!';
+            setTimeout(() => { button.textContent = label; }, 1500);
+            this.loadInteractions();
+        } catch (error) {
+            console.error('Failed to save annotation:', error);
+        }
+    }
+
+    async loadProxies() {
+        try {
+            const response = await this.apiFetch('/api/proxies');
+            this.proxies = await response.json() || [];
+        } catch (error) {
+            console.error('Failed to load proxies:', error);
+        }
+
+        const select = document.getElementById('filter-proxy');
+        this.proxies.forEach(proxy => {
+            const option = document.createElement('option');
+            option.value = proxy.name;
+            option.textContent = proxy.name;
+            select.appendChild(option);
+        });
+        this.populateFilterControls();
+    }
+
+    populateFilterControls() {
+        document.getElementById('filter-proxy').value = this.liveFilter.proxy || '';
+        document.getElementById('filter-session').value = this.liveFilter.session || '';
+        document.getElementById('filter-method').value = this.liveFilter.method || '';
+        document.getElementById('filter-endpoint').value = this.liveFilter.endpoint || '';
+    }
+
+    sendLiveFilter() {
+        localStorage.setItem('mimic.liveFilter', JSON.stringify(this.liveFilter));
+        if (this.transport === 'sse') {
+            // Event streams are one-way, so a new filter means a new stream
+            this.setupEventSource();
+        } else if (this.ws && this.ws.readyState === WebSocket.OPEN) {
+            this.ws.send(JSON.stringify({ type: 'subscribe', filter: this.liveFilter }));
+        }
+    }
+
+    updateFilterStatus(filter) {
+        const parts = Object.entries(filter || {})
+            .filter(([, value]) => value)
+            .map(([key, value]) => `${key}=${value}`);
+        document.getElementById('filter-status').textContent =
+            parts.length > 0 ? `Showing ${parts.join(', ')}` : 'Showing all traffic';
+    }
+
+    async resendInteraction(interaction, proxyName, target) {
+        const resultEl = document.getElementById('resend-result');
+        const body = { proxy: proxyName, matching_strategy: 'fuzzy' };
+
+        if (!proxyName) {
+            let url;
+            try {
+                url = new URL(target.includes('://') ? target : `http://${target}`);
+            } catch (error) {
+                resultEl.innerHTML = '<div class="no-events">Enter a valid target URL.</div>';
+                return;
+            }
+            const protocol = interaction.protocol === 'gRPC' ? 'grpc' : url.protocol.replace(':', '');
+            body.protocol = protocol;
+            body.target_host = url.hostname;
+            body.target_port = parseInt(url.port, 10) || (url.protocol === 'https:' ? 443 : 80);
+            body.base_path = url.pathname === '/' ? '' : url.pathname;
+            body.grpc_insecure = url.protocol !== 'https:';
+        }
+
+        resultEl.innerHTML = '<div class="no-events">Sending...</div>';
+
+        try {
+            const response = await this.apiFetch(`/api/interactions/${interaction.id}/resend`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body)
+            });
+            if (!response.ok) {
+                resultEl.innerHTML = `<div class="no-events">${this.escapeHtml(await response.text())}</div>`;
+                return;
+            }
+            this.renderResendResult(await response.json());
+        } catch (error) {
+            resultEl.innerHTML = `<div class="no-events">${this.escapeHtml(error.message)}</div>`;
+        }
+    }
+
+    renderResendResult(result) {
+        const resultEl = document.getElementById('resend-result');
+        const recorded = this.prettyBody(this.decodeBase64(result.expected_body));
+        const live = this.prettyBody(this.decodeBase64(result.actual_body));
+
+        resultEl.innerHTML = `
+            <p class="${result.success ? 'resend-match' : 'resend-mismatch'}">
+                ${result.success ? 'Matches recording' : 'Differs from recording'}
+                ${result.validation_error ? `: ${this.escapeHtml(result.validation_error)}` : ''}
+                ${result.error ? `: ${this.escapeHtml(result.error)}` : ''}
+            </p>
+            <div class="resend-compare">
+                <div>
+                    <h4>Recorded (${result.expected_status})</h4>
+                    <div class="detail-content">${this.escapeHtml(recorded) || '(empty)'}</div>
+                </div>
+                <div>
+                    <h4>Live (${result.actual_status || 'no response'}, ${(result.response_time / 1e6).toFixed(1)}ms)</h4>
+                    <div class="detail-content">${this.escapeHtml(live) || '(empty)'}</div>
+                </div>
+            </div>
+        `;
+    }
+
+    getCommandTarget() {
+        return localStorage.getItem('mimic.commandTarget') || window.location.origin;
+    }
+
+    parseHeaders(headersJSON) {
+        // REST interactions store single strings, gRPC metadata stores string arrays
+        const headers = [];
+        try {
+            const parsed = JSON.parse(headersJSON || '{}');
+            Object.entries(parsed).forEach(([key, value]) => {
+                (Array.isArray(value) ? value : [value]).forEach(v => headers.push([key, String(v)]));
+            });
+        } catch (error) {
+            console.error('Failed to parse headers:', error);
+        }
+        return headers;
+    }
+
+    shellQuote(value) {
+        return `'${String(value).replace(/'/g, `'\\''`)}'`;
+    }
+
+    buildCurlCommand(interaction, target) {
+        const skipHeaders = ['host', 'content-length', 'connection', 'transfer-encoding', 'accept-encoding'];
+        const base = (target || window.location.origin).replace(/\/+$/, '');
+        const parts = ['curl', '-X', this.shellQuote(interaction.method), this.shellQuote(base + interaction.endpoint)];
+
+        this.parseHeaders(interaction.request_headers).forEach(([key, value]) => {
+            if (skipHeaders.includes(key.toLowerCase())) return;
+            parts.push('-H', this.shellQuote(`${key}: ${value}`));
+        });
+
+        if (!interaction.request_body) {
+            return parts.join(' ');
+        }
+
+        const body = this.decodeBase64(interaction.request_body);
+        if (this.isPrintable(body)) {
+            parts.push('--data-raw', this.shellQuote(body));
+            return parts.join(' ');
+        }
+
+        // Binary bodies are piped through base64 so the command stays copy-pasteable
+        parts.push('--data-binary', '@-');
+        return `echo ${this.shellQuote(interaction.request_body)} | base64 -d | ${parts.join(' ')}`;
+    }
+
+    buildGrpcurlCommand(interaction, target) {
+        const address = (target || window.location.host).replace(/^[a-z]+:\/\//i, '').replace(/\/+$/, '');
+        const secure = /^https:\/\//i.test(target || '');
+        const parts = ['grpcurl'];
+        if (!secure) parts.push('-plaintext');
+
+        this.parseHeaders(interaction.request_headers).forEach(([key, value]) => {
+            const lower = key.toLowerCase();
+            if (lower.startsWith(':') || lower.startsWith('grpc-') || ['content-type', 'user-agent', 'te'].includes(lower)) return;
+            parts.push('-H', this.shellQuote(`${key}: ${value}`));
+        });
+
+        parts.push('-d', this.shellQuote(interaction.decoded ? JSON.stringify(interaction.decoded.request) : '{}'));
+        parts.push(address, this.shellQuote(interaction.endpoint.replace(/^\//, '')));
+        if (interaction.decoded) {
+            return parts.join(' ');
+        }
+
+        // Without descriptors the recorded payload is raw protobuf, which grpcurl cannot take directly
+        const size = interaction.request_body ? atob(interaction.request_body).length : 0;
+        return `# recorded request was ${size} bytes of protobuf; fill in -d with its JSON form\n${parts.join(' ')}`;
+    }
+
+    isPrintable(text) {
+        return !/[\x00-\x08\x0E-\x1F\uFFFD]/.test(text);
+    }
+
+    async copyToClipboard(text, button) {
+        try {
+            await navigator.clipboard.writeText(text);
+        } catch (error) {
+            // Clipboard API needs a secure context; fall back to a temporary textarea
+            const textarea = document.createElement('textarea');
+            textarea.value = text;
+            document.body.appendChild(textarea);
+            textarea.select();
+            document.execCommand('copy');
+            document.body.removeChild(textarea);
+        }
+
+        const label = button.textContent;
+        button.textContent = 'Copied!';
+        setTimeout(() => { button.textContent = label; }, 1500);
+    }
+
     async clearAll() {
         try {
-            const response = await fetch('/api/clear', { method: 'POST' });
+            const response = await this.apiFetch('/api/clear', { method: 'POST' });
             if (response.ok) {
                 this.loadSessions();
                 this.loadInteractions();
@@ -498,6 +1098,767 @@ class MimicUI {
         }
     }
 
+    updateReplaySessionSelect() {
+        const select = document.getElementById('replay-session');
+        const currentValue = select.value;
+
+        select.innerHTML = '';
+        this.sessions.forEach(session => {
+            const option = document.createElement('option');
+            option.value = session.session_name;
+            option.textContent = session.session_name;
+            if (currentValue === session.session_name) option.selected = true;
+            select.appendChild(option);
+        });
+    }
+
+    async startReplay() {
+        const body = {
+            session_name: document.getElementById('replay-session').value,
+            target_host: document.getElementById('replay-target-host').value.trim(),
+            target_port: parseInt(document.getElementById('replay-target-port').value, 10),
+            protocol: document.getElementById('replay-protocol').value,
+            matching_strategy: document.getElementById('replay-strategy').value,
+            max_concurrency: parseInt(document.getElementById('replay-concurrency').value, 10) || 0,
+            ignore_timestamps: document.getElementById('replay-ignore-timestamps').checked,
+            fail_fast: document.getElementById('replay-fail-fast').checked,
+            grpc_insecure: document.getElementById('replay-grpc-insecure').checked
+        };
+
+        try {
+            const response = await this.apiFetch('/api/replay/runs', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(body)
+            });
+            if (!response.ok) {
+                alert(`Failed to start replay: ${await response.text()}`);
+                return;
+            }
+            const run = await response.json();
+            this.selectedReplayRun = run.id;
+            this.renderReplayProgress(run);
+            this.loadReplayRuns();
+        } catch (error) {
+            console.error('Failed to start replay:', error);
+        }
+    }
+
+    handleReplayEvent(message) {
+        const data = message.data || {};
+
+        if (message.type === 'replay_progress') {
+            this.renderReplayProgress(data);
+            return;
+        }
+
+        this.renderReplayProgress(data);
+        this.loadReplayRuns();
+
+        if (message.type === 'replay_completed' && data.id && data.id === this.selectedReplayRun) {
+            this.loadReplayRun(data.id);
+        }
+    }
+
+    renderReplayProgress(data) {
+        const progressEl = document.getElementById('replay-progress');
+        const total = data.total || data.total_requests || 0;
+        const completed = data.completed !== undefined ? data.completed : total;
+        const percent = total > 0 ? Math.round((completed / total) * 100) : 0;
+        const label = data.status === 'completed' || data.status === 'failed'
+            ? `${data.session_name}: ${data.status} (${data.failures || 0} failed)`
+            : `${data.session_name}: ${completed}/${total}`;
+
+        progressEl.innerHTML = `
+            <div class="progress-bar"><div class="progress-fill" style="width: ${percent}%"></div></div>
+            <div class="progress-label">${this.escapeHtml(label)}</div>
+        `;
+    }
+
+    async loadReplayRuns() {
+        try {
+            const response = await this.apiFetch('/api/replay/runs');
+            this.replayRuns = await response.json() || [];
+            this.renderReplayRuns();
+        } catch (error) {
+            console.error('Failed to load replay runs:', error);
+        }
+    }
+
+    renderReplayRuns() {
+        const runsEl = document.getElementById('replay-runs');
+
+        if (this.replayRuns.length === 0) {
+            runsEl.innerHTML = '<div class="no-events">No replay runs yet.</div>';
+            return;
+        }
+
+        runsEl.innerHTML = this.replayRuns.map(run => `
+            <div class="replay-run-item ${run.id === this.selectedReplayRun ? 'active' : ''}" data-run-id="${run.id}">
+                <div class="session-name">${this.escapeHtml(run.session_name)}</div>
+                <div class="session-meta">
+                    ${this.escapeHtml(run.protocol)}://${this.escapeHtml(run.target_host)}:${run.target_port} (${this.escapeHtml(run.matching_strategy)})<br>
+                    <span class="replay-status replay-status-${run.status}">${run.status}</span>
+                    ${run.completed}/${run.total} done, ${run.failures} failed
+                    <br>${new Date(run.started_at).toLocaleString()}
+                </div>
+            </div>
+        `).join('');
+
+        runsEl.querySelectorAll('.replay-run-item').forEach(item => {
+            item.addEventListener('click', () => {
+                this.selectedReplayRun = item.dataset.runId;
+                this.renderReplayRuns();
+                this.loadReplayRun(item.dataset.runId);
+            });
+        });
+    }
+
+    async loadReplayRun(runId) {
+        try {
+            const response = await this.apiFetch(`/api/replay/runs/${runId}`);
+            if (!response.ok) return;
+            const run = await response.json();
+            this.renderReplayResults(run);
+        } catch (error) {
+            console.error('Failed to load replay run:', error);
+        }
+    }
+
+    renderReplayResults(run) {
+        const resultsEl = document.getElementById('replay-results');
+        const results = run.result?.results || [];
+
+        if (results.length === 0) {
+            const message = run.status === 'running' ? 'Replay in progress...' : (run.error || 'No results.');
+            resultsEl.innerHTML = `<div class="no-events">${this.escapeHtml(message)}</div>`;
+            return;
+        }
+
+        resultsEl.innerHTML = results.map((result, index) => {
+            const interaction = result.interaction || {};
+            const statusClass = result.success ? 'replay-pass' : 'replay-fail';
+            return `
+                <div class="interaction-item ${statusClass}" data-result-index="${index}">
+                    <div class="interaction-header">
+                        <div>
+                            <span class="event-method method-${interaction.method}">${this.escapeHtml(interaction.method || '')}</span>
+                            <span class="interaction-endpoint">${this.escapeHtml(interaction.endpoint || '')}</span>
+                            <span class="event-status status-${Math.floor(result.actual_status / 100)}xx">${result.actual_status}</span>
+                        </div>
+                        <div class="interaction-time">${result.success ? 'PASS' : 'FAIL'}</div>
+                    </div>
+                    <div class="event-meta">
+                        <span>Expected: ${result.expected_status}</span>
+                        <span>Time: ${(result.response_time / 1e6).toFixed(1)}ms</span>
+                        ${result.validation_error ? `<span>${this.escapeHtml(result.validation_error)}</span>` : ''}
+                        ${result.error ? `<span>Error: ${this.escapeHtml(result.error)}</span>` : ''}
+                    </div>
+                </div>
+            `;
+        }).join('');
+
+        resultsEl.querySelectorAll('.interaction-item').forEach(item => {
+            item.addEventListener('click', () => {
+                this.showReplayDiff(results[parseInt(item.dataset.resultIndex, 10)]);
+            });
+        });
+    }
+
+    showReplayDiff(result) {
+        const modal = document.getElementById('interaction-modal');
+        const detail = document.getElementById('interaction-detail');
+        const interaction = result.interaction || {};
+
+        const expected = this.prettyBody(this.decodeBase64(result.expected_body));
+        const actual = this.prettyBody(this.decodeBase64(result.actual_body));
+
+        const diffHtml = this.lineDiff(expected, actual).map(line => {
+            const cls = line.op === '+' ? 'diff-add' : line.op === '-' ? 'diff-del' : 'diff-same';
+            return `<div class="${cls}">${line.op} ${this.escapeHtml(line.text)}</div>`;
+        }).join('');
+
+        detail.innerHTML = `
+            <h2>${this.escapeHtml(interaction.method || '')} ${this.escapeHtml(interaction.endpoint || '')}</h2>
+            <p><strong>Result:</strong> ${result.success ? 'PASS' : 'FAIL'}</p>
+            <p><strong>Status:</strong> expected ${result.expected_status}, got ${result.actual_status}</p>
+            ${result.validation_error ? `<p><strong>Validation:</strong> ${this.escapeHtml(result.validation_error)}</p>` : ''}
+            ${result.error ? `<p><strong>Error:</strong> ${this.escapeHtml(result.error)}</p>` : ''}
+
+            <div class="detail-section">
+                <h4>Body Diff (- expected, + actual)</h4>
+                <div class="detail-content diff-content">${diffHtml || '(both empty)'}</div>
+            </div>
+        `;
+
+        modal.style.display = 'block';
+    }
+
+    updateTimelineSessionSelect() {
+        const select = document.getElementById('timeline-session');
+        const selected = this.currentSession || select.value;
+
+        select.innerHTML = '';
+        this.sessions.forEach(session => {
+            const option = document.createElement('option');
+            option.value = session.id;
+            option.textContent = session.session_name;
+            if (String(session.id) === String(selected)) option.selected = true;
+            select.appendChild(option);
+        });
+    }
+
+    async loadTimeline(sessionId) {
+        if (!sessionId) return;
+        try {
+            const response = await this.apiFetch(`/api/sessions/${sessionId}/timeline`);
+            this.renderTimeline(await response.json() || []);
+        } catch (error) {
+            console.error('Failed to load timeline:', error);
+        }
+    }
+
+    renderTimeline(entries) {
+        const contentEl = document.getElementById('timeline-content');
+
+        if (entries.length === 0) {
+            contentEl.innerHTML = '<div class="no-events">This session has no interactions.</div>';
+            return;
+        }
+
+        const startOf = entry => new Date(entry.started_at).getTime();
+        const t0 = Math.min(...entries.map(startOf));
+        const tEnd = Math.max(...entries.map(entry => startOf(entry) + entry.duration_ms));
+        const span = Math.max(tEnd - t0, 1);
+        const pct = ms => `${((ms / span) * 100).toFixed(3)}%`;
+
+        const axis = [0, 0.25, 0.5, 0.75, 1].map(fraction =>
+            `<span class="timeline-tick" style="left: ${fraction * 100}%">${this.formatDuration(fraction * span)}</span>`
+        ).join('');
+
+        const rows = entries.map((entry, index) => {
+            const offset = startOf(entry) - t0;
+            const classes = ['timeline-bar'];
+            if (entry.streaming) classes.push('streaming');
+            if (entry.status >= 400) classes.push('error');
+            if (entry.estimated) classes.push('estimated');
+
+            // Chunks are placed so the last one lands at the end of the span
+            const lastChunk = entry.chunk_offsets_ms?.length ? entry.chunk_offsets_ms[entry.chunk_offsets_ms.length - 1] : 0;
+            const firstChunkAt = offset + Math.max(entry.duration_ms - lastChunk, 0);
+            const ticks = (entry.chunk_offsets_ms || []).map(chunkOffset =>
+                `<span class="timeline-chunk" style="left: ${pct(firstChunkAt + chunkOffset)}"></span>`
+            ).join('');
+
+            const title = `${entry.method} ${entry.endpoint} -> ${entry.status}\n` +
+                `start +${this.formatDuration(offset)}, ${this.formatDuration(entry.duration_ms)}` +
+                (entry.streaming ? `, ${(entry.chunk_offsets_ms || []).length} chunks` : '') +
+                (entry.estimated ? ' (timing not recorded)' : '');
+
+            return `
+                <div class="timeline-row" data-index="${index}" title="${this.escapeHtml(title)}">
+                    <div class="timeline-label">
+                        <span class="event-method method-${entry.method}">${this.escapeHtml(entry.method)}</span>
+                        ${this.escapeHtml(entry.endpoint)}
+                    </div>
+                    <div class="timeline-track">
+                        <div class="${classes.join(' ')}" style="left: ${pct(offset)}; width: max(${pct(entry.duration_ms)}, 2px)"></div>
+                        ${ticks}
+                    </div>
+                </div>
+            `;
+        }).join('');
+
+        contentEl.innerHTML = `
+            <div class="timeline-row timeline-axis-row">
+                <div class="timeline-label">${entries.length} interactions over ${this.formatDuration(span)}</div>
+                <div class="timeline-axis">${axis}</div>
+            </div>
+            ${rows}
+        `;
+
+        contentEl.querySelectorAll('.timeline-row[data-index]').forEach(row => {
+            row.addEventListener('click', async () => {
+                const entry = entries[parseInt(row.dataset.index, 10)];
+                const response = await this.apiFetch(`/api/interactions/${entry.id}`);
+                if (response.ok) {
+                    this.showInteractionDetail(await response.json());
+                }
+            });
+        });
+    }
+
+    formatDuration(ms) {
+        if (ms < 1000) return `${ms.toFixed(0)}ms`;
+        if (ms < 60000) return `${(ms / 1000).toFixed(2)}s`;
+        return `${(ms / 60000).toFixed(1)}m`;
+    }
+
+    async loadSequences() {
+        try {
+            const response = await this.apiFetch('/api/sequences');
+            const entries = await response.json() || [];
+            this.renderSequences(entries);
+        } catch (error) {
+            console.error('Failed to load sequence state:', error);
+        }
+    }
+
+    renderSequences(entries) {
+        const listEl = document.getElementById('sequences-list');
+
+        if (entries.length === 0) {
+            listEl.innerHTML = '<div class="no-events">No sequence state yet. Mock proxies track positions once requests arrive.</div>';
+            return;
+        }
+
+        listEl.innerHTML = entries.map((entry, index) => `
+            <div class="interaction-item sequence-item">
+                <div class="interaction-header">
+                    <div>
+                        <span class="event-method method-${entry.method}">${this.escapeHtml(entry.method)}</span>
+                        <span class="interaction-endpoint">${this.escapeHtml(entry.path)}</span>
+                    </div>
+                    <button class="btn sequence-reset admin-only" data-index="${index}">Reset</button>
+                </div>
+                <div class="event-meta">
+                    <span>Proxy: ${this.escapeHtml(entry.proxy)}</span>
+                    <span>Position: ${entry.position}</span>
+                </div>
+                <div class="event-body">${this.escapeHtml(entry.signature)}</div>
+            </div>
+        `).join('');
+
+        listEl.querySelectorAll('.sequence-reset').forEach(button => {
+            button.addEventListener('click', () => {
+                const entry = entries[parseInt(button.dataset.index, 10)];
+                this.resetSequence(entry.proxy, entry.signature);
+            });
+        });
+    }
+
+    async resetSequence(proxy, signature) {
+        try {
+            const response = await this.apiFetch('/api/sequences/reset', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ proxy, signature })
+            });
+            if (!response.ok) {
+                alert(`Failed to reset sequence: ${await response.text()}`);
+            }
+            this.loadSequences();
+        } catch (error) {
+            console.error('Failed to reset sequence:', error);
+        }
+    }
+
+    updateConformanceSessionSelect() {
+        const select = document.getElementById('conformance-session');
+        const selected = select.value || this.currentSession;
+        select.innerHTML = this.sessions.map(session =>
+            `<option value="${session.id}">${this.escapeHtml(session.session_name)}</option>`).join('');
+        if (selected) select.value = selected;
+    }
+
+    async checkConformance(sessionId) {
+        if (!sessionId) return;
+        const reportEl = document.getElementById('conformance-report');
+        try {
+            const response = await this.apiFetch(`/api/sessions/${sessionId}/conformance`);
+            if (!response.ok) {
+                reportEl.innerHTML = `<div class="no-events">${this.escapeHtml(await response.text())}</div>`;
+                return;
+            }
+            const report = await response.json();
+            const summary = `
+                <div class="event-meta">
+                    <span>Spec: ${this.escapeHtml(report.spec)}</span>
+                    <span>Checked: ${report.checked} of ${report.interactions}</span>
+                    <span>Conforming: ${report.conforming}</span>
+                    <span>Violations: ${report.violations.length}</span>
+                </div>`;
+            const uncovered = report.uncovered.length === 0 ? '' : `
+                <div class="event-body">Not exercised: ${report.uncovered.map(op => this.escapeHtml(op)).join(', ')}</div>`;
+            reportEl.innerHTML = summary + uncovered + this.renderViolationItems(report.violations);
+        } catch (error) {
+            console.error('Failed to check conformance:', error);
+        }
+    }
+
+    async loadViolations() {
+        try {
+            const response = await this.apiFetch('/api/conformance');
+            if (!response.ok) return;
+            const events = await response.json() || [];
+
+            document.getElementById('violation-count').textContent = events.length > 0 ? events.length : '';
+            const listEl = document.getElementById('violations-list');
+            if (events.length === 0) {
+                listEl.innerHTML = '<div class="no-events">No violations seen.</div>';
+                return;
+            }
+            listEl.innerHTML = this.renderViolationItems(events.slice().reverse());
+        } catch (error) {
+            console.error('Failed to load violations:', error);
+        }
+    }
+
+    renderViolationItems(violations) {
+        return violations.map(v => `
+            <div class="interaction-item">
+                <div class="interaction-header">
+                    <div>
+                        <span class="event-method method-${v.method}">${this.escapeHtml(v.method)}</span>
+                        <span class="interaction-endpoint">${this.escapeHtml(v.path)}</span>
+                    </div>
+                    <span class="badge">${this.escapeHtml(v.kind)}</span>
+                </div>
+                <div class="event-meta">
+                    ${v.proxy ? `<span>Proxy: ${this.escapeHtml(v.proxy)}</span>` : ''}
+                    ${v.session ? `<span>Session: ${this.escapeHtml(v.session)}</span>` : ''}
+                    ${v.operation ? `<span>Operation: ${this.escapeHtml(v.operation)}</span>` : ''}
+                    ${v.status ? `<span>Status: ${v.status}</span>` : ''}
+                    ${v.interaction_id ? `<span>Interaction: ${v.interaction_id}</span>` : ''}
+                </div>
+                <div class="event-body">${this.escapeHtml(v.message)}</div>
+            </div>
+        `).join('');
+    }
+
+    updateBreakpointProxySelect() {
+        const select = document.getElementById('breakpoint-proxy');
+        const current = select.value;
+        select.innerHTML = '<option value="">All proxies</option>' +
+            this.proxies.map(p => `<option value="${this.escapeHtml(p.name)}">${this.escapeHtml(p.name)}</option>`).join('');
+        select.value = current;
+    }
+
+    async loadIntercepts() {
+        try {
+            const [breakpointsResponse, pausedResponse] = await Promise.all([
+                this.apiFetch('/api/intercept/breakpoints'),
+                this.apiFetch('/api/intercept/paused')
+            ]);
+            if (!breakpointsResponse.ok || !pausedResponse.ok) {
+                return;
+            }
+            const breakpoints = await breakpointsResponse.json() || [];
+            const paused = await pausedResponse.json() || [];
+
+            document.getElementById('paused-count').textContent = paused.length > 0 ? paused.length : '';
+            this.renderBreakpoints(breakpoints);
+            this.renderPaused(paused);
+        } catch (error) {
+            console.error('Failed to load intercepts:', error);
+        }
+    }
+
+    async addBreakpoint() {
+        const breakpoint = {
+            proxy: document.getElementById('breakpoint-proxy').value,
+            method: document.getElementById('breakpoint-method').value.trim().toUpperCase(),
+            endpoint: document.getElementById('breakpoint-endpoint').value.trim(),
+            stage: document.getElementById('breakpoint-stage').value
+        };
+        try {
+            const response = await this.apiFetch('/api/intercept/breakpoints', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(breakpoint)
+            });
+            if (!response.ok) {
+                alert(`Failed to add breakpoint: ${await response.text()}`);
+                return;
+            }
+            this.loadIntercepts();
+        } catch (error) {
+            console.error('Failed to add breakpoint:', error);
+        }
+    }
+
+    renderBreakpoints(breakpoints) {
+        const listEl = document.getElementById('breakpoints-list');
+        if (breakpoints.length === 0) {
+            listEl.innerHTML = '<div class="no-events">No breakpoints. Matching traffic flows through untouched.</div>';
+            return;
+        }
+
+        listEl.innerHTML = breakpoints.map(bp => `
+            <div class="breakpoint-item">
+                <span class="event-method">${this.escapeHtml(bp.method || 'ANY')}</span>
+                <span class="interaction-endpoint">${this.escapeHtml(bp.endpoint || '.*')}</span>
+                <span class="event-meta">
+                    <span>Proxy: ${this.escapeHtml(bp.proxy || 'all')}</span>
+                    <span>Stage: ${this.escapeHtml(bp.stage)}</span>
+                </span>
+                <button class="btn btn-danger remove-breakpoint admin-only" data-id="${bp.id}">Remove</button>
+            </div>
+        `).join('');
+
+        listEl.querySelectorAll('.remove-breakpoint').forEach(button => {
+            button.addEventListener('click', async () => {
+                await this.apiFetch(`/api/intercept/breakpoints/${button.dataset.id}`, { method: 'DELETE' });
+                this.loadIntercepts();
+            });
+        });
+    }
+
+    renderPaused(paused) {
+        const listEl = document.getElementById('paused-list');
+
+        // Keep in-progress edits: only re-render cards that appeared or disappeared
+        const ids = new Set(paused.map(exchange => exchange.id));
+        listEl.querySelectorAll('.paused-item').forEach(card => {
+            if (!ids.has(card.dataset.id)) {
+                card.remove();
+            }
+        });
+
+        paused.forEach(exchange => {
+            if (listEl.querySelector(`.paused-item[data-id="${exchange.id}"]`)) {
+                return;
+            }
+            listEl.insertAdjacentHTML('beforeend', this.renderPausedExchange(exchange));
+            const card = listEl.querySelector(`.paused-item[data-id="${exchange.id}"]`);
+            card.querySelectorAll('[data-action]').forEach(button => {
+                button.addEventListener('click', () => {
+                    this.resolvePaused(exchange, card, button.dataset.action);
+                });
+            });
+        });
+
+        const empty = listEl.querySelector('.no-events');
+        if (paused.length === 0 && !empty) {
+            listEl.innerHTML = '<div class="no-events">Nothing is paused.</div>';
+        } else if (paused.length > 0 && empty) {
+            empty.remove();
+        }
+    }
+
+    renderPausedExchange(exchange) {
+        const isRequest = exchange.stage === 'request';
+        const deadline = new Date(exchange.deadline).toLocaleTimeString();
+        return `
+            <div class="interaction-item paused-item" data-id="${exchange.id}">
+                <div class="interaction-header">
+                    <div>
+                        <span class="event-type">${this.escapeHtml(exchange.stage)}</span>
+                        <span class="event-method method-${exchange.method}">${this.escapeHtml(exchange.method)}</span>
+                        <span class="interaction-endpoint">${this.escapeHtml(exchange.endpoint)}</span>
+                    </div>
+                    <span class="event-meta">Proxy: ${this.escapeHtml(exchange.proxy)} · continues unchanged at ${deadline}</span>
+                </div>
+                <div class="paused-fields">
+                    ${isRequest ? `
+                        <label>Method <input type="text" class="paused-method" value="${this.escapeHtml(exchange.method)}"></label>
+                        <label>Path <input type="text" class="paused-endpoint" value="${this.escapeHtml(exchange.endpoint)}"></label>
+                        <label>Query <input type="text" class="paused-query" value="${this.escapeHtml(exchange.query || '')}"></label>
+                    ` : `
+                        <label>Status <input type="number" class="paused-status" value="${exchange.status}"></label>
+                    `}
+                    <label>Headers (JSON)
+                        <textarea class="notes-input paused-headers" rows="4">${this.escapeHtml(JSON.stringify(exchange.headers || {}, null, 2))}</textarea>
+                    </label>
+                    <label>Body
+                        <textarea class="notes-input paused-body" rows="6">${this.escapeHtml(exchange.body || '')}</textarea>
+                    </label>
+                </div>
+                <div class="admin-only">
+                    <button class="btn" data-action="edit">Continue with edits</button>
+                    <button class="btn" data-action="continue">Continue unchanged</button>
+                    <button class="btn btn-danger" data-action="abort">Abort</button>
+                    <label>Abort status <input type="number" class="paused-abort-status" value="503"></label>
+                </div>
+            </div>
+        `;
+    }
+
+    async resolvePaused(exchange, card, action) {
+        const decision = { action: action === 'abort' ? 'abort' : 'continue' };
+
+        if (action === 'abort') {
+            decision.status = parseInt(card.querySelector('.paused-abort-status').value, 10) || 503;
+        } else if (action === 'edit') {
+            try {
+                decision.headers = JSON.parse(card.querySelector('.paused-headers').value || '{}');
+            } catch (error) {
+                alert(`Headers must be a JSON object: ${error.message}`);
+                return;
+            }
+            decision.body = card.querySelector('.paused-body').value;
+            if (exchange.stage === 'request') {
+                decision.method = card.querySelector('.paused-method').value.trim();
+                decision.endpoint = card.querySelector('.paused-endpoint').value.trim();
+                decision.query = card.querySelector('.paused-query').value.trim();
+            } else {
+                decision.status = parseInt(card.querySelector('.paused-status').value, 10) || exchange.status;
+            }
+        }
+
+        try {
+            const response = await this.apiFetch(`/api/intercept/paused/${exchange.id}`, {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify(decision)
+            });
+            if (!response.ok) {
+                alert(`Failed to resume: ${await response.text()}`);
+            }
+            this.loadIntercepts();
+        } catch (error) {
+            console.error('Failed to resume exchange:', error);
+        }
+    }
+
+    async loadMetrics() {
+        try {
+            const response = await this.apiFetch('/api/metrics');
+            this.renderMetrics(await response.json());
+        } catch (error) {
+            console.error('Failed to load metrics:', error);
+        }
+    }
+
+    renderMetrics(snapshot) {
+        const contentEl = document.getElementById('metrics-content');
+        const proxies = snapshot.proxies || [];
+        const bucketSeconds = snapshot.bucket_seconds || 1;
+
+        document.getElementById('metrics-window').textContent =
+            `Since ${new Date(snapshot.started_at).toLocaleString()}, ${bucketSeconds}s buckets`;
+
+        if (proxies.length === 0) {
+            contentEl.innerHTML = '<div class="no-events">No traffic yet.</div>';
+            return;
+        }
+
+        const ratio = (part, whole) => whole > 0 ? `${((part / whole) * 100).toFixed(1)}%` : '-';
+
+        const rows = proxies.map(proxy => {
+            const t = proxy.totals;
+            const lookups = t.mock_hits + t.mock_misses;
+            return `
+                <tr>
+                    <td>${this.escapeHtml(proxy.name)}</td>
+                    <td>${t.requests}</td>
+                    <td>${t.mock_hits} / ${t.mock_misses} (${ratio(t.mock_hits, lookups)})</td>
+                    <td>${t.status_4xx}</td>
+                    <td>${t.status_5xx}</td>
+                    <td>${t.recorded} (${this.formatBytes(t.recorded_bytes)})</td>
+                </tr>
+            `;
+        }).join('');
+
+        const charts = proxies.map(proxy => `
+            <div class="metrics-chart">
+                <h4>${this.escapeHtml(proxy.name)}</h4>
+                ${this.renderSparkline('Requests/s', proxy.history.map(p => p.requests / bucketSeconds), 'metrics-line-requests')}
+                ${this.renderSparkline('Errors (4xx+5xx)/s', proxy.history.map(p => (p.status_4xx + p.status_5xx) / bucketSeconds), 'metrics-line-errors')}
+                ${this.renderSparkline('Mock misses/s', proxy.history.map(p => p.mock_misses / bucketSeconds), 'metrics-line-misses')}
+                ${this.renderSparkline('Recorded/s', proxy.history.map(p => p.recorded / bucketSeconds), 'metrics-line-recorded')}
+            </div>
+        `).join('');
+
+        contentEl.innerHTML = `
+            <table class="metrics-table">
+                <thead>
+                    <tr>
+                        <th>Proxy</th>
+                        <th>Requests</th>
+                        <th>Mock hits / misses</th>
+                        <th>4xx</th>
+                        <th>5xx</th>
+                        <th>Recorded</th>
+                    </tr>
+                </thead>
+                <tbody>${rows}</tbody>
+            </table>
+            <div class="metrics-charts">${charts}</div>
+        `;
+    }
+
+    renderSparkline(label, values, lineClass) {
+        const width = 300;
+        const height = 40;
+        const max = Math.max(...values, 0);
+        const step = values.length > 1 ? width / (values.length - 1) : width;
+        const points = values.map((value, i) => {
+            const y = max > 0 ? height - (value / max) * height : height;
+            return `${(i * step).toFixed(1)},${y.toFixed(1)}`;
+        }).join(' ');
+        const latest = values.length > 0 ? values[values.length - 1] : 0;
+
+        return `
+            <div class="sparkline">
+                <div class="sparkline-label">${label}: ${latest.toFixed(2)} (peak ${max.toFixed(2)})</div>
+                <svg viewBox="0 0 ${width} ${height}" preserveAspectRatio="none">
+                    <polyline class="${lineClass}" fill="none" stroke-width="1.5" points="${points}"></polyline>
+                </svg>
+            </div>
+        `;
+    }
+
+    formatBytes(bytes) {
+        if (bytes < 1024) return `${bytes} B`;
+        if (bytes < 1024 * 1024) return `${(bytes / 1024).toFixed(1)} KB`;
+        return `${(bytes / (1024 * 1024)).toFixed(1)} MB`;
+    }
+
+    // decodeBase64 turns a JSON-encoded []byte field back into text
+    decodeBase64(value) {
+        if (!value) return '';
+        try {
+            const binary = atob(value);
+            const bytes = Uint8Array.from(binary, c => c.charCodeAt(0));
+            return new TextDecoder().decode(bytes);
+        } catch (error) {
+            return String(value);
+        }
+    }
+
+    prettyBody(text) {
+        try {
+            return JSON.stringify(JSON.parse(text), null, 2);
+        } catch (error) {
+            return text;
+        }
+    }
+
+    // lineDiff computes a simple LCS-based line diff between two texts
+    lineDiff(before, after) {
+        const a = before ? before.split('\n') : [];
+        const b = after ? after.split('\n') : [];
+
+        // Fall back to a plain listing for very large bodies to keep the UI responsive
+        if (a.length * b.length > 1000000) {
+            return a.map(text => ({ op: '-', text })).concat(b.map(text => ({ op: '+', text })));
+        }
+
+        const lcs = Array.from({ length: a.length + 1 }, () => new Array(b.length + 1).fill(0));
+        for (let i = a.length - 1; i >= 0; i--) {
+            for (let j = b.length - 1; j >= 0; j--) {
+                lcs[i][j] = a[i] === b[j] ? lcs[i + 1][j + 1] + 1 : Math.max(lcs[i + 1][j], lcs[i][j + 1]);
+            }
+        }
+
+        const lines = [];
+        let i = 0, j = 0;
+        while (i < a.length && j < b.length) {
+            if (a[i] === b[j]) {
+                lines.push({ op: ' ', text: a[i] });
+                i++; j++;
+            } else if (lcs[i + 1][j] >= lcs[i][j + 1]) {
+                lines.push({ op: '-', text: a[i++] });
+            } else {
+                lines.push({ op: '+', text: b[j++] });
+            }
+        }
+        while (i < a.length) lines.push({ op: '-', text: a[i++] });
+        while (j < b.length) lines.push({ op: '+', text: b[j++] });
+        return lines;
+    }
+
     escapeHtml(text) {
         const div = document.createElement('div');
         div.textContent = text;
@@ -0,0 +1,38 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"mimic/stub"
+)
+
+// handleStubs saves stubs defined by hand into their sessions (POST, with a stub file as the
+// JSON body)
+func (s *Server) handleStubs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var file stub.File
+	if err := json.NewDecoder(r.Body).Decode(&file); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := file.Validate(); err != nil {
+		http.Error(w, fmt.Sprintf("Invalid stubs: %v", err), http.StatusBadRequest)
+		return
+	}
+	saved, err := stub.Save(s.database, &file)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to save stubs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	s.BroadcastEvent("stubs_saved", map[string]interface{}{"session": file.Session, "saved": saved})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]int{"saved": saved})
+}
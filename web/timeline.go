@@ -0,0 +1,82 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// TimelineEntry is one interaction positioned in time for the session timeline view
+type TimelineEntry struct {
+	ID         int       `json:"id"`
+	RequestID  string    `json:"request_id"`
+	Protocol   string    `json:"protocol"`
+	Method     string    `json:"method"`
+	Endpoint   string    `json:"endpoint"`
+	Status     int       `json:"status"`
+	StartedAt  time.Time `json:"started_at"`
+	DurationMs float64   `json:"duration_ms"`
+	Streaming  bool      `json:"streaming"`
+	// ChunkOffsetsMs holds each stream chunk's arrival time relative to the first chunk
+	ChunkOffsetsMs []int64 `json:"chunk_offsets_ms,omitempty"`
+	// Estimated is set when the interaction predates timing capture and StartedAt is the record time
+	Estimated bool `json:"estimated"`
+}
+
+// handleSessionTimeline returns a session's interactions ordered by start time
+func (s *Server) handleSessionTimeline(w http.ResponseWriter, r *http.Request, sessionID int) {
+	interactions, err := s.database.GetInteractionsBySession(sessionID)
+	if err != nil {
+		http.Error(w, "Failed to get interactions", http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]TimelineEntry, 0, len(interactions))
+	for _, interaction := range interactions {
+		entry := TimelineEntry{
+			ID:        interaction.ID,
+			RequestID: interaction.RequestID,
+			Protocol:  interaction.Protocol,
+			Method:    interaction.Method,
+			Endpoint:  interaction.Endpoint,
+			Status:    interaction.ResponseStatus,
+			Streaming: interaction.IsStreaming,
+		}
+
+		startedAt, duration, ok := interaction.Timing()
+		if ok {
+			entry.StartedAt = startedAt
+			entry.DurationMs = float64(duration) / float64(time.Millisecond)
+		} else {
+			entry.StartedAt = interaction.Timestamp
+			entry.Estimated = true
+		}
+
+		if interaction.IsStreaming {
+			chunks, err := s.database.GetStreamChunks(interaction.ID)
+			if err == nil {
+				var offset int64
+				for i, chunk := range chunks {
+					if i > 0 {
+						offset += chunk.TimeDelta
+					}
+					entry.ChunkOffsetsMs = append(entry.ChunkOffsetsMs, offset)
+				}
+				// Without recorded timing the chunk spacing is the best estimate of the span
+				if !ok {
+					entry.DurationMs = float64(offset)
+				}
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].StartedAt.Before(entries[j].StartedAt)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
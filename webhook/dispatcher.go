@@ -0,0 +1,227 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"mimic/config"
+)
+
+const (
+	EventMockMiss          = "mock_miss"          // A mock proxy had no recording for a request
+	EventRecordingComplete = "recording_complete" // An interaction (or a finished stream) was recorded
+	EventSessionCleared    = "session_cleared"    // One or all sessions were deleted
+	EventReplayFinished    = "replay_finished"    // A replay run completed
+
+	defaultMaxRetries     = 3
+	defaultTimeoutSeconds = 10
+	queueSize             = 1024
+	workers               = 4
+)
+
+// Default is the process-wide dispatcher; it does nothing until configured
+var Default = NewDispatcher(time.Second)
+
+// Event is the JSON payload posted to webhooks
+type Event struct {
+	ID        string      `json:"id"`
+	Event     string      `json:"event"`
+	Timestamp time.Time   `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+type delivery struct {
+	hook    config.WebhookConfig
+	payload []byte
+	event   Event
+}
+
+// Dispatcher delivers events to the configured webhooks in the background, retrying failures
+type Dispatcher struct {
+	mutex   sync.RWMutex
+	hooks   []config.WebhookConfig
+	queue   chan delivery
+	pending sync.WaitGroup
+	backoff time.Duration // Delay before the first retry, doubled for each further retry
+	start   sync.Once
+}
+
+// NewDispatcher creates a dispatcher with the given initial retry backoff
+func NewDispatcher(backoff time.Duration) *Dispatcher {
+	return &Dispatcher{
+		queue:   make(chan delivery, queueSize),
+		backoff: backoff,
+	}
+}
+
+// Configure replaces the webhooks events are delivered to
+func (d *Dispatcher) Configure(hooks []config.WebhookConfig) {
+	d.mutex.Lock()
+	d.hooks = hooks
+	d.mutex.Unlock()
+
+	if len(hooks) > 0 {
+		d.start.Do(func() {
+			for i := 0; i < workers; i++ {
+				go d.work()
+			}
+		})
+		log.Printf("Configured %d webhook(s)", len(hooks))
+	}
+}
+
+// Emit queues an event for every webhook subscribed to it without blocking the caller
+func (d *Dispatcher) Emit(eventType string, data interface{}) {
+	d.mutex.RLock()
+	hooks := d.hooks
+	d.mutex.RUnlock()
+
+	if len(hooks) == 0 {
+		return
+	}
+
+	event := Event{
+		ID:        uuid.New().String(),
+		Event:     eventType,
+		Timestamp: time.Now(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		log.Printf("Error encoding webhook event %s: %v", eventType, err)
+		return
+	}
+
+	for _, hook := range hooks {
+		if !subscribed(hook, eventType) {
+			continue
+		}
+		d.pending.Add(1)
+		select {
+		case d.queue <- delivery{hook: hook, payload: payload, event: event}:
+		default:
+			d.pending.Done()
+			log.Printf("Webhook queue full, dropping %s event for %s", eventType, hook.URL)
+		}
+	}
+}
+
+// Wait blocks until queued deliveries finish or the timeout passes; short-lived commands call it before exiting
+func (d *Dispatcher) Wait(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		d.pending.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Printf("Timed out waiting for webhook deliveries")
+	}
+}
+
+func (d *Dispatcher) work() {
+	for item := range d.queue {
+		d.deliver(item)
+		d.pending.Done()
+	}
+}
+
+// deliver posts one event, retrying network errors, 429s, and 5xx responses with exponential backoff
+func (d *Dispatcher) deliver(item delivery) {
+	maxRetries := item.hook.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	timeout := item.hook.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = defaultTimeoutSeconds
+	}
+	client := &http.Client{Timeout: time.Duration(timeout) * time.Second}
+
+	delay := d.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := d.post(client, item)
+		if err == nil {
+			return
+		}
+		if !retry || attempt >= maxRetries {
+			log.Printf("Webhook %s delivery of %s failed after %d attempt(s): %v", item.hook.URL, item.event.Event, attempt+1, err)
+			return
+		}
+		time.Sleep(delay)
+		delay *= 2
+	}
+}
+
+// post makes a single delivery attempt and reports whether a failure is worth retrying
+func (d *Dispatcher) post(client *http.Client, item delivery) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, item.hook.URL, bytes.NewReader(item.payload))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mimic-webhook")
+	req.Header.Set("X-Mimic-Event", item.event.Event)
+	req.Header.Set("X-Mimic-Delivery", item.event.ID)
+	for key, value := range item.hook.Headers {
+		req.Header.Set(key, value)
+	}
+	if item.hook.Secret != "" {
+		req.Header.Set("X-Mimic-Signature", "sha256="+Sign(item.hook.Secret, item.payload))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send request: %w", err)
+	}
+	resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return false, nil
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		return true, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	default:
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+}
+
+// Sign returns the hex HMAC-SHA256 of a payload, as sent in X-Mimic-Signature
+func Sign(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func subscribed(hook config.WebhookConfig, eventType string) bool {
+	if len(hook.Events) == 0 {
+		return true
+	}
+	for _, event := range hook.Events {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// Configure sets the webhooks of the default dispatcher
+func Configure(hooks []config.WebhookConfig) {
+	Default.Configure(hooks)
+}
+
+// Emit sends an event through the default dispatcher
+func Emit(eventType string, data interface{}) {
+	Default.Emit(eventType, data)
+}
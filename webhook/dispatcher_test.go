@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"mimic/config"
+)
+
+func TestDeliversSignedEvents(t *testing.T) {
+	var mutex sync.Mutex
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if got, want := r.Header.Get("X-Mimic-Signature"), "sha256="+Sign("secret", body); got != want {
+			t.Errorf("Expected signature %s, got %s", want, got)
+		}
+		if r.Header.Get("Authorization") != "Bearer ci" {
+			t.Errorf("Expected configured header, got %q", r.Header.Get("Authorization"))
+		}
+
+		var event Event
+		json.Unmarshal(body, &event)
+		mutex.Lock()
+		received = append(received, event)
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(time.Millisecond)
+	d.Configure([]config.WebhookConfig{{
+		URL:     server.URL,
+		Events:  []string{EventMockMiss},
+		Headers: map[string]string{"Authorization": "Bearer ci"},
+		Secret:  "secret",
+	}})
+
+	d.Emit(EventMockMiss, MockMissEvent{Proxy: "api", Method: "GET", Endpoint: "/missing"})
+	d.Emit(EventSessionCleared, SessionClearedEvent{All: true}) // Not subscribed
+	d.Wait(5 * time.Second)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(received) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(received))
+	}
+	if received[0].Event != EventMockMiss {
+		t.Errorf("Expected mock_miss event, got %s", received[0].Event)
+	}
+}
+
+func TestRetriesServerErrors(t *testing.T) {
+	var mutex sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(time.Millisecond)
+	d.Configure([]config.WebhookConfig{{URL: server.URL, MaxRetries: 5}})
+	d.Emit(EventReplayFinished, ReplayFinishedEvent{Session: "s"})
+	d.Wait(5 * time.Second)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+}
+
+func TestDoesNotRetryClientErrors(t *testing.T) {
+	var mutex sync.Mutex
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		attempts++
+		mutex.Unlock()
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	d := NewDispatcher(time.Millisecond)
+	d.Configure([]config.WebhookConfig{{URL: server.URL}})
+	d.Emit(EventRecordingComplete, InteractionEvent{Proxy: "api"})
+	d.Wait(5 * time.Second)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if attempts != 1 {
+		t.Errorf("Expected a single attempt, got %d", attempts)
+	}
+}
@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"mimic/storage"
+)
+
+// InteractionEvent describes a recorded interaction without its bodies
+type InteractionEvent struct {
+	Proxy         string `json:"proxy"`
+	Session       string `json:"session"`
+	InteractionID int    `json:"interaction_id"`
+	RequestID     string `json:"request_id"`
+	Protocol      string `json:"protocol"`
+	Method        string `json:"method"`
+	Endpoint      string `json:"endpoint"`
+	Status        int    `json:"status"`
+	Streaming     bool   `json:"streaming"`
+}
+
+// MockMissEvent describes a request a mock proxy could not answer
+type MockMissEvent struct {
+	Proxy    string `json:"proxy"`
+	Session  string `json:"session"`
+	Protocol string `json:"protocol"`
+	Method   string `json:"method"`
+	Endpoint string `json:"endpoint"`
+}
+
+// SessionClearedEvent names the cleared session, or sets All when every session was removed
+type SessionClearedEvent struct {
+	Session string `json:"session,omitempty"`
+	All     bool   `json:"all"`
+}
+
+// RecordingComplete emits recording_complete for an interaction that has been stored
+func RecordingComplete(proxyName, sessionName string, interaction *storage.Interaction) {
+	Emit(EventRecordingComplete, InteractionEvent{
+		Proxy:         proxyName,
+		Session:       sessionName,
+		InteractionID: interaction.ID,
+		RequestID:     interaction.RequestID,
+		Protocol:      interaction.Protocol,
+		Method:        interaction.Method,
+		Endpoint:      interaction.Endpoint,
+		Status:        interaction.ResponseStatus,
+		Streaming:     interaction.IsStreaming,
+	})
+}
+
+// MockMiss emits mock_miss for an unmatched request
+func MockMiss(proxyName, sessionName, protocol, method, endpoint string) {
+	Emit(EventMockMiss, MockMissEvent{
+		Proxy:    proxyName,
+		Session:  sessionName,
+		Protocol: protocol,
+		Method:   method,
+		Endpoint: endpoint,
+	})
+}
+
+// SessionCleared emits session_cleared; an empty name means all sessions
+func SessionCleared(sessionName string) {
+	Emit(EventSessionCleared, SessionClearedEvent{Session: sessionName, All: sessionName == ""})
+}
+
+// ReplayFinishedEvent summarizes a finished replay
+type ReplayFinishedEvent struct {
+	RunID      string `json:"run_id,omitempty"` // Set for replays started from the web UI or API
+	Source     string `json:"source"`           // "api", "proxy", or "cli"
+	Session    string `json:"session"`
+	Target     string `json:"target"`
+	Total      int    `json:"total"`
+	Successes  int    `json:"successes"`
+	Failures   int    `json:"failures"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+// ReplayFinished emits replay_finished
+func ReplayFinished(event ReplayFinishedEvent) {
+	Emit(EventReplayFinished, event)
+}